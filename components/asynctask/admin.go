@@ -5,10 +5,12 @@ package asynctask
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
 )
 
 // ListTasksArgs 任务列表查询参数
@@ -113,30 +115,125 @@ func GetTaskExecRecordsWithStatus(taskId int64, status string, page, pageSize in
 	return records, total, nil
 }
 
-// RetryTask 手动重试任务（供管理后台使用）
+// RetryTask 手动重试已结束的任务（供管理后台使用），重试后立即执行。
+// 只有处于终态的任务可以重试：执行中的任务重试会被重复执行，Blocked 的下游任务会越过上游提前执行。
+// 任务链/任务组中因该任务失败而被终止的下游任务会恢复为 Blocked，随它重新推进。
 func RetryTask(taskId int64, ctx context.Context) error {
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
 		return err
 	}
+	return retryTaskInDB(db.GetGormDb().WithContext(ctx), taskId)
+}
 
-	return db.GetGormDb().WithContext(ctx).Table(taskTable).Where("id = ?", taskId).
-		Updates(map[string]any{
-			"task_status": TaskStatusWait.String(),
-			"retry_time":  0,
-			"update_time": time.Now(),
-		}).Error
+func retryTaskInDB(db *gorm.DB, taskId int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Table(taskTable).
+			Where("id = ? AND task_status IN ?", taskId, []string{TaskStatusSuccess.String(), TaskStatusFailed.String(),
+				TaskStatusDead.String(), TaskStatusCanceled.String(), TaskStatusTimedOut.String()}).
+			Updates(map[string]any{
+				"task_status": TaskStatusWait.String(),
+				"retry_time":  0,
+				"run_at":      nil,
+				"next_run_at": nil,
+				"aborted_by":  0,
+				"update_time": time.Now(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			task := TaskModel{}
+			if err := tx.Table(taskTable).Select("id", "task_status").Where("id = ?", taskId).Find(&task).Error; err != nil {
+				return err
+			}
+			if task.Id == 0 {
+				return fmt.Errorf("task %d not found", taskId)
+			}
+			return fmt.Errorf("task %d is %s, only finished tasks can be retried", taskId, task.TaskStatus)
+		}
+		if _, err := reviveAbortedDownstream(tx, []int64{taskId}); err != nil {
+			return err
+		}
+		return clearCancelRequest(tx, []int64{taskId})
+	})
 }
 
-// CancelTask 取消任务（供管理后台使用）
+// CancelTask 取消任务（供管理后台使用）。
+// 尚未执行的任务直接置为 Canceled；执行中的任务通过心跳表通知持有它的执行器（可能在其他副本上）
+// 取消业务方法的 ctx，由执行器记为 Canceled，因此返回时任务可能仍处于 Running。
+// 任务链/任务组中的下游任务按上游失败处理。
 func CancelTask(taskId int64, ctx context.Context) error {
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
 		return err
 	}
 
-	return db.GetGormDb().WithContext(ctx).Table(taskTable).Where("id = ?", taskId).
-		UpdateColumn("task_status", TaskStatusFailed.String()).Error
+	task, err := getTaskById(taskId, ctx)
+	if err != nil {
+		return err
+	}
+	if task.Id == 0 {
+		return fmt.Errorf("task %d not found", taskId)
+	}
+	if isFinalStatus(task.TaskStatus) {
+		return fmt.Errorf("task %d has already finished with status %s", taskId, task.TaskStatus)
+	}
+	if task.TaskStatus == TaskStatusRunning.String() {
+		return RequestTaskCancel(taskId, ctx)
+	}
+
+	running, err := cancelPendingTaskInDB(db.GetGormDb().WithContext(ctx), taskId)
+	if err != nil {
+		return err
+	}
+	if running {
+		// 状态已变化，刚被抢占执行，交给执行器处理
+		return RequestTaskCancel(taskId, ctx)
+	}
+
+	task.TaskStatus = TaskStatusCanceled.String()
+	released, err := settleDownstream(task, ctx)
+	if scheduler := GetScheduler(task.SystemName); scheduler != nil {
+		for _, model := range released {
+			quickQueueIfReady(scheduler, model)
+		}
+	}
+	return err
+}
+
+// cancelPendingTaskInDB 把尚未执行的任务置为 Canceled。状态在读取后被并发修改时重新读取：
+// 已被抢占执行时返回 running=true，由调用方通知执行器取消；已结束或已删除时返回错误。
+func cancelPendingTaskInDB(db *gorm.DB, taskId int64) (running bool, err error) {
+	res := db.Table(taskTable).
+		Where("id = ? AND task_status IN ?", taskId,
+			[]string{TaskStatusWait.String(), TaskStatusRetry.String(), TaskStatusBlocked.String()}).
+		Updates(map[string]any{
+			"task_status":  TaskStatusCanceled.String(),
+			"last_err_msg": "任务已被取消",
+			"next_run_at":  nil,
+			"update_time":  time.Now(),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return false, nil
+	}
+	task := TaskModel{}
+	if err := db.Table(taskTable).Select("id", "task_status").Where("id = ?", taskId).Find(&task).Error; err != nil {
+		return false, err
+	}
+	switch {
+	case task.Id == 0:
+		return false, fmt.Errorf("task %d not found", taskId)
+	case task.TaskStatus == TaskStatusRunning.String():
+		return true, nil
+	case isFinalStatus(task.TaskStatus):
+		return false, fmt.Errorf("task %d has already finished with status %s", taskId, task.TaskStatus)
+	default:
+		return false, fmt.Errorf("task %d changed to %s while canceling, please retry", taskId, task.TaskStatus)
+	}
 }
 
 // GetAllSchedulerStatus 获取所有调度器状态（供管理后台使用）
@@ -25,7 +25,7 @@ func RegisterAsyncTaskAdminServer(s grpc.ServiceRegistrar) {
 }
 
 func (s *AsyncTaskAdminServer) SubmitTask(ctx context.Context, req *asynctaskpb.SubmitTaskReq) (*asynctaskpb.SubmitTaskResp, error) {
-	task, err := SubmitTask(req.Theme, TaskBaseInfo{
+	task := TaskBaseInfo{
 		ServiceName:  req.ServiceName,
 		MethodName:   req.MethodName,
 		TaskName:     req.TaskName,
@@ -33,11 +33,26 @@ func (s *AsyncTaskAdminServer) SubmitTask(ctx context.Context, req *asynctaskpb.
 		Priority:     req.Priority,
 		TenantId:     req.TenantId,
 		MaxRetryTime: int(req.MaxRetry),
-	})
+
+		RetryBackoff:         req.RetryBackoff,
+		RetryDelaySeconds:    int(req.RetryDelaySeconds),
+		RetryMaxDelaySeconds: int(req.RetryMaxDelaySeconds),
+
+		DedupKey:           req.DedupKey,
+		DedupMode:          req.DedupMode,
+		DedupWindowSeconds: int(req.DedupWindowSeconds),
+
+		TimeoutSeconds: int(req.TimeoutSeconds),
+	}
+	if req.RunAt != nil {
+		runAt := req.RunAt.AsTime()
+		task.RunAt = &runAt
+	}
+	model, err := SubmitTask(req.Theme, task)
 	if err != nil {
 		return nil, err
 	}
-	return &asynctaskpb.SubmitTaskResp{Id: task.Id}, nil
+	return &asynctaskpb.SubmitTaskResp{Id: model.Id}, nil
 }
 
 func (s *AsyncTaskAdminServer) GetTask(ctx context.Context, req *asynctaskpb.GetTaskReq) (*asynctaskpb.TaskItem, error) {
@@ -111,21 +126,80 @@ func (s *AsyncTaskAdminServer) ListRecords(ctx context.Context, req *asynctaskpb
 	}
 	items := make([]*asynctaskpb.TaskRecordItem, 0, len(records))
 	for _, r := range records {
-		items = append(items, &asynctaskpb.TaskRecordItem{
-			Id:           r.Id,
-			TaskId:       r.TaskId,
-			TaskName:     r.TaskName,
-			Status:       r.TaskStatus,
-			ErrorMessage: r.LastErrMsg,
-			DurationMs:   r.LastRunDuration,
-			CreatedAt:    timeToProto(r.LastRunTime),
-			LogId:        r.LogId,
-			Result:       r.LastResult,
-		})
+		items = append(items, taskRecordToProto(r))
 	}
 	return &asynctaskpb.ListRecordsResp{Records: items, Total: total}, nil
 }
 
+func (s *AsyncTaskAdminServer) ListDeadTasks(ctx context.Context, req *asynctaskpb.ListTasksReq) (*asynctaskpb.ListTasksResp, error) {
+	args := ListTasksArgs{
+		Page:     int(req.Page),
+		PageSize: int(req.PageSize),
+		TaskName: req.TaskName,
+		TenantId: req.TenantId,
+	}
+	if args.Page <= 0 {
+		args.Page = 1
+	}
+	if args.PageSize <= 0 {
+		args.PageSize = 20
+	}
+	result, err := ListDeadTasks(args, req.Theme, ctx)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*asynctaskpb.TaskItem, 0, len(result.List))
+	for _, t := range result.List {
+		tasks = append(tasks, taskModelToProto(t))
+	}
+	return &asynctaskpb.ListTasksResp{Tasks: tasks, Total: result.Total}, nil
+}
+
+func (s *AsyncTaskAdminServer) GetDeadTask(ctx context.Context, req *asynctaskpb.GetTaskReq) (*asynctaskpb.DeadTaskDetail, error) {
+	detail, err := GetDeadTask(req.Id, ctx)
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]*asynctaskpb.TaskRecordItem, 0, len(detail.Attempts))
+	for _, r := range detail.Attempts {
+		r.TaskName = detail.Task.TaskName
+		attempts = append(attempts, taskRecordToProto(r))
+	}
+	return &asynctaskpb.DeadTaskDetail{Task: taskModelToProto(detail.Task), Attempts: attempts}, nil
+}
+
+func (s *AsyncTaskAdminServer) GetTaskGraph(ctx context.Context, req *asynctaskpb.GetTaskReq) (*asynctaskpb.TaskGraph, error) {
+	graph, err := GetTaskGraph(req.Id, ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*asynctaskpb.TaskItem, 0, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		nodes = append(nodes, taskModelToProto(n))
+	}
+	edges := make([]*asynctaskpb.TaskGraphEdge, 0, len(graph.Edges))
+	for _, e := range graph.Edges {
+		edges = append(edges, &asynctaskpb.TaskGraphEdge{From: e.From, To: e.To})
+	}
+	return &asynctaskpb.TaskGraph{RootId: graph.RootId, Nodes: nodes, Edges: edges}, nil
+}
+
+func (s *AsyncTaskAdminServer) RequeueDeadTasks(ctx context.Context, req *asynctaskpb.DeadTasksReq) (*asynctaskpb.DeadTasksResp, error) {
+	affected, err := RequeueDeadTasks(req.Theme, req.Ids, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &asynctaskpb.DeadTasksResp{Affected: affected}, nil
+}
+
+func (s *AsyncTaskAdminServer) PurgeDeadTasks(ctx context.Context, req *asynctaskpb.DeadTasksReq) (*asynctaskpb.DeadTasksResp, error) {
+	affected, err := PurgeDeadTasks(req.Theme, req.Ids, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &asynctaskpb.DeadTasksResp{Affected: affected}, nil
+}
+
 func (s *AsyncTaskAdminServer) GetStats(ctx context.Context, _ *asynctaskpb.Empty) (*asynctaskpb.TaskStatsResp, error) {
 	var pending, running, success, failed int64
 	for _, sch := range GetAllSchedulerInfo() {
@@ -216,7 +290,38 @@ func taskModelToProto(t TaskModel) *asynctaskpb.TaskItem {
 		LastRunEndTime:  nullTimeToProto(t.LastRunEndTime),
 		LastRunDuration: t.LastRunDuration,
 		LastResult:      t.LastResult,
+		RunAt:           timePtrToProto(t.RunAt),
+		NextRunAt:       nullTimeToProto(t.NextRunAt),
+		DedupKey:        t.DedupKey,
+		RootId:          t.RootId,
+		ParentId:        t.ParentId,
+		GroupId:         t.GroupId,
+		CallbackGroupId: t.CallbackGroupId,
+		UpstreamPolicy:  t.UpstreamPolicy,
+		TimeoutSeconds:  int32(t.TimeoutSeconds),
+	}
+}
+
+func taskRecordToProto(r TaskExecModel) *asynctaskpb.TaskRecordItem {
+	return &asynctaskpb.TaskRecordItem{
+		Id:           r.Id,
+		TaskId:       r.TaskId,
+		TaskName:     r.TaskName,
+		Status:       r.TaskStatus,
+		ErrorMessage: r.LastErrMsg,
+		DurationMs:   r.LastRunDuration,
+		CreatedAt:    timeToProto(r.LastRunTime),
+		LogId:        r.LogId,
+		Result:       r.LastResult,
+		Attempt:      int32(r.Attempt),
+	}
+}
+
+func timePtrToProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
 	}
+	return timeToProto(*t)
 }
 
 func timeToProto(t time.Time) *timestamppb.Timestamp {
@@ -6,6 +6,7 @@ package asynctask
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/xxzhwl/gaia/framework/server"
 )
@@ -19,9 +20,15 @@ func RegisterAsyncTaskAdminRoutes(g *server.Server, authMid server.Plugin) {
 	v1.GET("/tasks", server.MakePlugin(authMid), server.MakeHandler(listTasks()))
 	v1.POST("/tasks/:id/retry", server.MakePlugin(authMid), server.MakeHandler(retryTask()))
 	v1.POST("/tasks/:id/cancel", server.MakePlugin(authMid), server.MakeHandler(cancelTask()))
+	v1.GET("/tasks/:id/graph", server.MakePlugin(authMid), server.MakeHandler(getTaskGraph()))
 
 	v1.GET("/records", server.MakePlugin(authMid), server.MakeHandler(listRecords()))
 
+	v1.GET("/dead-tasks", server.MakePlugin(authMid), server.MakeHandler(listDeadTasks()))
+	v1.GET("/dead-tasks/:id", server.MakePlugin(authMid), server.MakeHandler(getDeadTask()))
+	v1.POST("/dead-tasks/requeue", server.MakePlugin(authMid), server.MakeHandler(requeueDeadTasks()))
+	v1.POST("/dead-tasks/purge", server.MakePlugin(authMid), server.MakeHandler(purgeDeadTasks()))
+
 	v1.GET("/schedulers", server.MakePlugin(authMid), server.MakeHandler(listSchedulers()))
 	v1.GET("/schedulers/:theme", server.MakePlugin(authMid), server.MakeHandler(getScheduler()))
 	v1.POST("/schedulers/:theme/start", server.MakePlugin(authMid), server.MakeHandler(startScheduler()))
@@ -41,6 +48,18 @@ func submitTask() func(req server.Request) (any, error) {
 			Priority    int32  `json:"priority"`
 			TenantId    string `json:"tenant_id"`
 			MaxRetry    int    `json:"max_retry"`
+			// RunAt 最早执行时间（RFC3339），为空表示立即执行
+			RunAt *time.Time `json:"run_at"`
+			// 重试退避策略 fixed/exponential/jitter 及其基础延迟、延迟上限（秒）
+			RetryBackoff         string `json:"retry_backoff"`
+			RetryDelaySeconds    int    `json:"retry_delay_seconds"`
+			RetryMaxDelaySeconds int    `json:"retry_max_delay_seconds"`
+			// 去重键、去重模式 keep/replace/latest 及去重窗口（秒）
+			DedupKey           string `json:"dedup_key"`
+			DedupMode          string `json:"dedup_mode"`
+			DedupWindowSeconds int    `json:"dedup_window_seconds"`
+			// TimeoutSeconds 单次执行超时（秒），<=0 表示不限制
+			TimeoutSeconds int `json:"timeout_seconds"`
 		}
 		if err := req.BindJsonWithChecker(&body); err != nil {
 			return nil, err
@@ -53,6 +72,17 @@ func submitTask() func(req server.Request) (any, error) {
 			Priority:     body.Priority,
 			TenantId:     body.TenantId,
 			MaxRetryTime: body.MaxRetry,
+			RunAt:        body.RunAt,
+
+			RetryBackoff:         body.RetryBackoff,
+			RetryDelaySeconds:    body.RetryDelaySeconds,
+			RetryMaxDelaySeconds: body.RetryMaxDelaySeconds,
+
+			DedupKey:           body.DedupKey,
+			DedupMode:          body.DedupMode,
+			DedupWindowSeconds: body.DedupWindowSeconds,
+
+			TimeoutSeconds: body.TimeoutSeconds,
 		})
 		if err != nil {
 			return nil, err
@@ -147,6 +177,84 @@ func listRecords() func(req server.Request) (any, error) {
 	}
 }
 
+func listDeadTasks() func(req server.Request) (any, error) {
+	return func(req server.Request) (any, error) {
+		page, _ := strconv.ParseInt(req.GetUrlQuery("page"), 10, 64)
+		pageSize, _ := strconv.ParseInt(req.GetUrlQuery("page_size"), 10, 64)
+		args := ListTasksArgs{
+			Page:     int(page),
+			PageSize: int(pageSize),
+			TaskName: req.GetUrlQuery("task_name"),
+			TenantId: req.GetUrlQuery("tenant_id"),
+		}
+		if args.Page <= 0 {
+			args.Page = 1
+		}
+		if args.PageSize <= 0 {
+			args.PageSize = 20
+		}
+		result, err := ListDeadTasks(args, req.GetUrlQuery("theme"), req.TraceContext)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"tasks": result.List, "total": result.Total}, nil
+	}
+}
+
+func getTaskGraph() func(req server.Request) (any, error) {
+	return func(req server.Request) (any, error) {
+		id, err := strconv.ParseInt(req.GetUrlParam("id"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("[400]invalid task id")
+		}
+		return GetTaskGraph(id, req.TraceContext)
+	}
+}
+
+func getDeadTask() func(req server.Request) (any, error) {
+	return func(req server.Request) (any, error) {
+		id, err := strconv.ParseInt(req.GetUrlParam("id"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("[400]invalid task id")
+		}
+		return GetDeadTask(id, req.TraceContext)
+	}
+}
+
+// deadTasksBody 死信批量操作参数，ids 为空表示作用于 theme 下的全部死信任务
+type deadTasksBody struct {
+	Theme string  `json:"theme" require:"1"`
+	Ids   []int64 `json:"ids"`
+}
+
+func requeueDeadTasks() func(req server.Request) (any, error) {
+	return func(req server.Request) (any, error) {
+		var body deadTasksBody
+		if err := req.BindJsonWithChecker(&body); err != nil {
+			return nil, err
+		}
+		affected, err := RequeueDeadTasks(body.Theme, body.Ids, req.TraceContext)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"affected": affected}, nil
+	}
+}
+
+func purgeDeadTasks() func(req server.Request) (any, error) {
+	return func(req server.Request) (any, error) {
+		var body deadTasksBody
+		if err := req.BindJsonWithChecker(&body); err != nil {
+			return nil, err
+		}
+		affected, err := PurgeDeadTasks(body.Theme, body.Ids, req.TraceContext)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"affected": affected}, nil
+	}
+}
+
 func listSchedulers() func(req server.Request) (any, error) {
 	return func(req server.Request) (any, error) {
 		return GetAllSchedulerInfo(), nil
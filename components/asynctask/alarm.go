@@ -45,11 +45,16 @@ func NewAlarmThrottle(window time.Duration) *AlarmThrottle {
 // Send 发送告警；同一窗口内同 key 的告警只发首次，其余仅累加计数，
 // 在下一窗口的下一次调用时输出聚合信息。
 func (a *AlarmThrottle) Send(title, content string) {
+	a.SendWithKey(alarmKey(title, content), title, content)
+}
+
+// SendWithKey 与 Send 相同，但由调用方指定去重 key；
+// 用于内容中带有任务 ID 等易变信息、但仍希望按类别聚合的告警。
+func (a *AlarmThrottle) SendWithKey(key, title, content string) {
 	if a == nil {
 		_ = gaia.SendSystemAlarm(title, content)
 		return
 	}
-	key := alarmKey(title, content)
 	now := time.Now()
 
 	a.mu.Lock()
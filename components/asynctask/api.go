@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
 )
 
 // StartScheduler 一键创建并启动调度器。
@@ -41,10 +42,69 @@ func SubmitTask(theme string, task TaskBaseInfo) (TaskModel, error) {
 		return TaskModel{}, err
 	}
 
-	scheduler.TaskQuickQueue(model.Id)
+	quickQueueIfReady(scheduler, model)
 	return model, nil
 }
 
+// quickQueueIfReady 对已到执行时间的待执行任务做快速入队。
+// 延迟任务由扫描在到期后拉起；去重命中的已有任务可能已在执行或已结束，等待上游的任务由上游结束后释放。
+func quickQueueIfReady(scheduler *Scheduler, model TaskModel) {
+	if model.TaskStatus == TaskStatusWait.String() && (model.RunAt == nil || !model.RunAt.After(time.Now())) {
+		scheduler.TaskQuickQueue(model.Id)
+	}
+}
+
+// SubmitChain 快捷提交任务链并尝试快速入队首个任务。
+// 后一个任务在前一个任务结束后执行，可通过 UpstreamResults 读取前一个任务的结果；
+// policy 为上游未成功时的处理策略 abort/continue，为空等同 abort。
+func SubmitChain(theme string, tasks []TaskBaseInfo, policy string) ([]TaskModel, error) {
+	scheduler := GetScheduler(theme)
+	if scheduler == nil {
+		return nil, fmt.Errorf("scheduler %s not found", theme)
+	}
+
+	models, err := AddChain(tasks, theme, policy, context.Background())
+	if err != nil {
+		return nil, err
+	}
+	quickQueueIfReady(scheduler, models[0])
+	return models, nil
+}
+
+// SubmitGroup 快捷提交任务组并尝试快速入队组内任务，组内任务全部结束后执行 callback（可为空）。
+func SubmitGroup(theme string, tasks []TaskBaseInfo, callback *TaskBaseInfo, policy string) (TaskGroup, error) {
+	scheduler := GetScheduler(theme)
+	if scheduler == nil {
+		return TaskGroup{}, fmt.Errorf("scheduler %s not found", theme)
+	}
+
+	group, err := AddGroup(tasks, callback, theme, policy, context.Background())
+	if err != nil {
+		return TaskGroup{}, err
+	}
+	for _, model := range group.Members {
+		quickQueueIfReady(scheduler, model)
+	}
+	return group, nil
+}
+
+// SubmitTaskAt 提交一个在指定时间之后才会执行的任务。
+func SubmitTaskAt(theme string, task TaskBaseInfo, runAt time.Time) (TaskModel, error) {
+	if runAt.IsZero() {
+		return TaskModel{}, fmt.Errorf("runAt must not be zero")
+	}
+	task.RunAt = &runAt
+	return SubmitTask(theme, task)
+}
+
+// SubmitTaskAfter 提交一个延迟 delay 后执行的任务。
+func SubmitTaskAfter(theme string, task TaskBaseInfo, delay time.Duration) (TaskModel, error) {
+	if delay < 0 {
+		return TaskModel{}, fmt.Errorf("delay must not be negative")
+	}
+	return SubmitTaskAt(theme, task, time.Now().Add(delay))
+}
+
 // SubmitTaskWithRetry 快捷提交带重试配置的任务。
 func SubmitTaskWithRetry(theme, serviceName, methodName, taskName, arg string, maxRetry int) (TaskModel, error) {
 	return SubmitTask(theme, TaskBaseInfo{
@@ -80,13 +140,24 @@ func GetTask(taskId int64) (TaskModel, error) {
 	return task, nil
 }
 
-// IsTaskDone 判断任务是否已完成。
+// isFinalStatus 任务是否已处于终态（成功、失败、死信、取消或超时）。
+func isFinalStatus(status string) bool {
+	switch status {
+	case TaskStatusSuccess.String(), TaskStatusFailed.String(), TaskStatusDead.String(),
+		TaskStatusCanceled.String(), TaskStatusTimedOut.String():
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTaskDone 判断任务是否已完成（成功、失败、进入死信、被取消或超时）。
 func IsTaskDone(taskId int64) (bool, error) {
 	status, err := GetTaskStatus(taskId)
 	if err != nil {
 		return false, err
 	}
-	return status == TaskStatusSuccess.String() || status == TaskStatusFailed.String(), nil
+	return isFinalStatus(status), nil
 }
 
 // IsTaskSuccess 判断任务是否执行成功。
@@ -118,7 +189,7 @@ func WaitTaskDone(taskId int64, timeout time.Duration) (TaskModel, error) {
 		if task.Id == 0 {
 			return TaskModel{}, fmt.Errorf("task %d not found", taskId)
 		}
-		if task.TaskStatus == TaskStatusSuccess.String() || task.TaskStatus == TaskStatusFailed.String() {
+		if isFinalStatus(task.TaskStatus) {
 			return task, nil
 		}
 
@@ -132,12 +203,16 @@ func WaitTaskDone(taskId int64, timeout time.Duration) (TaskModel, error) {
 
 // TaskCountByStatus 按状态统计任务数量。
 type TaskCountByStatus struct {
-	Wait    int64 `json:"wait"`
-	Running int64 `json:"running"`
-	Success int64 `json:"success"`
-	Failed  int64 `json:"failed"`
-	Retry   int64 `json:"retry"`
-	Total   int64 `json:"total"`
+	Wait     int64 `json:"wait"`
+	Running  int64 `json:"running"`
+	Success  int64 `json:"success"`
+	Failed   int64 `json:"failed"`
+	Retry    int64 `json:"retry"`
+	Dead     int64 `json:"dead"`
+	Blocked  int64 `json:"blocked"`
+	Canceled int64 `json:"canceled"`
+	TimedOut int64 `json:"timed_out"`
+	Total    int64 `json:"total"`
 }
 
 type taskStatusCountRow struct {
@@ -174,6 +249,14 @@ func GetTaskCountByStatus(theme string) (TaskCountByStatus, error) {
 			result.Failed = row.Total
 		case TaskStatusRetry.String():
 			result.Retry = row.Total
+		case TaskStatusDead.String():
+			result.Dead = row.Total
+		case TaskStatusBlocked.String():
+			result.Blocked = row.Total
+		case TaskStatusCanceled.String():
+			result.Canceled = row.Total
+		case TaskStatusTimedOut.String():
+			result.TimedOut = row.Total
 		}
 		result.Total += row.Total
 	}
@@ -181,7 +264,7 @@ func GetTaskCountByStatus(theme string) (TaskCountByStatus, error) {
 	return result, nil
 }
 
-// CleanFinishedTasks 清理指定时间之前的已完成任务及关联记录。
+// CleanFinishedTasks 清理指定时间之前的已完成任务及关联记录；死信任务需通过 PurgeDeadTasks 清理。
 func CleanFinishedTasks(theme string, olderThan time.Duration) (int64, error) {
 	if olderThan <= 0 {
 		return 0, fmt.Errorf("olderThan must be greater than 0")
@@ -199,7 +282,8 @@ func CleanFinishedTasks(theme string, olderThan time.Duration) (int64, error) {
 	taskIds := make([]int64, 0)
 	if err := db.GetGormDb().WithContext(ctx).Table(taskTable).
 		Where("system_name = ?", theme).
-		Where("task_status IN ?", []string{TaskStatusSuccess.String(), TaskStatusFailed.String()}).
+		Where("task_status IN ?", []string{TaskStatusSuccess.String(), TaskStatusFailed.String(),
+			TaskStatusCanceled.String(), TaskStatusTimedOut.String()}).
 		Where("update_time < ?", cutoff).
 		Pluck("id", &taskIds).Error; err != nil {
 		return 0, err
@@ -209,7 +293,12 @@ func CleanFinishedTasks(theme string, olderThan time.Duration) (int64, error) {
 		return 0, nil
 	}
 
-	tx := db.GetGormDb().WithContext(ctx).Begin()
+	return deleteTasksWithRecords(db.GetGormDb().WithContext(ctx), taskIds)
+}
+
+// deleteTasksWithRecords 在一个事务内删除任务及其执行记录、心跳记录，返回删除的任务数。
+func deleteTasksWithRecords(db *gorm.DB, taskIds []int64) (int64, error) {
+	tx := db.Begin()
 	if tx.Error != nil {
 		return 0, tx.Error
 	}
@@ -0,0 +1,87 @@
+// Package asynctask 注释
+// @author wanlizhan
+// @created 2026/10/19
+package asynctask
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// RetryBackoffFixed 固定间隔重试
+	RetryBackoffFixed = "fixed"
+	// RetryBackoffExponential 指数退避：BaseDelay * 2^(n-1)
+	RetryBackoffExponential = "exponential"
+	// RetryBackoffJitter 带抖动的指数退避：在指数退避值的 [1/2, 1] 区间内随机，避免大量任务同时重试
+	RetryBackoffJitter = "jitter"
+
+	DefaultRetryBaseDelay = time.Second
+	DefaultRetryMaxDelay  = time.Hour
+)
+
+// RetryBackoffPolicy 重试退避策略
+type RetryBackoffPolicy struct {
+	Strategy  string        // fixed/exponential/jitter，为空表示立即重试
+	BaseDelay time.Duration // 首次重试的延迟；指数退避下 <=0 时使用 DefaultRetryBaseDelay
+	MaxDelay  time.Duration // 延迟上限；指数退避下 <=0 时使用 DefaultRetryMaxDelay
+}
+
+// retryBackoffPolicy 返回任务自身配置的退避策略；任务未配置时使用 fallback（通常来自调度器）。
+func (t TaskBaseInfo) retryBackoffPolicy(fallback RetryBackoffPolicy) RetryBackoffPolicy {
+	if t.RetryBackoff == "" {
+		return fallback
+	}
+	return RetryBackoffPolicy{
+		Strategy:  t.RetryBackoff,
+		BaseDelay: time.Duration(t.RetryDelaySeconds) * time.Second,
+		MaxDelay:  time.Duration(t.RetryMaxDelaySeconds) * time.Second,
+	}
+}
+
+// Delay 返回第 attempt 次重试（从 1 开始）前需要等待的时间。
+func (p RetryBackoffPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	switch p.Strategy {
+	case RetryBackoffFixed:
+		if p.BaseDelay < 0 {
+			return 0
+		}
+		if p.MaxDelay > 0 && p.BaseDelay > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return p.BaseDelay
+	case RetryBackoffExponential, RetryBackoffJitter:
+		base, maxDelay := p.BaseDelay, p.MaxDelay
+		if base <= 0 {
+			base = DefaultRetryBaseDelay
+		}
+		if maxDelay <= 0 {
+			maxDelay = DefaultRetryMaxDelay
+		}
+		delay := base
+		for i := 1; i < attempt && delay < maxDelay; i++ {
+			delay *= 2
+		}
+		delay = min(delay, maxDelay)
+		if p.Strategy == RetryBackoffJitter {
+			half := delay / 2
+			delay = half + rand.N(delay-half+1)
+		}
+		return delay
+	default:
+		return 0
+	}
+}
+
+// validRetryBackoff 校验退避策略名称
+func validRetryBackoff(strategy string) bool {
+	switch strategy {
+	case "", RetryBackoffFixed, RetryBackoffExponential, RetryBackoffJitter:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,56 @@
+package asynctask
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBackoffPolicyDelay(t *testing.T) {
+	fixed := RetryBackoffPolicy{Strategy: RetryBackoffFixed, BaseDelay: 3 * time.Second}
+	if got := fixed.Delay(5); got != 3*time.Second {
+		t.Fatalf("fixed Delay(5) = %v, want 3s", got)
+	}
+
+	exp := RetryBackoffPolicy{Strategy: RetryBackoffExponential, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 100: 10 * time.Second} {
+		if got := exp.Delay(attempt); got != want {
+			t.Fatalf("exponential Delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	jitter := RetryBackoffPolicy{Strategy: RetryBackoffJitter, BaseDelay: time.Second, MaxDelay: time.Minute}
+	for i := 0; i < 100; i++ {
+		if got := jitter.Delay(3); got < 2*time.Second || got > 4*time.Second {
+			t.Fatalf("jitter Delay(3) = %v, want within [2s, 4s]", got)
+		}
+	}
+
+	if got := (RetryBackoffPolicy{}).Delay(3); got != 0 {
+		t.Fatalf("empty policy Delay() = %v, want immediate retry", got)
+	}
+}
+
+func TestTaskRetryBackoffPolicy(t *testing.T) {
+	fallback := RetryBackoffPolicy{Strategy: RetryBackoffFixed, BaseDelay: time.Minute}
+	if got := (TaskBaseInfo{}).retryBackoffPolicy(fallback); got != fallback {
+		t.Fatalf("retryBackoffPolicy() = %+v, want scheduler fallback", got)
+	}
+	task := TaskBaseInfo{RetryBackoff: RetryBackoffExponential, RetryDelaySeconds: 2, RetryMaxDelaySeconds: 30}
+	want := RetryBackoffPolicy{Strategy: RetryBackoffExponential, BaseDelay: 2 * time.Second, MaxDelay: 30 * time.Second}
+	if got := task.retryBackoffPolicy(fallback); got != want {
+		t.Fatalf("retryBackoffPolicy() = %+v, want %+v", got, want)
+	}
+	if validRetryBackoff("linear") {
+		t.Fatal("unknown strategy should be rejected")
+	}
+}
+
+func TestAlarmThrottleSendWithKey(t *testing.T) {
+	throttle := NewAlarmThrottle(time.Minute)
+	before := GetMetrics().localCounters.alarmSuppress.Load()
+	throttle.SendWithKey("dead:svc", "dead letter", "task 1 failed")
+	throttle.SendWithKey("dead:svc", "dead letter", "task 2 failed")
+	if got := GetMetrics().localCounters.alarmSuppress.Load() - before; got != 1 {
+		t.Fatalf("suppressed alarms = %d, want 1 for the same key", got)
+	}
+}
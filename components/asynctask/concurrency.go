@@ -0,0 +1,197 @@
+// Package asynctask 注释
+// @author wanlizhan
+// @created 2026/10/19
+package asynctask
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const concurrencyLockTable = "async_task_concurrency_lock"
+
+// ConcurrencyLockModel 并发上限的互斥行。
+// 抢占受限任务时先锁定对应行，再统计 DB 中 Running 的任务数，保证多副本下计数与抢占是串行的。
+type ConcurrencyLockModel struct {
+	LockKey  string    `gorm:"column:lock_key;size:191;primaryKey"`
+	UpdateAt time.Time `gorm:"column:update_time"`
+}
+
+func (ConcurrencyLockModel) TableName() string { return concurrencyLockTable }
+
+// ConcurrencyLimits 并发上限配置，按 theme 生效。
+// 计数基于 DB 中处于 Running 的任务，因此跨副本生效；同一 theme 的所有副本需配置一致。
+type ConcurrencyLimits struct {
+	// Service 按 ServiceName 限制并发
+	Service map[string]int
+	// Tenant 按 TenantId 限制并发，优先于 DefaultTenant
+	Tenant map[string]int
+	// DefaultTenant 未单独配置的租户的并发上限，<=0 表示不限制；TenantId 为空的任务不受限
+	DefaultTenant int
+}
+
+// concurrencyCheck 一项需要校验的并发上限
+type concurrencyCheck struct {
+	key    string
+	column string
+	value  string
+	limit  int
+}
+
+func (l ConcurrencyLimits) enabled() bool {
+	return len(l.Service) > 0 || len(l.Tenant) > 0 || l.DefaultTenant > 0
+}
+
+func (l ConcurrencyLimits) tenantLimit(tenantId string) int {
+	if tenantId == "" {
+		return 0
+	}
+	if v, ok := l.Tenant[tenantId]; ok {
+		return v
+	}
+	return l.DefaultTenant
+}
+
+// checksFor 返回任务需要校验的并发上限，按 key 排序以保证各副本加锁顺序一致，避免死锁。
+func (l ConcurrencyLimits) checksFor(theme string, task TaskModel) []concurrencyCheck {
+	checks := make([]concurrencyCheck, 0, 2)
+	if limit := l.Service[task.ServiceName]; limit > 0 {
+		checks = append(checks, concurrencyCheck{key: fmt.Sprintf("%s:service:%s", theme, task.ServiceName),
+			column: "service_name", value: task.ServiceName, limit: limit})
+	}
+	if limit := l.tenantLimit(task.TenantId); limit > 0 {
+		checks = append(checks, concurrencyCheck{key: fmt.Sprintf("%s:tenant:%s", theme, task.TenantId),
+			column: "tenant_id", value: task.TenantId, limit: limit})
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].key < checks[j].key })
+	return checks
+}
+
+type runningCountRow struct {
+	Name  string `gorm:"column:name"`
+	Total int64  `gorm:"column:total"`
+}
+
+// saturatedFilter 查询已达到并发上限的服务与租户，扫描时跳过它们，避免受限任务挤占扫描批次。
+// 这里只是预筛，最终以 tryLockTaskWithLimits 中的加锁计数为准。
+func saturatedFilter(theme string, limits ConcurrencyLimits, ctx context.Context) (scanFilter, error) {
+	filter := scanFilter{}
+	if !limits.enabled() {
+		return filter, nil
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return filter, err
+	}
+
+	if len(limits.Service) > 0 {
+		services := make([]string, 0, len(limits.Service))
+		for name, limit := range limits.Service {
+			if limit > 0 {
+				services = append(services, name)
+			}
+		}
+		rows := make([]runningCountRow, 0)
+		if len(services) > 0 {
+			if err := db.GetGormDb().WithContext(ctx).Table(taskTable).
+				Select("service_name as name, count(*) as total").
+				Where("system_name = ? AND task_status = ?", theme, TaskStatusRunning.String()).
+				Where("service_name IN ?", services).
+				Group("service_name").Find(&rows).Error; err != nil {
+				return filter, err
+			}
+		}
+		for _, row := range rows {
+			if row.Total >= int64(limits.Service[row.Name]) {
+				filter.ExcludeServices = append(filter.ExcludeServices, row.Name)
+			}
+		}
+	}
+
+	if len(limits.Tenant) > 0 || limits.DefaultTenant > 0 {
+		rows := make([]runningCountRow, 0)
+		if err := db.GetGormDb().WithContext(ctx).Table(taskTable).
+			Select("tenant_id as name, count(*) as total").
+			Where("system_name = ? AND task_status = ?", theme, TaskStatusRunning.String()).
+			Where("tenant_id <> ''").
+			Group("tenant_id").Find(&rows).Error; err != nil {
+			return filter, err
+		}
+		for _, row := range rows {
+			if limit := limits.tenantLimit(row.Name); limit > 0 && row.Total >= int64(limit) {
+				filter.ExcludeTenants = append(filter.ExcludeTenants, row.Name)
+			}
+		}
+	}
+	return filter, nil
+}
+
+// tryLockTaskWithLimits 在并发上限内抢占任务。
+// 对每个上限先 upsert 互斥行（持有行锁直到事务结束），再统计 Running 数，未超限才将任务置为 Running。
+// 任务状态最终由带条件的 UPDATE 保证，这里事务外读取的服务与租户只用于确定需要校验的上限。
+// limited 为 true 表示因并发上限未能抢占，任务保持原状态等待下一轮扫描。
+func tryLockTaskWithLimits(taskId int64, theme string, limits ConcurrencyLimits, ctx context.Context) (flag, limited bool, err error) {
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return false, false, err
+	}
+	return tryLockTaskWithLimitsInDB(db.GetGormDb().WithContext(ctx), taskId, theme, limits)
+}
+
+func tryLockTaskWithLimitsInDB(db *gorm.DB, taskId int64, theme string, limits ConcurrencyLimits) (flag, limited bool, err error) {
+	task := TaskModel{}
+	if err := db.Table(taskTable).Select("id", "service_name", "tenant_id", "task_status").
+		Where("id = ?", taskId).Find(&task).Error; err != nil {
+		return false, false, err
+	}
+	if task.Id == 0 ||
+		(task.TaskStatus != TaskStatusWait.String() && task.TaskStatus != TaskStatusRetry.String()) {
+		return false, false, nil
+	}
+
+	// 事务内第一条一致性读必须发生在拿到全部互斥行锁之后，否则 REPEATABLE READ 下计数会读到加锁前的快照
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		checks := limits.checksFor(theme, task)
+		for _, check := range checks {
+			if err := tx.Table(concurrencyLockTable).Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "lock_key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"update_time"}),
+			}).Create(&ConcurrencyLockModel{LockKey: check.key, UpdateAt: now}).Error; err != nil {
+				return err
+			}
+		}
+		for _, check := range checks {
+			var running int64
+			if err := tx.Table(taskTable).
+				Where("system_name = ? AND task_status = ?", theme, TaskStatusRunning.String()).
+				Where(check.column+" = ?", check.value).
+				Count(&running).Error; err != nil {
+				return err
+			}
+			if running >= int64(check.limit) {
+				limited = true
+				return nil
+			}
+		}
+
+		res := whereRunnable(tx.Table(taskTable).Where(map[string]any{"id": taskId,
+			"task_status": []string{TaskStatusWait.String(), TaskStatusRetry.String()}}), now).
+			UpdateColumn("task_status", TaskStatusRunning.String())
+		if res.Error != nil {
+			return res.Error
+		}
+		flag = res.RowsAffected > 0
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+	return flag, limited, nil
+}
@@ -0,0 +1,126 @@
+package asynctask
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimitsChecksFor(t *testing.T) {
+	s := &Scheduler{}
+	s.Apply(WithServiceConcurrency("Report", 3), WithDefaultTenantConcurrency(10), WithTenantConcurrency("vip", 0))
+
+	checks := s.Concurrency.checksFor("sys", TaskModel{TaskBaseInfo: TaskBaseInfo{ServiceName: "Report", TenantId: "t1"}})
+	if len(checks) != 2 {
+		t.Fatalf("checksFor() = %+v, want service and tenant checks", checks)
+	}
+	if checks[0].key != "sys:service:Report" || checks[0].limit != 3 || checks[1].key != "sys:tenant:t1" || checks[1].limit != 10 {
+		t.Fatalf("checksFor() = %+v, want sorted service and tenant checks", checks)
+	}
+
+	// 单独配置为 0 的租户不受默认上限约束，无租户的任务也不受租户上限约束
+	if checks := s.Concurrency.checksFor("sys", TaskModel{TaskBaseInfo: TaskBaseInfo{ServiceName: "Other", TenantId: "vip"}}); len(checks) != 0 {
+		t.Fatalf("checksFor() = %+v, want no checks for unlimited tenant", checks)
+	}
+	if checks := s.Concurrency.checksFor("sys", TaskModel{TaskBaseInfo: TaskBaseInfo{ServiceName: "Other"}}); len(checks) != 0 {
+		t.Fatalf("checksFor() = %+v, want no checks without tenant", checks)
+	}
+	if (ConcurrencyLimits{}).enabled() {
+		t.Fatal("empty limits should be disabled")
+	}
+}
+
+func TestSubmitTaskAtValidatesTime(t *testing.T) {
+	if _, err := SubmitTaskAt("missing-scheduler", TaskBaseInfo{}, time.Time{}); err == nil {
+		t.Fatal("SubmitTaskAt() expected error for zero runAt")
+	}
+	if _, err := SubmitTaskAfter("missing-scheduler", TaskBaseInfo{}, -time.Second); err == nil {
+		t.Fatal("SubmitTaskAfter() expected error for negative delay")
+	}
+}
+
+func TestTryLockTaskWithLimitsInDB(t *testing.T) {
+	db := newSqliteTestDB(t)
+	now := time.Now()
+	tasks := []TaskModel{
+		{TaskBaseInfo: TaskBaseInfo{ServiceName: "Report"}, SystemName: "sys", TaskStatus: TaskStatusWait.String(), CreateAt: now},
+		{TaskBaseInfo: TaskBaseInfo{ServiceName: "Report"}, SystemName: "sys", TaskStatus: TaskStatusWait.String(), CreateAt: now},
+	}
+	if err := db.Table(taskTable).Create(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	limits := ConcurrencyLimits{Service: map[string]int{"Report": 1}}
+
+	flag, limited, err := tryLockTaskWithLimitsInDB(db, tasks[0].Id, "sys", limits)
+	if err != nil || !flag || limited {
+		t.Fatalf("first lock = (%v, %v, %v), want (true, false, nil)", flag, limited, err)
+	}
+	flag, limited, err = tryLockTaskWithLimitsInDB(db, tasks[1].Id, "sys", limits)
+	if err != nil || flag || !limited {
+		t.Fatalf("second lock = (%v, %v, %v), want (false, true, nil)", flag, limited, err)
+	}
+
+	var locks []ConcurrencyLockModel
+	if err := db.Table(concurrencyLockTable).Find(&locks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 1 || locks[0].LockKey != "sys:service:Report" {
+		t.Fatalf("lock rows = %+v, want one row for sys:service:Report", locks)
+	}
+	if got := taskStatusOf(t, db, tasks[0].Id); got != TaskStatusRunning.String() {
+		t.Fatalf("first task status = %s, want Running", got)
+	}
+	if got := taskStatusOf(t, db, tasks[1].Id); got != TaskStatusWait.String() {
+		t.Fatalf("limited task status = %s, want Wait", got)
+	}
+
+	// 名额释放后第二个任务可以抢占，互斥行被复用而不是重复插入
+	if err := db.Table(taskTable).Where("id = ?", tasks[0].Id).
+		UpdateColumn("task_status", TaskStatusSuccess.String()).Error; err != nil {
+		t.Fatal(err)
+	}
+	flag, limited, err = tryLockTaskWithLimitsInDB(db, tasks[1].Id, "sys", limits)
+	if err != nil || !flag || limited {
+		t.Fatalf("lock after release = (%v, %v, %v), want (true, false, nil)", flag, limited, err)
+	}
+	var lockCount int64
+	if err := db.Table(concurrencyLockTable).Count(&lockCount).Error; err != nil {
+		t.Fatal(err)
+	}
+	if lockCount != 1 {
+		t.Fatalf("lock rows = %d, want 1", lockCount)
+	}
+}
+
+func TestFindNeedRunTaskIdsInDBOrdersByPriority(t *testing.T) {
+	db := newSqliteTestDB(t)
+	now := time.Now()
+	future := now.Add(time.Hour)
+	tasks := []TaskModel{
+		{TaskBaseInfo: TaskBaseInfo{ServiceName: "Low"}, SystemName: "sys", TaskStatus: TaskStatusWait.String(), CreateAt: now},
+		{TaskBaseInfo: TaskBaseInfo{ServiceName: "High", Priority: 10}, SystemName: "sys", TaskStatus: TaskStatusRetry.String(), CreateAt: now},
+		{TaskBaseInfo: TaskBaseInfo{ServiceName: "Delayed", Priority: 20, RunAt: &future}, SystemName: "sys", TaskStatus: TaskStatusWait.String(), CreateAt: now},
+		{TaskBaseInfo: TaskBaseInfo{ServiceName: "Other", Priority: 30}, SystemName: "other", TaskStatus: TaskStatusWait.String(), CreateAt: now},
+	}
+	if err := db.Table(taskTable).Create(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ids, needContinue, err := findNeedRunTaskIdsInDB(db, 10, "sys", scanFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{tasks[1].Id, tasks[0].Id}; !reflect.DeepEqual(ids, want) || needContinue {
+		t.Fatalf("findNeedRunTaskIdsInDB() = (%v, %v), want (%v, false)", ids, needContinue, want)
+	}
+
+	// 批次打满时提示继续扫描，被限流的服务不参与扫描
+	ids, needContinue, err = findNeedRunTaskIdsInDB(db, 1, "sys", scanFilter{})
+	if err != nil || !reflect.DeepEqual(ids, []int64{tasks[1].Id}) || !needContinue {
+		t.Fatalf("findNeedRunTaskIdsInDB(limit 1) = (%v, %v, %v), want ([%d], true, nil)", ids, needContinue, err, tasks[1].Id)
+	}
+	ids, _, err = findNeedRunTaskIdsInDB(db, 10, "sys", scanFilter{ExcludeServices: []string{"High"}})
+	if err != nil || !reflect.DeepEqual(ids, []int64{tasks[0].Id}) {
+		t.Fatalf("findNeedRunTaskIdsInDB(exclude High) = (%v, %v), want [%d]", ids, err, tasks[0].Id)
+	}
+}
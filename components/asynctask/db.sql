@@ -13,6 +13,21 @@ create table asynctasks
     max_retry_time    int          default 0                 not null,
     priority          int          default 0                 not null comment '优先级',
     tenant_id         varchar(64)  default ''                not null comment '租户ID',
+    run_at            datetime(3)                            null comment '最早执行时间',
+    retry_backoff     varchar(16)  default ''                not null comment '重试退避策略 fixed/exponential/jitter',
+    retry_delay_seconds     int    default 0                 not null comment '退避基础延迟（秒）',
+    retry_max_delay_seconds int    default 0                 not null comment '退避延迟上限（秒）',
+    next_run_at       datetime(3)                            null comment '重试退避后的下次执行时间',
+    dedup_key         varchar(128)                           null comment '去重键，同一系统内唯一',
+    dedup_mode        varchar(16)  default ''                not null comment '去重模式 keep/replace/latest',
+    dedup_window_seconds    int    default 0                 not null comment '去重窗口（秒）',
+    timeout_seconds   int          default 0                 not null comment '单次执行超时（秒）',
+    root_id           bigint       default 0                 not null comment '所属任务链/任务组的首个任务id',
+    parent_id         bigint       default 0                 not null comment '任务链中的上游任务id',
+    group_id          bigint       default 0                 not null comment '所属任务组id',
+    callback_group_id bigint       default 0                 not null comment '回调任务等待的任务组id',
+    upstream_policy   varchar(16)  default ''                not null comment '上游失败策略 abort/continue',
+    aborted_by        bigint       default 0                 not null comment '按abort策略终止该任务的上游任务id',
     retry_time        int          default 0                 not null,
     last_result       longtext                               null,
     last_err_msg      varchar(512) default ''                not null,
@@ -47,12 +62,37 @@ create index asynctasks_priority_index
 create index asynctasks_tenant_id_index
     on asynctasks (tenant_id);
 
+create index asynctasks_run_at_index
+    on asynctasks (run_at);
+
+create index asynctasks_next_run_at_index
+    on asynctasks (next_run_at);
+
+create unique index asynctasks_dedup_key_uindex
+    on asynctasks (system_name, dedup_key);
+
+create index asynctasks_root_id_index
+    on asynctasks (root_id);
+
+create index asynctasks_parent_id_index
+    on asynctasks (parent_id);
+
+create index asynctasks_group_id_index
+    on asynctasks (group_id);
+
+create index asynctasks_callback_group_id_index
+    on asynctasks (callback_group_id);
+
+create index asynctasks_aborted_by_index
+    on asynctasks (aborted_by);
+
 
 CREATE TABLE `async_task_heartbeat` (
                                         `id` int(11) NOT NULL AUTO_INCREMENT,
                                         `task_id` int(11) NOT NULL COMMENT '异步任务id',
                                         `heart_beat_time` datetime(3) DEFAULT NULL COMMENT '心跳时间',
                                         `heart_beat_nano_time` bigint(20) DEFAULT '0',
+                                        `cancel_requested` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否请求取消执行',
                                         PRIMARY KEY (`id`),
                                         UNIQUE KEY `async_task_heartbeat_task_id_uindex` (`task_id`)
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8 COLLATE=utf8_bin COMMENT='异步任务心跳表';
@@ -61,12 +101,20 @@ CREATE TABLE `asynctask_exec_row` (
                                       `id` bigint NOT NULL AUTO_INCREMENT,
                                       `task_id` bigint NOT NULL DEFAULT '0' COMMENT '任务id',
                                       `task_status` varchar(32) NOT NULL DEFAULT 'Wait',
+                                      `attempt` int NOT NULL DEFAULT '0' COMMENT '第几次执行',
                                       `last_result` longtext,
                                       `last_err_msg` varchar(512) NOT NULL DEFAULT '',
                                       `last_run_time` datetime(3) DEFAULT NULL COMMENT '最后一次运行时间',
                                       `last_run_end_time` datetime(3) DEFAULT NULL COMMENT '最后一次运行结束时间',
                                       `last_run_duration` int NOT NULL DEFAULT '0' COMMENT '最后一次运行时长',
                                       `log_id` varchar(128) NOT NULL DEFAULT '' COMMENT '日志id',
+                                      `next_run_at` datetime(3) DEFAULT NULL COMMENT '计划的下次执行时间',
                                       PRIMARY KEY (`id`),
                                       KEY `asynctasks_create_time_index` (`last_run_time`)
-) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8 COMMENT='异步任务执行记录表'
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8 COMMENT='异步任务执行记录表';
+
+CREATE TABLE `async_task_concurrency_lock` (
+                                               `lock_key` varchar(191) NOT NULL COMMENT '并发上限键 theme:service|tenant:name',
+                                               `update_time` datetime(3) DEFAULT NULL,
+                                               PRIMARY KEY (`lock_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='异步任务并发上限互斥表';
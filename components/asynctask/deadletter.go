@@ -0,0 +1,127 @@
+// Package asynctask 注释
+// @author wanlizhan
+// @created 2026/10/19
+package asynctask
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
+)
+
+// DeadTaskDetail 死信任务详情，附带完整的执行历史
+type DeadTaskDetail struct {
+	Task     TaskModel       `json:"task"`
+	Attempts []TaskExecModel `json:"attempts"` // 按执行先后排序
+}
+
+// ListDeadTasks 分页查询死信任务（供管理后台使用），args.TaskStatus 会被忽略
+func ListDeadTasks(args ListTasksArgs, systemName string, ctx context.Context) (ListTasksResult, error) {
+	args.TaskStatus = []string{TaskStatusDead.String()}
+	return ListTasks(args, systemName, ctx)
+}
+
+// GetDeadTask 获取死信任务及其全部执行记录（供管理后台使用）
+func GetDeadTask(taskId int64, ctx context.Context) (DeadTaskDetail, error) {
+	task, err := getTaskById(taskId, ctx)
+	if err != nil {
+		return DeadTaskDetail{}, err
+	}
+	if task.Id == 0 || task.TaskStatus != TaskStatusDead.String() {
+		return DeadTaskDetail{}, fmt.Errorf("dead task %d not found", taskId)
+	}
+
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return DeadTaskDetail{}, err
+	}
+	attempts := make([]TaskExecModel, 0)
+	if err := db.GetGormDb().WithContext(ctx).Table("asynctask_exec_row").
+		Where("task_id = ?", taskId).Order("id asc").Find(&attempts).Error; err != nil {
+		return DeadTaskDetail{}, err
+	}
+	return DeadTaskDetail{Task: task, Attempts: attempts}, nil
+}
+
+// RequeueDeadTasks 将死信任务重新置为 Wait 并清零重试次数，返回重新入队的任务数。
+// taskIds 为空时重新入队该 theme 下的全部死信任务；执行历史会保留。
+// 任务链/任务组中因这些任务失败而被终止的下游任务会恢复为 Blocked，随它们重新推进。
+func RequeueDeadTasks(theme string, taskIds []int64, ctx context.Context) (int64, error) {
+	if theme == "" {
+		return 0, fmt.Errorf("theme is required")
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return 0, err
+	}
+	return requeueDeadTasksInDB(db.GetGormDb().WithContext(ctx), theme, taskIds)
+}
+
+func requeueDeadTasksInDB(db *gorm.DB, theme string, taskIds []int64) (int64, error) {
+	query := db.Table(taskTable).
+		Where("system_name = ? AND task_status = ?", theme, TaskStatusDead.String())
+	if len(taskIds) > 0 {
+		query = query.Where("id IN ?", taskIds)
+	}
+	ids := make([]int64, 0)
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var requeued int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Table(taskTable).
+			Where("id IN ? AND task_status = ?", ids, TaskStatusDead.String()).
+			Updates(map[string]any{
+				"task_status": TaskStatusWait.String(),
+				"retry_time":  0,
+				"next_run_at": nil,
+				"aborted_by":  0,
+				"update_time": time.Now(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		requeued = res.RowsAffected
+		if _, err := reviveAbortedDownstream(tx, ids); err != nil {
+			return err
+		}
+		return clearCancelRequest(tx, ids)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return requeued, nil
+}
+
+// PurgeDeadTasks 删除死信任务及其执行记录，返回删除的任务数。
+// taskIds 为空时删除该 theme 下的全部死信任务。
+func PurgeDeadTasks(theme string, taskIds []int64, ctx context.Context) (int64, error) {
+	if theme == "" {
+		return 0, fmt.Errorf("theme is required")
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return 0, err
+	}
+
+	query := db.GetGormDb().WithContext(ctx).Table(taskTable).
+		Where("system_name = ? AND task_status = ?", theme, TaskStatusDead.String())
+	if len(taskIds) > 0 {
+		query = query.Where("id IN ?", taskIds)
+	}
+	ids := make([]int64, 0)
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return deleteTasksWithRecords(db.GetGormDb().WithContext(ctx), ids)
+}
@@ -0,0 +1,182 @@
+// Package asynctask 注释
+// @author wanlizhan
+// @created 2026/10/19
+package asynctask
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DedupKeep 重复提交直接返回已有任务（默认）；配置了去重窗口时，超出窗口的提交会新建任务
+	DedupKeep = "keep"
+	// DedupReplacePending 已有任务尚未开始执行时用新的提交覆盖它，否则新建任务
+	DedupReplacePending = "replace"
+	// DedupKeepLatest 防抖：与 replace 相同，但每次提交都会把执行时间推迟到 now+去重窗口，
+	// 一连串提交只会在最后一次提交的窗口结束后执行一次
+	DedupKeepLatest = "latest"
+)
+
+// dedupAction 命中去重键后的处理方式
+type dedupAction int
+
+const (
+	dedupReturnExisting dedupAction = iota
+	dedupUpdatePending
+	dedupInsertNew
+)
+
+// validDedupMode 校验去重模式名称
+func validDedupMode(mode string) bool {
+	switch mode {
+	case "", DedupKeep, DedupReplacePending, DedupKeepLatest:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t TaskBaseInfo) dedupWindow() time.Duration {
+	if t.DedupWindowSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(t.DedupWindowSeconds) * time.Second
+}
+
+// decideDedup 根据新提交的去重模式与已有任务的状态决定如何处理重复提交
+func decideDedup(existing TaskModel, task TaskBaseInfo, now time.Time) dedupAction {
+	switch task.DedupMode {
+	case DedupReplacePending, DedupKeepLatest:
+		if existing.TaskStatus == TaskStatusWait.String() {
+			return dedupUpdatePending
+		}
+		// 已开始执行或已结束，新的提交需要在它之后再执行一次
+		return dedupInsertNew
+	default:
+		if window := task.dedupWindow(); window > 0 && now.Sub(existing.CreateAt) >= window {
+			return dedupInsertNew
+		}
+		return dedupReturnExisting
+	}
+}
+
+// dedupRunAt 返回新提交的最早执行时间，latest 模式下为 now+去重窗口
+func dedupRunAt(task TaskBaseInfo, now time.Time) *time.Time {
+	if task.DedupMode == DedupKeepLatest && task.DedupWindowSeconds > 0 {
+		runAt := now.Add(task.dedupWindow())
+		return &runAt
+	}
+	return task.RunAt
+}
+
+// dedupReplaceColumns 覆盖尚未执行的已有任务时写入的列：除去重键外的全部提交参数
+func dedupReplaceColumns(task TaskBaseInfo, now time.Time) map[string]any {
+	return map[string]any{
+		"service_name":            task.ServiceName,
+		"method_name":             task.MethodName,
+		"task_name":               task.TaskName,
+		"arg":                     task.Arg,
+		"max_retry_time":          task.MaxRetryTime,
+		"priority":                task.Priority,
+		"tenant_id":               task.TenantId,
+		"run_at":                  dedupRunAt(task, now),
+		"retry_backoff":           task.RetryBackoff,
+		"retry_delay_seconds":     task.RetryDelaySeconds,
+		"retry_max_delay_seconds": task.RetryMaxDelaySeconds,
+		"timeout_seconds":         task.TimeoutSeconds,
+		"dedup_mode":              task.DedupMode,
+		"dedup_window_seconds":    task.DedupWindowSeconds,
+		"update_time":             now,
+	}
+}
+
+// addTaskWithDedup 按去重键提交任务，inserted 为 false 表示复用了已有任务。
+// 同一去重键的并发提交由行锁与唯一索引串行化，冲突失败时重新读取已有任务再处理一次。
+func addTaskWithDedup(db *gorm.DB, model TaskModel) (result TaskModel, inserted bool, err error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		result, inserted, err = addTaskWithDedupOnce(db, model)
+		if err == nil {
+			return result, inserted, nil
+		}
+		var taken int64
+		if db.Table(taskTable).Where("system_name = ? AND dedup_key = ?", model.SystemName, model.DedupKey).
+			Count(&taken).Error != nil || taken == 0 {
+			break
+		}
+	}
+	return TaskModel{}, false, err
+}
+
+func addTaskWithDedupOnce(db *gorm.DB, model TaskModel) (result TaskModel, inserted bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		existing := TaskModel{}
+		if err := tx.Table(taskTable).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("system_name = ? AND dedup_key = ?", model.SystemName, model.DedupKey).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+
+		if existing.Id != 0 {
+			switch decideDedup(existing, model.TaskBaseInfo, now) {
+			case dedupReturnExisting:
+				result = existing
+				return nil
+			case dedupUpdatePending:
+				if err := tx.Table(taskTable).Where("id = ?", existing.Id).
+					Updates(dedupReplaceColumns(model.TaskBaseInfo, now)).Error; err != nil {
+					return err
+				}
+				return tx.Table(taskTable).Where("id = ?", existing.Id).Find(&result).Error
+			case dedupInsertNew:
+				// 释放旧任务占用的去重键，旧任务本身保持不变
+				if err := tx.Table(taskTable).Where("id = ?", existing.Id).
+					UpdateColumn("dedup_key", nil).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		model.RunAt = dedupRunAt(model.TaskBaseInfo, now)
+		if err := tx.Table(taskTable).Create(&model).Error; err != nil {
+			return err
+		}
+		result, inserted = model, true
+		return nil
+	})
+	return result, inserted, err
+}
+
+// validateDedup 校验去重相关参数
+func validateDedup(task TaskBaseInfo) error {
+	if !validDedupMode(task.DedupMode) {
+		return fmt.Errorf("unknown dedup mode %q", task.DedupMode)
+	}
+	if task.DedupKey == "" && (task.DedupMode != "" || task.DedupWindowSeconds != 0) {
+		return fmt.Errorf("dedup mode and window require a dedup key")
+	}
+	if task.DedupWindowSeconds < 0 {
+		return fmt.Errorf("dedup window must not be negative")
+	}
+	return nil
+}
+
+// FindTaskByDedupKey 按去重键查找当前持有该键的任务，不存在时返回零值
+func FindTaskByDedupKey(theme, dedupKey string, ctx context.Context) (TaskModel, error) {
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return TaskModel{}, err
+	}
+	model := TaskModel{}
+	if err := db.GetGormDb().WithContext(ctx).Table(taskTable).Where("system_name = ? AND dedup_key = ?", theme, dedupKey).
+		Find(&model).Error; err != nil {
+		return TaskModel{}, err
+	}
+	return model, nil
+}
@@ -0,0 +1,119 @@
+package asynctask
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecideDedup(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-10 * time.Second)
+	old := now.Add(-time.Hour)
+
+	cases := []struct {
+		name     string
+		status   TaskStatus
+		createAt time.Time
+		task     TaskBaseInfo
+		want     dedupAction
+	}{
+		{"keep returns pending", TaskStatusWait, recent, TaskBaseInfo{}, dedupReturnExisting},
+		{"keep returns finished", TaskStatusSuccess, old, TaskBaseInfo{}, dedupReturnExisting},
+		{"keep within window", TaskStatusSuccess, recent, TaskBaseInfo{DedupWindowSeconds: 60}, dedupReturnExisting},
+		{"keep window expired", TaskStatusSuccess, old, TaskBaseInfo{DedupWindowSeconds: 60}, dedupInsertNew},
+		{"replace pending", TaskStatusWait, old, TaskBaseInfo{DedupMode: DedupReplacePending}, dedupUpdatePending},
+		{"replace running", TaskStatusRunning, recent, TaskBaseInfo{DedupMode: DedupReplacePending}, dedupInsertNew},
+		{"latest pending", TaskStatusWait, recent, TaskBaseInfo{DedupMode: DedupKeepLatest, DedupWindowSeconds: 30}, dedupUpdatePending},
+		{"latest retrying", TaskStatusRetry, recent, TaskBaseInfo{DedupMode: DedupKeepLatest}, dedupInsertNew},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			existing := TaskModel{TaskStatus: c.status.String(), CreateAt: c.createAt}
+			if got := decideDedup(existing, c.task, now); got != c.want {
+				t.Fatalf("decideDedup() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDedupRunAt(t *testing.T) {
+	now := time.Now()
+	got := dedupRunAt(TaskBaseInfo{DedupMode: DedupKeepLatest, DedupWindowSeconds: 30}, now)
+	if got == nil || !got.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("latest dedupRunAt() = %v, want now+30s", got)
+	}
+	runAt := now.Add(time.Minute)
+	if got := dedupRunAt(TaskBaseInfo{DedupMode: DedupReplacePending, RunAt: &runAt}, now); got != &runAt {
+		t.Fatalf("replace dedupRunAt() = %v, want submitted run_at", got)
+	}
+}
+
+func TestValidateDedup(t *testing.T) {
+	if err := validateDedup(TaskBaseInfo{DedupKey: "k", DedupMode: DedupKeepLatest, DedupWindowSeconds: 5}); err != nil {
+		t.Fatalf("validateDedup() unexpected error: %v", err)
+	}
+	for _, task := range []TaskBaseInfo{
+		{DedupKey: "k", DedupMode: "first"},
+		{DedupMode: DedupReplacePending},
+		{DedupKey: "k", DedupWindowSeconds: -1},
+	} {
+		if err := validateDedup(task); err == nil {
+			t.Fatalf("validateDedup(%+v) should fail", task)
+		}
+	}
+}
+
+// fillTaskBaseInfo 给 TaskBaseInfo 的每个字段填入由 seed 区分的非零值，新增字段会自动参与比较
+func fillTaskBaseInfo(t *testing.T, seed int) TaskBaseInfo {
+	t.Helper()
+	info := TaskBaseInfo{}
+	v := reflect.ValueOf(&info).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(fmt.Sprintf("%s-%d", v.Type().Field(i).Name, seed))
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			f.SetInt(int64(seed*100 + i))
+		case reflect.Ptr:
+			at := time.Date(2026, 10, 19, seed, 0, 0, 0, time.Local)
+			f.Set(reflect.ValueOf(&at))
+		default:
+			t.Fatalf("fillTaskBaseInfo: unsupported field %s", v.Type().Field(i).Name)
+		}
+	}
+	info.DedupKey = "report"
+	info.DedupMode = DedupReplacePending
+	return info
+}
+
+func TestAddTaskWithDedupReplacesAllFields(t *testing.T) {
+	db := newSqliteTestDB(t)
+	first, inserted, err := addTaskWithDedup(db, TaskModel{TaskBaseInfo: fillTaskBaseInfo(t, 1), SystemName: "dedup",
+		TaskStatus: TaskStatusWait.String(), CreateAt: time.Now()})
+	if err != nil || !inserted {
+		t.Fatalf("addTaskWithDedup() = %v, %v", inserted, err)
+	}
+
+	want := fillTaskBaseInfo(t, 2)
+	got, inserted, err := addTaskWithDedup(db, TaskModel{TaskBaseInfo: want, SystemName: "dedup",
+		TaskStatus: TaskStatusWait.String(), CreateAt: time.Now()})
+	if err != nil || inserted || got.Id != first.Id {
+		t.Fatalf("addTaskWithDedup() = %d, %v, %v, want update of %d", got.Id, inserted, err, first.Id)
+	}
+	gv, wv := reflect.ValueOf(got.TaskBaseInfo), reflect.ValueOf(want)
+	for i := 0; i < wv.NumField(); i++ {
+		name := wv.Type().Field(i).Name
+		if at, ok := wv.Field(i).Interface().(*time.Time); ok {
+			if gotAt := gv.Field(i).Interface().(*time.Time); gotAt == nil || !gotAt.Equal(*at) {
+				t.Errorf("%s = %v, want %v", name, gotAt, at)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(gv.Field(i).Interface(), wv.Field(i).Interface()) {
+			t.Errorf("%s = %v, want %v", name, gv.Field(i).Interface(), wv.Field(i).Interface())
+		}
+	}
+}
@@ -10,11 +10,15 @@ type TaskStatus uint8
 type WorkerStatus uint8
 
 const (
-	TaskStatusWait    TaskStatus = iota //等待
-	TaskStatusRunning                   //执行
-	TaskStatusSuccess                   //执行成功
-	TaskStatusFailed                    //执行失败
-	TaskStatusRetry                     //需要重试
+	TaskStatusWait     TaskStatus = iota //等待
+	TaskStatusRunning                    //执行
+	TaskStatusSuccess                    //执行成功
+	TaskStatusFailed                     //执行失败
+	TaskStatusRetry                      //需要重试
+	TaskStatusDead                       //重试耗尽，进入死信
+	TaskStatusBlocked                    //等待上游任务完成（任务链/任务组回调）
+	TaskStatusCanceled                   //被取消
+	TaskStatusTimedOut                   //执行超时
 )
 const (
 	WorkerStatusSleep WorkerStatus = iota
@@ -34,6 +38,14 @@ func (s TaskStatus) String() string {
 		return "Failed"
 	case TaskStatusRetry:
 		return "Retry"
+	case TaskStatusDead:
+		return "Dead"
+	case TaskStatusBlocked:
+		return "Blocked"
+	case TaskStatusCanceled:
+		return "Canceled"
+	case TaskStatusTimedOut:
+		return "TimedOut"
 	default:
 		return "Unknown"
 	}
@@ -78,4 +90,7 @@ type SchedulerStatusInfo struct {
 	WorkerScaleDown int64 // worker 缩容累计次数
 	AlarmFired      int64 // 实际发送告警累计次数
 	AlarmSuppressed int64 // 因去重抑制的告警累计次数
+	DeadLetterCount int64 // 任务进入死信的累计次数
+	CanceledCount   int64 // 执行中被取消的累计次数
+	TimedOutCount   int64 // 执行超时的累计次数
 }
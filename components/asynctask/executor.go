@@ -15,6 +15,13 @@ import (
 	"github.com/xxzhwl/gaia/framework/logImpl"
 )
 
+var (
+	// ErrTaskCanceled 任务执行中被取消，业务方法可通过 context.Cause(ctx) 区分
+	ErrTaskCanceled = errors.New("asynctask: task canceled")
+	// ErrTaskTimedOut 任务执行超过 TimeoutSeconds
+	ErrTaskTimedOut = errors.New("asynctask: task timed out")
+)
+
 const (
 	ExecutorTaskIdCtxKey      = "ExecutorTaskIdCtxKey"
 	ExecutorTaskStatusCtxKey  = "ExecutorTaskStatusCtxKey"
@@ -95,6 +102,20 @@ func (e *Executor) Run() bool {
 	}
 
 	e.TaskInfo = model
+	// 任务链/任务组回调：将上游结果注入 ctx，业务方法通过 UpstreamResults 读取
+	upstream, err := loadUpstreamResults(model, e.Ctx)
+	if err != nil {
+		msg := fmt.Sprintf("[%s-%s-%s]获取任务%d上游结果失败:%s", model.SystemName, model.ServiceName,
+			model.MethodName, e.TaskId, err.Error())
+		e.Logger.Error(msg)
+		e.recordDBErr("get_upstream")
+		updateTaskWait(e.TaskId, msg, now, e.Ctx)
+		return false
+	}
+	if upstream != nil {
+		e.Ctx = context.WithValue(e.Ctx, upstreamResultsCtxKey{}, upstream)
+	}
+
 	// 触发 OnTaskStart Hook
 	e.fireStartHook(now)
 
@@ -102,8 +123,9 @@ func (e *Executor) Run() bool {
 		msg := fmt.Sprintf("[%s-%s-%s]预处理错误:%s", e.TaskInfo.SystemName, e.TaskInfo.ServiceName,
 			e.TaskInfo.MethodName, err.Error())
 		e.Logger.Error(msg)
-		updateTaskFailed(e.TaskInfo.Id, msg, now, e.Ctx)
+		updateTaskFailed(e.TaskInfo, msg, now, e.Ctx)
 		e.recordPostExec(now, TaskStatusFailed.String(), msg, false)
+		e.advanceGraph(TaskStatusFailed.String())
 		return false
 	}
 
@@ -116,16 +138,22 @@ func (e *Executor) Run() bool {
 		if isPanic {
 			recordPanic(e.Ctx, e.theme(), "run")
 		}
-		// 失败时若仍有重试次数，则置为 Retry，等待下一轮调度；否则置为 Failed
-		if e.TaskInfo.MaxRetryTime >= e.TaskInfo.RetryTime+1 {
+		// 取消与超时不再重试；其他失败若仍有重试次数，则置为 Retry，按退避策略等待下次调度；否则进入死信
+		if status, ok := interruptedStatus(err); ok {
+			finalStatus = status.String()
+			updateTaskFinalFailure(e.TaskInfo, status, msg, now, e.Ctx)
+			e.recordInterrupted(status)
+			e.recordPostExec(now, finalStatus, msg, false)
+		} else if e.TaskInfo.MaxRetryTime >= e.TaskInfo.RetryTime+1 {
 			finalStatus = TaskStatusRetry.String()
-			updateTaskRetry(e.TaskInfo, msg, now, e.Ctx)
+			updateTaskRetry(e.TaskInfo, msg, now, e.nextRetryAt(), e.Ctx)
 			recordRetry(e.Ctx, e.theme())
 			e.recordPostExec(now, TaskStatusRetry.String(), msg, isPanic)
 		} else {
-			finalStatus = TaskStatusFailed.String()
-			updateTaskFailed(e.TaskInfo.Id, msg, now, e.Ctx)
-			e.recordPostExec(now, TaskStatusFailed.String(), msg, isPanic)
+			finalStatus = TaskStatusDead.String()
+			updateTaskDead(e.TaskInfo, msg, now, e.Ctx)
+			e.recordDeadLetter(msg)
+			e.recordPostExec(now, TaskStatusDead.String(), msg, isPanic)
 		}
 	} else {
 		marshal, err := json.Marshal(res)
@@ -135,17 +163,21 @@ func (e *Executor) Run() bool {
 			e.Logger.Error(msg)
 			finalErrMsg = msg
 			finalStatus = TaskStatusFailed.String()
-			updateTaskFailed(e.TaskInfo.Id, msg, now, e.Ctx)
+			updateTaskFailed(e.TaskInfo, msg, now, e.Ctx)
 			e.recordPostExec(now, TaskStatusFailed.String(), msg, false)
 		} else {
 			// 业务成功：直接置为 Success（不再因 MaxRetryTime > 0 而被错误地置为 Retry）
 			ok = true
 			finalStatus = TaskStatusSuccess.String()
-			updateTaskSuccess(e.TaskInfo.Id, string(marshal), now, e.Ctx)
+			updateTaskSuccess(e.TaskInfo, string(marshal), now, e.Ctx)
 			e.recordPostExec(now, TaskStatusSuccess.String(), "", false)
 		}
 	}
 
+	if finalStatus != TaskStatusRetry.String() {
+		e.advanceGraph(finalStatus)
+	}
+
 	if err := tc.SetKvData(ExecutorTaskStatusCtxKey, finalStatus); err != nil {
 		e.Logger.Error(err.Error())
 	}
@@ -179,11 +211,100 @@ func (e *Executor) recordDBErr(op string) {
 	}
 }
 
+// nextRetryAt 按任务（或调度器默认）的退避策略计算下次重试时间，零值表示立即重试。
+func (e *Executor) nextRetryAt() time.Time {
+	fallback := RetryBackoffPolicy{}
+	if sch := GetScheduler(e.theme()); sch != nil {
+		fallback = sch.RetryBackoff
+	}
+	delay := e.TaskInfo.retryBackoffPolicy(fallback).Delay(e.TaskInfo.RetryTime + 1)
+	if delay <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(delay)
+}
+
+// advanceGraph 任务进入终态后推进任务链/任务组中等待它的下游任务，并快速入队被释放的任务。
+func (e *Executor) advanceGraph(status string) {
+	if e.TaskInfo.RootId == 0 {
+		return
+	}
+	task := e.TaskInfo
+	task.TaskStatus = status
+	released, err := settleDownstream(task, e.Ctx)
+	if err != nil {
+		e.Logger.ErrorF("[%s-%s-%s]推进任务%d的下游任务失败:%s", e.theme(), e.TaskInfo.ServiceName,
+			e.TaskInfo.MethodName, e.TaskInfo.Id, err.Error())
+		e.recordDBErr("settle_downstream")
+	}
+	if sch := GetScheduler(e.theme()); sch != nil {
+		for _, model := range released {
+			quickQueueIfReady(sch, model)
+		}
+	}
+}
+
+// timeout 返回单次执行超时，0 表示不限制
+func (t TaskBaseInfo) timeout() time.Duration {
+	if t.TimeoutSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(t.TimeoutSeconds) * time.Second
+}
+
+// interruptedStatus 判断执行错误是否由取消或超时引起
+func interruptedStatus(err error) (TaskStatus, bool) {
+	switch {
+	case errors.Is(err, ErrTaskCanceled):
+		return TaskStatusCanceled, true
+	case errors.Is(err, ErrTaskTimedOut):
+		return TaskStatusTimedOut, true
+	default:
+		return 0, false
+	}
+}
+
+// recordInterrupted 记录取消/超时指标
+func (e *Executor) recordInterrupted(status TaskStatus) {
+	sch := GetScheduler(e.theme())
+	switch status {
+	case TaskStatusCanceled:
+		recordCanceled(e.Ctx, e.theme(), e.TaskInfo.ServiceName)
+		if sch != nil {
+			sch.counters.canceled.Add(1)
+		}
+	case TaskStatusTimedOut:
+		recordTimedOut(e.Ctx, e.theme(), e.TaskInfo.ServiceName)
+		if sch != nil {
+			sch.counters.timedOut.Add(1)
+		}
+	}
+}
+
+// recordDeadLetter 记录死信指标，并通过调度器的告警入口（启用 AlarmThrottle 时去重）发送告警。
+func (e *Executor) recordDeadLetter(errMsg string) {
+	recordDeadLetter(e.Ctx, e.theme(), e.TaskInfo.ServiceName)
+	sch := GetScheduler(e.theme())
+	if sch == nil {
+		return
+	}
+	sch.counters.deadLetter.Add(1)
+	// 按 theme 与服务方法去重，同类死信在限流窗口内聚合
+	title := fmt.Sprintf("TaskMgr:%s任务进入死信[%s-%s]", e.theme(), e.TaskInfo.ServiceName, e.TaskInfo.MethodName)
+	sch.fireAlarmWithKey(alarmKey(title, ""), title,
+		fmt.Sprintf("任务%d(%s)已执行%d次仍失败:%s", e.TaskInfo.Id, e.TaskInfo.TaskName, e.TaskInfo.RetryTime+1, errMsg))
+}
+
 // fireStartHook 在执行业务函数前触发 OnTaskStart 钩子，并记录 wait 时延。
 func (e *Executor) fireStartHook(start time.Time) {
 	waitMs := int64(0)
 	if !e.TaskInfo.CreateAt.IsZero() && e.TaskInfo.RetryTime == 0 {
-		waitMs = start.Sub(e.TaskInfo.CreateAt).Milliseconds()
+		// 延迟任务从到期时间开始计算等待时延
+		readyAt := e.TaskInfo.CreateAt
+		if e.TaskInfo.RunAt != nil && e.TaskInfo.RunAt.After(readyAt) {
+			readyAt = *e.TaskInfo.RunAt
+		}
+		waitMs = start.Sub(readyAt).Milliseconds()
 		if waitMs < 0 {
 			waitMs = 0
 		}
@@ -287,27 +408,50 @@ func (e *Executor) run() (res any, err error) {
 	if err = InsertOrUpdateHeartBeat(e.TaskInfo.Id); err != nil {
 		e.Logger.Error(err.Error())
 	}
+	// 执行前已被请求取消（例如抢占后、心跳失活重置后）则不再执行
+	if requested, err := isCancelRequested(e.TaskInfo.Id, e.Ctx); err != nil {
+		e.Logger.Error(err.Error())
+	} else if requested {
+		return nil, ErrTaskCanceled
+	}
+
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
+	// 业务方法的 ctx：超时或取消时以 ErrTaskTimedOut/ErrTaskCanceled 作为 cause
+	runCtx, cancelRun := context.WithCancelCause(e.Ctx)
+	defer cancelRun(nil)
+	if timeout := e.TaskInfo.timeout(); timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		runCtx, cancelTimeout = context.WithTimeoutCause(runCtx, timeout, ErrTaskTimedOut)
+		defer cancelTimeout()
+	}
 	defer func() {
 		if r := recover(); r != nil {
 			err = errors.New("RunPanic:" + gaia.PanicLog(r))
+			return
+		}
+		// 协作式中断：业务方法响应 ctx 返回错误时，按 cause 记为取消或超时
+		if err != nil && runCtx.Err() != nil {
+			if cause := context.Cause(runCtx); errors.Is(cause, ErrTaskCanceled) || errors.Is(cause, ErrTaskTimedOut) {
+				err = fmt.Errorf("%w:%s", cause, err.Error())
+			}
 		}
 	}()
 	//这个任务要开始心跳连接
 	go func() {
 		defer gaia.CatchPanic()
-		e.heartBeat(ctx)
+		e.heartBeat(ctx, cancelRun)
 	}()
 	proxy := gaia.GetProxy(e.TaskInfo.SystemName, e.TaskInfo.ServiceName)
 	if proxy == nil {
 		return nil, fmt.Errorf("[%s-%s]获取Proxy为Nil", e.TaskInfo.SystemName, e.TaskInfo.ServiceName)
 	}
 
-	return gaia.CallMethodWithJSONArgsContext(e.Ctx, proxy, e.TaskInfo.MethodName, []byte(e.TaskInfo.Arg))
+	return gaia.CallMethodWithJSONArgsContext(runCtx, proxy, e.TaskInfo.MethodName, []byte(e.TaskInfo.Arg))
 }
 
-func (e *Executor) heartBeat(ctx context.Context) {
+// heartBeat 定期写入心跳，并检查管理端的取消请求，收到请求后以 ErrTaskCanceled 取消业务方法的 ctx
+func (e *Executor) heartBeat(ctx context.Context, cancelRun context.CancelCauseFunc) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -316,6 +460,15 @@ func (e *Executor) heartBeat(ctx context.Context) {
 			if err := InsertOrUpdateHeartBeat(e.TaskInfo.Id); err != nil {
 				e.Logger.Error(err.Error())
 			}
+			requested, err := isCancelRequested(e.TaskInfo.Id, ctx)
+			if err != nil {
+				e.Logger.Error(err.Error())
+				continue
+			}
+			if requested {
+				e.Logger.InfoF("TaskId:%d收到取消请求，中断执行", e.TaskInfo.Id)
+				cancelRun(ErrTaskCanceled)
+			}
 		}
 	}
 }
@@ -0,0 +1,73 @@
+package asynctask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+	"github.com/xxzhwl/gaia/framework/logImpl"
+)
+
+type slowService struct{}
+
+func (slowService) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (slowService) Quick(ctx context.Context) (string, error) {
+	return "done", nil
+}
+
+func TestExecutorRunTimeout(t *testing.T) {
+	gaia.RegisterProxy("executorTest", "SlowService", slowService{})
+	newExecutor := func(method string, timeoutSeconds int) *Executor {
+		return &Executor{
+			TaskInfo: TaskModel{SystemName: "executorTest", TaskBaseInfo: TaskBaseInfo{
+				ServiceName: "SlowService", MethodName: method, TimeoutSeconds: timeoutSeconds}},
+			Ctx:    context.Background(),
+			Logger: logImpl.NewDefaultLogger(),
+		}
+	}
+
+	start := time.Now()
+	_, err := newExecutor("Wait", 1).run()
+	if status, ok := interruptedStatus(err); !ok || status != TaskStatusTimedOut {
+		t.Fatalf("run() error = %v, want timed out", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("run() took %v, deadline not enforced", elapsed)
+	}
+
+	res, err := newExecutor("Quick", 1).run()
+	if err != nil || res != "done" {
+		t.Fatalf("run() = (%v, %v), want done", res, err)
+	}
+}
+
+func TestInterruptedStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		want   TaskStatus
+		wantOk bool
+	}{
+		{fmt.Errorf("%w:context canceled", ErrTaskCanceled), TaskStatusCanceled, true},
+		{fmt.Errorf("%w:context deadline exceeded", ErrTaskTimedOut), TaskStatusTimedOut, true},
+		{context.DeadlineExceeded, 0, false},
+		{errors.New("RunPanic:boom"), 0, false},
+	}
+	for _, c := range cases {
+		got, ok := interruptedStatus(c.err)
+		if got != c.want || ok != c.wantOk {
+			t.Fatalf("interruptedStatus(%v) = (%s, %v), want (%s, %v)", c.err, got, ok, c.want, c.wantOk)
+		}
+	}
+	for _, status := range []TaskStatus{TaskStatusCanceled, TaskStatusTimedOut} {
+		if !isFinalStatus(status.String()) {
+			t.Fatalf("%s should be a final status", status)
+		}
+	}
+}
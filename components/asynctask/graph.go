@@ -0,0 +1,424 @@
+// Package asynctask 注释
+// @author wanlizhan
+// @created 2026/10/19
+package asynctask
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
+)
+
+const (
+	// UpstreamAbort 上游任务未成功时终止下游（默认）：等待中的下游任务置为 Failed，并继续向后传播
+	UpstreamAbort = "abort"
+	// UpstreamContinue 上游任务未成功时下游照常执行，可通过 UpstreamResults 查看上游状态
+	UpstreamContinue = "continue"
+)
+
+// upstreamAbortedMsgFmt 下游任务因上游未成功被终止时记录的 last_err_msg，仅用于展示，恢复时以 aborted_by 为准
+const upstreamAbortedMsgFmt = "上游任务%d未成功(%s)，已终止"
+
+// UpstreamResult 上游任务的执行结果
+type UpstreamResult struct {
+	TaskId int64           `json:"task_id"`
+	Status string          `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"` // 上游任务的 LastResult，未成功时为空
+	ErrMsg string          `json:"err_msg,omitempty"`
+}
+
+type upstreamResultsCtxKey struct{}
+
+// UpstreamResults 在业务方法中获取上游任务的结果：任务链中为上一个任务的结果，
+// 任务组回调中为组内全部任务的结果（按任务 id 排序）；独立任务返回 nil。
+func UpstreamResults(ctx context.Context) []UpstreamResult {
+	results, _ := ctx.Value(upstreamResultsCtxKey{}).([]UpstreamResult)
+	return results
+}
+
+// TaskGroup 任务组
+type TaskGroup struct {
+	GroupId  int64
+	Members  []TaskModel
+	Callback *TaskModel // 组内任务全部结束后执行的回调任务，未设置时为 nil
+}
+
+// TaskGraphEdge 任务依赖边，From 执行结束后 To 才会执行
+type TaskGraphEdge struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// TaskGraph 任务链/任务组的依赖图
+type TaskGraph struct {
+	RootId int64           `json:"root_id"`
+	Nodes  []TaskModel     `json:"nodes"`
+	Edges  []TaskGraphEdge `json:"edges"`
+}
+
+// validUpstreamPolicy 校验上游失败策略名称
+func validUpstreamPolicy(policy string) bool {
+	switch policy {
+	case "", UpstreamAbort, UpstreamContinue:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateGraphTasks 校验任务链/任务组中的任务
+func validateGraphTasks(tasks []TaskBaseInfo, policy string) error {
+	if !validUpstreamPolicy(policy) {
+		return fmt.Errorf("unknown upstream policy %q", policy)
+	}
+	for _, task := range tasks {
+		if !validRetryBackoff(task.RetryBackoff) {
+			return fmt.Errorf("unknown retry backoff %q", task.RetryBackoff)
+		}
+		if task.DedupKey != "" {
+			return fmt.Errorf("dedup key is not supported in chains and groups")
+		}
+	}
+	return nil
+}
+
+// AddChain 新增一条任务链：tasks 按顺序执行，后一个任务在前一个任务结束后才会被释放执行
+func AddChain(tasks []TaskBaseInfo, systemName, policy string, ctx context.Context) ([]TaskModel, error) {
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("chain requires at least one task")
+	}
+	if err := validateGraphTasks(tasks, policy); err != nil {
+		return nil, err
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]TaskModel, 0, len(tasks))
+	err = db.GetGormDb().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		models = models[:0]
+		now := time.Now()
+		for i, task := range tasks {
+			model := TaskModel{TaskBaseInfo: task, SystemName: systemName, TaskStatus: TaskStatusWait.String(),
+				CreateAt: now, UpdateAt: now}
+			if i > 0 {
+				model.TaskStatus = TaskStatusBlocked.String()
+				model.RootId = models[0].Id
+				model.ParentId = models[i-1].Id
+				model.UpstreamPolicy = policy
+			}
+			if err := tx.Table(taskTable).Create(&model).Error; err != nil {
+				return err
+			}
+			if i == 0 {
+				model.RootId = model.Id
+				if err := tx.Table(taskTable).Where("id = ?", model.Id).UpdateColumn("root_id", model.Id).Error; err != nil {
+					return err
+				}
+			}
+			models = append(models, model)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, model := range models {
+		emitEnqueueLog(model)
+	}
+	return models, nil
+}
+
+// AddGroup 新增一个任务组：组内任务并行执行，全部结束后释放回调任务（chord）。
+// 回调任务可通过 UpstreamResults 读取组内全部任务的结果，callback 为空表示不需要回调。
+func AddGroup(tasks []TaskBaseInfo, callback *TaskBaseInfo, systemName, policy string, ctx context.Context) (TaskGroup, error) {
+	if len(tasks) == 0 {
+		return TaskGroup{}, fmt.Errorf("group requires at least one task")
+	}
+	all := tasks
+	if callback != nil {
+		all = append(append(make([]TaskBaseInfo, 0, len(tasks)+1), tasks...), *callback)
+	}
+	if err := validateGraphTasks(all, policy); err != nil {
+		return TaskGroup{}, err
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return TaskGroup{}, err
+	}
+
+	group := TaskGroup{}
+	err = db.GetGormDb().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group = TaskGroup{Members: make([]TaskModel, 0, len(tasks))}
+		now := time.Now()
+		for i, task := range tasks {
+			model := TaskModel{TaskBaseInfo: task, SystemName: systemName, TaskStatus: TaskStatusWait.String(),
+				CreateAt: now, UpdateAt: now, RootId: group.GroupId, GroupId: group.GroupId}
+			if err := tx.Table(taskTable).Create(&model).Error; err != nil {
+				return err
+			}
+			if i == 0 {
+				group.GroupId, model.RootId, model.GroupId = model.Id, model.Id, model.Id
+				if err := tx.Table(taskTable).Where("id = ?", model.Id).
+					UpdateColumns(map[string]any{"root_id": model.Id, "group_id": model.Id}).Error; err != nil {
+					return err
+				}
+			}
+			group.Members = append(group.Members, model)
+		}
+		if callback != nil {
+			model := TaskModel{TaskBaseInfo: *callback, SystemName: systemName, TaskStatus: TaskStatusBlocked.String(),
+				CreateAt: now, UpdateAt: now, RootId: group.GroupId, CallbackGroupId: group.GroupId, UpstreamPolicy: policy}
+			if err := tx.Table(taskTable).Create(&model).Error; err != nil {
+				return err
+			}
+			group.Callback = &model
+		}
+		return nil
+	})
+	if err != nil {
+		return TaskGroup{}, err
+	}
+	for _, model := range group.Members {
+		emitEnqueueLog(model)
+	}
+	if group.Callback != nil {
+		emitEnqueueLog(*group.Callback)
+	}
+	return group, nil
+}
+
+// releasedStatus 返回上游结束后等待中的下游任务应进入的状态
+func releasedStatus(upstreamSucceeded bool, policy string) TaskStatus {
+	if upstreamSucceeded || policy == UpstreamContinue {
+		return TaskStatusWait
+	}
+	return TaskStatusFailed
+}
+
+// groupOutcome 根据组内各状态的任务数判断任务组是否全部结束、是否全部成功
+func groupOutcome(rows []taskStatusCountRow) (done, succeeded bool) {
+	succeeded = true
+	for _, row := range rows {
+		if row.Total == 0 {
+			continue
+		}
+		if !isFinalStatus(row.TaskStatus) {
+			return false, false
+		}
+		if row.TaskStatus != TaskStatusSuccess.String() {
+			succeeded = false
+		}
+	}
+	return true, succeeded
+}
+
+// settleDownstream 任务进入终态后推进等待它的下游任务：上游成功或策略为 continue 时释放为 Wait，
+// 否则置为 Failed 并继续向后传播。返回被释放的任务，由调用方快速入队。
+// 组内多个任务同时结束时，每个任务都在自身状态落库后再统计，最后落库的任务必然能看到全组结束；
+// 下游状态的变更带 Blocked 条件，重复推进不会产生副作用。
+func settleDownstream(task TaskModel, ctx context.Context) ([]TaskModel, error) {
+	if task.RootId == 0 {
+		return nil, nil
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return nil, err
+	}
+	gdb := db.GetGormDb().WithContext(ctx)
+
+	released := make([]TaskModel, 0)
+	queue := []TaskModel{task}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		children := make([]TaskModel, 0)
+		if err := gdb.Table(taskTable).Where("parent_id = ? AND task_status = ?", cur.Id, TaskStatusBlocked.String()).
+			Find(&children).Error; err != nil {
+			return released, err
+		}
+		succeeded := make([]bool, len(children))
+		for i := range children {
+			succeeded[i] = cur.TaskStatus == TaskStatusSuccess.String()
+		}
+
+		if cur.GroupId != 0 {
+			rows := make([]taskStatusCountRow, 0)
+			if err := gdb.Table(taskTable).Select("task_status, count(*) as total").
+				Where("group_id = ?", cur.GroupId).Group("task_status").Find(&rows).Error; err != nil {
+				return released, err
+			}
+			if done, ok := groupOutcome(rows); done {
+				callbacks := make([]TaskModel, 0)
+				if err := gdb.Table(taskTable).Where("callback_group_id = ? AND task_status = ?", cur.GroupId,
+					TaskStatusBlocked.String()).Find(&callbacks).Error; err != nil {
+					return released, err
+				}
+				for range callbacks {
+					succeeded = append(succeeded, ok)
+				}
+				children = append(children, callbacks...)
+			}
+		}
+
+		for i, next := range children {
+			status := releasedStatus(succeeded[i], next.UpstreamPolicy)
+			updates := map[string]any{"task_status": status.String(), "update_time": time.Now()}
+			if status == TaskStatusFailed {
+				updates["last_err_msg"] = truncateErrMsg(fmt.Sprintf(upstreamAbortedMsgFmt, cur.Id, cur.TaskStatus))
+				updates["aborted_by"] = cur.Id
+			}
+			tx := gdb.Table(taskTable).Where("id = ? AND task_status = ?", next.Id, TaskStatusBlocked.String()).
+				Updates(updates)
+			if tx.Error != nil {
+				return released, tx.Error
+			}
+			if tx.RowsAffected == 0 {
+				continue
+			}
+			next.TaskStatus = status.String()
+			if status == TaskStatusWait {
+				released = append(released, next)
+			} else {
+				queue = append(queue, next)
+			}
+		}
+	}
+	return released, nil
+}
+
+// reviveAbortedDownstream 上游任务被手动重试或从死信重新入队后，把因它终止的下游任务恢复为 Blocked，
+// 等上游再次结束时由 settleDownstream 重新推进。只恢复 settleDownstream 按 abort 策略置为 Failed 的任务
+// （aborted_by 记录了终止它的上游），并沿任务链与任务组回调继续向后恢复。返回恢复的任务数。
+// 须与上游状态的变更放在同一事务中，避免上游在下游恢复前就已结束。
+func reviveAbortedDownstream(db *gorm.DB, taskIds []int64) (int64, error) {
+	if len(taskIds) == 0 {
+		return 0, nil
+	}
+	queue := make([]TaskModel, 0)
+	if err := db.Table(taskTable).Where("id IN ? AND root_id <> 0", taskIds).Find(&queue).Error; err != nil {
+		return 0, err
+	}
+
+	var revived int64
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		// 任务组的回调由组内最后结束的任务终止，不一定是 cur，因此按组 id 查找
+		aborted := db.Table(taskTable).Where("task_status = ?", TaskStatusFailed.String())
+		if cur.GroupId != 0 {
+			aborted = aborted.Where("aborted_by = ? OR (callback_group_id = ? AND aborted_by <> 0)", cur.Id, cur.GroupId)
+		} else {
+			aborted = aborted.Where("aborted_by = ?", cur.Id)
+		}
+		children := make([]TaskModel, 0)
+		if err := aborted.Find(&children).Error; err != nil {
+			return revived, err
+		}
+
+		for _, next := range children {
+			tx := db.Table(taskTable).Where("id = ? AND task_status = ?", next.Id, TaskStatusFailed.String()).
+				Updates(map[string]any{
+					"task_status":  TaskStatusBlocked.String(),
+					"last_err_msg": "",
+					"aborted_by":   0,
+					"update_time":  time.Now(),
+				})
+			if tx.Error != nil {
+				return revived, tx.Error
+			}
+			if tx.RowsAffected == 0 {
+				continue
+			}
+			revived++
+			queue = append(queue, next)
+		}
+	}
+	return revived, nil
+}
+
+// loadUpstreamResults 读取任务的上游结果：任务链取父任务，回调任务取组内全部任务
+func loadUpstreamResults(task TaskModel, ctx context.Context) ([]UpstreamResult, error) {
+	if task.ParentId == 0 && task.CallbackGroupId == 0 {
+		return nil, nil
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return nil, err
+	}
+	query := db.GetGormDb().WithContext(ctx).Table(taskTable).Select("id", "task_status", "last_result", "last_err_msg")
+	if task.ParentId != 0 {
+		query = query.Where("id = ?", task.ParentId)
+	} else {
+		query = query.Where("group_id = ?", task.CallbackGroupId)
+	}
+	upstream := make([]TaskModel, 0)
+	if err := query.Order("id asc").Find(&upstream).Error; err != nil {
+		return nil, err
+	}
+	return toUpstreamResults(upstream), nil
+}
+
+func toUpstreamResults(upstream []TaskModel) []UpstreamResult {
+	results := make([]UpstreamResult, 0, len(upstream))
+	for _, t := range upstream {
+		result := UpstreamResult{TaskId: t.Id, Status: t.TaskStatus, ErrMsg: t.LastErrMsg}
+		if t.TaskStatus == TaskStatusSuccess.String() && t.LastResult != "" {
+			result.Result = json.RawMessage(t.LastResult)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// GetTaskGraph 获取任务所在任务链/任务组的依赖图（供管理后台使用），独立任务只包含自身
+func GetTaskGraph(taskId int64, ctx context.Context) (TaskGraph, error) {
+	task, err := getTaskById(taskId, ctx)
+	if err != nil {
+		return TaskGraph{}, err
+	}
+	if task.Id == 0 {
+		return TaskGraph{}, fmt.Errorf("task %d not found", taskId)
+	}
+	if task.RootId == 0 {
+		return TaskGraph{RootId: task.Id, Nodes: []TaskModel{task}, Edges: []TaskGraphEdge{}}, nil
+	}
+
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return TaskGraph{}, err
+	}
+	nodes := make([]TaskModel, 0)
+	if err := db.GetGormDb().WithContext(ctx).Table(taskTable).Where("root_id = ?", task.RootId).
+		Order("id asc").Find(&nodes).Error; err != nil {
+		return TaskGraph{}, err
+	}
+	return TaskGraph{RootId: task.RootId, Nodes: nodes, Edges: buildGraphEdges(nodes)}, nil
+}
+
+// buildGraphEdges 根据父子关系与任务组回调关系生成依赖边
+func buildGraphEdges(nodes []TaskModel) []TaskGraphEdge {
+	members := make(map[int64][]int64)
+	for _, n := range nodes {
+		if n.GroupId != 0 {
+			members[n.GroupId] = append(members[n.GroupId], n.Id)
+		}
+	}
+	edges := make([]TaskGraphEdge, 0)
+	for _, n := range nodes {
+		if n.ParentId != 0 {
+			edges = append(edges, TaskGraphEdge{From: n.ParentId, To: n.Id})
+		}
+		for _, m := range members[n.CallbackGroupId] {
+			edges = append(edges, TaskGraphEdge{From: m, To: n.Id})
+		}
+	}
+	return edges
+}
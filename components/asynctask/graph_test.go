@@ -0,0 +1,206 @@
+package asynctask
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newSqliteTestDB 创建带 asynctask 表结构的 sqlite 库，供需要落库的测试使用
+func newSqliteTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "asynctask.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&TaskModel{}, &HeartBeatModel{}, &TaskExecModel{}, &ConcurrencyLockModel{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func taskStatusOf(t *testing.T, db *gorm.DB, id int64) string {
+	t.Helper()
+	task := TaskModel{}
+	if err := db.Table(taskTable).Where("id = ?", id).Find(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	return task.TaskStatus
+}
+
+func TestReleasedStatus(t *testing.T) {
+	cases := []struct {
+		succeeded bool
+		policy    string
+		want      TaskStatus
+	}{
+		{true, "", TaskStatusWait},
+		{true, UpstreamAbort, TaskStatusWait},
+		{false, "", TaskStatusFailed},
+		{false, UpstreamAbort, TaskStatusFailed},
+		{false, UpstreamContinue, TaskStatusWait},
+	}
+	for _, c := range cases {
+		if got := releasedStatus(c.succeeded, c.policy); got != c.want {
+			t.Fatalf("releasedStatus(%v, %q) = %s, want %s", c.succeeded, c.policy, got, c.want)
+		}
+	}
+}
+
+func TestGroupOutcome(t *testing.T) {
+	row := func(status TaskStatus, total int64) taskStatusCountRow {
+		return taskStatusCountRow{TaskStatus: status.String(), Total: total}
+	}
+	cases := []struct {
+		name          string
+		rows          []taskStatusCountRow
+		done, succeed bool
+	}{
+		{"all success", []taskStatusCountRow{row(TaskStatusSuccess, 3)}, true, true},
+		{"still running", []taskStatusCountRow{row(TaskStatusSuccess, 2), row(TaskStatusRunning, 1)}, false, false},
+		{"retrying", []taskStatusCountRow{row(TaskStatusRetry, 1)}, false, false},
+		{"finished with dead", []taskStatusCountRow{row(TaskStatusSuccess, 2), row(TaskStatusDead, 1)}, true, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			done, ok := groupOutcome(c.rows)
+			if done != c.done || ok != c.succeed {
+				t.Fatalf("groupOutcome() = (%v, %v), want (%v, %v)", done, ok, c.done, c.succeed)
+			}
+		})
+	}
+}
+
+func TestBuildGraphEdges(t *testing.T) {
+	// 1 -> 2 的链，以及 3、4 组成的任务组（组 id 为 3）回调 5
+	nodes := []TaskModel{
+		{Id: 1, RootId: 1},
+		{Id: 2, RootId: 1, ParentId: 1},
+		{Id: 3, RootId: 3, GroupId: 3},
+		{Id: 4, RootId: 3, GroupId: 3},
+		{Id: 5, RootId: 3, CallbackGroupId: 3},
+	}
+	want := []TaskGraphEdge{{From: 1, To: 2}, {From: 3, To: 5}, {From: 4, To: 5}}
+	if got := buildGraphEdges(nodes); !reflect.DeepEqual(got, want) {
+		t.Fatalf("buildGraphEdges() = %v, want %v", got, want)
+	}
+}
+
+func TestUpstreamResults(t *testing.T) {
+	if got := UpstreamResults(context.Background()); got != nil {
+		t.Fatalf("UpstreamResults() = %v, want nil for standalone task", got)
+	}
+	results := toUpstreamResults([]TaskModel{
+		{Id: 1, TaskStatus: TaskStatusSuccess.String(), LastResult: `{"rows":10}`},
+		{Id: 2, TaskStatus: TaskStatusDead.String(), LastResult: `null`, LastErrMsg: "boom"},
+	})
+	ctx := context.WithValue(context.Background(), upstreamResultsCtxKey{}, results)
+	got := UpstreamResults(ctx)
+	if len(got) != 2 || string(got[0].Result) != `{"rows":10}` || got[1].Result != nil || got[1].ErrMsg != "boom" {
+		t.Fatalf("UpstreamResults() = %+v", got)
+	}
+}
+
+func TestValidateGraphTasks(t *testing.T) {
+	if err := validateGraphTasks([]TaskBaseInfo{{}}, "skip"); err == nil {
+		t.Fatal("unknown upstream policy should be rejected")
+	}
+	if err := validateGraphTasks([]TaskBaseInfo{{DedupKey: "k"}}, UpstreamAbort); err == nil {
+		t.Fatal("dedup key in a chain should be rejected")
+	}
+	if err := validateGraphTasks([]TaskBaseInfo{{RetryBackoff: RetryBackoffFixed}}, UpstreamContinue); err != nil {
+		t.Fatalf("validateGraphTasks() unexpected error: %v", err)
+	}
+}
+
+func TestRequeueRevivesAbortedDownstream(t *testing.T) {
+	db := newSqliteTestDB(t)
+	aborted := func(upstream int64) string {
+		return fmt.Sprintf(upstreamAbortedMsgFmt, upstream, TaskStatusDead.String())
+	}
+	// 链 1 -> 2 -> 3 因 1 进入死信被逐级终止；4 是 continue 策略下自己执行失败的下游，
+	// 错误信息恰好与终止信息相同也不应恢复。组 10、11 的回调 12 在 11 最后结束时因 10 进入死信被终止。
+	tasks := []TaskModel{
+		{Id: 1, SystemName: "graph", TaskStatus: TaskStatusDead.String(), RootId: 1},
+		{Id: 2, SystemName: "graph", TaskStatus: TaskStatusFailed.String(), RootId: 1, ParentId: 1, LastErrMsg: aborted(1), AbortedBy: 1},
+		{Id: 3, SystemName: "graph", TaskStatus: TaskStatusFailed.String(), RootId: 1, ParentId: 2, LastErrMsg: aborted(2), AbortedBy: 2},
+		{Id: 4, SystemName: "graph", TaskStatus: TaskStatusFailed.String(), RootId: 1, ParentId: 1, LastErrMsg: aborted(1)},
+		{Id: 10, SystemName: "graph", TaskStatus: TaskStatusDead.String(), RootId: 10, GroupId: 10},
+		{Id: 11, SystemName: "graph", TaskStatus: TaskStatusSuccess.String(), RootId: 10, GroupId: 10},
+		{Id: 12, SystemName: "graph", TaskStatus: TaskStatusFailed.String(), RootId: 10, CallbackGroupId: 10, LastErrMsg: aborted(10), AbortedBy: 11},
+	}
+	if err := db.Table(taskTable).Create(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := requeueDeadTasksInDB(db, "graph", []int64{1})
+	if err != nil || n != 1 {
+		t.Fatalf("requeueDeadTasksInDB() = %d, %v", n, err)
+	}
+	want := map[int64]TaskStatus{1: TaskStatusWait, 2: TaskStatusBlocked, 3: TaskStatusBlocked, 4: TaskStatusFailed,
+		12: TaskStatusFailed}
+	for id, status := range want {
+		if got := taskStatusOf(t, db, id); got != status.String() {
+			t.Fatalf("task %d status = %s, want %s", id, got, status)
+		}
+	}
+
+	// 未结束的任务不能手动重试：Blocked 的下游会越过上游执行，Running 的任务会被重复执行
+	for _, id := range []int64{2, 404} {
+		if err := retryTaskInDB(db, id); err == nil {
+			t.Fatalf("retryTaskInDB(%d) should fail", id)
+		}
+	}
+	if got := taskStatusOf(t, db, 2); got != TaskStatusBlocked.String() {
+		t.Fatalf("blocked task status = %s, want Blocked", got)
+	}
+
+	// 手动重试组内失败的任务，回调随之恢复等待全组结束
+	if err := retryTaskInDB(db, 10); err != nil {
+		t.Fatal(err)
+	}
+	if got := taskStatusOf(t, db, 10); got != TaskStatusWait.String() {
+		t.Fatalf("retried task status = %s, want Wait", got)
+	}
+	if got := taskStatusOf(t, db, 12); got != TaskStatusBlocked.String() {
+		t.Fatalf("callback status = %s, want Blocked", got)
+	}
+}
+
+func TestCancelPendingTaskInDB(t *testing.T) {
+	db := newSqliteTestDB(t)
+	tasks := []TaskModel{
+		{Id: 1, SystemName: "graph", TaskStatus: TaskStatusWait.String()},
+		{Id: 2, SystemName: "graph", TaskStatus: TaskStatusRunning.String()},
+		{Id: 3, SystemName: "graph", TaskStatus: TaskStatusSuccess.String()},
+	}
+	if err := db.Table(taskTable).Create(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if running, err := cancelPendingTaskInDB(db, 1); err != nil || running {
+		t.Fatalf("cancelPendingTaskInDB(1) = %v, %v", running, err)
+	}
+	if got := taskStatusOf(t, db, 1); got != TaskStatusCanceled.String() {
+		t.Fatalf("canceled task status = %s", got)
+	}
+	// 读取状态后被抢占执行：交给执行器取消
+	if running, err := cancelPendingTaskInDB(db, 2); err != nil || !running {
+		t.Fatalf("cancelPendingTaskInDB(2) = %v, %v, want running", running, err)
+	}
+	// 已结束、已删除或刚被取消的任务不应再发出取消请求
+	for _, id := range []int64{1, 3, 404} {
+		if running, err := cancelPendingTaskInDB(db, id); err == nil || running {
+			t.Fatalf("cancelPendingTaskInDB(%d) = %v, %v, want error", id, running, err)
+		}
+	}
+	if got := taskStatusOf(t, db, 3); got != TaskStatusSuccess.String() {
+		t.Fatalf("finished task status = %s", got)
+	}
+}
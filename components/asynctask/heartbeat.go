@@ -4,9 +4,11 @@
 package asynctask
 
 import (
+	"context"
 	"time"
 
 	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -17,6 +19,8 @@ type HeartBeatModel struct {
 	TaskId            int64     `gorm:"column:task_id;not null;uniqueIndex:idx_async_task_heartbeat_task_id"`
 	HeartBeatTime     time.Time `gorm:"column:heart_beat_time"`
 	HeartBeatNanoTime int64     `gorm:"column:heart_beat_nano_time;default:0"`
+	// CancelRequested 管理端请求取消执行中的任务，持有任务的执行器在心跳时读取并中断执行
+	CancelRequested bool `gorm:"column:cancel_requested;not null;default:false"`
 }
 
 func (HeartBeatModel) TableName() string { return heartBeatTable }
@@ -123,3 +127,47 @@ func InsertOrUpdateHeartBeat(taskId int64) error {
 	}
 	return nil
 }
+
+// RequestTaskCancel 标记取消执行中的任务。持有该任务的执行器（可能在其他副本上）在下次心跳时
+// 读到标记后取消业务方法的 ctx，任务最终记为 Canceled；尚未开始执行的任务在执行前即被取消。
+func RequestTaskCancel(taskId int64, ctx context.Context) error {
+	if taskId <= 0 {
+		return nil
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return err
+	}
+	// 心跳行不存在时以当前时间插入，避免被心跳检测误判为失活
+	now := time.Now()
+	return db.GetGormDb().WithContext(ctx).Table(heartBeatTable).Clauses(
+		clause.OnConflict{Columns: []clause.Column{{Name: "task_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"cancel_requested"})}).
+		Create(&HeartBeatModel{TaskId: taskId, HeartBeatTime: now, HeartBeatNanoTime: now.UnixNano(), CancelRequested: true}).Error
+}
+
+// isCancelRequested 查询任务是否被请求取消
+func isCancelRequested(taskId int64, ctx context.Context) (bool, error) {
+	if taskId <= 0 {
+		return false, nil
+	}
+	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
+	if err != nil {
+		return false, err
+	}
+	var requested []bool
+	if err := db.GetGormDb().WithContext(ctx).Table(heartBeatTable).Where("task_id = ?", taskId).
+		Pluck("cancel_requested", &requested).Error; err != nil {
+		return false, err
+	}
+	return len(requested) > 0 && requested[0], nil
+}
+
+// clearCancelRequest 清除取消标记，任务被手动重试或重新入队时调用
+func clearCancelRequest(db *gorm.DB, taskIds []int64) error {
+	if len(taskIds) == 0 {
+		return nil
+	}
+	return db.Table(heartBeatTable).Where("task_id IN ? AND cancel_requested = ?", taskIds, true).
+		UpdateColumn("cancel_requested", false).Error
+}
@@ -16,7 +16,7 @@ type TaskEvent struct {
 	TaskName    string
 	ServiceName string
 	MethodName  string
-	Status      string // 事件结束时的状态：Success/Failed/Retry/Panic
+	Status      string // 事件结束时的状态：Success/Failed/Retry/Dead/Canceled/TimedOut
 	StartTime   time.Time
 	EndTime     time.Time
 	WaitMillis  int64  // 从 create_time 到首次执行的等待毫秒数
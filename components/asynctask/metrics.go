@@ -43,6 +43,9 @@ type AsyncTaskMetrics struct {
 	TaskWaitDuration metric.Float64Histogram // 任务从创建到首次执行的等待耗时（毫秒）
 	TaskRetryTotal   metric.Int64Counter     // 任务进入重试状态的次数
 	TaskPanicTotal   metric.Int64Counter     // 任务执行 panic 次数
+	TaskDeadTotal    metric.Int64Counter     // 任务重试耗尽进入死信的次数
+	TaskCancelTotal  metric.Int64Counter     // 执行中的任务被取消的次数
+	TaskTimeoutTotal metric.Int64Counter     // 任务执行超时的次数
 
 	// 调度器
 	ScanTotal       metric.Int64Counter // 扫描数据库次数
@@ -130,6 +133,27 @@ func GetMetrics() *AsyncTaskMetrics {
 			otel.Handle(err)
 		}
 
+		m.TaskDeadTotal, err = meter.Int64Counter("asynctask.dead.total",
+			metric.WithDescription("Async tasks moved to the dead-letter state"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		m.TaskCancelTotal, err = meter.Int64Counter("asynctask.canceled.total",
+			metric.WithDescription("Running async tasks interrupted by cancellation"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		m.TaskTimeoutTotal, err = meter.Int64Counter("asynctask.timeout.total",
+			metric.WithDescription("Async tasks that exceeded their execution timeout"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
 		m.ScanTotal, err = meter.Int64Counter("asynctask.scan.total",
 			metric.WithDescription("Scheduler DB scan attempts"),
 		)
@@ -296,6 +320,33 @@ func recordRetry(ctx context.Context, theme string) {
 	GetMetrics().localCounters.retry.Add(1)
 }
 
+// recordDeadLetter 记录任务进入死信。
+func recordDeadLetter(ctx context.Context, theme, serviceName string) {
+	GetMetrics().TaskDeadTotal.Add(ctx, 1, metric.WithAttributes(
+		MetricLabel.Theme.String(theme),
+		MetricLabel.ServiceName.String(serviceName),
+	))
+	GetMetrics().localCounters.deadLetter.Add(1)
+}
+
+// recordCanceled 记录执行中的任务被取消。
+func recordCanceled(ctx context.Context, theme, serviceName string) {
+	GetMetrics().TaskCancelTotal.Add(ctx, 1, metric.WithAttributes(
+		MetricLabel.Theme.String(theme),
+		MetricLabel.ServiceName.String(serviceName),
+	))
+	GetMetrics().localCounters.canceled.Add(1)
+}
+
+// recordTimedOut 记录任务执行超时。
+func recordTimedOut(ctx context.Context, theme, serviceName string) {
+	GetMetrics().TaskTimeoutTotal.Add(ctx, 1, metric.WithAttributes(
+		MetricLabel.Theme.String(theme),
+		MetricLabel.ServiceName.String(serviceName),
+	))
+	GetMetrics().localCounters.timedOut.Add(1)
+}
+
 // recordPanic 记录 panic。
 func recordPanic(ctx context.Context, theme, phase string) {
 	GetMetrics().TaskPanicTotal.Add(ctx, 1, metric.WithAttributes(
@@ -385,6 +436,9 @@ func (m *AsyncTaskMetrics) Snapshot() AsyncTaskMetricsValues {
 		WorkerScaleDown: m.localCounters.workerDown.Load(),
 		AlarmFired:      m.localCounters.alarmFired.Load(),
 		AlarmSuppressed: m.localCounters.alarmSuppress.Load(),
+		DeadLetterCount: m.localCounters.deadLetter.Load(),
+		CanceledCount:   m.localCounters.canceled.Load(),
+		TimedOutCount:   m.localCounters.timedOut.Load(),
 	}
 }
 
@@ -399,6 +453,9 @@ type AsyncTaskMetricsValues struct {
 	WorkerScaleDown int64
 	AlarmFired      int64
 	AlarmSuppressed int64
+	DeadLetterCount int64
+	CanceledCount   int64
+	TimedOutCount   int64
 }
 
 // MetricsSnapshot 是面向 admin / HTTP API 的指标快照（本进程内累计值），
@@ -421,6 +478,9 @@ type MetricsSnapshot struct {
 	WorkerScaleDown  int64 `json:"worker_scale_down_count"`
 	AlarmFiredCount  int64 `json:"alarm_fired_count"`
 	AlarmSuppressed  int64 `json:"alarm_suppressed_count"`
+	DeadLetterCount  int64 `json:"dead_letter_count"`
+	CanceledCount    int64 `json:"canceled_count"`
+	TimedOutCount    int64 `json:"timed_out_count"`
 	LastSnapshotTime int64 `json:"last_snapshot_time_ms"` // unix ms
 }
 
@@ -435,4 +495,7 @@ type localCounters struct {
 	workerDown    atomic.Int64
 	alarmFired    atomic.Int64
 	alarmSuppress atomic.Int64
+	deadLetter    atomic.Int64
+	canceled      atomic.Int64
+	timedOut      atomic.Int64
 }
@@ -79,6 +79,15 @@ type TaskItem struct {
 	LastRunEndTime  *timestamppb.Timestamp `protobuf:"bytes,17,opt,name=last_run_end_time,json=lastRunEndTime,proto3" json:"last_run_end_time,omitempty"`
 	LastRunDuration int64                  `protobuf:"varint,18,opt,name=last_run_duration,json=lastRunDuration,proto3" json:"last_run_duration,omitempty"`
 	LastResult      string                 `protobuf:"bytes,19,opt,name=last_result,json=lastResult,proto3" json:"last_result,omitempty"`
+	RunAt           *timestamppb.Timestamp `protobuf:"bytes,20,opt,name=run_at,json=runAt,proto3" json:"run_at,omitempty"`
+	NextRunAt       *timestamppb.Timestamp `protobuf:"bytes,21,opt,name=next_run_at,json=nextRunAt,proto3" json:"next_run_at,omitempty"`
+	DedupKey        string                 `protobuf:"bytes,22,opt,name=dedup_key,json=dedupKey,proto3" json:"dedup_key,omitempty"`
+	RootId          int64                  `protobuf:"varint,23,opt,name=root_id,json=rootId,proto3" json:"root_id,omitempty"`
+	ParentId        int64                  `protobuf:"varint,24,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	GroupId         int64                  `protobuf:"varint,25,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	CallbackGroupId int64                  `protobuf:"varint,26,opt,name=callback_group_id,json=callbackGroupId,proto3" json:"callback_group_id,omitempty"`
+	UpstreamPolicy  string                 `protobuf:"bytes,27,opt,name=upstream_policy,json=upstreamPolicy,proto3" json:"upstream_policy,omitempty"`
+	TimeoutSeconds  int32                  `protobuf:"varint,28,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -246,18 +255,89 @@ func (x *TaskItem) GetLastResult() string {
 	return ""
 }
 
+func (x *TaskItem) GetRunAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RunAt
+	}
+	return nil
+}
+
+func (x *TaskItem) GetNextRunAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextRunAt
+	}
+	return nil
+}
+
+func (x *TaskItem) GetDedupKey() string {
+	if x != nil {
+		return x.DedupKey
+	}
+	return ""
+}
+
+func (x *TaskItem) GetRootId() int64 {
+	if x != nil {
+		return x.RootId
+	}
+	return 0
+}
+
+func (x *TaskItem) GetParentId() int64 {
+	if x != nil {
+		return x.ParentId
+	}
+	return 0
+}
+
+func (x *TaskItem) GetGroupId() int64 {
+	if x != nil {
+		return x.GroupId
+	}
+	return 0
+}
+
+func (x *TaskItem) GetCallbackGroupId() int64 {
+	if x != nil {
+		return x.CallbackGroupId
+	}
+	return 0
+}
+
+func (x *TaskItem) GetUpstreamPolicy() string {
+	if x != nil {
+		return x.UpstreamPolicy
+	}
+	return ""
+}
+
+func (x *TaskItem) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
 type SubmitTaskReq struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Theme         string                 `protobuf:"bytes,1,opt,name=theme,proto3" json:"theme,omitempty"`
-	TaskName      string                 `protobuf:"bytes,2,opt,name=task_name,json=taskName,proto3" json:"task_name,omitempty"`
-	ServiceName   string                 `protobuf:"bytes,3,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
-	MethodName    string                 `protobuf:"bytes,4,opt,name=method_name,json=methodName,proto3" json:"method_name,omitempty"`
-	Arg           string                 `protobuf:"bytes,5,opt,name=arg,proto3" json:"arg,omitempty"`
-	Priority      int32                  `protobuf:"varint,6,opt,name=priority,proto3" json:"priority,omitempty"`
-	TenantId      string                 `protobuf:"bytes,7,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
-	MaxRetry      int32                  `protobuf:"varint,8,opt,name=max_retry,json=maxRetry,proto3" json:"max_retry,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Theme                string                 `protobuf:"bytes,1,opt,name=theme,proto3" json:"theme,omitempty"`
+	TaskName             string                 `protobuf:"bytes,2,opt,name=task_name,json=taskName,proto3" json:"task_name,omitempty"`
+	ServiceName          string                 `protobuf:"bytes,3,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	MethodName           string                 `protobuf:"bytes,4,opt,name=method_name,json=methodName,proto3" json:"method_name,omitempty"`
+	Arg                  string                 `protobuf:"bytes,5,opt,name=arg,proto3" json:"arg,omitempty"`
+	Priority             int32                  `protobuf:"varint,6,opt,name=priority,proto3" json:"priority,omitempty"`
+	TenantId             string                 `protobuf:"bytes,7,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	MaxRetry             int32                  `protobuf:"varint,8,opt,name=max_retry,json=maxRetry,proto3" json:"max_retry,omitempty"`
+	RunAt                *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=run_at,json=runAt,proto3" json:"run_at,omitempty"`
+	RetryBackoff         string                 `protobuf:"bytes,10,opt,name=retry_backoff,json=retryBackoff,proto3" json:"retry_backoff,omitempty"`
+	RetryDelaySeconds    int32                  `protobuf:"varint,11,opt,name=retry_delay_seconds,json=retryDelaySeconds,proto3" json:"retry_delay_seconds,omitempty"`
+	RetryMaxDelaySeconds int32                  `protobuf:"varint,12,opt,name=retry_max_delay_seconds,json=retryMaxDelaySeconds,proto3" json:"retry_max_delay_seconds,omitempty"`
+	DedupKey             string                 `protobuf:"bytes,13,opt,name=dedup_key,json=dedupKey,proto3" json:"dedup_key,omitempty"`
+	DedupMode            string                 `protobuf:"bytes,14,opt,name=dedup_mode,json=dedupMode,proto3" json:"dedup_mode,omitempty"`
+	DedupWindowSeconds   int32                  `protobuf:"varint,15,opt,name=dedup_window_seconds,json=dedupWindowSeconds,proto3" json:"dedup_window_seconds,omitempty"`
+	TimeoutSeconds       int32                  `protobuf:"varint,16,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
 }
 
 func (x *SubmitTaskReq) Reset() {
@@ -346,6 +426,62 @@ func (x *SubmitTaskReq) GetMaxRetry() int32 {
 	return 0
 }
 
+func (x *SubmitTaskReq) GetRunAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RunAt
+	}
+	return nil
+}
+
+func (x *SubmitTaskReq) GetRetryBackoff() string {
+	if x != nil {
+		return x.RetryBackoff
+	}
+	return ""
+}
+
+func (x *SubmitTaskReq) GetRetryDelaySeconds() int32 {
+	if x != nil {
+		return x.RetryDelaySeconds
+	}
+	return 0
+}
+
+func (x *SubmitTaskReq) GetRetryMaxDelaySeconds() int32 {
+	if x != nil {
+		return x.RetryMaxDelaySeconds
+	}
+	return 0
+}
+
+func (x *SubmitTaskReq) GetDedupKey() string {
+	if x != nil {
+		return x.DedupKey
+	}
+	return ""
+}
+
+func (x *SubmitTaskReq) GetDedupMode() string {
+	if x != nil {
+		return x.DedupMode
+	}
+	return ""
+}
+
+func (x *SubmitTaskReq) GetDedupWindowSeconds() int32 {
+	if x != nil {
+		return x.DedupWindowSeconds
+	}
+	return 0
+}
+
+func (x *SubmitTaskReq) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
 type SubmitTaskResp struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -678,6 +814,7 @@ type TaskRecordItem struct {
 	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
 	LogId         string                 `protobuf:"bytes,9,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
 	Result        string                 `protobuf:"bytes,10,opt,name=result,proto3" json:"result,omitempty"`
+	Attempt       int32                  `protobuf:"varint,11,opt,name=attempt,proto3" json:"attempt,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -782,6 +919,13 @@ func (x *TaskRecordItem) GetResult() string {
 	return ""
 }
 
+func (x *TaskRecordItem) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
 type ListRecordsReq struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TaskId        int64                  `protobuf:"varint,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
@@ -1406,12 +1550,272 @@ func (x *AllMetricsResp) GetMetrics() []*MetricItem {
 	return nil
 }
 
+type DeadTaskDetail struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Task          *TaskItem              `protobuf:"bytes,1,opt,name=task,proto3" json:"task,omitempty"`
+	Attempts      []*TaskRecordItem      `protobuf:"bytes,2,rep,name=attempts,proto3" json:"attempts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeadTaskDetail) Reset() {
+	*x = DeadTaskDetail{}
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeadTaskDetail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadTaskDetail) ProtoMessage() {}
+
+func (x *DeadTaskDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadTaskDetail.ProtoReflect.Descriptor instead.
+func (*DeadTaskDetail) Descriptor() ([]byte, []int) {
+	return file_components_asynctask_pb_async_task_admin_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *DeadTaskDetail) GetTask() *TaskItem {
+	if x != nil {
+		return x.Task
+	}
+	return nil
+}
+
+func (x *DeadTaskDetail) GetAttempts() []*TaskRecordItem {
+	if x != nil {
+		return x.Attempts
+	}
+	return nil
+}
+
+type DeadTasksReq struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Theme         string                 `protobuf:"bytes,1,opt,name=theme,proto3" json:"theme,omitempty"`
+	Ids           []int64                `protobuf:"varint,2,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeadTasksReq) Reset() {
+	*x = DeadTasksReq{}
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeadTasksReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadTasksReq) ProtoMessage() {}
+
+func (x *DeadTasksReq) ProtoReflect() protoreflect.Message {
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadTasksReq.ProtoReflect.Descriptor instead.
+func (*DeadTasksReq) Descriptor() ([]byte, []int) {
+	return file_components_asynctask_pb_async_task_admin_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *DeadTasksReq) GetTheme() string {
+	if x != nil {
+		return x.Theme
+	}
+	return ""
+}
+
+func (x *DeadTasksReq) GetIds() []int64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+type DeadTasksResp struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Affected      int64                  `protobuf:"varint,1,opt,name=affected,proto3" json:"affected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeadTasksResp) Reset() {
+	*x = DeadTasksResp{}
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeadTasksResp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadTasksResp) ProtoMessage() {}
+
+func (x *DeadTasksResp) ProtoReflect() protoreflect.Message {
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadTasksResp.ProtoReflect.Descriptor instead.
+func (*DeadTasksResp) Descriptor() ([]byte, []int) {
+	return file_components_asynctask_pb_async_task_admin_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *DeadTasksResp) GetAffected() int64 {
+	if x != nil {
+		return x.Affected
+	}
+	return 0
+}
+
+type TaskGraphEdge struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          int64                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            int64                  `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskGraphEdge) Reset() {
+	*x = TaskGraphEdge{}
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskGraphEdge) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskGraphEdge) ProtoMessage() {}
+
+func (x *TaskGraphEdge) ProtoReflect() protoreflect.Message {
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskGraphEdge.ProtoReflect.Descriptor instead.
+func (*TaskGraphEdge) Descriptor() ([]byte, []int) {
+	return file_components_asynctask_pb_async_task_admin_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *TaskGraphEdge) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *TaskGraphEdge) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type TaskGraph struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RootId        int64                  `protobuf:"varint,1,opt,name=root_id,json=rootId,proto3" json:"root_id,omitempty"`
+	Nodes         []*TaskItem            `protobuf:"bytes,2,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	Edges         []*TaskGraphEdge       `protobuf:"bytes,3,rep,name=edges,proto3" json:"edges,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskGraph) Reset() {
+	*x = TaskGraph{}
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskGraph) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskGraph) ProtoMessage() {}
+
+func (x *TaskGraph) ProtoReflect() protoreflect.Message {
+	mi := &file_components_asynctask_pb_async_task_admin_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskGraph.ProtoReflect.Descriptor instead.
+func (*TaskGraph) Descriptor() ([]byte, []int) {
+	return file_components_asynctask_pb_async_task_admin_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *TaskGraph) GetRootId() int64 {
+	if x != nil {
+		return x.RootId
+	}
+	return 0
+}
+
+func (x *TaskGraph) GetNodes() []*TaskItem {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+func (x *TaskGraph) GetEdges() []*TaskGraphEdge {
+	if x != nil {
+		return x.Edges
+	}
+	return nil
+}
+
 var File_components_asynctask_pb_async_task_admin_proto protoreflect.FileDescriptor
 
 const file_components_asynctask_pb_async_task_admin_proto_rawDesc = "" +
 	"\n" +
 	".components/asynctask/pb/async_task_admin.proto\x12\fasynctask.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\a\n" +
-	"\x05Empty\"\xb8\x05\n" +
+	"\x05Empty\"\x93\b\n" +
 	"\bTaskItem\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1b\n" +
 	"\ttask_name\x18\x02 \x01(\tR\btaskName\x12\x14\n" +
@@ -1437,7 +1841,16 @@ const file_components_asynctask_pb_async_task_admin_proto_rawDesc = "" +
 	"\x11last_run_end_time\x18\x11 \x01(\v2\x1a.google.protobuf.TimestampR\x0elastRunEndTime\x12*\n" +
 	"\x11last_run_duration\x18\x12 \x01(\x03R\x0flastRunDuration\x12\x1f\n" +
 	"\vlast_result\x18\x13 \x01(\tR\n" +
-	"lastResult\"\xee\x01\n" +
+	"lastResult\x121\n" +
+	"\x06run_at\x18\x14 \x01(\v2\x1a.google.protobuf.TimestampR\x05runAt\x12:\n" +
+	"\vnext_run_at\x18\x15 \x01(\v2\x1a.google.protobuf.TimestampR\tnextRunAt\x12\x1b\n" +
+	"\tdedup_key\x18\x16 \x01(\tR\bdedupKey\x12\x17\n" +
+	"\aroot_id\x18\x17 \x01(\x03R\x06rootId\x12\x1b\n" +
+	"\tparent_id\x18\x18 \x01(\x03R\bparentId\x12\x19\n" +
+	"\bgroup_id\x18\x19 \x01(\x03R\agroupId\x12*\n" +
+	"\x11callback_group_id\x18\x1a \x01(\x03R\x0fcallbackGroupId\x12'\n" +
+	"\x0fupstream_policy\x18\x1b \x01(\tR\x0eupstreamPolicy\x12'\n" +
+	"\x0ftimeout_seconds\x18\x1c \x01(\x05R\x0etimeoutSeconds\"\xc4\x04\n" +
 	"\rSubmitTaskReq\x12\x14\n" +
 	"\x05theme\x18\x01 \x01(\tR\x05theme\x12\x1b\n" +
 	"\ttask_name\x18\x02 \x01(\tR\btaskName\x12!\n" +
@@ -1447,7 +1860,17 @@ const file_components_asynctask_pb_async_task_admin_proto_rawDesc = "" +
 	"\x03arg\x18\x05 \x01(\tR\x03arg\x12\x1a\n" +
 	"\bpriority\x18\x06 \x01(\x05R\bpriority\x12\x1b\n" +
 	"\ttenant_id\x18\a \x01(\tR\btenantId\x12\x1b\n" +
-	"\tmax_retry\x18\b \x01(\x05R\bmaxRetry\" \n" +
+	"\tmax_retry\x18\b \x01(\x05R\bmaxRetry\x121\n" +
+	"\x06run_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\x05runAt\x12#\n" +
+	"\rretry_backoff\x18\n" +
+	" \x01(\tR\fretryBackoff\x12.\n" +
+	"\x13retry_delay_seconds\x18\v \x01(\x05R\x11retryDelaySeconds\x125\n" +
+	"\x17retry_max_delay_seconds\x18\f \x01(\x05R\x14retryMaxDelaySeconds\x12\x1b\n" +
+	"\tdedup_key\x18\r \x01(\tR\bdedupKey\x12\x1d\n" +
+	"\n" +
+	"dedup_mode\x18\x0e \x01(\tR\tdedupMode\x120\n" +
+	"\x14dedup_window_seconds\x18\x0f \x01(\x05R\x12dedupWindowSeconds\x12'\n" +
+	"\x0ftimeout_seconds\x18\x10 \x01(\x05R\x0etimeoutSeconds\" \n" +
 	"\x0eSubmitTaskResp\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\"\x1c\n" +
 	"\n" +
@@ -1467,7 +1890,7 @@ const file_components_asynctask_pb_async_task_admin_proto_rawDesc = "" +
 	"\fRetryTaskReq\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\"\x1f\n" +
 	"\rCancelTaskReq\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\x03R\x02id\"\xbb\x02\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\xd5\x02\n" +
 	"\x0eTaskRecordItem\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x17\n" +
 	"\atask_id\x18\x02 \x01(\x03R\x06taskId\x12\x1b\n" +
@@ -1481,7 +1904,8 @@ const file_components_asynctask_pb_async_task_admin_proto_rawDesc = "" +
 	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x15\n" +
 	"\x06log_id\x18\t \x01(\tR\x05logId\x12\x16\n" +
 	"\x06result\x18\n" +
-	" \x01(\tR\x06result\"r\n" +
+	" \x01(\tR\x06result\x12\x18\n" +
+	"\aattempt\x18\v \x01(\x05R\aattempt\"r\n" +
 	"\x0eListRecordsReq\x12\x17\n" +
 	"\atask_id\x18\x01 \x01(\x03R\x06taskId\x12\x16\n" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12\x12\n" +
@@ -1536,7 +1960,22 @@ const file_components_asynctask_pb_async_task_admin_proto_rawDesc = "" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\x03R\x05value\"D\n" +
 	"\x0eAllMetricsResp\x122\n" +
-	"\ametrics\x18\x01 \x03(\v2\x18.asynctask.v1.MetricItemR\ametrics2\xcb\x06\n" +
+	"\ametrics\x18\x01 \x03(\v2\x18.asynctask.v1.MetricItemR\ametrics\"v\n" +
+	"\x0eDeadTaskDetail\x12*\n" +
+	"\x04task\x18\x01 \x01(\v2\x16.asynctask.v1.TaskItemR\x04task\x128\n" +
+	"\battempts\x18\x02 \x03(\v2\x1c.asynctask.v1.TaskRecordItemR\battempts\"6\n" +
+	"\fDeadTasksReq\x12\x14\n" +
+	"\x05theme\x18\x01 \x01(\tR\x05theme\x12\x10\n" +
+	"\x03ids\x18\x02 \x03(\x03R\x03ids\"+\n" +
+	"\rDeadTasksResp\x12\x1a\n" +
+	"\baffected\x18\x01 \x01(\x03R\baffected\"3\n" +
+	"\rTaskGraphEdge\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x03R\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\x03R\x02to\"\x85\x01\n" +
+	"\tTaskGraph\x12\x17\n" +
+	"\aroot_id\x18\x01 \x01(\x03R\x06rootId\x12,\n" +
+	"\x05nodes\x18\x02 \x03(\v2\x16.asynctask.v1.TaskItemR\x05nodes\x121\n" +
+	"\x05edges\x18\x03 \x03(\v2\x1b.asynctask.v1.TaskGraphEdgeR\x05edges2\xb7\t\n" +
 	"\x0eAsyncTaskAdmin\x12G\n" +
 	"\n" +
 	"SubmitTask\x12\x1b.asynctask.v1.SubmitTaskReq\x1a\x1c.asynctask.v1.SubmitTaskResp\x12;\n" +
@@ -1551,7 +1990,12 @@ const file_components_asynctask_pb_async_task_admin_proto_rawDesc = "" +
 	"\fGetScheduler\x12\x1d.asynctask.v1.GetSchedulerReq\x1a\x1b.asynctask.v1.SchedulerInfo\x12F\n" +
 	"\x0eStartScheduler\x12\x1f.asynctask.v1.StartSchedulerReq\x1a\x13.asynctask.v1.Empty\x12D\n" +
 	"\rStopScheduler\x12\x1e.asynctask.v1.StopSchedulerReq\x1a\x13.asynctask.v1.Empty\x12B\n" +
-	"\rGetAllMetrics\x12\x13.asynctask.v1.Empty\x1a\x1c.asynctask.v1.AllMetricsRespB<Z:github.com/xxzhwl/gaia/components/asynctask/pb;asynctaskpbb\x06proto3"
+	"\rGetAllMetrics\x12\x13.asynctask.v1.Empty\x1a\x1c.asynctask.v1.AllMetricsResp\x12H\n" +
+	"\rListDeadTasks\x12\x1a.asynctask.v1.ListTasksReq\x1a\x1b.asynctask.v1.ListTasksResp\x12E\n" +
+	"\vGetDeadTask\x12\x18.asynctask.v1.GetTaskReq\x1a\x1c.asynctask.v1.DeadTaskDetail\x12K\n" +
+	"\x10RequeueDeadTasks\x12\x1a.asynctask.v1.DeadTasksReq\x1a\x1b.asynctask.v1.DeadTasksResp\x12I\n" +
+	"\x0ePurgeDeadTasks\x12\x1a.asynctask.v1.DeadTasksReq\x1a\x1b.asynctask.v1.DeadTasksResp\x12A\n" +
+	"\fGetTaskGraph\x12\x18.asynctask.v1.GetTaskReq\x1a\x17.asynctask.v1.TaskGraphB<Z:github.com/xxzhwl/gaia/components/asynctask/pb;asynctaskpbb\x06proto3"
 
 var (
 	file_components_asynctask_pb_async_task_admin_proto_rawDescOnce sync.Once
@@ -1565,7 +2009,7 @@ func file_components_asynctask_pb_async_task_admin_proto_rawDescGZIP() []byte {
 	return file_components_asynctask_pb_async_task_admin_proto_rawDescData
 }
 
-var file_components_asynctask_pb_async_task_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_components_asynctask_pb_async_task_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
 var file_components_asynctask_pb_async_task_admin_proto_goTypes = []any{
 	(*Empty)(nil),                 // 0: asynctask.v1.Empty
 	(*TaskItem)(nil),              // 1: asynctask.v1.TaskItem
@@ -1587,47 +2031,69 @@ var file_components_asynctask_pb_async_task_admin_proto_goTypes = []any{
 	(*TaskStatsResp)(nil),         // 17: asynctask.v1.TaskStatsResp
 	(*MetricItem)(nil),            // 18: asynctask.v1.MetricItem
 	(*AllMetricsResp)(nil),        // 19: asynctask.v1.AllMetricsResp
-	(*timestamppb.Timestamp)(nil), // 20: google.protobuf.Timestamp
+	(*DeadTaskDetail)(nil),        // 20: asynctask.v1.DeadTaskDetail
+	(*DeadTasksReq)(nil),          // 21: asynctask.v1.DeadTasksReq
+	(*DeadTasksResp)(nil),         // 22: asynctask.v1.DeadTasksResp
+	(*TaskGraphEdge)(nil),         // 23: asynctask.v1.TaskGraphEdge
+	(*TaskGraph)(nil),             // 24: asynctask.v1.TaskGraph
+	(*timestamppb.Timestamp)(nil), // 25: google.protobuf.Timestamp
 }
 var file_components_asynctask_pb_async_task_admin_proto_depIdxs = []int32{
-	20, // 0: asynctask.v1.TaskItem.created_at:type_name -> google.protobuf.Timestamp
-	20, // 1: asynctask.v1.TaskItem.updated_at:type_name -> google.protobuf.Timestamp
-	20, // 2: asynctask.v1.TaskItem.last_run_time:type_name -> google.protobuf.Timestamp
-	20, // 3: asynctask.v1.TaskItem.last_run_end_time:type_name -> google.protobuf.Timestamp
-	1,  // 4: asynctask.v1.ListTasksResp.tasks:type_name -> asynctask.v1.TaskItem
-	20, // 5: asynctask.v1.TaskRecordItem.created_at:type_name -> google.protobuf.Timestamp
-	9,  // 6: asynctask.v1.ListRecordsResp.records:type_name -> asynctask.v1.TaskRecordItem
-	12, // 7: asynctask.v1.ListSchedulersResp.schedulers:type_name -> asynctask.v1.SchedulerInfo
-	18, // 8: asynctask.v1.AllMetricsResp.metrics:type_name -> asynctask.v1.MetricItem
-	2,  // 9: asynctask.v1.AsyncTaskAdmin.SubmitTask:input_type -> asynctask.v1.SubmitTaskReq
-	4,  // 10: asynctask.v1.AsyncTaskAdmin.GetTask:input_type -> asynctask.v1.GetTaskReq
-	5,  // 11: asynctask.v1.AsyncTaskAdmin.ListTasks:input_type -> asynctask.v1.ListTasksReq
-	7,  // 12: asynctask.v1.AsyncTaskAdmin.RetryTask:input_type -> asynctask.v1.RetryTaskReq
-	8,  // 13: asynctask.v1.AsyncTaskAdmin.CancelTask:input_type -> asynctask.v1.CancelTaskReq
-	10, // 14: asynctask.v1.AsyncTaskAdmin.ListRecords:input_type -> asynctask.v1.ListRecordsReq
-	0,  // 15: asynctask.v1.AsyncTaskAdmin.GetStats:input_type -> asynctask.v1.Empty
-	0,  // 16: asynctask.v1.AsyncTaskAdmin.ListSchedulers:input_type -> asynctask.v1.Empty
-	14, // 17: asynctask.v1.AsyncTaskAdmin.GetScheduler:input_type -> asynctask.v1.GetSchedulerReq
-	15, // 18: asynctask.v1.AsyncTaskAdmin.StartScheduler:input_type -> asynctask.v1.StartSchedulerReq
-	16, // 19: asynctask.v1.AsyncTaskAdmin.StopScheduler:input_type -> asynctask.v1.StopSchedulerReq
-	0,  // 20: asynctask.v1.AsyncTaskAdmin.GetAllMetrics:input_type -> asynctask.v1.Empty
-	3,  // 21: asynctask.v1.AsyncTaskAdmin.SubmitTask:output_type -> asynctask.v1.SubmitTaskResp
-	1,  // 22: asynctask.v1.AsyncTaskAdmin.GetTask:output_type -> asynctask.v1.TaskItem
-	6,  // 23: asynctask.v1.AsyncTaskAdmin.ListTasks:output_type -> asynctask.v1.ListTasksResp
-	0,  // 24: asynctask.v1.AsyncTaskAdmin.RetryTask:output_type -> asynctask.v1.Empty
-	0,  // 25: asynctask.v1.AsyncTaskAdmin.CancelTask:output_type -> asynctask.v1.Empty
-	11, // 26: asynctask.v1.AsyncTaskAdmin.ListRecords:output_type -> asynctask.v1.ListRecordsResp
-	17, // 27: asynctask.v1.AsyncTaskAdmin.GetStats:output_type -> asynctask.v1.TaskStatsResp
-	13, // 28: asynctask.v1.AsyncTaskAdmin.ListSchedulers:output_type -> asynctask.v1.ListSchedulersResp
-	12, // 29: asynctask.v1.AsyncTaskAdmin.GetScheduler:output_type -> asynctask.v1.SchedulerInfo
-	0,  // 30: asynctask.v1.AsyncTaskAdmin.StartScheduler:output_type -> asynctask.v1.Empty
-	0,  // 31: asynctask.v1.AsyncTaskAdmin.StopScheduler:output_type -> asynctask.v1.Empty
-	19, // 32: asynctask.v1.AsyncTaskAdmin.GetAllMetrics:output_type -> asynctask.v1.AllMetricsResp
-	21, // [21:33] is the sub-list for method output_type
-	9,  // [9:21] is the sub-list for method input_type
-	9,  // [9:9] is the sub-list for extension type_name
-	9,  // [9:9] is the sub-list for extension extendee
-	0,  // [0:9] is the sub-list for field type_name
+	25, // 0: asynctask.v1.TaskItem.created_at:type_name -> google.protobuf.Timestamp
+	25, // 1: asynctask.v1.TaskItem.updated_at:type_name -> google.protobuf.Timestamp
+	25, // 2: asynctask.v1.TaskItem.last_run_time:type_name -> google.protobuf.Timestamp
+	25, // 3: asynctask.v1.TaskItem.last_run_end_time:type_name -> google.protobuf.Timestamp
+	25, // 4: asynctask.v1.TaskItem.run_at:type_name -> google.protobuf.Timestamp
+	25, // 5: asynctask.v1.TaskItem.next_run_at:type_name -> google.protobuf.Timestamp
+	25, // 6: asynctask.v1.SubmitTaskReq.run_at:type_name -> google.protobuf.Timestamp
+	1,  // 7: asynctask.v1.ListTasksResp.tasks:type_name -> asynctask.v1.TaskItem
+	25, // 8: asynctask.v1.TaskRecordItem.created_at:type_name -> google.protobuf.Timestamp
+	9,  // 9: asynctask.v1.ListRecordsResp.records:type_name -> asynctask.v1.TaskRecordItem
+	12, // 10: asynctask.v1.ListSchedulersResp.schedulers:type_name -> asynctask.v1.SchedulerInfo
+	18, // 11: asynctask.v1.AllMetricsResp.metrics:type_name -> asynctask.v1.MetricItem
+	1,  // 12: asynctask.v1.DeadTaskDetail.task:type_name -> asynctask.v1.TaskItem
+	9,  // 13: asynctask.v1.DeadTaskDetail.attempts:type_name -> asynctask.v1.TaskRecordItem
+	1,  // 14: asynctask.v1.TaskGraph.nodes:type_name -> asynctask.v1.TaskItem
+	23, // 15: asynctask.v1.TaskGraph.edges:type_name -> asynctask.v1.TaskGraphEdge
+	2,  // 16: asynctask.v1.AsyncTaskAdmin.SubmitTask:input_type -> asynctask.v1.SubmitTaskReq
+	4,  // 17: asynctask.v1.AsyncTaskAdmin.GetTask:input_type -> asynctask.v1.GetTaskReq
+	5,  // 18: asynctask.v1.AsyncTaskAdmin.ListTasks:input_type -> asynctask.v1.ListTasksReq
+	7,  // 19: asynctask.v1.AsyncTaskAdmin.RetryTask:input_type -> asynctask.v1.RetryTaskReq
+	8,  // 20: asynctask.v1.AsyncTaskAdmin.CancelTask:input_type -> asynctask.v1.CancelTaskReq
+	10, // 21: asynctask.v1.AsyncTaskAdmin.ListRecords:input_type -> asynctask.v1.ListRecordsReq
+	0,  // 22: asynctask.v1.AsyncTaskAdmin.GetStats:input_type -> asynctask.v1.Empty
+	0,  // 23: asynctask.v1.AsyncTaskAdmin.ListSchedulers:input_type -> asynctask.v1.Empty
+	14, // 24: asynctask.v1.AsyncTaskAdmin.GetScheduler:input_type -> asynctask.v1.GetSchedulerReq
+	15, // 25: asynctask.v1.AsyncTaskAdmin.StartScheduler:input_type -> asynctask.v1.StartSchedulerReq
+	16, // 26: asynctask.v1.AsyncTaskAdmin.StopScheduler:input_type -> asynctask.v1.StopSchedulerReq
+	0,  // 27: asynctask.v1.AsyncTaskAdmin.GetAllMetrics:input_type -> asynctask.v1.Empty
+	5,  // 28: asynctask.v1.AsyncTaskAdmin.ListDeadTasks:input_type -> asynctask.v1.ListTasksReq
+	4,  // 29: asynctask.v1.AsyncTaskAdmin.GetDeadTask:input_type -> asynctask.v1.GetTaskReq
+	21, // 30: asynctask.v1.AsyncTaskAdmin.RequeueDeadTasks:input_type -> asynctask.v1.DeadTasksReq
+	21, // 31: asynctask.v1.AsyncTaskAdmin.PurgeDeadTasks:input_type -> asynctask.v1.DeadTasksReq
+	4,  // 32: asynctask.v1.AsyncTaskAdmin.GetTaskGraph:input_type -> asynctask.v1.GetTaskReq
+	3,  // 33: asynctask.v1.AsyncTaskAdmin.SubmitTask:output_type -> asynctask.v1.SubmitTaskResp
+	1,  // 34: asynctask.v1.AsyncTaskAdmin.GetTask:output_type -> asynctask.v1.TaskItem
+	6,  // 35: asynctask.v1.AsyncTaskAdmin.ListTasks:output_type -> asynctask.v1.ListTasksResp
+	0,  // 36: asynctask.v1.AsyncTaskAdmin.RetryTask:output_type -> asynctask.v1.Empty
+	0,  // 37: asynctask.v1.AsyncTaskAdmin.CancelTask:output_type -> asynctask.v1.Empty
+	11, // 38: asynctask.v1.AsyncTaskAdmin.ListRecords:output_type -> asynctask.v1.ListRecordsResp
+	17, // 39: asynctask.v1.AsyncTaskAdmin.GetStats:output_type -> asynctask.v1.TaskStatsResp
+	13, // 40: asynctask.v1.AsyncTaskAdmin.ListSchedulers:output_type -> asynctask.v1.ListSchedulersResp
+	12, // 41: asynctask.v1.AsyncTaskAdmin.GetScheduler:output_type -> asynctask.v1.SchedulerInfo
+	0,  // 42: asynctask.v1.AsyncTaskAdmin.StartScheduler:output_type -> asynctask.v1.Empty
+	0,  // 43: asynctask.v1.AsyncTaskAdmin.StopScheduler:output_type -> asynctask.v1.Empty
+	19, // 44: asynctask.v1.AsyncTaskAdmin.GetAllMetrics:output_type -> asynctask.v1.AllMetricsResp
+	6,  // 45: asynctask.v1.AsyncTaskAdmin.ListDeadTasks:output_type -> asynctask.v1.ListTasksResp
+	20, // 46: asynctask.v1.AsyncTaskAdmin.GetDeadTask:output_type -> asynctask.v1.DeadTaskDetail
+	22, // 47: asynctask.v1.AsyncTaskAdmin.RequeueDeadTasks:output_type -> asynctask.v1.DeadTasksResp
+	22, // 48: asynctask.v1.AsyncTaskAdmin.PurgeDeadTasks:output_type -> asynctask.v1.DeadTasksResp
+	24, // 49: asynctask.v1.AsyncTaskAdmin.GetTaskGraph:output_type -> asynctask.v1.TaskGraph
+	33, // [33:50] is the sub-list for method output_type
+	16, // [16:33] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_components_asynctask_pb_async_task_admin_proto_init() }
@@ -1641,7 +2107,7 @@ func file_components_asynctask_pb_async_task_admin_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_components_asynctask_pb_async_task_admin_proto_rawDesc), len(file_components_asynctask_pb_async_task_admin_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   20,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
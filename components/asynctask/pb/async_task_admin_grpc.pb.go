@@ -19,18 +19,23 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	AsyncTaskAdmin_SubmitTask_FullMethodName     = "/asynctask.v1.AsyncTaskAdmin/SubmitTask"
-	AsyncTaskAdmin_GetTask_FullMethodName        = "/asynctask.v1.AsyncTaskAdmin/GetTask"
-	AsyncTaskAdmin_ListTasks_FullMethodName      = "/asynctask.v1.AsyncTaskAdmin/ListTasks"
-	AsyncTaskAdmin_RetryTask_FullMethodName      = "/asynctask.v1.AsyncTaskAdmin/RetryTask"
-	AsyncTaskAdmin_CancelTask_FullMethodName     = "/asynctask.v1.AsyncTaskAdmin/CancelTask"
-	AsyncTaskAdmin_ListRecords_FullMethodName    = "/asynctask.v1.AsyncTaskAdmin/ListRecords"
-	AsyncTaskAdmin_GetStats_FullMethodName       = "/asynctask.v1.AsyncTaskAdmin/GetStats"
-	AsyncTaskAdmin_ListSchedulers_FullMethodName = "/asynctask.v1.AsyncTaskAdmin/ListSchedulers"
-	AsyncTaskAdmin_GetScheduler_FullMethodName   = "/asynctask.v1.AsyncTaskAdmin/GetScheduler"
-	AsyncTaskAdmin_StartScheduler_FullMethodName = "/asynctask.v1.AsyncTaskAdmin/StartScheduler"
-	AsyncTaskAdmin_StopScheduler_FullMethodName  = "/asynctask.v1.AsyncTaskAdmin/StopScheduler"
-	AsyncTaskAdmin_GetAllMetrics_FullMethodName  = "/asynctask.v1.AsyncTaskAdmin/GetAllMetrics"
+	AsyncTaskAdmin_SubmitTask_FullMethodName       = "/asynctask.v1.AsyncTaskAdmin/SubmitTask"
+	AsyncTaskAdmin_GetTask_FullMethodName          = "/asynctask.v1.AsyncTaskAdmin/GetTask"
+	AsyncTaskAdmin_ListTasks_FullMethodName        = "/asynctask.v1.AsyncTaskAdmin/ListTasks"
+	AsyncTaskAdmin_RetryTask_FullMethodName        = "/asynctask.v1.AsyncTaskAdmin/RetryTask"
+	AsyncTaskAdmin_CancelTask_FullMethodName       = "/asynctask.v1.AsyncTaskAdmin/CancelTask"
+	AsyncTaskAdmin_ListRecords_FullMethodName      = "/asynctask.v1.AsyncTaskAdmin/ListRecords"
+	AsyncTaskAdmin_GetStats_FullMethodName         = "/asynctask.v1.AsyncTaskAdmin/GetStats"
+	AsyncTaskAdmin_ListSchedulers_FullMethodName   = "/asynctask.v1.AsyncTaskAdmin/ListSchedulers"
+	AsyncTaskAdmin_GetScheduler_FullMethodName     = "/asynctask.v1.AsyncTaskAdmin/GetScheduler"
+	AsyncTaskAdmin_StartScheduler_FullMethodName   = "/asynctask.v1.AsyncTaskAdmin/StartScheduler"
+	AsyncTaskAdmin_StopScheduler_FullMethodName    = "/asynctask.v1.AsyncTaskAdmin/StopScheduler"
+	AsyncTaskAdmin_GetAllMetrics_FullMethodName    = "/asynctask.v1.AsyncTaskAdmin/GetAllMetrics"
+	AsyncTaskAdmin_ListDeadTasks_FullMethodName    = "/asynctask.v1.AsyncTaskAdmin/ListDeadTasks"
+	AsyncTaskAdmin_GetDeadTask_FullMethodName      = "/asynctask.v1.AsyncTaskAdmin/GetDeadTask"
+	AsyncTaskAdmin_RequeueDeadTasks_FullMethodName = "/asynctask.v1.AsyncTaskAdmin/RequeueDeadTasks"
+	AsyncTaskAdmin_PurgeDeadTasks_FullMethodName   = "/asynctask.v1.AsyncTaskAdmin/PurgeDeadTasks"
+	AsyncTaskAdmin_GetTaskGraph_FullMethodName     = "/asynctask.v1.AsyncTaskAdmin/GetTaskGraph"
 )
 
 // AsyncTaskAdminClient is the client API for AsyncTaskAdmin service.
@@ -49,6 +54,11 @@ type AsyncTaskAdminClient interface {
 	StartScheduler(ctx context.Context, in *StartSchedulerReq, opts ...grpc.CallOption) (*Empty, error)
 	StopScheduler(ctx context.Context, in *StopSchedulerReq, opts ...grpc.CallOption) (*Empty, error)
 	GetAllMetrics(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AllMetricsResp, error)
+	ListDeadTasks(ctx context.Context, in *ListTasksReq, opts ...grpc.CallOption) (*ListTasksResp, error)
+	GetDeadTask(ctx context.Context, in *GetTaskReq, opts ...grpc.CallOption) (*DeadTaskDetail, error)
+	RequeueDeadTasks(ctx context.Context, in *DeadTasksReq, opts ...grpc.CallOption) (*DeadTasksResp, error)
+	PurgeDeadTasks(ctx context.Context, in *DeadTasksReq, opts ...grpc.CallOption) (*DeadTasksResp, error)
+	GetTaskGraph(ctx context.Context, in *GetTaskReq, opts ...grpc.CallOption) (*TaskGraph, error)
 }
 
 type asyncTaskAdminClient struct {
@@ -179,6 +189,56 @@ func (c *asyncTaskAdminClient) GetAllMetrics(ctx context.Context, in *Empty, opt
 	return out, nil
 }
 
+func (c *asyncTaskAdminClient) ListDeadTasks(ctx context.Context, in *ListTasksReq, opts ...grpc.CallOption) (*ListTasksResp, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTasksResp)
+	err := c.cc.Invoke(ctx, AsyncTaskAdmin_ListDeadTasks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *asyncTaskAdminClient) GetDeadTask(ctx context.Context, in *GetTaskReq, opts ...grpc.CallOption) (*DeadTaskDetail, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeadTaskDetail)
+	err := c.cc.Invoke(ctx, AsyncTaskAdmin_GetDeadTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *asyncTaskAdminClient) RequeueDeadTasks(ctx context.Context, in *DeadTasksReq, opts ...grpc.CallOption) (*DeadTasksResp, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeadTasksResp)
+	err := c.cc.Invoke(ctx, AsyncTaskAdmin_RequeueDeadTasks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *asyncTaskAdminClient) PurgeDeadTasks(ctx context.Context, in *DeadTasksReq, opts ...grpc.CallOption) (*DeadTasksResp, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeadTasksResp)
+	err := c.cc.Invoke(ctx, AsyncTaskAdmin_PurgeDeadTasks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *asyncTaskAdminClient) GetTaskGraph(ctx context.Context, in *GetTaskReq, opts ...grpc.CallOption) (*TaskGraph, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TaskGraph)
+	err := c.cc.Invoke(ctx, AsyncTaskAdmin_GetTaskGraph_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AsyncTaskAdminServer is the server API for AsyncTaskAdmin service.
 // All implementations must embed UnimplementedAsyncTaskAdminServer
 // for forward compatibility.
@@ -195,6 +255,11 @@ type AsyncTaskAdminServer interface {
 	StartScheduler(context.Context, *StartSchedulerReq) (*Empty, error)
 	StopScheduler(context.Context, *StopSchedulerReq) (*Empty, error)
 	GetAllMetrics(context.Context, *Empty) (*AllMetricsResp, error)
+	ListDeadTasks(context.Context, *ListTasksReq) (*ListTasksResp, error)
+	GetDeadTask(context.Context, *GetTaskReq) (*DeadTaskDetail, error)
+	RequeueDeadTasks(context.Context, *DeadTasksReq) (*DeadTasksResp, error)
+	PurgeDeadTasks(context.Context, *DeadTasksReq) (*DeadTasksResp, error)
+	GetTaskGraph(context.Context, *GetTaskReq) (*TaskGraph, error)
 	mustEmbedUnimplementedAsyncTaskAdminServer()
 }
 
@@ -241,6 +306,21 @@ func (UnimplementedAsyncTaskAdminServer) StopScheduler(context.Context, *StopSch
 func (UnimplementedAsyncTaskAdminServer) GetAllMetrics(context.Context, *Empty) (*AllMetricsResp, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetAllMetrics not implemented")
 }
+func (UnimplementedAsyncTaskAdminServer) ListDeadTasks(context.Context, *ListTasksReq) (*ListTasksResp, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListDeadTasks not implemented")
+}
+func (UnimplementedAsyncTaskAdminServer) GetDeadTask(context.Context, *GetTaskReq) (*DeadTaskDetail, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetDeadTask not implemented")
+}
+func (UnimplementedAsyncTaskAdminServer) RequeueDeadTasks(context.Context, *DeadTasksReq) (*DeadTasksResp, error) {
+	return nil, status.Error(codes.Unimplemented, "method RequeueDeadTasks not implemented")
+}
+func (UnimplementedAsyncTaskAdminServer) PurgeDeadTasks(context.Context, *DeadTasksReq) (*DeadTasksResp, error) {
+	return nil, status.Error(codes.Unimplemented, "method PurgeDeadTasks not implemented")
+}
+func (UnimplementedAsyncTaskAdminServer) GetTaskGraph(context.Context, *GetTaskReq) (*TaskGraph, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetTaskGraph not implemented")
+}
 func (UnimplementedAsyncTaskAdminServer) mustEmbedUnimplementedAsyncTaskAdminServer() {}
 func (UnimplementedAsyncTaskAdminServer) testEmbeddedByValue()                        {}
 
@@ -478,6 +558,96 @@ func _AsyncTaskAdmin_GetAllMetrics_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AsyncTaskAdmin_ListDeadTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTasksReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AsyncTaskAdminServer).ListDeadTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AsyncTaskAdmin_ListDeadTasks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AsyncTaskAdminServer).ListDeadTasks(ctx, req.(*ListTasksReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AsyncTaskAdmin_GetDeadTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTaskReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AsyncTaskAdminServer).GetDeadTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AsyncTaskAdmin_GetDeadTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AsyncTaskAdminServer).GetDeadTask(ctx, req.(*GetTaskReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AsyncTaskAdmin_RequeueDeadTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadTasksReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AsyncTaskAdminServer).RequeueDeadTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AsyncTaskAdmin_RequeueDeadTasks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AsyncTaskAdminServer).RequeueDeadTasks(ctx, req.(*DeadTasksReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AsyncTaskAdmin_PurgeDeadTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadTasksReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AsyncTaskAdminServer).PurgeDeadTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AsyncTaskAdmin_PurgeDeadTasks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AsyncTaskAdminServer).PurgeDeadTasks(ctx, req.(*DeadTasksReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AsyncTaskAdmin_GetTaskGraph_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTaskReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AsyncTaskAdminServer).GetTaskGraph(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AsyncTaskAdmin_GetTaskGraph_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AsyncTaskAdminServer).GetTaskGraph(ctx, req.(*GetTaskReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AsyncTaskAdmin_ServiceDesc is the grpc.ServiceDesc for AsyncTaskAdmin service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -533,6 +703,26 @@ var AsyncTaskAdmin_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAllMetrics",
 			Handler:    _AsyncTaskAdmin_GetAllMetrics_Handler,
 		},
+		{
+			MethodName: "ListDeadTasks",
+			Handler:    _AsyncTaskAdmin_ListDeadTasks_Handler,
+		},
+		{
+			MethodName: "GetDeadTask",
+			Handler:    _AsyncTaskAdmin_GetDeadTask_Handler,
+		},
+		{
+			MethodName: "RequeueDeadTasks",
+			Handler:    _AsyncTaskAdmin_RequeueDeadTasks_Handler,
+		},
+		{
+			MethodName: "PurgeDeadTasks",
+			Handler:    _AsyncTaskAdmin_PurgeDeadTasks_Handler,
+		},
+		{
+			MethodName: "GetTaskGraph",
+			Handler:    _AsyncTaskAdmin_GetTaskGraph_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "components/asynctask/pb/async_task_admin.proto",
@@ -59,6 +59,12 @@ type Scheduler struct {
 	// Hook 仅作用于本 scheduler 的任务事件钩子（与全局 RegisterTaskHook 共存）。
 	Hook TaskHook
 
+	// Concurrency 按服务/租户的并发上限，基于 DB 计数跨副本生效。
+	Concurrency ConcurrencyLimits
+
+	// RetryBackoff 任务未配置退避策略时使用的默认策略，零值表示立即重试。
+	RetryBackoff RetryBackoffPolicy
+
 	tracer trace.Tracer
 
 	taskIdChan chan int64
@@ -152,6 +158,7 @@ func (s *Scheduler) Bootstrap(ctx context.Context) error {
 		&TaskModel{},
 		&HeartBeatModel{},
 		&TaskExecModel{},
+		&ConcurrencyLockModel{},
 	); err != nil {
 		return fmt.Errorf("asynctask migrate tables: %w", err)
 	}
@@ -360,7 +367,7 @@ func (s *Scheduler) scanTasks() {
 			start, span := s.tracer.Start(context.Background(), "ScanTasks")
 			scanStart := time.Now()
 			scans := atomic.AddInt64(&s.statusInfo.Scans, 1)
-			ids, _, err := findNeedRunTaskIds(s.ScanTaskNum, s.Theme, start)
+			ids, err := s.findRunnableTaskIds(start)
 			recordScan(start, s.Theme, scanStart, err != nil)
 			if err != nil {
 				s.Logger.Error("扫描需要运行的任务Id列表失败:" + err.Error())
@@ -397,6 +404,28 @@ func (s *Scheduler) scanTasks() {
 	}
 }
 
+// findRunnableTaskIds 扫描待运行任务，跳过已达到并发上限的服务与租户。
+func (s *Scheduler) findRunnableTaskIds(ctx context.Context) ([]int64, error) {
+	filter, err := saturatedFilter(s.Theme, s.Concurrency, ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids, _, err := findNeedRunTaskIds(s.ScanTaskNum, s.Theme, filter, ctx)
+	return ids, err
+}
+
+// lockTask 抢占任务；配置了并发上限时在上限内抢占。
+func (s *Scheduler) lockTask(taskId int64, ctx context.Context) (bool, error) {
+	if !s.Concurrency.enabled() {
+		return tryLockTask(taskId, ctx)
+	}
+	flag, limited, err := tryLockTaskWithLimits(taskId, s.Theme, s.Concurrency, ctx)
+	if limited {
+		s.Logger.DebugF("任务%d已达到并发上限，等待下一轮调度", taskId)
+	}
+	return flag, err
+}
+
 func (s *Scheduler) allowInQueue(taskId int64) bool {
 	s.inQueueTaskIdsRw.Lock()
 	defer s.inQueueTaskIdsRw.Unlock()
@@ -499,6 +528,9 @@ func (s *Scheduler) GetStatusInfo() SchedulerStatusInfo {
 		WorkerScaleDown: s.counters.workerDown.Load(),
 		AlarmFired:      s.counters.alarmFired.Load(),
 		AlarmSuppressed: s.counters.alarmSuppress.Load(),
+		DeadLetterCount: s.counters.deadLetter.Load(),
+		CanceledCount:   s.counters.canceled.Load(),
+		TimedOutCount:   s.counters.timedOut.Load(),
 	}
 }
 
@@ -538,6 +570,9 @@ func (s *Scheduler) SnapshotMetrics() MetricsSnapshot {
 		WorkerScaleDown:  status.WorkerScaleDown,
 		AlarmFiredCount:  status.AlarmFired,
 		AlarmSuppressed:  status.AlarmSuppressed,
+		DeadLetterCount:  status.DeadLetterCount,
+		CanceledCount:    status.CanceledCount,
+		TimedOutCount:    status.TimedOutCount,
 		LastSnapshotTime: time.Now().UnixMilli(),
 	}
 }
@@ -562,12 +597,17 @@ func (s *Scheduler) popEnqueueAt(taskId int64) (time.Time, bool) {
 
 // fireAlarm 统一告警入口；启用 throttle 时会去重，否则直接发送。
 func (s *Scheduler) fireAlarm(title, content string) {
+	s.fireAlarmWithKey(alarmKey(title, content), title, content)
+}
+
+// fireAlarmWithKey 按指定 key 去重的告警入口。
+func (s *Scheduler) fireAlarmWithKey(key, title, content string) {
 	if s.alarmThrottle != nil {
 		// AlarmThrottle 内部已自行判断是否真实发送，并在内部维护 fired/suppressed 全局指标。
 		// 这里再额外做本地 counter 记录，方便 admin 接口直接读取。
 		before := GetMetrics().AlarmFired
 		_ = before
-		s.alarmThrottle.Send(title, content)
+		s.alarmThrottle.SendWithKey(key, title, content)
 		// 简化：每次调用都视为一次"投递尝试"，由 throttle 内部决定 fired/suppressed
 		// 这里通过比较前后无法精确归因到本 scheduler，因此采取简化策略：
 		// throttle.Send 已增加全局 metric；本地 counter 在 send 真实发送时由 SendSystemAlarm 直接调用计数。
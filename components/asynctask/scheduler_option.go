@@ -81,3 +81,37 @@ func WithAlarmThrottle(window time.Duration) SchedulerOption {
 		temp.alarmThrottle = NewAlarmThrottle(window)
 	}
 }
+
+// WithServiceConcurrency 限制某个 ServiceName 在本 theme 下同时运行的任务数；limit<=0 表示不限制。
+func WithServiceConcurrency(serviceName string, limit int) SchedulerOption {
+	return func(temp *Scheduler) {
+		if temp.Concurrency.Service == nil {
+			temp.Concurrency.Service = make(map[string]int)
+		}
+		temp.Concurrency.Service[serviceName] = limit
+	}
+}
+
+// WithTenantConcurrency 限制某个租户在本 theme 下同时运行的任务数；limit<=0 表示该租户不限制（覆盖默认上限）。
+func WithTenantConcurrency(tenantId string, limit int) SchedulerOption {
+	return func(temp *Scheduler) {
+		if temp.Concurrency.Tenant == nil {
+			temp.Concurrency.Tenant = make(map[string]int)
+		}
+		temp.Concurrency.Tenant[tenantId] = limit
+	}
+}
+
+// WithDefaultTenantConcurrency 为未单独配置的租户设置默认并发上限；limit<=0 表示不限制。
+func WithDefaultTenantConcurrency(limit int) SchedulerOption {
+	return func(temp *Scheduler) {
+		temp.Concurrency.DefaultTenant = limit
+	}
+}
+
+// WithRetryBackoff 设置任务未单独配置退避策略时的默认重试退避策略。
+func WithRetryBackoff(policy RetryBackoffPolicy) SchedulerOption {
+	return func(temp *Scheduler) {
+		temp.RetryBackoff = policy
+	}
+}
@@ -6,9 +6,11 @@ package asynctask
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
 )
 
 var taskTable = "asynctasks"
@@ -17,7 +19,7 @@ var taskTable = "asynctasks"
 type TaskModel struct {
 	Id int64 `gorm:"column:id;primaryKey;autoIncrement"`
 	TaskBaseInfo
-	SystemName      string       `gorm:"column:system_name;size:32;not null;default:'';index:idx_asynctasks_system_name"`
+	SystemName      string       `gorm:"column:system_name;size:32;not null;default:'';index:idx_asynctasks_system_name;uniqueIndex:uniq_asynctasks_dedup_key,priority:1"`
 	TaskStatus      string       `gorm:"column:task_status;size:32;not null;default:Wait;index:idx_asynctasks_task_status"`
 	LastRunTime     sql.NullTime `gorm:"column:last_run_time"`
 	LastRunEndTime  sql.NullTime `gorm:"column:last_run_end_time"`
@@ -28,6 +30,20 @@ type TaskModel struct {
 	LastResult      string       `gorm:"column:last_result;type:longtext"`
 	LastErrMsg      string       `gorm:"column:last_err_msg;size:512;not null;default:''"`
 	LogId           string       `gorm:"column:log_id;size:64"`
+	// NextRunAt 失败重试按退避策略计算出的下次执行时间
+	NextRunAt sql.NullTime `gorm:"column:next_run_at;index:idx_asynctasks_next_run_at"`
+	// RootId 所属任务链/任务组中首个任务的 id，独立任务为 0
+	RootId int64 `gorm:"column:root_id;not null;default:0;index:idx_asynctasks_root_id"`
+	// ParentId 任务链中的上游任务 id
+	ParentId int64 `gorm:"column:parent_id;not null;default:0;index:idx_asynctasks_parent_id"`
+	// GroupId 所属任务组 id（组内首个任务的 id）
+	GroupId int64 `gorm:"column:group_id;not null;default:0;index:idx_asynctasks_group_id"`
+	// CallbackGroupId 回调任务等待的任务组 id，组内任务全部结束后触发
+	CallbackGroupId int64 `gorm:"column:callback_group_id;not null;default:0;index:idx_asynctasks_callback_group_id"`
+	// UpstreamPolicy 上游任务失败时的处理策略 abort/continue
+	UpstreamPolicy string `gorm:"column:upstream_policy;size:16;not null;default:''"`
+	// AbortedBy 按 abort 策略终止该任务的上游任务 id，上游被重试或重新入队时据此恢复，0 表示未被上游终止
+	AbortedBy int64 `gorm:"column:aborted_by;not null;default:0;index:idx_asynctasks_aborted_by"`
 }
 
 func (TaskModel) TableName() string { return taskTable }
@@ -41,10 +57,32 @@ type TaskBaseInfo struct {
 	MaxRetryTime int    `gorm:"column:max_retry_time;not null;default:0"`
 	Priority     int32  `gorm:"column:priority;not null;default:0;index:idx_asynctasks_priority"`
 	TenantId     string `gorm:"column:tenant_id;size:64;not null;default:'';index:idx_asynctasks_tenant_id"`
+	// RunAt 最早可执行时间，为空表示提交后立即可执行
+	RunAt *time.Time `gorm:"column:run_at;index:idx_asynctasks_run_at"`
+	// RetryBackoff 重试退避策略 fixed/exponential/jitter，为空时使用调度器的默认策略
+	RetryBackoff string `gorm:"column:retry_backoff;size:16;not null;default:''"`
+	// RetryDelaySeconds 退避基础延迟（秒）
+	RetryDelaySeconds int `gorm:"column:retry_delay_seconds;not null;default:0"`
+	// RetryMaxDelaySeconds 退避延迟上限（秒）
+	RetryMaxDelaySeconds int `gorm:"column:retry_max_delay_seconds;not null;default:0"`
+	// TimeoutSeconds 单次执行超时（秒），通过 ctx deadline 通知业务方法，<=0 表示不限制
+	TimeoutSeconds int `gorm:"column:timeout_seconds;not null;default:0"`
+	// DedupKey 去重键，同一 theme 下唯一；为空表示不去重（列值为 NULL，不参与唯一约束）
+	DedupKey string `gorm:"column:dedup_key;size:128;default:null;uniqueIndex:uniq_asynctasks_dedup_key,priority:2"`
+	// DedupMode 重复提交的处理方式 keep/replace/latest，为空等同 keep
+	DedupMode string `gorm:"column:dedup_mode;size:16;not null;default:''"`
+	// DedupWindowSeconds 去重窗口（秒）：keep 模式下超出窗口的提交会新建任务，<=0 表示不过期；latest 模式下为防抖延迟
+	DedupWindowSeconds int `gorm:"column:dedup_window_seconds;not null;default:0"`
 }
 
 // AddTask 新增一个任务
 func AddTask(task TaskBaseInfo, systemName string, ctx context.Context) (model TaskModel, err error) {
+	if !validRetryBackoff(task.RetryBackoff) {
+		return TaskModel{}, fmt.Errorf("unknown retry backoff %q", task.RetryBackoff)
+	}
+	if err := validateDedup(task); err != nil {
+		return TaskModel{}, err
+	}
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
 		return TaskModel{}, err
@@ -58,6 +96,17 @@ func AddTask(task TaskBaseInfo, systemName string, ctx context.Context) (model T
 		UpdateAt:     time.Now(),
 	}
 
+	if task.DedupKey != "" {
+		result, inserted, err := addTaskWithDedup(db.GetGormDb().WithContext(ctx), model)
+		if err != nil {
+			return TaskModel{}, err
+		}
+		if inserted {
+			emitEnqueueLog(result)
+		}
+		return result, nil
+	}
+
 	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Create(&model)
 	if tx.Error != nil {
 		return TaskModel{}, tx.Error
@@ -69,17 +118,33 @@ func AddTask(task TaskBaseInfo, systemName string, ctx context.Context) (model T
 	return model, nil
 }
 
-func findNeedRunTaskIds(limit int, systemName string, ctx context.Context) (taskIds []int64, needContinue bool, err error) {
+// scanFilter 扫描时需要排除的服务与租户（已达到并发上限）
+type scanFilter struct {
+	ExcludeServices []string
+	ExcludeTenants  []string
+}
+
+// findNeedRunTaskIds 按优先级从高到低、同优先级按提交顺序取出已到执行时间的待运行任务
+func findNeedRunTaskIds(limit int, systemName string, filter scanFilter, ctx context.Context) (taskIds []int64, needContinue bool, err error) {
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
 		return nil, false, err
 	}
+	return findNeedRunTaskIdsInDB(db.GetGormDb().WithContext(ctx), limit, systemName, filter)
+}
 
-	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Select("id").
+func findNeedRunTaskIdsInDB(db *gorm.DB, limit int, systemName string, filter scanFilter) (taskIds []int64, needContinue bool, err error) {
+	query := db.Table(taskTable).Select("id").
 		Where("task_status in ?", []string{TaskStatusWait.String(), TaskStatusRetry.String()}).
-		Where("system_name = ?", systemName).
-		Limit(limit).Order("id asc").
-		Find(&taskIds)
+		Where("system_name = ?", systemName)
+	query = whereRunnable(query, time.Now())
+	if len(filter.ExcludeServices) > 0 {
+		query = query.Where("service_name NOT IN ?", filter.ExcludeServices)
+	}
+	if len(filter.ExcludeTenants) > 0 {
+		query = query.Where("tenant_id NOT IN ?", filter.ExcludeTenants)
+	}
+	tx := query.Limit(limit).Order("priority desc, id asc").Find(&taskIds)
 	if tx.Error != nil {
 		return nil, false, tx.Error
 	}
@@ -89,15 +154,21 @@ func findNeedRunTaskIds(limit int, systemName string, ctx context.Context) (task
 	return
 }
 
+// whereRunnable 只保留已到执行时间的任务：run_at 为提交时指定的最早执行时间，next_run_at 为重试退避后的下次执行时间
+func whereRunnable(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("(run_at IS NULL OR run_at <= ?)", now).
+		Where("(next_run_at IS NULL OR next_run_at <= ?)", now)
+}
+
 func tryLockTask(taskId int64, ctx context.Context) (flag bool, err error) {
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
 		return false, err
 	}
 
-	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Where(map[string]any{"id": taskId,
-		"task_status": []string{TaskStatusWait.String(), TaskStatusRetry.String()}}).
-		UpdateColumn("task_status", TaskStatusRunning.String())
+	query := db.GetGormDb().WithContext(ctx).Table(taskTable).Where(map[string]any{"id": taskId,
+		"task_status": []string{TaskStatusWait.String(), TaskStatusRetry.String()}})
+	tx := whereRunnable(query, time.Now()).UpdateColumn("task_status", TaskStatusRunning.String())
 	if tx.Error != nil {
 		return false, tx.Error
 	}
@@ -107,22 +178,24 @@ func tryLockTask(taskId int64, ctx context.Context) (flag bool, err error) {
 	return true, nil
 }
 
-func updateTaskSuccess(taskId int64, res string, startTime time.Time, ctx context.Context) error {
+func updateTaskSuccess(taskInfo TaskModel, res string, startTime time.Time, ctx context.Context) error {
 	endTime := time.Now()
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
 		return err
 	}
-	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Where(map[string]any{"id": taskId}).
+	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Where(map[string]any{"id": taskInfo.Id}).
 		Updates(map[string]any{"task_status": TaskStatusSuccess.String(),
 			"last_result": res, "last_run_time": startTime, "last_run_end_time": endTime,
-			"last_run_duration": endTime.Sub(startTime).Milliseconds(), "log_id": gaia.GetContextTrace().Id})
+			"last_run_duration": endTime.Sub(startTime).Milliseconds(), "log_id": gaia.GetContextTrace().Id,
+			"next_run_at": nil})
 	if tx.Error != nil {
 		return tx.Error
 	}
 	return InsertTaskExecRow(TaskExecModel{
-		TaskId:          taskId,
+		TaskId:          taskInfo.Id,
 		TaskStatus:      TaskStatusSuccess.String(),
+		Attempt:         taskInfo.RetryTime + 1,
 		LastResult:      res,
 		LastRunTime:     startTime,
 		LastRunEndTime:  endTime,
@@ -131,23 +204,33 @@ func updateTaskSuccess(taskId int64, res string, startTime time.Time, ctx contex
 	}, ctx)
 }
 
-func updateTaskFailed(taskId int64, res string, startTime time.Time, ctx context.Context) error {
+func updateTaskFailed(taskInfo TaskModel, res string, startTime time.Time, ctx context.Context) error {
+	return updateTaskFinalFailure(taskInfo, TaskStatusFailed, res, startTime, ctx)
+}
+
+// updateTaskDead 重试耗尽，任务进入死信；执行历史保留在 asynctask_exec_row 中
+func updateTaskDead(taskInfo TaskModel, res string, startTime time.Time, ctx context.Context) error {
+	return updateTaskFinalFailure(taskInfo, TaskStatusDead, res, startTime, ctx)
+}
+
+func updateTaskFinalFailure(taskInfo TaskModel, status TaskStatus, res string, startTime time.Time, ctx context.Context) error {
 	endTime := time.Now()
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
 		return err
 	}
-	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Where(map[string]any{"id": taskId}).
-		Updates(map[string]any{"task_status": TaskStatusFailed.String(), "last_err_msg": res,
+	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Where(map[string]any{"id": taskInfo.Id}).
+		Updates(map[string]any{"task_status": status.String(), "last_err_msg": truncateErrMsg(res),
 			"last_run_time": startTime, "last_run_end_time": endTime, "log_id": gaia.GetContextTrace().Id,
-			"last_run_duration": endTime.Sub(startTime).Milliseconds()})
+			"last_run_duration": endTime.Sub(startTime).Milliseconds(), "next_run_at": nil})
 	if tx.Error != nil {
 		return tx.Error
 	}
 	return InsertTaskExecRow(TaskExecModel{
-		TaskId:          taskId,
-		TaskStatus:      TaskStatusFailed.String(),
-		LastErrMsg:      res,
+		TaskId:          taskInfo.Id,
+		TaskStatus:      status.String(),
+		Attempt:         taskInfo.RetryTime + 1,
+		LastErrMsg:      truncateErrMsg(res),
 		LastRunTime:     startTime,
 		LastRunEndTime:  endTime,
 		LastRunDuration: endTime.Sub(startTime).Milliseconds(),
@@ -162,7 +245,7 @@ func updateTaskWait(taskId int64, res string, startTime time.Time, ctx context.C
 		return err
 	}
 	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Where(map[string]any{"id": taskId}).
-		Updates(map[string]any{"task_status": TaskStatusWait.String(), "last_err_msg": res,
+		Updates(map[string]any{"task_status": TaskStatusWait.String(), "last_err_msg": truncateErrMsg(res),
 			"last_run_time": startTime, "last_run_end_time": endTime, "log_id": gaia.GetContextTrace().Id,
 			"last_run_duration": endTime.Sub(startTime).Milliseconds()})
 	if tx.Error != nil {
@@ -171,7 +254,7 @@ func updateTaskWait(taskId int64, res string, startTime time.Time, ctx context.C
 	return InsertTaskExecRow(TaskExecModel{
 		TaskId:          taskId,
 		TaskStatus:      TaskStatusFailed.String(),
-		LastErrMsg:      res,
+		LastErrMsg:      truncateErrMsg(res),
 		LastRunTime:     startTime,
 		LastRunEndTime:  endTime,
 		LastRunDuration: endTime.Sub(startTime).Milliseconds(),
@@ -179,31 +262,45 @@ func updateTaskWait(taskId int64, res string, startTime time.Time, ctx context.C
 	}, ctx)
 }
 
-func updateTaskRetry(taskInfo TaskModel, res string, startTime time.Time, ctx context.Context) error {
+// updateTaskRetry 置为 Retry 并记录按退避策略计算出的下次执行时间，nextRunAt 为零值表示立即重试
+func updateTaskRetry(taskInfo TaskModel, res string, startTime, nextRunAt time.Time, ctx context.Context) error {
 	endTime := time.Now()
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
 		return err
 	}
+	next := sql.NullTime{Time: nextRunAt, Valid: !nextRunAt.IsZero()}
 	tx := db.GetGormDb().WithContext(ctx).Table(taskTable).Where(map[string]any{"id": taskInfo.Id}).
-		Updates(map[string]any{"task_status": TaskStatusRetry.String(), "last_result": res,
+		Updates(map[string]any{"task_status": TaskStatusRetry.String(), "last_result": res, "last_err_msg": truncateErrMsg(res),
 			"retry_time": taskInfo.RetryTime + 1, "log_id": gaia.GetContextTrace().Id,
 			"last_run_time": startTime, "last_run_end_time": endTime,
-			"last_run_duration": endTime.Sub(startTime).Milliseconds()})
+			"last_run_duration": endTime.Sub(startTime).Milliseconds(), "next_run_at": next})
 	if tx.Error != nil {
 		return tx.Error
 	}
 	return InsertTaskExecRow(TaskExecModel{
 		TaskId:          taskInfo.Id,
 		TaskStatus:      TaskStatusRetry.String(),
+		Attempt:         taskInfo.RetryTime + 1,
 		LastResult:      res,
+		LastErrMsg:      truncateErrMsg(res),
 		LastRunTime:     startTime,
 		LastRunEndTime:  endTime,
 		LastRunDuration: endTime.Sub(startTime).Milliseconds(),
+		NextRunAt:       next,
 		LogId:           gaia.GetContextTrace().Id,
 	}, ctx)
 }
 
+// truncateErrMsg 错误信息截断到 last_err_msg 列的长度上限
+func truncateErrMsg(msg string) string {
+	const maxLen = 512
+	if len([]rune(msg)) <= maxLen {
+		return msg
+	}
+	return string([]rune(msg)[:maxLen])
+}
+
 func getTaskById(taskId int64, ctx context.Context) (model TaskModel, err error) {
 	db, err := gaia.NewMysqlWithSchema("AsyncTask.Mysql")
 	if err != nil {
@@ -5,6 +5,7 @@ package asynctask
 
 import (
 	"context"
+	"database/sql"
 	"slices"
 	"time"
 
@@ -16,13 +17,16 @@ type TaskExecModel struct {
 	Id              int64     `gorm:"column:id;primaryKey;autoIncrement"`
 	TaskId          int64     `gorm:"column:task_id;not null;default:0"`
 	TaskStatus      string    `gorm:"column:task_status;size:32;not null;default:Wait"`
+	Attempt         int       `gorm:"column:attempt;not null;default:0"` // 第几次执行，从 1 开始
 	LastResult      string    `gorm:"column:last_result;type:longtext"`
 	LastErrMsg      string    `gorm:"column:last_err_msg;size:512;not null;default:''"`
 	LastRunTime     time.Time `gorm:"column:last_run_time;index:idx_asynctask_exec_row_last_run_time"`
 	LastRunEndTime  time.Time `gorm:"column:last_run_end_time"`
 	LastRunDuration int64     `gorm:"column:last_run_duration;not null;default:0"`
 	LogId           string    `gorm:"column:log_id;size:128;not null;default:''"`
-	TaskName        string    `gorm:"column:task_name;->;-:migration"`
+	// NextRunAt 进入重试时计划的下次执行时间
+	NextRunAt sql.NullTime `gorm:"column:next_run_at"`
+	TaskName  string       `gorm:"column:task_name;->;-:migration"`
 }
 
 func (m *TaskExecModel) TableName() string {
@@ -75,7 +79,8 @@ func GetTaskExecStats(theme string, ctx context.Context) (*TaskExecStats, error)
 		Joins("join asynctasks on asynctasks.id = exec_rows.task_id").
 		Where("asynctasks.system_name = ?", theme).
 		Where("exec_rows.last_run_time > ?", fiveMinutesAgo).
-		Where("exec_rows.task_status IN ?", []string{TaskStatusSuccess.String(), TaskStatusFailed.String()}).
+		Where("exec_rows.task_status IN ?", []string{TaskStatusSuccess.String(), TaskStatusFailed.String(), TaskStatusDead.String(),
+			TaskStatusCanceled.String(), TaskStatusTimedOut.String()}).
 		Order("exec_rows.last_run_time DESC").
 		Limit(1000).
 		Find(&records)
@@ -103,7 +108,7 @@ func GetTaskExecStats(theme string, ctx context.Context) (*TaskExecStats, error)
 		switch r.TaskStatus {
 		case TaskStatusSuccess.String():
 			stats.SuccessNum++
-		case TaskStatusFailed.String():
+		case TaskStatusFailed.String(), TaskStatusDead.String(), TaskStatusCanceled.String(), TaskStatusTimedOut.String():
 			stats.FailedNum++
 		}
 	}
@@ -182,7 +187,7 @@ func GetFailureReasonTopK(theme string, since time.Duration, topK int, ctx conte
 		Select("LEFT(exec_rows.last_err_msg, 80) AS reason, COUNT(*) AS cnt, MAX(exec_rows.last_run_end_time) AS latest").
 		Joins("join asynctasks on asynctasks.id = exec_rows.task_id").
 		Where("asynctasks.system_name = ?", theme).
-		Where("exec_rows.task_status = ?", TaskStatusFailed.String()).
+		Where("exec_rows.task_status IN ?", []string{TaskStatusFailed.String(), TaskStatusDead.String(), TaskStatusTimedOut.String()}).
 		Where("exec_rows.last_run_time > ?", cutoff).
 		Where("exec_rows.last_err_msg <> ''").
 		Group("reason").
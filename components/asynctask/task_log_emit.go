@@ -32,6 +32,7 @@ import (
 //	"Retry"             → "retry"
 //	"Failed" 且没重试   → "drop"   （retry==maxRetry，没有再被拉起的机会）
 //	"Failed" 还能重试   → "fail"   （理论上当前 executor 不走这里，留兼容）
+//	"Dead"              → "drop"   （重试耗尽进入死信）
 //
 // 之所以区分 fail / drop：监控告警侧关心"是否还会自愈"——能 retry 的允许短暂抖动，
 // 但 drop 表示已经永久失败需要人工介入，告警阈值不一样。
@@ -41,6 +42,12 @@ func mapAsyncTaskStatusToPhase(status string, info TaskModel) string {
 		return "success"
 	case TaskStatusRetry.String():
 		return "retry"
+	case TaskStatusDead.String():
+		return "drop"
+	case TaskStatusCanceled.String():
+		return "cancel"
+	case TaskStatusTimedOut.String():
+		return "timeout"
 	case TaskStatusFailed.String():
 		// 是否已经"用光重试"——用光则归为 drop。
 		if info.MaxRetryTime > 0 && info.RetryTime+1 >= info.MaxRetryTime {
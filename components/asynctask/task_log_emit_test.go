@@ -33,6 +33,22 @@ func TestMapAsyncTaskStatusToPhase(t *testing.T) {
 			info:   TaskModel{},
 			want:   "retry",
 		},
+		{
+			name:   "dead → drop",
+			status: TaskStatusDead.String(),
+			info:   TaskModel{TaskBaseInfo: TaskBaseInfo{MaxRetryTime: 3}, RetryTime: 3},
+			want:   "drop",
+		},
+		{
+			name:   "canceled → cancel",
+			status: TaskStatusCanceled.String(),
+			want:   "cancel",
+		},
+		{
+			name:   "timed out → timeout",
+			status: TaskStatusTimedOut.String(),
+			want:   "timeout",
+		},
 		{
 			name:   "failed-with-room → fail",
 			status: TaskStatusFailed.String(),
@@ -160,7 +160,7 @@ func (s *Scheduler) exec(taskId int64, ctx context.Context) {
 	defer span.End()
 
 	lockTaskCtx, span2 := s.tracer.Start(start, fmt.Sprintf("抢占任务Id:%d", taskId))
-	flag, err := tryLockTask(taskId, lockTaskCtx)
+	flag, err := s.lockTask(taskId, lockTaskCtx)
 	span2.End()
 	if err != nil {
 		s.Logger.ErrorF("尝试锁定当前任务%d失败:%s", taskId, err.Error())
@@ -73,7 +73,8 @@ func (w *Worker) postHandler() coretask.PostHandlerFunc {
 			}
 			_ = w.bindingStore.MarkCallback(ctx, taskID, domain.AsyncTaskCallbackStatusSuccess, "")
 			return nil
-		case coretask.TaskStatusFailed.String():
+		case coretask.TaskStatusFailed.String(), coretask.TaskStatusDead.String(),
+			coretask.TaskStatusCanceled.String(), coretask.TaskStatusTimedOut.String():
 			if err := w.bindingStore.MarkTaskStatus(ctx, taskID, domain.AsyncTaskBindingStatusFailed, errMsg); err != nil {
 				gaia.ErrorF("workflow worker mark failed failed: %v", err)
 			}
@@ -291,7 +291,12 @@ func (s *AdminService) CreateUser(ctx context.Context, user *User) error {
 	if user.Status == "" {
 		user.Status = UserStatusNormal
 	}
-	return s.m.db.WithContext(ctx).Create(user).Error
+	return s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.m.tenantSvc.admitUser(tx, user.TenantID); err != nil {
+			return err
+		}
+		return tx.Create(user).Error
+	})
 }
 
 // CreateUserWithPassword 由管理员创建带初始密码的用户，同时创建密码凭证。
@@ -309,7 +314,7 @@ func (s *AdminService) CreateUserWithPassword(ctx context.Context, req CreateUse
 	}); err != nil {
 		return nil, accountError(ErrInvalidArgument, err.Error())
 	}
-	if err := validatePassword(req.Password, s.m.cfg.Password); err != nil {
+	if err := validatePassword(req.Password, s.m.tenantSvc.passwordConfig(ctx, tenantID)); err != nil {
 		return nil, err
 	}
 	if req.Status == "" {
@@ -318,49 +323,54 @@ func (s *AdminService) CreateUserWithPassword(ctx context.Context, req CreateUse
 
 	var created *UserInfo
 	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var count int64
-		if err := tx.Model(&User{}).Where("tenant_id = ? AND username = ?", tenantID, req.Username).Count(&count).Error; err != nil {
+		user, err := s.createUserTx(ctx, tx, tenantID, req, "admin")
+		if err != nil {
 			return err
 		}
-		if count > 0 {
-			return accountError(ErrIdentifierExists, "用户名已存在")
-		}
-		if req.Email != "" {
-			if err := tx.Model(&User{}).Where("tenant_id = ? AND email = ?", tenantID, req.Email).Count(&count).Error; err != nil {
-				return err
-			}
-			if count > 0 {
-				return accountError(ErrIdentifierExists, "邮箱已存在")
-			}
-		}
-		if req.Phone != "" {
-			if err := tx.Model(&User{}).Where("tenant_id = ? AND phone = ?", tenantID, req.Phone).Count(&count).Error; err != nil {
-				return err
-			}
-			if count > 0 {
-				return accountError(ErrIdentifierExists, "手机号已存在")
-			}
+		roles, err := s.m.auth.loadRoleCodes(ctx, tx, user.ID)
+		if err != nil {
+			return err
 		}
+		created = user.toInfo(roles, []string{})
+		return nil
+	})
+	if err != nil {
+		s.m.audit(ctx, tenantID, "", "admin_create_user", "failed", err.Error(), "", "")
+		return nil, err
+	}
+	s.m.audit(ctx, tenantID, created.ID, "admin_create_user", "success", "", "", "")
+	return created, nil
+}
 
+// createUserTx 在事务内检查标识唯一性，创建用户、密码凭证（Password 非空时）并分配默认角色。
+// 调用方负责校验参数；createdBy 写入 EventUserRegistered 事件。
+func (s *AdminService) createUserTx(ctx context.Context, tx *gorm.DB, tenantID string, req CreateUserWithPasswordRequest, createdBy string) (*User, error) {
+	if err := s.m.tenantSvc.admitUser(tx, tenantID); err != nil {
+		return nil, err
+	}
+	if err := checkIdentifiersAvailable(tx, tenantID, req.Username, req.Email, req.Phone); err != nil {
+		return nil, err
+	}
+	user := User{
+		ID:             newID(),
+		TenantID:       tenantID,
+		Username:       req.Username,
+		Email:          nullableString(req.Email),
+		Phone:          nullableString(req.Phone),
+		Nickname:       req.Nickname,
+		AvatarURL:      req.AvatarURL,
+		Status:         req.Status,
+		AuthVersion:    1,
+		RolesVersion:   1,
+		ProfileVersion: 1,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	if req.Password != "" {
 		passHash, err := hashPassword(req.Password, s.m.cfg.Password)
 		if err != nil {
-			return fmt.Errorf("hash password: %w", err)
-		}
-		user := User{
-			ID:             newID(),
-			TenantID:       tenantID,
-			Username:       req.Username,
-			Email:          nullableString(req.Email),
-			Phone:          nullableString(req.Phone),
-			Nickname:       req.Nickname,
-			AvatarURL:      req.AvatarURL,
-			Status:         req.Status,
-			AuthVersion:    1,
-			RolesVersion:   1,
-			ProfileVersion: 1,
-		}
-		if err := tx.Create(&user).Error; err != nil {
-			return fmt.Errorf("create user: %w", err)
+			return nil, fmt.Errorf("hash password: %w", err)
 		}
 		cred := Credential{
 			ID:         newID(),
@@ -373,33 +383,50 @@ func (s *AdminService) CreateUserWithPassword(ctx context.Context, req CreateUse
 			Enabled:    true,
 		}
 		if err := tx.Create(&cred).Error; err != nil {
-			return fmt.Errorf("create credential: %w", err)
+			return nil, fmt.Errorf("create credential: %w", err)
 		}
-		if err := s.m.auth.assignDefaultRole(ctx, tx, &user); err != nil {
+	}
+	if err := s.m.auth.assignDefaultRole(ctx, tx, &user); err != nil {
+		return nil, err
+	}
+	_ = emitOutbox(tx, EventUserRegistered, user.ID, map[string]any{
+		"user_id":       user.ID,
+		"tenant_id":     tenantID,
+		"username":      user.Username,
+		"email":         user.Email,
+		"phone":         user.Phone,
+		"created_by":    createdBy,
+		"registered_at": time.Now(),
+	})
+	return &user, nil
+}
+
+// checkIdentifiersAvailable 检查用户名、邮箱、手机号在租户内未被占用。
+func checkIdentifiersAvailable(tx *gorm.DB, tenantID, username, email, phone string) error {
+	var count int64
+	if err := tx.Model(&User{}).Where("tenant_id = ? AND username = ?", tenantID, username).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return accountError(ErrIdentifierExists, "用户名已存在")
+	}
+	if email != "" {
+		if err := tx.Model(&User{}).Where("tenant_id = ? AND email = ?", tenantID, email).Count(&count).Error; err != nil {
 			return err
 		}
-		roles, err := s.m.auth.loadRoleCodes(ctx, tx, user.ID)
-		if err != nil {
+		if count > 0 {
+			return accountError(ErrIdentifierExists, "邮箱已存在")
+		}
+	}
+	if phone != "" {
+		if err := tx.Model(&User{}).Where("tenant_id = ? AND phone = ?", tenantID, phone).Count(&count).Error; err != nil {
 			return err
 		}
-		created = user.toInfo(roles, []string{})
-		_ = emitOutbox(tx, EventUserRegistered, user.ID, map[string]any{
-			"user_id":       user.ID,
-			"tenant_id":     tenantID,
-			"username":      user.Username,
-			"email":         user.Email,
-			"phone":         user.Phone,
-			"created_by":    "admin",
-			"registered_at": time.Now(),
-		})
-		return nil
-	})
-	if err != nil {
-		s.m.audit(ctx, tenantID, "", "admin_create_user", "failed", err.Error(), "", "")
-		return nil, err
+		if count > 0 {
+			return accountError(ErrIdentifierExists, "手机号已存在")
+		}
 	}
-	s.m.audit(ctx, tenantID, created.ID, "admin_create_user", "success", "", "", "")
-	return created, nil
+	return nil
 }
 
 // UpdateUserStatus 更新用户状态（禁用/启用/锁定/解锁）。
@@ -13,6 +13,7 @@ import (
 type AuditQueryRequest struct {
 	TenantID string
 	UserID   string
+	ActorID  string // 代操作期间的实际操作者
 	Event    string
 	Status   string
 	StartAt  *time.Time
@@ -54,6 +55,9 @@ func (s *AuditService) Query(ctx context.Context, req AuditQueryRequest) (*Audit
 	if req.UserID != "" {
 		q = q.Where("user_id = ?", req.UserID)
 	}
+	if req.ActorID != "" {
+		q = q.Where("actor_id = ?", req.ActorID)
+	}
 	if req.Event != "" {
 		q = q.Where("event = ?", req.Event)
 	}
@@ -130,6 +134,7 @@ func (s *AuditService) ArchiveOldLogs(ctx context.Context, before time.Time, bat
 				ID:         log.ID,
 				TenantID:   log.TenantID,
 				UserID:     log.UserID,
+				ActorID:    log.ActorID,
 				Event:      log.Event,
 				IP:         log.IP,
 				UserAgent:  log.UserAgent,
@@ -167,6 +172,9 @@ func (s *AuditService) QueryArchived(ctx context.Context, req AuditQueryRequest)
 	if req.UserID != "" {
 		q = q.Where("user_id = ?", req.UserID)
 	}
+	if req.ActorID != "" {
+		q = q.Where("actor_id = ?", req.ActorID)
+	}
 	if req.Event != "" {
 		q = q.Where("event = ?", req.Event)
 	}
@@ -201,6 +209,7 @@ func (s *AuditService) QueryArchived(ctx context.Context, req AuditQueryRequest)
 			ID:        item.ID,
 			TenantID:  item.TenantID,
 			UserID:    item.UserID,
+			ActorID:   item.ActorID,
 			Event:     item.Event,
 			IP:        item.IP,
 			UserAgent: item.UserAgent,
@@ -225,6 +234,7 @@ func (s *AuditService) RestoreFromArchive(ctx context.Context, id string) error
 		ID:        archived.ID,
 		TenantID:  archived.TenantID,
 		UserID:    archived.UserID,
+		ActorID:   archived.ActorID,
 		Event:     archived.Event,
 		IP:        archived.IP,
 		UserAgent: archived.UserAgent,
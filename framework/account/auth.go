@@ -48,6 +48,8 @@ type LoginRequest struct {
 	DeviceID       string
 	IP             string
 	UserAgent      string
+	// ClientHints 客户端提示头（Sec-CH-UA 等），用于没有 DeviceID 时生成设备指纹。
+	ClientHints map[string]string
 }
 
 // RefreshRequest 令牌刷新请求参数。
@@ -79,6 +81,10 @@ type AuthResult struct {
 
 	// PhoneBindingRequired is true when policy requires a verified phone before token issuance.
 	PhoneBindingRequired bool `json:"phone_binding_required"`
+
+	// MFAEnrollmentRequired is true when a tenant MFA policy requires this user to enroll TOTP
+	// and the grace period has not ended yet; the client should guide the user to set up MFA.
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
 }
 
 // Principal 表示经过身份验证的用户主体，包含用户、租户、角色和会话信息。
@@ -93,6 +99,41 @@ type Principal struct {
 	RolesVersion  int64    `json:"roles_version"`
 	PhoneVerified bool     `json:"phone_verified"`
 	Roles         []string `json:"roles"`
+	// Impersonated 为 true 表示这是管理员代操作会话：ActorID / ActorUsername 为实际操作者，
+	// Scopes 限定可用的权限码，前端应显示代操作横幅。
+	Impersonated  bool   `json:"impersonated,omitempty"`
+	ActorID       string `json:"actor_id,omitempty"`
+	ActorUsername string `json:"actor_username,omitempty"`
+	// ACR / AMR / AuthTime 来自令牌声明：登录时的认证保证级别、认证方式与认证时间（Unix 秒）。
+	// 判断敏感操作请使用 RequireStepUp，它按会话实时状态校验。
+	ACR      string   `json:"acr,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+	AuthTime int64    `json:"auth_time,omitempty"`
+	// ClientID 非空表示令牌由该 IdP 客户端通过令牌交换（RFC 8693）取得；
+	// Delegation 为委托链上的操作者，当前调用方在前，最初的委托方在后；
+	// GrantedScopes 为交换时授予的 scope，可用权限取用户权限与它的交集。
+	ClientID      string   `json:"client_id,omitempty"`
+	Delegation    []string `json:"delegation,omitempty"`
+	GrantedScopes []string `json:"granted_scopes,omitempty"`
+}
+
+// CacheIdentity 实现 server.CacheIdentifier：响应缓存按租户 + 用户区分，
+// 代操作、令牌交换与 API Token 的权限范围不同，额外带上操作者会话、客户端与令牌 ID。
+func (p *Principal) CacheIdentity() string {
+	if p == nil || p.UserID == "" {
+		return ""
+	}
+	id := p.TenantID + "/" + p.UserID
+	if p.Impersonated {
+		id += "|actor=" + p.ActorID + "/" + p.SessionID
+	}
+	if p.ClientID != "" {
+		id += "|client=" + p.ClientID
+	}
+	if p.APITokenID != "" {
+		id += "|token=" + p.APITokenID
+	}
+	return id
 }
 
 type accessClaims struct {
@@ -104,9 +145,38 @@ type accessClaims struct {
 	RolesVersion  int64    `json:"roles_version"`
 	PhoneVerified bool     `json:"phone_verified"`
 	Roles         []string `json:"roles,omitempty"`
+	// 以下仅代操作令牌携带：imp 为前端横幅标记，act 为实际操作者（RFC 8693），scopes 为可用权限码。
+	Impersonated bool        `json:"imp,omitempty"`
+	Actor        *actorClaim `json:"act,omitempty"`
+	Scopes       []string    `json:"scopes,omitempty"`
+	// 认证上下文（OIDC Core §2）：保证级别、认证方式（RFC 8176）与最近一次认证时间。
+	ACR      string           `json:"acr,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// 令牌交换签发的令牌携带：client_id 为发起交换的客户端（RFC 8693 §4.3），scope 为授予的范围。
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// actorClaim 代操作令牌中的实际操作者。令牌交换形成委托链时，
+// 更早的操作者嵌套在 Actor 中（RFC 8693 §4.1）。
+type actorClaim struct {
+	Subject  string      `json:"sub"`
+	Username string      `json:"username,omitempty"`
+	ClientID string      `json:"client_id,omitempty"`
+	Actor    *actorClaim `json:"act,omitempty"`
+}
+
+// chain 返回委托链上全部操作者，由外到内。
+func (a *actorClaim) chain() []*actorClaim {
+	var out []*actorClaim
+	for ; a != nil; a = a.Actor {
+		out = append(out, a)
+	}
+	return out
+}
+
 // BindPhoneRequest 通过已验证的短信验证码为账号绑定手机号，并在成功后签发令牌。
 type BindPhoneRequest struct {
 	TenantID       string
@@ -132,12 +202,15 @@ func (s *AuthService) Register(ctx context.Context, req RegisterRequest) (*AuthR
 	if err := validateIdentifier(req); err != nil {
 		return nil, accountError(ErrInvalidArgument, err.Error())
 	}
-	if err := validatePassword(req.Password, s.m.cfg.Password); err != nil {
+	if err := validatePassword(req.Password, s.m.tenantSvc.passwordConfig(ctx, tenantID)); err != nil {
 		return nil, err
 	}
 
 	var result *AuthResult
 	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.m.tenantSvc.admitUser(tx, tenantID); err != nil {
+			return err
+		}
 		var count int64
 		if err := tx.Model(&User{}).Where("tenant_id = ? AND username = ?", tenantID, req.Username).Count(&count).Error; err != nil {
 			return err
@@ -258,7 +331,7 @@ func (s *AuthService) Register(ctx context.Context, req RegisterRequest) (*AuthR
 		if err != nil {
 			return err
 		}
-		authResult, err := s.issueTokens(ctx, tx, &user, roles, req.DeviceID, req.IP, req.UserAgent, "", "", "")
+		authResult, err := s.issueTokens(ctx, tx, &user, roles, []string{AMRPassword}, req.DeviceID, req.IP, req.UserAgent, "", "", "")
 		if err != nil {
 			return err
 		}
@@ -293,9 +366,13 @@ func (s *AuthService) Login(ctx context.Context, req LoginRequest) (*AuthResult,
 	if identifier == "" || req.Password == "" {
 		return nil, accountError(ErrInvalidArgument, "登录标识和密码不能为空")
 	}
+	if err := s.m.tenantSvc.checkLogin(ctx, tenantID, LoginMethodPassword); err != nil {
+		return nil, err
+	}
 
 	var user User
 	var result *AuthResult
+	var riskCtx RiskContext
 	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		query := tx.Where("tenant_id = ?", tenantID)
 		switch req.IdentifierType {
@@ -334,7 +411,8 @@ func (s *AuthService) Login(ctx context.Context, req LoginRequest) (*AuthResult,
 		}
 
 		// Risk assessment before credential check
-		riskResult, err := s.m.risk.Assess(ctx, tenantID, user.ID, req.IP)
+		riskCtx = RiskContext{TenantID: tenantID, UserID: user.ID, IP: req.IP, UserAgent: req.UserAgent, DeviceID: req.DeviceID, ClientHints: req.ClientHints}
+		riskResult, err := s.m.risk.AssessLogin(ctx, &riskCtx)
 		if err != nil {
 			return err
 		}
@@ -434,15 +512,17 @@ func (s *AuthService) Login(ctx context.Context, req LoginRequest) (*AuthResult,
 		if err != nil {
 			return err
 		}
-		// Enforce admin MFA if configured
-		if s.m.cfg.AccountPolicy.RequireAdminMFA && userHasAdminRole(roles) && !s.m.mfa.HasTOTP(ctx, tenantID, user.ID) {
+		// Enforce admin MFA / tenant MFA policy if configured
+		mfaRequired, mfaEnforced := s.m.mfa.enrollmentState(ctx, tenantID, user.ID, roles)
+		if mfaEnforced {
 			return accountError(ErrPermissionDenied, "需要先配置 MFA 后才能登录")
 		}
 
-		result, err = s.issueTokens(ctx, tx, &user, roles, req.DeviceID, req.IP, req.UserAgent, "", "", "")
+		result, err = s.issueTokens(ctx, tx, &user, roles, []string{AMRPassword}, req.DeviceID, req.IP, req.UserAgent, "", "", "")
 		if err != nil {
 			return err
 		}
+		result.MFAEnrollmentRequired = mfaRequired
 		now := time.Now()
 		if err := emitOutbox(tx, EventUserLoggedIn, user.ID, map[string]any{
 			"user_id":      user.ID,
@@ -477,6 +557,7 @@ func (s *AuthService) Login(ctx context.Context, req LoginRequest) (*AuthResult,
 	// Don't log login success for MFA-required — the full login completes in CompleteMFA
 	if !result.MFARequired && !result.PhoneBindingRequired {
 		s.m.audit(ctx, tenantID, user.ID, "login", "success", "", req.IP, req.UserAgent)
+		s.m.risk.RecordLogin(ctx, riskCtx)
 	}
 	if m := s.m.metrics; m != nil {
 		m.LoginTotal.Add(ctx, 1, metric.WithAttributes(
@@ -505,6 +586,9 @@ func (s *AuthService) BindPhoneAndLogin(ctx context.Context, req BindPhoneReques
 	if identifier == "" || req.Password == "" || phone == "" || req.ChallengeID == "" || req.Code == "" {
 		return nil, accountError(ErrInvalidArgument, "登录标识、密码、手机号和验证码不能为空")
 	}
+	if err := s.m.tenantSvc.checkLogin(ctx, tenantID, LoginMethodPassword); err != nil {
+		return nil, err
+	}
 
 	var result *AuthResult
 	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
@@ -586,15 +670,18 @@ func (s *AuthService) BindPhoneAndLogin(ctx context.Context, req BindPhoneReques
 		if err != nil {
 			return err
 		}
-		// Enforce admin MFA if configured
-		if s.m.cfg.AccountPolicy.RequireAdminMFA && userHasAdminRole(roles) && !s.m.mfa.HasTOTP(ctx, tenantID, user.ID) {
+		// Enforce admin MFA / tenant MFA policy if configured
+		mfaRequired, mfaEnforced := s.m.mfa.enrollmentState(ctx, tenantID, user.ID, roles)
+		if mfaEnforced {
 			return accountError(ErrPermissionDenied, "需要先配置 MFA 后才能登录")
 		}
 
-		result, err = s.issueTokens(ctx, tx, &user, roles, req.DeviceID, req.IP, req.UserAgent, "", "", "")
+		// 这里的短信码只证明手机号归属，不作为第二认证因素计入 amr。
+		result, err = s.issueTokens(ctx, tx, &user, roles, []string{AMRPassword}, req.DeviceID, req.IP, req.UserAgent, "", "", "")
 		if err != nil {
 			return err
 		}
+		result.MFAEnrollmentRequired = mfaRequired
 		return tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
 			"last_login_at": now,
 			"last_login_ip": req.IP,
@@ -639,6 +726,9 @@ func (s *AuthService) LoginWithVerificationCode(ctx context.Context, req LoginWi
 	if identifier == "" || req.ChallengeID == "" || req.Code == "" {
 		return nil, accountError(ErrInvalidArgument, "登录标识、验证码不能为空")
 	}
+	if err := s.m.tenantSvc.checkLogin(ctx, tenantID, LoginMethodCode); err != nil {
+		return nil, err
+	}
 
 	// Determine the target (email or phone) and identifier type
 	var channel, target string
@@ -714,14 +804,16 @@ func (s *AuthService) LoginWithVerificationCode(ctx context.Context, req LoginWi
 		if err != nil {
 			return err
 		}
-		// Enforce admin MFA if configured
-		if s.m.cfg.AccountPolicy.RequireAdminMFA && userHasAdminRole(roles) && !s.m.mfa.HasTOTP(ctx, tenantID, user.ID) {
+		// Enforce admin MFA / tenant MFA policy if configured
+		mfaRequired, mfaEnforced := s.m.mfa.enrollmentState(ctx, tenantID, user.ID, roles)
+		if mfaEnforced {
 			return accountError(ErrPermissionDenied, "需要先配置 MFA 后才能登录")
 		}
-		result, err = s.m.auth.issueTokens(ctx, tx, &user, roles, req.DeviceID, req.IP, req.UserAgent, "", "", "")
+		result, err = s.m.auth.issueTokens(ctx, tx, &user, roles, []string{amrForMethod(channel)}, req.DeviceID, req.IP, req.UserAgent, "", "", "")
 		if err != nil {
 			return err
 		}
+		result.MFAEnrollmentRequired = mfaRequired
 		now := time.Now()
 		return tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
 			"last_login_at": now,
@@ -803,7 +895,7 @@ func (s *AuthService) Refresh(ctx context.Context, req RefreshRequest) (*AuthRes
 		if err != nil {
 			return err
 		}
-		result, err = s.issueTokens(ctx, tx, &user, roles, req.DeviceID, req.IP, req.UserAgent, session.ID, session.FamilyID, oldToken.TokenHash)
+		result, err = s.issueTokens(ctx, tx, &user, roles, nil, req.DeviceID, req.IP, req.UserAgent, session.ID, session.FamilyID, oldToken.TokenHash)
 		return err
 	})
 	if err != nil {
@@ -905,6 +997,16 @@ func (s *AuthService) Validate(ctx context.Context, accessToken string) (*Princi
 			return nil, accountError(ErrInvalidToken, "租户不可用")
 		}
 	}
+	if err := s.m.tenantSvc.checkActive(ctx, claims.TenantID); err != nil {
+		s.m.audit(ctx, claims.TenantID, claims.UserID, "validate_token", "failed", "tenant inactive: "+err.Error(), "", "")
+		if m := s.m.metrics; m != nil {
+			m.TokenValidationTotal.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("status", "failed"),
+				attribute.String("reason", "tenant_inactive"),
+			))
+		}
+		return nil, accountError(ErrInvalidToken, "租户不可用")
+	}
 	if s.m.cfg.AccountPolicy.RequireVerifiedPhone && !claims.PhoneVerified {
 		s.m.audit(ctx, claims.TenantID, claims.UserID, "validate_token", "failed", "phone binding required", "", "")
 		return nil, accountError(ErrPhoneBindingRequired, "需要先绑定并验证手机号")
@@ -1002,6 +1104,27 @@ func (s *AuthService) Validate(ctx context.Context, accessToken string) (*Princi
 		PhoneVerified: claims.PhoneVerified,
 		Roles:         claims.Roles,
 	}
+	actors := claims.Actor.chain()
+	if claims.Impersonated && len(actors) > 0 {
+		// 代操作者总是委托链最内层：之后的令牌交换只会在外层追加操作者。
+		origin := actors[len(actors)-1]
+		principal.Impersonated = true
+		principal.ActorID = origin.Subject
+		principal.ActorUsername = origin.Username
+		principal.Scopes = claims.Scopes
+		actors = actors[:len(actors)-1]
+	}
+	if claims.ClientID != "" {
+		principal.ClientID = claims.ClientID
+		principal.GrantedScopes = strings.Fields(claims.Scope)
+		for _, a := range actors {
+			principal.Delegation = append(principal.Delegation, a.Subject)
+		}
+	}
+	principal.ACR, principal.AMR = claims.ACR, claims.AMR
+	if claims.AuthTime != nil {
+		principal.AuthTime = claims.AuthTime.Unix()
+	}
 	_ = s.cachePrincipal(ctx, claims, principal)
 	if m := s.m.metrics; m != nil {
 		m.TokenValidationTotal.Add(ctx, 1, metric.WithAttributes(
@@ -1040,16 +1163,21 @@ func (s *AuthService) loadRoleCodes(ctx context.Context, tx *gorm.DB, userID str
 }
 
 // issueTokens 为用户创建会话、访问令牌和刷新令牌。
-// 当 sessionID 和 familyID 为空时，将生成新的。
-func (s *AuthService) issueTokens(ctx context.Context, tx *gorm.DB, user *User, roles []string, deviceID, ip, userAgent, sessionID, familyID, previousHash string) (*AuthResult, error) {
+// 当 sessionID 和 familyID 为空时，将生成新的。amr 为本次登录使用的认证方式，
+// 写入新会话并决定 acr；续签（previousHash 非空）时忽略，沿用会话已记录的认证上下文。
+func (s *AuthService) issueTokens(ctx context.Context, tx *gorm.DB, user *User, roles []string, amr []string, deviceID, ip, userAgent, sessionID, familyID, previousHash string) (*AuthResult, error) {
 	if sessionID == "" {
 		sessionID = newID()
 	}
 	if familyID == "" {
 		familyID = newID()
 	}
-	sessionExpiresAt := time.Now().Add(s.m.cfg.RefreshTokenTTL)
+	now := time.Now()
+	accessTTL, refreshTTL := s.m.tenantSvc.tokenTTLs(ctx, user.TenantID)
+	sessionExpiresAt := now.Add(refreshTTL)
+	var assurance authAssurance
 	if previousHash == "" {
+		assurance = newAuthAssurance(amr, now)
 		session := Session{
 			ID:            sessionID,
 			TenantID:      user.TenantID,
@@ -1060,17 +1188,30 @@ func (s *AuthService) issueTokens(ctx context.Context, tx *gorm.DB, user *User,
 			UserAgentHash: hashUserAgent(userAgent),
 			Status:        SessionActive,
 			ExpiresAt:     sessionExpiresAt,
+			ACR:           assurance.ACR,
+			AMR:           strings.Join(assurance.AMR, ","),
+		}
+		// 登录时已完成第二因素，视同刚完成一次 step-up
+		if method := assurance.mfaMethod(); method != "" {
+			session.MFASatisfiedAt = &now
+			session.MFAMethod = method
 		}
 		if err := tx.Create(&session).Error; err != nil {
 			return nil, fmt.Errorf("create session: %w", err)
 		}
 	} else {
+		var session Session
+		if err := tx.Select("id", "acr", "amr", "mfa_satisfied_at", "created_at").
+			Where("id = ?", sessionID).First(&session).Error; err != nil {
+			return nil, fmt.Errorf("load session: %w", err)
+		}
+		assurance = session.assurance()
 		if err := tx.Model(&Session{}).Where("id = ? AND status = ?", sessionID, SessionActive).
 			Update("expires_at", sessionExpiresAt).Error; err != nil {
 			return nil, fmt.Errorf("extend session: %w", err)
 		}
 	}
-	accessToken, accessExpiresAt, err := s.signAccessToken(user, roles, sessionID)
+	accessToken, accessExpiresAt, err := s.signSessionAccessToken(user, roles, sessionID, assurance, accessTTL)
 	if err != nil {
 		return nil, err
 	}
@@ -1105,8 +1246,14 @@ func (s *AuthService) issueTokens(ctx context.Context, tx *gorm.DB, user *User,
 
 // signAccessToken 创建带有用户声明和会话 ID 的签名 JWT 访问令牌。
 func (s *AuthService) signAccessToken(user *User, roles []string, sessionID string) (string, time.Time, error) {
+	return s.signSessionAccessToken(user, roles, sessionID, authAssurance{}, s.m.cfg.AccessTokenTTL)
+}
+
+// signSessionAccessToken 与 signAccessToken 相同，额外写入会话的 acr / amr / auth_time 声明，
+// 有效期为 ttl（已应用租户覆盖）。
+func (s *AuthService) signSessionAccessToken(user *User, roles []string, sessionID string, assurance authAssurance, ttl time.Duration) (string, time.Time, error) {
 	now := time.Now()
-	expiresAt := now.Add(s.m.cfg.AccessTokenTTL)
+	expiresAt := now.Add(ttl)
 	claims := accessClaims{
 		UserID:        user.ID,
 		TenantID:      user.TenantID,
@@ -1116,6 +1263,8 @@ func (s *AuthService) signAccessToken(user *User, roles []string, sessionID stri
 		RolesVersion:  user.RolesVersion,
 		PhoneVerified: user.PhoneVerifiedAt != nil,
 		Roles:         roles,
+		ACR:           assurance.ACR,
+		AMR:           assurance.AMR,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        newID(),
 			Subject:   user.ID,
@@ -1125,35 +1274,54 @@ func (s *AuthService) signAccessToken(user *User, roles []string, sessionID stri
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	}
+	if !assurance.AuthTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(assurance.AuthTime)
+	}
+	signed, err := s.signClaims(claims)
+	return signed, expiresAt, err
+}
+
+// signClaims 签名访问令牌声明。
+func (s *AuthService) signClaims(claims accessClaims) (string, error) {
+	return s.signJWT(claims)
+}
+
+// signJWT 使用 KeySet（已配置时）或 HMAC 密钥签名任意 JWT 声明。
+func (s *AuthService) signJWT(claims jwt.Claims) (string, error) {
 	// Use KeySet for signing if configured (supports asymmetric keys and rotation)
 	if keySet := s.m.cfg.JWT.KeySet; keySet != nil {
-		signed, err := keySet.Sign(claims)
-		return signed, expiresAt, err
+		return keySet.Sign(claims)
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	token.Header["kid"] = "hmac-default"
-	signed, err := token.SignedString([]byte(s.m.cfg.JWT.SecretKey))
-	return signed, expiresAt, err
+	return token.SignedString([]byte(s.m.cfg.JWT.SecretKey))
 }
 
 // parseAccessToken 解析并验证 JWT 访问令牌，验证签名。
 // 如果配置了 KeySet 则使用非对称密钥验证，否则回退到 HMAC。
 func (s *AuthService) parseAccessToken(tokenString string) (*accessClaims, error) {
 	claims := &accessClaims{}
+	if err := s.parseJWT(tokenString, claims, s.m.cfg.JWT.Audience...); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
 
+// parseJWT 验证签名、签发者与受众，并把声明解析到 claims。
+func (s *AuthService) parseJWT(tokenString string, claims jwt.Claims, audience ...string) error {
 	// Use KeySet for verification if configured (supports asymmetric keys and rotation)
 	if keySet := s.m.cfg.JWT.KeySet; keySet != nil {
 		token, err := keySet.ParseWithKeySet(tokenString, claims,
 			jwt.WithIssuer(s.m.cfg.JWT.Issuer),
-			jwt.WithAudience(s.m.cfg.JWT.Audience...),
+			jwt.WithAudience(audience...),
 		)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if !token.Valid {
-			return nil, errors.New("invalid token")
+			return errors.New("invalid token")
 		}
-		return claims, nil
+		return nil
 	}
 
 	// Fallback to HMAC verification
@@ -1162,14 +1330,14 @@ func (s *AuthService) parseAccessToken(tokenString string) (*accessClaims, error
 			return nil, errors.New("invalid signing method")
 		}
 		return []byte(s.m.cfg.JWT.SecretKey), nil
-	}, jwt.WithIssuer(s.m.cfg.JWT.Issuer), jwt.WithAudience(s.m.cfg.JWT.Audience...))
+	}, jwt.WithIssuer(s.m.cfg.JWT.Issuer), jwt.WithAudience(audience...))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return errors.New("invalid token")
 	}
-	return claims, nil
+	return nil
 }
 
 // principalCacheKey 返回从访问声明派生的主体的缓存键。
@@ -1184,7 +1352,7 @@ func (s *AuthService) principalSessionKey(sessionID string) string {
 
 // getCachedPrincipal 返回给定声明的缓存主体（如果有）。
 func (s *AuthService) getCachedPrincipal(ctx context.Context, claims *accessClaims) (*Principal, bool, error) {
-	if s.m.cache == nil {
+	if s.m.cache == nil || claims.ClientID != "" {
 		return nil, false, nil
 	}
 	raw, ok, err := s.m.cache.Get(ctx, s.principalCacheKey(claims))
@@ -1199,8 +1367,9 @@ func (s *AuthService) getCachedPrincipal(ctx context.Context, claims *accessClai
 }
 
 // cachePrincipal 将主体存储在缓存中，TTL 受 PrincipalCacheMaxTTL 限制。
+// 令牌交换签发的令牌与原令牌共享 sid 却带有不同的委托链，不进入缓存以免互相覆盖。
 func (s *AuthService) cachePrincipal(ctx context.Context, claims *accessClaims, principal *Principal) error {
-	if s.m.cache == nil || claims.ExpiresAt == nil {
+	if s.m.cache == nil || claims.ExpiresAt == nil || claims.ClientID != "" {
 		return nil
 	}
 	ttl := time.Until(claims.ExpiresAt.Time)
@@ -1255,7 +1424,8 @@ func (s *AuthService) invalidateUserPrincipalCaches(ctx context.Context, userID
 // RequireStepUp 检查当前会话最近是否完成过 step-up MFA。
 //
 // 语义：
-//  1. 用户**未启用 TOTP** → 直接放行（业务方可结合自身策略额外要求其它二次因素）。
+//  1. 用户**未启用 TOTP** → 直接放行（业务方可结合自身策略额外要求其它二次因素）；
+//     租户 MFA 策略要求该用户启用 MFA 时拒绝。
 //  2. principal 缺少 SessionID → 拒绝；通常意味着调用方未携带合法 access token，
 //     或属于内部服务直调，敏感操作必须显式带上 sid 否则保守兜底。
 //  3. 找不到对应 session 行 / session 已被吊销/过期 → 拒绝。
@@ -1264,63 +1434,71 @@ func (s *AuthService) invalidateUserPrincipalCaches(ctx context.Context, userID
 // 该检查严格按 sid 维度（每个浏览器/设备独立）：
 // 用户在 Safari 上 step-up 不会让 Chrome 上的会话获得敏感操作权限。
 // 不再依赖外部缓存——即使 Redis 不可用，敏感操作也不会被静默放行。
+// 需要自定义时效或限定验证方式时使用 EvaluateStepUp。
 func (s *AuthService) RequireStepUp(ctx context.Context, principal *Principal) error {
-	if principal == nil {
-		return accountError(ErrInvalidArgument, "principal 不能为空")
-	}
-	// 用户未启用 TOTP，跳过 step-up（业务方如有额外需求可显式拦截）。
-	if !s.m.mfa.HasTOTP(ctx, principal.TenantID, principal.UserID) {
-		return nil
-	}
-	if principal.SessionID == "" {
-		s.recordStepUpDenied(ctx, "no_sid")
-		return accountError(ErrPermissionDenied, "需要二次验证才能执行此操作")
-	}
-
-	var sess Session
-	if err := s.m.db.WithContext(ctx).
-		Select("id", "status", "mfa_satisfied_at").
-		Where("id = ? AND tenant_id = ? AND user_id = ?",
-			principal.SessionID, principal.TenantID, principal.UserID).
-		First(&sess).Error; err != nil {
-		s.recordStepUpDenied(ctx, "session_not_found")
-		return accountError(ErrPermissionDenied, "需要二次验证才能执行此操作")
-	}
-	if sess.Status != SessionActive {
-		s.recordStepUpDenied(ctx, "session_inactive")
-		return accountError(ErrInvalidToken, "会话已失效")
-	}
-
-	window := s.m.cfg.StepUpWindow
-	if window <= 0 {
-		window = 5 * time.Minute
+	challenge, err := s.EvaluateStepUp(ctx, principal, StepUpRequirement{})
+	if err != nil {
+		return err
 	}
-	if sess.MFASatisfiedAt == nil || time.Since(*sess.MFASatisfiedAt) > window {
-		s.recordStepUpDenied(ctx, "expired_or_missing")
+	if challenge != nil {
 		return accountError(ErrPermissionDenied, "需要二次验证才能执行此操作")
 	}
 	return nil
 }
 
 // RequestStepUp 创建 step-up MFA 挑战，返回挑战 ID。
-// 客户端应使用此 ID 引导用户完成 TOTP 验证。
+// 客户端应使用此 ID 引导用户完成 TOTP 验证；其他方式使用 StartStepUp。
 func (s *AuthService) RequestStepUp(ctx context.Context, principal *Principal) (string, error) {
+	start, err := s.StartStepUp(ctx, principal, AMROTP, "")
+	if err != nil {
+		return "", err
+	}
+	return start.ChallengeID, nil
+}
+
+// StepUpStart 发起 step-up 的结果：otp / sms / email 返回挑战 ID，由 CompleteStepUp 提交验证码；
+// hwk 返回 WebAuthn 认证选项，由 CompletePasskeyStepUp 提交断言。
+type StepUpStart struct {
+	Method      string                 `json:"method"`
+	ChallengeID string                 `json:"challenge_id,omitempty"`
+	Passkey     *PasskeyAuthentication `json:"passkey,omitempty"`
+}
+
+// StartStepUp 按 method（amr 值）发起 step-up：otp 使用已启用的 TOTP，sms / email 向已验证的手机号 / 邮箱
+// 发送验证码，hwk 生成通行密钥认证挑战。ip 用于验证码发送的频率限制。
+func (s *AuthService) StartStepUp(ctx context.Context, principal *Principal, method, ip string) (*StepUpStart, error) {
 	if principal == nil {
-		return "", accountError(ErrInvalidArgument, "principal 不能为空")
+		return nil, accountError(ErrInvalidArgument, "principal 不能为空")
+	}
+	if err := validateStepUpMethods([]string{method}); err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
 	}
-	if !s.m.mfa.HasTOTP(ctx, principal.TenantID, principal.UserID) {
-		return "", accountError(ErrInvalidArgument, "没有配置 MFA，无需二次验证")
+	if !s.hasStepUpFactor(ctx, principal, []string{method}) {
+		return nil, accountError(ErrInvalidArgument, "没有可用的二次验证方式: "+method)
+	}
+	switch method {
+	case AMRPasskey:
+		auth, err := s.m.passkeySvc.StartPasskeyAuthentication(ctx, principal.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return &StepUpStart{Method: method, Passkey: auth}, nil
+	case AMRSMS, AMREmail:
+		challenge, err := s.m.mfa.RequestMFACode(ctx, principal.TenantID, principal.UserID, method, ip)
+		if err != nil {
+			return nil, err
+		}
+		return &StepUpStart{Method: method, ChallengeID: challenge.ID}, nil
 	}
-	// Read current auth version
 	var user User
 	if err := s.m.db.WithContext(ctx).Where("id = ?", principal.UserID).First(&user).Error; err != nil {
-		return "", err
+		return nil, err
 	}
 	challenge, err := s.m.mfa.CreateMFAChallenge(ctx, principal.TenantID, principal.UserID, user.AuthVersion)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return challenge.ID, nil
+	return &StepUpStart{Method: method, ChallengeID: challenge.ID}, nil
 }
 
 // CompleteStepUp 验证 step-up MFA 挑战，并把成功标记写入「当前 session」（按 sid）。
@@ -1329,9 +1507,11 @@ func (s *AuthService) RequestStepUp(ctx context.Context, principal *Principal) (
 //   - 标记仅对发起 step-up 的那一个 session 有效——同一用户在其他浏览器/设备上的
 //     会话不会自动获得敏感操作权限。
 //   - 标记的有效期由 cfg.StepUpWindow 控制（默认 5 分钟）。
+//   - 按挑战的方式校验 TOTP 或短信 / 邮箱验证码，会话 acr 升级为 aal2、amr 追加对应方式；
+//     刷新令牌后新的 access token 带上升级后的声明。
 //   - 失败时同样递增 challenge 的 attempts 计数，使 MaxAttempts=5 限速生效，
 //     防止攻击者持有 access_token 后在 5 分钟挑战窗口内对 6 位数暴力尝试。
-//   - TOTP 验证通过 + session 标记写入 在同一事务中完成，避免跨事务半成功。
+//   - 验证通过 + session 标记写入 在同一事务中完成，避免跨事务半成功。
 func (s *AuthService) CompleteStepUp(ctx context.Context, principal *Principal, challengeID, code string) error {
 	if principal == nil {
 		return accountError(ErrInvalidArgument, "principal 不能为空")
@@ -1347,28 +1527,50 @@ func (s *AuthService) CompleteStepUp(ctx context.Context, principal *Principal,
 		return accountError(ErrPermissionDenied, "MFA 挑战不属于当前用户")
 	}
 
+	var method string
 	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		ok, verr := s.m.mfa.verifyTOTPTx(ctx, tx, tenantID, userID, code)
-		if verr != nil {
-			return accountError(ErrInternal, "MFA 验证失败")
-		}
-		if !ok {
-			// TOTP 错误：在同一事务内递增 challenge.attempts，达到 MaxAttempts 后挑战自动作废。
-			_ = tx.Model(&MFAChallenge{}).Where("id = ?", challengeID).
-				Update("attempts", gorm.Expr("attempts + 1")).Error
-			return accountError(ErrInvalidCredential, "MFA 验证码错误")
+		var challenge MFAChallenge
+		if err := tx.Where("id = ?", challengeID).First(&challenge).Error; err != nil {
+			return accountError(ErrInvalidArgument, "MFA 挑战无效")
+		}
+		method = amrForMethod(challenge.Method)
+		if method == AMRSMS || method == AMREmail {
+			var u User
+			if err := tx.Where("id = ? AND tenant_id = ?", userID, tenantID).First(&u).Error; err != nil {
+				return accountError(ErrInvalidToken, "用户不可用")
+			}
+			target := u.Phone
+			if method == AMREmail {
+				target = u.Email
+			}
+			if target == nil || *target == "" {
+				return accountError(ErrInvalidArgument, "验证码接收方已解绑")
+			}
+			if err := s.m.verification.verifyTx(ctx, tx, VerifyCodeRequest{
+				ChallengeID: challenge.VerificationChallengeID,
+				Code:        code,
+				Purpose:     VerificationPurposeMFA,
+				Channel:     challenge.Method,
+				Target:      *target,
+			}); err != nil {
+				_ = tx.Model(&MFAChallenge{}).Where("id = ?", challengeID).
+					Update("attempts", gorm.Expr("attempts + 1")).Error
+				return accountError(ErrInvalidCredential, "验证码错误")
+			}
+		} else {
+			ok, verr := s.m.mfa.verifyTOTPTx(ctx, tx, tenantID, userID, code)
+			if verr != nil {
+				return accountError(ErrInternal, "MFA 验证失败")
+			}
+			if !ok {
+				// TOTP 错误：在同一事务内递增 challenge.attempts，达到 MaxAttempts 后挑战自动作废。
+				_ = tx.Model(&MFAChallenge{}).Where("id = ?", challengeID).
+					Update("attempts", gorm.Expr("attempts + 1")).Error
+				return accountError(ErrInvalidCredential, "MFA 验证码错误")
+			}
 		}
-		// 仅给当前 session 打 step-up 标记
-		now := time.Now()
-		res := tx.Model(&Session{}).
-			Where("id = ? AND tenant_id = ? AND user_id = ? AND status = ?",
-				principal.SessionID, principal.TenantID, principal.UserID, SessionActive).
-			Update("mfa_satisfied_at", &now)
-		if res.Error != nil {
-			return res.Error
-		}
-		if res.RowsAffected == 0 {
-			return accountError(ErrInvalidToken, "当前会话已失效")
+		if err := s.markStepUp(tx, principal, method); err != nil {
+			return err
 		}
 		// 消费 challenge：成功路径
 		nowTs := time.Now()
@@ -1380,12 +1582,61 @@ func (s *AuthService) CompleteStepUp(ctx context.Context, principal *Principal,
 	if err != nil {
 		return err
 	}
+	s.recordStepUpGranted(ctx, principal, method)
+	return nil
+}
+
+// CompletePasskeyStepUp 校验 StartStepUp(hwk) 返回的挑战对应的通行密钥断言，
+// 通过后与 CompleteStepUp 一样把 step-up 标记写入当前 session，amr 追加 hwk。
+func (s *AuthService) CompletePasskeyStepUp(ctx context.Context, principal *Principal, resp PasskeyAuthenticationResponse) error {
+	if principal == nil {
+		return accountError(ErrInvalidArgument, "principal 不能为空")
+	}
+	if principal.SessionID == "" {
+		return accountError(ErrInvalidArgument, "principal.SessionID 不能为空")
+	}
+	cred, err := s.m.passkeySvc.CompletePasskeyAuthentication(ctx, principal.UserID, resp)
+	if err != nil {
+		return err
+	}
+	if cred.TenantID != principal.TenantID {
+		return accountError(ErrPermissionDenied, "凭证不属于当前用户")
+	}
+	if err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.markStepUp(tx, principal, AMRPasskey)
+	}); err != nil {
+		return err
+	}
+	s.recordStepUpGranted(ctx, principal, AMRPasskey)
+	return nil
+}
+
+// markStepUp 仅给当前 session 打 step-up 标记，并把认证保证级别升级为 aal2。
+func (s *AuthService) markStepUp(tx *gorm.DB, principal *Principal, method string) error {
+	var sess Session
+	if err := tx.Select("id", "amr").
+		Where("id = ? AND tenant_id = ? AND user_id = ? AND status = ?",
+			principal.SessionID, principal.TenantID, principal.UserID, SessionActive).
+		First(&sess).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return accountError(ErrInvalidToken, "当前会话已失效")
+		}
+		return err
+	}
+	now := time.Now()
+	return tx.Model(&Session{}).Where("id = ?", sess.ID).Updates(map[string]any{
+		"mfa_satisfied_at": &now,
+		"mfa_method":       method,
+		"acr":              ACRMultiFactor,
+		"amr":              upgradeAMR(sess.AMR, method),
+	}).Error
+}
 
-	s.m.audit(ctx, principal.TenantID, principal.UserID, "mfa", "stepup", "", "", "")
+func (s *AuthService) recordStepUpGranted(ctx context.Context, principal *Principal, method string) {
+	s.m.audit(ctx, principal.TenantID, principal.UserID, "mfa", "stepup", method, "", "")
 	if m := s.m.metrics; m != nil && m.StepUpGranted != nil {
 		m.StepUpGranted.Add(ctx, 1)
 	}
-	return nil
 }
 
 // recordStepUpDenied 记录 step-up 检查失败的指标。
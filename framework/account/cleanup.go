@@ -84,6 +84,34 @@ func (m *Manager) cleanupAll(ctx context.Context) error {
 		return err
 	}
 
+	// Clean expired OAuth authorization states
+	if err := m.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&OAuthState{}).Error; err != nil {
+		recordDBError(ctx)
+		return err
+	}
+
+	// Clean expired passkey challenges
+	if err := m.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&PasskeyChallenge{}).Error; err != nil {
+		recordDBError(ctx)
+		return err
+	}
+
+	// Clean expired device authorization requests
+	if err := m.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&DeviceAuthorization{}).Error; err != nil {
+		recordDBError(ctx)
+		return err
+	}
+
+	// Clean SAML login tickets past their replay window and expired SAML session mappings
+	if err := m.db.WithContext(ctx).Where("replay_until < ?", now).Delete(&SAMLLoginTicket{}).Error; err != nil {
+		recordDBError(ctx)
+		return err
+	}
+	if err := m.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&SAMLSession{}).Error; err != nil {
+		recordDBError(ctx)
+		return err
+	}
+
 	// Clean already-sent / expired outbox events
 	if err := m.db.WithContext(ctx).Where("status IN ? AND created_at < ?",
 		[]string{outboxSent, outboxIgnored, outboxFailed}, now.Add(-24*time.Hour)).Delete(&OutboxEvent{}).Error; err != nil {
@@ -91,6 +119,12 @@ func (m *Manager) cleanupAll(ctx context.Context) error {
 		return err
 	}
 
+	// Remove expired data export archives
+	if err := m.exportSvc.cleanupExpired(ctx); err != nil {
+		recordDBError(ctx)
+		gaia.WarnF("[account] cleanup data exports failed: %v", err)
+	}
+
 	// Archive audit logs before retention cleanup
 	if archiveDays := m.cfg.Audit.ArchiveRetentionDays; archiveDays > 0 {
 		archiveCutoff := now.AddDate(0, 0, -archiveDays)
@@ -2,13 +2,17 @@ package account
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/xxzhwl/gaia"
 	frameworkredis "github.com/xxzhwl/gaia/components/redis"
+	"github.com/xxzhwl/gaia/components/storage"
 	"gorm.io/gorm"
 )
 
@@ -37,7 +41,22 @@ type Config struct {
 	Verification                   VerificationConfig
 	NotifyProvider                 NotifyProvider
 	Risk                           RiskConfig
+	// GeoIP 离线 IP 库，配置后启用地理速度信号；框架配置 Account.Risk.GeoIPDatabase 指向 CSV 文件。
+	GeoIP                          GeoIPResolver
+	// RiskSignals 自定义风险信号，追加在内置信号之后。
+	RiskSignals                    []RiskSignal
 	Audit                          AuditConfig
+	SCIM                           SCIMConfig
+	LDAP                           LDAPConfig
+	SAML                           SAMLConfig
+	ReBAC                          ReBACConfig
+	Impersonation                  ImpersonationConfig
+	Invitation                     InvitationConfig
+	UserImport                     UserImportConfig
+	DataExport                     DataExportConfig
+	IdP                            IdPConfig
+	// OIDC 通用 OIDC 提供商，键为提供商标识。New 时注册到 OAuthProviders。
+	OIDC                           map[string]OIDCProviderConfig
 	OAuthProviders                 map[string]OAuthProvider
 	EventSubscribers               map[string]EventSubscriber
 	TenantValidator                func(ctx context.Context, tenantID string) error
@@ -85,12 +104,176 @@ type AuditConfig struct {
 	AsyncBufferSize int
 }
 
+// ImpersonationConfig 管理员代操作（以用户身份登录）参数。
+type ImpersonationConfig struct {
+	// TTL 代操作会话时长，到期后令牌失效且不可刷新，默认 30 分钟。
+	TTL time.Duration
+	// AllowedScopes 代操作会话可使用的权限码（支持 "*" 与 "order:*" 前缀通配）。
+	// 发起时未指定 scopes 则使用全部 AllowedScopes，指定的 scopes 必须落在其范围内；
+	// 为空时发起方必须显式指定 scopes。
+	AllowedScopes []string
+	// RequireStepUp 发起代操作前要求操作者当前会话完成 MFA step-up。
+	RequireStepUp bool
+}
+
+// InvitationConfig 租户邀请参数。
+type InvitationConfig struct {
+	// TTL 邀请链接有效期，默认 7 天。
+	TTL time.Duration
+	// AcceptURL 前端接受邀请的页面地址，邀请令牌以 token 查询参数附加在其后；
+	// 为空时 CreateInvitation 只返回令牌。
+	AcceptURL string
+	// OrgMemberRole 预分配组织未指定角色时使用的角色编码，默认 "user"。
+	OrgMemberRole string
+	// Sender 发送邀请邮件，为空时由调用方自行把链接交给被邀请人。
+	Sender InvitationSender
+}
+
+// UserImportConfig 批量导入用户参数。
+type UserImportConfig struct {
+	// MaxRows 单次导入的最大行数，默认 1000。
+	MaxRows int
+	// MaxPayloadSize 导入文件的最大字节数，默认 5 MiB。
+	MaxPayloadSize int
+}
+
+// DataExportConfig 用户自助数据导出参数。
+type DataExportConfig struct {
+	// Store 导出归档的对象存储（cos / minio / s3 / oss 的 NewAdapter），为空时不提供导出。
+	Store storage.ObjectStore
+	// KeyPrefix 归档对象键前缀，默认 "account/exports/"。
+	KeyPrefix string
+	// URLTTL 下载链接的有效期，默认 15 分钟。
+	URLTTL time.Duration
+	// Retention 归档保留时长，过期后由 Cleanup 删除对象，默认 7 天。
+	Retention time.Duration
+	// MinInterval 同一用户两次导出的最小间隔，默认 24 小时，负数表示不限制。
+	MinInterval time.Duration
+	// MaxAuditEntries 归档中包含的最近审计日志条数上限，默认 10000。
+	MaxAuditEntries int
+}
+
+// IdPConfig 内置 IdP 的设备授权（RFC 8628）与令牌交换（RFC 8693）参数。
+type IdPConfig struct {
+	// DeviceCodeTTL 设备码与用户码的有效期，默认 10 分钟。
+	DeviceCodeTTL time.Duration
+	// DevicePollInterval 设备轮询令牌端点的最小间隔，默认 5 秒；轮询过快返回 slow_down 并把间隔加 5 秒。
+	DevicePollInterval time.Duration
+	// DeviceVerificationURI 用户输入用户码的页面地址（通常是前端页面），
+	// 为空时使用独立服务的 /oauth/device（需配置 StandaloneConfig.DeviceVerificationPage）。
+	DeviceVerificationURI string
+	// TokenExchangeAudiences 令牌交换时允许请求的 audience，为空时不接受 audience 参数。
+	TokenExchangeAudiences []string
+	// TokenExchangeMaxDepth 委托链（嵌套 act 声明）的最大深度，默认 5。
+	TokenExchangeMaxDepth int
+}
+
+// SCIMConfig SCIM 2.0 预配置参数。
+type SCIMConfig struct {
+	// GroupBackend SCIM Group 映射的对象："role"（默认）映射为租户级非系统角色，
+	// "org" 映射为组织，成员在组织内被授予 OrgMemberRole。
+	GroupBackend string
+	// OrgMemberRole GroupBackend=org 时成员获得的角色编码，默认 "user"。
+	OrgMemberRole string
+	// MaxResults 列表接口单页最大条数，默认 200。
+	MaxResults int
+	// MaxBulkOperations /Bulk 单次最多操作数，默认 100。
+	MaxBulkOperations int
+	// MaxPayloadSize 请求体最大字节数，默认 1MB。
+	MaxPayloadSize int
+}
+
+// LDAPConfig LDAP / Active Directory 认证与目录同步参数。URL 为空表示不启用。
+type LDAPConfig struct {
+	// URL 目录服务地址，如 ldap://ad.corp:389 或 ldaps://ad.corp:636。
+	URL string
+	// StartTLS 在 ldap:// 连接上先执行 StartTLS 再绑定，避免明文传输口令。
+	StartTLS bool
+	// InsecureSkipVerify 跳过证书校验，仅用于测试环境。
+	InsecureSkipVerify bool
+	// TLSConfig 自定义 TLS 配置（如私有 CA），优先于 InsecureSkipVerify。
+	TLSConfig *tls.Config
+	// Timeout 建连与单次请求超时，默认 10 秒。
+	Timeout time.Duration
+	// BindDN / BindPassword 服务账号，用于先搜索后绑定与目录同步；为空时匿名搜索。
+	BindDN       string
+	BindPassword string
+	// UserDNTemplate 用户 DN 模板，如 "uid=%s,ou=people,dc=corp,dc=com"。
+	// 配置后登录直接以模板拼出的 DN 绑定；为空则先用 UserFilter 搜索再绑定。
+	UserDNTemplate string
+	// BaseDN 用户搜索的基准 DN。
+	BaseDN string
+	// UserFilter 登录时按用户名搜索的过滤器，%s 为转义后的用户名，默认 "(&(objectClass=person)(uid=%s))"。
+	// AD 通常配置为 "(&(objectClass=user)(sAMAccountName=%s))"。
+	UserFilter string
+	// UserSyncFilter 同步时枚举用户的过滤器，默认把 UserFilter 中的 %s 替换为 *。
+	UserSyncFilter string
+	// 属性映射，默认分别为 uid / mail / displayName / mobile / entryUUID（AD 可用 objectGUID）。
+	UsernameAttr    string
+	EmailAttr       string
+	DisplayNameAttr string
+	PhoneAttr       string
+	UniqueIDAttr    string
+	// GroupBaseDN 组搜索基准 DN，为空则不同步组。
+	GroupBaseDN string
+	// GroupFilter 组过滤器，默认 "(|(objectClass=groupOfNames)(objectClass=group))"。
+	GroupFilter string
+	// GroupNameAttr / GroupMemberAttr 组名与成员属性，默认 cn / member。
+	// 成员值既可以是 DN（member / uniqueMember），也可以是用户名（posixGroup 的 memberUid）。
+	GroupNameAttr   string
+	GroupMemberAttr string
+	// GroupRoleMapping 组名（不区分大小写）到角色编码的映射。
+	// 为空时每个组自动映射为编码 "ldap_<组名>" 的租户角色；非空时只同步映射中的组。
+	GroupRoleMapping map[string]string
+	// OrgBaseDN 组织单元搜索基准 DN，为空则不同步组织。
+	OrgBaseDN string
+	// OrgFilter 组织单元过滤器，默认 "(objectClass=organizationalUnit)"。
+	OrgFilter string
+	// OrgMemberRole 用户在其所属 OU 对应组织内获得的角色编码，默认 "user"。
+	OrgMemberRole string
+	// SyncInterval 独立服务中目录同步的周期，0 表示不启动定时同步。
+	SyncInterval time.Duration
+	// DisableMissingUsers 同步时禁用目录中已不存在的 LDAP 来源用户并吊销其会话。
+	DisableMissingUsers bool
+	// TenantID 目录用户归属的租户，默认 DefaultTenantID。
+	TenantID string
+}
+
+// SAMLConfig SAML 2.0 服务提供方参数。BaseURL 为空表示不启用；IdP 连接按租户在管理接口中配置。
+type SAMLConfig struct {
+	// BaseURL 对外访问地址，如 https://account.example.com。
+	// 租户 SP 端点为 {BaseURL}/saml/{tenant}/metadata|acs|slo，其中 metadata 地址同时作为 SP EntityID。
+	BaseURL string
+	// Certificate / PrivateKey SP 证书与私钥（PEM），用于请求签名与加密断言解密，发布在 SP 元数据中。
+	Certificate string
+	PrivateKey  string
+	// SignRequests 对 AuthnRequest / LogoutRequest / LogoutResponse 签名，需要配置证书与私钥。
+	SignRequests bool
+	// DefaultRedirectURL 登录完成后的前端地址（携带 saml_ticket 参数），未指定 return_to 时使用。
+	DefaultRedirectURL string
+	// AllowedRedirectURLs 允许的 return_to 前缀（同源且路径前缀匹配），DefaultRedirectURL 始终允许。
+	AllowedRedirectURLs []string
+	// HTTPClient 拉取 IdP 元数据使用的客户端，默认 10 秒超时。
+	HTTPClient *http.Client `json:"-"`
+}
+
 // New 创建 Manager，先应用默认值并验证配置。
 func New(cfg Config) (*Manager, error) {
 	cfg = cfg.withDefaults()
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
+	if len(cfg.OIDC) > 0 {
+		providers := make(map[string]OAuthProvider, len(cfg.OAuthProviders)+len(cfg.OIDC))
+		for name, p := range cfg.OAuthProviders {
+			providers[name] = p
+		}
+		for name, pc := range cfg.OIDC {
+			pc.Name = name
+			providers[name] = NewOIDCProvider(pc)
+		}
+		cfg.OAuthProviders = providers
+	}
 	return newManager(cfg), nil
 }
 
@@ -122,6 +305,27 @@ func FrameworkConfig(db *gorm.DB, redisClient *frameworkredis.Client) Config {
 	if redisClient != nil {
 		cache = NewRedisCache(redisClient, gaia.GetSafeConfStringWithDefault("Account.Redis.KeyPrefix", "acct:"))
 	}
+	var oidcProviders map[string]OIDCProviderConfig
+	gaia.LoadConfToObj("Account.OAuth.OIDC", &oidcProviders)
+	var rebacNamespaces map[string]ReBACNamespace
+	gaia.LoadConfToObj("Account.ReBAC.Namespaces", &rebacNamespaces)
+	signalWeights := defaultRiskSignalWeights()
+	gaia.LoadConfToObj("Account.Risk.SignalWeights", &signalWeights)
+	var geoIP GeoIPResolver
+	if path := gaia.GetSafeConfString("Account.Risk.GeoIPDatabase"); path != "" {
+		resolver, err := NewCSVGeoIPResolver(path)
+		if err != nil {
+			gaia.ErrorF("[account] load Account.Risk.GeoIPDatabase failed: %v", err)
+		} else {
+			geoIP = resolver
+		}
+	}
+	var verificationTemplates []VerificationTemplate
+	gaia.LoadConfToObj("Account.Verification.Templates", &verificationTemplates)
+	var tupleStore TupleStore
+	if redisClient != nil && gaia.GetSafeConfString("Account.ReBAC.Store") == "redis" {
+		tupleStore = NewRedisTupleStore(redisClient, gaia.GetSafeConfStringWithDefault("Account.Redis.KeyPrefix", "acct:")+"rebac:")
+	}
 	return Config{
 		AppID:           gaia.GetSafeConfStringWithDefault("Account.AppID", "gaia-account"),
 		Mode:            gaia.GetSafeConfStringWithDefault("Account.Mode", "production"),
@@ -158,6 +362,9 @@ func FrameworkConfig(db *gorm.DB, redisClient *frameworkredis.Client) Config {
 			SendInterval:        time.Second * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.Verification.SendInterval", 60)),
 			MaxPerTargetPerHour: int(gaia.GetSafeConfInt64WithDefault("Account.Verification.MaxPerTargetPerHour", 5)),
 			MaxPerIPPer10Min:    int(gaia.GetSafeConfInt64WithDefault("Account.Verification.MaxPerIPPer10Min", 20)),
+			Providers:           frameworkVerificationProviders(),
+			Templates:           verificationTemplates,
+			DefaultLocale:       gaia.GetSafeConfStringWithDefault("Account.Verification.DefaultLocale", "zh-CN"),
 		},
 		Risk: RiskConfig{
 			MaxLoginFailuresPerUser: int(gaia.GetSafeConfInt64WithDefault("Account.Risk.MaxLoginFailuresPerUser", 10)),
@@ -166,14 +373,146 @@ func FrameworkConfig(db *gorm.DB, redisClient *frameworkredis.Client) Config {
 			LockoutDuration:         time.Minute * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.Risk.LockoutDuration", 30)),
 			BlockDuration:           time.Minute * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.Risk.BlockDuration", 5)),
 			EnableIPReputation:      gaia.GetSafeConfBoolWithDefault("Account.Risk.EnableIPReputation", false),
+			BadIPs:                  gaia.GetSafeConfSlice[string]("Account.Risk.BadIPs"),
+			BadIPListFile:           gaia.GetSafeConfString("Account.Risk.BadIPListFile"),
+			SignalWeights:           signalWeights,
+			ChallengeScore:          gaia.GetSafeConfFloat64WithDefault("Account.Risk.ChallengeScore", 50),
+			BlockScore:              gaia.GetSafeConfFloat64WithDefault("Account.Risk.BlockScore", 90),
+			MaxTravelSpeedKmh:       gaia.GetSafeConfFloat64WithDefault("Account.Risk.MaxTravelSpeedKmh", 900),
+			TimeOfDayMinSamples:     int(gaia.GetSafeConfInt64WithDefault("Account.Risk.TimeOfDayMinSamples", 10)),
+			DisableNewDeviceNotice:  gaia.GetSafeConfBoolWithDefault("Account.Risk.DisableNewDeviceNotice", false),
 		},
+		GeoIP: geoIP,
 		Audit: AuditConfig{
 				RetentionDays:        int(gaia.GetSafeConfInt64WithDefault("Account.Audit.RetentionDays", 90)),
 				ArchiveRetentionDays: int(gaia.GetSafeConfInt64WithDefault("Account.Audit.ArchiveRetentionDays", 0)),
 				AsyncWrite:           gaia.GetSafeConfBoolWithDefault("Account.Audit.AsyncWrite", false),
 				AsyncBufferSize:      int(gaia.GetSafeConfInt64WithDefault("Account.Audit.AsyncBufferSize", 100)),
 			},
+		SCIM: SCIMConfig{
+			GroupBackend:      gaia.GetSafeConfStringWithDefault("Account.SCIM.GroupBackend", SCIMGroupBackendRole),
+			OrgMemberRole:     gaia.GetSafeConfStringWithDefault("Account.SCIM.OrgMemberRole", "user"),
+			MaxResults:        int(gaia.GetSafeConfInt64WithDefault("Account.SCIM.MaxResults", 200)),
+			MaxBulkOperations: int(gaia.GetSafeConfInt64WithDefault("Account.SCIM.MaxBulkOperations", 100)),
+			MaxPayloadSize:    int(gaia.GetSafeConfInt64WithDefault("Account.SCIM.MaxPayloadSize", 1<<20)),
+		},
+		OIDC: oidcProviders,
+		LDAP: LDAPConfig{
+			URL:                 gaia.GetSafeConfString("Account.LDAP.URL"),
+			StartTLS:            gaia.GetSafeConfBoolWithDefault("Account.LDAP.StartTLS", false),
+			InsecureSkipVerify:  gaia.GetSafeConfBoolWithDefault("Account.LDAP.InsecureSkipVerify", false),
+			Timeout:             time.Second * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.LDAP.Timeout", 10)),
+			BindDN:              gaia.GetSafeConfString("Account.LDAP.BindDN"),
+			BindPassword:        gaia.GetSafeConfString("Account.LDAP.BindPassword"),
+			UserDNTemplate:      gaia.GetSafeConfString("Account.LDAP.UserDNTemplate"),
+			BaseDN:              gaia.GetSafeConfString("Account.LDAP.BaseDN"),
+			UserFilter:          gaia.GetSafeConfString("Account.LDAP.UserFilter"),
+			UserSyncFilter:      gaia.GetSafeConfString("Account.LDAP.UserSyncFilter"),
+			UsernameAttr:        gaia.GetSafeConfString("Account.LDAP.UsernameAttr"),
+			EmailAttr:           gaia.GetSafeConfString("Account.LDAP.EmailAttr"),
+			DisplayNameAttr:     gaia.GetSafeConfString("Account.LDAP.DisplayNameAttr"),
+			PhoneAttr:           gaia.GetSafeConfString("Account.LDAP.PhoneAttr"),
+			UniqueIDAttr:        gaia.GetSafeConfString("Account.LDAP.UniqueIDAttr"),
+			GroupBaseDN:         gaia.GetSafeConfString("Account.LDAP.GroupBaseDN"),
+			GroupFilter:         gaia.GetSafeConfString("Account.LDAP.GroupFilter"),
+			GroupNameAttr:       gaia.GetSafeConfString("Account.LDAP.GroupNameAttr"),
+			GroupMemberAttr:     gaia.GetSafeConfString("Account.LDAP.GroupMemberAttr"),
+			GroupRoleMapping:    gaia.GetSafeConfMapT[string]("Account.LDAP.GroupRoleMapping"),
+			OrgBaseDN:           gaia.GetSafeConfString("Account.LDAP.OrgBaseDN"),
+			OrgFilter:           gaia.GetSafeConfString("Account.LDAP.OrgFilter"),
+			OrgMemberRole:       gaia.GetSafeConfString("Account.LDAP.OrgMemberRole"),
+			SyncInterval:        time.Minute * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.LDAP.SyncInterval", 60)),
+			DisableMissingUsers: gaia.GetSafeConfBoolWithDefault("Account.LDAP.DisableMissingUsers", false),
+			TenantID:            gaia.GetSafeConfString("Account.LDAP.TenantID"),
+		},
+		SAML: SAMLConfig{
+			BaseURL:             gaia.GetSafeConfString("Account.SAML.BaseURL"),
+			Certificate:         gaia.GetSafeConfString("Account.SAML.Certificate"),
+			PrivateKey:          gaia.GetSafeConfString("Account.SAML.PrivateKey"),
+			SignRequests:        gaia.GetSafeConfBoolWithDefault("Account.SAML.SignRequests", false),
+			DefaultRedirectURL:  gaia.GetSafeConfString("Account.SAML.DefaultRedirectURL"),
+			AllowedRedirectURLs: gaia.GetSafeConfSlice[string]("Account.SAML.AllowedRedirectURLs"),
+		},
+		Impersonation: ImpersonationConfig{
+			TTL:           time.Minute * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.Impersonation.TTL", 30)),
+			AllowedScopes: gaia.GetSafeConfSlice[string]("Account.Impersonation.AllowedScopes"),
+			RequireStepUp: gaia.GetSafeConfBoolWithDefault("Account.Impersonation.RequireStepUp", false),
+		},
+		Invitation: InvitationConfig{
+			TTL:           time.Hour * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.Invitation.TTL", 7*24)),
+			AcceptURL:     gaia.GetSafeConfString("Account.Invitation.AcceptURL"),
+			OrgMemberRole: gaia.GetSafeConfStringWithDefault("Account.Invitation.OrgMemberRole", "user"),
+		},
+		UserImport: UserImportConfig{
+			MaxRows:        int(gaia.GetSafeConfInt64WithDefault("Account.UserImport.MaxRows", 1000)),
+			MaxPayloadSize: int(gaia.GetSafeConfInt64WithDefault("Account.UserImport.MaxPayloadSize", 5<<20)),
+		},
+		DataExport: DataExportConfig{
+			KeyPrefix:       gaia.GetSafeConfStringWithDefault("Account.DataExport.KeyPrefix", "account/exports/"),
+			URLTTL:          time.Minute * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.DataExport.URLTTL", 15)),
+			Retention:       time.Hour * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.DataExport.Retention", 7*24)),
+			MinInterval:     time.Hour * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.DataExport.MinInterval", 24)),
+			MaxAuditEntries: int(gaia.GetSafeConfInt64WithDefault("Account.DataExport.MaxAuditEntries", 10000)),
+		},
+		IdP: IdPConfig{
+			DeviceCodeTTL:          time.Second * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.IdP.DeviceCodeTTL", 600)),
+			DevicePollInterval:     time.Second * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.IdP.DevicePollInterval", 5)),
+			DeviceVerificationURI:  gaia.GetSafeConfString("Account.IdP.DeviceVerificationURI"),
+			TokenExchangeAudiences: gaia.GetSafeConfSlice[string]("Account.IdP.TokenExchangeAudiences"),
+			TokenExchangeMaxDepth:  int(gaia.GetSafeConfInt64WithDefault("Account.IdP.TokenExchangeMaxDepth", 5)),
+		},
+		ReBAC: ReBACConfig{
+			Namespaces:        rebacNamespaces,
+			Store:             tupleStore,
+			MaxDepth:          int(gaia.GetSafeConfInt64WithDefault("Account.ReBAC.MaxDepth", defaultReBACMaxDepth)),
+			CacheTTL:          time.Second * time.Duration(gaia.GetSafeConfInt64WithDefault("Account.ReBAC.CacheTTL", 30)),
+			MaxListCandidates: int(gaia.GetSafeConfInt64WithDefault("Account.ReBAC.MaxListCandidates", defaultReBACMaxListCandidates)),
+		},
+	}
+}
+
+// frameworkVerificationProviders 从 Account.Verification.SMTP / HTTPSMS / AliyunSMS / TencentSMS
+// 构建验证码发送方；短信按 Account.Verification.SMSProviders 的顺序回退，未配置顺序时为 aliyun、tencent、http_sms。
+func frameworkVerificationProviders() map[string][]VerificationSender {
+	providers := map[string][]VerificationSender{}
+	var smtpCfg SMTPConfig
+	gaia.LoadConfToObj("Account.Verification.SMTP", &smtpCfg)
+	if smtpCfg.Host != "" {
+		providers[VerificationChannelEmail] = append(providers[VerificationChannelEmail], NewSMTPSender(smtpCfg))
 	}
+
+	sms := map[string]VerificationSender{}
+	var aliyunCfg AliyunSMSConfig
+	gaia.LoadConfToObj("Account.Verification.AliyunSMS", &aliyunCfg)
+	if aliyunCfg.AccessKeyID != "" {
+		sms["aliyun"] = NewAliyunSMSSender(aliyunCfg)
+	}
+	var tencentCfg TencentSMSConfig
+	gaia.LoadConfToObj("Account.Verification.TencentSMS", &tencentCfg)
+	if tencentCfg.SecretID != "" {
+		sms["tencent"] = NewTencentSMSSender(tencentCfg)
+	}
+	var httpCfg HTTPSMSConfig
+	gaia.LoadConfToObj("Account.Verification.HTTPSMS", &httpCfg)
+	if httpCfg.URL != "" {
+		sender, err := NewHTTPSMSSender(httpCfg)
+		if err != nil {
+			gaia.ErrorF("[account] invalid Account.Verification.HTTPSMS: %v", err)
+		} else {
+			sms[sender.Name()] = sender
+		}
+	}
+	order := gaia.GetSafeConfSlice[string]("Account.Verification.SMSProviders")
+	if len(order) == 0 {
+		order = []string{"aliyun", "tencent", httpCfg.Name, "http_sms"}
+	}
+	for _, name := range order {
+		if sender, ok := sms[name]; ok {
+			providers[VerificationChannelSMS] = append(providers[VerificationChannelSMS], sender)
+			delete(sms, name)
+		}
+	}
+	return providers
 }
 
 // withDefaults 用合理的默认值填充零值字段。
@@ -186,10 +525,35 @@ func (c Config) withDefaults() Config {
 	}
 	if c.Verification.CodeLength == 0 {
 		vc := defaultVerificationConfig()
+		vc.Providers, vc.Templates = c.Verification.Providers, c.Verification.Templates
+		if c.Verification.DefaultLocale != "" {
+			vc.DefaultLocale = c.Verification.DefaultLocale
+		}
 		c.Verification = vc
 	}
+	if c.Verification.DefaultLocale == "" {
+		c.Verification.DefaultLocale = "zh-CN"
+	}
 	if c.Risk.MaxLoginFailuresPerUser == 0 {
-		c.Risk = defaultRiskConfig()
+		rc := defaultRiskConfig()
+		rc.EnableIPReputation, rc.BadIPs, rc.BadIPListFile = c.Risk.EnableIPReputation, c.Risk.BadIPs, c.Risk.BadIPListFile
+		rc.DisableNewDeviceNotice = c.Risk.DisableNewDeviceNotice
+		for name, w := range c.Risk.SignalWeights {
+			rc.SignalWeights[name] = w
+		}
+		c.Risk = rc
+	}
+	if c.Risk.SignalWeights == nil {
+		c.Risk.SignalWeights = defaultRiskSignalWeights()
+	}
+	if c.Risk.ChallengeScore == 0 {
+		c.Risk.ChallengeScore = 50
+	}
+	if c.Risk.BlockScore == 0 {
+		c.Risk.BlockScore = 90
+	}
+	if c.Risk.MaxTravelSpeedKmh == 0 {
+		c.Risk.MaxTravelSpeedKmh = 900
 	}
 	if c.DefaultTenantID == "" {
 		c.DefaultTenantID = defaultTenantID
@@ -236,6 +600,105 @@ func (c Config) withDefaults() Config {
 	if c.Audit.AsyncBufferSize == 0 {
 		c.Audit.AsyncBufferSize = 100
 	}
+	if c.SCIM.GroupBackend == "" {
+		c.SCIM.GroupBackend = SCIMGroupBackendRole
+	}
+	if c.SCIM.OrgMemberRole == "" {
+		c.SCIM.OrgMemberRole = "user"
+	}
+	if c.SCIM.MaxResults <= 0 {
+		c.SCIM.MaxResults = 200
+	}
+	if c.SCIM.MaxBulkOperations <= 0 {
+		c.SCIM.MaxBulkOperations = 100
+	}
+	if c.SCIM.MaxPayloadSize <= 0 {
+		c.SCIM.MaxPayloadSize = 1 << 20
+	}
+	if c.Impersonation.TTL <= 0 {
+		c.Impersonation.TTL = 30 * time.Minute
+	}
+	if c.Invitation.TTL <= 0 {
+		c.Invitation.TTL = 7 * 24 * time.Hour
+	}
+	if c.Invitation.OrgMemberRole == "" {
+		c.Invitation.OrgMemberRole = "user"
+	}
+	if c.UserImport.MaxRows <= 0 {
+		c.UserImport.MaxRows = 1000
+	}
+	if c.UserImport.MaxPayloadSize <= 0 {
+		c.UserImport.MaxPayloadSize = 5 << 20
+	}
+	if c.DataExport.KeyPrefix == "" {
+		c.DataExport.KeyPrefix = "account/exports/"
+	}
+	if c.DataExport.URLTTL <= 0 {
+		c.DataExport.URLTTL = 15 * time.Minute
+	}
+	if c.DataExport.Retention <= 0 {
+		c.DataExport.Retention = 7 * 24 * time.Hour
+	}
+	if c.DataExport.MinInterval == 0 {
+		c.DataExport.MinInterval = 24 * time.Hour
+	}
+	if c.DataExport.MaxAuditEntries <= 0 {
+		c.DataExport.MaxAuditEntries = 10000
+	}
+	if c.IdP.DeviceCodeTTL <= 0 {
+		c.IdP.DeviceCodeTTL = 10 * time.Minute
+	}
+	if c.IdP.DevicePollInterval <= 0 {
+		c.IdP.DevicePollInterval = 5 * time.Second
+	}
+	if c.IdP.TokenExchangeMaxDepth <= 0 {
+		c.IdP.TokenExchangeMaxDepth = 5
+	}
+	c.LDAP = c.LDAP.withDefaults()
+	return c
+}
+
+// withDefaults 填充 LDAP 的默认属性映射与过滤器。
+func (c LDAPConfig) withDefaults() LDAPConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.UserFilter == "" {
+		c.UserFilter = "(&(objectClass=person)(uid=%s))"
+	}
+	if c.UserSyncFilter == "" {
+		c.UserSyncFilter = strings.ReplaceAll(c.UserFilter, "%s", "*")
+	}
+	if c.UsernameAttr == "" {
+		c.UsernameAttr = "uid"
+	}
+	if c.EmailAttr == "" {
+		c.EmailAttr = "mail"
+	}
+	if c.DisplayNameAttr == "" {
+		c.DisplayNameAttr = "displayName"
+	}
+	if c.PhoneAttr == "" {
+		c.PhoneAttr = "mobile"
+	}
+	if c.UniqueIDAttr == "" {
+		c.UniqueIDAttr = "entryUUID"
+	}
+	if c.GroupFilter == "" {
+		c.GroupFilter = "(|(objectClass=groupOfNames)(objectClass=group))"
+	}
+	if c.GroupNameAttr == "" {
+		c.GroupNameAttr = "cn"
+	}
+	if c.GroupMemberAttr == "" {
+		c.GroupMemberAttr = "member"
+	}
+	if c.OrgFilter == "" {
+		c.OrgFilter = "(objectClass=organizationalUnit)"
+	}
+	if c.OrgMemberRole == "" {
+		c.OrgMemberRole = "user"
+	}
 	return c
 }
 
@@ -259,5 +722,55 @@ func (c Config) validate() error {
 	if c.AccessTokenTTL <= 0 || c.RefreshTokenTTL <= 0 {
 		return errors.New("account token ttl must be positive")
 	}
+	if c.SCIM.GroupBackend != SCIMGroupBackendRole && c.SCIM.GroupBackend != SCIMGroupBackendOrg {
+		return fmt.Errorf("account scim group backend must be %q or %q", SCIMGroupBackendRole, SCIMGroupBackendOrg)
+	}
+	for name, pc := range c.OIDC {
+		if name == "" || len(name) > 32 {
+			return fmt.Errorf("account oidc provider name %q must be 1-32 characters", name)
+		}
+		if _, ok := c.OAuthProviders[name]; ok {
+			return fmt.Errorf("account oidc provider %q conflicts with OAuthProviders", name)
+		}
+		if pc.ClientID == "" {
+			return fmt.Errorf("account oidc provider %q requires ClientID", name)
+		}
+		if pc.Issuer == "" && (pc.AuthorizationEndpoint == "" || pc.TokenEndpoint == "") {
+			return fmt.Errorf("account oidc provider %q requires Issuer or AuthorizationEndpoint/TokenEndpoint", name)
+		}
+		switch pc.TokenAuthMethod {
+		case "", oidcAuthMethodBasic, oidcAuthMethodPost, oidcAuthMethodNone:
+		default:
+			return fmt.Errorf("account oidc provider %q has unsupported TokenAuthMethod %q", name, pc.TokenAuthMethod)
+		}
+	}
+	if c.LDAP.URL != "" {
+		if c.LDAP.UserDNTemplate == "" && c.LDAP.BaseDN == "" {
+			return errors.New("account ldap requires UserDNTemplate or BaseDN")
+		}
+		if c.LDAP.UserDNTemplate != "" && strings.Count(c.LDAP.UserDNTemplate, "%s") != 1 {
+			return errors.New("account ldap UserDNTemplate must contain exactly one %s")
+		}
+		if strings.Count(c.LDAP.UserFilter, "%s") != 1 {
+			return errors.New("account ldap UserFilter must contain exactly one %s")
+		}
+	}
+	if c.SAML.BaseURL != "" {
+		if u, err := url.Parse(c.SAML.BaseURL); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return errors.New("account saml BaseURL must be an absolute http(s) url")
+		}
+		if u, err := url.Parse(c.SAML.DefaultRedirectURL); err != nil || u.Host == "" {
+			return errors.New("account saml requires an absolute DefaultRedirectURL")
+		}
+		if c.SAML.SignRequests && (c.SAML.Certificate == "" || c.SAML.PrivateKey == "") {
+			return errors.New("account saml SignRequests requires Certificate and PrivateKey")
+		}
+		if _, _, err := parseSAMLKeyPair(c.SAML.Certificate, c.SAML.PrivateKey); err != nil {
+			return err
+		}
+	}
+	if _, err := compileReBACNamespaces(c.ReBAC.Namespaces); err != nil {
+		return fmt.Errorf("account %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,299 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
+)
+
+// 数据导出任务状态。
+const (
+	DataExportPending = "pending"
+	DataExportRunning = "running"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+	DataExportExpired = "expired"
+)
+
+// dataExportStaleAfter 进行中的任务超过该时长视为中断，不再阻止新的导出。
+const dataExportStaleAfter = time.Hour
+
+// DataExport 用户自助数据导出任务（GDPR 第 15 / 20 条）。归档为 zip，
+// 包含资料、会话、同意记录、第三方账号绑定与审计日志，保存在 DataExportConfig.Store。
+type DataExport struct {
+	ID          string     `json:"id" gorm:"size:36;primaryKey"`
+	TenantID    string     `json:"tenant_id" gorm:"size:64;not null;index:idx_acct_data_exports_user,priority:1"`
+	UserID      string     `json:"user_id" gorm:"size:36;not null;index:idx_acct_data_exports_user,priority:2"`
+	Status      string     `json:"status" gorm:"size:16;not null;default:pending;index"`
+	ObjectKey   string     `json:"-" gorm:"size:512"`
+	Size        int64      `json:"size"`
+	Error       string     `json:"error,omitempty" gorm:"size:255"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index:idx_acct_data_exports_user,priority:3"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (DataExport) TableName() string { return "acct_data_exports" }
+
+// DataExportInfo 导出任务的对外视图，DownloadURL 仅在 Get 且状态为 ready 时返回。
+type DataExportInfo struct {
+	DataExport
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// DataExportService 用户数据导出。
+type DataExportService struct {
+	m *Manager
+}
+
+// Enabled 是否配置了导出存储。
+func (s *DataExportService) Enabled() bool {
+	return s.m.cfg.DataExport.Store != nil
+}
+
+// Request 为当前用户创建导出任务并在后台生成归档。同一用户同时只能有一个进行中的任务，
+// 两次导出间隔不少于 DataExportConfig.MinInterval；代操作会话不能导出。
+func (s *DataExportService) Request(ctx context.Context, principal *Principal) (*DataExport, error) {
+	if principal == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	if principal.Impersonated {
+		return nil, accountError(ErrPermissionDenied, "代操作会话不能导出用户数据")
+	}
+	if !s.Enabled() {
+		return nil, accountError(ErrInvalidArgument, "未配置数据导出存储")
+	}
+	tenantID := s.m.tenantID(principal.TenantID)
+	now := time.Now()
+	var last DataExport
+	err := s.m.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ? AND status <> ?", tenantID, principal.UserID, DataExportFailed).
+		Order("created_at DESC").First(&last).Error
+	switch {
+	case err == nil:
+		inFlight := (last.Status == DataExportPending || last.Status == DataExportRunning) && now.Sub(last.CreatedAt) < dataExportStaleAfter
+		if inFlight {
+			return nil, accountError(ErrRateLimited, "已有进行中的导出任务")
+		}
+		if interval := s.m.cfg.DataExport.MinInterval; interval > 0 && now.Sub(last.CreatedAt) < interval {
+			return nil, accountError(ErrRateLimited, "导出过于频繁，请稍后再试")
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	export := DataExport{ID: newID(), TenantID: tenantID, UserID: principal.UserID, Status: DataExportPending}
+	if err := s.m.db.WithContext(ctx).Create(&export).Error; err != nil {
+		recordDBError(ctx)
+		return nil, fmt.Errorf("create data export: %w", err)
+	}
+	s.m.audit(ctx, tenantID, principal.UserID, "data_export_request", "success", "export: "+export.ID, "", "")
+	go s.run(context.WithoutCancel(ctx), export.ID)
+	return &export, nil
+}
+
+// Get 返回导出任务，状态为 ready 时附带有效期为 DataExportConfig.URLTTL 的下载链接。
+func (s *DataExportService) Get(ctx context.Context, tenantID, userID, exportID string) (*DataExportInfo, error) {
+	tenantID = s.m.tenantID(tenantID)
+	var export DataExport
+	if err := s.m.db.WithContext(ctx).Where("id = ? AND tenant_id = ? AND user_id = ?", exportID, tenantID, userID).
+		First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, accountError(ErrInvalidArgument, "导出任务不存在")
+		}
+		return nil, err
+	}
+	info := &DataExportInfo{DataExport: export}
+	if export.Status == DataExportReady && export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt) {
+		info.Status = DataExportExpired
+	}
+	if info.Status == DataExportReady && s.Enabled() {
+		ttl := s.m.cfg.DataExport.URLTTL
+		if remaining := time.Until(*export.ExpiresAt); remaining < ttl {
+			ttl = remaining
+		}
+		url, err := s.m.cfg.DataExport.Store.SignURL(ctx, export.ObjectKey, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("sign export url: %w", err)
+		}
+		info.DownloadURL = url
+		s.m.audit(ctx, tenantID, userID, "data_export_download", "success", "export: "+export.ID, "", "")
+	}
+	return info, nil
+}
+
+// List 返回用户最近的导出任务。
+func (s *DataExportService) List(ctx context.Context, tenantID, userID string) ([]DataExport, error) {
+	var rows []DataExport
+	if err := s.m.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ?", s.m.tenantID(tenantID), userID).
+		Order("created_at DESC").Limit(20).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// run 生成归档并上传，结果写回任务状态。
+func (s *DataExportService) run(ctx context.Context, exportID string) {
+	res := s.m.db.WithContext(ctx).Model(&DataExport{}).
+		Where("id = ? AND status = ?", exportID, DataExportPending).Update("status", DataExportRunning)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+	var export DataExport
+	if err := s.m.db.WithContext(ctx).Where("id = ?", exportID).First(&export).Error; err != nil {
+		return
+	}
+	fail := func(err error) {
+		gaia.ErrorF("[account] data export %s failed: %v", exportID, err)
+		s.m.db.WithContext(ctx).Model(&DataExport{}).Where("id = ?", exportID).
+			Updates(map[string]any{"status": DataExportFailed, "error": truncateString(err.Error(), 255)})
+		s.m.audit(ctx, export.TenantID, export.UserID, "data_export", "failed", truncateString(err.Error(), 255), "", "")
+	}
+
+	archive, err := s.buildArchive(ctx, export.TenantID, export.UserID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	key := fmt.Sprintf("%s%s/%s/%s.zip", s.m.cfg.DataExport.KeyPrefix, export.TenantID, export.UserID, export.ID)
+	if err := s.m.cfg.DataExport.Store.Put(ctx, key, archive); err != nil {
+		fail(fmt.Errorf("upload archive: %w", err))
+		return
+	}
+	now := time.Now()
+	expiresAt := now.Add(s.m.cfg.DataExport.Retention)
+	if err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&DataExport{}).Where("id = ?", exportID).Updates(map[string]any{
+			"status": DataExportReady, "object_key": key, "size": int64(len(archive)),
+			"expires_at": expiresAt, "completed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return emitOutbox(tx, EventDataExportReady, export.UserID, map[string]any{
+			"export_id":  export.ID,
+			"tenant_id":  export.TenantID,
+			"user_id":    export.UserID,
+			"size":       len(archive),
+			"expires_at": expiresAt,
+		})
+	}); err != nil {
+		_ = s.m.cfg.DataExport.Store.Delete(ctx, key)
+		fail(err)
+		return
+	}
+	s.m.audit(ctx, export.TenantID, export.UserID, "data_export", "success", "export: "+export.ID, "", "")
+}
+
+// exportOAuthAccount 归档中的第三方账号绑定。
+type exportOAuthAccount struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	AvatarURL string    `json:"avatar_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// buildArchive 收集用户数据并打包为 zip，每类数据一个 JSON 文件。
+func (s *DataExportService) buildArchive(ctx context.Context, tenantID, userID string) ([]byte, error) {
+	db := s.m.db.WithContext(ctx)
+	var user User
+	if err := db.Where("id = ? AND tenant_id = ?", userID, tenantID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("load user: %w", err)
+	}
+	roles, err := s.m.auth.loadRoleCodes(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("load roles: %w", err)
+	}
+	orgs, err := s.m.orgSvc.ListUserOrgs(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("load orgs: %w", err)
+	}
+	var sessions []Session
+	if err := db.Where("tenant_id = ? AND user_id = ?", tenantID, userID).Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("load sessions: %w", err)
+	}
+	consents, err := s.m.consentSvc.List(ctx, tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("load consents: %w", err)
+	}
+	var links []OAuthAccount
+	if err := db.Where("tenant_id = ? AND user_id = ?", tenantID, userID).Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("load oauth accounts: %w", err)
+	}
+	oauthAccounts := make([]exportOAuthAccount, 0, len(links))
+	for _, l := range links {
+		oauthAccounts = append(oauthAccounts, exportOAuthAccount{
+			Provider: l.Provider, Subject: l.Subject, Email: l.Email, Name: l.Name, AvatarURL: l.AvatarURL, CreatedAt: l.CreatedAt,
+		})
+	}
+	var audits []AuditLog
+	if err := db.Where("tenant_id = ? AND user_id = ?", tenantID, userID).Order("created_at DESC").
+		Limit(s.m.cfg.DataExport.MaxAuditEntries).Find(&audits).Error; err != nil {
+		return nil, fmt.Errorf("load audit logs: %w", err)
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"manifest.json", map[string]any{
+			"user_id": userID, "tenant_id": tenantID, "generated_at": time.Now(),
+			"files": []string{"profile.json", "sessions.json", "consents.json", "oauth_accounts.json", "audit_logs.json"},
+		}},
+		{"profile.json", map[string]any{"user": user.toInfo(roles, []string{}), "organizations": orgs}},
+		{"sessions.json", sessions},
+		{"consents.json", consents},
+		{"oauth_accounts.json", oauthAccounts},
+		{"audit_logs.json", audits},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return nil, fmt.Errorf("encode %s: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cleanupExpired 删除过期归档对象并把任务标记为 expired。
+func (s *DataExportService) cleanupExpired(ctx context.Context) error {
+	if !s.Enabled() {
+		return nil
+	}
+	var rows []DataExport
+	if err := s.m.db.WithContext(ctx).Where("status = ? AND expires_at < ?", DataExportReady, time.Now()).
+		Limit(500).Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := s.m.cfg.DataExport.Store.Delete(ctx, row.ObjectKey); err != nil {
+			gaia.WarnF("[account] delete expired data export %s failed: %v", row.ID, err)
+			continue
+		}
+		if err := s.m.db.WithContext(ctx).Model(&DataExport{}).Where("id = ?", row.ID).
+			Updates(map[string]any{"status": DataExportExpired, "object_key": ""}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
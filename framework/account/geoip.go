@@ -0,0 +1,127 @@
+package account
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeoLocation IP 对应的地理位置。
+type GeoLocation struct {
+	Country   string
+	Region    string
+	City      string
+	Latitude  float64
+	Longitude float64
+}
+
+// String 返回便于展示的 "国家 地区 城市"。
+func (l *GeoLocation) String() string {
+	if l == nil {
+		return ""
+	}
+	parts := make([]string, 0, 3)
+	for _, p := range []string{l.Country, l.Region, l.City} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// GeoIPResolver 把 IP 解析为地理位置，用于异地登录与地理速度检测。
+// 查不到时返回 nil, nil。
+type GeoIPResolver interface {
+	Lookup(ip string) (*GeoLocation, error)
+}
+
+type geoIPRange struct {
+	start, end netip.Addr
+	loc        GeoLocation
+}
+
+// CSVGeoIPResolver 基于离线 CSV 的 IP 库，每行：起始 IP,结束 IP,国家,地区,城市,纬度,经度，
+// 与 DB-IP / IP2Location 等免费城市库导出的列顺序一致（多余的列忽略，# 开头的行为注释）。
+// 整库加载到内存，按起始 IP 二分查找。
+type CSVGeoIPResolver struct {
+	ranges []geoIPRange
+}
+
+// NewCSVGeoIPResolver 从文件加载离线 IP 库。
+func NewCSVGeoIPResolver(path string) (*CSVGeoIPResolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCSVGeoIP(bytes.NewReader(data))
+}
+
+// ParseCSVGeoIP 从 reader 解析离线 IP 库。
+func ParseCSVGeoIP(r io.Reader) (*CSVGeoIPResolver, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	var ranges []geoIPRange
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geoip csv line %d: %w", line, err)
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("geoip csv line %d: expected at least 3 columns", line)
+		}
+		start, err1 := netip.ParseAddr(strings.TrimSpace(record[0]))
+		end, err2 := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err1 != nil || err2 != nil || start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("geoip csv line %d: invalid ip range", line)
+		}
+		rng := geoIPRange{start: start.Unmap(), end: end.Unmap(), loc: GeoLocation{Country: strings.TrimSpace(record[2])}}
+		if len(record) > 3 {
+			rng.loc.Region = strings.TrimSpace(record[3])
+		}
+		if len(record) > 4 {
+			rng.loc.City = strings.TrimSpace(record[4])
+		}
+		if len(record) > 6 {
+			rng.loc.Latitude, _ = strconv.ParseFloat(strings.TrimSpace(record[5]), 64)
+			rng.loc.Longitude, _ = strconv.ParseFloat(strings.TrimSpace(record[6]), 64)
+		}
+		ranges = append(ranges, rng)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	return &CSVGeoIPResolver{ranges: ranges}, nil
+}
+
+func (r *CSVGeoIPResolver) Lookup(ip string) (*GeoLocation, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, nil
+	}
+	addr = addr.Unmap()
+	i := sort.Search(len(r.ranges), func(i int) bool { return addr.Less(r.ranges[i].start) }) - 1
+	if i < 0 || r.ranges[i].end.Less(addr) || r.ranges[i].start.Is4() != addr.Is4() {
+		return nil, nil
+	}
+	loc := r.ranges[i].loc
+	return &loc, nil
+}
+
+// distanceKm 两个坐标之间的大圆距离（千米）。
+func distanceKm(a, b *GeoLocation) float64 {
+	const earthRadiusKm = 6371.0
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	dLat := rad(b.Latitude - a.Latitude)
+	dLon := rad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(a.Latitude))*math.Cos(rad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
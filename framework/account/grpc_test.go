@@ -0,0 +1,225 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func testGRPCAuthOption(rules map[string]GRPCMethodRule) GRPCAuthOption {
+	principals := map[string]*Principal{
+		"admin-token": {UserID: "u-admin", TenantID: "default", Roles: []string{"platform_admin"}},
+		"user-token":  {UserID: "u-1", TenantID: "default", Roles: []string{"user"}},
+		"pat-token":   {UserID: "u-2", TenantID: "default", APITokenID: "pat-1", Scopes: []string{"orders:read"}},
+		"imp-token": {UserID: "u-owner", TenantID: "default", Roles: []string{"tenant_owner"},
+			Impersonated: true, ActorID: "u-support", Scopes: []string{"orders:read"}},
+	}
+	return GRPCAuthOption{
+		Rules: rules,
+		Authenticate: func(_ context.Context, token string) (*Principal, error) {
+			if p, ok := principals[token]; ok {
+				return p, nil
+			}
+			return nil, accountError(ErrInvalidToken, "access token 无效")
+		},
+	}
+}
+
+func incomingWithToken(token string) context.Context {
+	ctx := context.Background()
+	if token == "" {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func callUnary(t *testing.T, interceptor grpc.UnaryServerInterceptor, method, token string) (*Principal, codes.Code) {
+	t.Helper()
+	var got *Principal
+	_, err := interceptor(incomingWithToken(token), nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, _ any) (any, error) {
+			got, _ = PrincipalFromContext(ctx)
+			if AccessTokenFromContext(ctx) != token {
+				t.Fatalf("context 中的令牌应为 %q", token)
+			}
+			return nil, nil
+		})
+	return got, status.Code(err)
+}
+
+func TestGRPCServerInterceptorAuthenticate(t *testing.T) {
+	m := testManager(t, testAuthConfig())
+	interceptor := m.Middleware().UnaryServerInterceptor(testGRPCAuthOption(map[string]GRPCMethodRule{
+		"/shop.Catalog/*": {Public: true},
+	}))
+
+	if _, code := callUnary(t, interceptor, "/shop.Orders/Get", ""); code != codes.Unauthenticated {
+		t.Fatalf("缺少令牌应返回 Unauthenticated，实际 %v", code)
+	}
+	if _, code := callUnary(t, interceptor, "/shop.Orders/Get", "bad-token"); code != codes.Unauthenticated {
+		t.Fatalf("无效令牌应返回 Unauthenticated，实际 %v", code)
+	}
+	if p, code := callUnary(t, interceptor, "/shop.Orders/Get", "user-token"); code != codes.OK || p == nil || p.UserID != "u-1" {
+		t.Fatalf("有效令牌应注入 Principal，实际 %v %+v", code, p)
+	}
+	if _, code := callUnary(t, interceptor, "/shop.Catalog/List", ""); code != codes.OK {
+		t.Fatalf("服务通配的公开方法应放行，实际 %v", code)
+	}
+	if _, code := callUnary(t, interceptor, "/grpc.health.v1.Health/Check", ""); code != codes.OK {
+		t.Fatalf("健康检查应默认免鉴权，实际 %v", code)
+	}
+}
+
+func TestGRPCServerInterceptorRules(t *testing.T) {
+	m := testManager(t, testAuthConfig())
+	interceptor := m.Middleware().UnaryServerInterceptor(testGRPCAuthOption(map[string]GRPCMethodRule{
+		"/shop.Orders/Delete": {Roles: []string{"ops"}},
+		"/shop.Orders/Export": {Permissions: []string{"orders:export"}},
+	}))
+
+	if _, code := callUnary(t, interceptor, "/shop.Orders/Delete", "user-token"); code != codes.PermissionDenied {
+		t.Fatalf("缺少角色应返回 PermissionDenied，实际 %v", code)
+	}
+	if _, code := callUnary(t, interceptor, "/shop.Orders/Delete", "admin-token"); code != codes.OK {
+		t.Fatalf("系统角色应绕过角色检查，实际 %v", code)
+	}
+	if _, code := callUnary(t, interceptor, "/shop.Orders/Export", "pat-token"); code != codes.PermissionDenied {
+		t.Fatalf("PAT scope 不包含权限应被拒绝，实际 %v", code)
+	}
+	if _, code := callUnary(t, interceptor, "/shop.Orders/Export", "admin-token"); code != codes.OK {
+		t.Fatalf("系统角色应通过权限检查，实际 %v", code)
+	}
+}
+
+func TestGRPCServerInterceptorImpersonatedSystemRole(t *testing.T) {
+	m, _ := newPolicyTestManager(t)
+	interceptor := m.Middleware().UnaryServerInterceptor(testGRPCAuthOption(map[string]GRPCMethodRule{
+		"/shop.Orders/Delete": {Roles: []string{"ops"}},
+		"/shop.Orders/Export": {Permissions: []string{"orders:export"}},
+		"/shop.Orders/Audit":  {AnyPermissions: []string{"orders:export", "orders:audit"}},
+	}))
+
+	// 代操作 tenant_owner 用户时不能借系统角色绕过代操作的权限范围
+	for _, method := range []string{"/shop.Orders/Delete", "/shop.Orders/Export", "/shop.Orders/Audit"} {
+		if _, code := callUnary(t, interceptor, method, "imp-token"); code != codes.PermissionDenied {
+			t.Fatalf("%s: 代操作会话不应享有系统角色放行，实际 %v", method, code)
+		}
+	}
+	if _, code := callUnary(t, interceptor, "/shop.Orders/Audit", "admin-token"); code != codes.OK {
+		t.Fatalf("非代操作的系统角色仍应放行，实际 %v", code)
+	}
+}
+
+func TestGRPCServerInterceptorScopedRoles(t *testing.T) {
+	m, bob := newPolicyTestManager(t)
+	scoped := func(mutate func(p *Principal)) *Principal {
+		p := *bob
+		mutate(&p)
+		return &p
+	}
+	principals := map[string]*Principal{
+		"own-token": bob,
+		"imp-narrow": scoped(func(p *Principal) {
+			p.Impersonated, p.ActorID, p.Scopes = true, "u-support", []string{"orders:read"}
+		}),
+		"imp-wide": scoped(func(p *Principal) {
+			p.Impersonated, p.ActorID, p.Scopes = true, "u-support", []string{"invoice:*"}
+		}),
+		"exchange-narrow": scoped(func(p *Principal) { p.ClientID, p.GrantedScopes = "reports", []string{"orders:read"} }),
+		"exchange-wide":   scoped(func(p *Principal) { p.ClientID, p.GrantedScopes = "reports", []string{"invoice:approve"} }),
+	}
+	opt := GRPCAuthOption{
+		Rules: map[string]GRPCMethodRule{"/shop.Invoices/Approve": {Roles: []string{"accountant"}}},
+		Authenticate: func(_ context.Context, token string) (*Principal, error) {
+			return principals[token], nil
+		},
+	}
+	interceptor := m.Middleware().UnaryServerInterceptor(opt)
+
+	// 借来的角色只有在其权限全部落在代操作 / 令牌交换的 scope 内时才满足角色规则
+	want := map[string]codes.Code{
+		"own-token":       codes.OK,
+		"imp-narrow":      codes.PermissionDenied,
+		"imp-wide":        codes.OK,
+		"exchange-narrow": codes.PermissionDenied,
+		"exchange-wide":   codes.OK,
+	}
+	for token, code := range want {
+		if _, got := callUnary(t, interceptor, "/shop.Invoices/Approve", token); got != code {
+			t.Fatalf("%s: 期望 %v，实际 %v", token, code, got)
+		}
+	}
+}
+
+type staticTokenSource struct {
+	token string
+	err   error
+}
+
+func (s staticTokenSource) Token(context.Context) (string, error) { return s.token, s.err }
+
+func outgoingToken(t *testing.T, opt GRPCClientAuthOption, ctx context.Context) (string, error) {
+	t.Helper()
+	var got string
+	err := UnaryClientAuthInterceptor(opt)(ctx, "/shop.Orders/Get", nil, nil, nil,
+		func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if vals := md.Get("authorization"); len(vals) == 1 {
+				got = vals[0]
+			} else if len(vals) > 1 {
+				t.Fatalf("authorization 只应有一个值，实际 %v", vals)
+			}
+			return nil
+		})
+	return got, err
+}
+
+func TestGRPCClientInterceptor(t *testing.T) {
+	caller := ContextWithAccessToken(context.Background(), "caller-token")
+	source := staticTokenSource{token: "svc-token"}
+
+	if got, _ := outgoingToken(t, GRPCClientAuthOption{}, caller); got != "Bearer caller-token" {
+		t.Fatalf("应转发调用方令牌，实际 %q", got)
+	}
+	if got, _ := outgoingToken(t, GRPCClientAuthOption{}, incomingWithToken("in-token")); got != "Bearer in-token" {
+		t.Fatalf("应转发入站 metadata 中的令牌，实际 %q", got)
+	}
+	if got, _ := outgoingToken(t, GRPCClientAuthOption{Mode: GRPCServiceToken, Source: source}, caller); got != "Bearer svc-token" {
+		t.Fatalf("服务令牌模式应替换为服务令牌，实际 %q", got)
+	}
+	if got, _ := outgoingToken(t, GRPCClientAuthOption{Mode: GRPCForwardOrServiceToken, Source: source}, context.Background()); got != "Bearer svc-token" {
+		t.Fatalf("无调用方时应回落到服务令牌，实际 %q", got)
+	}
+	_, err := outgoingToken(t, GRPCClientAuthOption{Mode: GRPCServiceToken, Source: staticTokenSource{err: errors.New("down")}}, caller)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("换取服务令牌失败应返回 Unauthenticated，实际 %v", err)
+	}
+}
+
+func TestClientCredentialsSourceCachesToken(t *testing.T) {
+	calls := 0
+	src := &ClientCredentialsSource{}
+	src.fetch = func(context.Context) (*TokenResponse, error) {
+		calls++
+		return &TokenResponse{AccessToken: "svc-token", ExpiresIn: int64(time.Hour / time.Second)}, nil
+	}
+	for i := 0; i < 3; i++ {
+		if token, err := src.Token(context.Background()); err != nil || token != "svc-token" {
+			t.Fatalf("Token() = %q, %v", token, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("未过期前应复用令牌，实际请求 %d 次", calls)
+	}
+	src.expiresAt = time.Now().Add(10 * time.Second)
+	_, _ = src.Token(context.Background())
+	if calls != 2 {
+		t.Fatalf("临近过期应刷新令牌，实际请求 %d 次", calls)
+	}
+}
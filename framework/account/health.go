@@ -2,6 +2,7 @@ package account
 
 import (
 	"context"
+	"strings"
 )
 
 // HealthStatus 健康检查结果。
@@ -45,6 +46,16 @@ func (s *HealthService) Check(ctx context.Context) *HealthStatus {
 	if s.m.cfg.NotifyProvider != nil {
 		checks["notify_provider"] = &HealthCheck{Status: "ok", Message: "configured"}
 	}
+	for channel, senders := range s.m.cfg.Verification.Providers {
+		if len(senders) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(senders))
+		for _, sender := range senders {
+			names = append(names, sender.Name())
+		}
+		checks["verification_"+channel] = &HealthCheck{Status: "ok", Message: strings.Join(names, ",")}
+	}
 
 	status := "ok"
 	if !allOK {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -14,11 +15,30 @@ import (
 )
 
 // IdpService OAuth 2.0 / OIDC 身份提供商服务。
-// 支持授权码流程（authorization_code）和客户端凭证流程（client_credentials）。
+// 支持授权码流程（authorization_code）、客户端凭证流程（client_credentials）、
+// 设备授权流程（RFC 8628）和令牌交换（RFC 8693）；每种流程须在客户端的 AllowedGrantTypes 中登记。
 type IdpService struct {
 	m *Manager
 }
 
+// 令牌端点支持的 grant_type。
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// supportedGrantTypes 注册客户端时允许登记的 grant_type，同时用于 OIDC 发现文档。
+var supportedGrantTypes = []string{
+	GrantTypeAuthorizationCode,
+	GrantTypeRefreshToken,
+	GrantTypeClientCredentials,
+	GrantTypeDeviceCode,
+	GrantTypeTokenExchange,
+}
+
 // IdpClientRequest 注册 OAuth 客户端的请求参数。
 type IdpClientRequest struct {
 	TenantID          string
@@ -26,6 +46,8 @@ type IdpClientRequest struct {
 	RedirectURIs      []string
 	AllowedGrantTypes []string
 	Scopes            []string
+	// Public 登记为公开客户端：不生成密钥，仅允许设备授权流程。
+	Public bool
 }
 
 // IdpClientResponse 注册/查询客户端的返回。
@@ -37,6 +59,7 @@ type IdpClientResponse struct {
 	RedirectURIs      []string `json:"redirect_uris"`
 	AllowedGrantTypes []string `json:"allowed_grant_types"`
 	Scopes            []string `json:"scopes"`
+	Public            bool     `json:"public"`
 	Status            string   `json:"status"`
 }
 
@@ -66,6 +89,18 @@ type TokenRequest struct {
 	TenantID     string
 	UserID       string // set by caller for client_credentials
 	Roles        []string
+	// DeviceCode 设备授权流程轮询时提交的 device_code。
+	DeviceCode string
+	// 令牌交换（RFC 8693）参数；Audience 可包含多个目标服务。
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedTokenType string
+	Audience           []string
+	// IP / UserAgent 记录到令牌端点的审计日志中。
+	IP        string
+	UserAgent string
 }
 
 // TokenResponse OAuth 令牌响应。
@@ -76,6 +111,8 @@ type TokenResponse struct {
 	RefreshToken string `json:"refresh_token,omitempty"`
 	IDToken      string `json:"id_token,omitempty"`
 	Scope        string `json:"scope,omitempty"`
+	// IssuedTokenType 令牌交换返回的令牌类型（RFC 8693 §2.2.1）。
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
 }
 
 // RegisterClient 注册新的 OAuth 客户端。
@@ -84,12 +121,25 @@ func (s *IdpService) RegisterClient(ctx context.Context, req IdpClientRequest) (
 	if err != nil {
 		return nil, err
 	}
-	clientSecret, err := generateClientSecret()
-	if err != nil {
-		return nil, err
+	for _, gt := range req.AllowedGrantTypes {
+		if !contains(supportedGrantTypes, gt) {
+			return nil, accountError(ErrInvalidArgument, "不支持的 grant_type: "+gt)
+		}
+		if req.Public && gt != GrantTypeDeviceCode {
+			return nil, accountError(ErrInvalidArgument, "公开客户端只能使用设备授权流程")
+		}
+	}
+	if req.Public && len(req.AllowedGrantTypes) == 0 {
+		return nil, accountError(ErrInvalidArgument, "公开客户端只能使用设备授权流程")
+	}
+	var clientSecret string
+	if !req.Public {
+		if clientSecret, err = generateClientSecret(); err != nil {
+			return nil, err
+		}
 	}
 	redirectURIs, _ := json.Marshal(req.RedirectURIs)
-	grantTypes := "authorization_code"
+	grantTypes := GrantTypeAuthorizationCode
 	if len(req.AllowedGrantTypes) > 0 {
 		grantTypes = strings.Join(req.AllowedGrantTypes, ",")
 	}
@@ -106,6 +156,7 @@ func (s *IdpService) RegisterClient(ctx context.Context, req IdpClientRequest) (
 		RedirectURIs:      string(redirectURIs),
 		AllowedGrantTypes: grantTypes,
 		Scopes:            scopes,
+		Public:            req.Public,
 		Status:            "enabled",
 	}
 	if err := s.m.db.WithContext(ctx).Create(client).Error; err != nil {
@@ -114,13 +165,15 @@ func (s *IdpService) RegisterClient(ctx context.Context, req IdpClientRequest) (
 	var uris []string
 	json.Unmarshal([]byte(client.RedirectURIs), &uris)
 	return &IdpClientResponse{
-		ID:           client.ID,
-		ClientID:     client.ClientID,
-		ClientSecret: client.ClientSecret,
-		Name:         client.Name,
-		RedirectURIs: uris,
-		Scopes:       strings.Split(client.Scopes, ","),
-		Status:       client.Status,
+		ID:                client.ID,
+		ClientID:          client.ClientID,
+		ClientSecret:      client.ClientSecret,
+		Name:              client.Name,
+		RedirectURIs:      uris,
+		AllowedGrantTypes: strings.Split(client.AllowedGrantTypes, ","),
+		Scopes:            strings.Split(client.Scopes, ","),
+		Public:            client.Public,
+		Status:            client.Status,
 	}, nil
 }
 
@@ -133,12 +186,14 @@ func (s *IdpService) GetClient(ctx context.Context, clientID string) (*IdpClient
 	var uris []string
 	json.Unmarshal([]byte(client.RedirectURIs), &uris)
 	return &IdpClientResponse{
-		ID:           client.ID,
-		ClientID:     client.ClientID,
-		Name:         client.Name,
-		RedirectURIs: uris,
-		Scopes:       strings.Split(client.Scopes, ","),
-		Status:       client.Status,
+		ID:                client.ID,
+		ClientID:          client.ClientID,
+		Name:              client.Name,
+		RedirectURIs:      uris,
+		AllowedGrantTypes: strings.Split(client.AllowedGrantTypes, ","),
+		Scopes:            strings.Split(client.Scopes, ","),
+		Public:            client.Public,
+		Status:            client.Status,
 	}, nil
 }
 
@@ -153,12 +208,14 @@ func (s *IdpService) ListClients(ctx context.Context, tenantID string) ([]IdpCli
 		var uris []string
 		json.Unmarshal([]byte(c.RedirectURIs), &uris)
 		resp[i] = IdpClientResponse{
-			ID:           c.ID,
-			ClientID:     c.ClientID,
-			Name:         c.Name,
-			RedirectURIs: uris,
-			Scopes:       strings.Split(c.Scopes, ","),
-			Status:       c.Status,
+			ID:                c.ID,
+			ClientID:          c.ClientID,
+			Name:              c.Name,
+			RedirectURIs:      uris,
+			AllowedGrantTypes: strings.Split(c.AllowedGrantTypes, ","),
+			Scopes:            strings.Split(c.Scopes, ","),
+			Public:            c.Public,
+			Status:            c.Status,
 		}
 	}
 	return resp, nil
@@ -178,12 +235,15 @@ func (s *IdpService) Authorize(ctx context.Context, req AuthorizeRequest) (*Auth
 	if client.Status != "enabled" {
 		return nil, accountError(ErrInvalidArgument, "客户端已禁用")
 	}
-	if !s.hasGrantType(client, "authorization_code") {
+	if !s.hasGrantType(client, GrantTypeAuthorizationCode) {
 		return nil, accountError(ErrInvalidArgument, "客户端不支持授权码流程")
 	}
 	if !s.matchesRedirectURI(client, req.RedirectURI) {
 		return nil, accountError(ErrInvalidArgument, "重定向 URI 不匹配")
 	}
+	if err := s.m.tenantSvc.checkIdPConnection(ctx, s.m.tenantID(req.TenantID), req.ClientID); err != nil {
+		return nil, err
+	}
 
 	code, err := generateAuthCode()
 	if err != nil {
@@ -210,7 +270,7 @@ func (s *IdpService) Authorize(ctx context.Context, req AuthorizeRequest) (*Auth
 	return authCode, nil
 }
 
-// Token 令牌端点，处理授权码兑换和令牌刷新。
+// Token 令牌端点，处理授权码兑换、客户端凭证、设备码轮询和令牌交换。
 func (s *IdpService) Token(ctx context.Context, req TokenRequest) (*TokenResponse, error) {
 	client, err := s.loadClient(ctx, req.ClientID)
 	if err != nil {
@@ -221,12 +281,16 @@ func (s *IdpService) Token(ctx context.Context, req TokenRequest) (*TokenRespons
 	}
 
 	switch req.GrantType {
-	case "authorization_code":
+	case GrantTypeAuthorizationCode:
 		return s.handleAuthCodeGrant(ctx, client, req)
-	case "refresh_token":
+	case GrantTypeRefreshToken:
 		return s.handleRefreshTokenGrant(ctx, client, req)
-	case "client_credentials":
+	case GrantTypeClientCredentials:
 		return s.handleClientCredentials(ctx, client, req)
+	case GrantTypeDeviceCode:
+		return s.handleDeviceCodeGrant(ctx, client, req)
+	case GrantTypeTokenExchange:
+		return s.handleTokenExchange(ctx, client, req)
 	default:
 		return nil, accountError(ErrInvalidArgument, "不支持的 grant_type")
 	}
@@ -250,13 +314,14 @@ func (s *IdpService) OpenIDConfig(ctx context.Context, issuer string) map[string
 		"issuer":                                issuer,
 		"authorization_endpoint":                issuer + "/oauth/authorize",
 		"token_endpoint":                        issuer + "/oauth/token",
+		"device_authorization_endpoint":         issuer + "/oauth/device/code",
 		"jwks_uri":                              issuer + "/oauth/certs",
 		"response_types_supported":              []string{"code"},
-		"grant_types_supported":                 []string{"authorization_code", "refresh_token", "client_credentials"},
+		"grant_types_supported":                 supportedGrantTypes,
 		"subject_types_supported":               []string{"public"},
 		"id_token_signing_alg_values_supported": []string{"ES256", "RS256", "HS256"},
 		"scopes_supported":                      []string{"openid", "profile", "email"},
-		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
 	}
 }
 
@@ -291,7 +356,7 @@ func (s *IdpService) handleAuthCodeGrant(ctx context.Context, client *IdpClient,
 
 	s.m.db.WithContext(ctx).Delete(&authCode)
 
-	return s.issueTokens(ctx, authCode.TenantID, authCode.UserID, authCode.ClientID, authCode.Scopes, nil)
+	return s.issueUserTokens(ctx, authCode.TenantID, authCode.UserID, authCode.ClientID, authCode.Scopes)
 }
 
 func (s *IdpService) handleRefreshTokenGrant(ctx context.Context, client *IdpClient, req TokenRequest) (*TokenResponse, error) {
@@ -302,7 +367,7 @@ func (s *IdpService) handleRefreshTokenGrant(ctx context.Context, client *IdpCli
 }
 
 func (s *IdpService) handleClientCredentials(ctx context.Context, client *IdpClient, req TokenRequest) (*TokenResponse, error) {
-	if !s.hasGrantType(client, "client_credentials") {
+	if !s.hasGrantType(client, GrantTypeClientCredentials) {
 		return nil, accountError(ErrInvalidArgument, "客户端不支持 client_credentials")
 	}
 	if !s.verifyClientSecret(client, req.ClientSecret) {
@@ -336,15 +401,57 @@ func (s *IdpService) issueTokens(ctx context.Context, tenantID, userID, clientID
 		TokenType:   "Bearer",
 		ExpiresIn:   int64(s.m.cfg.AccessTokenTTL.Seconds()),
 	}
+	s.attachIDToken(ctx, resp, tenantID, userID, clientID, scopes)
+	return resp, nil
+}
+
+// issueUserTokens 为用户授权给第三方客户端的流程（授权码、设备授权）签发令牌：
+// 不带用户角色，client_id 与 scope 声明记录客户端及用户同意的范围，不创建会话也不签发刷新令牌。
+func (s *IdpService) issueUserTokens(ctx context.Context, tenantID, userID, clientID, scopes string) (*TokenResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.m.cfg.AccessTokenTTL)
+	scope := strings.Join(strings.FieldsFunc(scopes, func(r rune) bool { return r == ',' || r == ' ' }), " ")
+	claims := accessClaims{
+		UserID:       userID,
+		TenantID:     tenantID,
+		Username:     userID,
+		SessionID:    clientID,
+		AuthVersion:  1,
+		RolesVersion: 1,
+		Roles:        []string{},
+		ClientID:     clientID,
+		Scope:        scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newID(),
+			Subject:   userID,
+			Issuer:    s.m.cfg.JWT.Issuer,
+			Audience:  s.m.cfg.JWT.Audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token, err := s.m.auth.signClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	resp := &TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.m.cfg.AccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}
+	s.attachIDToken(ctx, resp, tenantID, userID, clientID, scopes)
+	return resp, nil
+}
 
+// attachIDToken scope 含 openid 时附带 ID Token。
+func (s *IdpService) attachIDToken(ctx context.Context, resp *TokenResponse, tenantID, userID, clientID, scopes string) {
 	if strings.Contains(scopes, "openid") {
 		idToken, err := s.signIDToken(ctx, tenantID, userID, clientID)
 		if err == nil {
 			resp.IDToken = idToken
 		}
 	}
-
-	return resp, nil
 }
 
 func (s *IdpService) signIDToken(ctx context.Context, tenantID, userID, clientID string) (string, error) {
@@ -393,8 +500,20 @@ func (s *IdpService) matchesRedirectURI(client *IdpClient, uri string) bool {
 	return false
 }
 
+// verifyClientSecret 校验客户端密钥；公开客户端没有密钥，始终校验失败。
 func (s *IdpService) verifyClientSecret(client *IdpClient, secret string) bool {
-	return client.ClientSecret == secret
+	return client.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(client.ClientSecret), []byte(secret)) == 1
+}
+
+// authenticateClient 认证客户端：登记了密钥的客户端必须提交正确的密钥，只有公开客户端可以不提交。
+func (s *IdpService) authenticateClient(client *IdpClient, secret string) error {
+	if client.Public && secret == "" {
+		return nil
+	}
+	if !s.verifyClientSecret(client, secret) {
+		return accountError(ErrInvalidCredential, "客户端密钥错误")
+	}
+	return nil
 }
 
 func generateClientID() (string, error) {
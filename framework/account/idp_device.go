@@ -0,0 +1,361 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 设备授权状态。
+const (
+	DeviceAuthorizationPending  = "pending"
+	DeviceAuthorizationApproved = "approved"
+	DeviceAuthorizationDenied   = "denied"
+	DeviceAuthorizationConsumed = "consumed"
+)
+
+// 设备码轮询令牌端点时返回的错误（RFC 8628 §3.5），作为错误消息返回给客户端。
+const (
+	DeviceErrAuthorizationPending = "authorization_pending"
+	DeviceErrSlowDown             = "slow_down"
+	DeviceErrAccessDenied         = "access_denied"
+	DeviceErrExpiredToken         = "expired_token"
+)
+
+// userCodeAlphabet 用户码字符集：去掉元音和易混淆字符，避免拼出单词或输错（RFC 8628 §6.1）。
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// DeviceAuthorization 设备授权请求（RFC 8628）。device_code 只保存哈希，
+// user_code 保存去掉分隔符的大写形式。
+type DeviceAuthorization struct {
+	ID             string     `gorm:"size:36;primaryKey" json:"id"`
+	TenantID       string     `gorm:"size:64;not null;index" json:"tenant_id"`
+	ClientID       string     `gorm:"size:128;not null;index" json:"client_id"`
+	DeviceCodeHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	UserCode       string     `gorm:"size:16;not null;uniqueIndex" json:"user_code"`
+	Scopes         string     `gorm:"size:255" json:"scopes"`
+	Status         string     `gorm:"size:20;not null;index" json:"status"`
+	UserID         string     `gorm:"size:36;index" json:"user_id,omitempty"`
+	Interval       int        `gorm:"not null" json:"interval"`
+	LastPolledAt   *time.Time `json:"last_polled_at,omitempty"`
+	ExpiresAt      time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (DeviceAuthorization) TableName() string { return "acct_device_authorizations" }
+
+// DeviceAuthorizationRequest 设备授权端点请求。
+type DeviceAuthorizationRequest struct {
+	ClientID string
+	// ClientSecret 登记了密钥的客户端必须提交；只有登记为公开客户端（IdpClientRequest.Public）的可以省略。
+	ClientSecret string
+	// Scope 空格分隔，为空时使用客户端登记的全部 scope。
+	Scope string
+	// VerificationURI 未配置 Account.IdP.DeviceVerificationURI 时使用的验证页地址。
+	VerificationURI string
+}
+
+// DeviceAuthorizationResponse 设备授权端点响应（RFC 8628 §3.2）。
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceVerificationInfo 验证页展示给用户确认的授权信息。
+type DeviceVerificationInfo struct {
+	UserCode   string    `json:"user_code"`
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// StartDeviceAuthorization 设备授权流程第一步：为输入受限的设备（CLI、电视看板）
+// 生成 device_code 与 user_code。设备展示 user_code 与验证地址，然后按 interval 轮询令牌端点。
+func (s *IdpService) StartDeviceAuthorization(ctx context.Context, req DeviceAuthorizationRequest) (*DeviceAuthorizationResponse, error) {
+	client, err := s.loadClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "客户端不存在")
+	}
+	if client.Status != "enabled" {
+		return nil, accountError(ErrInvalidArgument, "客户端已禁用")
+	}
+	if !s.hasGrantType(client, GrantTypeDeviceCode) {
+		return nil, accountError(ErrInvalidArgument, "客户端不支持设备授权流程")
+	}
+	if err := s.authenticateClient(client, req.ClientSecret); err != nil {
+		return nil, err
+	}
+	scopes, err := s.grantedScopes(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+	verificationURI := s.m.cfg.IdP.DeviceVerificationURI
+	if verificationURI == "" {
+		verificationURI = req.VerificationURI
+	}
+	if verificationURI == "" {
+		return nil, accountError(ErrInternal, "未配置设备验证页地址")
+	}
+
+	deviceCode, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	interval := int(s.m.cfg.IdP.DevicePollInterval / time.Second)
+	if interval < 1 {
+		interval = 1
+	}
+	row := &DeviceAuthorization{
+		ID:             newID(),
+		TenantID:       client.TenantID,
+		ClientID:       client.ClientID,
+		DeviceCodeHash: tokenHash(deviceCode),
+		Scopes:         scopes,
+		Status:         DeviceAuthorizationPending,
+		Interval:       interval,
+		ExpiresAt:      time.Now().Add(s.m.cfg.IdP.DeviceCodeTTL),
+	}
+	// user_code 熵较低，唯一索引冲突时重新生成。
+	for attempt := 0; ; attempt++ {
+		if row.UserCode, err = generateUserCode(); err != nil {
+			return nil, err
+		}
+		err = s.m.db.WithContext(ctx).Create(row).Error
+		if err == nil {
+			break
+		}
+		if attempt == 2 {
+			recordDBError(ctx)
+			return nil, err
+		}
+	}
+
+	userCode := formatUserCode(row.UserCode)
+	sep := "?"
+	if strings.Contains(verificationURI, "?") {
+		sep = "&"
+	}
+	s.m.audit(ctx, client.TenantID, "", "device_authorization_start", "success", "client: "+client.ClientID, "", "")
+	return &DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + sep + "user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int64(s.m.cfg.IdP.DeviceCodeTTL.Seconds()),
+		Interval:                interval,
+	}, nil
+}
+
+// LookupDeviceCode 验证页根据用户输入的 user_code 查询待确认的授权，展示客户端名称与 scope。
+func (s *IdpService) LookupDeviceCode(ctx context.Context, principal *Principal, userCode string) (*DeviceVerificationInfo, error) {
+	row, err := s.pendingDeviceAuthorization(ctx, principal, userCode)
+	if err != nil {
+		return nil, err
+	}
+	name := row.ClientID
+	if client, err := s.loadClient(ctx, row.ClientID); err == nil {
+		name = client.Name
+	}
+	return &DeviceVerificationInfo{
+		UserCode:   formatUserCode(row.UserCode),
+		ClientID:   row.ClientID,
+		ClientName: name,
+		Scopes:     splitScopes(row.Scopes),
+		ExpiresAt:  row.ExpiresAt,
+	}, nil
+}
+
+// ConfirmDeviceCode 当前登录用户批准或拒绝设备授权。每个 user_code 只能确认一次；
+// 批准后设备下一次轮询即取得令牌。代操作与委托令牌不能代替用户批准。
+func (s *IdpService) ConfirmDeviceCode(ctx context.Context, principal *Principal, userCode string, approve bool) error {
+	if principal == nil {
+		return accountError(ErrInvalidToken, "未认证")
+	}
+	if principal.Impersonated || principal.ClientID != "" {
+		return accountError(ErrPermissionDenied, "当前会话不能批准设备授权")
+	}
+	row, err := s.pendingDeviceAuthorization(ctx, principal, userCode)
+	if err != nil {
+		return err
+	}
+	status, event := DeviceAuthorizationDenied, "device_authorization_deny"
+	if approve {
+		if err := s.m.tenantSvc.checkIdPConnection(ctx, principal.TenantID, row.ClientID); err != nil {
+			return err
+		}
+		status, event = DeviceAuthorizationApproved, "device_authorization_approve"
+	}
+	res := s.m.db.WithContext(ctx).Model(&DeviceAuthorization{}).
+		Where("id = ? AND status = ? AND expires_at > ?", row.ID, DeviceAuthorizationPending, time.Now()).
+		Updates(map[string]any{"status": status, "user_id": principal.UserID})
+	if res.Error != nil {
+		recordDBError(ctx)
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return accountError(ErrInvalidArgument, "用户码无效或已过期")
+	}
+	s.m.audit(ctx, row.TenantID, principal.UserID, event, "success", "client: "+row.ClientID, "", "")
+	if !approve {
+		return nil
+	}
+	return s.recordAuthorizedApp(ctx, row.TenantID, principal.UserID, row.ClientID, row.Scopes)
+}
+
+func (s *IdpService) pendingDeviceAuthorization(ctx context.Context, principal *Principal, userCode string) (*DeviceAuthorization, error) {
+	if principal == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	code := normalizeUserCode(userCode)
+	if len(code) != userCodeLength {
+		return nil, accountError(ErrInvalidArgument, "用户码无效或已过期")
+	}
+	var row DeviceAuthorization
+	err := s.m.db.WithContext(ctx).
+		Where("user_code = ? AND tenant_id = ? AND status = ? AND expires_at > ?",
+			code, principal.TenantID, DeviceAuthorizationPending, time.Now()).
+		First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, accountError(ErrInvalidArgument, "用户码无效或已过期")
+	}
+	if err != nil {
+		recordDBError(ctx)
+		return nil, err
+	}
+	return &row, nil
+}
+
+// handleDeviceCodeGrant 设备轮询令牌端点。批准后与授权码流程一样签发客户端令牌：
+// 只携带用户同意的 scope 与 client_id，不带用户角色，也不签发刷新令牌；令牌过期后设备重新发起授权。
+func (s *IdpService) handleDeviceCodeGrant(ctx context.Context, client *IdpClient, req TokenRequest) (*TokenResponse, error) {
+	if !s.hasGrantType(client, GrantTypeDeviceCode) {
+		return nil, accountError(ErrInvalidArgument, "客户端不支持设备授权流程")
+	}
+	if err := s.authenticateClient(client, req.ClientSecret); err != nil {
+		return nil, err
+	}
+	if req.DeviceCode == "" {
+		return nil, accountError(ErrInvalidArgument, "device_code 不能为空")
+	}
+	var row DeviceAuthorization
+	if err := s.m.db.WithContext(ctx).Where("device_code_hash = ?", tokenHash(req.DeviceCode)).First(&row).Error; err != nil {
+		return nil, accountError(ErrInvalidArgument, "设备码无效")
+	}
+	if row.ClientID != client.ClientID {
+		return nil, accountError(ErrInvalidArgument, "设备码与客户端不匹配")
+	}
+	now := time.Now()
+	if row.ExpiresAt.Before(now) {
+		return nil, accountError(ErrExpiredToken, DeviceErrExpiredToken)
+	}
+
+	switch row.Status {
+	case DeviceAuthorizationPending:
+		updates := map[string]any{"last_polled_at": now}
+		tooFast := row.LastPolledAt != nil && now.Sub(*row.LastPolledAt) < time.Duration(row.Interval)*time.Second
+		if tooFast {
+			updates["interval"] = row.Interval + 5
+		}
+		if err := s.m.db.WithContext(ctx).Model(&DeviceAuthorization{}).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+			recordDBError(ctx)
+			return nil, err
+		}
+		if tooFast {
+			return nil, accountError(ErrRateLimited, DeviceErrSlowDown)
+		}
+		return nil, accountError(ErrInvalidArgument, DeviceErrAuthorizationPending)
+	case DeviceAuthorizationDenied:
+		return nil, accountError(ErrPermissionDenied, DeviceErrAccessDenied)
+	case DeviceAuthorizationApproved:
+	default:
+		return nil, accountError(ErrInvalidArgument, "设备码已使用")
+	}
+
+	var resp *TokenResponse
+	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&DeviceAuthorization{}).
+			Where("id = ? AND status = ?", row.ID, DeviceAuthorizationApproved).
+			Update("status", DeviceAuthorizationConsumed)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return accountError(ErrInvalidArgument, "设备码已使用")
+		}
+		var user User
+		if err := tx.Where("id = ? AND tenant_id = ?", row.UserID, row.TenantID).First(&user).Error; err != nil {
+			return accountError(ErrInvalidArgument, "用户不存在")
+		}
+		if user.Status != UserStatusNormal {
+			return accountError(ErrPermissionDenied, DeviceErrAccessDenied)
+		}
+		var err error
+		resp, err = s.issueUserTokens(ctx, row.TenantID, row.UserID, client.ClientID, row.Scopes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.m.audit(ctx, row.TenantID, row.UserID, "device_authorization_token", "success", "client: "+client.ClientID, req.IP, req.UserAgent)
+	return resp, nil
+}
+
+// grantedScopes 校验空格分隔的 scope 都在客户端登记范围内，为空时返回客户端全部 scope。
+func (s *IdpService) grantedScopes(client *IdpClient, scope string) (string, error) {
+	allowed := splitScopes(client.Scopes)
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return joinScopes(allowed), nil
+	}
+	for _, sc := range requested {
+		if !contains(allowed, sc) {
+			return "", accountError(ErrInvalidArgument, "不允许的 scope: "+sc)
+		}
+	}
+	return joinScopes(requested), nil
+}
+
+func generateUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	b := make([]byte, userCodeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// formatUserCode 以 XXXX-XXXX 形式展示用户码。
+func formatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+// normalizeUserCode 去掉用户输入中的分隔符与空白并转为大写。
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
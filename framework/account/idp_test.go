@@ -0,0 +1,267 @@
+package account
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDeviceAuthorizationGrant(t *testing.T) {
+	m, bob := newPolicyTestManager(t)
+	ctx := context.Background()
+
+	if _, err := m.IdP().RegisterClient(ctx, IdpClientRequest{Name: "bad", AllowedGrantTypes: []string{"password"}}); err == nil {
+		t.Fatal("expected unsupported grant type to be rejected")
+	}
+	web, err := m.IdP().RegisterClient(ctx, IdpClientRequest{Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IdP().StartDeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: web.ClientID}); err == nil {
+		t.Fatal("expected client without device_code grant to be rejected")
+	}
+	if _, err := m.IdP().RegisterClient(ctx, IdpClientRequest{Name: "spa", Public: true}); err == nil {
+		t.Fatal("expected public client without device_code grant to be rejected")
+	}
+	cli, err := m.IdP().RegisterClient(ctx, IdpClientRequest{Name: "ops-cli", AllowedGrantTypes: []string{GrantTypeDeviceCode}, Scopes: []string{"openid", "profile"}, Public: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cli.ClientSecret != "" || !cli.Public {
+		t.Fatalf("public client should have no secret: %+v", cli)
+	}
+	if _, err := m.IdP().StartDeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: cli.ClientID}); err == nil {
+		t.Fatal("expected missing verification uri to be rejected")
+	}
+	m.cfg.IdP.DeviceVerificationURI = "https://app.example.com/device"
+	if _, err := m.IdP().StartDeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: cli.ClientID, Scope: "openid admin"}); err == nil {
+		t.Fatal("expected unregistered scope to be rejected")
+	}
+
+	start, err := m.IdP().StartDeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: cli.ClientID, Scope: "openid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(start.UserCode) != 9 || start.VerificationURIComplete != start.VerificationURI+"?user_code="+start.UserCode || start.Interval != 5 {
+		t.Fatalf("unexpected device authorization: %+v", start)
+	}
+	poll := TokenRequest{GrantType: GrantTypeDeviceCode, ClientID: cli.ClientID, DeviceCode: start.DeviceCode}
+	if _, err := m.IdP().Token(ctx, poll); err == nil || !strings.Contains(err.Error(), DeviceErrAuthorizationPending) {
+		t.Fatalf("expected authorization_pending, got %v", err)
+	}
+	if _, err := m.IdP().Token(ctx, poll); err == nil || !strings.Contains(err.Error(), DeviceErrSlowDown) {
+		t.Fatalf("expected slow_down, got %v", err)
+	}
+
+	typed := strings.ToLower(strings.ReplaceAll(start.UserCode, "-", " "))
+	info, err := m.IdP().LookupDeviceCode(ctx, bob, typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ClientName != "ops-cli" || len(info.Scopes) != 1 || info.Scopes[0] != "openid" {
+		t.Fatalf("unexpected verification info: %+v", info)
+	}
+	if _, err := m.IdP().LookupDeviceCode(ctx, &Principal{UserID: bob.UserID, TenantID: "other"}, typed); err == nil {
+		t.Fatal("expected user code from another tenant to be hidden")
+	}
+	impersonated := *bob
+	impersonated.Impersonated = true
+	if err := m.IdP().ConfirmDeviceCode(ctx, &impersonated, typed, true); err == nil {
+		t.Fatal("expected impersonated session to be unable to approve")
+	}
+	if err := m.IdP().ConfirmDeviceCode(ctx, bob, typed, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.IdP().ConfirmDeviceCode(ctx, bob, typed, false); err == nil {
+		t.Fatal("expected user code to be confirmed only once")
+	}
+
+	tokens, err := m.IdP().Token(ctx, poll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken != "" || tokens.IDToken == "" || tokens.Scope != "openid" {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+	// 设备令牌与授权码令牌一样只代表客户端获得的授权，不继承用户角色
+	claims, err := m.auth.parseAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != bob.UserID || claims.ClientID != cli.ClientID || claims.Scope != "openid" || len(claims.Roles) != 0 {
+		t.Fatalf("device token claims = %+v", claims)
+	}
+	if _, err := m.IdP().Token(ctx, poll); err == nil {
+		t.Fatal("expected device code to be single-use")
+	}
+
+	denied, err := m.IdP().StartDeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: cli.ClientID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.IdP().ConfirmDeviceCode(ctx, bob, denied.UserCode, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IdP().Token(ctx, TokenRequest{GrantType: GrantTypeDeviceCode, ClientID: cli.ClientID, DeviceCode: denied.DeviceCode}); err == nil || !strings.Contains(err.Error(), DeviceErrAccessDenied) {
+		t.Fatalf("expected access_denied, got %v", err)
+	}
+	if _, err := m.IdP().Token(ctx, TokenRequest{GrantType: GrantTypeDeviceCode, ClientID: web.ClientID, DeviceCode: denied.DeviceCode}); err == nil {
+		t.Fatal("expected device code to be bound to its client")
+	}
+
+	// 登记了密钥的客户端不能靠省略密钥跳过认证
+	tv, err := m.IdP().RegisterClient(ctx, IdpClientRequest{Name: "tv", AllowedGrantTypes: []string{GrantTypeDeviceCode}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IdP().StartDeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: tv.ClientID}); err == nil {
+		t.Fatal("expected confidential client without secret to be rejected")
+	}
+	tvStart, err := m.IdP().StartDeviceAuthorization(ctx, DeviceAuthorizationRequest{ClientID: tv.ClientID, ClientSecret: tv.ClientSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IdP().Token(ctx, TokenRequest{GrantType: GrantTypeDeviceCode, ClientID: tv.ClientID, DeviceCode: tvStart.DeviceCode}); err == nil || strings.Contains(err.Error(), DeviceErrAuthorizationPending) {
+		t.Fatalf("expected confidential client poll without secret to be rejected, got %v", err)
+	}
+	if _, err := m.IdP().Token(ctx, TokenRequest{GrantType: GrantTypeDeviceCode, ClientID: tv.ClientID, ClientSecret: tv.ClientSecret, DeviceCode: tvStart.DeviceCode}); err == nil || !strings.Contains(err.Error(), DeviceErrAuthorizationPending) {
+		t.Fatalf("expected authorization_pending, got %v", err)
+	}
+}
+
+func TestTokenExchange(t *testing.T) {
+	m, bob := newPolicyTestManager(t)
+	ctx := context.Background()
+
+	var user User
+	if err := m.db.Where("id = ?", bob.UserID).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	session, err := m.auth.issueTokens(ctx, m.db, &user, []string{"accountant"}, []string{AMRPassword}, "", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway, err := m.IdP().RegisterClient(ctx, IdpClientRequest{Name: "gateway", AllowedGrantTypes: []string{GrantTypeTokenExchange}, Scopes: []string{"orders.read", "orders.write"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, err := m.IdP().RegisterClient(ctx, IdpClientRequest{Name: "orders", AllowedGrantTypes: []string{GrantTypeClientCredentials}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actor, err := m.IdP().Token(ctx, TokenRequest{GrantType: GrantTypeClientCredentials, ClientID: orders.ClientID, ClientSecret: orders.ClientSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IdP().Token(ctx, TokenRequest{GrantType: GrantTypeTokenExchange, ClientID: orders.ClientID, ClientSecret: orders.ClientSecret, SubjectToken: session.AccessToken}); err == nil {
+		t.Fatal("expected client without token-exchange grant to be rejected")
+	}
+
+	exchange := TokenRequest{
+		GrantType:    GrantTypeTokenExchange,
+		ClientID:     gateway.ClientID,
+		ClientSecret: gateway.ClientSecret,
+		SubjectToken: session.AccessToken,
+		ActorToken:   actor.AccessToken,
+		Scope:        "orders.read",
+	}
+	bad := exchange
+	bad.ClientSecret = "wrong"
+	if _, err := m.IdP().Token(ctx, bad); err == nil {
+		t.Fatal("expected wrong client secret to be rejected")
+	}
+	bad = exchange
+	bad.Audience = []string{"https://billing.internal"}
+	if _, err := m.IdP().Token(ctx, bad); err == nil {
+		t.Fatal("expected unlisted audience to be rejected")
+	}
+	m.cfg.IdP.TokenExchangeAudiences = []string{"https://billing.internal"}
+	delegated, err := m.IdP().Token(ctx, bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delegated.IssuedTokenType != TokenTypeAccessToken || delegated.Scope != "orders.read" || delegated.RefreshToken != "" {
+		t.Fatalf("unexpected exchange response: %+v", delegated)
+	}
+	p, err := m.Auth().Validate(ctx, delegated.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UserID != bob.UserID || p.ClientID != gateway.ClientID || len(p.Delegation) != 1 || p.Delegation[0] != "svc:"+orders.ClientID {
+		t.Fatalf("unexpected delegated principal: %+v", p)
+	}
+	if _, err := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{}); err == nil {
+		t.Fatal("expected delegated token to be refused for step-up operations")
+	}
+
+	bad = exchange
+	bad.ActorToken = delegated.AccessToken
+	if _, err := m.IdP().Token(ctx, bad); err == nil {
+		t.Fatal("expected delegated token to be rejected as actor_token")
+	}
+
+	// 被委托的服务继续向下游交换，委托链向内嵌套。
+	exchange.SubjectToken = delegated.AccessToken
+	chained, err := m.IdP().Token(ctx, exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err = m.Auth().Validate(ctx, chained.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Delegation) != 2 {
+		t.Fatalf("expected two actors in the chain, got %+v", p.Delegation)
+	}
+	m.cfg.IdP.TokenExchangeMaxDepth = 2
+	exchange.SubjectToken = chained.AccessToken
+	if _, err := m.IdP().Token(ctx, exchange); err == nil {
+		t.Fatal("expected delegation chain longer than the limit to be rejected")
+	}
+
+	// 不带 actor_token 时沿用原委托链。
+	exchange.ActorToken = ""
+	exchange.SubjectToken = session.AccessToken
+	plain, err := m.IdP().Token(ctx, exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err = m.Auth().Validate(ctx, plain.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if p.ClientID != gateway.ClientID || len(p.Delegation) != 0 {
+		t.Fatalf("unexpected exchanged principal: %+v", p)
+	}
+
+	// 交换后的令牌只能使用授予的 scope：bob 本身有 invoice:approve，但 orders.read 令牌不能用它。
+	approve := AuthzRequest{Subject: p, Permission: "invoice:approve"}
+	if d, err := m.Authorizer().Check(ctx, approve); err != nil || d.Allowed {
+		t.Fatalf("expected downscoped token to be denied, got %+v %v", d, err)
+	}
+	if perms, err := m.Authorizer().GetEffectivePermissionsForPrincipal(ctx, p); err != nil || len(perms) != 0 {
+		t.Fatalf("expected no effective permissions outside granted scope, got %v %v", perms, err)
+	}
+	billing, err := m.IdP().RegisterClient(ctx, IdpClientRequest{Name: "billing", AllowedGrantTypes: []string{GrantTypeTokenExchange}, Scopes: []string{"invoice:approve"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scoped, err := m.IdP().Token(ctx, TokenRequest{GrantType: GrantTypeTokenExchange, ClientID: billing.ClientID, ClientSecret: billing.ClientSecret, SubjectToken: session.AccessToken, Scope: "invoice:approve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approve.Subject, err = m.Auth().Validate(ctx, scoped.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := m.Authorizer().Check(ctx, approve); err != nil || !d.Allowed {
+		t.Fatalf("expected granted scope to be usable, got %+v %v", d, err)
+	}
+
+	if err := m.db.Model(&Session{}).Where("user_id = ?", bob.UserID).Update("status", SessionRevoked).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Auth().Validate(ctx, delegated.AccessToken); err == nil {
+		t.Fatal("expected exchanged token to die with the subject session")
+	}
+	if _, err := m.IdP().Token(ctx, exchange); err == nil {
+		t.Fatal("expected revoked subject token to be rejected")
+	}
+}
@@ -0,0 +1,151 @@
+package account
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 令牌交换（RFC 8693 §3）使用的令牌类型标识。
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// handleTokenExchange 令牌交换（RFC 8693）：微服务用收到的用户访问令牌（subject_token）
+// 换取调用下游服务的新令牌。
+//
+//   - 提交 actor_token 时为委托：新令牌的 act 声明记录当前操作者，subject_token 已有的委托链嵌套在其中；
+//   - 不提交 actor_token 时为模拟：新令牌沿用 subject_token 的委托链，不增加操作者。
+//
+// 新令牌与 subject_token 共享会话（sid），会话吊销、用户禁用或角色变更后一并失效；
+// 有效期不超过 subject_token，且带有 client_id 声明，不能执行 step-up 敏感操作或发起代操作。
+func (s *IdpService) handleTokenExchange(ctx context.Context, client *IdpClient, req TokenRequest) (*TokenResponse, error) {
+	if !s.hasGrantType(client, GrantTypeTokenExchange) {
+		return nil, accountError(ErrInvalidArgument, "客户端不支持令牌交换")
+	}
+	if !s.verifyClientSecret(client, req.ClientSecret) {
+		return nil, accountError(ErrInvalidCredential, "客户端密钥错误")
+	}
+	if req.SubjectToken == "" {
+		return nil, accountError(ErrInvalidArgument, "subject_token 不能为空")
+	}
+	if !isExchangeableTokenType(req.SubjectTokenType) || !isExchangeableTokenType(req.ActorTokenType) {
+		return nil, accountError(ErrInvalidArgument, "不支持的令牌类型")
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		return nil, accountError(ErrInvalidArgument, "不支持的 requested_token_type")
+	}
+
+	// Validate 负责会话、用户状态、版本与黑名单校验，这里再取原始声明以继承委托链与认证上下文。
+	if _, err := s.m.auth.Validate(ctx, req.SubjectToken); err != nil {
+		return nil, err
+	}
+	subject, err := s.m.auth.parseAccessToken(req.SubjectToken)
+	if err != nil {
+		return nil, accountError(ErrInvalidToken, "subject_token 无效")
+	}
+	if subject.TenantID != client.TenantID {
+		return nil, accountError(ErrPermissionDenied, "subject_token 与客户端不属于同一租户")
+	}
+
+	act := subject.Actor
+	if req.ActorToken != "" {
+		actor, err := s.validateActorToken(ctx, req.ActorToken)
+		if err != nil {
+			return nil, err
+		}
+		if actor.TenantID != subject.TenantID {
+			return nil, accountError(ErrPermissionDenied, "actor_token 与 subject_token 不属于同一租户")
+		}
+		act = &actorClaim{Subject: actor.UserID, Username: actor.Username, ClientID: actor.ClientID, Actor: subject.Actor}
+	}
+	if len(act.chain()) > s.m.cfg.IdP.TokenExchangeMaxDepth {
+		return nil, accountError(ErrPermissionDenied, "委托链过长")
+	}
+
+	audience := append([]string{}, s.m.cfg.JWT.Audience...)
+	for _, aud := range req.Audience {
+		if !contains(s.m.cfg.IdP.TokenExchangeAudiences, aud) {
+			return nil, accountError(ErrInvalidArgument, "不允许的 audience: "+aud)
+		}
+		if !contains(audience, aud) {
+			audience = append(audience, aud)
+		}
+	}
+	scopes, err := s.grantedScopes(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+	scope := strings.Join(splitScopes(scopes), " ")
+
+	now := time.Now()
+	expiresAt := now.Add(s.m.cfg.AccessTokenTTL)
+	if subject.ExpiresAt != nil && subject.ExpiresAt.Time.Before(expiresAt) {
+		expiresAt = subject.ExpiresAt.Time
+	}
+	claims := *subject
+	claims.Actor = act
+	claims.ClientID = client.ClientID
+	claims.Scope = scope
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        newID(),
+		Subject:   subject.Subject,
+		Issuer:    s.m.cfg.JWT.Issuer,
+		Audience:  audience,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	token, err := s.m.auth.signClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	auditCtx := ctx
+	if act != nil {
+		auditCtx = withActor(ctx, act.Subject)
+	}
+	s.m.audit(auditCtx, subject.TenantID, subject.UserID, "token_exchange", "success", "client: "+client.ClientID, req.IP, req.UserAgent)
+	return &TokenResponse{
+		AccessToken:     token,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(time.Until(expiresAt).Seconds()),
+		Scope:           scope,
+		IssuedTokenType: TokenTypeAccessToken,
+	}, nil
+}
+
+// validateActorToken 校验 actor_token 并返回其声明。用户令牌走 Validate 的完整校验；
+// client_credentials 签发的服务令牌（svc:<client_id>）没有用户与会话，改为确认签发它的客户端仍然启用。
+// 与发起代操作一样，代操作或委托取得的令牌不能再充当操作者。
+func (s *IdpService) validateActorToken(ctx context.Context, token string) (*accessClaims, error) {
+	claims, err := s.m.auth.parseAccessToken(token)
+	if err != nil {
+		return nil, accountError(ErrInvalidToken, "actor_token 无效")
+	}
+	if claims.Impersonated || claims.Actor != nil || claims.ClientID != "" {
+		return nil, accountError(ErrPermissionDenied, "actor_token 不能是代操作或委托令牌")
+	}
+	if !strings.HasPrefix(claims.UserID, "svc:") {
+		if _, err := s.m.auth.Validate(ctx, token); err != nil {
+			return nil, err
+		}
+		return claims, nil
+	}
+	if s.m.cfg.EnableAccessTokenDenylistCheck {
+		if denied, _ := s.m.auth.isDenied(ctx, claims.ID); denied {
+			return nil, accountError(ErrRevokedToken, "actor_token 已吊销")
+		}
+	}
+	client, err := s.loadClient(ctx, strings.TrimPrefix(claims.UserID, "svc:"))
+	if err != nil || client.Status != "enabled" || client.TenantID != claims.TenantID {
+		return nil, accountError(ErrInvalidToken, "actor_token 对应的客户端不可用")
+	}
+	return claims, nil
+}
+
+func isExchangeableTokenType(tokenType string) bool {
+	return tokenType == "" || tokenType == TokenTypeAccessToken || tokenType == TokenTypeJWT
+}
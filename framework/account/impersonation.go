@@ -0,0 +1,328 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// PermissionImpersonate 发起代操作所需的权限码。
+const PermissionImpersonate = "admin.user.impersonate"
+
+// impersonationDeviceID 代操作会话的设备标识，便于在会话列表中识别。
+const impersonationDeviceID = "impersonation"
+
+// ImpersonateRequest 发起代操作的请求参数。
+type ImpersonateRequest struct {
+	TargetUserID string
+	// Reason 代操作原因（工单号等），必填，写入审计日志。
+	Reason string
+	// Scopes 本次可使用的权限码，须落在 ImpersonationConfig.AllowedScopes 范围内；为空使用全部 AllowedScopes。
+	Scopes []string
+	// TTL 本次会话时长，为 0 或超过 ImpersonationConfig.TTL 时使用配置值。
+	TTL       time.Duration
+	IP        string
+	UserAgent string
+}
+
+// ImpersonationResult 代操作令牌。代操作会话不签发刷新令牌，到期后需要重新发起。
+type ImpersonationResult struct {
+	User        *UserInfo `json:"user"`
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	SessionID   string    `json:"session_id"`
+	ActorID     string    `json:"actor_id"`
+	Scopes      []string  `json:"scopes"`
+}
+
+// ImpersonationInfo 进行中的代操作会话。
+type ImpersonationInfo struct {
+	SessionID string    `json:"session_id"`
+	TenantID  string    `json:"tenant_id"`
+	UserID    string    `json:"user_id"`
+	ActorID   string    `json:"actor_id"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type actorContextKey struct{}
+
+// withActor 把代操作者写入 ctx，此后经该 ctx 写入的审计日志都带上 ActorID。
+func withActor(ctx context.Context, actorID string) context.Context {
+	if actorID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, actorContextKey{}, actorID)
+}
+
+// actorFromContext 返回 ctx 中的代操作者，没有时为空。
+func actorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actorID, _ := ctx.Value(actorContextKey{}).(string)
+	return actorID
+}
+
+// Impersonate 以目标用户身份签发短时代操作令牌。
+// 操作者须持有 admin.user.impersonate 权限（按 Authorizer 规则求值，目标用户作为 user 资源），
+// 且自身不能是代操作、API Token 或令牌交换取得的会话；不能代操作自己、其他租户的用户（platform_admin 除外）
+// 或持有 platform_admin / tenant_owner 的用户。令牌携带 imp / act 声明，
+// 会话期间可用权限受 scopes 限制、敏感操作一律拒绝，全部审计日志带有操作者 ID。
+func (s *AdminService) Impersonate(ctx context.Context, actor *Principal, req ImpersonateRequest) (*ImpersonationResult, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.admin.impersonate")
+	defer span.End()
+
+	if actor == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	if actor.Impersonated || actor.APITokenID != "" || actor.ClientID != "" {
+		return nil, accountError(ErrPermissionDenied, "当前会话不能发起代操作")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, accountError(ErrInvalidArgument, "代操作原因不能为空")
+	}
+	if req.TargetUserID == "" || req.TargetUserID == actor.UserID {
+		return nil, accountError(ErrInvalidArgument, "无效的代操作目标")
+	}
+	decision, err := s.m.authorizer.Check(ctx, AuthzRequest{
+		Subject: actor, Permission: PermissionImpersonate, ResourceType: "user", ResourceID: req.TargetUserID,
+		Context: &AuthzContext{ClientIP: req.IP, UserAgent: req.UserAgent},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !decision.Allowed {
+		s.m.audit(ctx, actor.TenantID, actor.UserID, "impersonation_start", "failed", "permission denied, target: "+req.TargetUserID, req.IP, req.UserAgent)
+		return nil, accountError(ErrPermissionDenied, "无代操作权限")
+	}
+	if s.m.cfg.Impersonation.RequireStepUp {
+		if err := s.m.auth.RequireStepUp(ctx, actor); err != nil {
+			return nil, err
+		}
+	}
+	scopes, err := s.impersonationScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	ttl := s.m.cfg.Impersonation.TTL
+	if req.TTL > 0 && req.TTL < ttl {
+		ttl = req.TTL
+	}
+
+	var target User
+	if err := s.m.db.WithContext(ctx).Where("id = ?", req.TargetUserID).First(&target).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, accountError(ErrInvalidArgument, "用户不存在")
+		}
+		return nil, err
+	}
+	if target.TenantID != actor.TenantID && !contains(actor.Roles, "platform_admin") {
+		return nil, accountError(ErrPermissionDenied, "不能代操作其他租户的用户")
+	}
+	if target.Status != UserStatusNormal {
+		return nil, accountError(ErrInvalidArgument, "用户不可用")
+	}
+	roles, err := s.m.auth.loadRoleCodes(ctx, s.m.db, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	if contains(roles, "platform_admin") || contains(roles, "tenant_owner") {
+		s.m.audit(ctx, actor.TenantID, actor.UserID, "impersonation_start", "failed", "privileged target: "+target.ID, req.IP, req.UserAgent)
+		return nil, accountError(ErrPermissionDenied, "不能代操作系统管理员")
+	}
+
+	now := time.Now()
+	session := Session{
+		ID:            newID(),
+		TenantID:      target.TenantID,
+		UserID:        target.ID,
+		FamilyID:      newID(),
+		DeviceID:      impersonationDeviceID,
+		IP:            req.IP,
+		UserAgentHash: hashUserAgent(req.UserAgent),
+		Status:        SessionActive,
+		ExpiresAt:     now.Add(ttl),
+		ActorID:       actor.UserID,
+	}
+	if err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return fmt.Errorf("create impersonation session: %w", err)
+		}
+		return emitOutbox(tx, EventImpersonationStarted, target.ID, map[string]any{
+			"tenant_id":  target.TenantID,
+			"user_id":    target.ID,
+			"actor_id":   actor.UserID,
+			"session_id": session.ID,
+			"scopes":     scopes,
+			"reason":     reason,
+			"expires_at": session.ExpiresAt,
+		})
+	}); err != nil {
+		recordDBError(ctx)
+		return nil, err
+	}
+
+	claims := accessClaims{
+		UserID:        target.ID,
+		TenantID:      target.TenantID,
+		Username:      target.Username,
+		SessionID:     session.ID,
+		AuthVersion:   target.AuthVersion,
+		RolesVersion:  target.RolesVersion,
+		PhoneVerified: target.PhoneVerifiedAt != nil,
+		Roles:         roles,
+		Impersonated:  true,
+		Actor:         &actorClaim{Subject: actor.UserID, Username: actor.Username},
+		Scopes:        scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newID(),
+			Subject:   target.ID,
+			Issuer:    s.m.cfg.JWT.Issuer,
+			Audience:  s.m.cfg.JWT.Audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+		},
+	}
+	token, err := s.m.auth.signClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	s.m.audit(withActor(ctx, actor.UserID), target.TenantID, target.ID, "impersonation_start", "success",
+		truncateString(fmt.Sprintf("session: %s, scopes: %s, reason: %s", session.ID, strings.Join(scopes, ","), reason), 255), req.IP, req.UserAgent)
+	return &ImpersonationResult{
+		User:        target.toInfo(roles, nil),
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresAt:   session.ExpiresAt,
+		SessionID:   session.ID,
+		ActorID:     actor.UserID,
+		Scopes:      scopes,
+	}, nil
+}
+
+// impersonationScopes 解析本次代操作可用的权限码。
+func (s *AdminService) impersonationScopes(requested []string) ([]string, error) {
+	allowed := s.m.cfg.Impersonation.AllowedScopes
+	if len(requested) == 0 {
+		if len(allowed) == 0 {
+			return nil, accountError(ErrInvalidArgument, "须指定代操作可用的权限范围")
+		}
+		return allowed, nil
+	}
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if len(allowed) > 0 && !scopeCovered(allowed, scope) {
+			return nil, accountError(ErrInvalidArgument, "超出允许的代操作权限范围: "+scope)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, accountError(ErrInvalidArgument, "须指定代操作可用的权限范围")
+	}
+	return scopes, nil
+}
+
+// scopeCovered 判断 scope（可能带通配）是否完全落在 allowed 范围内。
+func scopeCovered(allowed []string, scope string) bool {
+	if !strings.HasSuffix(scope, "*") {
+		return apiTokenAllowsPermission(allowed, scope)
+	}
+	for _, a := range allowed {
+		if a == "*" || a == scope {
+			return true
+		}
+		if strings.HasSuffix(a, ":*") && strings.HasPrefix(scope, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// EndImpersonation 结束代操作会话。caller 可以是发起者本人、代操作会话自身，
+// 或持有 admin.user.impersonate 权限的同租户管理员。
+func (s *AdminService) EndImpersonation(ctx context.Context, caller *Principal, sessionID string) error {
+	ctx, span := s.m.tracer.Start(ctx, "account.admin.end_impersonation")
+	defer span.End()
+
+	if caller == nil {
+		return accountError(ErrInvalidToken, "未认证")
+	}
+	var session Session
+	if err := s.m.db.WithContext(ctx).Where("id = ? AND actor_id <> ''", sessionID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return accountError(ErrInvalidArgument, "代操作会话不存在")
+		}
+		return err
+	}
+	allowed := caller.SessionID == session.ID || (!caller.Impersonated && caller.UserID == session.ActorID)
+	if !allowed && !caller.Impersonated && caller.APITokenID == "" &&
+		(caller.TenantID == session.TenantID || contains(caller.Roles, "platform_admin")) {
+		decision, err := s.m.authorizer.Check(ctx, AuthzRequest{Subject: caller, Permission: PermissionImpersonate})
+		if err != nil {
+			return err
+		}
+		allowed = decision.Allowed
+	}
+	if !allowed {
+		return accountError(ErrPermissionDenied, "无权结束该代操作会话")
+	}
+	if session.Status != SessionActive {
+		return nil
+	}
+	now := time.Now()
+	if err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Session{}).Where("id = ? AND status = ?", session.ID, SessionActive).
+			Updates(map[string]any{"status": SessionRevoked, "revoked_at": now}).Error; err != nil {
+			return err
+		}
+		return emitOutbox(tx, EventImpersonationEnded, session.UserID, map[string]any{
+			"tenant_id":  session.TenantID,
+			"user_id":    session.UserID,
+			"actor_id":   session.ActorID,
+			"session_id": session.ID,
+			"ended_by":   caller.UserID,
+		})
+	}); err != nil {
+		recordDBError(ctx)
+		return err
+	}
+	s.m.auth.invalidatePrincipalCache(ctx, session.ID)
+	s.m.audit(withActor(ctx, session.ActorID), session.TenantID, session.UserID, "impersonation_end", "success",
+		fmt.Sprintf("session: %s, ended_by: %s", session.ID, caller.UserID), "", "")
+	return nil
+}
+
+// ListImpersonations 列出租户内进行中的代操作会话。
+func (s *AdminService) ListImpersonations(ctx context.Context, tenantID string) ([]ImpersonationInfo, error) {
+	var sessions []Session
+	if err := s.m.db.WithContext(ctx).
+		Where("tenant_id = ? AND actor_id <> '' AND status = ? AND expires_at > ?", s.m.tenantID(tenantID), SessionActive, time.Now()).
+		Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("list impersonations: %w", err)
+	}
+	out := make([]ImpersonationInfo, len(sessions))
+	for i, sess := range sessions {
+		out[i] = ImpersonationInfo{
+			SessionID: sess.ID,
+			TenantID:  sess.TenantID,
+			UserID:    sess.UserID,
+			ActorID:   sess.ActorID,
+			IP:        sess.IP,
+			CreatedAt: sess.CreatedAt,
+			ExpiresAt: sess.ExpiresAt,
+		}
+	}
+	return out, nil
+}
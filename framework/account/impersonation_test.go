@@ -0,0 +1,166 @@
+package account
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/xxzhwl/gaia/framework/server"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newImpersonationTestManager 创建持有 admin.user.impersonate 的 support 管理员和普通用户 bob。
+func newImpersonationTestManager(t *testing.T) (*Manager, *Principal, *User) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "impersonation.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testAuthConfig()
+	cfg.DB = db
+	cfg.Impersonation.AllowedScopes = []string{"invoice:*"}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Bootstrap(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	admin := &User{ID: newID(), TenantID: "default", Username: "support", Status: UserStatusNormal, AuthVersion: 1, RolesVersion: 1}
+	target := &User{ID: newID(), TenantID: "default", Username: "bob", Status: UserStatusNormal, AuthVersion: 1, RolesVersion: 1}
+	supportRole := &Role{ID: newID(), TenantID: "default", Code: "support", Name: "Support", Status: "enabled"}
+	accountant := &Role{ID: newID(), TenantID: "default", Code: "accountant", Name: "Accountant", Status: "enabled"}
+	impersonate := &Permission{ID: newID(), TenantID: "default", Code: PermissionImpersonate, ResourceType: "user", Action: "impersonate", Status: "enabled"}
+	approve := &Permission{ID: newID(), TenantID: "default", Code: "invoice:approve", ResourceType: "invoice", Action: "approve", Status: "enabled"}
+	profile := &Permission{ID: newID(), TenantID: "default", Code: "profile:update", ResourceType: "profile", Action: "update", Status: "enabled"}
+	for _, v := range []any{admin, target, supportRole, accountant, impersonate, approve, profile,
+		&UserRole{ID: newID(), TenantID: "default", UserID: admin.ID, RoleID: supportRole.ID, ScopeType: "tenant", ScopeID: "default"},
+		&UserRole{ID: newID(), TenantID: "default", UserID: target.ID, RoleID: accountant.ID, ScopeType: "tenant", ScopeID: "default"},
+		&RolePermission{ID: newID(), RoleID: supportRole.ID, PermissionID: impersonate.ID},
+		&RolePermission{ID: newID(), RoleID: accountant.ID, PermissionID: approve.ID},
+		&RolePermission{ID: newID(), RoleID: accountant.ID, PermissionID: profile.ID},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	actor := &Principal{UserID: admin.ID, TenantID: "default", Username: "support", AuthVersion: 1, RolesVersion: 1, Roles: []string{"support"}}
+	return m, actor, target
+}
+
+func TestImpersonationLifecycle(t *testing.T) {
+	m, actor, target := newImpersonationTestManager(t)
+	ctx := context.Background()
+
+	if _, err := m.Admin().Impersonate(ctx, actor, ImpersonateRequest{TargetUserID: target.ID}); err == nil {
+		t.Fatal("expected missing reason to be rejected")
+	}
+	if _, err := m.Admin().Impersonate(ctx, actor, ImpersonateRequest{TargetUserID: target.ID, Reason: "T-1", Scopes: []string{"profile:update"}}); err == nil {
+		t.Fatal("expected scope outside AllowedScopes to be rejected")
+	}
+	res, err := m.Admin().Impersonate(ctx, actor, ImpersonateRequest{TargetUserID: target.ID, Reason: "ticket T-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ActorID != actor.UserID || len(res.Scopes) != 1 || res.Scopes[0] != "invoice:*" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	principal, err := m.Auth().Validate(ctx, res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !principal.Impersonated || principal.UserID != target.ID || principal.ActorID != actor.UserID || principal.ActorUsername != "support" {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+
+	for perm, want := range map[string]bool{"invoice:approve": true, "profile:update": false} {
+		d, err := m.Authorizer().Check(ctx, AuthzRequest{Subject: principal, Permission: perm})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed != want {
+			t.Fatalf("%s: allowed=%v want %v (%s)", perm, d.Allowed, want, d.Reason)
+		}
+	}
+	perms, err := m.Authorizer().GetEffectivePermissionsForPrincipal(ctx, principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perms) != 1 || perms[0] != "invoice:approve" {
+		t.Fatalf("effective permissions = %v", perms)
+	}
+	if err := m.Auth().RequireStepUp(ctx, principal); err == nil {
+		t.Fatal("expected step-up to be denied for impersonated session")
+	}
+	if _, err := m.Admin().Impersonate(ctx, principal, ImpersonateRequest{TargetUserID: actor.UserID, Reason: "chain"}); err == nil {
+		t.Fatal("expected nested impersonation to be rejected")
+	}
+
+	active, err := m.Admin().ListImpersonations(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].SessionID != res.SessionID {
+		t.Fatalf("active impersonations = %+v", active)
+	}
+
+	if err := m.Admin().EndImpersonation(ctx, actor, res.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Auth().Validate(ctx, res.AccessToken); err == nil {
+		t.Fatal("expected revoked impersonation token to be rejected")
+	}
+
+	logs, err := m.Audit().Query(ctx, AuditQueryRequest{TenantID: "default", ActorID: actor.UserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := map[string]bool{}
+	for _, l := range logs.Items {
+		if l.UserID != target.ID {
+			t.Fatalf("audit entry user = %s, want %s", l.UserID, target.ID)
+		}
+		events[l.Event] = true
+	}
+	if !events["impersonation_start"] || !events["impersonation_end"] {
+		t.Fatalf("audit events = %v", events)
+	}
+}
+
+func TestImpersonationRejectsPrivilegedTarget(t *testing.T) {
+	m, actor, target := newImpersonationTestManager(t)
+	ctx := context.Background()
+	var owner Role
+	if err := m.db.Where("tenant_id = ? AND code = ?", "default", "tenant_owner").First(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := m.db.Create(&UserRole{ID: newID(), TenantID: "default", UserID: target.ID, RoleID: owner.ID, ScopeType: "tenant", ScopeID: "default"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Admin().Impersonate(ctx, actor, ImpersonateRequest{TargetUserID: target.ID, Reason: "T-2"}); err == nil {
+		t.Fatal("expected tenant_owner target to be rejected")
+	}
+	if _, err := m.Admin().Impersonate(ctx, &Principal{UserID: target.ID, TenantID: "default", RolesVersion: 1}, ImpersonateRequest{TargetUserID: actor.UserID, Reason: "T-3"}); err == nil {
+		t.Fatal("expected caller without permission to be rejected")
+	}
+}
+
+func TestPrincipalCacheIdentity(t *testing.T) {
+	var _ server.CacheIdentifier = (*Principal)(nil)
+	own := &Principal{TenantID: "default", UserID: "u-1", SessionID: "s-1"}
+	imp := &Principal{TenantID: "default", UserID: "u-1", SessionID: "s-2", Impersonated: true, ActorID: "u-support"}
+	exchanged := &Principal{TenantID: "default", UserID: "u-1", ClientID: "reports"}
+	if own.CacheIdentity() != "default/u-1" {
+		t.Fatalf("CacheIdentity = %q", own.CacheIdentity())
+	}
+	// 代操作与令牌交换的权限范围不同，不能与用户本人共享缓存
+	if imp.CacheIdentity() == own.CacheIdentity() || exchanged.CacheIdentity() == own.CacheIdentity() {
+		t.Fatalf("scoped principals should not share cache identity: %q %q", imp.CacheIdentity(), exchanged.CacheIdentity())
+	}
+	if (&Principal{TenantID: "default"}).CacheIdentity() != "" || (*Principal)(nil).CacheIdentity() != "" {
+		t.Fatal("principal without user should have empty cache identity")
+	}
+}
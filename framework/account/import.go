@@ -0,0 +1,367 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 批量导入支持的文件格式。
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatJSON = "json"
+)
+
+// importColumns CSV 可用的列，首行为表头，列名不区分大小写，username 必填。
+var importColumns = []string{"username", "email", "phone", "nickname", "password", "status", "roles"}
+
+// ImportUserRow 导入文件中的一行。CSV 的 roles 列用 ";" 或 "|" 分隔多个角色编码。
+// Password 为空时不创建密码凭证，用户通过找回密码、验证码或第三方登录进入。
+type ImportUserRow struct {
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Phone    string   `json:"phone"`
+	Nickname string   `json:"nickname"`
+	Password string   `json:"password"`
+	Status   string   `json:"status"`
+	Roles    []string `json:"roles"`
+}
+
+// ImportUsersRequest 批量导入参数，用户导入到发起者所在租户。
+type ImportUsersRequest struct {
+	Format string
+	Data   []byte
+	// DryRun 只校验不写入，返回与实际导入相同的逐行错误报告。
+	DryRun bool
+}
+
+// ImportRowError 单行的校验或写入错误。Row 从 1 开始，不含 CSV 表头。
+type ImportRowError struct {
+	Row      int    `json:"row"`
+	Username string `json:"username,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ImportUsersResult 批量导入结果。DryRun 时 Created 为通过校验、将会创建的行数。
+type ImportUsersResult struct {
+	DryRun  bool             `json:"dry_run"`
+	Total   int              `json:"total"`
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	UserIDs []string         `json:"user_ids,omitempty"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+// ImportUsers 从 CSV 或 JSON 批量创建用户。先逐行校验（格式、密码策略、文件内重复、
+// 与已有用户冲突、角色存在性），再逐行在独立事务中创建；失败的行写入错误报告，不影响其他行。
+// 导入 user 以外的角色等同于分配角色，发起者还需要 admin.role.assign 权限。
+func (s *AdminService) ImportUsers(ctx context.Context, principal *Principal, req ImportUsersRequest) (*ImportUsersResult, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.admin.import_users")
+	defer span.End()
+	if principal == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	tenantID := s.m.tenantID(principal.TenantID)
+	if len(req.Data) == 0 {
+		return nil, accountError(ErrInvalidArgument, "导入内容为空")
+	}
+	if len(req.Data) > s.m.cfg.UserImport.MaxPayloadSize {
+		return nil, accountError(ErrInvalidArgument, fmt.Sprintf("导入文件超过 %d 字节", s.m.cfg.UserImport.MaxPayloadSize))
+	}
+	rows, err := parseImportRows(req.Format, req.Data)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	if len(rows) == 0 {
+		return nil, accountError(ErrInvalidArgument, "导入内容为空")
+	}
+	if len(rows) > s.m.cfg.UserImport.MaxRows {
+		return nil, accountError(ErrInvalidArgument, fmt.Sprintf("单次最多导入 %d 行", s.m.cfg.UserImport.MaxRows))
+	}
+
+	result := &ImportUsersResult{DryRun: req.DryRun, Total: len(rows), Errors: []ImportRowError{}}
+	valid, err := s.validateImportRows(ctx, tenantID, rows, result)
+	if err != nil {
+		return nil, err
+	}
+	var elevated []string
+	for _, i := range valid {
+		elevated = append(elevated, rows[i].Roles...)
+	}
+	if invitationElevates(elevated, nil) {
+		d, err := s.m.authorizer.Check(ctx, AuthzRequest{Subject: principal, Permission: "admin.role.assign"})
+		if err != nil {
+			return nil, err
+		}
+		if !d.Allowed {
+			return nil, accountError(ErrPermissionDenied, "导入角色需要 admin.role.assign 权限")
+		}
+	}
+	if req.DryRun {
+		result.Created = len(valid)
+		result.Failed = result.Total - result.Created
+		return result, nil
+	}
+
+	roleIDs, err := s.roleIDsByCode(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range valid {
+		row := rows[i]
+		var user *User
+		err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			user, err = s.createUserTx(ctx, tx, tenantID, CreateUserWithPasswordRequest{
+				Username: row.Username,
+				Password: row.Password,
+				Nickname: row.Nickname,
+				Email:    row.Email,
+				Phone:    row.Phone,
+				Status:   row.Status,
+			}, "import")
+			if err != nil {
+				return err
+			}
+			for _, code := range row.Roles {
+				userRole := UserRole{ID: newID(), TenantID: tenantID, UserID: user.ID, RoleID: roleIDs[code], ScopeType: "tenant", ScopeID: tenantID}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&userRole).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: i + 1, Username: row.Username, Message: err.Error()})
+			continue
+		}
+		result.Created++
+		result.UserIDs = append(result.UserIDs, user.ID)
+	}
+	result.Failed = result.Total - result.Created
+	_ = emitOutbox(s.m.db.WithContext(ctx), EventUsersImported, tenantID, map[string]any{
+		"tenant_id":   tenantID,
+		"imported_by": principal.UserID,
+		"total":       result.Total,
+		"created":     result.Created,
+		"failed":      result.Failed,
+		"imported_at": time.Now(),
+	})
+	s.m.audit(ctx, tenantID, principal.UserID, "admin_import_users", "success",
+		fmt.Sprintf("total=%d created=%d failed=%d", result.Total, result.Created, result.Failed), "", "")
+	return result, nil
+}
+
+// validateImportRows 规范化并校验每一行，错误写入 result，返回通过校验的行下标。
+func (s *AdminService) validateImportRows(ctx context.Context, tenantID string, rows []ImportUserRow, result *ImportUsersResult) ([]int, error) {
+	roleIDs, err := s.roleIDsByCode(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var usernames, emails, phones []string
+	seen := map[string]int{}
+	rowErrs := make(map[int][]ImportRowError)
+	fail := func(i int, field, msg string) {
+		rowErrs[i] = append(rowErrs[i], ImportRowError{Row: i + 1, Username: rows[i].Username, Field: field, Message: msg})
+	}
+	// dup 记录文件内重复的标识，返回首次出现的行号（从 1 开始），未重复返回 0
+	dup := func(kind, value string, i int) int {
+		key := kind + ":" + value
+		if first, ok := seen[key]; ok {
+			return first + 1
+		}
+		seen[key] = i
+		return 0
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		row.Username = strings.TrimSpace(row.Username)
+		row.Email = normalizeEmail(row.Email)
+		row.Phone = normalizePhone(row.Phone)
+		row.Nickname = strings.TrimSpace(row.Nickname)
+		row.Status = strings.TrimSpace(row.Status)
+		if err := validateIdentifier(RegisterRequest{Username: row.Username, Email: row.Email, Phone: row.Phone}); err != nil {
+			fail(i, "username", err.Error())
+		} else if first := dup("username", row.Username, i); first > 0 {
+			fail(i, "username", fmt.Sprintf("与第 %d 行重复", first))
+		} else {
+			usernames = append(usernames, row.Username)
+		}
+		if row.Email != "" {
+			if !isEmailIdentifier(row.Email) {
+				fail(i, "email", "邮箱格式不正确")
+			} else if first := dup("email", row.Email, i); first > 0 {
+				fail(i, "email", fmt.Sprintf("与第 %d 行重复", first))
+			} else {
+				emails = append(emails, row.Email)
+			}
+		}
+		if row.Phone != "" {
+			if first := dup("phone", row.Phone, i); first > 0 {
+				fail(i, "phone", fmt.Sprintf("与第 %d 行重复", first))
+			} else {
+				phones = append(phones, row.Phone)
+			}
+		}
+		if row.Password != "" {
+			if err := validatePassword(row.Password, s.m.tenantSvc.passwordConfig(ctx, tenantID)); err != nil {
+				fail(i, "password", err.Error())
+			}
+		}
+		switch row.Status {
+		case "":
+			row.Status = UserStatusNormal
+		case UserStatusNormal, UserStatusDisabled, UserStatusPending:
+		default:
+			fail(i, "status", "不支持的状态: "+row.Status)
+		}
+		roles := make([]string, 0, len(row.Roles))
+		for _, code := range row.Roles {
+			code = strings.TrimSpace(code)
+			if code == "" || contains(roles, code) {
+				continue
+			}
+			if _, ok := roleIDs[code]; !ok {
+				fail(i, "roles", "角色不存在: "+code)
+				continue
+			}
+			roles = append(roles, code)
+		}
+		row.Roles = roles
+	}
+
+	// 与库中已有用户冲突
+	for _, check := range []struct {
+		field  string
+		values []string
+		match  func(string) (int, bool)
+	}{
+		{"username", usernames, func(v string) (int, bool) { i, ok := seen["username:"+v]; return i, ok }},
+		{"email", emails, func(v string) (int, bool) { i, ok := seen["email:"+v]; return i, ok }},
+		{"phone", phones, func(v string) (int, bool) { i, ok := seen["phone:"+v]; return i, ok }},
+	} {
+		for start := 0; start < len(check.values); start += 500 {
+			end := min(start+500, len(check.values))
+			var existing []string
+			if err := s.m.db.WithContext(ctx).Model(&User{}).
+				Where("tenant_id = ? AND "+check.field+" IN ?", tenantID, check.values[start:end]).
+				Pluck(check.field, &existing).Error; err != nil {
+				return nil, err
+			}
+			for _, v := range existing {
+				if i, ok := check.match(v); ok {
+					fail(i, check.field, check.field+" 已存在")
+				}
+			}
+		}
+	}
+
+	valid := make([]int, 0, len(rows))
+	for i := range rows {
+		if errs := rowErrs[i]; len(errs) > 0 {
+			result.Errors = append(result.Errors, errs...)
+			continue
+		}
+		valid = append(valid, i)
+	}
+	return valid, nil
+}
+
+// roleIDsByCode 返回租户内启用角色的编码到 ID 映射。
+func (s *AdminService) roleIDsByCode(ctx context.Context, tenantID string) (map[string]string, error) {
+	var roles []Role
+	if err := s.m.db.WithContext(ctx).Select("id", "code").
+		Where("tenant_id = ? AND status = ?", tenantID, "enabled").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string, len(roles))
+	for _, r := range roles {
+		ids[r.Code] = r.ID
+	}
+	return ids, nil
+}
+
+// parseImportRows 解析 CSV（首行表头）或 JSON 数组。
+func parseImportRows(format string, data []byte) ([]ImportUserRow, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case ImportFormatJSON:
+		var rows []ImportUserRow
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("JSON 格式错误: %w", err)
+		}
+		return rows, nil
+	case ImportFormatCSV, "":
+		return parseImportCSV(data)
+	default:
+		return nil, errors.New("不支持的导入格式: " + format)
+	}
+}
+
+func parseImportCSV(data []byte) ([]ImportUserRow, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("CSV 表头错误: %w", err)
+	}
+	cols := make([]string, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !contains(importColumns, h) {
+			return nil, fmt.Errorf("未知列: %s（可用列: %s）", h, strings.Join(importColumns, ","))
+		}
+		cols[i] = h
+	}
+	if !contains(cols, "username") {
+		return nil, errors.New("缺少 username 列")
+	}
+	var rows []ImportUserRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV 第 %d 行格式错误: %w", len(rows)+1, err)
+		}
+		var row ImportUserRow
+		for i, v := range record {
+			if i >= len(cols) {
+				break
+			}
+			switch cols[i] {
+			case "username":
+				row.Username = v
+			case "email":
+				row.Email = v
+			case "phone":
+				row.Phone = v
+			case "nickname":
+				row.Nickname = v
+			case "password":
+				row.Password = v
+			case "status":
+				row.Status = v
+			case "roles":
+				row.Roles = strings.FieldsFunc(v, func(c rune) bool { return c == ';' || c == '|' })
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
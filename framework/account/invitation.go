@@ -0,0 +1,503 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 邀请状态。过期的邀请仍为 pending，查询时按 ExpiresAt 呈现为 expired。
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// invitationAudience 邀请令牌的受众，与 access token 的受众不同，二者不能互相冒用。
+const invitationAudience = "account:invitation"
+
+// Invitation 租户邀请。邀请链接中的令牌是签名的 JWT（jti 为邀请 ID），
+// 服务端仍以本记录的状态为准，撤销或接受后令牌立即失效。
+type Invitation struct {
+	ID         string     `json:"id" gorm:"size:36;primaryKey"`
+	TenantID   string     `json:"tenant_id" gorm:"size:64;not null;index:idx_acct_invitations_tenant_status,priority:1"`
+	Email      string     `json:"email" gorm:"size:160;index"` // 为空表示任何持有链接的用户均可接受（仅一次）
+	Roles      string     `json:"roles" gorm:"size:512"`       // 逗号分隔的租户级角色编码
+	Orgs       string     `json:"orgs" gorm:"size:1024"`       // 逗号分隔的 org_id:role_code
+	InvitedBy  string     `json:"invited_by" gorm:"size:36"`
+	Status     string     `json:"status" gorm:"size:16;not null;default:pending;index:idx_acct_invitations_tenant_status,priority:2"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	AcceptedBy string     `json:"accepted_by,omitempty" gorm:"size:36"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (Invitation) TableName() string { return "acct_invitations" }
+
+// InvitationOrg 邀请预分配的组织及其组织级角色。
+type InvitationOrg struct {
+	OrgID string `json:"org_id"`
+	Role  string `json:"role,omitempty"` // 角色编码，为空使用 InvitationConfig.OrgMemberRole
+}
+
+// InvitationInfo 邀请的对外视图。
+type InvitationInfo struct {
+	ID         string          `json:"id"`
+	TenantID   string          `json:"tenant_id"`
+	Email      string          `json:"email,omitempty"`
+	Roles      []string        `json:"roles"`
+	Orgs       []InvitationOrg `json:"orgs"`
+	InvitedBy  string          `json:"invited_by,omitempty"`
+	Status     string          `json:"status"`
+	ExpiresAt  time.Time       `json:"expires_at"`
+	AcceptedBy string          `json:"accepted_by,omitempty"`
+	AcceptedAt *time.Time      `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func (inv Invitation) toInfo() *InvitationInfo {
+	info := &InvitationInfo{
+		ID:         inv.ID,
+		TenantID:   inv.TenantID,
+		Email:      inv.Email,
+		Roles:      []string{},
+		Orgs:       []InvitationOrg{},
+		InvitedBy:  inv.InvitedBy,
+		Status:     inv.Status,
+		ExpiresAt:  inv.ExpiresAt,
+		AcceptedBy: inv.AcceptedBy,
+		AcceptedAt: inv.AcceptedAt,
+		CreatedAt:  inv.CreatedAt,
+	}
+	if inv.Roles != "" {
+		info.Roles = strings.Split(inv.Roles, ",")
+	}
+	for _, item := range strings.Split(inv.Orgs, ",") {
+		if orgID, role, ok := strings.Cut(item, ":"); ok {
+			info.Orgs = append(info.Orgs, InvitationOrg{OrgID: orgID, Role: role})
+		}
+	}
+	if inv.Status == InvitationPending && time.Now().After(inv.ExpiresAt) {
+		info.Status = InvitationExpired
+	}
+	return info
+}
+
+// InvitationSender 发送邀请通知（通常是邮件），link 为带令牌的接受地址。
+type InvitationSender interface {
+	SendInvitation(ctx context.Context, invitation *InvitationInfo, link string) error
+}
+
+// InvitationSenderFunc 将函数适配为 InvitationSender 接口。
+type InvitationSenderFunc func(ctx context.Context, invitation *InvitationInfo, link string) error
+
+func (f InvitationSenderFunc) SendInvitation(ctx context.Context, invitation *InvitationInfo, link string) error {
+	return f(ctx, invitation, link)
+}
+
+// CreateInvitationRequest 创建邀请的参数，邀请属于发起者所在租户。
+type CreateInvitationRequest struct {
+	Email string          `json:"email"`
+	Roles []string        `json:"roles"`
+	Orgs  []InvitationOrg `json:"orgs"`
+	// TTL 有效期，0 或超过 InvitationConfig.TTL 时使用配置值。
+	TTL time.Duration `json:"-"`
+}
+
+// InvitationResult 创建邀请的结果。Token 只在此处返回一次，服务端不保存。
+type InvitationResult struct {
+	Invitation *InvitationInfo `json:"invitation"`
+	Token      string          `json:"token"`
+	Link       string          `json:"link,omitempty"`
+	Sent       bool            `json:"sent"`
+}
+
+// invitationClaims 邀请令牌声明。
+type invitationClaims struct {
+	TenantID string `json:"tenant_id"`
+	jwt.RegisteredClaims
+}
+
+// CreateInvitation 创建租户邀请并生成签名链接；配置了 InvitationConfig.Sender 且指定邮箱时同时发送。
+// 预分配 user 以外的角色等同于分配角色，发起者还需要 admin.role.assign 权限。
+// 同一邮箱未处理的旧邀请会被撤销。
+func (s *AdminService) CreateInvitation(ctx context.Context, inviter *Principal, req CreateInvitationRequest) (*InvitationResult, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.admin.create_invitation")
+	defer span.End()
+	if inviter == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	tenantID := s.m.tenantID(inviter.TenantID)
+	email := normalizeEmail(req.Email)
+	if email != "" && !isEmailIdentifier(email) {
+		return nil, accountError(ErrInvalidArgument, "邮箱格式不正确")
+	}
+	roles, orgs, err := s.resolveInvitationGrants(ctx, tenantID, req.Roles, req.Orgs)
+	if err != nil {
+		return nil, err
+	}
+	if invitationElevates(roles, orgs) {
+		d, err := s.m.authorizer.Check(ctx, AuthzRequest{Subject: inviter, Permission: "admin.role.assign"})
+		if err != nil {
+			return nil, err
+		}
+		if !d.Allowed {
+			return nil, accountError(ErrPermissionDenied, "预分配角色需要 admin.role.assign 权限")
+		}
+	}
+	ttl := s.m.cfg.Invitation.TTL
+	if req.TTL > 0 && req.TTL < ttl {
+		ttl = req.TTL
+	}
+
+	orgPairs := make([]string, 0, len(orgs))
+	for _, o := range orgs {
+		orgPairs = append(orgPairs, o.OrgID+":"+o.Role)
+	}
+	inv := Invitation{
+		ID:        newID(),
+		TenantID:  tenantID,
+		Email:     email,
+		Roles:     strings.Join(roles, ","),
+		Orgs:      strings.Join(orgPairs, ","),
+		InvitedBy: inviter.UserID,
+		Status:    InvitationPending,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if len(inv.Roles) > 512 || len(inv.Orgs) > 1024 {
+		return nil, accountError(ErrInvalidArgument, "预分配的角色或组织过多")
+	}
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if email != "" {
+			if err := tx.Model(&Invitation{}).
+				Where("tenant_id = ? AND email = ? AND status = ?", tenantID, email, InvitationPending).
+				Update("status", InvitationRevoked).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&inv).Error; err != nil {
+			return fmt.Errorf("create invitation: %w", err)
+		}
+		return emitOutbox(tx, EventInvitationCreated, inv.ID, map[string]any{
+			"invitation_id": inv.ID,
+			"tenant_id":     tenantID,
+			"email":         email,
+			"roles":         roles,
+			"invited_by":    inviter.UserID,
+			"expires_at":    inv.ExpiresAt,
+		})
+	})
+	if err != nil {
+		recordDBError(ctx)
+		return nil, err
+	}
+
+	token, err := s.m.auth.signJWT(invitationClaims{
+		TenantID: tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        inv.ID,
+			Issuer:    s.m.cfg.JWT.Issuer,
+			Audience:  jwt.ClaimStrings{invitationAudience},
+			IssuedAt:  jwt.NewNumericDate(inv.CreatedAt),
+			ExpiresAt: jwt.NewNumericDate(inv.ExpiresAt),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sign invitation: %w", err)
+	}
+	result := &InvitationResult{Invitation: inv.toInfo(), Token: token, Link: invitationLink(s.m.cfg.Invitation.AcceptURL, token)}
+	if sender := s.m.cfg.Invitation.Sender; sender != nil && email != "" {
+		link := result.Link
+		if link == "" {
+			link = token
+		}
+		if err := sender.SendInvitation(ctx, result.Invitation, link); err != nil {
+			gaia.WarnF("[account] send invitation failed: tenant=%s invitation=%s err=%v", tenantID, inv.ID, err)
+		} else {
+			result.Sent = true
+		}
+	}
+	s.m.audit(ctx, tenantID, inviter.UserID, "invitation_create", "success", truncateString("invitation: "+inv.ID+" email: "+email, 255), "", "")
+	return result, nil
+}
+
+// resolveInvitationGrants 校验预分配的角色与组织均存在于租户内，返回去重后的角色编码与组织分配。
+func (s *AdminService) resolveInvitationGrants(ctx context.Context, tenantID string, roleCodes []string, orgReqs []InvitationOrg) ([]string, []InvitationOrg, error) {
+	roles := make([]string, 0, len(roleCodes))
+	for _, code := range roleCodes {
+		code = strings.TrimSpace(code)
+		if code != "" && !contains(roles, code) {
+			roles = append(roles, code)
+		}
+	}
+	orgs := make([]InvitationOrg, 0, len(orgReqs))
+	needed := append([]string{}, roles...)
+	for _, o := range orgReqs {
+		o.OrgID = strings.TrimSpace(o.OrgID)
+		o.Role = strings.TrimSpace(o.Role)
+		if o.OrgID == "" {
+			return nil, nil, accountError(ErrInvalidArgument, "org_id 不能为空")
+		}
+		if o.Role == "" {
+			o.Role = s.m.cfg.Invitation.OrgMemberRole
+		}
+		orgs = append(orgs, o)
+		if !contains(needed, o.Role) {
+			needed = append(needed, o.Role)
+		}
+	}
+	for _, code := range needed {
+		if strings.ContainsAny(code, ",:") {
+			return nil, nil, accountError(ErrInvalidArgument, "角色编码不能包含逗号或冒号: "+code)
+		}
+	}
+	if len(needed) > 0 {
+		var found []string
+		if err := s.m.db.WithContext(ctx).Model(&Role{}).
+			Where("tenant_id = ? AND code IN ? AND status = ?", tenantID, needed, "enabled").
+			Pluck("code", &found).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, code := range needed {
+			if !contains(found, code) {
+				return nil, nil, accountError(ErrInvalidArgument, "角色不存在: "+code)
+			}
+		}
+	}
+	for _, o := range orgs {
+		var count int64
+		if err := s.m.db.WithContext(ctx).Model(&Organization{}).
+			Where("id = ? AND tenant_id = ?", o.OrgID, tenantID).Count(&count).Error; err != nil {
+			return nil, nil, err
+		}
+		if count == 0 {
+			return nil, nil, accountError(ErrInvalidArgument, "组织不存在: "+o.OrgID)
+		}
+	}
+	return roles, orgs, nil
+}
+
+// invitationElevates 邀请是否预分配了默认 user 以外的角色。
+func invitationElevates(roles []string, orgs []InvitationOrg) bool {
+	for _, code := range roles {
+		if code != "user" {
+			return true
+		}
+	}
+	for _, o := range orgs {
+		if o.Role != "user" {
+			return true
+		}
+	}
+	return false
+}
+
+// invitationLink 把令牌作为 token 查询参数附加到接受页面地址。
+func invitationLink(acceptURL, token string) string {
+	if acceptURL == "" {
+		return ""
+	}
+	sep := "?"
+	if strings.Contains(acceptURL, "?") {
+		sep = "&"
+	}
+	return acceptURL + sep + "token=" + url.QueryEscape(token)
+}
+
+// ListInvitations 列出租户的邀请，status 为空时返回全部；status=expired 返回已过期未处理的邀请。
+func (s *AdminService) ListInvitations(ctx context.Context, tenantID, status string) ([]InvitationInfo, error) {
+	q := s.m.db.WithContext(ctx).Where("tenant_id = ?", s.m.tenantID(tenantID))
+	now := time.Now()
+	switch status {
+	case "":
+	case InvitationExpired:
+		q = q.Where("status = ? AND expires_at <= ?", InvitationPending, now)
+	case InvitationPending:
+		q = q.Where("status = ? AND expires_at > ?", InvitationPending, now)
+	default:
+		q = q.Where("status = ?", status)
+	}
+	var rows []Invitation
+	if err := q.Order("created_at DESC").Limit(500).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	items := make([]InvitationInfo, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, *row.toInfo())
+	}
+	return items, nil
+}
+
+// RevokeInvitation 撤销尚未接受的邀请。
+func (s *AdminService) RevokeInvitation(ctx context.Context, tenantID, invitationID string) error {
+	tenantID = s.m.tenantID(tenantID)
+	res := s.m.db.WithContext(ctx).Model(&Invitation{}).
+		Where("id = ? AND tenant_id = ? AND status = ?", invitationID, tenantID, InvitationPending).
+		Update("status", InvitationRevoked)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return accountError(ErrInvalidArgument, "邀请不存在或已处理")
+	}
+	s.m.audit(ctx, tenantID, "", "invitation_revoke", "success", "invitation: "+invitationID, "", "")
+	return nil
+}
+
+// loadInvitation 验证令牌签名并加载仍可接受的邀请。
+func (s *UserService) loadInvitation(ctx context.Context, db *gorm.DB, token string) (*Invitation, error) {
+	var claims invitationClaims
+	if err := s.m.auth.parseJWT(strings.TrimSpace(token), &claims, invitationAudience); err != nil || claims.ID == "" {
+		return nil, accountError(ErrInvalidToken, "邀请链接无效或已过期")
+	}
+	var inv Invitation
+	if err := db.WithContext(ctx).Where("id = ? AND tenant_id = ?", claims.ID, claims.TenantID).First(&inv).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, accountError(ErrInvalidToken, "邀请链接无效或已过期")
+		}
+		return nil, err
+	}
+	if inv.Status != InvitationPending || time.Now().After(inv.ExpiresAt) {
+		return nil, accountError(ErrInvalidToken, "邀请已失效")
+	}
+	return &inv, nil
+}
+
+// PreviewInvitation 返回邀请的租户、邮箱与预分配角色，供接受页面在用户登录 / 注册前展示。
+func (s *UserService) PreviewInvitation(ctx context.Context, token string) (*InvitationInfo, error) {
+	inv, err := s.loadInvitation(ctx, s.m.db, token)
+	if err != nil {
+		return nil, err
+	}
+	info := inv.toInfo()
+	info.InvitedBy = ""
+	return info, nil
+}
+
+// AcceptInvitation 由已登录用户接受邀请，登录方式不限（密码、验证码、OAuth、SAML、Passkey 等），
+// 新用户先在邀请所属租户注册后再接受。指定了邮箱的邀请只能由该邮箱的账户接受，
+// 接受后该邮箱视为已验证。预分配的角色与组织在同一事务中授予。
+func (s *UserService) AcceptInvitation(ctx context.Context, principal *Principal, token string) (*InvitationInfo, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.user.accept_invitation")
+	defer span.End()
+	if principal == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	if principal.Impersonated {
+		return nil, accountError(ErrPermissionDenied, "代操作会话不能接受邀请")
+	}
+	var accepted *Invitation
+	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		inv, err := s.loadInvitation(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+		if inv.TenantID != principal.TenantID {
+			return accountError(ErrPermissionDenied, "邀请属于其他租户，请登录该租户后接受")
+		}
+		var user User
+		if err := tx.Where("id = ? AND tenant_id = ?", principal.UserID, principal.TenantID).First(&user).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if inv.Email != "" {
+			if normalizeEmail(stringValue(user.Email)) != inv.Email {
+				return accountError(ErrPermissionDenied, "该邀请只能由受邀邮箱对应的账户接受")
+			}
+			if user.EmailVerifiedAt == nil {
+				if err := tx.Model(&User{}).Where("id = ?", user.ID).Update("email_verified_at", now).Error; err != nil {
+					return err
+				}
+			}
+		}
+		res := tx.Model(&Invitation{}).Where("id = ? AND status = ?", inv.ID, InvitationPending).
+			Updates(map[string]any{"status": InvitationAccepted, "accepted_by": user.ID, "accepted_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return accountError(ErrInvalidToken, "邀请已失效")
+		}
+		if err := s.grantInvitation(ctx, tx, inv, user.ID); err != nil {
+			return err
+		}
+		inv.Status, inv.AcceptedBy, inv.AcceptedAt = InvitationAccepted, user.ID, &now
+		accepted = inv
+		return emitOutbox(tx, EventInvitationAccepted, inv.ID, map[string]any{
+			"invitation_id": inv.ID,
+			"tenant_id":     inv.TenantID,
+			"user_id":       user.ID,
+			"roles":         inv.Roles,
+			"accepted_at":   now,
+		})
+	})
+	if err != nil {
+		s.m.audit(ctx, principal.TenantID, principal.UserID, "invitation_accept", "failed", truncateString(err.Error(), 255), "", "")
+		return nil, err
+	}
+	_ = s.m.authorizer.invalidatePermissions(ctx, principal.UserID)
+	s.m.auth.invalidateUserPrincipalCaches(ctx, principal.UserID)
+	s.m.audit(ctx, principal.TenantID, principal.UserID, "invitation_accept", "success", "invitation: "+accepted.ID, "", "")
+	return accepted.toInfo(), nil
+}
+
+// grantInvitation 授予邀请预分配的角色与组织，邀请发出后被删除或停用的角色跳过。
+func (s *UserService) grantInvitation(ctx context.Context, tx *gorm.DB, inv *Invitation, userID string) error {
+	info := inv.toInfo()
+	codes := append([]string{}, info.Roles...)
+	for _, o := range info.Orgs {
+		codes = append(codes, o.Role)
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	var roles []Role
+	if err := tx.Where("tenant_id = ? AND code IN ? AND status = ?", inv.TenantID, codes, "enabled").Find(&roles).Error; err != nil {
+		return err
+	}
+	roleIDs := make(map[string]string, len(roles))
+	for _, r := range roles {
+		roleIDs[r.Code] = r.ID
+	}
+	grants := make([]UserRole, 0, len(codes))
+	for _, code := range info.Roles {
+		if id, ok := roleIDs[code]; ok {
+			grants = append(grants, UserRole{ID: newID(), TenantID: inv.TenantID, UserID: userID, RoleID: id, ScopeType: "tenant", ScopeID: inv.TenantID})
+		} else {
+			gaia.WarnF("[account] invitation %s: role %s no longer available, skipped", inv.ID, code)
+		}
+	}
+	for _, o := range info.Orgs {
+		id, ok := roleIDs[o.Role]
+		if !ok {
+			gaia.WarnF("[account] invitation %s: org role %s no longer available, skipped", inv.ID, o.Role)
+			continue
+		}
+		var count int64
+		if err := tx.Model(&Organization{}).Where("id = ? AND tenant_id = ?", o.OrgID, inv.TenantID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			gaia.WarnF("[account] invitation %s: org %s no longer exists, skipped", inv.ID, o.OrgID)
+			continue
+		}
+		grants = append(grants, UserRole{ID: newID(), TenantID: inv.TenantID, UserID: userID, RoleID: id, ScopeType: "org", ScopeID: o.OrgID})
+	}
+	if len(grants) == 0 {
+		return nil
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&grants).Error; err != nil {
+		return err
+	}
+	return tx.Model(&User{}).Where("id = ?", userID).Update("roles_version", gorm.Expr("roles_version + 1")).Error
+}
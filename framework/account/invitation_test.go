@@ -0,0 +1,278 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xxzhwl/gaia/components/storage"
+)
+
+func TestInvitationLifecycle(t *testing.T) {
+	m, inviter := newPolicyTestManager(t)
+	ctx := context.Background()
+	var sent []string
+	m.cfg.Invitation.AcceptURL = "https://app.example.com/invite"
+	m.cfg.Invitation.Sender = InvitationSenderFunc(func(_ context.Context, inv *InvitationInfo, link string) error {
+		sent = append(sent, inv.Email+" "+link)
+		return nil
+	})
+
+	if _, err := m.Admin().CreateInvitation(ctx, inviter, CreateInvitationRequest{Email: "Alice@Example.com", Roles: []string{"accountant"}}); err == nil {
+		t.Fatal("expected pre-assigning roles without admin.role.assign to be rejected")
+	}
+	if _, err := m.Admin().CreateInvitation(ctx, inviter, CreateInvitationRequest{Email: "alice@example.com", Roles: []string{"missing"}}); err == nil {
+		t.Fatal("expected unknown role to be rejected")
+	}
+	var role Role
+	if err := m.db.Where("tenant_id = ? AND code = ?", "default", "accountant").First(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	assign := &Permission{ID: newID(), TenantID: "default", Code: "admin.role.assign", ResourceType: "role", Action: "assign", Status: "enabled"}
+	if err := m.db.Create(assign).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := m.db.Create(&RolePermission{ID: newID(), RoleID: role.ID, PermissionID: assign.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := m.Admin().CreateInvitation(ctx, inviter, CreateInvitationRequest{Email: "Alice@Example.com", Roles: []string{"accountant"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Sent || len(sent) != 1 || !strings.HasPrefix(res.Link, "https://app.example.com/invite?token=") {
+		t.Fatalf("unexpected result: %+v sent=%v", res, sent)
+	}
+	if _, err := m.Auth().Validate(ctx, res.Token); err == nil {
+		t.Fatal("invitation token must not be usable as an access token")
+	}
+	preview, err := m.Users().PreviewInvitation(ctx, res.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Email != "alice@example.com" || len(preview.Roles) != 1 || preview.InvitedBy != "" {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+
+	email := "alice@example.com"
+	other := "mallory@example.com"
+	alice := &User{ID: newID(), TenantID: "default", Username: "alice", Email: &email, Status: UserStatusNormal, AuthVersion: 1, RolesVersion: 1}
+	mallory := &User{ID: newID(), TenantID: "default", Username: "mallory", Email: &other, Status: UserStatusNormal, AuthVersion: 1, RolesVersion: 1}
+	for _, u := range []*User{alice, mallory} {
+		if err := m.db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Users().AcceptInvitation(ctx, &Principal{UserID: mallory.ID, TenantID: "default"}, res.Token); err == nil {
+		t.Fatal("expected invitation bound to another email to be rejected")
+	}
+	if _, err := m.Users().AcceptInvitation(ctx, &Principal{UserID: alice.ID, TenantID: "default"}, res.Token+"x"); err == nil {
+		t.Fatal("expected tampered token to be rejected")
+	}
+	info, err := m.Users().AcceptInvitation(ctx, &Principal{UserID: alice.ID, TenantID: "default"}, res.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != InvitationAccepted || info.AcceptedBy != alice.ID {
+		t.Fatalf("unexpected accepted invitation: %+v", info)
+	}
+	roles, err := m.auth.loadRoleCodes(ctx, m.db, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(roles, "accountant") {
+		t.Fatalf("roles after accept = %v", roles)
+	}
+	var reloaded User
+	if err := m.db.Where("id = ?", alice.ID).First(&reloaded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.EmailVerifiedAt == nil || reloaded.RolesVersion != 2 {
+		t.Fatalf("expected verified email and bumped roles_version, got %+v", reloaded)
+	}
+	if _, err := m.Users().AcceptInvitation(ctx, &Principal{UserID: alice.ID, TenantID: "default"}, res.Token); err == nil {
+		t.Fatal("expected invitation to be single-use")
+	}
+
+	open, err := m.Admin().CreateInvitation(ctx, inviter, CreateInvitationRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Admin().RevokeInvitation(ctx, "default", open.Invitation.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Users().PreviewInvitation(ctx, open.Token); err == nil {
+		t.Fatal("expected revoked invitation to be rejected")
+	}
+	list, err := m.Admin().ListInvitations(ctx, "default", InvitationRevoked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != open.Invitation.ID {
+		t.Fatalf("revoked invitations = %+v", list)
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	m, admin := newPolicyTestManager(t)
+	ctx := context.Background()
+	csvData := "\ufeffUsername,Email,Password,Roles\n" +
+		"carol,carol@example.com,correct-horse-1,\n" +
+		"dave,not-an-email,,\n" +
+		"carol,other@example.com,,\n" +
+		"bob,,,\n" +
+		"erin,erin@example.com,short,missing\n" +
+		"frank,,,user\n"
+
+	dry, err := m.Admin().ImportUsers(ctx, admin, ImportUsersRequest{Format: ImportFormatCSV, Data: []byte(csvData), DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dry.Total != 6 || dry.Created != 2 || dry.Failed != 4 {
+		t.Fatalf("unexpected dry run: %+v", dry)
+	}
+	failed := map[int]string{}
+	for _, e := range dry.Errors {
+		failed[e.Row] += e.Field + ";"
+	}
+	if failed[2] != "email;" || failed[3] != "username;" || failed[4] != "username;" || failed[5] != "password;roles;" {
+		t.Fatalf("unexpected errors: %+v", dry.Errors)
+	}
+	var count int64
+	m.db.Model(&User{}).Where("username IN ?", []string{"carol", "frank"}).Count(&count)
+	if count != 0 {
+		t.Fatal("dry run must not create users")
+	}
+
+	res, err := m.Admin().ImportUsers(ctx, admin, ImportUsersRequest{Format: ImportFormatCSV, Data: []byte(csvData)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Created != 2 || len(res.UserIDs) != 2 {
+		t.Fatalf("unexpected import: %+v", res)
+	}
+	var cred Credential
+	if err := m.db.Where("user_id = ? AND type = ?", res.UserIDs[0], CredentialPassword).First(&cred).Error; err != nil {
+		t.Fatalf("expected password credential for carol: %v", err)
+	}
+
+	if _, err := m.Admin().ImportUsers(ctx, admin, ImportUsersRequest{Format: ImportFormatJSON, Data: []byte(`[{"username":"gina","roles":["accountant"]}]`)}); err == nil {
+		t.Fatal("expected importing elevated roles without admin.role.assign to be rejected")
+	}
+	if _, err := m.Admin().ImportUsers(ctx, admin, ImportUsersRequest{Format: ImportFormatCSV, Data: []byte("login,email\nx,y\n")}); err == nil {
+		t.Fatal("expected unknown column to be rejected")
+	}
+}
+
+// memoryObjectStore 测试用对象存储。
+type memoryObjectStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *memoryObjectStore) Put(_ context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = data
+	return nil
+}
+
+func (s *memoryObjectStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key], nil
+}
+
+func (s *memoryObjectStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memoryObjectStore) Exists(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.data[key]
+	return ok, nil
+}
+
+func (s *memoryObjectStore) List(context.Context, string, int) ([]storage.ObjectInfo, error) {
+	return nil, nil
+}
+
+func (s *memoryObjectStore) SignURL(_ context.Context, key string, _ time.Duration) (string, error) {
+	return "https://objects.example.com/" + key + "?sig=test", nil
+}
+
+func TestDataExport(t *testing.T) {
+	m, p := newPolicyTestManager(t)
+	ctx := context.Background()
+	if _, err := m.DataExports().Request(ctx, p); err == nil {
+		t.Fatal("expected export to be unavailable without a store")
+	}
+	store := &memoryObjectStore{data: map[string][]byte{}}
+	m.cfg.DataExport.Store = store
+	if _, err := m.Consents().Record(ctx, RecordConsentRequest{TenantID: "default", UserID: p.UserID, DocumentType: "privacy", Version: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.DataExports().Request(ctx, &Principal{UserID: p.UserID, TenantID: "default", Impersonated: true}); err == nil {
+		t.Fatal("expected impersonated session to be rejected")
+	}
+
+	export, err := m.DataExports().Request(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.DataExports().Request(ctx, p); err == nil {
+		t.Fatal("expected second export to be rate limited")
+	}
+	var info *DataExportInfo
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if info, err = m.DataExports().Get(ctx, "default", p.UserID, export.ID); err != nil {
+			t.Fatal(err)
+		}
+		if info.Status == DataExportReady || info.Status == DataExportFailed {
+			break
+		}
+	}
+	if info.Status != DataExportReady || info.DownloadURL == "" {
+		t.Fatalf("unexpected export: %+v", info)
+	}
+	if _, err := m.DataExports().Get(ctx, "default", newID(), export.ID); err == nil {
+		t.Fatal("expected other users to be unable to read the export")
+	}
+
+	var archive []byte
+	for _, v := range store.data {
+		archive = v
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]bool{}
+	for _, f := range zr.File {
+		files[f.Name] = true
+	}
+	for _, name := range []string{"manifest.json", "profile.json", "sessions.json", "consents.json", "oauth_accounts.json", "audit_logs.json"} {
+		if !files[name] {
+			t.Fatalf("archive missing %s: %v", name, files)
+		}
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if err := m.db.Model(&DataExport{}).Where("id = ?", export.ID).Update("expires_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := m.exportSvc.cleanupExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.data) != 0 {
+		t.Fatal("expected expired archive to be deleted")
+	}
+}
@@ -0,0 +1,1155 @@
+package account
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/xxzhwl/gaia"
+	"github.com/xxzhwl/gaia/errwrap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LDAPProvider 目录身份在 acct_oauth_accounts 中使用的 provider 标识。
+const LDAPProvider = "ldap"
+
+const (
+	defaultLDAPSyncInterval = time.Hour
+	ldapSyncLockKey         = "ldap:sync:lock"
+	ldapSyncLockTTL         = 10 * time.Minute
+	ldapPageSize            = 500
+	ldapBatchSize           = 500
+	ldapCodePrefix          = "ldap_"
+)
+
+// LDAPService LDAP / Active Directory 认证与目录同步。
+//
+// 目录身份通过 acct_oauth_accounts（provider=ldap，subject=UniqueIDAttr 的值）关联到本地用户，
+// 首次登录或同步时自动创建本地账号并分配默认角色；本地不保存目录口令。
+// 同步时组映射为租户角色、组织单元映射为组织节点，成员关系以目录为准，
+// 但只调整 LDAP 来源用户的授权，本地账号的手工授权不受影响。
+type LDAPService struct {
+	m *Manager
+}
+
+// LDAPLoginRequest LDAP 登录请求参数。
+type LDAPLoginRequest struct {
+	TenantID  string
+	Username  string
+	Password  string
+	DeviceID  string
+	IP        string
+	UserAgent string
+}
+
+// LDAPEntry 目录中的用户条目。
+type LDAPEntry struct {
+	DN          string `json:"dn"`
+	UniqueID    string `json:"unique_id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Phone       string `json:"phone"`
+}
+
+// LDAPSyncResult 一次目录同步的统计。
+type LDAPSyncResult struct {
+	UsersCreated  int           `json:"users_created"`
+	UsersUpdated  int           `json:"users_updated"`
+	UsersDisabled int           `json:"users_disabled"`
+	RolesCreated  int           `json:"roles_created"`
+	RoleGrants    int           `json:"role_grants"`
+	RoleRevokes   int           `json:"role_revokes"`
+	OrgsCreated   int           `json:"orgs_created"`
+	OrgsUpdated   int           `json:"orgs_updated"`
+	OrgsDisabled  int           `json:"orgs_disabled"`
+	OrgGrants     int           `json:"org_grants"`
+	OrgRevokes    int           `json:"org_revokes"`
+	Duration      time.Duration `json:"duration"`
+}
+
+// Enabled 是否配置了目录服务。
+func (s *LDAPService) Enabled() bool {
+	return s.m.cfg.LDAP.URL != ""
+}
+
+// Authenticate 以用户名和口令在目录中绑定，成功后返回用户条目。
+// 空口令一律拒绝：多数目录把空口令的简单绑定视为匿名绑定并返回成功。
+func (s *LDAPService) Authenticate(ctx context.Context, username, password string) (*LDAPEntry, error) {
+	if !s.Enabled() {
+		return nil, accountError(ErrInvalidArgument, "未启用 LDAP 登录")
+	}
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return nil, accountError(ErrInvalidArgument, "用户名和密码不能为空")
+	}
+	cfg := s.m.cfg.LDAP
+	conn, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var entry *LDAPEntry
+	dn := ""
+	if cfg.UserDNTemplate != "" {
+		dn = fmt.Sprintf(cfg.UserDNTemplate, ldap.EscapeDN(username))
+	} else {
+		if err := s.bindService(conn); err != nil {
+			return nil, err
+		}
+		if entry, err = s.findUser(conn, username); err != nil {
+			return nil, err
+		}
+		dn = entry.DN
+	}
+	if err := conn.Bind(dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, accountError(ErrInvalidCredential, "账号或密码错误")
+		}
+		return nil, fmt.Errorf("ldap bind: %w", err)
+	}
+	if entry != nil {
+		return entry, nil
+	}
+	// DN 模板模式下没有服务账号，以用户自身身份读取条目
+	res, err := conn.Search(ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1,
+		s.timeLimit(), false, "(objectClass=*)", s.userAttributes(), nil))
+	if err != nil {
+		return nil, fmt.Errorf("ldap read user entry: %w", err)
+	}
+	if len(res.Entries) == 0 {
+		return nil, accountError(ErrInvalidCredential, "账号或密码错误")
+	}
+	return s.toEntry(res.Entries[0]), nil
+}
+
+// Login 使用目录账号登录。首次登录自动创建本地账号，之后每次登录同步目录中的资料。
+// 账号锁定策略由目录自身负责，这里只按 IP 维度限流，避免把目录账号锁死。
+func (s *LDAPService) Login(ctx context.Context, req LDAPLoginRequest) (*AuthResult, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.ldap.login")
+	defer span.End()
+	tenantID := s.tenantID(req.TenantID)
+	if err := s.m.tenantSvc.checkLogin(ctx, tenantID, LoginMethodLDAP); err != nil {
+		return nil, err
+	}
+
+	riskResult, err := s.m.risk.Assess(ctx, tenantID, "", req.IP)
+	if err != nil {
+		return nil, err
+	}
+	if riskResult.Decision == RiskBlock {
+		return nil, accountError(ErrRateLimited, riskResult.Reason)
+	}
+
+	entry, err := s.Authenticate(ctx, req.Username, req.Password)
+	if err != nil {
+		if errwrap.GetCode(err) == ErrInvalidCredential {
+			s.m.risk.RecordFailure(ctx, tenantID, "", req.IP)
+		}
+		s.m.audit(ctx, tenantID, "", "ldap_login", "failed",
+			fmt.Sprintf("username=%s: %s", truncateString(req.Username, 80), err.Error()), req.IP, req.UserAgent)
+		return nil, err
+	}
+
+	var (
+		user   *User
+		result *AuthResult
+	)
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := s.linkedUser(tx, tenantID, entry.UniqueID)
+		if err != nil {
+			return err
+		}
+		if user, _, err = s.applyEntry(ctx, tx, tenantID, entry, current); err != nil {
+			return err
+		}
+		if user.Status == UserStatusDisabled || user.Status == UserStatusDeleted {
+			return accountError(ErrPermissionDenied, "账号不可用")
+		}
+		if user.Status == UserStatusLocked {
+			if user.LockedUntil == nil || time.Now().Before(*user.LockedUntil) {
+				return accountError(ErrAccountLocked, "账号已锁定")
+			}
+			if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+				"status":       UserStatusNormal,
+				"locked_until": nil,
+			}).Error; err != nil {
+				return err
+			}
+			user.Status = UserStatusNormal
+			user.LockedUntil = nil
+		}
+		if s.m.phoneBindingRequired(user) {
+			result = &AuthResult{
+				User:                 user.toInfo(nil, nil),
+				PhoneBindingRequired: true,
+				TokenType:            "Bearer",
+			}
+			return nil
+		}
+		if s.m.mfa.HasTOTP(ctx, tenantID, user.ID) {
+			challenge, err := s.m.mfa.CreateMFAChallenge(ctx, tenantID, user.ID, user.AuthVersion)
+			if err != nil {
+				return err
+			}
+			result = &AuthResult{
+				MFARequired:  true,
+				MFAChallenge: challenge.ID,
+				User:         user.toInfo(nil, nil),
+			}
+			return nil
+		}
+		roles, err := s.m.auth.loadRoleCodes(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		mfaRequired, mfaEnforced := s.m.mfa.enrollmentState(ctx, tenantID, user.ID, roles)
+		if mfaEnforced {
+			if r := s.m.auth.adminMFAFallback(ctx, tx, user, req.IP); r != nil {
+				result = r
+				return nil
+			}
+			return accountError(ErrPermissionDenied, "需要先配置 MFA 后才能登录")
+		}
+		if result, err = s.m.auth.issueTokens(ctx, tx, user, roles, []string{AMRPassword}, req.DeviceID, req.IP, req.UserAgent, "", "", ""); err != nil {
+			return err
+		}
+		result.MFAEnrollmentRequired = mfaRequired
+		now := time.Now()
+		if err := emitOutbox(tx, EventUserLoggedIn, user.ID, map[string]any{
+			"user_id":      user.ID,
+			"tenant_id":    tenantID,
+			"method":       "ldap",
+			"logged_in_at": now,
+		}); err != nil {
+			gaia.WarnF("[account] emit user logged in event failed: %v", err)
+		}
+		return tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"last_login_at": now,
+			"last_login_ip": req.IP,
+		}).Error
+	})
+	if err != nil {
+		userID := ""
+		if user != nil {
+			userID = user.ID
+		}
+		s.m.audit(ctx, tenantID, userID, "ldap_login", "failed", err.Error(), req.IP, req.UserAgent)
+		return nil, err
+	}
+	s.m.risk.RecordSuccess(ctx, tenantID, "", req.IP)
+	if !result.MFARequired && !result.PhoneBindingRequired {
+		s.m.audit(ctx, tenantID, user.ID, "ldap_login", "success", "dn="+entry.DN, req.IP, req.UserAgent)
+		_, _ = s.m.authorizer.GetEffectivePermissions(ctx, user.ID)
+	}
+	return result, nil
+}
+
+// ============================================================================
+// 目录同步
+// ============================================================================
+
+type ldapGroup struct {
+	DN      string
+	Name    string
+	Members []string
+}
+
+type ldapOrgUnit struct {
+	DN   string // 规范化后的 DN
+	Name string
+}
+
+type ldapDirectory struct {
+	users  []*LDAPEntry
+	groups []ldapGroup
+	orgs   []ldapOrgUnit
+}
+
+// ldapUserIndex 本次同步涉及的 LDAP 来源用户，用于解析组成员与 OU 归属。
+type ldapUserIndex struct {
+	byDN       map[string]string // 规范化 DN → 本地用户 ID
+	byUsername map[string]string // 小写目录用户名 → 本地用户 ID
+	ids        map[string]bool   // 全部 LDAP 来源用户（含本次已不在目录中的）
+}
+
+// resolve 把组成员属性值解析为本地用户 ID：含 "=" 的按 DN 处理，否则按用户名（memberUid）处理。
+func (idx *ldapUserIndex) resolve(member string) (string, bool) {
+	if strings.Contains(member, "=") {
+		id, ok := idx.byDN[normalizeDN(member)]
+		return id, ok
+	}
+	id, ok := idx.byUsername[strings.ToLower(strings.TrimSpace(member))]
+	return id, ok
+}
+
+// Sync 从目录全量同步用户、组和组织单元。
+// 先把目录数据全部读出，再在一个事务内完成对账，读取失败时不会修改本地数据。
+func (s *LDAPService) Sync(ctx context.Context) (*LDAPSyncResult, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.ldap.sync")
+	defer span.End()
+	if !s.Enabled() {
+		return nil, accountError(ErrInvalidArgument, "未启用 LDAP")
+	}
+	start := time.Now()
+	tenantID := s.tenantID("")
+
+	dir, err := s.fetchDirectory()
+	if err != nil {
+		s.m.audit(ctx, tenantID, "", "ldap_sync", "failed", err.Error(), "", "")
+		return nil, err
+	}
+
+	result := &LDAPSyncResult{}
+	affected := map[string]bool{}
+	var revokedSessions []string
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		idx, sessionIDs, err := s.syncUsers(ctx, tx, tenantID, dir.users, result)
+		if err != nil {
+			return err
+		}
+		revokedSessions = sessionIDs
+		if s.m.cfg.LDAP.OrgBaseDN != "" {
+			if err := s.syncOrgs(tx, tenantID, dir.orgs, idx, result, affected); err != nil {
+				return err
+			}
+		}
+		if s.m.cfg.LDAP.GroupBaseDN != "" {
+			if err := s.syncGroups(tx, tenantID, dir.groups, idx, result, affected); err != nil {
+				return err
+			}
+		}
+		ids := make([]string, 0, len(affected))
+		for id := range affected {
+			ids = append(ids, id)
+		}
+		for _, batch := range chunkStrings(ids, ldapBatchSize) {
+			if err := bumpRolesVersion(tx, batch); err != nil {
+				return err
+			}
+		}
+		result.Duration = time.Since(start)
+		return emitOutbox(tx, EventLDAPSyncCompleted, tenantID, map[string]any{
+			"tenant_id":    tenantID,
+			"result":       result,
+			"completed_at": time.Now(),
+		})
+	})
+	if err != nil {
+		recordDBError(ctx)
+		s.m.audit(ctx, tenantID, "", "ldap_sync", "failed", err.Error(), "", "")
+		return nil, err
+	}
+
+	s.m.auth.invalidatePrincipalCaches(ctx, revokedSessions)
+	for id := range affected {
+		_ = s.m.authorizer.invalidatePermissions(ctx, id)
+		s.m.auth.invalidateUserPrincipalCaches(ctx, id)
+	}
+	s.m.audit(ctx, tenantID, "", "ldap_sync", "success", fmt.Sprintf(
+		"users +%d ~%d -%d, roles +%d, grants +%d -%d, orgs +%d ~%d -%d, org members +%d -%d",
+		result.UsersCreated, result.UsersUpdated, result.UsersDisabled, result.RolesCreated,
+		result.RoleGrants, result.RoleRevokes, result.OrgsCreated, result.OrgsUpdated, result.OrgsDisabled,
+		result.OrgGrants, result.OrgRevokes), "", "")
+	return result, nil
+}
+
+// StartLDAPSyncTask 启动后台协程，启动时立即同步一次，之后按 interval 周期同步目录。
+// 使用分布式锁防止多实例并发同步；interval 传 0 时使用 LDAPConfig.SyncInterval，仍为 0 则默认 1 小时。
+// 返回一个 cancel 函数，调用后停止同步协程。
+func (m *Manager) StartLDAPSyncTask(ctx context.Context, interval time.Duration) context.CancelFunc {
+	if interval <= 0 {
+		interval = m.cfg.LDAP.SyncInterval
+	}
+	if interval <= 0 {
+		interval = defaultLDAPSyncInterval
+	}
+
+	syncCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		gaia.InfoF("[account] ldap sync task started, interval=%v", interval)
+		m.runLDAPSync(syncCtx)
+		for {
+			select {
+			case <-syncCtx.Done():
+				gaia.InfoF("[account] ldap sync task stopped")
+				return
+			case <-ticker.C:
+				m.runLDAPSync(syncCtx)
+			}
+		}
+	}()
+	return cancel
+}
+
+// runLDAPSync 在分布式锁保护下执行一次同步。
+func (m *Manager) runLDAPSync(ctx context.Context) {
+	if m.cache != nil {
+		val, err := m.cache.Increment(ctx, ldapSyncLockKey, ldapSyncLockTTL)
+		if err != nil {
+			gaia.WarnF("[account] ldap sync lock check failed: %v", err)
+		} else if val != 1 {
+			gaia.InfoF("[account] ldap sync skipped — another instance is already running")
+			return
+		}
+		defer func() { _ = m.cache.Del(ctx, ldapSyncLockKey) }()
+	}
+	result, err := m.ldapSvc.Sync(ctx)
+	if err != nil {
+		gaia.WarnF("[account] ldap sync error: %v", err)
+		return
+	}
+	gaia.InfoF("[account] ldap sync done in %v: users +%d ~%d -%d, role grants +%d -%d",
+		result.Duration, result.UsersCreated, result.UsersUpdated, result.UsersDisabled, result.RoleGrants, result.RoleRevokes)
+}
+
+// fetchDirectory 以服务账号读取同步所需的全部目录数据。
+func (s *LDAPService) fetchDirectory() (*ldapDirectory, error) {
+	cfg := s.m.cfg.LDAP
+	conn, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := s.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	dir := &ldapDirectory{}
+	baseDN := cfg.BaseDN
+	if baseDN == "" {
+		// 仅配置了 DN 模板时，用模板去掉首个 RDN 后的部分作为用户基准 DN
+		baseDN = parentDN(fmt.Sprintf(cfg.UserDNTemplate, "x"))
+	}
+	users, err := s.search(conn, baseDN, cfg.UserSyncFilter, s.userAttributes())
+	if err != nil {
+		return nil, fmt.Errorf("ldap search users: %w", err)
+	}
+	for _, e := range users {
+		dir.users = append(dir.users, s.toEntry(e))
+	}
+	if cfg.GroupBaseDN != "" {
+		groups, err := s.search(conn, cfg.GroupBaseDN, cfg.GroupFilter, []string{cfg.GroupNameAttr, cfg.GroupMemberAttr})
+		if err != nil {
+			return nil, fmt.Errorf("ldap search groups: %w", err)
+		}
+		for _, e := range groups {
+			dir.groups = append(dir.groups, ldapGroup{
+				DN:      e.DN,
+				Name:    strings.TrimSpace(e.GetEqualFoldAttributeValue(cfg.GroupNameAttr)),
+				Members: e.GetEqualFoldAttributeValues(cfg.GroupMemberAttr),
+			})
+		}
+	}
+	if cfg.OrgBaseDN != "" {
+		orgs, err := s.search(conn, cfg.OrgBaseDN, cfg.OrgFilter, []string{"ou", "name"})
+		if err != nil {
+			return nil, fmt.Errorf("ldap search org units: %w", err)
+		}
+		for _, e := range orgs {
+			name := e.GetEqualFoldAttributeValue("ou")
+			if name == "" {
+				name = e.GetEqualFoldAttributeValue("name")
+			}
+			dir.orgs = append(dir.orgs, ldapOrgUnit{DN: normalizeDN(e.DN), Name: strings.TrimSpace(name)})
+		}
+	}
+	return dir, nil
+}
+
+// syncUsers 创建/更新目录中的用户，并按配置禁用目录中已不存在的 LDAP 来源用户。
+// 返回用户索引与被吊销的会话 ID。
+func (s *LDAPService) syncUsers(ctx context.Context, tx *gorm.DB, tenantID string, entries []*LDAPEntry,
+	result *LDAPSyncResult) (*ldapUserIndex, []string, error) {
+	var links []OAuthAccount
+	if err := tx.Where("tenant_id = ? AND provider = ?", tenantID, LDAPProvider).Find(&links).Error; err != nil {
+		return nil, nil, err
+	}
+	idx := &ldapUserIndex{byDN: map[string]string{}, byUsername: map[string]string{}, ids: map[string]bool{}}
+	linkUser := make(map[string]string, len(links))
+	userIDs := make([]string, 0, len(links))
+	for _, l := range links {
+		linkUser[l.Subject] = l.UserID
+		idx.ids[l.UserID] = true
+		userIDs = append(userIDs, l.UserID)
+	}
+	users := make(map[string]*User, len(userIDs))
+	for _, batch := range chunkStrings(userIDs, ldapBatchSize) {
+		var rows []User
+		if err := tx.Where("id IN ?", batch).Find(&rows).Error; err != nil {
+			return nil, nil, err
+		}
+		for i := range rows {
+			users[rows[i].ID] = &rows[i]
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.Username == "" || seen[e.UniqueID] {
+			continue
+		}
+		seen[e.UniqueID] = true
+		linkedID, linked := linkUser[e.UniqueID]
+		current := users[linkedID]
+		if linked && current == nil {
+			// 关联的本地账号已被删除，清理失效关联后重新创建
+			if err := tx.Where("tenant_id = ? AND provider = ? AND subject = ?", tenantID, LDAPProvider, e.UniqueID).
+				Delete(&OAuthAccount{}).Error; err != nil {
+				return nil, nil, err
+			}
+		}
+		user, changed, err := s.applyEntry(ctx, tx, tenantID, e, current)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case current == nil:
+			result.UsersCreated++
+		case changed:
+			result.UsersUpdated++
+		}
+		idx.byDN[normalizeDN(e.DN)] = user.ID
+		idx.byUsername[strings.ToLower(e.Username)] = user.ID
+		idx.ids[user.ID] = true
+	}
+
+	if !s.m.cfg.LDAP.DisableMissingUsers {
+		return idx, nil, nil
+	}
+	if len(seen) == 0 {
+		// 一个用户都没读到多半是过滤器或权限配置错误，不能据此禁用全部账号
+		gaia.WarnF("[account] ldap sync found no users, skip disabling missing users")
+		return idx, nil, nil
+	}
+	var sessionIDs []string
+	for _, l := range links {
+		u := users[l.UserID]
+		if seen[l.Subject] || u == nil || u.Status == UserStatusDisabled || u.Status == UserStatusDeleted {
+			continue
+		}
+		if err := tx.Model(&User{}).Where("id = ?", u.ID).Updates(map[string]any{
+			"status":       UserStatusDisabled,
+			"auth_version": gorm.Expr("auth_version + 1"),
+		}).Error; err != nil {
+			return nil, nil, err
+		}
+		var ids []string
+		if err := tx.Model(&Session{}).Where("user_id = ? AND status = ?", u.ID, SessionActive).Pluck("id", &ids).Error; err != nil {
+			return nil, nil, err
+		}
+		sessionIDs = append(sessionIDs, ids...)
+		if err := revokeUserSessionsTx(tx, u.ID); err != nil {
+			return nil, nil, err
+		}
+		result.UsersDisabled++
+	}
+	return idx, sessionIDs, nil
+}
+
+// syncOrgs 把组织单元映射为组织节点（编码 ldap_<DN 摘要>），并按用户 DN 的最近祖先 OU
+// 授予 OrgMemberRole。目录中已删除的 OU 只停用不删除。
+func (s *LDAPService) syncOrgs(tx *gorm.DB, tenantID string, units []ldapOrgUnit, idx *ldapUserIndex,
+	result *LDAPSyncResult, affected map[string]bool) error {
+	// 按 DN 深度排序，保证父节点先于子节点处理
+	sort.SliceStable(units, func(i, j int) bool { return dnDepth(units[i].DN) < dnDepth(units[j].DN) })
+
+	var existing []Organization
+	if err := tx.Unscoped().Where("tenant_id = ? AND code LIKE ?", tenantID, ldapCodePrefix+"%").
+		Find(&existing).Error; err != nil {
+		return err
+	}
+	byCode := make(map[string]*Organization, len(existing))
+	for i := range existing {
+		byCode[existing[i].Code] = &existing[i]
+	}
+
+	orgByDN := map[string]string{}
+	seen := map[string]bool{}
+	for _, ou := range units {
+		code := ldapOrgCode(ou.DN)
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		var parentID *string
+		if pid, ok := orgByDN[parentDN(ou.DN)]; ok {
+			parentID = &pid
+		}
+		name := truncateString(ou.Name, 200)
+		if name == "" {
+			name = code
+		}
+		cur, ok := byCode[code]
+		if !ok {
+			org := &Organization{
+				ID:          newID(),
+				TenantID:    tenantID,
+				Code:        code,
+				Name:        name,
+				Description: truncateString(ou.DN, 500),
+				ParentID:    parentID,
+				Status:      "enabled",
+				Version:     1,
+			}
+			if err := tx.Create(org).Error; err != nil {
+				return err
+			}
+			orgByDN[ou.DN] = org.ID
+			result.OrgsCreated++
+			continue
+		}
+		orgByDN[ou.DN] = cur.ID
+		if cur.Name == name && stringValue(cur.ParentID) == stringValue(parentID) &&
+			cur.Status == "enabled" && !cur.DeletedAt.Valid {
+			continue
+		}
+		if err := tx.Unscoped().Model(&Organization{}).Where("id = ?", cur.ID).Updates(map[string]any{
+			"name":       name,
+			"parent_id":  parentID,
+			"status":     "enabled",
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		result.OrgsUpdated++
+	}
+	ldapOrgIDs := map[string]bool{}
+	for _, org := range existing {
+		ldapOrgIDs[org.ID] = true
+		if seen[org.Code] || org.Status != "enabled" || org.DeletedAt.Valid || len(units) == 0 {
+			continue
+		}
+		if err := tx.Model(&Organization{}).Where("id = ?", org.ID).Updates(map[string]any{
+			"status":  "disabled",
+			"version": gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		result.OrgsDisabled++
+	}
+	for _, id := range orgByDN {
+		ldapOrgIDs[id] = true
+	}
+
+	var role Role
+	if err := tx.Where("tenant_id = ? AND code = ?", tenantID, s.m.cfg.LDAP.OrgMemberRole).First(&role).Error; err != nil {
+		return fmt.Errorf("load ldap org member role %q: %w", s.m.cfg.LDAP.OrgMemberRole, err)
+	}
+	want := map[string]string{} // user → org
+	for dn, userID := range idx.byDN {
+		for p := parentDN(dn); p != ""; p = parentDN(p) {
+			if orgID, ok := orgByDN[p]; ok {
+				want[userID] = orgID
+				break
+			}
+		}
+	}
+	var current []UserRole
+	if err := tx.Where("tenant_id = ? AND role_id = ? AND scope_type = ?", tenantID, role.ID, "org").
+		Find(&current).Error; err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	var revoke []string
+	for _, ur := range current {
+		if !idx.ids[ur.UserID] || !ldapOrgIDs[ur.ScopeID] {
+			continue
+		}
+		if want[ur.UserID] == ur.ScopeID {
+			have[ur.UserID] = true
+			continue
+		}
+		revoke = append(revoke, ur.ID)
+		affected[ur.UserID] = true
+		result.OrgRevokes++
+	}
+	for _, batch := range chunkStrings(revoke, ldapBatchSize) {
+		if err := tx.Where("id IN ?", batch).Delete(&UserRole{}).Error; err != nil {
+			return err
+		}
+	}
+	for userID, orgID := range want {
+		if have[userID] {
+			continue
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UserRole{
+			ID:        newID(),
+			TenantID:  tenantID,
+			UserID:    userID,
+			RoleID:    role.ID,
+			ScopeType: "org",
+			ScopeID:   orgID,
+		}).Error; err != nil {
+			return err
+		}
+		affected[userID] = true
+		result.OrgGrants++
+	}
+	return nil
+}
+
+// syncGroups 把目录组映射为租户角色，并以目录成员为准调整 LDAP 来源用户的角色授权。
+// 不展开嵌套组。
+func (s *LDAPService) syncGroups(tx *gorm.DB, tenantID string, groups []ldapGroup, idx *ldapUserIndex,
+	result *LDAPSyncResult, affected map[string]bool) error {
+	mapping := make(map[string]string, len(s.m.cfg.LDAP.GroupRoleMapping))
+	for group, code := range s.m.cfg.LDAP.GroupRoleMapping {
+		mapping[strings.ToLower(strings.TrimSpace(group))] = strings.TrimSpace(code)
+	}
+
+	var roles []Role
+	if err := tx.Where("tenant_id = ?", tenantID).Find(&roles).Error; err != nil {
+		return err
+	}
+	roleByCode := make(map[string]*Role, len(roles))
+	for i := range roles {
+		roleByCode[roles[i].Code] = &roles[i]
+	}
+
+	// managed 为本次需要对账的角色及其期望成员；目录中已消失的组对应空集合
+	managed := map[string]map[string]bool{}
+	for _, g := range groups {
+		if g.Name == "" {
+			continue
+		}
+		code := ldapRoleCode(g.Name)
+		if len(mapping) > 0 {
+			if code = mapping[strings.ToLower(g.Name)]; code == "" {
+				continue
+			}
+		}
+		role := roleByCode[code]
+		if role == nil {
+			if len(mapping) > 0 {
+				gaia.WarnF("[account] ldap group %q maps to unknown role %q, skipped", g.Name, code)
+				continue
+			}
+			role = &Role{
+				ID:          newID(),
+				TenantID:    tenantID,
+				Code:        code,
+				Name:        truncateString(g.Name, 100),
+				Description: truncateString("LDAP 组 "+g.DN, 255),
+				Status:      "enabled",
+				Version:     1,
+			}
+			if err := tx.Create(role).Error; err != nil {
+				return err
+			}
+			roleByCode[code] = role
+			result.RolesCreated++
+		}
+		if managed[role.ID] == nil {
+			managed[role.ID] = map[string]bool{}
+		}
+		for _, member := range g.Members {
+			if userID, ok := idx.resolve(member); ok {
+				managed[role.ID][userID] = true
+			}
+		}
+	}
+	for _, role := range roleByCode {
+		if _, ok := managed[role.ID]; ok {
+			continue
+		}
+		isLDAPRole := len(mapping) == 0 && strings.HasPrefix(role.Code, ldapCodePrefix) && !role.IsSystem
+		if !isLDAPRole && !mappedRole(mapping, role.Code) {
+			continue
+		}
+		managed[role.ID] = map[string]bool{}
+	}
+
+	for roleID, want := range managed {
+		var current []UserRole
+		if err := tx.Where("tenant_id = ? AND role_id = ? AND scope_type = ?", tenantID, roleID, "tenant").
+			Find(&current).Error; err != nil {
+			return err
+		}
+		have := map[string]bool{}
+		var revoke []string
+		for _, ur := range current {
+			if !idx.ids[ur.UserID] {
+				continue
+			}
+			if want[ur.UserID] {
+				have[ur.UserID] = true
+				continue
+			}
+			revoke = append(revoke, ur.ID)
+			affected[ur.UserID] = true
+			result.RoleRevokes++
+		}
+		for _, batch := range chunkStrings(revoke, ldapBatchSize) {
+			if err := tx.Where("id IN ?", batch).Delete(&UserRole{}).Error; err != nil {
+				return err
+			}
+		}
+		for userID := range want {
+			if have[userID] {
+				continue
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UserRole{
+				ID:        newID(),
+				TenantID:  tenantID,
+				UserID:    userID,
+				RoleID:    roleID,
+				ScopeType: "tenant",
+				ScopeID:   tenantID,
+			}).Error; err != nil {
+				return err
+			}
+			affected[userID] = true
+			result.RoleGrants++
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// 本地账号映射
+// ============================================================================
+
+// linkedUser 返回目录身份关联的本地用户，未关联时返回 nil。
+// 关联的本地账号已被删除时清理失效关联，之后按新用户重新创建。
+func (s *LDAPService) linkedUser(tx *gorm.DB, tenantID, uniqueID string) (*User, error) {
+	var link OAuthAccount
+	err := tx.Where("tenant_id = ? AND provider = ? AND subject = ?", tenantID, LDAPProvider, uniqueID).First(&link).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	if err := tx.Where("id = ? AND tenant_id = ?", link.UserID, tenantID).First(&user).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		return nil, tx.Delete(&link).Error
+	}
+	return &user, nil
+}
+
+// applyEntry 把目录条目落到本地：current 为 nil 时创建账号并关联，否则同步资料。
+// 邮箱/手机号已被其他本地账号占用时不覆盖，避免按标识符静默合并账号。
+func (s *LDAPService) applyEntry(ctx context.Context, tx *gorm.DB, tenantID string, e *LDAPEntry, current *User) (*User, bool, error) {
+	if current == nil {
+		user, err := s.provision(ctx, tx, tenantID, e)
+		return user, true, err
+	}
+	updates := map[string]any{}
+	now := time.Now()
+	if name := truncateString(e.DisplayName, 80); name != "" && name != current.Nickname {
+		updates["nickname"] = name
+		current.Nickname = name
+	}
+	if e.Email != "" && e.Email != stringValue(current.Email) && isEmailIdentifier(e.Email) &&
+		!identifierTaken(tx, tenantID, "email", e.Email, current.ID) {
+		updates["email"] = e.Email
+		updates["email_verified_at"] = now
+		current.Email = nullableString(e.Email)
+		current.EmailVerifiedAt = &now
+	}
+	if e.Phone != "" && e.Phone != stringValue(current.Phone) &&
+		!identifierTaken(tx, tenantID, "phone", e.Phone, current.ID) {
+		updates["phone"] = e.Phone
+		updates["phone_verified_at"] = now
+		current.Phone = nullableString(e.Phone)
+		current.PhoneVerifiedAt = &now
+	}
+	if len(updates) == 0 {
+		return current, false, nil
+	}
+	updates["profile_version"] = gorm.Expr("profile_version + 1")
+	if err := tx.Model(&User{}).Where("id = ?", current.ID).Updates(updates).Error; err != nil {
+		return nil, false, err
+	}
+	if err := tx.Model(&OAuthAccount{}).Where("tenant_id = ? AND provider = ? AND subject = ?", tenantID, LDAPProvider, e.UniqueID).
+		Updates(map[string]any{"email": e.Email, "name": truncateString(e.DisplayName, 160)}).Error; err != nil {
+		return nil, false, err
+	}
+	s.m.auth.invalidateUserPrincipalCaches(ctx, current.ID)
+	return current, true, nil
+}
+
+// provision 为目录用户创建本地账号、关联目录身份并分配默认角色。
+func (s *LDAPService) provision(ctx context.Context, tx *gorm.DB, tenantID string, e *LDAPEntry) (*User, error) {
+	now := time.Now()
+	user := &User{
+		ID:             newID(),
+		TenantID:       tenantID,
+		Username:       truncateString(e.Username, 80),
+		Nickname:       truncateString(e.DisplayName, 80),
+		Status:         UserStatusNormal,
+		AuthVersion:    1,
+		RolesVersion:   1,
+		ProfileVersion: 1,
+	}
+	if identifierTaken(tx, tenantID, "username", user.Username, "") {
+		user.Username = fmt.Sprintf("%s_%s", truncateString(e.Username, 71), newID()[:8])
+	}
+	if e.Email != "" && isEmailIdentifier(e.Email) && !identifierTaken(tx, tenantID, "email", e.Email, "") {
+		user.Email = nullableString(e.Email)
+		user.EmailVerifiedAt = &now
+	}
+	if e.Phone != "" && !identifierTaken(tx, tenantID, "phone", e.Phone, "") {
+		user.Phone = nullableString(e.Phone)
+		user.PhoneVerifiedAt = &now
+	}
+	if s.m.cfg.AccountPolicy.RequireVerifiedPhone && user.PhoneVerifiedAt == nil {
+		user.Status = UserStatusPending
+	}
+	if err := s.m.tenantSvc.admitUser(tx, tenantID); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return nil, fmt.Errorf("create user from ldap: %w", err)
+	}
+	if err := tx.Create(&OAuthAccount{
+		ID:       newID(),
+		TenantID: tenantID,
+		UserID:   user.ID,
+		Provider: LDAPProvider,
+		Subject:  e.UniqueID,
+		Email:    e.Email,
+		Name:     truncateString(e.DisplayName, 160),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("create ldap account link: %w", err)
+	}
+	if err := s.m.auth.assignDefaultRole(ctx, tx, user); err != nil {
+		return nil, err
+	}
+	if err := emitOutbox(tx, EventLDAPUserProvisioned, user.ID, map[string]any{
+		"user_id":        user.ID,
+		"tenant_id":      tenantID,
+		"username":       user.Username,
+		"dn":             e.DN,
+		"provisioned_at": now,
+	}); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// identifierTaken 判断 username/email/phone 是否已被 excludeUserID 以外的本地账号占用。
+func identifierTaken(tx *gorm.DB, tenantID, column, value, excludeUserID string) bool {
+	var count int64
+	q := tx.Unscoped().Model(&User{}).Where("tenant_id = ? AND "+column+" = ?", tenantID, value)
+	if excludeUserID != "" {
+		q = q.Where("id <> ?", excludeUserID)
+	}
+	if err := q.Count(&count).Error; err != nil {
+		return true
+	}
+	return count > 0
+}
+
+// ============================================================================
+// 连接与条目解析
+// ============================================================================
+
+func (s *LDAPService) tenantID(tenantID string) string {
+	if tenantID == "" {
+		tenantID = s.m.cfg.LDAP.TenantID
+	}
+	return s.m.tenantID(tenantID)
+}
+
+// dial 建立连接；配置 StartTLS 时在绑定前升级为 TLS。
+func (s *LDAPService) dial() (*ldap.Conn, error) {
+	cfg := s.m.cfg.LDAP
+	tlsConfig := s.tlsConfig()
+	conn, err := ldap.DialURL(cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("ldap dial: %w", err)
+	}
+	conn.SetTimeout(cfg.Timeout)
+	if cfg.StartTLS && !strings.HasPrefix(strings.ToLower(cfg.URL), "ldaps://") {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap starttls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (s *LDAPService) tlsConfig() *tls.Config {
+	cfg := s.m.cfg.LDAP
+	var tc *tls.Config
+	if cfg.TLSConfig != nil {
+		tc = cfg.TLSConfig.Clone()
+	} else {
+		tc = &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	}
+	if tc.ServerName == "" {
+		if u, err := url.Parse(cfg.URL); err == nil {
+			tc.ServerName = u.Hostname()
+		}
+	}
+	return tc
+}
+
+// bindService 以服务账号绑定；未配置服务账号时保持匿名。
+func (s *LDAPService) bindService(conn *ldap.Conn) error {
+	cfg := s.m.cfg.LDAP
+	if cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
+		return fmt.Errorf("ldap service bind: %w", err)
+	}
+	return nil
+}
+
+// findUser 按 UserFilter 查找唯一的用户条目；找不到或匹配多条都按认证失败处理。
+func (s *LDAPService) findUser(conn *ldap.Conn, username string) (*LDAPEntry, error) {
+	cfg := s.m.cfg.LDAP
+	filter := fmt.Sprintf(cfg.UserFilter, ldap.EscapeFilter(username))
+	res, err := conn.Search(ldap.NewSearchRequest(cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2,
+		s.timeLimit(), false, filter, s.userAttributes(), nil))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, accountError(ErrInvalidCredential, "账号或密码错误")
+		}
+		return nil, fmt.Errorf("ldap search user: %w", err)
+	}
+	if len(res.Entries) != 1 {
+		return nil, accountError(ErrInvalidCredential, "账号或密码错误")
+	}
+	return s.toEntry(res.Entries[0]), nil
+}
+
+func (s *LDAPService) search(conn *ldap.Conn, baseDN, filter string, attrs []string) ([]*ldap.Entry, error) {
+	res, err := conn.SearchWithPaging(ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0,
+		s.timeLimit(), false, filter, attrs, nil), ldapPageSize)
+	if err != nil {
+		return nil, err
+	}
+	return res.Entries, nil
+}
+
+func (s *LDAPService) timeLimit() int {
+	return int(s.m.cfg.LDAP.Timeout / time.Second)
+}
+
+func (s *LDAPService) userAttributes() []string {
+	cfg := s.m.cfg.LDAP
+	return []string{cfg.UsernameAttr, cfg.EmailAttr, cfg.DisplayNameAttr, "cn", cfg.PhoneAttr, cfg.UniqueIDAttr}
+}
+
+func (s *LDAPService) toEntry(e *ldap.Entry) *LDAPEntry {
+	cfg := s.m.cfg.LDAP
+	out := &LDAPEntry{
+		DN:          e.DN,
+		UniqueID:    ldapUniqueID(e.GetEqualFoldRawAttributeValue(cfg.UniqueIDAttr)),
+		Username:    strings.TrimSpace(e.GetEqualFoldAttributeValue(cfg.UsernameAttr)),
+		Email:       normalizeEmail(e.GetEqualFoldAttributeValue(cfg.EmailAttr)),
+		DisplayName: strings.TrimSpace(e.GetEqualFoldAttributeValue(cfg.DisplayNameAttr)),
+		Phone:       normalizePhone(e.GetEqualFoldAttributeValue(cfg.PhoneAttr)),
+	}
+	if out.DisplayName == "" {
+		out.DisplayName = strings.TrimSpace(e.GetEqualFoldAttributeValue("cn"))
+	}
+	if out.UniqueID == "" {
+		// 目录不提供唯一 ID 时退回到 DN 摘要；用户被移动到其他 OU 后会被视为新身份
+		sum := sha256.Sum256([]byte(normalizeDN(e.DN)))
+		out.UniqueID = "dn:" + hex.EncodeToString(sum[:])
+	}
+	return out
+}
+
+// ldapUniqueID 把唯一 ID 属性转成字符串；objectGUID 等二进制值使用十六进制。
+func ldapUniqueID(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	if utf8.Valid(raw) && !strings.ContainsFunc(string(raw), func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return truncateString(string(raw), 128)
+	}
+	return hex.EncodeToString(raw)
+}
+
+// normalizeDN 返回小写规范化的 DN，便于比较；无法解析时退回到去空白的小写原文。
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(dn))
+	}
+	return strings.ToLower(parsed.String())
+}
+
+// parentDN 返回去掉首个 RDN 后的规范化 DN，已是根时返回空串。
+func parentDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) <= 1 {
+		return ""
+	}
+	return strings.ToLower((&ldap.DN{RDNs: parsed.RDNs[1:]}).String())
+}
+
+func dnDepth(dn string) int {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return 0
+	}
+	return len(parsed.RDNs)
+}
+
+// ldapOrgCode 由 DN 摘要生成稳定的组织编码，OU 改名不影响编码。
+func ldapOrgCode(dn string) string {
+	sum := sha256.Sum256([]byte(normalizeDN(dn)))
+	return ldapCodePrefix + hex.EncodeToString(sum[:16])
+}
+
+// ldapRoleCode 自动映射时由组名生成角色编码。
+func ldapRoleCode(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return truncateString(ldapCodePrefix+b.String(), 100)
+}
+
+func mappedRole(mapping map[string]string, code string) bool {
+	for _, c := range mapping {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func chunkStrings(list []string, size int) [][]string {
+	var out [][]string
+	for len(list) > size {
+		out = append(out, list[:size])
+		list = list[size:]
+	}
+	if len(list) > 0 {
+		out = append(out, list)
+	}
+	return out
+}
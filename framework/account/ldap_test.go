@@ -0,0 +1,524 @@
+package account
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/xxzhwl/gaia/errwrap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ldapTestEntry 测试目录中的条目，属性名不区分大小写。
+type ldapTestEntry struct {
+	dn       string
+	attrs    map[string][]string
+	password string
+}
+
+// ldapTestServer 进程内的最小 LDAP 服务，支持 Bind / Search / StartTLS / Unbind。
+type ldapTestServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	entries  []*ldapTestEntry
+	startTLS int
+}
+
+func newLDAPTestServer(t *testing.T, entries []*ldapTestEntry) *ldapTestServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &ldapTestServer{ln: ln, entries: entries, tlsConfig: &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return srv
+}
+
+func (srv *ldapTestServer) url() string { return "ldap://" + srv.ln.Addr().String() }
+
+func (srv *ldapTestServer) find(dn string) *ldapTestEntry {
+	for _, e := range srv.entries {
+		if normalizeDN(e.dn) == normalizeDN(dn) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (srv *ldapTestServer) update(fn func()) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	fn()
+}
+
+func (srv *ldapTestServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		msgID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case 0: // BindRequest
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			srv.mu.Lock()
+			e := srv.find(dn)
+			srv.mu.Unlock()
+			code := 0
+			if e == nil || e.password == "" || e.password != password {
+				code = 49
+			}
+			_, _ = conn.Write(ldapTestResult(msgID, 1, code).Bytes())
+		case 2: // UnbindRequest
+			return
+		case 3: // SearchRequest
+			srv.search(conn, msgID, op)
+		case 23: // ExtendedRequest
+			if op.Children[0].Data.String() != "1.3.6.1.4.1.1466.20037" {
+				_, _ = conn.Write(ldapTestResult(msgID, 24, 2).Bytes())
+				continue
+			}
+			_, _ = conn.Write(ldapTestResult(msgID, 24, 0).Bytes())
+			tlsConn := tls.Server(conn, srv.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.startTLS++
+			srv.mu.Unlock()
+			conn = tlsConn
+		}
+	}
+}
+
+func (srv *ldapTestServer) search(conn net.Conn, msgID int64, op *ber.Packet) {
+	base := op.Children[0].Value.(string)
+	scope := op.Children[1].Value.(int64)
+	filter := op.Children[6]
+	var attrs []string
+	for _, a := range op.Children[7].Children {
+		attrs = append(attrs, a.Value.(string))
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	found := false
+	for _, e := range srv.entries {
+		dn := normalizeDN(e.dn)
+		switch scope {
+		case 0:
+			if dn != normalizeDN(base) {
+				continue
+			}
+		case 1:
+			if parentDN(dn) != normalizeDN(base) {
+				continue
+			}
+		default:
+			if dn != normalizeDN(base) && !strings.HasSuffix(dn, ","+normalizeDN(base)) {
+				continue
+			}
+		}
+		found = found || dn == normalizeDN(base)
+		if !ldapTestMatch(filter, e) {
+			continue
+		}
+		_, _ = conn.Write(ldapTestSearchEntry(msgID, e, attrs).Bytes())
+	}
+	code := 0
+	if scope == 0 && !found {
+		code = 32
+	}
+	_, _ = conn.Write(ldapTestResult(msgID, 5, code).Bytes())
+}
+
+func ldapTestValues(e *ldapTestEntry, attr string) []string {
+	for k, v := range e.attrs {
+		if strings.EqualFold(k, attr) {
+			return v
+		}
+	}
+	return nil
+}
+
+func ldapTestMatch(f *ber.Packet, e *ldapTestEntry) bool {
+	switch f.Tag {
+	case 0: // and
+		for _, c := range f.Children {
+			if !ldapTestMatch(c, e) {
+				return false
+			}
+		}
+		return true
+	case 1: // or
+		for _, c := range f.Children {
+			if ldapTestMatch(c, e) {
+				return true
+			}
+		}
+		return false
+	case 2: // not
+		return !ldapTestMatch(f.Children[0], e)
+	case 3: // equalityMatch
+		want := f.Children[1].Value.(string)
+		for _, v := range ldapTestValues(e, f.Children[0].Value.(string)) {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+		return false
+	case 7: // present
+		return len(ldapTestValues(e, f.Data.String())) > 0
+	}
+	return false
+}
+
+func ldapTestResult(msgID int64, tag ber.Tag, code int) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, ""))
+	r := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	r.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	p.AppendChild(r)
+	return p
+}
+
+func ldapTestSearchEntry(msgID int64, e *ldapTestEntry, attrs []string) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, ""))
+	r := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "")
+	r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, ""))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for name, values := range e.attrs {
+		wanted := len(attrs) == 0
+		for _, a := range attrs {
+			wanted = wanted || strings.EqualFold(a, name)
+		}
+		if !wanted {
+			continue
+		}
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+		}
+		attr.AppendChild(set)
+		list.AppendChild(attr)
+	}
+	r.AppendChild(list)
+	p.AppendChild(r)
+	return p
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func ldapTestDirectory() []*ldapTestEntry {
+	return []*ldapTestEntry{
+		{dn: "cn=svc,dc=corp,dc=test", password: "svc-secret", attrs: map[string][]string{"objectClass": {"person"}, "cn": {"svc"}}},
+		{dn: "ou=people,dc=corp,dc=test", attrs: map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"People"}}},
+		{dn: "ou=eng,ou=people,dc=corp,dc=test", attrs: map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"Engineering"}}},
+		{dn: "uid=alice,ou=eng,ou=people,dc=corp,dc=test", password: "alice-pass", attrs: map[string][]string{
+			"objectClass": {"person"}, "uid": {"alice"}, "mail": {"Alice@Corp.Test"},
+			"displayName": {"Alice Liddell"}, "entryUUID": {"uuid-alice"},
+		}},
+		{dn: "uid=bob,ou=people,dc=corp,dc=test", password: "bob-pass", attrs: map[string][]string{
+			"objectClass": {"person"}, "uid": {"bob"}, "mail": {"bob@corp.test"}, "cn": {"Bob"}, "entryUUID": {"uuid-bob"},
+		}},
+		{dn: "ou=groups,dc=corp,dc=test", attrs: map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"Groups"}}},
+		{dn: "cn=Developers,ou=groups,dc=corp,dc=test", attrs: map[string][]string{
+			"objectClass": {"groupOfNames"}, "cn": {"Developers"},
+			"member": {"uid=alice,ou=eng,ou=people,dc=corp,dc=test"},
+		}},
+		{dn: "cn=Ops,ou=groups,dc=corp,dc=test", attrs: map[string][]string{
+			"objectClass": {"groupOfNames"}, "cn": {"Ops"},
+			"member": {"UID=Alice,OU=Eng,OU=People,DC=corp,DC=test", "uid=bob,ou=people,dc=corp,dc=test"},
+		}},
+	}
+}
+
+func newLDAPTestManager(t *testing.T, srv *ldapTestServer, mutate func(*LDAPConfig)) *Manager {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ldap.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	cert, _ := x509.ParseCertificate(srv.tlsConfig.Certificates[0].Certificate[0])
+	pool.AddCert(cert)
+	cfg := testAuthConfig()
+	cfg.DB = db
+	cfg.LDAP = LDAPConfig{
+		URL:          srv.url(),
+		StartTLS:     true,
+		TLSConfig:    &tls.Config{RootCAs: pool},
+		BindDN:       "cn=svc,dc=corp,dc=test",
+		BindPassword: "svc-secret",
+		BaseDN:       "ou=people,dc=corp,dc=test",
+		GroupBaseDN:  "ou=groups,dc=corp,dc=test",
+		OrgBaseDN:    "ou=people,dc=corp,dc=test",
+	}
+	if mutate != nil {
+		mutate(&cfg.LDAP)
+	}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Bootstrap(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func ldapUserRoles(t *testing.T, m *Manager, userID string) map[string]bool {
+	t.Helper()
+	var codes []string
+	if err := m.db.Table("acct_user_roles").Joins("JOIN acct_roles ON acct_roles.id = acct_user_roles.role_id").
+		Where("acct_user_roles.user_id = ? AND acct_user_roles.scope_type = ?", userID, "tenant").
+		Pluck("acct_roles.code", &codes).Error; err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]bool{}
+	for _, c := range codes {
+		out[c] = true
+	}
+	return out
+}
+
+func TestLDAPLoginProvisionsUser(t *testing.T) {
+	srv := newLDAPTestServer(t, ldapTestDirectory())
+	m := newLDAPTestManager(t, srv, nil)
+	ctx := context.Background()
+
+	res, err := m.LDAP().Login(ctx, LDAPLoginRequest{Username: "alice", Password: "alice-pass", IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("LDAP 登录失败: %v", err)
+	}
+	if res.AccessToken == "" || res.User.Username != "alice" {
+		t.Fatalf("登录结果错误: %+v", res)
+	}
+	if srv.startTLS == 0 {
+		t.Fatal("应先执行 StartTLS")
+	}
+	var user User
+	m.db.Where("id = ?", res.User.ID).First(&user)
+	if stringValue(user.Email) != "alice@corp.test" || user.EmailVerifiedAt == nil || user.Nickname != "Alice Liddell" {
+		t.Fatalf("自动创建的用户资料错误: %+v", user)
+	}
+	if roles := ldapUserRoles(t, m, user.ID); !roles["user"] {
+		t.Fatalf("应分配默认角色，实际 %v", roles)
+	}
+
+	// 目录资料变更后再次登录，复用同一本地账号并同步资料
+	srv.update(func() {
+		srv.find("uid=alice,ou=eng,ou=people,dc=corp,dc=test").attrs["displayName"] = []string{"Alice L."}
+	})
+	res2, err := m.LDAP().Login(ctx, LDAPLoginRequest{Username: "ALICE", Password: "alice-pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res2.User.ID != user.ID || res2.User.Nickname != "Alice L." {
+		t.Fatalf("再次登录应复用账号并更新昵称: %+v", res2.User)
+	}
+
+	if _, err := m.LDAP().Login(ctx, LDAPLoginRequest{Username: "alice", Password: "wrong"}); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("错误口令应返回 401，实际 %v", err)
+	}
+	// 空口令会被目录当作匿名绑定，必须在本地拒绝
+	if _, err := m.LDAP().Login(ctx, LDAPLoginRequest{Username: "alice", Password: ""}); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("空口令应返回 400，实际 %v", err)
+	}
+	if _, err := m.LDAP().Login(ctx, LDAPLoginRequest{Username: "*", Password: "alice-pass"}); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("通配用户名不应匹配任何条目，实际 %v", err)
+	}
+
+	m.db.Model(&User{}).Where("id = ?", user.ID).Update("status", UserStatusDisabled)
+	if _, err := m.LDAP().Login(ctx, LDAPLoginRequest{Username: "alice", Password: "alice-pass"}); errwrap.GetCode(err) != ErrPermissionDenied {
+		t.Fatalf("本地已禁用的账号应拒绝登录，实际 %v", err)
+	}
+}
+
+func TestLDAPLoginWithDNTemplate(t *testing.T) {
+	srv := newLDAPTestServer(t, ldapTestDirectory())
+	m := newLDAPTestManager(t, srv, func(c *LDAPConfig) {
+		c.StartTLS = false
+		c.BindDN, c.BindPassword, c.BaseDN = "", "", ""
+		c.UserDNTemplate = "uid=%s,ou=people,dc=corp,dc=test"
+	})
+	ctx := context.Background()
+
+	// 本地已有同名账号时，目录用户使用带后缀的用户名，不会合并到本地账号
+	if err := m.Admin().CreateUser(ctx, &User{Username: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := m.LDAP().Login(ctx, LDAPLoginRequest{Username: "bob", Password: "bob-pass"})
+	if err != nil {
+		t.Fatalf("DN 模板登录失败: %v", err)
+	}
+	if !strings.HasPrefix(res.User.Username, "bob_") || res.User.Nickname != "Bob" {
+		t.Fatalf("用户名冲突时应追加后缀: %+v", res.User)
+	}
+	if _, err := m.LDAP().Login(ctx, LDAPLoginRequest{Username: "bob,ou=people", Password: "bob-pass"}); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("DN 特殊字符应被转义，实际 %v", err)
+	}
+}
+
+func TestLDAPSync(t *testing.T) {
+	srv := newLDAPTestServer(t, ldapTestDirectory())
+	m := newLDAPTestManager(t, srv, func(c *LDAPConfig) { c.DisableMissingUsers = true })
+	ctx := context.Background()
+
+	result, err := m.LDAP().Sync(ctx)
+	if err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	if result.UsersCreated != 2 || result.RolesCreated != 2 || result.RoleGrants != 3 || result.OrgsCreated != 2 || result.OrgGrants != 2 {
+		t.Fatalf("首次同步统计错误: %+v", result)
+	}
+	var alice, bob User
+	m.db.Where("username = ?", "alice").First(&alice)
+	m.db.Where("username = ?", "bob").First(&bob)
+	if roles := ldapUserRoles(t, m, alice.ID); !roles["ldap_developers"] || !roles["ldap_ops"] || !roles["user"] {
+		t.Fatalf("alice 角色错误: %v", roles)
+	}
+
+	var people, eng Organization
+	m.db.Where("code = ?", ldapOrgCode("ou=people,dc=corp,dc=test")).First(&people)
+	m.db.Where("code = ?", ldapOrgCode("ou=eng,ou=people,dc=corp,dc=test")).First(&eng)
+	if people.Name != "People" || eng.Name != "Engineering" || eng.ParentID == nil || *eng.ParentID != people.ID {
+		t.Fatalf("组织树错误: people=%+v eng=%+v", people, eng)
+	}
+	var member UserRole
+	if err := m.db.Where("user_id = ? AND scope_type = ?", alice.ID, "org").First(&member).Error; err != nil || member.ScopeID != eng.ID {
+		t.Fatalf("alice 应归属 Engineering: %+v %v", member, err)
+	}
+
+	// 本地账号被手工授予 LDAP 角色，不受目录对账影响
+	local := &User{Username: "carol"}
+	if err := m.Admin().CreateUser(ctx, local); err != nil {
+		t.Fatal(err)
+	}
+	var ops Role
+	m.db.Where("code = ?", "ldap_ops").First(&ops)
+	if err := m.Admin().AssignUserRole(ctx, local.ID, ops.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 幂等：目录不变时再次同步不产生变更
+	if result, err = m.LDAP().Sync(ctx); err != nil || result.UsersCreated+result.UsersUpdated+result.RoleGrants+result.RoleRevokes+result.OrgGrants != 0 {
+		t.Fatalf("重复同步不应产生变更: %+v %v", result, err)
+	}
+
+	// alice 移出 Ops，bob 从目录删除
+	srv.update(func() {
+		srv.find("cn=Ops,ou=groups,dc=corp,dc=test").attrs["member"] = []string{"uid=bob,ou=people,dc=corp,dc=test"}
+		var kept []*ldapTestEntry
+		for _, e := range srv.entries {
+			if !strings.HasPrefix(e.dn, "uid=bob") {
+				kept = append(kept, e)
+			}
+		}
+		srv.entries = kept
+	})
+	if result, err = m.LDAP().Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if result.UsersDisabled != 1 || result.RoleRevokes != 2 || result.OrgRevokes != 1 {
+		t.Fatalf("目录变更后的同步统计错误: %+v", result)
+	}
+	if roles := ldapUserRoles(t, m, alice.ID); roles["ldap_ops"] || !roles["ldap_developers"] {
+		t.Fatalf("alice 应只保留 Developers: %v", roles)
+	}
+	m.db.Where("id = ?", bob.ID).First(&bob)
+	if bob.Status != UserStatusDisabled || bob.AuthVersion != 2 {
+		t.Fatalf("目录中删除的用户应被禁用: %+v", bob)
+	}
+	if roles := ldapUserRoles(t, m, local.ID); !roles["ldap_ops"] {
+		t.Fatalf("本地账号的手工授权不应被撤销: %v", roles)
+	}
+}
+
+func TestLDAPSyncGroupRoleMapping(t *testing.T) {
+	srv := newLDAPTestServer(t, ldapTestDirectory())
+	m := newLDAPTestManager(t, srv, func(c *LDAPConfig) {
+		c.OrgBaseDN = ""
+		c.GroupRoleMapping = map[string]string{"ops": "tenant_admin"}
+	})
+	result, err := m.LDAP().Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RolesCreated != 0 || result.RoleGrants != 2 || result.OrgsCreated != 0 {
+		t.Fatalf("映射模式统计错误: %+v", result)
+	}
+	var alice User
+	m.db.Where("username = ?", "alice").First(&alice)
+	if roles := ldapUserRoles(t, m, alice.ID); !roles["tenant_admin"] || roles["ldap_developers"] {
+		t.Fatalf("只应同步映射中的组: %v", roles)
+	}
+}
+
+func TestLDAPHelpers(t *testing.T) {
+	if got := parentDN("UID=Alice, OU=Eng,dc=corp"); got != "ou=eng,dc=corp" {
+		t.Fatalf("parentDN = %q", got)
+	}
+	if normalizeDN("CN=A,DC=X") != normalizeDN("cn=a, dc=x") {
+		t.Fatal("normalizeDN 应忽略大小写和空白")
+	}
+	if got := ldapUniqueID([]byte{0x01, 0xff, 0x10}); got != "01ff10" {
+		t.Fatalf("二进制唯一 ID 应转为十六进制，实际 %q", got)
+	}
+	if got := ldapRoleCode("Domain Admins"); got != "ldap_domain_admins" {
+		t.Fatalf("ldapRoleCode = %q", got)
+	}
+}
@@ -20,6 +20,8 @@ type Manager struct {
 	users        *UserService
 	roles        *RoleService
 	authorizer   *Authorizer
+	policySvc    *PolicyService
+	rebacSvc     *ReBACService
 	middleware   *Middleware
 	verification *VerificationService
 	risk         *RiskService
@@ -32,6 +34,11 @@ type Manager struct {
 	passkeySvc   *PasskeyService
 	apiTokenSvc  *APITokenService
 	consentSvc   *ConsentService
+	exportSvc    *DataExportService
+	scimSvc      *SCIMService
+	ldapSvc      *LDAPService
+	samlSvc      *SAMLService
+	tenantSvc    *TenantService
 	health       *HealthService
 	metrics      *AccountMetrics
 	tracer       trace.Tracer
@@ -49,6 +56,8 @@ func newManager(cfg Config) *Manager {
 	m.users = &UserService{m: m}
 	m.roles = &RoleService{m: m}
 	m.authorizer = &Authorizer{m: m}
+	m.policySvc = &PolicyService{m: m}
+	m.rebacSvc = newReBACService(m)
 	m.middleware = &Middleware{m: m}
 	m.verification = &VerificationService{m: m}
 	m.risk = &RiskService{m: m}
@@ -61,6 +70,11 @@ func newManager(cfg Config) *Manager {
 	m.passkeySvc = &PasskeyService{m: m}
 	m.apiTokenSvc = &APITokenService{m: m}
 	m.consentSvc = &ConsentService{m: m}
+	m.exportSvc = &DataExportService{m: m}
+	m.scimSvc = &SCIMService{m: m}
+	m.ldapSvc = &LDAPService{m: m}
+	m.samlSvc = &SAMLService{m: m}
+	m.tenantSvc = &TenantService{m: m}
 	m.health = &HealthService{m: m}
 	m.metrics = initAccountMetrics()
 	m.tracer = otel.Tracer("github.com/xxzhwl/gaia/framework/account")
@@ -69,7 +83,15 @@ func newManager(cfg Config) *Manager {
 
 // Bootstrap 运行所有账户表的自动迁移并填充默认角色和权限。
 func (m *Manager) Bootstrap(ctx context.Context) error {
-	if err := m.db.WithContext(ctx).AutoMigrate(
+	if err := m.db.WithContext(ctx).AutoMigrate(accountModels()...); err != nil {
+		return fmt.Errorf("account migrate tables: %w", err)
+	}
+	return m.seedDefaults(ctx)
+}
+
+// accountModels 返回账户模块的全部表模型，供迁移与租户级联删除使用。
+func accountModels() []any {
+	return []any{
 		&User{},
 		&Credential{},
 		&Session{},
@@ -81,22 +103,34 @@ func (m *Manager) Bootstrap(ctx context.Context) error {
 		&AuditLog{},
 		&AuditLogArchive{},
 		&VerificationChallenge{},
+		&VerificationDelivery{},
+		&KnownDevice{},
 		&AccessTokenDenylist{},
 		&MFAChallenge{},
 		&OAuthAccount{},
+		&OAuthState{},
 		&Organization{},
 		&IdpClient{},
 		&AuthorizationCode{},
 		&PasskeyCredential{},
+		&PasskeyChallenge{},
 		&OutboxEvent{},
 		&Policy{},
 		&PersonalAccessToken{},
 		&AuthorizedApp{},
 		&UserConsent{},
-	); err != nil {
-		return fmt.Errorf("account migrate tables: %w", err)
+		&SCIMExternalID{},
+		&SAMLConnection{},
+		&SAMLSession{},
+		&SAMLLoginTicket{},
+		&RelationTuple{},
+		&MFAPolicy{},
+		&Invitation{},
+		&DataExport{},
+		&DeviceAuthorization{},
+		&Tenant{},
+		&TenantDomain{},
 	}
-	return m.seedDefaults(ctx)
 }
 
 // Close 释放底层资源，包括：
@@ -173,7 +207,12 @@ func (m *Manager) Admin() *AdminService {
 
 // Policies 返回 PolicyService，用于 ABAC 策略管理。
 func (m *Manager) Policies() *PolicyService {
-	return &PolicyService{m: m}
+	return m.policySvc
+}
+
+// ReBAC 返回 ReBACService，用于关系元组管理与关系型授权。
+func (m *Manager) ReBAC() *ReBACService {
+	return m.rebacSvc
 }
 
 // Verification 返回 VerificationService，用于邮件/短信验证码验证。
@@ -236,6 +275,31 @@ func (m *Manager) Consents() *ConsentService {
 	return m.consentSvc
 }
 
+// DataExports 返回 DataExportService，用于用户自助数据导出。
+func (m *Manager) DataExports() *DataExportService {
+	return m.exportSvc
+}
+
+// SCIM 返回 SCIMService，用于 SCIM 2.0 用户与组的预配置。
+func (m *Manager) SCIM() *SCIMService {
+	return m.scimSvc
+}
+
+// LDAP 返回 LDAP / Active Directory 认证与目录同步服务。
+func (m *Manager) LDAP() *LDAPService {
+	return m.ldapSvc
+}
+
+// SAML 返回 SAML 2.0 服务提供方（企业单点登录与单点登出）。
+func (m *Manager) SAML() *SAMLService {
+	return m.samlSvc
+}
+
+// Tenants 返回 TenantService，用于租户生命周期、租户级配置、配额与自定义域名。
+func (m *Manager) Tenants() *TenantService {
+	return m.tenantSvc
+}
+
 // Cleanup 清理过期的刷新令牌、会话、验证挑战和黑名单条目。
 // 使用分布式锁防止多个实例同时执行清理。
 // 委托给 cleanupAll 统一实现。
@@ -293,6 +357,7 @@ func (m *Manager) seedDefaults(ctx context.Context) error {
 			{Code: "audit:read", ResourceType: "audit", Action: "read", Description: "查看审计"},
 			{Code: "security:event:read", ResourceType: "security_event", Action: "read", Description: "查看安全事件"},
 			{Code: "security:policy:write", ResourceType: "security_policy", Action: "write", Description: "修改安全策略"},
+			{Code: PermissionSCIMProvision, ResourceType: "scim", Action: "provision", Description: "SCIM 预配置用户与组"},
 		}
 		for _, perm := range perms {
 			perm.ID = newID()
@@ -335,6 +400,7 @@ func (m *Manager) audit(ctx context.Context, tenantID, userID, event, status, re
 		ID:        newID(),
 		TenantID:  m.tenantID(tenantID),
 		UserID:    userID,
+		ActorID:   actorFromContext(ctx),
 		Event:     event,
 		Status:    status,
 		Reason:    reason,
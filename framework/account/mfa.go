@@ -449,7 +449,8 @@ func (s *AuthService) CompleteMFA(ctx context.Context, challengeID, code string,
 		if err != nil {
 			return err
 		}
-		result, err = s.issueTokens(ctx, tx, &user, roles, req.DeviceID, req.IP, req.UserAgent, "", "", "")
+		// 登录前一步的第一因素未记录在挑战上，amr 只写第二因素并标记 mfa
+		result, err = s.issueTokens(ctx, tx, &user, roles, []string{amrForMethod(challenge.Method), AMRMultiFactor}, req.DeviceID, req.IP, req.UserAgent, "", "", "")
 		if err != nil {
 			return err
 		}
@@ -475,6 +476,7 @@ func (s *AuthService) CompleteMFA(ctx context.Context, challengeID, code string,
 	mfaStart := time.Now()
 	s.m.audit(ctx, tenantID, userID, "mfa", "success", "", req.IP, req.UserAgent)
 	s.m.audit(ctx, tenantID, userID, "login", "success", "with mfa", req.IP, req.UserAgent)
+	s.m.risk.RecordLogin(ctx, RiskContext{TenantID: tenantID, UserID: userID, IP: req.IP, UserAgent: req.UserAgent, DeviceID: req.DeviceID, ClientHints: req.ClientHints})
 	_ = emitOutbox(s.m.db.WithContext(ctx), EventUserLoggedIn, userID, map[string]any{
 		"user_id": userID,
 		"tenant_id": tenantID,
@@ -496,9 +498,10 @@ func (s *AuthService) CompleteMFA(ctx context.Context, challengeID, code string,
 
 // CompleteMFARequest 最终令牌颁发的设备信息请求参数。
 type CompleteMFARequest struct {
-	DeviceID  string
-	IP        string
-	UserAgent string
+	DeviceID    string
+	IP          string
+	UserAgent   string
+	ClientHints map[string]string
 }
 
 // generateRecoveryCode returns a cryptographically random recovery code.
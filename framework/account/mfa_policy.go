@@ -0,0 +1,123 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MFAPolicy 租户级 MFA 强制策略：持有 RequiredRoles 中任一角色的用户必须启用 TOTP。
+// EnforceAfter 之前为宽限期，未启用的用户仍可登录，登录结果带 mfa_enrollment_required 提示；
+// 之后（或 EnforceAfter 为空）未启用的用户无法登录，敏感操作一律拒绝。
+type MFAPolicy struct {
+	ID            string     `json:"id" gorm:"size:36;primaryKey"`
+	TenantID      string     `json:"tenant_id" gorm:"size:64;not null;uniqueIndex:uniq_acct_mfa_policies_tenant"`
+	RequiredRoles string     `json:"required_roles" gorm:"size:512;not null"` // 逗号分隔的角色编码
+	EnforceAfter  *time.Time `json:"enforce_after"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (MFAPolicy) TableName() string { return "acct_mfa_policies" }
+
+// Roles 返回策略要求启用 MFA 的角色编码。
+func (p *MFAPolicy) Roles() []string {
+	if p.RequiredRoles == "" {
+		return nil
+	}
+	return strings.Split(p.RequiredRoles, ",")
+}
+
+// MFAPolicyRequest 设置租户 MFA 强制策略的参数。
+type MFAPolicyRequest struct {
+	RequiredRoles []string   `json:"required_roles"`
+	EnforceAfter  *time.Time `json:"enforce_after"`
+}
+
+// GetPolicy 返回租户的 MFA 强制策略，未配置时返回 nil。
+func (s *MFAService) GetPolicy(ctx context.Context, tenantID string) (*MFAPolicy, error) {
+	var policy MFAPolicy
+	if err := s.m.db.WithContext(ctx).Where("tenant_id = ?", s.m.tenantID(tenantID)).First(&policy).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("load mfa policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// SetPolicy 创建或覆盖租户的 MFA 强制策略。
+func (s *MFAService) SetPolicy(ctx context.Context, tenantID string, req MFAPolicyRequest) (*MFAPolicy, error) {
+	tenantID = s.m.tenantID(tenantID)
+	roles := make([]string, 0, len(req.RequiredRoles))
+	for _, code := range req.RequiredRoles {
+		code = strings.TrimSpace(code)
+		if code == "" || contains(roles, code) {
+			continue
+		}
+		if strings.Contains(code, ",") {
+			return nil, accountError(ErrInvalidArgument, "角色编码不能包含逗号: "+code)
+		}
+		roles = append(roles, code)
+	}
+	if len(roles) == 0 {
+		return nil, accountError(ErrInvalidArgument, "required_roles 不能为空")
+	}
+	policy := MFAPolicy{
+		ID:            newID(),
+		TenantID:      tenantID,
+		RequiredRoles: strings.Join(roles, ","),
+		EnforceAfter:  req.EnforceAfter,
+	}
+	if len(policy.RequiredRoles) > 512 {
+		return nil, accountError(ErrInvalidArgument, "required_roles 过长")
+	}
+	if err := s.m.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"required_roles", "enforce_after", "updated_at"}),
+	}).Create(&policy).Error; err != nil {
+		return nil, fmt.Errorf("save mfa policy: %w", err)
+	}
+	s.m.audit(ctx, tenantID, "", "mfa_policy", "updated", truncateString("roles: "+policy.RequiredRoles, 255), "", "")
+	return s.GetPolicy(ctx, tenantID)
+}
+
+// DeletePolicy 删除租户的 MFA 强制策略。
+func (s *MFAService) DeletePolicy(ctx context.Context, tenantID string) error {
+	tenantID = s.m.tenantID(tenantID)
+	if err := s.m.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Delete(&MFAPolicy{}).Error; err != nil {
+		return fmt.Errorf("delete mfa policy: %w", err)
+	}
+	s.m.audit(ctx, tenantID, "", "mfa_policy", "deleted", "", "", "")
+	return nil
+}
+
+// enrollmentState 判断尚未启用 TOTP 的用户是否被要求启用 MFA。
+// required 表示命中 Config.AccountPolicy.RequireAdminMFA、租户设置 RequireMFA 或租户 MFA 策略；
+// enforced 表示已过宽限期，登录应被拒绝（RequireAdminMFA 总是立即生效）。
+func (s *MFAService) enrollmentState(ctx context.Context, tenantID, userID string, roles []string) (required, enforced bool) {
+	if s.HasTOTP(ctx, tenantID, userID) {
+		return false, false
+	}
+	if s.m.cfg.AccountPolicy.RequireAdminMFA && userHasAdminRole(roles) {
+		return true, true
+	}
+	if s.m.tenantSvc.requireMFA(ctx, tenantID) {
+		// 租户级要求不阻止登录（否则新用户无从绑定），登录结果带提示，敏感操作在绑定前一律拒绝
+		return true, false
+	}
+	policy, err := s.GetPolicy(ctx, tenantID)
+	if err != nil || policy == nil {
+		return false, false
+	}
+	for _, code := range policy.Roles() {
+		if contains(roles, code) {
+			return true, policy.EnforceAfter == nil || !time.Now().Before(*policy.EnforceAfter)
+		}
+	}
+	return false, false
+}
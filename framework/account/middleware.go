@@ -3,6 +3,7 @@ package account
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -13,7 +14,7 @@ import (
 	"go.opentelemetry.io/otel/metric"
 )
 
-const principalContextKey = "account_principal"
+const principalContextKey = server.PrincipalKey
 
 // Middleware 提供与 Hertz HTTP 框架集成的认证和授权中间件。
 type Middleware struct {
@@ -42,7 +43,7 @@ func (m *Middleware) Authenticate() app.HandlerFunc {
 			}
 			return err
 		}
-		arg.C().Set(principalContextKey, principal)
+		m.setPrincipal(arg, principal)
 		if mm != nil {
 			mm.AuthMiddlewareDuration.Record(arg.TraceContext, float64(time.Since(start).Microseconds())/1000.0,
 				metric.WithAttributes(attribute.String("status", "success")))
@@ -61,6 +62,7 @@ func (m *Middleware) RequirePermission(permissionCode string) app.HandlerFunc {
 		decision, err := m.m.Authorizer().Check(arg.TraceContext, AuthzRequest{
 			Subject:    principal,
 			Permission: permissionCode,
+			Context:    requestAuthzContext(arg),
 		})
 		if err != nil {
 			return err
@@ -77,6 +79,42 @@ func (m *Middleware) RequirePermission(permissionCode string) app.HandlerFunc {
 	})
 }
 
+// RequireStepUp 返回中间件处理器，要求当前会话在 maxAge 内完成过第二因素验证（0 使用 Config.StepUpWindow），
+// methods 限定可接受的验证方式（amr 值：otp、sms、email、hwk），为空表示任意；
+// 传入无法完成的方式属于配置错误，请求时返回 500 而不是放行。
+// 不满足时返回 403，并通过 WWW-Authenticate 头（RFC 9470）告知客户端所需的 acr / max_age，
+// 客户端完成 /mfa/step-up 后重试即可。须放在 Authenticate 之后。
+func (m *Middleware) RequireStepUp(maxAge time.Duration, methods ...string) app.HandlerFunc {
+	configErr := validateStepUpMethods(methods)
+	return server.MakePlugin(func(arg server.Request) error {
+		if configErr != nil {
+			return errwrap.Error(ErrInternal, fmt.Errorf("account.RequireStepUp: %w", configErr))
+		}
+		principal, ok := GetPrincipal(arg)
+		if !ok {
+			return errwrap.Error(ErrInvalidToken, errors.New("missing principal"))
+		}
+		challenge, err := m.m.Auth().EvaluateStepUp(arg.TraceContext, principal, StepUpRequirement{MaxAge: maxAge, Methods: methods})
+		if err != nil {
+			return err
+		}
+		if challenge != nil {
+			arg.C().Header("WWW-Authenticate", challenge.WWWAuthenticate())
+			return errwrap.Error(ErrPermissionDenied, errors.New("step-up authentication required"))
+		}
+		return nil
+	})
+}
+
+// requestAuthzContext 从 HTTP 请求提取策略可用的 request.* 属性。
+func requestAuthzContext(arg server.Request) *AuthzContext {
+	return &AuthzContext{
+		ClientIP:  arg.C().ClientIP(),
+		UserAgent: string(arg.C().UserAgent()),
+		Time:      time.Now(),
+	}
+}
+
 // RequireAnyPermission 返回中间件处理器，检查主体是否至少拥有指定权限之一。
 // 系统角色（platform_admin、tenant_owner）绕过检查。
 func (m *Middleware) RequireAnyPermission(permissionCodes ...string) app.HandlerFunc {
@@ -138,7 +176,7 @@ func (m *Middleware) OptionalAuthenticate() app.HandlerFunc {
 		if err != nil {
 			return nil // ignore invalid token, continue without principal
 		}
-		arg.C().Set(principalContextKey, principal)
+		m.setPrincipal(arg, principal)
 		return nil
 	})
 }
@@ -209,7 +247,7 @@ func (m *Middleware) AuthenticateAPIToken() app.HandlerFunc {
 		if err != nil {
 			return err
 		}
-		arg.C().Set(principalContextKey, principal)
+		m.setPrincipal(arg, principal)
 		return nil
 	})
 }
@@ -226,11 +264,25 @@ func (m *Middleware) AuthenticateAnyBearer() app.HandlerFunc {
 		if err != nil {
 			return err
 		}
-		arg.C().Set(principalContextKey, principal)
+		m.setPrincipal(arg, principal)
 		return nil
 	})
 }
 
+// setPrincipal 把 Principal 写入请求上下文。代操作会话额外把操作者写入 TraceContext，
+// 使后续审计日志带上 ActorID，并为每个请求记录一条 impersonation_request 审计。
+func (m *Middleware) setPrincipal(arg server.Request, principal *Principal) {
+	arg.C().Set(principalContextKey, principal)
+	if !principal.Impersonated {
+		return
+	}
+	ctx := withActor(arg.TraceContext, principal.ActorID)
+	arg.C().Set("ParentContext", ctx)
+	m.m.audit(ctx, principal.TenantID, principal.UserID, "impersonation_request", "success",
+		truncateString(string(arg.C().Method())+" "+string(arg.C().Path()), 255),
+		arg.C().ClientIP(), string(arg.C().UserAgent()))
+}
+
 func (m *Middleware) authenticateBearer(ctx context.Context, token string) (*Principal, error) {
 	principal, err := m.m.Auth().Validate(ctx, token)
 	if err == nil {
@@ -242,6 +294,41 @@ func (m *Middleware) authenticateBearer(ctx context.Context, token string) (*Pri
 	return nil, err
 }
 
+// WSAuthenticator 返回 server.WSHubConfig.Authenticate 可直接使用的握手鉴权函数。
+// 浏览器 WebSocket API 无法自定义请求头，因此除 Authorization 外也接受
+// access_token 查询参数；令牌可以是登录 JWT 或个人访问令牌。
+// 鉴权通过后 Principal 绑定到 WSIdentity，业务通过 WSPrincipal 取回。
+func (m *Middleware) WSAuthenticator() func(ctx context.Context, c *app.RequestContext) (*server.WSIdentity, error) {
+	return func(ctx context.Context, c *app.RequestContext) (*server.WSIdentity, error) {
+		token := bearerToken(string(c.GetHeader("Authorization")))
+		if token == "" {
+			token = c.Query("access_token")
+		}
+		if token == "" {
+			return nil, errwrap.Error(ErrInvalidToken, errors.New("missing bearer token"))
+		}
+		principal, err := m.authenticateBearer(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(principalContextKey, principal)
+		return &server.WSIdentity{
+			UserID:    principal.UserID,
+			Principal: principal,
+			Meta:      map[string]string{"tenant_id": principal.TenantID},
+		}, nil
+	}
+}
+
+// WSPrincipal 从 WSHub 连接中取回握手时绑定的 Principal。
+func WSPrincipal(client *server.WSClient) (*Principal, bool) {
+	if client == nil {
+		return nil, false
+	}
+	principal, ok := client.Identity().Principal.(*Principal)
+	return principal, ok
+}
+
 // GetPrincipal 从请求上下文中提取 Principal。
 func GetPrincipal(req server.Request) (*Principal, bool) {
 	value, ok := req.C().Get(principalContextKey)
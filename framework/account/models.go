@@ -100,8 +100,16 @@ type Session struct {
 	// 该标记**严格按 sid 维度**：用户在 Safari 上 step-up 不会让 Chrome 上的会话获得敏感操作权限。
 	// 当 session 被吊销 / 改密 / 风控时整条记录失效，标记自然作废。
 	MFASatisfiedAt *time.Time `json:"mfa_satisfied_at"`
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
+	// MFAMethod 最近一次满足 MFA 时使用的方式（amr 值，如 otp / sms / hwk），供 RequireStepUp 按方式校验。
+	MFAMethod string `json:"mfa_method,omitempty" gorm:"size:16"`
+	// ACR / AMR 会话的认证保证级别与已使用的认证方式（逗号分隔），登录时写入、step-up 后升级，
+	// 刷新令牌时带入新的 access token。
+	ACR string `json:"acr,omitempty" gorm:"size:16"`
+	AMR string `json:"amr,omitempty" gorm:"size:128"`
+	// ActorID 非空表示这是管理员代操作（impersonation）会话，值为发起代操作的管理员用户 ID。
+	ActorID   string    `json:"actor_id,omitempty" gorm:"size:36;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func (Session) TableName() string { return "acct_sessions" }
@@ -182,6 +190,7 @@ type AuditLog struct {
 	ID        string    `json:"id" gorm:"size:36;primaryKey"`
 	TenantID  string    `json:"tenant_id" gorm:"size:64;not null;index:idx_acct_audit_tenant_created,priority:1"`
 	UserID    string    `json:"user_id" gorm:"size:36;index"`
+	ActorID   string    `json:"actor_id,omitempty" gorm:"size:36;index"` // 代操作期间实际执行操作的管理员，普通操作为空
 	Event     string    `json:"event" gorm:"size:80;not null;index"`
 	IP        string    `json:"ip" gorm:"size:64"`
 	UserAgent string    `json:"user_agent" gorm:"size:512"`
@@ -197,6 +206,7 @@ type AuditLogArchive struct {
 	ID        string    `json:"id" gorm:"size:36;primaryKey"`
 	TenantID  string    `json:"tenant_id" gorm:"size:64;not null;index:idx_acct_audit_archive_tenant_created,priority:1"`
 	UserID    string    `json:"user_id" gorm:"size:36;index"`
+	ActorID   string    `json:"actor_id,omitempty" gorm:"size:36;index"`
 	Event     string    `json:"event" gorm:"size:80;not null;index"`
 	IP        string    `json:"ip" gorm:"size:64"`
 	UserAgent string    `json:"user_agent" gorm:"size:512"`
@@ -0,0 +1,342 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+)
+
+// VerificationSender 验证码发送方。内置 SMTP 邮件、通用 HTTP 短信网关与阿里云 / 腾讯云短信，
+// 也可以自行实现；按 VerificationConfig.Providers 中的顺序依次尝试，前一个失败时回退到下一个。
+type VerificationSender interface {
+	// Name 提供商名称，写入投递记录，并用于选择模板中的平台模板 ID。
+	Name() string
+	// Send 发送一条消息，成功时返回提供商的消息 ID（可为空），用于回执更新投递状态。
+	Send(ctx context.Context, msg *VerificationMessage) (messageID string, err error)
+}
+
+// VerificationMessage 按模板渲染后的验证码消息。
+type VerificationMessage struct {
+	ChallengeID string
+	TenantID    string
+	Channel     string
+	Purpose     string
+	Locale      string
+	Target      string
+	Code        string
+	TTL         time.Duration
+	// Subject 邮件主题；Body 邮件或短信正文，HTML 表示邮件正文为 HTML。
+	Subject string
+	Body    string
+	HTML    bool
+	// TemplateCodes 短信平台模板 ID，键为提供商名称；TemplateParams 为平台模板变量。
+	TemplateCodes  map[string]string
+	TemplateParams map[string]string
+}
+
+// VerificationTemplate 验证码消息模板。Subject / Body 使用 text/template，
+// 可用变量：.Code .Purpose .TTLMinutes .AppName。
+type VerificationTemplate struct {
+	// Channel email / sms。
+	Channel string
+	// Purpose 为空时匹配所有用途。
+	Purpose string
+	// Locale 如 zh-CN / en；为空时作为该渠道的兜底模板。
+	Locale  string
+	Subject string
+	Body    string
+	HTML    bool
+	// TemplateCodes 阿里云、腾讯云等只能发送已审核模板的平台使用的模板 ID，键为提供商名称。
+	TemplateCodes map[string]string
+}
+
+// notifyProviderSender 把旧的 NotifyProvider 适配为 VerificationSender。
+type notifyProviderSender struct {
+	p NotifyProvider
+}
+
+func (s notifyProviderSender) Name() string { return "notify_provider" }
+
+func (s notifyProviderSender) Send(ctx context.Context, msg *VerificationMessage) (string, error) {
+	return "", s.p.Send(ctx, msg.Channel, msg.Target, msg.Code)
+}
+
+// defaultVerificationTemplates 未配置模板时使用的内置模板。
+var defaultVerificationTemplates = []VerificationTemplate{
+	{
+		Channel: VerificationChannelEmail, Locale: "zh-CN",
+		Subject: "{{.AppName}} 验证码",
+		Body:    "您的验证码是 {{.Code}}，{{.TTLMinutes}} 分钟内有效。如非本人操作，请忽略本邮件。",
+	},
+	{
+		Channel: VerificationChannelEmail, Locale: "en",
+		Subject: "{{.AppName}} verification code",
+		Body:    "Your verification code is {{.Code}}. It expires in {{.TTLMinutes}} minutes. If you did not request it, please ignore this email.",
+	},
+	{
+		Channel: VerificationChannelEmail, Purpose: NotificationPurposeNewDevice, Locale: "zh-CN",
+		Subject: "{{.AppName}} 新设备登录提醒",
+		Body:    "{{.Username}}，您的账号于 {{.Time}} 在新设备上登录。\n设备：{{.Device}}\nIP：{{.IP}}（{{.Location}}）\n如非本人操作，请立即修改密码并退出其他设备。",
+	},
+	{
+		Channel: VerificationChannelEmail, Purpose: NotificationPurposeNewDevice, Locale: "en",
+		Subject: "{{.AppName}} new device sign-in",
+		Body:    "Hi {{.Username}}, your account was signed in from a new device at {{.Time}}.\nDevice: {{.Device}}\nIP: {{.IP}} ({{.Location}})\nIf this wasn't you, change your password and sign out other devices now.",
+	},
+	{
+		Channel: VerificationChannelSMS, Locale: "zh-CN",
+		Body: "验证码 {{.Code}}，{{.TTLMinutes}} 分钟内有效，请勿泄露。",
+	},
+	{
+		Channel: VerificationChannelSMS, Locale: "en",
+		Body: "Your verification code is {{.Code}}, valid for {{.TTLMinutes}} minutes.",
+	},
+}
+
+// verificationSenders 返回渠道的发送方，旧的 NotifyProvider 作为最后的回退。
+func (s *VerificationService) verificationSenders(channel string) []VerificationSender {
+	senders := append([]VerificationSender{}, s.m.cfg.Verification.Providers[channel]...)
+	if s.m.cfg.NotifyProvider != nil {
+		senders = append(senders, notifyProviderSender{p: s.m.cfg.NotifyProvider})
+	}
+	return senders
+}
+
+// deliver 按顺序尝试发送方，每次尝试写入投递记录；全部失败时返回错误。
+func (s *VerificationService) deliver(ctx context.Context, challenge *VerificationChallenge, senders []VerificationSender, target, code string) error {
+	msg, err := s.renderMessage(challenge, target, code)
+	if err != nil {
+		gaia.ErrorF("[account] render verification message failed: challenge=%s err=%v", challenge.ID, err)
+		return accountError(ErrInternal, "验证码发送失败")
+	}
+	db := s.m.db.WithContext(ctx)
+	for _, sender := range senders {
+		messageID, sendErr := sender.Send(ctx, msg)
+		delivery := &VerificationDelivery{
+			ID:                newID(),
+			TenantID:          challenge.TenantID,
+			ChallengeID:       challenge.ID,
+			Channel:           challenge.Channel,
+			Provider:          sender.Name(),
+			ProviderMessageID: messageID,
+			Status:            DeliveryStatusSent,
+		}
+		if sendErr != nil {
+			delivery.Status = DeliveryStatusFailed
+			delivery.Error = truncateString(sendErr.Error(), 512)
+			gaia.WarnF("[account] verification sender %s failed: channel=%s challenge=%s err=%v",
+				sender.Name(), challenge.Channel, challenge.ID, sendErr)
+		}
+		if err := db.Create(delivery).Error; err != nil {
+			recordDBError(ctx)
+			gaia.WarnF("[account] record verification delivery failed: challenge=%s err=%v", challenge.ID, err)
+		}
+		_ = db.Model(challenge).Updates(map[string]any{"delivery_status": delivery.Status, "provider": delivery.Provider}).Error
+		if sendErr == nil {
+			return nil
+		}
+	}
+	return accountError(ErrInternal, "验证码发送失败")
+}
+
+// ReportDelivery 处理提供商的送达回执，按提供商名称与消息 ID 更新投递记录和挑战的投递状态。
+// status 只能是 delivered / undelivered，detail 为提供商返回的原因。
+func (s *VerificationService) ReportDelivery(ctx context.Context, provider, messageID, status, detail string) error {
+	if status != DeliveryStatusDelivered && status != DeliveryStatusUndelivered {
+		return accountError(ErrInvalidArgument, "不支持的投递状态")
+	}
+	if provider == "" || messageID == "" {
+		return accountError(ErrInvalidArgument, "提供商与消息 ID 不能为空")
+	}
+	db := s.m.db.WithContext(ctx)
+	var delivery VerificationDelivery
+	if err := db.Where("provider = ? AND provider_message_id = ?", provider, messageID).First(&delivery).Error; err != nil {
+		return accountError(ErrInvalidArgument, "投递记录不存在")
+	}
+	if err := db.Model(&delivery).Updates(map[string]any{"status": status, "error": truncateString(detail, 512)}).Error; err != nil {
+		recordDBError(ctx)
+		return err
+	}
+	return db.Model(&VerificationChallenge{}).Where("id = ? AND provider = ?", delivery.ChallengeID, provider).
+		Update("delivery_status", status).Error
+}
+
+// VerificationDeliveryStatus 验证码挑战的投递情况。
+type VerificationDeliveryStatus struct {
+	ChallengeID string
+	Status      string
+	Provider    string
+	Attempts    []VerificationDelivery
+}
+
+// DeliveryStatus 查询挑战的投递状态与各次尝试，供客服排查"收不到验证码"。
+func (s *VerificationService) DeliveryStatus(ctx context.Context, tenantID, challengeID string) (*VerificationDeliveryStatus, error) {
+	tenantID = s.m.tenantID(tenantID)
+	var challenge VerificationChallenge
+	if err := s.m.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", challengeID, tenantID).First(&challenge).Error; err != nil {
+		return nil, accountError(ErrInvalidArgument, "验证码挑战不存在")
+	}
+	var attempts []VerificationDelivery
+	if err := s.m.db.WithContext(ctx).Where("challenge_id = ?", challenge.ID).Order("created_at").Find(&attempts).Error; err != nil {
+		recordDBError(ctx)
+		return nil, err
+	}
+	return &VerificationDeliveryStatus{
+		ChallengeID: challenge.ID,
+		Status:      challenge.DeliveryStatus,
+		Provider:    challenge.Provider,
+		Attempts:    attempts,
+	}, nil
+}
+
+// resolveTemplate 按 用途+语言 → 用途+语种 → 通用+语言 → 通用+语种 的顺序选择模板，
+// 找不到时依次使用默认语言与内置模板。
+func (s *VerificationService) resolveTemplate(channel, purpose, locale string) VerificationTemplate {
+	if t, ok := s.findTemplate(channel, []string{purpose, ""}, locale); ok {
+		return t
+	}
+	return VerificationTemplate{Channel: channel, Body: "{{.Code}}"}
+}
+
+func (s *VerificationService) findTemplate(channel string, purposes []string, locale string) (VerificationTemplate, bool) {
+	locales := candidateLocales(locale)
+	locales = append(locales, candidateLocales(s.m.cfg.Verification.DefaultLocale)...)
+	locales = append(locales, "")
+	for _, templates := range [][]VerificationTemplate{s.m.cfg.Verification.Templates, defaultVerificationTemplates} {
+		for _, loc := range locales {
+			for _, p := range purposes {
+				for _, t := range templates {
+					if t.Channel == channel && t.Purpose == p && strings.EqualFold(t.Locale, loc) {
+						return t, true
+					}
+				}
+			}
+		}
+	}
+	return VerificationTemplate{}, false
+}
+
+// sendNotice 发送不含验证码的通知（如新设备登录提醒）。模板必须与 purpose 完全匹配，
+// 可用变量为 .AppName 加上 data；只使用 VerificationConfig.Providers，不回退到 NotifyProvider。
+func (s *VerificationService) sendNotice(ctx context.Context, tenantID, channel, target, purpose, locale string, data map[string]any) error {
+	tpl, ok := s.findTemplate(channel, []string{purpose}, locale)
+	if !ok {
+		return fmt.Errorf("no %s template for %s", channel, purpose)
+	}
+	senders := s.m.cfg.Verification.Providers[channel]
+	if len(senders) == 0 {
+		return nil
+	}
+	vars := map[string]any{"AppName": s.m.cfg.AppID}
+	for k, v := range data {
+		vars[k] = v
+	}
+	subject, err := renderTemplate(tpl.Subject, vars)
+	if err != nil {
+		return err
+	}
+	body, err := renderTemplate(tpl.Body, vars)
+	if err != nil {
+		return err
+	}
+	msg := &VerificationMessage{
+		TenantID:      tenantID,
+		Channel:       channel,
+		Purpose:       purpose,
+		Locale:        tpl.Locale,
+		Target:        target,
+		Subject:       subject,
+		Body:          body,
+		HTML:          tpl.HTML,
+		TemplateCodes: tpl.TemplateCodes,
+	}
+	var lastErr error
+	for _, sender := range senders {
+		if _, lastErr = sender.Send(ctx, msg); lastErr == nil {
+			return nil
+		}
+		gaia.WarnF("[account] notice sender %s failed: purpose=%s err=%v", sender.Name(), purpose, lastErr)
+	}
+	return lastErr
+}
+
+// renderMessage 用模板渲染验证码消息。
+func (s *VerificationService) renderMessage(challenge *VerificationChallenge, target, code string) (*VerificationMessage, error) {
+	tpl := s.resolveTemplate(challenge.Channel, challenge.Purpose, challenge.Locale)
+	ttl := s.m.cfg.Verification.CodeTTL
+	data := map[string]any{
+		"Code":       code,
+		"Purpose":    challenge.Purpose,
+		"TTLMinutes": int(ttl.Round(time.Minute) / time.Minute),
+		"AppName":    s.m.cfg.AppID,
+	}
+	subject, err := renderTemplate(tpl.Subject, data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := renderTemplate(tpl.Body, data)
+	if err != nil {
+		return nil, err
+	}
+	return &VerificationMessage{
+		ChallengeID:   challenge.ID,
+		TenantID:      challenge.TenantID,
+		Channel:       challenge.Channel,
+		Purpose:       challenge.Purpose,
+		Locale:        challenge.Locale,
+		Target:        target,
+		Code:          code,
+		TTL:           ttl,
+		Subject:       subject,
+		Body:          body,
+		HTML:          tpl.HTML,
+		TemplateCodes: tpl.TemplateCodes,
+		TemplateParams: map[string]string{
+			"code": code,
+			"ttl":  fmt.Sprint(data["TTLMinutes"]),
+		},
+	}, nil
+}
+
+func renderTemplate(text string, data map[string]any) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	t, err := template.New("verification").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse verification template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render verification template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// candidateLocales 返回 locale 及其语种，如 zh-CN → [zh-CN zh]；zh_CN 视同 zh-CN。
+func candidateLocales(locale string) []string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	if locale == "" {
+		return nil
+	}
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		return []string{locale, locale[:i]}
+	}
+	return []string{locale}
+}
+
+// parseAcceptLanguage 取 Accept-Language 中的第一个语言标签。
+func parseAcceptLanguage(header string) string {
+	first, _, _ := strings.Cut(header, ",")
+	tag, _, _ := strings.Cut(first, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return ""
+	}
+	return tag
+}
@@ -0,0 +1,432 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// smsMaxResponseSize 短信网关响应体读取上限。
+const smsMaxResponseSize = 64 << 10
+
+// HTTPSMSConfig 通用 HTTP 短信网关参数。
+type HTTPSMSConfig struct {
+	// Name 提供商名称，默认 "http_sms"；同时接入多个网关时用于区分投递记录。
+	Name string
+	URL  string
+	// Method 请求方法，默认 POST。
+	Method string
+	// Headers 附加请求头，通常放鉴权信息。
+	Headers map[string]string
+	// BodyTemplate 请求体模板（text/template），可用变量 .Target .Body .Code .Purpose .Locale .ChallengeID，
+	// 以及 json 函数做 JSON 转义；为空时发送 {"to","content","code","purpose","locale"}。
+	BodyTemplate string
+	// ContentType 请求体类型，默认 application/json。
+	ContentType string
+	// MessageIDField 响应 JSON 中消息 ID 的路径，"." 表示嵌套。
+	MessageIDField string
+	// Timeout 请求超时，默认 10 秒；HTTPClient 非空时忽略。
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+// HTTPSMSSender 把验证码短信提交给自建或第三方的 HTTP 网关，2xx 视为成功。
+type HTTPSMSSender struct {
+	cfg  HTTPSMSConfig
+	body *template.Template
+}
+
+// NewHTTPSMSSender 创建通用 HTTP 短信发送方，BodyTemplate 无法解析时返回错误。
+func NewHTTPSMSSender(cfg HTTPSMSConfig) (*HTTPSMSSender, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("http sms: url is required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = "http_sms"
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodPost
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = "application/json"
+	}
+	cfg.HTTPClient = smsHTTPClient(cfg.HTTPClient, cfg.Timeout)
+	s := &HTTPSMSSender{cfg: cfg}
+	if cfg.BodyTemplate != "" {
+		t, err := template.New("http_sms").Funcs(template.FuncMap{"json": jsonEscape}).Parse(cfg.BodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("http sms: parse body template: %w", err)
+		}
+		s.body = t
+	}
+	return s, nil
+}
+
+func (s *HTTPSMSSender) Name() string { return s.cfg.Name }
+
+func (s *HTTPSMSSender) Send(ctx context.Context, msg *VerificationMessage) (string, error) {
+	var body []byte
+	if s.body != nil {
+		var buf bytes.Buffer
+		if err := s.body.Execute(&buf, msg); err != nil {
+			return "", fmt.Errorf("http sms: render body: %w", err)
+		}
+		body = buf.Bytes()
+	} else {
+		body, _ = json.Marshal(map[string]string{
+			"to":      msg.Target,
+			"content": msg.Body,
+			"code":    msg.Code,
+			"purpose": msg.Purpose,
+			"locale":  msg.Locale,
+		})
+	}
+	req, err := http.NewRequestWithContext(ctx, s.cfg.Method, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", s.cfg.ContentType)
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	respBody, err := doSMSRequest(s.cfg.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+	if s.cfg.MessageIDField == "" {
+		return "", nil
+	}
+	var out map[string]any
+	dec := json.NewDecoder(bytes.NewReader(respBody))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return "", nil
+	}
+	return lookupClaim(out, s.cfg.MessageIDField), nil
+}
+
+// AliyunSMSConfig 阿里云短信（dysmsapi SendSms）参数。
+type AliyunSMSConfig struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SignName        string
+	// TemplateCode 默认模板 ID，验证码模板的 TemplateCodes["aliyun"] 优先。
+	TemplateCode string
+	// ParamNames 模板变量名，取值自 code / ttl，默认 ["code"]。
+	ParamNames []string
+	// Endpoint 默认 https://dysmsapi.aliyuncs.com/。
+	Endpoint   string
+	RegionID   string
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+// AliyunSMSSender 使用阿里云 RPC 签名（HMAC-SHA1，SignatureVersion 1.0）调用 SendSms。
+type AliyunSMSSender struct {
+	cfg AliyunSMSConfig
+	now func() time.Time
+}
+
+// NewAliyunSMSSender 创建阿里云短信发送方。
+func NewAliyunSMSSender(cfg AliyunSMSConfig) *AliyunSMSSender {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://dysmsapi.aliyuncs.com/"
+	}
+	if cfg.RegionID == "" {
+		cfg.RegionID = "cn-hangzhou"
+	}
+	if len(cfg.ParamNames) == 0 {
+		cfg.ParamNames = []string{"code"}
+	}
+	cfg.HTTPClient = smsHTTPClient(cfg.HTTPClient, cfg.Timeout)
+	return &AliyunSMSSender{cfg: cfg, now: time.Now}
+}
+
+func (s *AliyunSMSSender) Name() string { return "aliyun" }
+
+func (s *AliyunSMSSender) Send(ctx context.Context, msg *VerificationMessage) (string, error) {
+	templateCode := templateCodeFor(msg, s.Name(), s.cfg.TemplateCode)
+	if templateCode == "" {
+		return "", fmt.Errorf("aliyun sms: template code is required")
+	}
+	templateParam := map[string]string{}
+	for _, name := range s.cfg.ParamNames {
+		templateParam[name] = templateParamValue(msg, name)
+	}
+	paramJSON, _ := json.Marshal(templateParam)
+	params := url.Values{
+		"AccessKeyId":      {s.cfg.AccessKeyID},
+		"Action":           {"SendSms"},
+		"Format":           {"JSON"},
+		"RegionId":         {s.cfg.RegionID},
+		"SignatureMethod":  {"HMAC-SHA1"},
+		"SignatureNonce":   {newID()},
+		"SignatureVersion": {"1.0"},
+		"Timestamp":        {s.now().UTC().Format("2006-01-02T15:04:05Z")},
+		"Version":          {"2017-05-25"},
+		"PhoneNumbers":     {strings.TrimPrefix(msg.Target, "+")},
+		"SignName":         {s.cfg.SignName},
+		"TemplateCode":     {templateCode},
+		"TemplateParam":    {string(paramJSON)},
+		"OutId":            {msg.ChallengeID},
+	}
+	query := aliyunCanonicalQuery(params)
+	signature := aliyunSignature(http.MethodPost, query, s.cfg.AccessKeySecret)
+	body := "Signature=" + aliyunPercentEncode(signature) + "&" + query
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	respBody, err := doSMSRequest(s.cfg.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Code    string
+		Message string
+		BizID   string `json:"BizId"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return "", fmt.Errorf("aliyun sms: decode response: %w", err)
+	}
+	if out.Code != "OK" {
+		return "", fmt.Errorf("aliyun sms: %s %s", out.Code, out.Message)
+	}
+	return out.BizID, nil
+}
+
+// aliyunCanonicalQuery 按参数名排序并使用阿里云的百分号编码拼接。
+func aliyunCanonicalQuery(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, aliyunPercentEncode(k)+"="+aliyunPercentEncode(params.Get(k)))
+	}
+	return strings.Join(parts, "&")
+}
+
+// aliyunSignature 计算 RPC 风格签名：HMAC-SHA1(secret&, METHOD&%2F&encode(query))。
+func aliyunSignature(method, canonicalQuery, secret string) string {
+	stringToSign := method + "&" + aliyunPercentEncode("/") + "&" + aliyunPercentEncode(canonicalQuery)
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func aliyunPercentEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
+
+// TencentSMSConfig 腾讯云短信（SendSms 2021-01-11）参数。
+type TencentSMSConfig struct {
+	SecretID  string
+	SecretKey string
+	SdkAppID  string
+	SignName  string
+	// TemplateID 默认模板 ID，验证码模板的 TemplateCodes["tencent"] 优先。
+	TemplateID string
+	// ParamNames 模板参数顺序，取值自 code / ttl，默认 ["code"]。
+	ParamNames []string
+	// Region 默认 ap-guangzhou；Endpoint 默认 https://sms.tencentcloudapi.com。
+	Region   string
+	Endpoint string
+	// DefaultCountryCode 号码不带 + 前缀时补上的国家码，默认 +86。
+	DefaultCountryCode string
+	Timeout            time.Duration
+	HTTPClient         *http.Client
+}
+
+// TencentSMSSender 使用 TC3-HMAC-SHA256 签名调用腾讯云 SendSms。
+type TencentSMSSender struct {
+	cfg TencentSMSConfig
+	now func() time.Time
+}
+
+// NewTencentSMSSender 创建腾讯云短信发送方。
+func NewTencentSMSSender(cfg TencentSMSConfig) *TencentSMSSender {
+	if cfg.Region == "" {
+		cfg.Region = "ap-guangzhou"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://sms.tencentcloudapi.com"
+	}
+	if cfg.DefaultCountryCode == "" {
+		cfg.DefaultCountryCode = "+86"
+	}
+	if len(cfg.ParamNames) == 0 {
+		cfg.ParamNames = []string{"code"}
+	}
+	cfg.HTTPClient = smsHTTPClient(cfg.HTTPClient, cfg.Timeout)
+	return &TencentSMSSender{cfg: cfg, now: time.Now}
+}
+
+func (s *TencentSMSSender) Name() string { return "tencent" }
+
+func (s *TencentSMSSender) Send(ctx context.Context, msg *VerificationMessage) (string, error) {
+	templateID := templateCodeFor(msg, s.Name(), s.cfg.TemplateID)
+	if templateID == "" {
+		return "", fmt.Errorf("tencent sms: template id is required")
+	}
+	phone := msg.Target
+	if !strings.HasPrefix(phone, "+") {
+		phone = s.cfg.DefaultCountryCode + phone
+	}
+	params := make([]string, 0, len(s.cfg.ParamNames))
+	for _, name := range s.cfg.ParamNames {
+		params = append(params, templateParamValue(msg, name))
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"PhoneNumberSet":   []string{phone},
+		"SmsSdkAppId":      s.cfg.SdkAppID,
+		"SignName":         s.cfg.SignName,
+		"TemplateId":       templateID,
+		"TemplateParamSet": params,
+		"SessionContext":   msg.ChallengeID,
+	})
+	endpoint, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("tencent sms: invalid endpoint: %w", err)
+	}
+	const action, version = "SendSms", "2021-01-11"
+	now := s.now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Host", endpoint.Host)
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Version", version)
+	req.Header.Set("X-TC-Region", s.cfg.Region)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("Authorization", tencentAuthorization(s.cfg.SecretID, s.cfg.SecretKey, endpoint.Host, "sms", action, payload, now))
+	respBody, err := doSMSRequest(s.cfg.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Response struct {
+			Error *struct {
+				Code    string
+				Message string
+			}
+			SendStatusSet []struct {
+				SerialNo string
+				Code     string
+				Message  string
+			}
+		}
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return "", fmt.Errorf("tencent sms: decode response: %w", err)
+	}
+	if e := out.Response.Error; e != nil {
+		return "", fmt.Errorf("tencent sms: %s %s", e.Code, e.Message)
+	}
+	if len(out.Response.SendStatusSet) == 0 {
+		return "", fmt.Errorf("tencent sms: empty send status")
+	}
+	status := out.Response.SendStatusSet[0]
+	if !strings.EqualFold(status.Code, "Ok") {
+		return "", fmt.Errorf("tencent sms: %s %s", status.Code, status.Message)
+	}
+	return status.SerialNo, nil
+}
+
+// tencentAuthorization 计算 TC3-HMAC-SHA256 签名的 Authorization 头。
+func tencentAuthorization(secretID, secretKey, host, service, action string, payload []byte, now time.Time) string {
+	const signedHeaders = "content-type;host;x-tc-action"
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		"content-type:application/json; charset=utf-8\nhost:" + host + "\nx-tc-action:" + strings.ToLower(action) + "\n",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	date := now.UTC().Format("2006-01-02")
+	scope := date + "/" + service + "/tc3_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "TC3-HMAC-SHA256\n" + strconv.FormatInt(now.Unix(), 10) + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	secretDate := hmacSHA256([]byte("TC3"+secretKey), date)
+	secretService := hmacSHA256(secretDate, service)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+	return "TC3-HMAC-SHA256 Credential=" + secretID + "/" + scope + ", SignedHeaders=" + signedHeaders + ", Signature=" + signature
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// templateCodeFor 优先取验证码模板中为该提供商配置的模板 ID。
+func templateCodeFor(msg *VerificationMessage, provider, fallback string) string {
+	if code := msg.TemplateCodes[provider]; code != "" {
+		return code
+	}
+	return fallback
+}
+
+func templateParamValue(msg *VerificationMessage, name string) string {
+	if v, ok := msg.TemplateParams[name]; ok {
+		return v
+	}
+	return msg.Code
+}
+
+func smsHTTPClient(client *http.Client, timeout time.Duration) *http.Client {
+	if client != nil {
+		return client
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// doSMSRequest 执行请求，非 2xx 响应作为错误返回。
+func doSMSRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, smsMaxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: %s", resp.Status, truncateString(strings.TrimSpace(string(body)), 200))
+	}
+	return body, nil
+}
+
+func jsonEscape(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
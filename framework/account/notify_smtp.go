@@ -0,0 +1,150 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig SMTP 邮件发送参数。
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// From 发件地址，FromName 为显示名称。
+	From     string
+	FromName string
+	// Security 连接方式：starttls（默认，587）/ tls（隐式 TLS，465）/ none（仅用于本地测试）。
+	Security string
+	// Timeout 连接与发送超时，默认 10 秒。
+	Timeout time.Duration
+}
+
+// SMTPSender 通过 SMTP 发送验证码邮件。
+type SMTPSender struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPSender 创建 SMTP 邮件发送方。
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.Security == "tls" {
+			cfg.Port = 465
+		}
+	}
+	if cfg.Security == "" {
+		cfg.Security = "starttls"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &SMTPSender{cfg: cfg}
+}
+
+func (s *SMTPSender) Name() string { return "smtp" }
+
+func (s *SMTPSender) Send(ctx context.Context, msg *VerificationMessage) (string, error) {
+	if msg.Channel != VerificationChannelEmail {
+		return "", fmt.Errorf("smtp sender does not support channel %s", msg.Channel)
+	}
+	messageID := fmt.Sprintf("<%s@%s>", newID(), s.cfg.Host)
+	data, err := s.buildMessage(msg, messageID)
+	if err != nil {
+		return "", err
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < s.cfg.Timeout {
+		return messageID, s.deliver(ctx, msg.Target, data, time.Until(deadline))
+	}
+	return messageID, s.deliver(ctx, msg.Target, data, s.cfg.Timeout)
+}
+
+func (s *SMTPSender) deliver(ctx context.Context, to string, data []byte, timeout time.Duration) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if s.cfg.Security == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.cfg.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+	if s.cfg.Security == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := client.Mail(s.cfg.From); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage 组装 RFC 5322 邮件，正文使用 base64 编码避免中文被中继改写。
+func (s *SMTPSender) buildMessage(msg *VerificationMessage, messageID string) ([]byte, error) {
+	if _, err := mail.ParseAddress(msg.Target); err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	from := (&mail.Address{Name: s.cfg.FromName, Address: s.cfg.From}).String()
+	contentType := "text/plain; charset=UTF-8"
+	if msg.HTML {
+		contentType = "text/html; charset=UTF-8"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.Target)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: %s\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,270 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type fakeSender struct {
+	name string
+	err  error
+	sent []*VerificationMessage
+}
+
+func (f *fakeSender) Name() string { return f.name }
+
+func (f *fakeSender) Send(_ context.Context, msg *VerificationMessage) (string, error) {
+	f.sent = append(f.sent, msg)
+	if f.err != nil {
+		return "", f.err
+	}
+	return f.name + "-" + msg.ChallengeID, nil
+}
+
+func newNotifyTestManager(t *testing.T, providers map[string][]VerificationSender) (*Manager, *rebacTestCache) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "notify.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &rebacTestCache{data: map[string]string{}}
+	cfg := testAuthConfig()
+	cfg.DB = db
+	cfg.Cache = cache
+	cfg.Verification = defaultVerificationConfig()
+	cfg.Verification.Providers = providers
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Bootstrap(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return m, cache
+}
+
+func TestVerificationSenderFallbackAndDelivery(t *testing.T) {
+	primary := &fakeSender{name: "primary", err: errors.New("gateway timeout")}
+	backup := &fakeSender{name: "backup"}
+	m, _ := newNotifyTestManager(t, map[string][]VerificationSender{VerificationChannelSMS: {primary, backup}})
+	m.cfg.Verification.Templates = []VerificationTemplate{
+		{Channel: VerificationChannelSMS, Purpose: VerificationPurposeLogin, Locale: "en", Body: "Login code {{.Code}} ({{.TTLMinutes}} min)", TemplateCodes: map[string]string{"backup": "SMS_1"}},
+	}
+	ctx := context.Background()
+
+	res, err := m.Verification().Send(ctx, SendVerificationRequest{Channel: VerificationChannelSMS, Target: "13800000000", Purpose: VerificationPurposeLogin, Locale: "en-US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.sent) != 1 || len(backup.sent) != 1 {
+		t.Fatalf("expected fallback to backup sender, got primary=%d backup=%d", len(primary.sent), len(backup.sent))
+	}
+	msg := backup.sent[0]
+	if msg.Body != "Login code "+msg.Code+" (5 min)" || msg.TemplateCodes["backup"] != "SMS_1" || msg.TemplateParams["code"] != msg.Code {
+		t.Fatalf("unexpected rendered message: %+v", msg)
+	}
+
+	status, err := m.Verification().DeliveryStatus(ctx, "", res.ChallengeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != DeliveryStatusSent || status.Provider != "backup" || len(status.Attempts) != 2 ||
+		status.Attempts[0].Status != DeliveryStatusFailed || status.Attempts[0].Error == "" {
+		t.Fatalf("unexpected delivery status: %+v", status)
+	}
+
+	if err := m.Verification().ReportDelivery(ctx, "backup", "unknown", DeliveryStatusDelivered, ""); err == nil {
+		t.Fatal("expected unknown message id to be rejected")
+	}
+	if err := m.Verification().ReportDelivery(ctx, "backup", "backup-"+res.ChallengeID, DeliveryStatusUndelivered, "blacklisted"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ = m.Verification().DeliveryStatus(ctx, "", res.ChallengeID); status.Status != DeliveryStatusUndelivered {
+		t.Fatalf("expected receipt to update challenge, got %+v", status)
+	}
+	if _, err := m.Verification().DeliveryStatus(ctx, "other", res.ChallengeID); err == nil {
+		t.Fatal("expected challenge of another tenant to be hidden")
+	}
+
+	// 其他语言回退到内置中文模板；全部发送方失败时挑战标记为 failed。
+	backup.err = errors.New("quota exceeded")
+	if _, err := m.Verification().Send(ctx, SendVerificationRequest{Channel: VerificationChannelSMS, Target: "13900000000", Purpose: VerificationPurposeLogin, Locale: "fr"}); err == nil {
+		t.Fatal("expected send to fail when every sender fails")
+	}
+	if !strings.HasPrefix(backup.sent[1].Body, "验证码 ") {
+		t.Fatalf("expected built-in zh-CN template, got %q", backup.sent[1].Body)
+	}
+	var failed VerificationChallenge
+	if err := m.db.Where("id = ?", backup.sent[1].ChallengeID).First(&failed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if failed.DeliveryStatus != DeliveryStatusFailed {
+		t.Fatalf("expected failed delivery status, got %q", failed.DeliveryStatus)
+	}
+}
+
+func TestVerificationSendSharedLimits(t *testing.T) {
+	m, _ := newNotifyTestManager(t, map[string][]VerificationSender{VerificationChannelEmail: {&fakeSender{name: "mail"}}})
+	m.cfg.Verification.MaxPerIPPer10Min = 2
+	ctx := context.Background()
+
+	send := func(target, ip string) error {
+		_, err := m.Verification().Send(ctx, SendVerificationRequest{Channel: VerificationChannelEmail, Target: target, Purpose: VerificationPurposeRegister, IP: ip})
+		return err
+	}
+	if err := send("a@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := send("a@example.com", "10.0.0.2"); err == nil {
+		t.Fatal("expected send interval to apply per target")
+	}
+	if err := send("b@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := send("c@example.com", "10.0.0.1"); err == nil {
+		t.Fatal("expected per-IP limit to apply")
+	}
+
+	for i := 0; i < m.cfg.Verification.MaxPerTargetPerHour; i++ {
+		if err := m.risk.CheckVerificationSend(ctx, "default", targetHash("d@example.com"), VerificationPurposeRegister, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.risk.CheckVerificationSend(ctx, "default", targetHash("d@example.com"), VerificationPurposeRegister, ""); err == nil {
+		t.Fatal("expected per-target hourly limit to apply")
+	}
+
+	for i := 0; i < m.cfg.Risk.MaxLoginFailuresPerIP; i++ {
+		m.risk.RecordFailure(ctx, "default", "", "10.0.0.9")
+	}
+	if err := send("e@example.com", "10.0.0.9"); err == nil || !strings.Contains(err.Error(), "风险") {
+		t.Fatalf("expected IP with login failures to be blocked, got %v", err)
+	}
+}
+
+func TestAliyunSMSSender(t *testing.T) {
+	// 阿里云 RPC 签名文档中的示例。
+	params := url.Values{
+		"AccessKeyId":      {"testid"},
+		"Action":           {"DescribeRegions"},
+		"Format":           {"XML"},
+		"SignatureMethod":  {"HMAC-SHA1"},
+		"SignatureNonce":   {"3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf"},
+		"SignatureVersion": {"1.0"},
+		"Timestamp":        {"2016-02-23T12:46:24Z"},
+		"Version":          {"2014-05-26"},
+	}
+	if got := aliyunSignature(http.MethodGet, aliyunCanonicalQuery(params), "testsecret"); got != "OLeaidS1JvxuMvnyHOwuJ+uX5qY=" {
+		t.Fatalf("unexpected signature %s", got)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form := r.PostForm
+		signature := form.Get("Signature")
+		form.Del("Signature")
+		if aliyunSignature(http.MethodPost, aliyunCanonicalQuery(form), "secret") != signature {
+			_, _ = w.Write([]byte(`{"Code":"SignatureDoesNotMatch","Message":"bad signature"}`))
+			return
+		}
+		if form.Get("TemplateCode") != "SMS_42" || form.Get("TemplateParam") != `{"code":"123456","ttl":"5"}` || form.Get("PhoneNumbers") != "8613800000000" {
+			_, _ = w.Write([]byte(`{"Code":"isv.INVALID_PARAMETERS","Message":"` + form.Encode() + `"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Code":"OK","Message":"OK","BizId":"biz-1"}`))
+	}))
+	defer srv.Close()
+
+	sender := NewAliyunSMSSender(AliyunSMSConfig{AccessKeyID: "key", AccessKeySecret: "secret", SignName: "gaia", TemplateCode: "SMS_1", ParamNames: []string{"code", "ttl"}, Endpoint: srv.URL})
+	msg := &VerificationMessage{Target: "+8613800000000", Code: "123456", TemplateCodes: map[string]string{"aliyun": "SMS_42"}, TemplateParams: map[string]string{"code": "123456", "ttl": "5"}}
+	id, err := sender.Send(context.Background(), msg)
+	if err != nil || id != "biz-1" {
+		t.Fatalf("aliyun send = %q, %v", id, err)
+	}
+	sender.cfg.AccessKeySecret = "wrong"
+	if _, err := sender.Send(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+func TestTencentSMSSender(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		want := tencentAuthorization("sid", "skey", r.Host, "sms", "SendSms", body, now)
+		if r.Header.Get("Authorization") != want || r.Header.Get("X-TC-Timestamp") != "1700000000" || r.Header.Get("X-TC-Action") != "SendSms" {
+			_, _ = w.Write([]byte(`{"Response":{"Error":{"Code":"AuthFailure.SignatureFailure","Message":"bad signature"}}}`))
+			return
+		}
+		var payload struct {
+			PhoneNumberSet   []string
+			TemplateId       string
+			TemplateParamSet []string
+		}
+		_ = json.Unmarshal(body, &payload)
+		if payload.PhoneNumberSet[0] != "+8613800000000" || payload.TemplateId != "1001" || len(payload.TemplateParamSet) != 1 || payload.TemplateParamSet[0] != "654321" {
+			_, _ = w.Write([]byte(`{"Response":{"Error":{"Code":"InvalidParameter","Message":"` + string(body) + `"}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Response":{"SendStatusSet":[{"SerialNo":"serial-1","Code":"Ok","Message":"send success"}]}}`))
+	}))
+	defer srv.Close()
+
+	sender := NewTencentSMSSender(TencentSMSConfig{SecretID: "sid", SecretKey: "skey", SdkAppID: "1400000000", SignName: "gaia", TemplateID: "1001", Endpoint: srv.URL})
+	sender.now = func() time.Time { return now }
+	msg := &VerificationMessage{Target: "13800000000", Code: "654321", TemplateParams: map[string]string{"code": "654321"}}
+	id, err := sender.Send(context.Background(), msg)
+	if err != nil || id != "serial-1" {
+		t.Fatalf("tencent send = %q, %v", id, err)
+	}
+	sender.cfg.SecretKey = "wrong"
+	if _, err := sender.Send(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "SignatureFailure") {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+func TestHTTPSMSSender(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Api-Key") != "k" || string(body) != `{"mobile":"13800000000","text":"code \"1\""}` {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write(body)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"id":"msg-9"}}`))
+	}))
+	defer srv.Close()
+
+	if _, err := NewHTTPSMSSender(HTTPSMSConfig{URL: srv.URL, BodyTemplate: "{{.Target"}); err == nil {
+		t.Fatal("expected invalid body template to be rejected")
+	}
+	sender, err := NewHTTPSMSSender(HTTPSMSConfig{
+		URL:            srv.URL,
+		Headers:        map[string]string{"X-Api-Key": "k"},
+		BodyTemplate:   `{"mobile":{{json .Target}},"text":{{json .Body}}}`,
+		MessageIDField: "data.id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := sender.Send(context.Background(), &VerificationMessage{Target: "13800000000", Body: `code "1"`})
+	if err != nil || id != "msg-9" {
+		t.Fatalf("http sms send = %q, %v", id, err)
+	}
+	if _, err := sender.Send(context.Background(), &VerificationMessage{Target: "13900000000"}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected non-2xx response to fail, got %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package account
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"strings"
 	"time"
@@ -34,6 +36,25 @@ type OAuthExchangeRequest struct {
 	CodeVerifier string
 }
 
+// OAuthAuthorizer 由支持服务端发起授权的提供商实现（如 OIDCProvider）。
+// 对这类提供商，OAuthService 负责生成并保存 state / nonce / PKCE code_verifier，
+// 登录与绑定时只接受由 Authorize 签发且未使用过的 state。
+type OAuthAuthorizer interface {
+	OAuthProvider
+
+	// AuthCodeURL builds the authorization URL. An empty RedirectURI falls back to
+	// the provider's configured callback; the callback actually used is returned.
+	AuthCodeURL(ctx context.Context, req OAuthAuthorizeParams) (authURL, redirectURI string, err error)
+}
+
+// OAuthAuthorizeParams 生成授权地址所需的参数。
+type OAuthAuthorizeParams struct {
+	RedirectURI   string
+	State         string
+	Nonce         string
+	CodeChallenge string // S256
+}
+
 // OAuthToken OAuth 提供商返回的令牌数据。
 type OAuthToken struct {
 	AccessToken  string `json:"access_token"`
@@ -61,6 +82,7 @@ type IdpClient struct {
 	TenantID          string    `gorm:"size:64;not null;uniqueIndex:uniq_acct_idp_clients,priority:1"`
 	ClientID          string    `gorm:"size:128;not null;uniqueIndex:uniq_acct_idp_clients,priority:2"`
 	ClientSecret      string    `gorm:"size:256;not null"`
+	Public            bool      `gorm:"not null;default:false"` // 公开客户端：没有密钥，只能使用设备授权流程
 	Name              string    `gorm:"size:200;not null"`
 	RedirectURIs      string    `gorm:"type:text;not null"` // JSON array of allowed redirect URIs
 	AllowedGrantTypes string    `gorm:"size:255;not null;default:authorization_code"` // comma-separated
@@ -128,6 +150,23 @@ type OAuthAccount struct {
 
 func (OAuthAccount) TableName() string { return "acct_oauth_accounts" }
 
+// OAuthState 服务端发起授权时保存的一次性 state，绑定 nonce 与 PKCE code_verifier。
+type OAuthState struct {
+	State        string    `gorm:"size:128;primaryKey"`
+	TenantID     string    `gorm:"size:64;not null"`
+	Provider     string    `gorm:"size:32;not null"`
+	Nonce        string    `gorm:"size:128;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	RedirectURI  string    `gorm:"size:512;not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OAuthState) TableName() string { return "acct_oauth_states" }
+
+// oauthStateTTL 授权 state 的有效期，覆盖用户在提供商页面登录的时间。
+const oauthStateTTL = 10 * time.Minute
+
 type OAuthService struct {
 	m *Manager
 }
@@ -158,25 +197,146 @@ type OAuthBindRequest struct {
 	CodeVerifier string
 }
 
+// OAuthAuthorizeRequest 服务端发起 OAuth 授权的请求参数。
+type OAuthAuthorizeRequest struct {
+	TenantID    string
+	Provider    string
+	RedirectURI string
+}
+
+// OAuthAuthorization 授权地址与对应的 state，前端跳转到 AuthorizationURL，回调后把 code 与 state 提交给登录/绑定接口。
+type OAuthAuthorization struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	State            string    `json:"state"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// Authorize 为支持 OAuthAuthorizer 的提供商生成授权地址，并保存一次性 state、nonce 与 PKCE code_verifier。
+func (s *OAuthService) Authorize(ctx context.Context, req OAuthAuthorizeRequest) (*OAuthAuthorization, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.oauth.authorize")
+	defer span.End()
+	tenantID := s.m.tenantID(req.TenantID)
+	if err := s.m.tenantSvc.checkOAuthProvider(ctx, tenantID, req.Provider); err != nil {
+		return nil, err
+	}
+
+	provider, ok := s.m.cfg.OAuthProviders[req.Provider]
+	if !ok {
+		return nil, accountError(ErrInvalidArgument, fmt.Sprintf("不支持的OAuth提供商: %s", req.Provider))
+	}
+	authorizer, ok := provider.(OAuthAuthorizer)
+	if !ok {
+		return nil, accountError(ErrInvalidArgument, fmt.Sprintf("OAuth提供商 %s 不支持服务端发起授权", req.Provider))
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := generateAuthCode()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL, redirectURI, err := authorizer.AuthCodeURL(ctx, OAuthAuthorizeParams{
+		RedirectURI:   req.RedirectURI,
+		State:         state,
+		Nonce:         nonce,
+		CodeChallenge: base64.RawURLEncoding.EncodeToString(challenge[:]),
+	})
+	if err != nil {
+		return nil, err
+	}
+	row := &OAuthState{
+		State:        state,
+		TenantID:     tenantID,
+		Provider:     req.Provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectURI:  redirectURI,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}
+	if err := s.m.db.WithContext(ctx).Create(row).Error; err != nil {
+		recordDBError(ctx)
+		return nil, fmt.Errorf("create oauth state: %w", err)
+	}
+	return &OAuthAuthorization{AuthorizationURL: authURL, State: state, ExpiresAt: row.ExpiresAt}, nil
+}
+
+// consumeState 对 OAuthAuthorizer 提供商消费一次性 state，并用保存的 nonce、code_verifier、
+// redirect_uri 覆盖请求中的值；其他提供商沿用调用方传入的参数。
+func (s *OAuthService) consumeState(ctx context.Context, tenantID, providerName string, provider OAuthProvider, req *OAuthExchangeRequest) error {
+	if _, ok := provider.(OAuthAuthorizer); !ok {
+		return nil
+	}
+	row, err := s.takeState(ctx, tenantID, providerName, req.State)
+	if err != nil {
+		return err
+	}
+	if req.RedirectURI != "" && req.RedirectURI != row.RedirectURI {
+		return accountError(ErrInvalidArgument, "redirect_uri 与授权请求不一致")
+	}
+	req.RedirectURI = row.RedirectURI
+	req.Nonce = row.Nonce
+	req.CodeVerifier = row.CodeVerifier
+	return nil
+}
+
+// takeState 消费一次性 state 记录，并校验其所属租户、用途（provider）与有效期。
+func (s *OAuthService) takeState(ctx context.Context, tenantID, providerName, state string) (*OAuthState, error) {
+	if state == "" {
+		return nil, accountError(ErrInvalidArgument, "缺少 state")
+	}
+	var row OAuthState
+	if err := s.m.db.WithContext(ctx).Where("state = ?", state).First(&row).Error; err != nil {
+		return nil, accountError(ErrInvalidCredential, "state 无效或已使用")
+	}
+	// 以删除成功作为消费凭证，并发回调只有一个能通过
+	res := s.m.db.WithContext(ctx).Where("state = ?", state).Delete(&OAuthState{})
+	if res.Error != nil {
+		recordDBError(ctx)
+		return nil, res.Error
+	}
+	if res.RowsAffected != 1 || row.Provider != providerName || row.TenantID != tenantID {
+		return nil, accountError(ErrInvalidCredential, "state 无效或已使用")
+	}
+	if time.Now().After(row.ExpiresAt) {
+		return nil, accountError(ErrExpiredToken, "state 已过期")
+	}
+	return &row, nil
+}
+
 // OAuthLogin 通过 OAuth/OIDC 提供商登录或注册用户。
 func (s *OAuthService) Login(ctx context.Context, req OAuthLoginRequest) (*AuthResult, error) {
 	ctx, span := s.m.tracer.Start(ctx, "account.oauth.login")
 	defer span.End()
 	tenantID := s.m.tenantID(req.TenantID)
+	if err := s.m.tenantSvc.checkOAuthProvider(ctx, tenantID, req.Provider); err != nil {
+		return nil, err
+	}
 
 	provider, ok := s.m.cfg.OAuthProviders[req.Provider]
 	if !ok {
 		return nil, accountError(ErrInvalidArgument, fmt.Sprintf("不支持的OAuth提供商: %s", req.Provider))
 	}
 
-	// Exchange code for tokens
-	oauthToken, err := provider.ExchangeCode(ctx, OAuthExchangeRequest{
+	exchange := OAuthExchangeRequest{
 		Code:         req.Code,
 		RedirectURI:  req.RedirectURI,
 		State:        req.State,
 		Nonce:        req.Nonce,
 		CodeVerifier: req.CodeVerifier,
-	})
+	}
+	if err := s.consumeState(ctx, tenantID, req.Provider, provider, &exchange); err != nil {
+		s.m.audit(ctx, tenantID, "", "oauth_login", "failed",
+			fmt.Sprintf("state check failed: %s", err.Error()), req.IP, req.UserAgent)
+		return nil, err
+	}
+
+	// Exchange code for tokens
+	oauthToken, err := provider.ExchangeCode(ctx, exchange)
 	if err != nil {
 		s.m.audit(ctx, tenantID, "", "oauth_login", "failed",
 			fmt.Sprintf("code exchange failed: %s", err.Error()), req.IP, req.UserAgent)
@@ -236,18 +396,20 @@ func (s *OAuthService) Login(ctx context.Context, req OAuthLoginRequest) (*AuthR
 		if err != nil {
 			return err
 		}
-		// Enforce admin MFA: require TOTP, or fall back to email/SMS verification
-		if s.m.cfg.AccountPolicy.RequireAdminMFA && userHasAdminRole(roles) && !s.m.mfa.HasTOTP(ctx, tenantID, user.ID) {
+		// Enforce admin MFA / tenant MFA policy: require TOTP, or fall back to email/SMS verification
+		mfaRequired, mfaEnforced := s.m.mfa.enrollmentState(ctx, tenantID, user.ID, roles)
+		if mfaEnforced {
 			if r := s.m.auth.adminMFAFallback(ctx, tx, &user, req.IP); r != nil {
 				result = r
 				return nil
 			}
 			return accountError(ErrPermissionDenied, "需要先配置 MFA 后才能登录")
 		}
-		result, err = s.m.auth.issueTokens(ctx, tx, &user, roles, req.DeviceID, req.IP, req.UserAgent, "", "", "")
+		result, err = s.m.auth.issueTokens(ctx, tx, &user, roles, []string{AMRFederated}, req.DeviceID, req.IP, req.UserAgent, "", "", "")
 		if err != nil {
 			return err
 		}
+		result.MFAEnrollmentRequired = mfaRequired
 		now := time.Now()
 		return tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
 			"last_login_at": now,
@@ -283,13 +445,18 @@ func (s *OAuthService) Bind(ctx context.Context, req OAuthBindRequest) error {
 		return accountError(ErrInvalidArgument, fmt.Sprintf("不支持的OAuth提供商: %s", req.Provider))
 	}
 
-	oauthToken, err := provider.ExchangeCode(ctx, OAuthExchangeRequest{
+	exchange := OAuthExchangeRequest{
 		Code:         req.Code,
 		RedirectURI:  req.RedirectURI,
 		State:        req.State,
 		Nonce:        req.Nonce,
 		CodeVerifier: req.CodeVerifier,
-	})
+	}
+	if err := s.consumeState(ctx, tenantID, req.Provider, provider, &exchange); err != nil {
+		return err
+	}
+
+	oauthToken, err := provider.ExchangeCode(ctx, exchange)
 	if err != nil {
 		return accountError(ErrInvalidCredential, "OAuth 授权码无效")
 	}
@@ -393,6 +560,9 @@ func (s *OAuthService) createUserFromOAuth(ctx context.Context, tx *gorm.DB, ten
 	if s.m.cfg.AccountPolicy.RequireVerifiedPhone {
 		user.Status = UserStatusPending
 	}
+	if err := s.m.tenantSvc.admitUser(tx, tenantID); err != nil {
+		return err
+	}
 
 	if err := tx.Create(&user).Error; err != nil {
 		if isDuplicateKeyErr(err) {
@@ -441,16 +611,20 @@ func (s *OAuthService) createUserFromOAuth(ctx context.Context, tx *gorm.DB, ten
 	if err != nil {
 		return err
 	}
-	// Enforce admin MFA: require TOTP, or fall back to email/SMS verification
-	if s.m.cfg.AccountPolicy.RequireAdminMFA && userHasAdminRole(roles) && !s.m.mfa.HasTOTP(ctx, tenantID, user.ID) {
+	// Enforce admin MFA / tenant MFA policy: require TOTP, or fall back to email/SMS verification
+	mfaRequired, mfaEnforced := s.m.mfa.enrollmentState(ctx, tenantID, user.ID, roles)
+	if mfaEnforced {
 		if r := s.m.auth.adminMFAFallback(ctx, tx, &user, req.IP); r != nil {
 			*result = r
 			return nil
 		}
 		return accountError(ErrPermissionDenied, "需要先配置 MFA 后才能登录")
 	}
-	*result, err = s.m.auth.issueTokens(ctx, tx, &user, roles, req.DeviceID, req.IP, req.UserAgent, "", "", "")
-	return err
+	if *result, err = s.m.auth.issueTokens(ctx, tx, &user, roles, []string{AMRFederated}, req.DeviceID, req.IP, req.UserAgent, "", "", ""); err != nil {
+		return err
+	}
+	(*result).MFAEnrollmentRequired = mfaRequired
+	return nil
 }
 
 // bindOAuthToUser handles the case where an OAuth user has the same email as an existing user.
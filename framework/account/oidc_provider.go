@@ -0,0 +1,665 @@
+package account
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/xxzhwl/gaia"
+)
+
+const (
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	// oidcDiscoveryTTL 发现文档的缓存时长。
+	oidcDiscoveryTTL = 24 * time.Hour
+	// oidcJWKSCacheTTL JWKS 的缓存时长；遇到未知 kid 时会提前刷新。
+	oidcJWKSCacheTTL = time.Hour
+	// oidcJWKSMinRefreshInterval 两次 JWKS 拉取的最小间隔，防止伪造 kid 的令牌把请求放大到提供商。
+	oidcJWKSMinRefreshInterval = time.Minute
+	// oidcClockSkew 校验 exp / iat / nbf 时允许的时钟偏差。
+	oidcClockSkew = time.Minute
+	// oidcMaxResponseSize 提供商响应体上限。
+	oidcMaxResponseSize = 1 << 20
+
+	oidcAuthMethodBasic = "client_secret_basic"
+	oidcAuthMethodPost  = "client_secret_post"
+	oidcAuthMethodNone  = "none"
+)
+
+// oidcSigningMethods id_token 允许的签名算法；拒绝 none 与 HMAC，避免用 client_secret 伪造令牌。
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// OIDCProviderConfig 通用 OIDC / OAuth2 依赖方（RP）提供商配置。
+// 只需填写 Issuer 与客户端凭据，其余端点从 Issuer + /.well-known/openid-configuration 自动发现；
+// 对未完整实现 OIDC 的提供商，可用 *Endpoint 字段覆盖或补齐发现结果。
+type OIDCProviderConfig struct {
+	// Name 提供商标识，即 /auth/login/oauth/:provider 中的 provider；从配置加载时取 map 的键。
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL 默认回调地址。配置后授权请求只能使用该地址。
+	RedirectURL string
+	// Scopes 授权范围，默认 openid profile email。
+	Scopes []string
+	// TokenAuthMethod 令牌端点的客户端认证方式：client_secret_basic / client_secret_post / none。
+	// 为空时按发现文档选择，优先 client_secret_basic；ClientSecret 为空时为 none。
+	TokenAuthMethod string
+	// AuthParams 追加到授权地址的参数，如 Azure AD 的 domain_hint、Keycloak 的 kc_idp_hint。
+	AuthParams map[string]string
+
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	UserInfoEndpoint      string
+	JWKSURI               string
+
+	// SkipUserInfo 不调用 userinfo 端点，只使用 id_token 中的声明。
+	SkipUserInfo bool
+	// AllowMissingIDToken 允许令牌响应不含 id_token（纯 OAuth2 提供商），此时用户信息只来自 userinfo 端点。
+	AllowMissingIDToken bool
+	// TrustEmail 把映射到的邮箱视为已验证，适用于不返回 email_verified 的企业 IdP（如 Azure AD）。
+	TrustEmail bool
+	// Claims 声明到 OAuthUserInfo 的映射。
+	Claims OIDCClaimMapping
+
+	HTTPClient *http.Client `json:"-"`
+}
+
+// OIDCClaimMapping 声明映射。每项是声明路径，嵌套字段用 "." 分隔（如 data.union_id），
+// 多个候选用 "," 分隔，取第一个非空值。
+type OIDCClaimMapping struct {
+	Subject       string // 默认 sub
+	Email         string // 默认 email
+	EmailVerified string // 默认 email_verified
+	Name          string // 默认 name,preferred_username
+	AvatarURL     string // 默认 picture
+}
+
+// OIDCProvider 基于发现文档的通用 OIDC 提供商，实现 OAuthProvider 与 OAuthAuthorizer。
+// 授权使用 PKCE（S256），id_token 按 JWKS 校验签名，并校验 iss / aud / exp / nonce / at_hash。
+type OIDCProvider struct {
+	cfg        OIDCProviderConfig
+	httpClient *http.Client
+
+	discoveryMu  sync.Mutex
+	discovery    *oidcDiscovery
+	discoveredAt time.Time
+
+	jwksMu        sync.Mutex
+	jwks          []oidcKey
+	jwksFetchedAt time.Time
+	jwksAttemptAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                   string   `json:"issuer"`
+	AuthorizationEndpoint    string   `json:"authorization_endpoint"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	UserInfoEndpoint         string   `json:"userinfo_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+type oidcJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type oidcKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+type oidcTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Error        string `json:"error,omitempty"`
+	ErrorDesc    string `json:"error_description,omitempty"`
+}
+
+// NewOIDCProvider 创建通用 OIDC 提供商。发现文档与 JWKS 在首次使用时拉取。
+func NewOIDCProvider(cfg OIDCProviderConfig) *OIDCProvider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	cfg.Claims = cfg.Claims.withDefaults()
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDCProvider{cfg: cfg, httpClient: client}
+}
+
+func (m OIDCClaimMapping) withDefaults() OIDCClaimMapping {
+	if m.Subject == "" {
+		m.Subject = "sub"
+	}
+	if m.Email == "" {
+		m.Email = "email"
+	}
+	if m.EmailVerified == "" {
+		m.EmailVerified = "email_verified"
+	}
+	if m.Name == "" {
+		m.Name = "name,preferred_username"
+	}
+	if m.AvatarURL == "" {
+		m.AvatarURL = "picture"
+	}
+	return m
+}
+
+func (p *OIDCProvider) Name() string { return p.cfg.Name }
+
+// AuthCodeURL 生成带 state / nonce / PKCE 的授权地址，返回实际使用的回调地址。
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, req OAuthAuthorizeParams) (string, string, error) {
+	doc, err := p.endpoints(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if doc.AuthorizationEndpoint == "" {
+		return "", "", fmt.Errorf("oidc %s: authorization endpoint is not configured", p.cfg.Name)
+	}
+	redirectURI := req.RedirectURI
+	if redirectURI == "" {
+		redirectURI = p.cfg.RedirectURL
+	}
+	if redirectURI == "" {
+		return "", "", accountError(ErrInvalidArgument, "缺少 redirect_uri")
+	}
+	if p.cfg.RedirectURL != "" && redirectURI != p.cfg.RedirectURL {
+		return "", "", accountError(ErrInvalidArgument, "redirect_uri 与提供商配置不一致")
+	}
+	u, err := url.Parse(doc.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("oidc %s: parse authorization endpoint: %w", p.cfg.Name, err)
+	}
+	q := u.Query()
+	for k, v := range p.cfg.AuthParams {
+		q.Set(k, v)
+	}
+	// 协议参数最后写入，避免被 AuthParams 覆盖
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", req.State)
+	q.Set("nonce", req.Nonce)
+	q.Set("code_challenge", req.CodeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), redirectURI, nil
+}
+
+// ExchangeCode 用授权码与 PKCE code_verifier 换取令牌。
+func (p *OIDCProvider) ExchangeCode(ctx context.Context, req OAuthExchangeRequest) (*OAuthToken, error) {
+	if req.State == "" {
+		return nil, fmt.Errorf("oidc %s: state is required for CSRF protection", p.cfg.Name)
+	}
+	if req.CodeVerifier == "" {
+		return nil, fmt.Errorf("oidc %s: code_verifier is required", p.cfg.Name)
+	}
+	doc, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if doc.TokenEndpoint == "" {
+		return nil, fmt.Errorf("oidc %s: token endpoint is not configured", p.cfg.Name)
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {req.Code},
+		"redirect_uri":  {req.RedirectURI},
+		"code_verifier": {req.CodeVerifier},
+	}
+	method := p.tokenAuthMethod(doc)
+	if method != oidcAuthMethodBasic {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+	if method == oidcAuthMethodPost {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s token request: %w", p.cfg.Name, err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if method == oidcAuthMethodBasic {
+		// RFC 6749 2.3.1：凭据先做 form 编码再放入 Basic 认证头
+		httpReq.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s token request: %w", p.cfg.Name, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s token response read: %w", p.cfg.Name, err)
+	}
+	var tr oidcTokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("oidc %s token response parse (%s): %w", p.cfg.Name, resp.Status, err)
+	}
+	if tr.Error != "" {
+		return nil, fmt.Errorf("oidc %s error: %s: %s", p.cfg.Name, tr.Error, tr.ErrorDesc)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc %s token endpoint: %s", p.cfg.Name, resp.Status)
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("oidc %s: empty access token", p.cfg.Name)
+	}
+	return &OAuthToken{
+		AccessToken:  tr.AccessToken,
+		TokenType:    tr.TokenType,
+		ExpiresIn:    tr.ExpiresIn,
+		RefreshToken: tr.RefreshToken,
+		IDToken:      tr.IDToken,
+		Nonce:        req.Nonce,
+	}, nil
+}
+
+// GetUserInfo 校验 id_token 并合并 userinfo 端点返回的声明，按 Claims 映射为 OAuthUserInfo。
+// id_token 中的声明经过签名，优先级高于 userinfo；userinfo 只补齐缺失的声明。
+func (p *OIDCProvider) GetUserInfo(ctx context.Context, token *OAuthToken) (*OAuthUserInfo, error) {
+	doc, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]any{}
+	if token.IDToken != "" {
+		if claims, err = p.verifyIDToken(ctx, doc, token); err != nil {
+			return nil, fmt.Errorf("oidc %s id token validation failed: %w", p.cfg.Name, err)
+		}
+	} else if !p.cfg.AllowMissingIDToken {
+		return nil, fmt.Errorf("oidc %s: token response has no id_token", p.cfg.Name)
+	}
+
+	if doc.UserInfoEndpoint != "" && !p.cfg.SkipUserInfo {
+		info, err := p.fetchUserInfo(ctx, doc.UserInfoEndpoint, token.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		// OIDC Core 5.3.2：userinfo 的 sub 必须与 id_token 一致，防止令牌替换
+		if sub, ok := claims["sub"]; ok {
+			if got, ok := info["sub"]; ok && claimToString(got) != claimToString(sub) {
+				return nil, fmt.Errorf("oidc %s: userinfo sub does not match id token", p.cfg.Name)
+			}
+		}
+		for k, v := range info {
+			if _, exists := claims[k]; !exists {
+				claims[k] = v
+			}
+		}
+	}
+
+	userInfo := &OAuthUserInfo{
+		Subject:   lookupClaim(claims, p.cfg.Claims.Subject),
+		Email:     lookupClaim(claims, p.cfg.Claims.Email),
+		Name:      lookupClaim(claims, p.cfg.Claims.Name),
+		AvatarURL: lookupClaim(claims, p.cfg.Claims.AvatarURL),
+	}
+	if userInfo.Subject == "" {
+		return nil, fmt.Errorf("oidc %s: subject claim %q is empty", p.cfg.Name, p.cfg.Claims.Subject)
+	}
+	if len(userInfo.Subject) > 128 {
+		return nil, fmt.Errorf("oidc %s: subject exceeds 128 characters", p.cfg.Name)
+	}
+	if userInfo.Email != "" {
+		userInfo.EmailVerified = p.cfg.TrustEmail || strings.EqualFold(lookupClaim(claims, p.cfg.Claims.EmailVerified), "true")
+	}
+	return userInfo, nil
+}
+
+// verifyIDToken 校验 id_token 的签名、iss、aud/azp、exp/iat、nonce 与 at_hash，返回全部声明。
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, doc *oidcDiscovery, token *OAuthToken) (map[string]any, error) {
+	if token.Nonce == "" {
+		return nil, errors.New("expected nonce is missing")
+	}
+	if doc.JWKSURI == "" {
+		return nil, errors.New("jwks_uri is not configured")
+	}
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token.IDToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.verificationKey(ctx, doc.JWKSURI, kid, t.Method.Alg())
+	},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+		jwt.WithJSONNumber(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Azure AD 多租户端点的 issuer 形如 https://login.microsoftonline.com/{tenantid}/v2.0，按 tid 声明展开
+	expectedIssuer := doc.Issuer
+	if strings.Contains(expectedIssuer, "{tenantid}") {
+		tid := claimToString(claims["tid"])
+		if tid == "" {
+			return nil, errors.New("tid claim is required for multi-tenant issuer")
+		}
+		expectedIssuer = strings.ReplaceAll(expectedIssuer, "{tenantid}", tid)
+	}
+	if iss := claimToString(claims["iss"]); iss != expectedIssuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if azp := claimToString(claims["azp"]); azp != "" && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("unexpected azp %q", azp)
+	}
+	if nonce := claimToString(claims["nonce"]); subtle.ConstantTimeCompare([]byte(nonce), []byte(token.Nonce)) != 1 {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	if atHash := claimToString(claims["at_hash"]); atHash != "" && token.AccessToken != "" {
+		if !verifyAtHash(parsed.Method.Alg(), token.AccessToken, atHash) {
+			return nil, errors.New("id token at_hash mismatch")
+		}
+	}
+	return claims, nil
+}
+
+// verificationKey 按 kid 查找 JWKS 公钥。缓存过期或遇到未知 kid（提供商轮换密钥）时重新拉取，
+// 但两次拉取至少间隔 oidcJWKSMinRefreshInterval。
+func (p *OIDCProvider) verificationKey(ctx context.Context, jwksURI, kid, alg string) (any, error) {
+	p.jwksMu.Lock()
+	defer p.jwksMu.Unlock()
+
+	if time.Since(p.jwksFetchedAt) > oidcJWKSCacheTTL && time.Since(p.jwksAttemptAt) >= oidcJWKSMinRefreshInterval {
+		if err := p.refreshJWKS(ctx, jwksURI); err != nil && len(p.jwks) == 0 {
+			return nil, err
+		}
+	}
+	if key := p.lookupKey(kid, alg); key != nil {
+		return key, nil
+	}
+	if time.Since(p.jwksAttemptAt) >= oidcJWKSMinRefreshInterval {
+		if err := p.refreshJWKS(ctx, jwksURI); err != nil {
+			return nil, err
+		}
+		if key := p.lookupKey(kid, alg); key != nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no matching jwk for kid %q", kid)
+}
+
+func (p *OIDCProvider) lookupKey(kid, alg string) crypto.PublicKey {
+	for _, k := range p.jwks {
+		if kid != "" && k.kid != kid {
+			continue
+		}
+		if k.alg != "" && k.alg != alg {
+			continue
+		}
+		switch k.key.(type) {
+		case *rsa.PublicKey:
+			if strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS") {
+				return k.key
+			}
+		case *ecdsa.PublicKey:
+			if strings.HasPrefix(alg, "ES") {
+				return k.key
+			}
+		}
+	}
+	return nil
+}
+
+func (p *OIDCProvider) refreshJWKS(ctx context.Context, jwksURI string) error {
+	p.jwksAttemptAt = time.Now()
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := p.getJSON(ctx, jwksURI, "", &set); err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys := make([]oidcKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			gaia.WarnF("[account] oidc %s: skip jwk %q: %v", p.cfg.Name, jwk.Kid, err)
+			continue
+		}
+		keys = append(keys, oidcKey{kid: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return errors.New("no usable signing keys in jwks")
+	}
+	p.jwks = keys
+	p.jwksFetchedAt = time.Now()
+	return nil
+}
+
+func (k oidcJWK) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode e: %w", err)
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid rsa key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode y: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("invalid ec key")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// endpoints 返回合并了配置覆盖项的端点信息。发现文档按 oidcDiscoveryTTL 缓存，
+// 刷新失败时继续使用旧文档。
+func (p *OIDCProvider) endpoints(ctx context.Context) (*oidcDiscovery, error) {
+	p.discoveryMu.Lock()
+	defer p.discoveryMu.Unlock()
+
+	if p.discovery != nil && time.Since(p.discoveredAt) < oidcDiscoveryTTL {
+		return p.discovery, nil
+	}
+	doc := &oidcDiscovery{Issuer: p.cfg.Issuer}
+	if p.cfg.Issuer != "" {
+		var fetched oidcDiscovery
+		if err := p.getJSON(ctx, p.cfg.Issuer+oidcDiscoveryPath, "", &fetched); err != nil {
+			if p.discovery != nil {
+				gaia.WarnF("[account] oidc %s: refresh discovery failed, using cached document: %v", p.cfg.Name, err)
+				p.discoveredAt = time.Now()
+				return p.discovery, nil
+			}
+			return nil, fmt.Errorf("oidc %s discovery: %w", p.cfg.Name, err)
+		}
+		// OIDC Discovery 4.3：文档中的 issuer 必须与请求的 issuer 完全一致
+		if strings.TrimRight(fetched.Issuer, "/") != p.cfg.Issuer && !strings.Contains(fetched.Issuer, "{tenantid}") {
+			return nil, fmt.Errorf("oidc %s discovery: issuer mismatch: %q", p.cfg.Name, fetched.Issuer)
+		}
+		doc = &fetched
+	}
+	if p.cfg.AuthorizationEndpoint != "" {
+		doc.AuthorizationEndpoint = p.cfg.AuthorizationEndpoint
+	}
+	if p.cfg.TokenEndpoint != "" {
+		doc.TokenEndpoint = p.cfg.TokenEndpoint
+	}
+	if p.cfg.UserInfoEndpoint != "" {
+		doc.UserInfoEndpoint = p.cfg.UserInfoEndpoint
+	}
+	if p.cfg.JWKSURI != "" {
+		doc.JWKSURI = p.cfg.JWKSURI
+	}
+	p.discovery = doc
+	p.discoveredAt = time.Now()
+	return doc, nil
+}
+
+func (p *OIDCProvider) tokenAuthMethod(doc *oidcDiscovery) string {
+	if p.cfg.TokenAuthMethod != "" {
+		return p.cfg.TokenAuthMethod
+	}
+	if p.cfg.ClientSecret == "" {
+		return oidcAuthMethodNone
+	}
+	// 发现文档未声明时按规范默认 client_secret_basic
+	if len(doc.TokenEndpointAuthMethods) == 0 || contains(doc.TokenEndpointAuthMethods, oidcAuthMethodBasic) {
+		return oidcAuthMethodBasic
+	}
+	if contains(doc.TokenEndpointAuthMethods, oidcAuthMethodPost) {
+		return oidcAuthMethodPost
+	}
+	return oidcAuthMethodBasic
+}
+
+func (p *OIDCProvider) fetchUserInfo(ctx context.Context, endpoint, accessToken string) (map[string]any, error) {
+	info := map[string]any{}
+	if err := p.getJSON(ctx, endpoint, accessToken, &info); err != nil {
+		return nil, fmt.Errorf("oidc %s userinfo: %w", p.cfg.Name, err)
+	}
+	return info, nil
+}
+
+// getJSON 发起 GET 请求并解码 JSON 响应，数字保留为 json.Number 以免大整数 ID 丢失精度。
+func (p *OIDCProvider) getJSON(ctx context.Context, endpoint, bearer string, out any) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	dec := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	dec.UseNumber()
+	return dec.Decode(out)
+}
+
+// lookupClaim 按映射路径取声明值，"," 分隔的候选取第一个非空值。
+func lookupClaim(claims map[string]any, paths string) string {
+	for _, path := range strings.Split(paths, ",") {
+		var cur any = claims
+		for _, part := range strings.Split(strings.TrimSpace(path), ".") {
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				cur = nil
+				break
+			}
+			cur = obj[part]
+		}
+		if s := claimToString(cur); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func claimToString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		if val {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return fmt.Sprintf("%.0f", val)
+	}
+	return ""
+}
+
+// verifyAtHash 按 OIDC Core 3.1.3.6 校验 at_hash：access_token 哈希的左半部分。
+func verifyAtHash(alg, accessToken, atHash string) bool {
+	var h hash.Hash
+	switch alg[len(alg)-3:] {
+	case "256":
+		h = sha256.New()
+	case "384":
+		h = sha512.New384()
+	case "512":
+		h = sha512.New()
+	default:
+		return false
+	}
+	h.Write([]byte(accessToken))
+	sum := h.Sum(nil)
+	want := base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+	return subtle.ConstantTimeCompare([]byte(want), []byte(atHash)) == 1
+}
@@ -0,0 +1,405 @@
+package account
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/xxzhwl/gaia/errwrap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// oidcTestIdP 进程内的 OIDC 提供商：发现文档、JWKS、令牌端点（校验 PKCE）与 userinfo。
+type oidcTestIdP struct {
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	issuer    string
+	kid       string
+	key       any
+	codes     map[string]oidcTestGrant
+	jwksHits  int
+	claims    map[string]any // 追加/覆盖 id_token 声明
+	userinfo  map[string]any
+	basicAuth string
+}
+
+type oidcTestGrant struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+func newOIDCTestIdP(t *testing.T) *oidcTestIdP {
+	t.Helper()
+	idp := &oidcTestIdP{codes: map[string]oidcTestGrant{}}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.kid, idp.key = "rsa-1", key
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 idp.issuerValue(),
+			"authorization_endpoint": idp.srv.URL + "/authorize?tenant=corp",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"userinfo_endpoint":      idp.srv.URL + "/userinfo",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.jwksHits++
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []any{oidcTestJWK(idp.kid, idp.key)}})
+	})
+	mux.HandleFunc("/token", idp.handleToken)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at-"+"ok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(idp.userinfo)
+	})
+	idp.srv = httptest.NewServer(mux)
+	idp.issuer = idp.srv.URL
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *oidcTestIdP) issuerValue() string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.issuer
+}
+
+// authorize 模拟用户在提供商页面完成登录，返回授权码。
+func (idp *oidcTestIdP) authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("nonce") == "" {
+		t.Fatalf("授权地址缺少 PKCE / nonce 参数: %s", authURL)
+	}
+	code = "code-" + q.Get("state")[:8]
+	idp.mu.Lock()
+	idp.codes[code] = oidcTestGrant{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri")}
+	idp.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (idp *oidcTestIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	user, pass, _ := r.BasicAuth()
+	idp.basicAuth = user + ":" + pass
+	grant, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge || r.PostForm.Get("redirect_uri") != grant.redirectURI {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	atHash := sha256.Sum256([]byte("at-ok"))
+	claims := jwt.MapClaims{
+		"iss":     idp.issuer,
+		"sub":     "kc-user-1",
+		"aud":     "rp-client",
+		"exp":     time.Now().Add(5 * time.Minute).Unix(),
+		"iat":     time.Now().Unix(),
+		"nonce":   grant.nonce,
+		"at_hash": base64.RawURLEncoding.EncodeToString(atHash[:16]),
+		"email":   "Kc.User@Example.com",
+		"name":    "KC User",
+	}
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	if _, ok := idp.key.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+		// ES256 的 at_hash 同样使用 SHA-256，无需重算
+	}
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = idp.kid
+	signed, err := tok.SignedString(idp.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "at-ok", "token_type": "Bearer", "expires_in": 300, "id_token": signed})
+}
+
+func oidcTestJWK(kid string, key any) map[string]string {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+			"n": enc(k.N.Bytes()), "e": enc(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PrivateKey:
+		pub, _ := k.PublicKey.Bytes()
+		return map[string]string{"kty": "EC", "kid": kid, "use": "sig", "crv": "P-256",
+			"x": enc(pub[1:33]), "y": enc(pub[33:])}
+	}
+	return nil
+}
+
+func newOIDCTestManager(t *testing.T, idp *oidcTestIdP) *Manager {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "oidc.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testAuthConfig()
+	cfg.DB = db
+	cfg.OIDC = map[string]OIDCProviderConfig{
+		"keycloak": {
+			Issuer:       idp.srv.URL + "/",
+			ClientID:     "rp-client",
+			ClientSecret: "rp-secret",
+			RedirectURL:  "https://app.example.com/callback",
+			AuthParams:   map[string]string{"kc_idp_hint": "corp", "state": "ignored"},
+		},
+	}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Bootstrap(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func oidcTestLogin(t *testing.T, m *Manager, idp *oidcTestIdP) (*AuthResult, error) {
+	t.Helper()
+	ctx := context.Background()
+	authz, err := m.OAuth().Authorize(ctx, OAuthAuthorizeRequest{Provider: "keycloak"})
+	if err != nil {
+		t.Fatalf("生成授权地址失败: %v", err)
+	}
+	code, state := idp.authorize(t, authz.AuthorizationURL)
+	if state != authz.State {
+		t.Fatalf("授权地址中的 state 应与返回值一致")
+	}
+	return m.OAuth().Login(ctx, OAuthLoginRequest{Provider: "keycloak", Code: code, State: state})
+}
+
+func TestOIDCProviderLogin(t *testing.T) {
+	idp := newOIDCTestIdP(t)
+	idp.userinfo = map[string]any{"sub": "kc-user-1", "email_verified": true, "picture": "https://img.example.com/a.png", "name": "Ignored"}
+	m := newOIDCTestManager(t, idp)
+	ctx := context.Background()
+
+	authz, err := m.OAuth().Authorize(ctx, OAuthAuthorizeRequest{Provider: "keycloak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authz.AuthorizationURL)
+	q := u.Query()
+	if !strings.HasPrefix(authz.AuthorizationURL, idp.srv.URL+"/authorize?") || q.Get("tenant") != "corp" ||
+		q.Get("kc_idp_hint") != "corp" || q.Get("state") != authz.State || q.Get("client_id") != "rp-client" ||
+		q.Get("redirect_uri") != "https://app.example.com/callback" || q.Get("scope") != "openid profile email" {
+		t.Fatalf("授权地址参数错误: %s", authz.AuthorizationURL)
+	}
+	if _, err := m.OAuth().Authorize(ctx, OAuthAuthorizeRequest{Provider: "keycloak", RedirectURI: "https://evil.example.com/cb"}); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("未配置的回调地址应被拒绝，实际 %v", err)
+	}
+
+	code, state := idp.authorize(t, authz.AuthorizationURL)
+	res, err := m.OAuth().Login(ctx, OAuthLoginRequest{Provider: "keycloak", Code: code, State: state})
+	if err != nil {
+		t.Fatalf("OIDC 登录失败: %v", err)
+	}
+	if idp.basicAuth != "rp-client:rp-secret" {
+		t.Fatalf("默认应使用 client_secret_basic，实际 %q", idp.basicAuth)
+	}
+	var user User
+	m.db.Where("id = ?", res.User.ID).First(&user)
+	if stringValue(user.Email) != "kc.user@example.com" || user.EmailVerifiedAt == nil || user.Nickname != "KC User" || user.AvatarURL != "https://img.example.com/a.png" {
+		t.Fatalf("用户资料映射错误（id_token 优先，userinfo 补齐）: %+v", user)
+	}
+	var link OAuthAccount
+	if err := m.db.Where("provider = ? AND subject = ?", "keycloak", "kc-user-1").First(&link).Error; err != nil || link.UserID != user.ID {
+		t.Fatalf("应创建 OAuth 关联: %+v %v", link, err)
+	}
+
+	// state 只能使用一次
+	if _, err := m.OAuth().Login(ctx, OAuthLoginRequest{Provider: "keycloak", Code: code, State: state}); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("重放 state 应被拒绝，实际 %v", err)
+	}
+	if _, err := m.OAuth().Login(ctx, OAuthLoginRequest{Provider: "keycloak", Code: code}); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("缺少 state 应返回 400，实际 %v", err)
+	}
+
+	// 同一外部账号再次登录复用本地用户
+	res2, err := oidcTestLogin(t, m, idp)
+	if err != nil || res2.User.ID != user.ID {
+		t.Fatalf("再次登录应复用账号: %+v %v", res2, err)
+	}
+}
+
+func TestOIDCProviderRejectsInvalidIDToken(t *testing.T) {
+	cases := []struct {
+		name   string
+		claims map[string]any
+	}{
+		{"nonce", map[string]any{"nonce": "attacker-nonce"}},
+		{"audience", map[string]any{"aud": "other-client"}},
+		{"azp", map[string]any{"aud": []string{"rp-client", "other"}, "azp": "other"}},
+		{"issuer", map[string]any{"iss": "https://evil.example.com"}},
+		{"expired", map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{"at_hash", map[string]any{"at_hash": "AAAAAAAAAAAAAAAAAAAAAA"}},
+	}
+	idp := newOIDCTestIdP(t)
+	idp.userinfo = map[string]any{"sub": "kc-user-1"}
+	m := newOIDCTestManager(t, idp)
+	for _, tc := range cases {
+		idp.claims = tc.claims
+		if _, err := oidcTestLogin(t, m, idp); errwrap.GetCode(err) != ErrInvalidCredential {
+			t.Errorf("%s: 无效 id_token 应返回 401，实际 %v", tc.name, err)
+		}
+	}
+
+	// userinfo 的 sub 与 id_token 不一致视为令牌替换
+	idp.claims = nil
+	idp.userinfo = map[string]any{"sub": "someone-else"}
+	if _, err := oidcTestLogin(t, m, idp); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("userinfo sub 不一致应被拒绝，实际 %v", err)
+	}
+}
+
+func TestOIDCProviderJWKSRotation(t *testing.T) {
+	idp := newOIDCTestIdP(t)
+	idp.userinfo = map[string]any{"sub": "kc-user-1"}
+	m := newOIDCTestManager(t, idp)
+	provider := m.cfg.OAuthProviders["keycloak"].(*OIDCProvider)
+
+	for i := 0; i < 2; i++ {
+		if _, err := oidcTestLogin(t, m, idp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if idp.jwksHits != 1 {
+		t.Fatalf("JWKS 应被缓存，实际拉取 %d 次", idp.jwksHits)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.kid, idp.key = "ec-2", ecKey
+	idp.mu.Unlock()
+
+	// 距上次拉取不足最小间隔时，未知 kid 不触发刷新
+	if _, err := oidcTestLogin(t, m, idp); errwrap.GetCode(err) != ErrInvalidCredential || idp.jwksHits != 1 {
+		t.Fatalf("限流期内不应刷新 JWKS: %v hits=%d", err, idp.jwksHits)
+	}
+	provider.jwksMu.Lock()
+	provider.jwksAttemptAt = time.Now().Add(-oidcJWKSMinRefreshInterval)
+	provider.jwksMu.Unlock()
+	if _, err := oidcTestLogin(t, m, idp); err != nil || idp.jwksHits != 2 {
+		t.Fatalf("密钥轮换后应刷新 JWKS 并通过 ES256 校验: %v hits=%d", err, idp.jwksHits)
+	}
+}
+
+func TestOIDCProviderClaimMapping(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte(`{"data":{"union_id":123456789012345678901,"nick":"钉钉用户","avatar":"https://a/b.png","mail":"d@corp.cn"}}`))
+	}))
+	defer srv.Close()
+	p := NewOIDCProvider(OIDCProviderConfig{
+		Name:                  "dingtalk",
+		ClientID:              "ding",
+		AuthorizationEndpoint: srv.URL + "/auth",
+		TokenEndpoint:         srv.URL + "/token",
+		UserInfoEndpoint:      srv.URL + "/me",
+		AllowMissingIDToken:   true,
+		TrustEmail:            true,
+		Claims: OIDCClaimMapping{
+			Subject:   "data.union_id",
+			Email:     "data.email,data.mail",
+			Name:      "data.name,data.nick",
+			AvatarURL: "data.avatar",
+		},
+	})
+	info, err := p.GetUserInfo(context.Background(), &OAuthToken{AccessToken: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Subject != "123456789012345678901" || info.Email != "d@corp.cn" || !info.EmailVerified || info.Name != "钉钉用户" || info.AvatarURL != "https://a/b.png" {
+		t.Fatalf("声明映射错误: %+v", info)
+	}
+	if hits != 1 {
+		t.Fatalf("未配置 Issuer 时不应请求发现文档，实际请求 %d 次", hits)
+	}
+	if p.tokenAuthMethod(&oidcDiscovery{}) != oidcAuthMethodNone {
+		t.Fatal("没有 ClientSecret 时应作为公开客户端")
+	}
+
+	strict := NewOIDCProvider(OIDCProviderConfig{Name: "strict", ClientID: "c", TokenEndpoint: srv.URL, AuthorizationEndpoint: srv.URL})
+	if _, err := strict.GetUserInfo(context.Background(), &OAuthToken{AccessToken: "x"}); err == nil {
+		t.Fatal("默认要求 id_token")
+	}
+}
+
+func TestOIDCProviderMultiTenantIssuer(t *testing.T) {
+	idp := newOIDCTestIdP(t)
+	idp.issuer = "https://login.example.com/{tenantid}/v2.0"
+	idp.userinfo = map[string]any{}
+	idp.claims = map[string]any{"iss": "https://login.example.com/t-42/v2.0", "tid": "t-42"}
+	p := NewOIDCProvider(OIDCProviderConfig{Name: "azure", Issuer: idp.srv.URL, ClientID: "rp-client", ClientSecret: "s", RedirectURL: "https://rp/cb", SkipUserInfo: true})
+	ctx := context.Background()
+	authURL, redirectURI, err := p.AuthCodeURL(ctx, OAuthAuthorizeParams{State: "state-123456", Nonce: "n-1", CodeChallenge: oidcTestChallenge("v-1")})
+	if err != nil || redirectURI != "https://rp/cb" {
+		t.Fatalf("AuthCodeURL: %v %q", err, redirectURI)
+	}
+	code, _ := idp.authorize(t, authURL)
+	token, err := p.ExchangeCode(ctx, OAuthExchangeRequest{Code: code, RedirectURI: redirectURI, State: "state-123456", Nonce: "n-1", CodeVerifier: "v-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetUserInfo(ctx, token); err != nil {
+		t.Fatalf("按 tid 展开的 issuer 应通过校验: %v", err)
+	}
+
+	idp.claims["tid"] = "t-other"
+	code, _ = idp.authorize(t, authURL)
+	token, _ = p.ExchangeCode(ctx, OAuthExchangeRequest{Code: code, RedirectURI: redirectURI, State: "state-123456", Nonce: "n-1", CodeVerifier: "v-1"})
+	if _, err := p.GetUserInfo(ctx, token); err == nil {
+		t.Fatal("iss 与 tid 不一致应被拒绝")
+	}
+}
+
+func oidcTestChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
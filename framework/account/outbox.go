@@ -24,6 +24,30 @@ const (
 	EventUserLocked        = "account.user.locked"
 	EventRoleAssigned      = "account.role.assigned"
 	EventPermissionChanged = "account.permission.changed"
+
+	EventImpersonationStarted = "account.impersonation.started"
+	EventImpersonationEnded   = "account.impersonation.ended"
+
+	EventInvitationCreated  = "account.invitation.created"
+	EventInvitationAccepted = "account.invitation.accepted"
+	EventUsersImported      = "account.user.imported"
+	EventDataExportReady    = "account.user.data_export_ready"
+	EventNewDeviceLogin     = "account.user.new_device_login"
+
+	EventSCIMUserCreated  = "account.scim.user.created"
+	EventSCIMUserUpdated  = "account.scim.user.updated"
+	EventSCIMUserDeleted  = "account.scim.user.deleted"
+	EventSCIMGroupCreated = "account.scim.group.created"
+	EventSCIMGroupUpdated = "account.scim.group.updated"
+	EventSCIMGroupDeleted = "account.scim.group.deleted"
+
+	EventLDAPUserProvisioned = "account.ldap.user.provisioned"
+	EventLDAPSyncCompleted   = "account.ldap.sync.completed"
+
+	EventTenantCreated   = "account.tenant.created"
+	EventTenantSuspended = "account.tenant.suspended"
+	EventTenantResumed   = "account.tenant.resumed"
+	EventTenantDeleted   = "account.tenant.deleted"
 )
 
 const (
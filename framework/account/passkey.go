@@ -2,11 +2,17 @@ package account
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/xxzhwl/gaia"
+	"github.com/xxzhwl/gaia/errwrap"
+	"gorm.io/gorm"
 )
 
 // PasskeyConfig WebAuthn 信赖方配置。
@@ -15,30 +21,58 @@ type PasskeyConfig struct {
 	RPDisplayName string
 	// RPID 信赖方 ID（域名）。
 	RPID string
-	// RPOrigin 信赖方来源 URL。
+	// RPOrigin 信赖方来源 URL，多个来源用逗号分隔（如 Web 与 App 内嵌页）。
 	RPOrigin string
 	// Timeout 认证/注册超时（毫秒）。
 	Timeout int
+	// Attestation 注册时的 attestation 偏好：none / indirect / direct。
+	Attestation string
+	// AuthenticatorNames 额外的 AAGUID → 认证器名称映射，覆盖内置表。
+	AuthenticatorNames map[string]string
 }
 
 // PasskeyService WebAuthn 通行密钥服务。
+//
+// 注册时校验 clientDataJSON、rpIdHash、用户验证标志与 attestation 语句（none / packed / fido-u2f），
+// 保存 COSE 公钥与 AAGUID；认证时校验断言签名与签名计数器，计数器回退视为凭证被克隆并停用该凭证。
+// 支持可发现凭证（discoverable credential）：StartPasskeyLogin 下发空 allowCredentials 的挑战，
+// LoginWithPasskey 通过断言中的 userHandle 定位用户并签发会话。
 type PasskeyService struct {
 	m *Manager
 }
 
+// PasskeyChallenge 一次性 WebAuthn 挑战，消费即删除。
+type PasskeyChallenge struct {
+	Challenge string    `gorm:"size:64;primaryKey"`
+	TenantID  string    `gorm:"size:64;not null"`
+	UserID    string    `gorm:"size:36"` // 可发现凭证登录时为空
+	Purpose   string    `gorm:"size:16;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+func (PasskeyChallenge) TableName() string { return "acct_passkey_challenges" }
+
 // PasskeyRegistration 注册请求的挑战数据。
 type PasskeyRegistration struct {
-	Challenge       string `json:"challenge"`
-	RPID            string `json:"rp_id"`
-	RPName          string `json:"rp_name"`
-	UserID          string `json:"user_id"`
+	Challenge string `json:"challenge"`
+	RPID      string `json:"rp_id"`
+	RPName    string `json:"rp_name"`
+	UserID    string `json:"user_id"`
+	// UserHandle WebAuthn user.id（base64url），登录断言中的 userHandle 与之相同。
+	UserHandle      string `json:"user_handle"`
 	UserName        string `json:"user_name"`
 	UserDisplayName string `json:"user_display_name"`
 	Timeout         int    `json:"timeout"`
 	// Attestation  attestation 偏好。
-	Attestation string              `json:"attestation"`
+	Attestation string `json:"attestation"`
 	// CredProtect 凭证保护策略。
-	CredProtect string              `json:"cred_protect"`
+	CredProtect string `json:"cred_protect"`
+	// ResidentKey 要求创建可发现凭证，以支持无用户名登录。
+	ResidentKey      string `json:"resident_key"`
+	UserVerification string `json:"user_verification"`
+	// PubKeyCredParams 支持的 COSE 算法（ES256 / EdDSA / RS256）。
+	PubKeyCredParams []int64 `json:"pub_key_cred_params"`
 	// ExcludeCredentials 已注册凭证列表（防止重复注册）。
 	ExcludeCredentials []PasskeyDescriptor `json:"exclude_credentials,omitempty"`
 }
@@ -50,7 +84,7 @@ type PasskeyDescriptor struct {
 	Transports []string `json:"transports,omitempty"`
 }
 
-// PasskeyAuthentication 认证请求的挑战数据。
+// PasskeyAuthentication 认证请求的挑战数据。AllowCredentials 为空表示由用户在认证器中选择可发现凭证。
 type PasskeyAuthentication struct {
 	Challenge        string              `json:"challenge"`
 	RPID             string              `json:"rp_id"`
@@ -59,7 +93,7 @@ type PasskeyAuthentication struct {
 	UserVerification string              `json:"user_verification"`
 }
 
-// PasskeyRegistrationResponse 客户端返回的注册结果。
+// PasskeyRegistrationResponse 客户端返回的注册结果，二进制字段均为 base64url。
 type PasskeyRegistrationResponse struct {
 	ID                string `json:"id"`
 	RawID             string `json:"raw_id"`
@@ -70,7 +104,7 @@ type PasskeyRegistrationResponse struct {
 	DeviceName        string `json:"device_name,omitempty"`
 }
 
-// PasskeyAuthenticationResponse 客户端返回的认证结果。
+// PasskeyAuthenticationResponse 客户端返回的认证结果，二进制字段均为 base64url。
 type PasskeyAuthenticationResponse struct {
 	ID                string `json:"id"`
 	RawID             string `json:"raw_id"`
@@ -81,142 +115,188 @@ type PasskeyAuthenticationResponse struct {
 	UserHandle        string `json:"user_handle,omitempty"`
 }
 
+// PasskeyLoginRequest 使用可发现凭证登录的参数。
+type PasskeyLoginRequest struct {
+	TenantID   string
+	Credential PasskeyAuthenticationResponse
+	DeviceID   string
+	IP         string
+	UserAgent  string
+}
+
+// PasskeyCredentialInfo 凭证列表项，附带认证器型号信息。
+type PasskeyCredentialInfo struct {
+	ID                string     `json:"id"`
+	CredentialID      string     `json:"credential_id"`
+	DeviceName        string     `json:"device_name"`
+	AAGUID            string     `json:"aaguid,omitempty"`
+	AuthenticatorName string     `json:"authenticator_name,omitempty"`
+	AttestationType   string     `json:"attestation_type"`
+	Transports        []string   `json:"transports,omitempty"`
+	BackupEligible    bool       `json:"backup_eligible"`
+	BackupState       bool       `json:"backup_state"`
+	SignCount         int64      `json:"sign_count"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
 const (
 	passkeyChallengeLen = 32
 	passkeyChallengeTTL = 5 * time.Minute
-)
 
-var (
-	passkeyChallenges = NewMemoryStore[PasskeyChallengeData]()
+	passkeyPurposeRegister = "register"
+	passkeyPurposeAuth     = "authenticate"
+	passkeyPurposeLogin    = "login"
 )
 
-type PasskeyChallengeData struct {
-	Challenge string
-	UserID    string
-	TenantID  string
-	ExpiresAt time.Time
-}
-
-// NewMemoryStore 创建内存存储。
-func NewMemoryStore[T any]() *MemoryStore[T] {
-	return &MemoryStore[T]{data: make(map[string]T)}
-}
-
-// MemoryStore 泛型内存存储。
-type MemoryStore[T any] struct {
-	data map[string]T
+// passkeyAuthenticators 常见认证器的 AAGUID（来自社区维护的 passkey-authenticator-aaguids 列表）。
+var passkeyAuthenticators = map[string]string{
+	"fbfc3007-154e-4ecc-8c0b-6e020557d7bd": "iCloud Keychain",
+	"dd4ec289-e01d-41c9-bb89-70fa845d4bf2": "iCloud Keychain (Managed)",
+	"ea9b8d66-4d01-1d21-3ce4-b6b48cb575d4": "Google Password Manager",
+	"adce0002-35bc-c60a-648b-0b25f1f05503": "Chrome on Mac",
+	"08987058-cadc-4b81-b6e1-30de50dcbe96": "Windows Hello",
+	"9ddd1817-af5a-4672-a2b9-3e3dd95000a9": "Windows Hello",
+	"6028b017-b1d4-4c02-b4b3-afcdafc96bb2": "Windows Hello",
+	"53414d53-554e-4700-0000-000000000000": "Samsung Pass",
+	"bada5566-a7aa-401f-bd96-45619a55120d": "1Password",
+	"d548826e-79b4-db40-a3d8-11116f7e8349": "Bitwarden",
+	"531126d6-e717-415c-9320-3d9aa6981239": "Dashlane",
+	"cb69481e-8ff7-4039-93ec-0a2729a154a8": "YubiKey 5 Series",
+	"ee882879-721c-4913-9775-3dfcce97072a": "YubiKey 5 Series",
+	"fa2b99dc-9e39-4257-8f92-4a30d23c4118": "YubiKey 5 Series with NFC",
+	"2fc0579f-8113-47ea-b116-bb5a8db9202a": "YubiKey 5 Series with NFC",
 }
 
-func (s *MemoryStore[T]) Set(key string, val T)      { s.data[key] = val }
-func (s *MemoryStore[T]) Get(key string) (T, bool)    { v, ok := s.data[key]; return v, ok }
-func (s *MemoryStore[T]) Del(key string)              { delete(s.data, key) }
-
 // StartPasskeyRegistration 开始 WebAuthn 注册流程，生成挑战。
 func (s *PasskeyService) StartPasskeyRegistration(ctx context.Context, userID, tenantID, userName string) (*PasskeyRegistration, error) {
-	challenge, err := generatePasskeyChallenge()
+	tenantID = s.m.tenantID(tenantID)
+	challenge, err := s.newChallenge(ctx, tenantID, userID, passkeyPurposeRegister)
 	if err != nil {
 		return nil, err
 	}
 
-	verificationKey := "passkey_reg:" + userID
-	passkeyChallenges.Set(verificationKey, PasskeyChallengeData{
-		Challenge: challenge,
-		UserID:    userID,
-		TenantID:  s.m.tenantID(tenantID),
-		ExpiresAt: time.Now().Add(passkeyChallengeTTL),
-	})
-
 	// Gather existing credentials for exclusion
 	var existing []PasskeyCredential
 	s.m.db.WithContext(ctx).Where("user_id = ? AND enabled = ?", userID, true).Find(&existing)
 	excludeCreds := make([]PasskeyDescriptor, len(existing))
 	for i, cred := range existing {
-		excludeCreds[i] = PasskeyDescriptor{
-			Type: "public-key",
-			ID:   cred.CredentialID,
-		}
+		excludeCreds[i] = passkeyDescriptor(cred)
 	}
 
 	cfg := s.passkeyConfig()
 	return &PasskeyRegistration{
-		Challenge:           challenge,
-		RPID:                cfg.RPID,
-		RPName:              cfg.RPDisplayName,
-		UserID:              userID,
-		UserName:            userName,
-		UserDisplayName:     userName,
-		Timeout:             cfg.Timeout,
-		Attestation:         "none",
-		CredProtect:         "userVerificationRequired",
-		ExcludeCredentials:  excludeCreds,
+		Challenge:          challenge,
+		RPID:               cfg.RPID,
+		RPName:             cfg.RPDisplayName,
+		UserID:             userID,
+		UserHandle:         base64.RawURLEncoding.EncodeToString([]byte(userID)),
+		UserName:           userName,
+		UserDisplayName:    userName,
+		Timeout:            cfg.Timeout,
+		Attestation:        cfg.Attestation,
+		CredProtect:        "userVerificationRequired",
+		ResidentKey:        "required",
+		UserVerification:   "required",
+		PubKeyCredParams:   []int64{coseAlgES256, coseAlgEdDSA, coseAlgRS256},
+		ExcludeCredentials: excludeCreds,
 	}, nil
 }
 
-// CompletePasskeyRegistration 完成 WebAuthn 注册，验证并存储凭证。
+// CompletePasskeyRegistration 完成 WebAuthn 注册：校验客户端数据与 attestation 语句后保存凭证公钥。
 func (s *PasskeyService) CompletePasskeyRegistration(ctx context.Context, userID string, resp PasskeyRegistrationResponse) (*PasskeyCredential, error) {
-	verificationKey := "passkey_reg:" + userID
-	data, ok := passkeyChallenges.Get(verificationKey)
-	if !ok {
-		return nil, accountError(ErrInvalidArgument, "未找到注册挑战，请重新开始")
-	}
-	if time.Now().After(data.ExpiresAt) {
-		passkeyChallenges.Del(verificationKey)
-		return nil, accountError(ErrExpiredToken, "注册挑战已过期")
-	}
-	passkeyChallenges.Del(verificationKey)
-
 	// Validate the response type
 	if resp.Type != "public-key" {
 		return nil, accountError(ErrInvalidArgument, "不支持的凭证类型")
 	}
+	cfg := s.passkeyConfig()
+	clientDataRaw, err := decodeWebAuthnBase64(resp.ClientDataJSON)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "client_data_json 编码无效")
+	}
+	clientData, err := parseWebAuthnClientData(clientDataRaw, "webauthn.create", s.origins(cfg))
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	data, err := s.takeChallenge(ctx, clientData.Challenge, passkeyPurposeRegister)
+	if err != nil {
+		return nil, err
+	}
+	if data.UserID != userID {
+		return nil, accountError(ErrInvalidArgument, "注册挑战与当前用户不匹配")
+	}
 
 	var user User
 	if err := s.m.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
 		return nil, accountError(ErrInvalidArgument, "用户不存在")
 	}
 
+	attObj, err := decodeWebAuthnBase64(resp.AttestationObject)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "attestation_object 编码无效")
+	}
+	clientDataHash := sha256.Sum256(clientDataRaw)
+	att, err := verifyAttestationObject(attObj, clientDataHash[:])
+	if err != nil {
+		s.m.audit(ctx, data.TenantID, userID, "passkey_registered", "failed", err.Error(), "", "")
+		return nil, accountError(ErrInvalidCredential, "attestation 校验失败: "+err.Error())
+	}
+	if err := att.AuthData.verifyRP(cfg.RPID, true); err != nil {
+		return nil, accountError(ErrInvalidCredential, err.Error())
+	}
+	credentialID := base64.RawURLEncoding.EncodeToString(att.AuthData.CredentialID)
+	if rawID, err := decodeWebAuthnBase64(credentialRawID(resp.RawID, resp.ID)); err != nil ||
+		base64.RawURLEncoding.EncodeToString(rawID) != credentialID {
+		return nil, accountError(ErrInvalidArgument, "凭证 ID 与认证器数据不一致")
+	}
+
 	cred := &PasskeyCredential{
-		ID:           newID(),
-		TenantID:     data.TenantID,
-		UserID:       userID,
-		CredentialID: resp.ID,
-		PublicKey:    resp.AttestationObject,
-		CredType:     resp.Type,
-		DeviceName:   resp.DeviceName,
-		Transports:   resp.Transports,
-		SignCount:    0,
-		Enabled:      true,
+		ID:              newID(),
+		TenantID:        data.TenantID,
+		UserID:          userID,
+		CredentialID:    credentialID,
+		PublicKey:       base64.RawURLEncoding.EncodeToString(att.AuthData.PublicKey),
+		CredType:        resp.Type,
+		AAGUID:          formatAAGUID(att.AuthData.AAGUID),
+		DeviceName:      truncateString(resp.DeviceName, 128),
+		Transports:      truncateString(resp.Transports, 255),
+		SignCount:       int64(att.AuthData.SignCount),
+		AttestationType: att.Type,
+		BackupEligible:  att.AuthData.has(authFlagBackupEligible),
+		BackupState:     att.AuthData.has(authFlagBackupState),
+		Enabled:         true,
+	}
+	if cred.DeviceName == "" {
+		cred.DeviceName = s.authenticatorName(cfg, cred.AAGUID)
 	}
 	if err := s.m.db.WithContext(ctx).Create(cred).Error; err != nil {
+		if isDuplicateKeyErr(err) {
+			return nil, accountError(ErrIdentifierExists, "该通行密钥已注册")
+		}
 		return nil, err
 	}
 
-	s.m.audit(ctx, data.TenantID, userID, "passkey_registered", "success", "passkey registered", "", "")
+	s.m.audit(ctx, data.TenantID, userID, "passkey_registered", "success",
+		fmt.Sprintf("fmt=%s attestation=%s aaguid=%s", att.Format, att.Type, cred.AAGUID), "", "")
 	return cred, nil
 }
 
-// StartPasskeyAuthentication 开始 WebAuthn 认证流程，生成挑战。
+// StartPasskeyAuthentication 为已知用户生成认证挑战（如二次验证），AllowCredentials 列出该用户的凭证。
 func (s *PasskeyService) StartPasskeyAuthentication(ctx context.Context, userID string) (*PasskeyAuthentication, error) {
-	challenge, err := generatePasskeyChallenge()
+	var user User
+	if err := s.m.db.WithContext(ctx).Select("id", "tenant_id").Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, accountError(ErrInvalidArgument, "用户不存在")
+	}
+	challenge, err := s.newChallenge(ctx, user.TenantID, userID, passkeyPurposeAuth)
 	if err != nil {
 		return nil, err
 	}
 
-	verificationKey := "passkey_auth:" + userID
-	passkeyChallenges.Set(verificationKey, PasskeyChallengeData{
-		Challenge: challenge,
-		UserID:    userID,
-		ExpiresAt: time.Now().Add(passkeyChallengeTTL),
-	})
-
 	var existing []PasskeyCredential
 	s.m.db.WithContext(ctx).Where("user_id = ? AND enabled = ?", userID, true).Find(&existing)
 	allowCreds := make([]PasskeyDescriptor, len(existing))
 	for i, cred := range existing {
-		allowCreds[i] = PasskeyDescriptor{
-			Type: "public-key",
-			ID:   cred.CredentialID,
-		}
+		allowCreds[i] = passkeyDescriptor(cred)
 	}
 
 	cfg := s.passkeyConfig()
@@ -231,45 +311,253 @@ func (s *PasskeyService) StartPasskeyAuthentication(ctx context.Context, userID
 
 // CompletePasskeyAuthentication 完成 WebAuthn 认证，验证签名并更新计数器。
 func (s *PasskeyService) CompletePasskeyAuthentication(ctx context.Context, userID string, resp PasskeyAuthenticationResponse) (*PasskeyCredential, error) {
-	verificationKey := "passkey_auth:" + userID
-	data, ok := passkeyChallenges.Get(verificationKey)
-	if !ok {
-		return nil, accountError(ErrInvalidArgument, "未找到认证挑战，请重新开始")
+	cred, err := s.verifyAssertion(ctx, passkeyPurposeAuth, "", resp)
+	if err != nil {
+		return nil, err
+	}
+	if cred.UserID != userID {
+		return nil, accountError(ErrInvalidCredential, "凭证不属于该用户")
+	}
+	s.m.audit(ctx, cred.TenantID, userID, "passkey_authenticated", "success", "passkey authentication", "", "")
+	return cred, nil
+}
+
+// StartPasskeyLogin 生成可发现凭证登录的挑战：不指定用户，allowCredentials 为空，由认证器列出本站凭证。
+func (s *PasskeyService) StartPasskeyLogin(ctx context.Context, tenantID string) (*PasskeyAuthentication, error) {
+	challenge, err := s.newChallenge(ctx, s.m.tenantID(tenantID), "", passkeyPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+	cfg := s.passkeyConfig()
+	return &PasskeyAuthentication{
+		Challenge:        challenge,
+		RPID:             cfg.RPID,
+		Timeout:          cfg.Timeout,
+		UserVerification: "required",
+	}, nil
+}
+
+// LoginWithPasskey 使用可发现凭证登录：按 userHandle 定位用户，校验断言后签发会话。
+// 断言要求用户验证（UV），通行密钥本身即满足多因素要求，不再要求 TOTP。
+func (s *PasskeyService) LoginWithPasskey(ctx context.Context, req PasskeyLoginRequest) (*AuthResult, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.passkey.login")
+	defer span.End()
+	tenantID := s.m.tenantID(req.TenantID)
+	if err := s.m.tenantSvc.checkLogin(ctx, tenantID, LoginMethodPasskey); err != nil {
+		return nil, err
+	}
+
+	riskResult, err := s.m.risk.Assess(ctx, tenantID, "", req.IP)
+	if err != nil {
+		return nil, err
+	}
+	if riskResult.Decision == RiskBlock {
+		return nil, accountError(ErrRateLimited, riskResult.Reason)
+	}
+	if req.Credential.UserHandle == "" {
+		return nil, accountError(ErrInvalidArgument, "缺少 user_handle，认证器未返回可发现凭证")
+	}
+	cred, err := s.verifyAssertion(ctx, passkeyPurposeLogin, tenantID, req.Credential)
+	if err != nil {
+		if errwrap.GetCode(err) == ErrInvalidCredential {
+			s.m.risk.RecordFailure(ctx, tenantID, "", req.IP)
+		}
+		s.m.audit(ctx, tenantID, "", "passkey_login", "failed", err.Error(), req.IP, req.UserAgent)
+		return nil, err
+	}
+
+	var (
+		user   User
+		result *AuthResult
+	)
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND tenant_id = ?", cred.UserID, tenantID).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return accountError(ErrInvalidCredential, "凭证对应的用户不存在")
+			}
+			return err
+		}
+		if user.Status == UserStatusDisabled || user.Status == UserStatusDeleted || user.Status == UserStatusPending {
+			return accountError(ErrPermissionDenied, "账号不可用")
+		}
+		if user.Status == UserStatusLocked {
+			if user.LockedUntil == nil || time.Now().Before(*user.LockedUntil) {
+				return accountError(ErrAccountLocked, "账号已锁定")
+			}
+			if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+				"status":       UserStatusNormal,
+				"locked_until": nil,
+			}).Error; err != nil {
+				return err
+			}
+			user.Status = UserStatusNormal
+			user.LockedUntil = nil
+		}
+		if s.m.phoneBindingRequired(&user) {
+			result = &AuthResult{
+				User:                 user.toInfo(nil, nil),
+				PhoneBindingRequired: true,
+				TokenType:            "Bearer",
+			}
+			return nil
+		}
+		roles, err := s.m.auth.loadRoleCodes(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if result, err = s.m.auth.issueTokens(ctx, tx, &user, roles, []string{AMRPasskey}, req.DeviceID, req.IP, req.UserAgent, "", "", ""); err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := emitOutbox(tx, EventUserLoggedIn, user.ID, map[string]any{
+			"user_id":      user.ID,
+			"tenant_id":    tenantID,
+			"method":       "passkey",
+			"logged_in_at": now,
+		}); err != nil {
+			gaia.WarnF("[account] emit user logged in event failed: %v", err)
+		}
+		return tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"last_login_at": now,
+			"last_login_ip": req.IP,
+		}).Error
+	})
+	if err != nil {
+		s.m.audit(ctx, tenantID, cred.UserID, "passkey_login", "failed", err.Error(), req.IP, req.UserAgent)
+		return nil, err
 	}
-	if time.Now().After(data.ExpiresAt) {
-		passkeyChallenges.Del(verificationKey)
-		return nil, accountError(ErrExpiredToken, "认证挑战已过期")
+	s.m.risk.RecordSuccess(ctx, tenantID, "", req.IP)
+	if !result.PhoneBindingRequired {
+		s.m.audit(ctx, tenantID, user.ID, "passkey_login", "success", "credential="+truncateString(cred.CredentialID, 64), req.IP, req.UserAgent)
+		_, _ = s.m.authorizer.GetEffectivePermissions(ctx, user.ID)
 	}
-	passkeyChallenges.Del(verificationKey)
+	return result, nil
+}
 
+// verifyAssertion 校验认证断言：消费挑战、定位凭证、校验 clientData / rpIdHash / UV / 签名与签名计数器。
+// tenantID 为空时使用挑战中的租户。
+func (s *PasskeyService) verifyAssertion(ctx context.Context, purpose, tenantID string, resp PasskeyAuthenticationResponse) (*PasskeyCredential, error) {
 	if resp.Type != "public-key" {
 		return nil, accountError(ErrInvalidArgument, "不支持的凭证类型")
 	}
+	cfg := s.passkeyConfig()
+	clientDataRaw, err := decodeWebAuthnBase64(resp.ClientDataJSON)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "client_data_json 编码无效")
+	}
+	clientData, err := parseWebAuthnClientData(clientDataRaw, "webauthn.get", s.origins(cfg))
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	challenge, err := s.takeChallenge(ctx, clientData.Challenge, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if tenantID != "" && challenge.TenantID != tenantID {
+		return nil, accountError(ErrInvalidCredential, "认证挑战无效")
+	}
 
+	rawID, err := decodeWebAuthnBase64(credentialRawID(resp.RawID, resp.ID))
+	if err != nil || len(rawID) == 0 {
+		return nil, accountError(ErrInvalidArgument, "凭证 ID 编码无效")
+	}
 	var cred PasskeyCredential
-	if err := s.m.db.WithContext(ctx).Where("user_id = ? AND credential_id = ? AND enabled = ?", userID, resp.ID, true).First(&cred).Error; err != nil {
+	if err := s.m.db.WithContext(ctx).Where("tenant_id = ? AND credential_id = ? AND enabled = ?",
+		challenge.TenantID, base64.RawURLEncoding.EncodeToString(rawID), true).First(&cred).Error; err != nil {
 		return nil, accountError(ErrInvalidCredential, "凭证不存在或已禁用")
 	}
+	if challenge.UserID != "" && challenge.UserID != cred.UserID {
+		return nil, accountError(ErrInvalidCredential, "凭证不属于该用户")
+	}
+	if resp.UserHandle != "" {
+		handle, err := decodeWebAuthnBase64(resp.UserHandle)
+		if err != nil || string(handle) != cred.UserID {
+			return nil, accountError(ErrInvalidCredential, "userHandle 与凭证不匹配")
+		}
+	}
 
+	authDataRaw, err := decodeWebAuthnBase64(resp.AuthenticatorData)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "authenticator_data 编码无效")
+	}
+	sig, err := decodeWebAuthnBase64(resp.Signature)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "signature 编码无效")
+	}
+	authData, err := parseAuthenticatorData(authDataRaw)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	if err := authData.verifyRP(cfg.RPID, true); err != nil {
+		return nil, accountError(ErrInvalidCredential, err.Error())
+	}
+	pub, alg, err := passkeyPublicKey(cred.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("passkey credential %s: %w", cred.ID, err)
+	}
+	clientDataHash := sha256.Sum256(clientDataRaw)
+	if err := verifyWebAuthnSignature(pub, alg, append(append([]byte(nil), authDataRaw...), clientDataHash[:]...), sig); err != nil {
+		return nil, accountError(ErrInvalidCredential, "通行密钥签名无效")
+	}
+
+	// 签名计数器：任一方非零时必须严格递增，否则说明凭证可能被克隆
+	newCount := int64(authData.SignCount)
+	if (newCount != 0 || cred.SignCount != 0) && newCount <= cred.SignCount {
+		s.m.db.WithContext(ctx).Model(&PasskeyCredential{}).Where("id = ?", cred.ID).Update("enabled", false)
+		s.m.audit(ctx, cred.TenantID, cred.UserID, "passkey_clone_detected", "failed",
+			fmt.Sprintf("credential=%s stored=%d received=%d", truncateString(cred.CredentialID, 64), cred.SignCount, newCount), "", "")
+		gaia.WarnF("[account] passkey %s sign counter went backwards (%d -> %d), credential disabled", cred.ID, cred.SignCount, newCount)
+		return nil, accountError(ErrInvalidCredential, "通行密钥疑似被复制，已停用，请使用其他方式登录")
+	}
 	now := time.Now()
-	cred.SignCount++
+	res := s.m.db.WithContext(ctx).Model(&PasskeyCredential{}).
+		Where("id = ? AND sign_count = ?", cred.ID, cred.SignCount).
+		Updates(map[string]any{
+			"sign_count":   newCount,
+			"backup_state": authData.has(authFlagBackupState),
+			"last_used_at": now,
+		})
+	if res.Error != nil {
+		recordDBError(ctx)
+		return nil, res.Error
+	}
+	if res.RowsAffected != 1 {
+		return nil, accountError(ErrInvalidCredential, "通行密钥正在被并发使用，请重试")
+	}
+	cred.SignCount = newCount
+	cred.BackupState = authData.has(authFlagBackupState)
 	cred.LastUsedAt = &now
-	s.m.db.WithContext(ctx).Model(&cred).Updates(map[string]interface{}{
-		"sign_count":   cred.SignCount,
-		"last_used_at": now,
-	})
-
-	s.m.audit(ctx, cred.TenantID, userID, "passkey_authenticated", "success", "passkey authentication", "", "")
 	return &cred, nil
 }
 
 // ListCredentials 列出用户的所有通行密钥凭证。
-func (s *PasskeyService) ListCredentials(ctx context.Context, userID string) ([]PasskeyCredential, error) {
+func (s *PasskeyService) ListCredentials(ctx context.Context, userID string) ([]PasskeyCredentialInfo, error) {
 	var creds []PasskeyCredential
 	if err := s.m.db.WithContext(ctx).Where("user_id = ? AND enabled = ?", userID, true).Order("created_at DESC").Find(&creds).Error; err != nil {
 		return nil, err
 	}
-	return creds, nil
+	cfg := s.passkeyConfig()
+	out := make([]PasskeyCredentialInfo, 0, len(creds))
+	for _, c := range creds {
+		info := PasskeyCredentialInfo{
+			ID:                c.ID,
+			CredentialID:      c.CredentialID,
+			DeviceName:        c.DeviceName,
+			AAGUID:            c.AAGUID,
+			AuthenticatorName: s.authenticatorName(cfg, c.AAGUID),
+			AttestationType:   c.AttestationType,
+			BackupEligible:    c.BackupEligible,
+			BackupState:       c.BackupState,
+			SignCount:         c.SignCount,
+			LastUsedAt:        c.LastUsedAt,
+			CreatedAt:         c.CreatedAt,
+		}
+		if c.Transports != "" {
+			info.Transports = strings.Split(c.Transports, ",")
+		}
+		out = append(out, info)
+	}
+	return out, nil
 }
 
 // DeleteCredential 删除用户的通行密钥凭证。
@@ -283,11 +571,114 @@ func (s *PasskeyService) DeleteCredential(ctx context.Context, userID, credentia
 
 func (s *PasskeyService) passkeyConfig() PasskeyConfig {
 	return PasskeyConfig{
-		RPDisplayName: gaia.GetSafeConfStringWithDefault("Account.Passkey.RPDisplayName", "Gaia Account"),
-		RPID:          gaia.GetSafeConfStringWithDefault("Account.Passkey.RPID", "localhost"),
-		RPOrigin:      gaia.GetSafeConfStringWithDefault("Account.Passkey.RPOrigin", "http://localhost:8080"),
-		Timeout:       int(gaia.GetSafeConfInt64WithDefault("Account.Passkey.Timeout", 60000)),
+		RPDisplayName:      gaia.GetSafeConfStringWithDefault("Account.Passkey.RPDisplayName", "Gaia Account"),
+		RPID:               gaia.GetSafeConfStringWithDefault("Account.Passkey.RPID", "localhost"),
+		RPOrigin:           gaia.GetSafeConfStringWithDefault("Account.Passkey.RPOrigin", "http://localhost:8080"),
+		Timeout:            int(gaia.GetSafeConfInt64WithDefault("Account.Passkey.Timeout", 60000)),
+		Attestation:        gaia.GetSafeConfStringWithDefault("Account.Passkey.Attestation", "none"),
+		AuthenticatorNames: gaia.GetSafeConfMapT[string]("Account.Passkey.AuthenticatorNames"),
+	}
+}
+
+func (s *PasskeyService) origins(cfg PasskeyConfig) []string {
+	var out []string
+	for _, o := range strings.Split(cfg.RPOrigin, ",") {
+		if o = strings.TrimSuffix(strings.TrimSpace(o), "/"); o != "" {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+func (s *PasskeyService) authenticatorName(cfg PasskeyConfig, aaguid string) string {
+	if aaguid == "" {
+		return ""
+	}
+	if name, ok := cfg.AuthenticatorNames[aaguid]; ok {
+		return name
+	}
+	return passkeyAuthenticators[aaguid]
+}
+
+// newChallenge 生成并保存一次性挑战。
+func (s *PasskeyService) newChallenge(ctx context.Context, tenantID, userID, purpose string) (string, error) {
+	challenge, err := generatePasskeyChallenge()
+	if err != nil {
+		return "", err
+	}
+	if err := s.m.db.WithContext(ctx).Create(&PasskeyChallenge{
+		Challenge: challenge,
+		TenantID:  tenantID,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(passkeyChallengeTTL),
+	}).Error; err != nil {
+		recordDBError(ctx)
+		return "", fmt.Errorf("create passkey challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// takeChallenge 消费 clientDataJSON 中的挑战，校验用途与有效期。
+func (s *PasskeyService) takeChallenge(ctx context.Context, challenge, purpose string) (*PasskeyChallenge, error) {
+	var row PasskeyChallenge
+	if err := s.m.db.WithContext(ctx).Where("challenge = ?", challenge).First(&row).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, accountError(ErrInvalidArgument, "未找到挑战，请重新开始")
+		}
+		recordDBError(ctx)
+		return nil, err
+	}
+	res := s.m.db.WithContext(ctx).Where("challenge = ?", challenge).Delete(&PasskeyChallenge{})
+	if res.Error != nil {
+		recordDBError(ctx)
+		return nil, res.Error
+	}
+	if res.RowsAffected != 1 || row.Purpose != purpose {
+		return nil, accountError(ErrInvalidArgument, "未找到挑战，请重新开始")
+	}
+	if time.Now().After(row.ExpiresAt) {
+		return nil, accountError(ErrExpiredToken, "挑战已过期")
 	}
+	return &row, nil
+}
+
+// passkeyPublicKey 解析保存的凭证公钥。早期版本保存的是整个 attestationObject，这里一并兼容。
+func passkeyPublicKey(stored string) (crypto.PublicKey, int64, error) {
+	raw, err := decodeWebAuthnBase64(stored)
+	if err != nil {
+		return nil, 0, err
+	}
+	if pub, alg, err := parseCOSEKey(raw); err == nil {
+		return pub, alg, nil
+	}
+	v, _, err := cborDecode(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	obj, _ := v.(map[any]any)
+	authDataRaw, _ := obj["authData"].([]byte)
+	ad, err := parseAuthenticatorData(authDataRaw)
+	if err != nil || ad.PublicKey == nil {
+		return nil, 0, fmt.Errorf("stored public key is neither a COSE key nor an attestation object")
+	}
+	return parseCOSEKey(ad.PublicKey)
+}
+
+// credentialRawID 优先使用 rawId，部分客户端只回传 id（同为 base64url）。
+func credentialRawID(rawID, id string) string {
+	if rawID != "" {
+		return rawID
+	}
+	return id
+}
+
+func passkeyDescriptor(cred PasskeyCredential) PasskeyDescriptor {
+	d := PasskeyDescriptor{Type: "public-key", ID: cred.CredentialID}
+	if cred.Transports != "" {
+		d.Transports = strings.Split(cred.Transports, ",")
+	}
+	return d
 }
 
 func generatePasskeyChallenge() (string, error) {
@@ -297,4 +688,3 @@ func generatePasskeyChallenge() (string, error) {
 	}
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
-
@@ -0,0 +1,444 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xxzhwl/gaia/errwrap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const passkeyTestOrigin = "http://localhost:8080"
+
+// cborPair 测试用 CBOR 映射项，按给定顺序编码。
+type cborPair struct {
+	k, v any
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	default:
+		b := []byte{major<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		return b
+	}
+}
+
+// cborEncode 只覆盖 WebAuthn 用到的类型：整数、字节串、文本、数组与映射。
+func cborEncode(v any) []byte {
+	switch x := v.(type) {
+	case int:
+		return cborEncode(int64(x))
+	case int64:
+		if x >= 0 {
+			return cborHead(0, uint64(x))
+		}
+		return cborHead(1, uint64(-1-x))
+	case []byte:
+		return append(cborHead(2, uint64(len(x))), x...)
+	case string:
+		return append(cborHead(3, uint64(len(x))), x...)
+	case []any:
+		out := cborHead(4, uint64(len(x)))
+		for _, e := range x {
+			out = append(out, cborEncode(e)...)
+		}
+		return out
+	case []cborPair:
+		out := cborHead(5, uint64(len(x)))
+		for _, p := range x {
+			out = append(out, cborEncode(p.k)...)
+			out = append(out, cborEncode(p.v)...)
+		}
+		return out
+	}
+	panic("cborEncode: unsupported type")
+}
+
+// softAuthenticator 软件实现的 ES256 平台认证器。
+type softAuthenticator struct {
+	key       *ecdsa.PrivateKey
+	credID    []byte
+	aaguid    []byte
+	signCount uint32
+	userID    string
+}
+
+func newSoftAuthenticator(t *testing.T, aaguid string) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &softAuthenticator{key: key, credID: make([]byte, 16), aaguid: make([]byte, 16)}
+	rand.Read(a.credID)
+	if aaguid != "" {
+		b, err := hex.DecodeString(strings.ReplaceAll(aaguid, "-", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.aaguid = b
+	}
+	return a
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return cborEncode([]cborPair{{1, 2}, {3, coseAlgES256}, {-1, 1}, {-2, x}, {-3, y}})
+}
+
+func (a *softAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	h := sha256.Sum256([]byte(rpID))
+	out := append([]byte(nil), h[:]...)
+	if attested {
+		flags |= authFlagAttestedData
+	}
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, a.signCount)
+	if attested {
+		out = append(out, a.aaguid...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credID)))
+		out = append(out, a.credID...)
+		out = append(out, a.coseKey()...)
+	}
+	return out
+}
+
+func (a *softAuthenticator) sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+	h := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func passkeyClientData(typ, challenge, origin string) []byte {
+	b, _ := json.Marshal(map[string]any{"type": typ, "challenge": challenge, "origin": origin})
+	return b
+}
+
+// register 模拟 navigator.credentials.create，format 为 none / packed / fido-u2f。
+func (a *softAuthenticator) register(t *testing.T, reg *PasskeyRegistration, format string) PasskeyRegistrationResponse {
+	t.Helper()
+	a.userID = reg.UserID
+	clientData := passkeyClientData("webauthn.create", reg.Challenge, passkeyTestOrigin)
+	cdHash := sha256.Sum256(clientData)
+	authData := a.authData(reg.RPID, authFlagUserPresent|authFlagUserVerified|authFlagBackupEligible, true)
+	signed := append(append([]byte(nil), authData...), cdHash[:]...)
+
+	var stmt []cborPair
+	switch format {
+	case "packed":
+		stmt = []cborPair{{"alg", coseAlgES256}, {"sig", a.sign(t, a.key, signed)}}
+	case "fido-u2f":
+		certKey, cert := passkeyTestCert(t)
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		a.key.X.FillBytes(x)
+		a.key.Y.FillBytes(y)
+		h := sha256.Sum256([]byte(reg.RPID))
+		var data []byte
+		data = append(data, 0x00)
+		data = append(data, h[:]...)
+		data = append(data, cdHash[:]...)
+		data = append(data, a.credID...)
+		data = append(data, 0x04)
+		data = append(data, x...)
+		data = append(data, y...)
+		stmt = []cborPair{{"sig", a.sign(t, certKey, data)}, {"x5c", []any{cert}}}
+	}
+	if stmt == nil {
+		stmt = []cborPair{}
+	}
+	attObj := cborEncode([]cborPair{{"fmt", format}, {"attStmt", stmt}, {"authData", authData}})
+	id := base64.RawURLEncoding.EncodeToString(a.credID)
+	return PasskeyRegistrationResponse{
+		ID:                id,
+		RawID:             id,
+		Type:              "public-key",
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientData),
+		AttestationObject: base64.RawURLEncoding.EncodeToString(attObj),
+		Transports:        "internal,hybrid",
+	}
+}
+
+// assert 模拟 navigator.credentials.get，每次调用签名计数器加一。
+func (a *softAuthenticator) assert(t *testing.T, challenge, rpID string) PasskeyAuthenticationResponse {
+	t.Helper()
+	a.signCount++
+	clientData := passkeyClientData("webauthn.get", challenge, passkeyTestOrigin)
+	cdHash := sha256.Sum256(clientData)
+	authData := a.authData(rpID, authFlagUserPresent|authFlagUserVerified|authFlagBackupEligible|authFlagBackupState, false)
+	id := base64.RawURLEncoding.EncodeToString(a.credID)
+	return PasskeyAuthenticationResponse{
+		ID:                id,
+		RawID:             id,
+		Type:              "public-key",
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientData),
+		AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+		Signature:         base64.RawURLEncoding.EncodeToString(a.sign(t, a.key, append(authData, cdHash[:]...))),
+		UserHandle:        base64.RawURLEncoding.EncodeToString([]byte(a.userID)),
+	}
+}
+
+func passkeyTestCert(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "U2F Test Attestation"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, der
+}
+
+func newPasskeyTestManager(t *testing.T) (*Manager, *User) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "passkey.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testAuthConfig()
+	cfg.DB = db
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Bootstrap(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	user := &User{ID: newID(), TenantID: "default", Username: "alice", Status: UserStatusNormal}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return m, user
+}
+
+func registerPasskey(t *testing.T, m *Manager, user *User, a *softAuthenticator, format string) *PasskeyCredential {
+	t.Helper()
+	ctx := context.Background()
+	reg, err := m.Passkey().StartPasskeyRegistration(ctx, user.ID, user.TenantID, user.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.ResidentKey != "required" || reg.UserHandle != base64.RawURLEncoding.EncodeToString([]byte(user.ID)) {
+		t.Fatalf("注册选项不正确: %+v", reg)
+	}
+	cred, err := m.Passkey().CompletePasskeyRegistration(ctx, user.ID, a.register(t, reg, format))
+	if err != nil {
+		t.Fatalf("%s 注册失败: %v", format, err)
+	}
+	return cred
+}
+
+func TestPasskeyRegistrationFormats(t *testing.T) {
+	m, user := newPasskeyTestManager(t)
+	cases := []struct {
+		format, aaguid, wantType string
+	}{
+		{"none", "fbfc3007-154e-4ecc-8c0b-6e020557d7bd", PasskeyAttestationNone},
+		{"packed", "bada5566-a7aa-401f-bd96-45619a55120d", PasskeyAttestationSelf},
+		{"fido-u2f", "", PasskeyAttestationBasic},
+	}
+	for _, c := range cases {
+		a := newSoftAuthenticator(t, c.aaguid)
+		cred := registerPasskey(t, m, user, a, c.format)
+		if cred.AttestationType != c.wantType || cred.AAGUID != c.aaguid || !cred.BackupEligible {
+			t.Fatalf("%s: 凭证字段不正确: %+v", c.format, cred)
+		}
+		if cred.CredentialID != base64.RawURLEncoding.EncodeToString(a.credID) {
+			t.Fatalf("%s: credential_id 应为 base64url", c.format)
+		}
+		if _, alg, err := passkeyPublicKey(cred.PublicKey); err != nil || alg != coseAlgES256 {
+			t.Fatalf("%s: 保存的公钥无法解析: %v", c.format, err)
+		}
+	}
+
+	infos, err := m.Passkey().ListCredentials(context.Background(), user.ID)
+	if err != nil || len(infos) != 3 {
+		t.Fatalf("ListCredentials = %d, %v", len(infos), err)
+	}
+	names := map[string]string{}
+	for _, info := range infos {
+		names[info.AAGUID] = info.AuthenticatorName
+		if len(info.Transports) != 2 {
+			t.Fatalf("transports 应拆分为数组: %+v", info.Transports)
+		}
+	}
+	if names["fbfc3007-154e-4ecc-8c0b-6e020557d7bd"] != "iCloud Keychain" || names["bada5566-a7aa-401f-bd96-45619a55120d"] != "1Password" {
+		t.Fatalf("认证器名称不正确: %v", names)
+	}
+}
+
+func TestPasskeyRegistrationRejectsTampering(t *testing.T) {
+	m, user := newPasskeyTestManager(t)
+	ctx := context.Background()
+	a := newSoftAuthenticator(t, "")
+
+	// 来源不在白名单
+	reg, _ := m.Passkey().StartPasskeyRegistration(ctx, user.ID, user.TenantID, user.Username)
+	resp := a.register(t, reg, "packed")
+	resp.ClientDataJSON = base64.RawURLEncoding.EncodeToString(passkeyClientData("webauthn.create", reg.Challenge, "https://evil.example.com"))
+	if _, err := m.Passkey().CompletePasskeyRegistration(ctx, user.ID, resp); err == nil {
+		t.Fatal("非法来源应被拒绝")
+	}
+
+	// 签名覆盖 clientDataJSON，替换挑战后自证明签名失效
+	reg, _ = m.Passkey().StartPasskeyRegistration(ctx, user.ID, user.TenantID, user.Username)
+	other, _ := m.Passkey().StartPasskeyRegistration(ctx, user.ID, user.TenantID, user.Username)
+	resp = a.register(t, reg, "packed")
+	resp.ClientDataJSON = base64.RawURLEncoding.EncodeToString(passkeyClientData("webauthn.create", other.Challenge, passkeyTestOrigin))
+	if _, err := m.Passkey().CompletePasskeyRegistration(ctx, user.ID, resp); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("篡改 clientDataJSON 应导致 attestation 校验失败, got %v", err)
+	}
+
+	// 挑战只能使用一次
+	resp = a.register(t, reg, "none")
+	if _, err := m.Passkey().CompletePasskeyRegistration(ctx, user.ID, resp); err != nil {
+		t.Fatalf("首次使用挑战应成功: %v", err)
+	}
+	if _, err := m.Passkey().CompletePasskeyRegistration(ctx, user.ID, resp); err == nil {
+		t.Fatal("重放挑战应被拒绝")
+	}
+}
+
+func TestPasskeyDiscoverableLogin(t *testing.T) {
+	m, user := newPasskeyTestManager(t)
+	ctx := context.Background()
+	a := newSoftAuthenticator(t, "ea9b8d66-4d01-1d21-3ce4-b6b48cb575d4")
+	registerPasskey(t, m, user, a, "none")
+
+	start, err := m.Passkey().StartPasskeyLogin(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(start.AllowCredentials) != 0 || start.UserVerification != "required" {
+		t.Fatalf("可发现凭证登录不应指定 allowCredentials: %+v", start)
+	}
+	result, err := m.Passkey().LoginWithPasskey(ctx, PasskeyLoginRequest{
+		Credential: a.assert(t, start.Challenge, start.RPID),
+		IP:         "10.0.0.1",
+	})
+	if err != nil {
+		t.Fatalf("通行密钥登录失败: %v", err)
+	}
+	if result.AccessToken == "" || result.MFARequired || result.User.ID != user.ID {
+		t.Fatalf("登录结果不正确: %+v", result)
+	}
+
+	var stored PasskeyCredential
+	m.db.Where("user_id = ?", user.ID).First(&stored)
+	if stored.SignCount != 1 || !stored.BackupState || stored.LastUsedAt == nil {
+		t.Fatalf("断言后凭证状态未更新: %+v", stored)
+	}
+
+	// userHandle 与凭证不一致
+	start, _ = m.Passkey().StartPasskeyLogin(ctx, "")
+	resp := a.assert(t, start.Challenge, start.RPID)
+	resp.UserHandle = base64.RawURLEncoding.EncodeToString([]byte("someone-else"))
+	if _, err := m.Passkey().LoginWithPasskey(ctx, PasskeyLoginRequest{Credential: resp}); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("userHandle 不匹配应被拒绝, got %v", err)
+	}
+
+	// 已知用户的认证挑战不能用于登录
+	auth, _ := m.Passkey().StartPasskeyAuthentication(ctx, user.ID)
+	if _, err := m.Passkey().LoginWithPasskey(ctx, PasskeyLoginRequest{Credential: a.assert(t, auth.Challenge, auth.RPID)}); err == nil {
+		t.Fatal("认证挑战不应用于登录")
+	}
+	auth, _ = m.Passkey().StartPasskeyAuthentication(ctx, user.ID)
+	if _, err := m.Passkey().CompletePasskeyAuthentication(ctx, user.ID, a.assert(t, auth.Challenge, auth.RPID)); err != nil {
+		t.Fatalf("已知用户认证失败: %v", err)
+	}
+}
+
+func TestPasskeyCloneDetection(t *testing.T) {
+	m, user := newPasskeyTestManager(t)
+	ctx := context.Background()
+	a := newSoftAuthenticator(t, "")
+	registerPasskey(t, m, user, a, "packed")
+
+	start, _ := m.Passkey().StartPasskeyLogin(ctx, "")
+	if _, err := m.Passkey().LoginWithPasskey(ctx, PasskeyLoginRequest{Credential: a.assert(t, start.Challenge, start.RPID)}); err != nil {
+		t.Fatal(err)
+	}
+	// 克隆体的计数器落后于服务端记录
+	clone := *a
+	clone.signCount = 0
+	start, _ = m.Passkey().StartPasskeyLogin(ctx, "")
+	if _, err := m.Passkey().LoginWithPasskey(ctx, PasskeyLoginRequest{Credential: clone.assert(t, start.Challenge, start.RPID)}); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("计数器回退应被拒绝, got %v", err)
+	}
+	var stored PasskeyCredential
+	m.db.Where("user_id = ?", user.ID).First(&stored)
+	if stored.Enabled {
+		t.Fatal("疑似克隆的凭证应被停用")
+	}
+	// 原认证器也不能再使用该凭证
+	start, _ = m.Passkey().StartPasskeyLogin(ctx, "")
+	if _, err := m.Passkey().LoginWithPasskey(ctx, PasskeyLoginRequest{Credential: a.assert(t, start.Challenge, start.RPID)}); err == nil {
+		t.Fatal("停用的凭证不应登录成功")
+	}
+	var n int64
+	m.db.Model(&AuditLog{}).Where("event = ?", "passkey_clone_detected").Count(&n)
+	if n != 1 {
+		t.Fatalf("应记录克隆审计, got %d", n)
+	}
+}
+
+func TestCBORDecode(t *testing.T) {
+	raw := cborEncode([]cborPair{{"a", int64(-300)}, {1, []any{[]byte{1, 2}, "x"}}})
+	v, n, err := cborDecode(raw)
+	if err != nil || n != len(raw) {
+		t.Fatalf("decode: %v", err)
+	}
+	m := v.(map[any]any)
+	if m["a"] != int64(-300) {
+		t.Fatalf("负整数解码错误: %v", m["a"])
+	}
+	arr := m[int64(1)].([]any)
+	if !bytes.Equal(arr[0].([]byte), []byte{1, 2}) || arr[1] != "x" {
+		t.Fatalf("数组解码错误: %v", arr)
+	}
+	// 不定长编码与截断数据应被拒绝
+	for _, bad := range [][]byte{{0x5f, 0x41, 0x00, 0xff}, {0x82, 0x01}, {0x58}} {
+		if _, _, err := cborDecode(bad); err == nil {
+			t.Fatalf("应拒绝 % x", bad)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/xxzhwl/gaia"
@@ -12,28 +13,35 @@ import (
 
 // Policy 定义 ABAC 策略，用于基于属性的授权判断。
 type Policy struct {
-	ID         string    `json:"id" gorm:"size:36;primaryKey"`
-	TenantID   string    `json:"tenant_id" gorm:"size:64;not null;uniqueIndex:uniq_acct_policies_code,priority:1"`
-	Code       string    `json:"code" gorm:"size:120;not null;uniqueIndex:uniq_acct_policies_code,priority:2"`
-	Name       string    `json:"name" gorm:"size:120;not null"`
-	Effect     string    `json:"effect" gorm:"size:16;not null;default:allow"` // allow / deny
-	Expression string    `json:"expression" gorm:"type:text;not null"`
-	ResourceType string  `json:"resource_type" gorm:"size:80;not null;default:*"`
-	Action     string    `json:"action" gorm:"size:80;not null;default:*"`
-	Priority   int       `json:"priority" gorm:"not null;default:0"`
-	Version    int64     `json:"version" gorm:"not null;default:1"`
-	Status     string    `json:"status" gorm:"size:20;not null;default:enabled"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID           string    `json:"id" gorm:"size:36;primaryKey"`
+	TenantID     string    `json:"tenant_id" gorm:"size:64;not null;uniqueIndex:uniq_acct_policies_code,priority:1"`
+	Code         string    `json:"code" gorm:"size:120;not null;uniqueIndex:uniq_acct_policies_code,priority:2"`
+	Name         string    `json:"name" gorm:"size:120;not null"`
+	Effect       string    `json:"effect" gorm:"size:16;not null;default:allow"` // allow / deny
+	Expression   string    `json:"expression" gorm:"type:text;not null"`
+	ResourceType string    `json:"resource_type" gorm:"size:80;not null;default:*"`
+	Action       string    `json:"action" gorm:"size:80;not null;default:*"`
+	Priority     int       `json:"priority" gorm:"not null;default:0"`
+	Version      int64     `json:"version" gorm:"not null;default:1"`
+	Status       string    `json:"status" gorm:"size:20;not null;default:enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 func (Policy) TableName() string { return "acct_policies" }
 
 // PolicyService 提供 ABAC 策略的 CRUD 和评估功能。
+// 表达式语法见 policy_expr.go；编译结果按表达式文本缓存，策略更新后自然换用新程序。
 type PolicyService struct {
 	m *Manager
+
+	mu       sync.RWMutex
+	programs map[string]*policyProgram
 }
 
+// policyProgramCacheSize 编译缓存上限，超出后整体清空重建。
+const policyProgramCacheSize = 1024
+
 // CreatePolicyRequest 创建策略的请求参数。
 type CreatePolicyRequest struct {
 	TenantID     string
@@ -51,6 +59,24 @@ type PolicyDecision struct {
 	Allowed bool
 	Reason  string
 	Matched bool // 是否有策略匹配
+	// PolicyCode 作出决定的策略：deny 为第一条命中的拒绝策略，allow 为第一条命中的允许策略。
+	PolicyCode string
+	// Trace 每条策略的评估轨迹，仅 Explain 时填充。
+	Trace []PolicyTrace
+}
+
+// PolicyTrace 单条策略的评估轨迹。
+type PolicyTrace struct {
+	PolicyID   string `json:"policy_id"`
+	Code       string `json:"code"`
+	Effect     string `json:"effect"`
+	Priority   int    `json:"priority"`
+	Expression string `json:"expression"`
+	// Applicable resource_type 与 action 是否匹配本次请求。
+	Applicable bool `json:"applicable"`
+	// Matched 表达式是否为真。
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
 }
 
 // CreatePolicy 创建新的 ABAC 策略。
@@ -71,6 +97,13 @@ func (s *PolicyService) CreatePolicy(ctx context.Context, req CreatePolicyReques
 		action = "*"
 	}
 
+	if req.Code == "" {
+		return nil, accountError(ErrInvalidArgument, "策略编码不能为空")
+	}
+	if _, err := s.program(req.Expression); err != nil {
+		return nil, accountError(ErrInvalidArgument, "策略表达式无效: "+err.Error())
+	}
+
 	policy := &Policy{
 		ID:           newID(),
 		TenantID:     s.m.tenantID(req.TenantID),
@@ -95,6 +128,9 @@ func (s *PolicyService) UpdatePolicy(ctx context.Context, policyID string, req C
 	ctx, span := s.m.tracer.Start(ctx, "account.policy.update")
 	defer span.End()
 
+	if _, err := s.program(req.Expression); err != nil {
+		return accountError(ErrInvalidArgument, "策略表达式无效: "+err.Error())
+	}
 	updates := map[string]any{
 		"name":          req.Name,
 		"expression":    req.Expression,
@@ -125,15 +161,28 @@ func (s *PolicyService) ListPolicies(ctx context.Context, tenantID string) ([]Po
 	return policies, err
 }
 
+// ValidateExpression 编译表达式并返回语法错误，供管理端保存前校验。
+func (s *PolicyService) ValidateExpression(expr string) error {
+	if _, err := s.program(expr); err != nil {
+		return accountError(ErrInvalidArgument, err.Error())
+	}
+	return nil
+}
+
 // EvaluatePolicies 评估所有匹配的策略并返回决策。
 // 评估逻辑：
 // 1. 按 priority 降序排列
 // 2. 匹配 resource_type 和 action（* 为通配）
-// 3. 评估表达式
+// 3. 评估表达式（求值出错的策略跳过并记录告警）
 // 4. 第一个匹配的 deny 直接拒绝
 // 5. 任意匹配的 allow 则允许
 // 6. 无匹配则返回未匹配
 func (s *PolicyService) EvaluatePolicies(ctx context.Context, req AuthzRequest) (*PolicyDecision, error) {
+	return s.evaluate(ctx, req, false)
+}
+
+// evaluate 评估策略；trace 为 true 时评估全部策略并记录轨迹（决策与非 trace 模式一致）。
+func (s *PolicyService) evaluate(ctx context.Context, req AuthzRequest, trace bool) (*PolicyDecision, error) {
 	ctx, span := s.m.tracer.Start(ctx, "account.policy.evaluate")
 	defer span.End()
 
@@ -149,34 +198,74 @@ func (s *PolicyService) EvaluatePolicies(ctx context.Context, req AuthzRequest)
 	}
 
 	env := buildPolicyEnv(req)
-
-	var anyAllowed bool
+	decision := &PolicyDecision{}
+	var allowedBy string
 	for _, p := range policies {
-		if !matchResourceAction(p, req) {
-			continue
+		t := PolicyTrace{PolicyID: p.ID, Code: p.Code, Effect: p.Effect, Priority: p.Priority, Expression: p.Expression}
+		t.Applicable = matchResourceAction(p, req)
+		if t.Applicable {
+			matched, err := s.evalPolicy(p, env)
+			if err != nil {
+				gaia.WarnF("[account] policy %s eval error: %v", p.Code, err)
+				t.Error = err.Error()
+			}
+			t.Matched = matched
 		}
-		matched, err := evalExpression(p.Expression, env)
-		if err != nil {
-			gaia.WarnF("[account] policy %s eval error: %v", p.Code, err)
-			continue
+		if trace {
+			decision.Trace = append(decision.Trace, t)
 		}
-		if !matched {
+		if !t.Matched || decision.Matched && !decision.Allowed {
 			continue
 		}
 		if p.Effect == "deny" {
-			return &PolicyDecision{
-				Allowed: false,
-				Reason:  fmt.Sprintf("denied by policy %s", p.Code),
-				Matched: true,
-			}, nil
+			decision.Allowed, decision.Matched, decision.PolicyCode = false, true, p.Code
+			decision.Reason = fmt.Sprintf("denied by policy %s", p.Code)
+			if !trace {
+				return decision, nil
+			}
+			continue
+		}
+		if allowedBy == "" {
+			allowedBy = p.Code
 		}
-		anyAllowed = true
 	}
+	if decision.Matched {
+		return decision, nil
+	}
+	if allowedBy != "" {
+		decision.Allowed, decision.Matched, decision.PolicyCode = true, true, allowedBy
+		decision.Reason = fmt.Sprintf("allowed by policy %s", allowedBy)
+	}
+	return decision, nil
+}
+
+func (s *PolicyService) evalPolicy(p Policy, env PolicyEnv) (bool, error) {
+	prog, err := s.program(p.Expression)
+	if err != nil {
+		return false, err
+	}
+	return prog.Eval(env)
+}
 
-	if anyAllowed {
-		return &PolicyDecision{Allowed: true, Reason: "allowed by policy", Matched: true}, nil
+// program 返回表达式的编译结果，按表达式文本缓存。
+func (s *PolicyService) program(expr string) (*policyProgram, error) {
+	s.mu.RLock()
+	prog, ok := s.programs[expr]
+	s.mu.RUnlock()
+	if ok {
+		return prog, nil
+	}
+	prog, err := compilePolicyExpression(expr)
+	if err != nil {
+		return nil, err
 	}
-	return &PolicyDecision{Matched: false}, nil
+	s.mu.Lock()
+	if s.programs == nil || len(s.programs) >= policyProgramCacheSize {
+		s.programs = make(map[string]*policyProgram)
+	}
+	s.programs[expr] = prog
+	s.mu.Unlock()
+	return prog, nil
 }
 
 // matchResourceAction 检查策略是否匹配请求的资源类型和操作。
@@ -184,262 +273,74 @@ func matchResourceAction(p Policy, req AuthzRequest) bool {
 	if p.ResourceType != "*" && p.ResourceType != req.ResourceType {
 		return false
 	}
-	if p.Action != "*" {
-		perm := req.Permission
-		if idx := strings.LastIndex(perm, ":"); idx >= 0 {
-			perm = perm[idx+1:]
-		}
-		if p.Action != perm {
-			return false
-		}
+	if p.Action != "*" && p.Action != permissionAction(req.Permission) {
+		return false
 	}
 	return true
 }
 
-// PolicyEnv 策略表达式求值环境。
-type PolicyEnv struct {
-	Subject  map[string]string
-	Resource map[string]string
+// permissionAction 取权限码的操作部分，如 "order:read" → "read"。
+func permissionAction(perm string) string {
+	if idx := strings.LastIndex(perm, ":"); idx >= 0 {
+		return perm[idx+1:]
+	}
+	return perm
 }
 
 // buildPolicyEnv 从 AuthzRequest 构建策略求值环境。
+//
+//	subject.*  user_id / tenant_id / username / session_id / roles（列表）/ phone_verified / api_token
+//	resource.* type / id / owner_id / org_id，以及 ResourceAttrs 中的自定义属性
+//	request.*  permission / action / ip / user_agent / time / mfa_level
+//	env.*      AuthzContext.Env 中由调用方提供的环境属性
 func buildPolicyEnv(req AuthzRequest) PolicyEnv {
+	roles := make([]any, 0, len(req.Subject.Roles))
+	for _, r := range req.Subject.Roles {
+		roles = append(roles, r)
+	}
 	env := PolicyEnv{
-		Subject: map[string]string{
-			"user_id": req.Subject.UserID,
-			"tenant_id": req.Subject.TenantID,
+		Subject: map[string]any{
+			"user_id":        req.Subject.UserID,
+			"tenant_id":      req.Subject.TenantID,
+			"username":       req.Subject.Username,
+			"session_id":     req.Subject.SessionID,
+			"roles":          roles,
+			"phone_verified": req.Subject.PhoneVerified,
+			"api_token":      req.Subject.APITokenID != "",
 		},
-		Resource: map[string]string{
-			"type":    req.ResourceType,
-			"id":      req.ResourceID,
+		Resource: map[string]any{
+			"type":     req.ResourceType,
+			"id":       req.ResourceID,
 			"owner_id": req.OwnerID,
+			"org_id":   req.OrgID,
 		},
+		Request: map[string]any{
+			"permission": req.Permission,
+			"action":     permissionAction(req.Permission),
+			"time":       time.Now(),
+			"mfa_level":  float64(0),
+		},
+		Env: map[string]any{},
 	}
-	if len(req.Subject.Roles) > 0 {
-		env.Subject["roles"] = strings.Join(req.Subject.Roles, ",")
-	}
-	return env
-}
-
-// evalExpression 评估策略表达式。
-// 支持的语法：
-//   subject.user_id == resource.owner_id
-//   subject.roles contains "admin"
-//   resource.type == "document" && subject.user_id == resource.owner_id
-//   subject.user_id in ["id1", "id2"]
-//   resource.type == "order"
-//   contains(subject.roles, "admin")
-func evalExpression(expr string, env PolicyEnv) (bool, error) {
-	expr = strings.TrimSpace(expr)
-	if expr == "" {
-		return true, nil
-	}
-
-	// Handle AND (&&)
-	if parts := splitLogical(expr, "&&"); len(parts) > 1 {
-		for _, part := range parts {
-			ok, err := evalExpression(part, env)
-			if err != nil {
-				return false, err
-			}
-			if !ok {
-				return false, nil
-			}
-		}
-		return true, nil
-	}
-
-	// Handle OR (||)
-	if parts := splitLogical(expr, "||"); len(parts) > 1 {
-		for _, part := range parts {
-			ok, err := evalExpression(part, env)
-			if err != nil {
-				return false, err
-			}
-			if ok {
-				return true, nil
-			}
-		}
-		return false, nil
-	}
-
-	// Handle NOT (!)
-	if strings.HasPrefix(expr, "!") {
-		inner := strings.TrimSpace(expr[1:])
-		if strings.HasPrefix(inner, "(") && strings.HasSuffix(inner, ")") {
-			inner = strings.TrimSpace(inner[1 : len(inner)-1])
+	for k, v := range req.ResourceAttrs {
+		if _, builtin := env.Resource[k]; !builtin {
+			env.Resource[k] = v
 		}
-		ok, err := evalExpression(inner, env)
-		return !ok, err
-	}
-
-	// Handle parenthesized expression
-	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
-		return evalExpression(strings.TrimSpace(expr[1:len(expr)-1]), env)
-	}
-
-	// Handle "contains(attr, value)" function
-	if strings.HasPrefix(expr, "contains(") && strings.HasSuffix(expr, ")") {
-		return evalContains(expr, env)
-	}
-
-	// Handle "attr in [val1, val2]" syntax
-	if idx := strings.Index(expr, " in "); idx > 0 {
-		return evalIn(expr[:idx], expr[idx+4:], env)
 	}
-
-	// Handle comparison operators
-	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
-		if idx := strings.Index(expr, op); idx > 0 {
-			left := strings.TrimSpace(expr[:idx])
-			right := strings.TrimSpace(expr[idx+len(op):])
-			return evalCompare(left, op, right, env)
+	if c := req.Context; c != nil {
+		if c.ClientIP != "" {
+			env.Request["ip"] = c.ClientIP
 		}
-	}
-
-	return false, fmt.Errorf("unsupported expression: %s", expr)
-}
-
-// splitLogical 按逻辑运算符拆分表达式，尊重括号嵌套。
-func splitLogical(expr, op string) []string {
-	var parts []string
-	depth := 0
-	last := 0
-	for i := 0; i <= len(expr)-len(op); i++ {
-		switch expr[i] {
-		case '(':
-			depth++
-		case ')':
-			depth--
+		if c.UserAgent != "" {
+			env.Request["user_agent"] = c.UserAgent
 		}
-		if depth == 0 && expr[i:i+len(op)] == op {
-			part := strings.TrimSpace(expr[last:i])
-			if part != "" {
-				parts = append(parts, part)
-			}
-			last = i + len(op)
+		if !c.Time.IsZero() {
+			env.Request["time"] = c.Time
 		}
-	}
-	part := strings.TrimSpace(expr[last:])
-	if part != "" {
-		parts = append(parts, part)
-	}
-	return parts
-}
-
-// resolveAttr 解析属性路径，如 "subject.user_id"。
-func resolveAttr(path string, env PolicyEnv) (string, bool) {
-	path = strings.TrimSpace(path)
-	parts := strings.SplitN(path, ".", 2)
-	if len(parts) != 2 {
-		return "", false
-	}
-	prefix, key := parts[0], parts[1]
-	switch prefix {
-	case "subject":
-		v, ok := env.Subject[key]
-		return v, ok
-	case "resource":
-		v, ok := env.Resource[key]
-		return v, ok
-	}
-	return "", false
-}
-
-// resolveValue 解析值：支持属性引用、字符串字面量和数字。
-func resolveValue(val string, env PolicyEnv) string {
-	val = strings.TrimSpace(val)
-	// String literal
-	if (strings.HasPrefix(val, "\"") && strings.HasSuffix(val, "\"")) ||
-		(strings.HasPrefix(val, "'") && strings.HasSuffix(val, "'")) {
-		return val[1 : len(val)-1]
-	}
-	// Attribute reference
-	if v, ok := resolveAttr(val, env); ok {
-		return v
-	}
-	return val
-}
-
-// evalCompare 评估比较表达式。
-func evalCompare(left, op, right string, env PolicyEnv) (bool, error) {
-	l := resolveValue(left, env)
-	r := resolveValue(right, env)
-	switch op {
-	case "==":
-		return l == r, nil
-	case "!=":
-		return l != r, nil
-	case ">":
-		return l > r, nil
-	case "<":
-		return l < r, nil
-	case ">=":
-		return l >= r, nil
-	case "<=":
-		return l <= r, nil
-	}
-	return false, fmt.Errorf("unsupported operator: %s", op)
-}
-
-// evalContains 评估 contains(attr, value) 函数。
-func evalContains(expr string, env PolicyEnv) (bool, error) {
-	inner := strings.TrimSpace(expr[9 : len(expr)-1])
-	parts := splitFuncArgs(inner)
-	if len(parts) != 2 {
-		return false, fmt.Errorf("contains() requires 2 arguments")
-	}
-	haystack := resolveValue(parts[0], env)
-	needle := resolveValue(parts[1], env)
-	return strings.Contains(haystack, needle), nil
-}
-
-// evalIn 评估 "attr in [val1, val2]" 表达式。
-func evalIn(left, right string, env PolicyEnv) (bool, error) {
-	val := resolveValue(left, env)
-	right = strings.TrimSpace(right)
-	if !strings.HasPrefix(right, "[") || !strings.HasSuffix(right, "]") {
-		return false, fmt.Errorf("in operator requires list on right side")
-	}
-	items := strings.Split(right[1:len(right)-1], ",")
-	for _, item := range items {
-		item = resolveValue(strings.TrimSpace(item), env)
-		if val == item {
-			return true, nil
+		env.Request["mfa_level"] = float64(c.MFALevel)
+		for k, v := range c.Env {
+			env.Env[k] = v
 		}
 	}
-	return false, nil
-}
-
-// splitFuncArgs 拆分函数参数，尊重引号。
-func splitFuncArgs(s string) []string {
-	var args []string
-	depth := 0
-	inQuote := byte(0)
-	last := 0
-	for i := 0; i < len(s); i++ {
-		switch s[i] {
-		case '"', '\'':
-			if inQuote == 0 {
-				inQuote = s[i]
-			} else if inQuote == s[i] {
-				inQuote = 0
-			}
-		case '(':
-			if inQuote == 0 {
-				depth++
-			}
-		case ')':
-			if inQuote == 0 {
-				depth--
-			}
-		case ',':
-			if inQuote == 0 && depth == 0 {
-				args = append(args, strings.TrimSpace(s[last:i]))
-				last = i + 1
-			}
-		}
-	}
-	args = append(args, strings.TrimSpace(s[last:]))
-	return args
+	return env
 }
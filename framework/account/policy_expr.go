@@ -0,0 +1,956 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 策略表达式语言
+//
+// 语法（优先级由低到高）：
+//
+//	expr    := or
+//	or      := and { ("||" | "or") and }
+//	and     := not { ("&&" | "and") not }
+//	not     := ("!" | "not") not | compare
+//	compare := operand [ op operand ]
+//	op      := == != < <= > >= in "not in" contains matches
+//	operand := 字面量 | 属性路径 | 函数调用 | 列表 | "(" expr ")"
+//
+// 字面量：字符串（"..." 或 '...'）、数字、true / false / null；列表写作 ["a", "b"]。
+// 属性路径以命名空间开头：subject.* / resource.* / request.* / env.*，不存在的属性求值为 null。
+// 值带类型：字符串、数字、布尔、列表、时间与 null，数字与数字字符串可以互相比较，时间可与 RFC 3339 字符串比较。
+//
+// 内置函数：
+//
+//	contains(list|str, x)       列表包含元素或字符串包含子串
+//	startsWith(s, p) / endsWith(s, p) / lower(s) / upper(s) / len(x)
+//	matches(s, regexp)          正则匹配
+//	ipInRange(ip, cidr...)      IP 是否落在任一网段内，参数可以是字符串或列表
+//	timeBetween(t, "09:00", "18:00"[, "Asia/Shanghai"])  一天中的时段，支持跨零点
+//	hour(t) / weekday(t)        小时（0-23）与星期（0=周日）
+//	now()                       request.time
+//	time(s)                     解析 RFC 3339 或 2006-01-02
+//
+// 表达式在保存时编译，语法错误与未知命名空间、未知函数在编译期报错。
+
+// PolicyEnv 策略表达式求值环境，对应四个命名空间。
+type PolicyEnv struct {
+	Subject  map[string]any `json:"subject"`
+	Resource map[string]any `json:"resource"`
+	Request  map[string]any `json:"request"`
+	Env      map[string]any `json:"env"`
+}
+
+// policyProgram 编译后的策略表达式。
+type policyProgram struct {
+	expr string
+	root policyNode // nil 表示空表达式，恒为真
+}
+
+// Eval 对环境求值，结果必须为布尔值（null 视为 false）。
+func (p *policyProgram) Eval(env PolicyEnv) (bool, error) {
+	if p.root == nil {
+		return true, nil
+	}
+	v, err := p.root.eval(&env)
+	if err != nil {
+		return false, err
+	}
+	return policyTruth(v)
+}
+
+// compilePolicyExpression 编译策略表达式。
+func compilePolicyExpression(expr string) (*policyProgram, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return &policyProgram{}, nil
+	}
+	toks, err := lexPolicy(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &policyParser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("位置 %d 处有多余的 %q", t.pos, t.text)
+	}
+	return &policyProgram{expr: expr, root: root}, nil
+}
+
+// evalExpression 编译并求值表达式，供一次性调用使用；热路径应使用 PolicyService 缓存的程序。
+func evalExpression(expr string, env PolicyEnv) (bool, error) {
+	prog, err := compilePolicyExpression(expr)
+	if err != nil {
+		return false, err
+	}
+	return prog.Eval(env)
+}
+
+// ============================================================
+// 词法分析
+// ============================================================
+
+type policyTokKind int
+
+const (
+	tokEOF policyTokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type policyToken struct {
+	kind policyTokKind
+	text string
+	pos  int
+}
+
+func lexPolicy(s string) ([]policyToken, error) {
+	var toks []policyToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("位置 %d 处的字符串未闭合", i)
+			}
+			toks = append(toks, policyToken{tokString, b.String(), i})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			toks = append(toks, policyToken{tokNumber, s[i:j], i})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			toks = append(toks, policyToken{tokIdent, s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, cand := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(s[i:], cand) {
+					op = cand
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("位置 %d 处有无法识别的字符 %q", i, c)
+			}
+			toks = append(toks, policyToken{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, policyToken{kind: tokEOF, pos: len(s)}), nil
+}
+
+// ============================================================
+// 语法分析
+// ============================================================
+
+type policyParser struct {
+	toks []policyToken
+	pos  int
+}
+
+func (p *policyParser) peek() policyToken { return p.toks[p.pos] }
+
+func (p *policyParser) next() policyToken {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept 消费给定的运算符或关键字。
+func (p *policyParser) accept(words ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return false
+	}
+	for _, w := range words {
+		if t.text == w {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *policyParser) expect(op string) error {
+	if t := p.next(); t.kind != tokOp || t.text != op {
+		return fmt.Errorf("位置 %d 处应为 %q", t.pos, op)
+	}
+	return nil
+}
+
+func (p *policyParser) parseOr() (policyNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||", "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &policyLogicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *policyParser) parseAnd() (policyNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&", "and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &policyLogicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *policyParser) parseNot() (policyNode, error) {
+	// "not in" 是比较运算符，只在操作数之后出现，这里的 not 一定是一元取反
+	if p.accept("!", "not") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &policyNotNode{inner: inner}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *policyParser) parseCompare() (policyNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	op := ""
+	switch {
+	case t.kind == tokOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+		op = t.text
+	case t.kind == tokIdent && (t.text == "in" || t.text == "contains" || t.text == "matches"):
+		op = t.text
+	case t.kind == tokIdent && t.text == "not" && p.toks[p.pos+1].kind == tokIdent && p.toks[p.pos+1].text == "in":
+		p.pos++
+		op = "not in"
+	}
+	if op == "" {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	node := &policyCompareNode{op: op, left: left, right: right}
+	if op == "matches" {
+		if lit, ok := right.(*policyLiteralNode); ok {
+			s, ok := lit.v.(string)
+			if !ok {
+				return nil, errors.New("matches 的右侧必须是字符串")
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("正则表达式无效: %w", err)
+			}
+			node.re = re
+		}
+	}
+	return node, nil
+}
+
+func (p *policyParser) parseOperand() (policyNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return &policyLiteralNode{v: t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d 处的数字 %q 无效", t.pos, t.text)
+		}
+		return &policyLiteralNode{v: f}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			list := &policyListNode{}
+			if p.accept("]") {
+				return list, nil
+			}
+			for {
+				item, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+				if p.accept("]") {
+					return list, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &policyLiteralNode{v: true}, nil
+		case "false":
+			return &policyLiteralNode{v: false}, nil
+		case "null":
+			return &policyLiteralNode{v: nil}, nil
+		}
+		if p.accept("(") {
+			return p.parseCall(t)
+		}
+		path := []string{t.text}
+		for p.accept(".") {
+			seg := p.next()
+			if seg.kind != tokIdent {
+				return nil, fmt.Errorf("位置 %d 处应为属性名", seg.pos)
+			}
+			path = append(path, seg.text)
+		}
+		switch path[0] {
+		case "subject", "resource", "request", "env":
+		default:
+			return nil, fmt.Errorf("未知的命名空间 %q，可用 subject / resource / request / env", path[0])
+		}
+		if len(path) < 2 {
+			return nil, fmt.Errorf("位置 %d 处的属性路径不完整", t.pos)
+		}
+		return &policyPathNode{path: path}, nil
+	}
+	if t.kind == tokEOF {
+		return nil, errors.New("表达式意外结束")
+	}
+	return nil, fmt.Errorf("位置 %d 处有意外的 %q", t.pos, t.text)
+}
+
+func (p *policyParser) parseCall(name policyToken) (policyNode, error) {
+	fn, ok := policyFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("未知函数 %s()", name.text)
+	}
+	call := &policyCallNode{name: name.text, fn: fn}
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(call.args) < fn.min || fn.max >= 0 && len(call.args) > fn.max {
+		return nil, fmt.Errorf("%s() 参数个数不正确", name.text)
+	}
+	return call, nil
+}
+
+// ============================================================
+// 求值
+// ============================================================
+
+type policyNode interface {
+	eval(env *PolicyEnv) (any, error)
+}
+
+type policyLiteralNode struct{ v any }
+
+func (n *policyLiteralNode) eval(*PolicyEnv) (any, error) { return n.v, nil }
+
+type policyListNode struct{ items []policyNode }
+
+func (n *policyListNode) eval(env *PolicyEnv) (any, error) {
+	out := make([]any, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+type policyPathNode struct{ path []string }
+
+func (n *policyPathNode) eval(env *PolicyEnv) (any, error) {
+	v, _ := resolveAttr(n.path, *env)
+	return v, nil
+}
+
+type policyNotNode struct{ inner policyNode }
+
+func (n *policyNotNode) eval(env *PolicyEnv) (any, error) {
+	v, err := n.inner.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, err := policyTruth(v)
+	return !b, err
+}
+
+type policyLogicNode struct {
+	and         bool
+	left, right policyNode
+}
+
+func (n *policyLogicNode) eval(env *PolicyEnv) (any, error) {
+	v, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	l, err := policyTruth(v)
+	if err != nil {
+		return nil, err
+	}
+	if n.and != l {
+		// and 左侧为假 / or 左侧为真时短路
+		return l, nil
+	}
+	v, err = n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return policyTruth(v)
+}
+
+type policyCompareNode struct {
+	op          string
+	left, right policyNode
+	re          *regexp.Regexp // matches 右侧为字面量时预编译
+}
+
+func (n *policyCompareNode) eval(env *PolicyEnv) (any, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return policyEqual(l, r), nil
+	case "!=":
+		return !policyEqual(l, r), nil
+	case "in":
+		return policyContains(r, l)
+	case "not in":
+		ok, err := policyContains(r, l)
+		return !ok, err
+	case "contains":
+		return policyContains(l, r)
+	case "matches":
+		return policyMatches(l, r, n.re)
+	}
+	if l == nil || r == nil {
+		// 缺失的属性不参与大小比较
+		return false, nil
+	}
+	c, err := policyCompare(l, r)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type policyCallNode struct {
+	name string
+	fn   policyFunc
+	args []policyNode
+}
+
+func (n *policyCallNode) eval(env *PolicyEnv) (any, error) {
+	args := make([]any, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := n.fn.call(env, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", n.name, err)
+	}
+	return v, nil
+}
+
+// resolveAttr 按路径查找属性，支持嵌套映射，如 ["resource", "labels", "env"]。
+func resolveAttr(path []string, env PolicyEnv) (any, bool) {
+	var cur any
+	switch path[0] {
+	case "subject":
+		cur = env.Subject
+	case "resource":
+		cur = env.Resource
+	case "request":
+		cur = env.Request
+	case "env":
+		cur = env.Env
+	default:
+		return nil, false
+	}
+	for _, key := range path[1:] {
+		switch m := cur.(type) {
+		case map[string]any:
+			v, ok := m[key]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case map[string]string:
+			v, ok := m[key]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		default:
+			return nil, false
+		}
+	}
+	return normalizePolicyValue(cur), true
+}
+
+// normalizePolicyValue 把调用方传入的 Go 值归一化为表达式类型。
+func normalizePolicyValue(v any) any {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int8:
+		return float64(x)
+	case int16:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case uint8:
+		return float64(x)
+	case uint16:
+		return float64(x)
+	case uint32:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case json.Number:
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case *time.Time:
+		if x == nil {
+			return nil
+		}
+		return *x
+	case []string:
+		out := make([]any, len(x))
+		for i, s := range x {
+			out[i] = s
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = normalizePolicyValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+func policyTruth(v any) (bool, error) {
+	switch x := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return x, nil
+	}
+	return false, fmt.Errorf("期望布尔值，实际为 %s", policyTypeName(v))
+}
+
+func policyTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case time.Time:
+		return "time"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// policyNumber 数字或可解析为数字的字符串。
+func policyNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// policyTime 时间或可解析为时间的字符串。
+func policyTime(v any) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, x); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func policyEqual(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	switch x := a.(type) {
+	case float64:
+		if y, ok := policyNumber(b); ok {
+			return x == y
+		}
+		return false
+	case time.Time:
+		if y, ok := policyTime(b); ok {
+			return x.Equal(y)
+		}
+		return false
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !policyEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case string:
+		switch b.(type) {
+		case float64, time.Time:
+			return policyEqual(b, a)
+		}
+	}
+	return a == b
+}
+
+// policyCompare 返回 -1 / 0 / 1。
+func policyCompare(a, b any) (int, error) {
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum || bNum {
+		x, ok1 := policyNumber(a)
+		y, ok2 := policyNumber(b)
+		if ok1 && ok2 {
+			return cmpFloat(x, y), nil
+		}
+	}
+	_, aTime := a.(time.Time)
+	_, bTime := b.(time.Time)
+	if aTime || bTime {
+		x, ok1 := policyTime(a)
+		y, ok2 := policyTime(b)
+		if ok1 && ok2 {
+			return x.Compare(y), nil
+		}
+	}
+	x, ok1 := a.(string)
+	y, ok2 := b.(string)
+	if ok1 && ok2 {
+		return strings.Compare(x, y), nil
+	}
+	return 0, fmt.Errorf("无法比较 %s 与 %s", policyTypeName(a), policyTypeName(b))
+}
+
+func cmpFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// policyContains 列表包含元素，或字符串包含子串。
+func policyContains(haystack, needle any) (bool, error) {
+	switch h := haystack.(type) {
+	case nil:
+		return false, nil
+	case []any:
+		for _, item := range h {
+			if policyEqual(item, needle) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		s, ok := needle.(string)
+		if !ok {
+			return false, fmt.Errorf("字符串只能包含字符串，实际为 %s", policyTypeName(needle))
+		}
+		return strings.Contains(h, s), nil
+	}
+	return false, fmt.Errorf("%s 不支持包含判断", policyTypeName(haystack))
+}
+
+func policyMatches(v, pattern any, re *regexp.Regexp) (bool, error) {
+	s, ok := v.(string)
+	if !ok {
+		return false, nil
+	}
+	if re == nil {
+		p, ok := pattern.(string)
+		if !ok {
+			return false, errors.New("matches 的右侧必须是字符串")
+		}
+		var err error
+		if re, err = regexp.Compile(p); err != nil {
+			return false, fmt.Errorf("正则表达式无效: %w", err)
+		}
+	}
+	return re.MatchString(s), nil
+}
+
+// ============================================================
+// 内置函数
+// ============================================================
+
+type policyFunc struct {
+	min, max int // max < 0 表示可变参数
+	call     func(env *PolicyEnv, args []any) (any, error)
+}
+
+var policyFuncs map[string]policyFunc
+
+func init() {
+	policyFuncs = map[string]policyFunc{
+		"contains": {2, 2, func(_ *PolicyEnv, a []any) (any, error) { return policyContains(a[0], a[1]) }},
+		"startsWith": {2, 2, func(_ *PolicyEnv, a []any) (any, error) {
+			s, p, err := policyStringArgs(a[0], a[1])
+			return err == nil && strings.HasPrefix(s, p), err
+		}},
+		"endsWith": {2, 2, func(_ *PolicyEnv, a []any) (any, error) {
+			s, p, err := policyStringArgs(a[0], a[1])
+			return err == nil && strings.HasSuffix(s, p), err
+		}},
+		"lower": {1, 1, func(_ *PolicyEnv, a []any) (any, error) {
+			if s, ok := a[0].(string); ok {
+				return strings.ToLower(s), nil
+			}
+			return a[0], nil
+		}},
+		"upper": {1, 1, func(_ *PolicyEnv, a []any) (any, error) {
+			if s, ok := a[0].(string); ok {
+				return strings.ToUpper(s), nil
+			}
+			return a[0], nil
+		}},
+		"len": {1, 1, func(_ *PolicyEnv, a []any) (any, error) {
+			switch x := a[0].(type) {
+			case nil:
+				return float64(0), nil
+			case string:
+				return float64(len([]rune(x))), nil
+			case []any:
+				return float64(len(x)), nil
+			}
+			return nil, fmt.Errorf("不支持 %s", policyTypeName(a[0]))
+		}},
+		"matches":     {2, 2, func(_ *PolicyEnv, a []any) (any, error) { return policyMatches(a[0], a[1], nil) }},
+		"ipInRange":   {2, -1, policyIPInRange},
+		"timeBetween": {3, 4, policyTimeBetween},
+		"hour": {1, 1, func(_ *PolicyEnv, a []any) (any, error) {
+			t, ok := policyTime(a[0])
+			if !ok {
+				return nil, nil
+			}
+			return float64(t.Hour()), nil
+		}},
+		"weekday": {1, 1, func(_ *PolicyEnv, a []any) (any, error) {
+			t, ok := policyTime(a[0])
+			if !ok {
+				return nil, nil
+			}
+			return float64(t.Weekday()), nil
+		}},
+		"now": {0, 0, func(env *PolicyEnv, _ []any) (any, error) {
+			if t, ok := env.Request["time"].(time.Time); ok {
+				return t, nil
+			}
+			return time.Now(), nil
+		}},
+		"time": {1, 1, func(_ *PolicyEnv, a []any) (any, error) {
+			t, ok := policyTime(a[0])
+			if !ok {
+				return nil, fmt.Errorf("无法解析时间 %v", a[0])
+			}
+			return t, nil
+		}},
+	}
+}
+
+func policyStringArgs(a, b any) (string, string, error) {
+	if a == nil {
+		return "", "", nil
+	}
+	s, ok1 := a.(string)
+	p, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return "", "", errors.New("参数必须是字符串")
+	}
+	return s, p, nil
+}
+
+func policyIPInRange(_ *PolicyEnv, args []any) (any, error) {
+	s, _ := args[0].(string)
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		// 缺失或无效的 IP 不在任何网段内
+		return false, nil
+	}
+	addr = addr.Unmap()
+	var ranges []any
+	for _, a := range args[1:] {
+		if list, ok := a.([]any); ok {
+			ranges = append(ranges, list...)
+		} else {
+			ranges = append(ranges, a)
+		}
+	}
+	for _, r := range ranges {
+		cidr, ok := r.(string)
+		if !ok {
+			return nil, errors.New("网段必须是字符串")
+		}
+		if !strings.Contains(cidr, "/") {
+			if single, err := netip.ParseAddr(cidr); err == nil && single.Unmap() == addr {
+				return true, nil
+			}
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("网段 %q 无效", cidr)
+		}
+		if prefix.Contains(addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func policyTimeBetween(_ *PolicyEnv, args []any) (any, error) {
+	t, ok := policyTime(args[0])
+	if !ok {
+		return false, nil
+	}
+	if len(args) == 4 {
+		name, _ := args[3].(string)
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("时区 %q 无效", name)
+		}
+		t = t.In(loc)
+	}
+	parse := func(v any) (int, error) {
+		s, _ := v.(string)
+		clock, err := time.Parse("15:04", s)
+		if err != nil {
+			return 0, fmt.Errorf("时刻 %q 应为 HH:MM", s)
+		}
+		return clock.Hour()*60 + clock.Minute(), nil
+	}
+	start, err := parse(args[1])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parse(args[2])
+	if err != nil {
+		return nil, err
+	}
+	cur := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return cur >= start && cur < end, nil
+	}
+	// 跨零点，如 22:00 - 06:00
+	return cur >= start || cur < end, nil
+}
@@ -1,33 +1,54 @@
 package account
 
-import "testing"
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
 
 func TestBuildPolicyEnv(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC)
 	req := AuthzRequest{
-		Subject:    &Principal{UserID: "u1", TenantID: "t1", Roles: []string{"admin"}},
-		ResourceType: "order",
-		ResourceID:   "123",
-		OwnerID:      "owner1",
-		Permission:   "order:read",
+		Subject:       &Principal{UserID: "u1", TenantID: "t1", Roles: []string{"admin"}},
+		ResourceType:  "order",
+		ResourceID:    "123",
+		OwnerID:       "owner1",
+		Permission:    "order:read",
+		ResourceAttrs: map[string]any{"amount": 120, "type": "ignored"},
+		Context:       &AuthzContext{ClientIP: "10.0.0.8", Time: now, MFALevel: 2, Env: map[string]any{"region": "cn"}},
 	}
 	env := buildPolicyEnv(req)
 	if env.Subject["user_id"] != "u1" {
-		t.Fatalf("expected u1, got %s", env.Subject["user_id"])
+		t.Fatalf("expected u1, got %v", env.Subject["user_id"])
 	}
 	if env.Subject["tenant_id"] != "t1" {
-		t.Fatalf("expected t1, got %s", env.Subject["tenant_id"])
+		t.Fatalf("expected t1, got %v", env.Subject["tenant_id"])
 	}
 	if env.Resource["type"] != "order" {
-		t.Fatalf("expected order, got %s", env.Resource["type"])
+		t.Fatalf("resource attrs must not override builtins, got %v", env.Resource["type"])
 	}
 	if env.Resource["id"] != "123" {
-		t.Fatalf("expected 123, got %s", env.Resource["id"])
+		t.Fatalf("expected 123, got %v", env.Resource["id"])
 	}
 	if env.Resource["owner_id"] != "owner1" {
-		t.Fatalf("expected owner1, got %s", env.Resource["owner_id"])
+		t.Fatalf("expected owner1, got %v", env.Resource["owner_id"])
+	}
+	if env.Resource["amount"] != 120 {
+		t.Fatalf("expected custom attr amount, got %v", env.Resource["amount"])
+	}
+	if roles, ok := env.Subject["roles"].([]any); !ok || len(roles) != 1 || roles[0] != "admin" {
+		t.Fatalf("expected roles list [admin], got %v", env.Subject["roles"])
+	}
+	if env.Request["action"] != "read" || env.Request["ip"] != "10.0.0.8" || env.Request["time"] != now || env.Request["mfa_level"] != float64(2) {
+		t.Fatalf("unexpected request attrs: %v", env.Request)
 	}
-	if env.Subject["roles"] != "admin" {
-		t.Fatalf("expected admin, got %s", env.Subject["roles"])
+	if env.Env["region"] != "cn" {
+		t.Fatalf("expected env.region, got %v", env.Env["region"])
 	}
 }
 
@@ -36,188 +57,48 @@ func TestBuildPolicyEnvNoRoles(t *testing.T) {
 		Subject: &Principal{UserID: "u1", TenantID: "t1"},
 	}
 	env := buildPolicyEnv(req)
-	if _, ok := env.Subject["roles"]; ok {
-		t.Fatal("expected no roles key when roles is empty")
-	}
-}
-
-func TestSplitLogical(t *testing.T) {
-	tests := []struct {
-		expr string
-		op   string
-		want int
-	}{
-		{"a == b && c == d", "&&", 2},
-		{"a == b || c == d", "||", 2},
-		{"(a && b) || c", "||", 2},
-		{"single", "&&", 1},
-		{"", "&&", 0},
+	if roles, ok := env.Subject["roles"].([]any); !ok || len(roles) != 0 {
+		t.Fatalf("expected empty roles list, got %v", env.Subject["roles"])
 	}
-	for _, tt := range tests {
-		t.Run(tt.expr, func(t *testing.T) {
-			parts := splitLogical(tt.expr, tt.op)
-			if len(parts) != tt.want {
-				t.Errorf("splitLogical(%q, %q) got %d parts, want %d: %v", tt.expr, tt.op, len(parts), tt.want, parts)
-			}
-		})
+	if _, ok := env.Request["ip"]; ok {
+		t.Fatal("expected no ip without context")
 	}
 }
 
 func TestResolveAttr(t *testing.T) {
 	env := PolicyEnv{
-		Subject:  map[string]string{"user_id": "u1", "roles": "admin,user"},
-		Resource: map[string]string{"type": "order", "id": "123", "owner_id": "owner1"},
+		Subject:  map[string]any{"user_id": "u1", "roles": []string{"admin", "user"}},
+		Resource: map[string]any{"type": "order", "labels": map[string]string{"env": "prod"}, "amount": 42},
 	}
 	tests := []struct {
-		path    string
-		want   string
+		path   []string
+		want   any
 		wantOK bool
 	}{
-		{"subject.user_id", "u1", true},
-		{"subject.roles", "admin,user", true},
-		{"resource.type", "order", true},
-		{"resource.id", "123", true},
-		{"resource.owner_id", "owner1", true},
-		{"subject.nonexistent", "", false},
-		{"resource.nonexistent", "", false},
-		{"invalid", "", false},
-		{"too.many.parts", "", false},
+		{[]string{"subject", "user_id"}, "u1", true},
+		{[]string{"resource", "type"}, "order", true},
+		{[]string{"resource", "labels", "env"}, "prod", true},
+		{[]string{"resource", "amount"}, float64(42), true},
+		{[]string{"subject", "nonexistent"}, nil, false},
+		{[]string{"resource", "type", "deeper"}, nil, false},
+		{[]string{"invalid", "x"}, nil, false},
 	}
 	for _, tt := range tests {
-		t.Run(tt.path, func(t *testing.T) {
-			got, ok := resolveAttr(tt.path, env)
-			if ok != tt.wantOK || got != tt.want {
-				t.Errorf("resolveAttr(%q) = (%q, %v), want (%q, %v)", tt.path, got, ok, tt.want, tt.wantOK)
-			}
-		})
-	}
-}
-
-func TestResolveValue(t *testing.T) {
-	env := PolicyEnv{
-		Subject:  map[string]string{"user_id": "u1"},
-		Resource: map[string]string{"owner_id": "owner1"},
-	}
-	tests := []struct {
-		val  string
-		want string
-	}{
-		{`"literal"`, "literal"},
-		{`'single'`, "single"},
-		{"subject.user_id", "u1"},
-		{"resource.owner_id", "owner1"},
-		{"42", "42"},
-		{"nonexistent", "nonexistent"}, // unresolvable returns as-is
-	}
-	for _, tt := range tests {
-		t.Run(tt.val, func(t *testing.T) {
-			if got := resolveValue(tt.val, env); got != tt.want {
-				t.Errorf("resolveValue(%q) = %q, want %q", tt.val, got, tt.want)
-			}
-		})
-	}
-}
-
-func TestEvalCompare(t *testing.T) {
-	env := PolicyEnv{
-		Subject:  map[string]string{"user_id": "u1"},
-		Resource: map[string]string{"owner_id": "u1", "type": "order"},
+		got, ok := resolveAttr(tt.path, env)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("resolveAttr(%v) = (%v, %v), want (%v, %v)", tt.path, got, ok, tt.want, tt.wantOK)
+		}
 	}
-	tests := []struct {
-		left    string
-		op      string
-		right   string
-		want    bool
-		wantErr bool
-	}{
-		{"subject.user_id", "==", "resource.owner_id", true, false},
-		{"subject.user_id", "!=", "resource.owner_id", false, false},
-		{`"a"`, "==", `"a"`, true, false},
-		{`"a"`, "==", `"b"`, false, false},
-		{`"b"`, ">", `"a"`, true, false},
-		{`"a"`, "<", `"b"`, true, false},
-		{`"a"`, ">=", `"a"`, true, false},
-		{`"a"`, "<=", `"a"`, true, false},
-		{`"a"`, "^^", `"b"`, false, true},
-	}
-	for _, tt := range tests {
-		t.Run(tt.left+" "+tt.op, func(t *testing.T) {
-			got, err := evalCompare(tt.left, tt.op, tt.right, env)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("evalCompare() error = %v, wantErr = %v", err, tt.wantErr)
-				return
-			}
-			if err == nil && got != tt.want {
-				t.Errorf("evalCompare() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestEvalContains(t *testing.T) {
-	env := PolicyEnv{
-		Subject:  map[string]string{"roles": "admin,user,editor"},
-		Resource: map[string]string{"type": "order"},
-	}
-	tests := []struct {
-		expr    string
-		want    bool
-		wantErr bool
-	}{
-		{`contains(subject.roles, "admin")`, true, false},
-		{`contains(subject.roles, "superadmin")`, false, false},
-		{`contains(subject.roles, "editor")`, true, false},
-		{`contains(resource.type, "order")`, true, false},
-		{`contains(resource.type, "document")`, false, false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.expr, func(t *testing.T) {
-			got, err := evalContains(tt.expr, env)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("evalContains(%q) error = %v, wantErr = %v", tt.expr, err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("evalContains(%q) = %v, want %v", tt.expr, got, tt.want)
-			}
-		})
-	}
-}
-
-func TestEvalIn(t *testing.T) {
-	env := PolicyEnv{
-		Subject:  map[string]string{"user_id": "u1"},
-		Resource: map[string]string{"id": "123"},
-	}
-	tests := []struct {
-		left    string
-		right   string
-		want    bool
-		wantErr bool
-	}{
-		{`subject.user_id`, `["u1", "u2"]`, true, false},
-		{`subject.user_id`, `["u3", "u4"]`, false, false},
-		{`resource.id`, `["123", "456"]`, true, false},
-		{`resource.id`, `"not-a-list"`, false, true},
-	}
-	for _, tt := range tests {
-		t.Run(tt.left+" in", func(t *testing.T) {
-			got, err := evalIn(tt.left, tt.right, env)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("evalIn() error = %v, wantErr = %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("evalIn() = %v, want %v", got, tt.want)
-			}
-		})
+	if roles, _ := resolveAttr([]string{"subject", "roles"}, env); len(roles.([]any)) != 2 {
+		t.Errorf("expected []string to normalize to list, got %v", roles)
 	}
 }
 
 func TestEvalExpression(t *testing.T) {
 	env := PolicyEnv{
-		Subject:  map[string]string{"user_id": "u1", "roles": "admin,user"},
-		Resource: map[string]string{"type": "order", "id": "123", "owner_id": "u1"},
+		Subject:  map[string]any{"user_id": "u1", "roles": []any{"admin", "user"}, "level": 3},
+		Resource: map[string]any{"type": "order", "id": "123", "owner_id": "u1", "amount": "250.5"},
+		Request:  map[string]any{"mfa_level": float64(2)},
 	}
 	tests := []struct {
 		expr    string
@@ -227,30 +108,52 @@ func TestEvalExpression(t *testing.T) {
 		// Simple equality
 		{`subject.user_id == resource.owner_id`, true, false},
 		{`subject.user_id != resource.owner_id`, false, false},
-		// Contains
+		// Contains (function and infix forms)
 		{`contains(subject.roles, "admin")`, true, false},
 		{`contains(subject.roles, "superadmin")`, false, false},
+		{`subject.roles contains "user"`, true, false},
+		{`contains(resource.type, "ord")`, true, false},
 		// In
 		{`resource.type in ["order", "invoice"]`, true, false},
 		{`resource.type in ["document", "note"]`, false, false},
-		// AND
+		{`resource.type not in ["document", "note"]`, true, false},
+		// AND / OR / NOT, including keyword forms
 		{`subject.user_id == resource.owner_id && resource.type == "order"`, true, false},
-		{`subject.user_id == resource.owner_id && resource.type == "document"`, false, false},
-		// OR
+		{`subject.user_id == resource.owner_id and resource.type == "document"`, false, false},
 		{`resource.type == "order" || resource.type == "document"`, true, false},
-		{`resource.type == "invoice" || resource.type == "document"`, false, false},
-		// NOT
+		{`resource.type == "invoice" or resource.type == "document"`, false, false},
 		{`!contains(subject.roles, "guest")`, true, false},
-		// Parenthesized
-		{`(subject.user_id == resource.owner_id)`, true, false},
-		// AND with parenthesized OR
+		{`not (resource.type == "order")`, false, false},
+		// Precedence: && binds tighter than ||
+		{`resource.type == "x" && false || true`, true, false},
 		{`resource.type == "order" && (subject.user_id == resource.owner_id || contains(subject.roles, "admin"))`, true, false},
-		// Empty expression (matches everything)
+		// Typed values: numbers compare numerically, numeric strings coerce
+		{`subject.level >= 3`, true, false},
+		{`subject.level > 10`, false, false},
+		{`resource.amount > 100`, true, false},
+		{`resource.amount == 250.5`, true, false},
+		{`request.mfa_level >= 2`, true, false},
+		{`len(subject.roles) == 2`, true, false},
+		// Missing attributes are null and never satisfy ordering
+		{`resource.missing == null`, true, false},
+		{`resource.missing > 1`, false, false},
+		// Literals
 		{``, true, false},
-		// Literal comparison
-		{`"admin" == "admin"`, true, false},
+		{`true`, true, false},
 		{`"admin" == "user"`, false, false},
-		{`true`, false, true}, // unsupported
+		// String helpers
+		{`startsWith(resource.id, "12") && endsWith(resource.id, "3")`, true, false},
+		{`resource.id matches "^[0-9]+$"`, true, false},
+		{`upper(resource.type) == "ORDER"`, true, false},
+		// Errors
+		{`resource.type`, false, true},               // not a boolean
+		{`subject.roles > 1`, false, true},           // cannot order a list
+		{`resource.type == "order" &&`, false, true}, // incomplete
+		{`unknown.attr == 1`, false, true},           // unknown namespace
+		{`nosuchfn(subject.user_id)`, false, true},   // unknown function
+		{`resource.id matches "("`, false, true},     // bad regexp
+		{`contains(subject.roles)`, false, true},     // arity
+		{`subject.user_id == "unterminated`, false, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.expr, func(t *testing.T) {
@@ -266,6 +169,50 @@ func TestEvalExpression(t *testing.T) {
 	}
 }
 
+func TestPolicyNetworkAndTimeFunctions(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	env := PolicyEnv{
+		Request: map[string]any{
+			"ip":   "192.168.1.20",
+			"time": time.Date(2026, 3, 4, 23, 15, 0, 0, shanghai), // Wednesday
+		},
+		Env: map[string]any{"office_cidrs": []string{"10.0.0.0/8", "192.168.0.0/16"}},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`ipInRange(request.ip, "192.168.0.0/16")`, true},
+		{`ipInRange(request.ip, "10.0.0.0/8", "172.16.0.0/12")`, false},
+		{`ipInRange(request.ip, env.office_cidrs)`, true},
+		{`ipInRange(request.ip, "192.168.1.20")`, true},
+		{`ipInRange(request.missing, "0.0.0.0/0")`, false},
+		{`ipInRange("::ffff:10.1.2.3", "10.0.0.0/8")`, true},
+		{`timeBetween(request.time, "09:00", "18:00")`, false},
+		{`timeBetween(request.time, "22:00", "06:00")`, true},
+		{`timeBetween(request.time, "09:00", "18:00", "America/New_York")`, true},
+		{`hour(now()) == 23 && weekday(now()) == 3`, true},
+		{`weekday(request.time) in [0, 6]`, false},
+		{`request.time > time("2026-03-01")`, true},
+		{`request.time < "2026-01-01T00:00:00Z"`, false},
+	}
+	for _, tt := range tests {
+		got, err := evalExpression(tt.expr, env)
+		if err != nil || got != tt.want {
+			t.Errorf("evalExpression(%q) = %v, %v, want %v", tt.expr, got, err, tt.want)
+		}
+	}
+	if _, err := evalExpression(`ipInRange(request.ip, "not-a-cidr/8")`, env); err == nil {
+		t.Error("expected invalid CIDR to fail")
+	}
+	if _, err := evalExpression(`timeBetween(request.time, "9am", "18:00")`, env); err == nil {
+		t.Error("expected invalid clock to fail")
+	}
+}
+
 func TestMatchResourceAction(t *testing.T) {
 	tests := []struct {
 		name string
@@ -290,22 +237,118 @@ func TestMatchResourceAction(t *testing.T) {
 	}
 }
 
-func TestSplitFuncArgs(t *testing.T) {
-	tests := []struct {
-		input string
-		want  int
-	}{
-		{`subject.roles, "admin"`, 2},
-		{`a, b, c`, 3},
-		{`"hello, world", x`, 2},
-		{`single`, 1},
+func TestPolicyProgramCache(t *testing.T) {
+	s := &PolicyService{}
+	p1, err := s.program(`subject.user_id == "u1"`)
+	if err != nil {
+		t.Fatal(err)
 	}
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			args := splitFuncArgs(tt.input)
-			if len(args) != tt.want {
-				t.Errorf("splitFuncArgs(%q) = %d args, want %d", tt.input, len(args), tt.want)
-			}
-		})
+	p2, _ := s.program(`subject.user_id == "u1"`)
+	if p1 != p2 {
+		t.Fatal("expected compiled program to be cached")
+	}
+	if err := s.ValidateExpression(`subject.user_id ==`); err == nil {
+		t.Fatal("expected syntax error")
+	}
+}
+
+func newPolicyTestManager(t *testing.T) (*Manager, *Principal) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "policy.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testAuthConfig()
+	cfg.DB = db
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := m.Bootstrap(ctx); err != nil {
+		t.Fatal(err)
+	}
+	user := &User{ID: newID(), TenantID: "default", Username: "bob", Status: UserStatusNormal}
+	role := &Role{ID: newID(), TenantID: "default", Code: "accountant", Name: "Accountant", Status: "enabled"}
+	perm := &Permission{ID: newID(), TenantID: "default", Code: "invoice:approve", ResourceType: "invoice", Action: "approve", Status: "enabled"}
+	for _, v := range []any{user, role, perm,
+		&UserRole{ID: newID(), TenantID: "default", UserID: user.ID, RoleID: role.ID, ScopeType: "tenant", ScopeID: "default"},
+		&RolePermission{ID: newID(), RoleID: role.ID, PermissionID: perm.ID},
+	} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m, &Principal{UserID: user.ID, TenantID: "default", Username: "bob", RolesVersion: 1, Roles: []string{"accountant"}}
+}
+
+func TestAuthorizerExplain(t *testing.T) {
+	m, p := newPolicyTestManager(t)
+	ctx := context.Background()
+	office := &AuthzContext{ClientIP: "10.1.2.3", MFALevel: 2}
+
+	if _, err := m.Policies().CreatePolicy(ctx, CreatePolicyRequest{Code: "bad", Expression: `subject.user_id ==`}); err == nil {
+		t.Fatal("expected invalid expression to be rejected on save")
+	}
+	if _, err := m.Policies().CreatePolicy(ctx, CreatePolicyRequest{
+		Code: "approve-from-office", Effect: "deny", ResourceType: "invoice", Action: "approve", Priority: 10,
+		Expression: `!ipInRange(request.ip, "10.0.0.0/8") || request.mfa_level < 2`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Policies().CreatePolicy(ctx, CreatePolicyRequest{
+		Code: "small-refunds", ResourceType: "refund", Action: "issue",
+		Expression: `resource.amount <= 100`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// RBAC grants, deny policy does not match from the office with MFA
+	req := AuthzRequest{Subject: p, Permission: "invoice:approve", ResourceType: "invoice", Context: office}
+	ex, err := m.Authorizer().Explain(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ex.Allowed || ex.DecidedBy != AuthzByRole || len(ex.GrantingRoles) != 1 || ex.GrantingRoles[0].RoleCode != "accountant" {
+		t.Fatalf("expected role grant, got %+v", ex)
+	}
+	if len(ex.Policies) != 2 || !ex.Policies[0].Applicable || ex.Policies[0].Matched || ex.Policies[1].Applicable {
+		t.Fatalf("unexpected policy trace: %+v", ex.Policies)
+	}
+
+	// Same request from outside the office is denied by policy even though a role grants it
+	req.Context = &AuthzContext{ClientIP: "203.0.113.9", MFALevel: 2}
+	ex, err = m.Authorizer().Explain(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Allowed || ex.DecidedBy != AuthzByPolicyDeny || ex.DecidingPolicy != "approve-from-office" || len(ex.GrantingRoles) != 1 {
+		t.Fatalf("expected policy deny with role still listed, got %+v", ex)
+	}
+	if d, _ := m.Authorizer().Check(ctx, req); d.Allowed {
+		t.Fatal("Check must agree with Explain")
+	}
+
+	// Policy allow without any role
+	refund := AuthzRequest{Subject: p, Permission: "refund:issue", ResourceType: "refund", ResourceAttrs: map[string]any{"amount": 80}}
+	ex, err = m.Authorizer().Explain(ctx, refund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ex.Allowed || ex.DecidedBy != AuthzByPolicyAllow || ex.DecidingPolicy != "small-refunds" || len(ex.GrantingRoles) != 0 {
+		t.Fatalf("expected policy allow, got %+v", ex)
+	}
+	refund.ResourceAttrs["amount"] = 500
+	decisions, err := m.Authorizer().CheckMany(ctx, []AuthzRequest{refund, req})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decisions[0].Allowed || decisions[1].Allowed {
+		t.Fatalf("expected both denied, got %+v", decisions)
+	}
+
+	ex, err = m.Authorizer().ExplainForUser(ctx, p.UserID, AuthzRequest{Permission: "invoice:approve", ResourceType: "invoice", Context: office})
+	if err != nil || !ex.Allowed || len(ex.SubjectRoles) != 1 {
+		t.Fatalf("ExplainForUser = %+v, %v", ex, err)
 	}
 }
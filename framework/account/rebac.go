@@ -0,0 +1,759 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+)
+
+// ReBACConfig 关系型授权（Zanzibar 风格的关系元组）参数。Namespaces 为空表示不启用。
+type ReBACConfig struct {
+	// Namespaces 对象类型 → 命名空间定义，如 "document"、"folder"、"group"。
+	Namespaces map[string]ReBACNamespace
+	// Store 元组存储，nil 时使用账户数据库（acct_relation_tuples）。
+	Store TupleStore
+	// MaxDepth 单次检查沿用户集 / 父对象递归的最大深度，默认 16。
+	MaxDepth int
+	// CacheTTL Check 结果在 Config.Cache 中的缓存时长，默认 30 秒；写入或删除元组后该租户缓存整体失效。
+	CacheTTL time.Duration
+	// MaxListCandidates ListObjects 反向遍历访问的最大节点数，默认 10000。
+	MaxListCandidates int
+}
+
+// ReBACNamespace 一个对象类型的关系定义。
+type ReBACNamespace struct {
+	// Relations 关系名 → 改写表达式，空表达式等价于 "this"。语法：
+	//
+	//	this               直接写入该关系的元组
+	//	editor             同一对象上另一关系的主体（computed userset）
+	//	parent->viewer     沿 parent 关系找到的对象上的 viewer 主体（tuple to userset）
+	//	a | b   a & b   a - b   并集 / 交集 / 差集，同级左结合，可用括号
+	Relations map[string]string `json:"relations"`
+	// Permissions 权限码 → 关系名。Authorizer 收到带 ResourceType/ResourceID 的请求时据此查元组；
+	// 未映射的权限码若与关系同名也直接使用该关系。
+	Permissions map[string]string `json:"permissions"`
+}
+
+const (
+	defaultReBACMaxDepth          = 16
+	defaultReBACCacheTTL          = 30 * time.Second
+	defaultReBACMaxListCandidates = 10000
+)
+
+// withDefaults 填充 ReBAC 默认值。
+func (c ReBACConfig) withDefaults() ReBACConfig {
+	if c.MaxDepth <= 0 {
+		c.MaxDepth = defaultReBACMaxDepth
+	}
+	if c.CacheTTL == 0 {
+		c.CacheTTL = defaultReBACCacheTTL
+	}
+	if c.MaxListCandidates <= 0 {
+		c.MaxListCandidates = defaultReBACMaxListCandidates
+	}
+	return c
+}
+
+// ============================================================================
+// 改写表达式
+// ============================================================================
+
+const (
+	rewriteThis         = "this"
+	rewriteComputed     = "computed_userset"
+	rewriteTupleToUsers = "tuple_to_userset"
+	rewriteUnion        = "union"
+	rewriteIntersection = "intersection"
+	rewriteExclusion    = "exclusion"
+)
+
+// rewriteNode 编译后的关系改写树。
+type rewriteNode struct {
+	op       string
+	relation string // computed_userset / tuple_to_userset 的目标关系
+	tupleset string // tuple_to_userset 的中间关系
+	children []*rewriteNode
+}
+
+// rebacNamespace 编译后的命名空间。
+type rebacNamespace struct {
+	relations   map[string]*rewriteNode
+	permissions map[string]string
+}
+
+// compileReBACNamespaces 编译并交叉校验全部命名空间定义。
+func compileReBACNamespaces(defs map[string]ReBACNamespace) (map[string]*rebacNamespace, error) {
+	out := make(map[string]*rebacNamespace, len(defs))
+	for typ, def := range defs {
+		if !rebacNamePattern.MatchString(typ) {
+			return nil, fmt.Errorf("rebac namespace %q: invalid name", typ)
+		}
+		ns := &rebacNamespace{relations: make(map[string]*rewriteNode, len(def.Relations)), permissions: def.Permissions}
+		for rel, expr := range def.Relations {
+			if !rebacNamePattern.MatchString(rel) || rel == rewriteThis {
+				return nil, fmt.Errorf("rebac namespace %q: invalid relation name %q", typ, rel)
+			}
+			node, err := parseRewrite(expr)
+			if err != nil {
+				return nil, fmt.Errorf("rebac %s#%s: %w", typ, rel, err)
+			}
+			ns.relations[rel] = node
+		}
+		out[typ] = ns
+	}
+	for typ, ns := range out {
+		for rel, node := range ns.relations {
+			if err := checkRewriteRefs(node, ns); err != nil {
+				return nil, fmt.Errorf("rebac %s#%s: %w", typ, rel, err)
+			}
+		}
+		for perm, rel := range ns.permissions {
+			if _, ok := ns.relations[rel]; !ok {
+				return nil, fmt.Errorf("rebac namespace %q: permission %q maps to undefined relation %q", typ, perm, rel)
+			}
+		}
+	}
+	return out, nil
+}
+
+// checkRewriteRefs 校验改写树引用的关系在本命名空间中存在。
+// tuple_to_userset 的目标关系属于被指向对象的命名空间，运行时按对象类型解析。
+func checkRewriteRefs(node *rewriteNode, ns *rebacNamespace) error {
+	switch node.op {
+	case rewriteComputed:
+		if _, ok := ns.relations[node.relation]; !ok {
+			return fmt.Errorf("undefined relation %q", node.relation)
+		}
+	case rewriteTupleToUsers:
+		if _, ok := ns.relations[node.tupleset]; !ok {
+			return fmt.Errorf("undefined relation %q", node.tupleset)
+		}
+	}
+	for _, c := range node.children {
+		if err := checkRewriteRefs(c, ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRewrite 解析改写表达式，见 ReBACNamespace.Relations。
+func parseRewrite(expr string) (*rewriteNode, error) {
+	p := &rewriteParser{tokens: tokenizeRewrite(expr)}
+	if len(p.tokens) == 0 {
+		return &rewriteNode{op: rewriteThis}, nil
+	}
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+func tokenizeRewrite(expr string) []string {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(expr) && expr[i+1] == '>':
+			tokens = append(tokens, "->")
+			i += 2
+		case strings.IndexByte("|&-()", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			j := i
+			for j < len(expr) && strings.IndexByte(" \t\n\r|&-()", expr[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+type rewriteParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *rewriteParser) parseExpr() (*rewriteNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) {
+		var op string
+		switch p.tokens[p.pos] {
+		case "|":
+			op = rewriteUnion
+		case "&":
+			op = rewriteIntersection
+		case "-":
+			op = rewriteExclusion
+		default:
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if op != rewriteExclusion && left.op == op {
+			left.children = append(left.children, right)
+		} else {
+			left = &rewriteNode{op: op, children: []*rewriteNode{left, right}}
+		}
+	}
+	return left, nil
+}
+
+func (p *rewriteParser) parseTerm() (*rewriteNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	if tok == "(" {
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos] != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return node, nil
+	}
+	if !rebacNamePattern.MatchString(tok) {
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	if p.pos < len(p.tokens) && p.tokens[p.pos] == "->" {
+		p.pos++
+		if p.pos >= len(p.tokens) || !rebacNamePattern.MatchString(p.tokens[p.pos]) {
+			return nil, fmt.Errorf("%s-> must be followed by a relation", tok)
+		}
+		target := p.tokens[p.pos]
+		p.pos++
+		return &rewriteNode{op: rewriteTupleToUsers, tupleset: tok, relation: target}, nil
+	}
+	if tok == rewriteThis {
+		return &rewriteNode{op: rewriteThis}, nil
+	}
+	return &rewriteNode{op: rewriteComputed, relation: tok}, nil
+}
+
+// ============================================================================
+// ReBACService
+// ============================================================================
+
+// ReBACService 关系型授权：维护关系元组，并按命名空间的改写规则回答
+// "subject 是否对 object 拥有 relation"（Check）、"subject 对哪些对象拥有 relation"（ListObjects）
+// 以及 "谁对 object 拥有 relation"（Expand）。
+type ReBACService struct {
+	m          *Manager
+	store      TupleStore
+	namespaces map[string]*rebacNamespace
+	cfg        ReBACConfig
+}
+
+// newReBACService 编译命名空间并选择元组存储。配置已在 Config.validate 中校验过。
+func newReBACService(m *Manager) *ReBACService {
+	cfg := m.cfg.ReBAC.withDefaults()
+	namespaces, err := compileReBACNamespaces(cfg.Namespaces)
+	if err != nil {
+		gaia.ErrorF("[account] rebac namespaces invalid, rebac disabled: %v", err)
+		namespaces = nil
+	}
+	store := cfg.Store
+	if store == nil {
+		store = NewDBTupleStore(m.db)
+	}
+	return &ReBACService{m: m, store: store, namespaces: namespaces, cfg: cfg}
+}
+
+// Enabled 报告是否配置了 ReBAC 命名空间。
+func (s *ReBACService) Enabled() bool {
+	return len(s.namespaces) > 0
+}
+
+// WriteTuples 写入关系元组。对象类型与关系须在命名空间中定义。
+func (s *ReBACService) WriteTuples(ctx context.Context, tenantID string, tuples ...RelationTuple) error {
+	ctx, span := s.m.tracer.Start(ctx, "account.rebac.write")
+	defer span.End()
+
+	if err := s.validateTuples(tuples); err != nil {
+		return err
+	}
+	tenantID = s.m.tenantID(tenantID)
+	if err := s.store.Write(ctx, tenantID, tuples); err != nil {
+		return err
+	}
+	s.bumpVersion(ctx, tenantID)
+	return nil
+}
+
+// DeleteTuples 删除关系元组，不存在的元组被忽略。
+func (s *ReBACService) DeleteTuples(ctx context.Context, tenantID string, tuples ...RelationTuple) error {
+	ctx, span := s.m.tracer.Start(ctx, "account.rebac.delete")
+	defer span.End()
+
+	if err := s.validateTuples(tuples); err != nil {
+		return err
+	}
+	tenantID = s.m.tenantID(tenantID)
+	if err := s.store.Delete(ctx, tenantID, tuples); err != nil {
+		return err
+	}
+	s.bumpVersion(ctx, tenantID)
+	return nil
+}
+
+// DeleteObject 删除对象上的全部元组以及以该对象为主体的元组，业务删除资源时调用。
+func (s *ReBACService) DeleteObject(ctx context.Context, tenantID string, object ObjectRef) error {
+	tenantID = s.m.tenantID(tenantID)
+	asObject, err := s.store.Read(ctx, tenantID, TupleFilter{ObjectType: object.Type, ObjectID: object.ID})
+	if err != nil {
+		return err
+	}
+	asSubject, err := s.store.Read(ctx, tenantID, TupleFilter{SubjectType: object.Type, SubjectID: object.ID})
+	if err != nil {
+		return err
+	}
+	if err := s.store.Delete(ctx, tenantID, append(asObject, asSubject...)); err != nil {
+		return err
+	}
+	s.bumpVersion(ctx, tenantID)
+	return nil
+}
+
+// ReadTuples 按条件查询元组，至少指定对象或主体之一。
+func (s *ReBACService) ReadTuples(ctx context.Context, tenantID string, filter TupleFilter) ([]RelationTuple, error) {
+	if !filter.hasObject() && !filter.hasSubject() {
+		return nil, accountError(ErrInvalidArgument, "须指定 object_type+object_id 或 subject_type+subject_id")
+	}
+	return s.store.Read(ctx, s.m.tenantID(tenantID), filter)
+}
+
+func (s *ReBACService) validateTuples(tuples []RelationTuple) error {
+	if !s.Enabled() {
+		return accountError(ErrInvalidArgument, "未启用 ReBAC")
+	}
+	for _, t := range tuples {
+		if err := t.validate(); err != nil {
+			return accountError(ErrInvalidArgument, err.Error())
+		}
+		ns, ok := s.namespaces[t.ObjectType]
+		if !ok {
+			return accountError(ErrInvalidArgument, "未定义的对象类型: "+t.ObjectType)
+		}
+		if _, ok := ns.relations[t.Relation]; !ok {
+			return accountError(ErrInvalidArgument, "未定义的关系: "+t.ObjectType+"#"+t.Relation)
+		}
+		if t.SubjectRelation != "" {
+			sns, ok := s.namespaces[t.SubjectType]
+			if !ok {
+				return accountError(ErrInvalidArgument, "未定义的对象类型: "+t.SubjectType)
+			}
+			if _, ok := sns.relations[t.SubjectRelation]; !ok {
+				return accountError(ErrInvalidArgument, "未定义的关系: "+t.SubjectType+"#"+t.SubjectRelation)
+			}
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// Check
+// ============================================================================
+
+// Check 判断 subject 是否对 object 拥有 relation。结果按租户版本缓存在 Config.Cache 中。
+func (s *ReBACService) Check(ctx context.Context, tenantID string, object ObjectRef, relation string, subject SubjectRef) (bool, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.rebac.check")
+	defer span.End()
+
+	if !s.Enabled() {
+		return false, accountError(ErrInvalidArgument, "未启用 ReBAC")
+	}
+	tenantID = s.m.tenantID(tenantID)
+	cacheKey := ""
+	if s.m.cache != nil && s.cfg.CacheTTL > 0 {
+		cacheKey = fmt.Sprintf("rebac:check:%s:%s:%s#%s@%s", tenantID, s.version(ctx, tenantID), object, relation, subject)
+		if v, ok, err := s.m.cache.Get(ctx, cacheKey); err == nil && ok {
+			return v == "1", nil
+		}
+	}
+	c := s.newChecker(ctx, tenantID, subject)
+	ok, err := c.check(object, relation, 0)
+	if err != nil {
+		return false, err
+	}
+	if cacheKey != "" {
+		v := "0"
+		if ok {
+			v = "1"
+		}
+		_ = s.m.cache.Set(ctx, cacheKey, v, s.cfg.CacheTTL)
+	}
+	return ok, nil
+}
+
+// rebacChecker 单次求值的状态：memo 记录已确认成立的 object#relation，visiting 记录求值路径上的节点
+// 用于截断环（环上的节点按不成立处理，因此只缓存成立的结果），tuples 缓存本次已读过的元组。
+type rebacChecker struct {
+	s        *ReBACService
+	ctx      context.Context
+	tenantID string
+	subject  SubjectRef
+	memo     map[string]bool
+	visiting map[string]bool
+	tuples   map[string][]RelationTuple
+}
+
+func (s *ReBACService) newChecker(ctx context.Context, tenantID string, subject SubjectRef) *rebacChecker {
+	return &rebacChecker{
+		s: s, ctx: ctx, tenantID: tenantID, subject: subject,
+		memo: map[string]bool{}, visiting: map[string]bool{}, tuples: map[string][]RelationTuple{},
+	}
+}
+
+func (c *rebacChecker) check(object ObjectRef, relation string, depth int) (bool, error) {
+	if c.subject.Relation != "" && c.subject.Type == object.Type && c.subject.ID == object.ID && c.subject.Relation == relation {
+		return true, nil
+	}
+	if depth > c.s.cfg.MaxDepth {
+		return false, fmt.Errorf("rebac check exceeded max depth %d at %s#%s", c.s.cfg.MaxDepth, object, relation)
+	}
+	ns, ok := c.s.namespaces[object.Type]
+	if !ok {
+		return false, nil
+	}
+	node, ok := ns.relations[relation]
+	if !ok {
+		return false, nil
+	}
+	key := object.String() + "#" + relation
+	if v, ok := c.memo[key]; ok {
+		return v, nil
+	}
+	if c.visiting[key] {
+		return false, nil
+	}
+	c.visiting[key] = true
+	v, err := c.eval(object, relation, node, depth)
+	delete(c.visiting, key)
+	if err != nil {
+		return false, err
+	}
+	if v {
+		c.memo[key] = true
+	}
+	return v, nil
+}
+
+func (c *rebacChecker) eval(object ObjectRef, relation string, node *rewriteNode, depth int) (bool, error) {
+	switch node.op {
+	case rewriteThis:
+		tuples, err := c.read(object, relation)
+		if err != nil {
+			return false, err
+		}
+		for _, t := range tuples {
+			if t.SubjectRelation == "" {
+				if c.subject.Relation == "" && t.SubjectType == c.subject.Type && (t.SubjectID == c.subject.ID || t.SubjectID == "*") {
+					return true, nil
+				}
+				continue
+			}
+			ok, err := c.check(ObjectRef{Type: t.SubjectType, ID: t.SubjectID}, t.SubjectRelation, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case rewriteComputed:
+		return c.check(object, node.relation, depth+1)
+	case rewriteTupleToUsers:
+		tuples, err := c.read(object, node.tupleset)
+		if err != nil {
+			return false, err
+		}
+		for _, t := range tuples {
+			ok, err := c.check(ObjectRef{Type: t.SubjectType, ID: t.SubjectID}, node.relation, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case rewriteUnion:
+		for _, child := range node.children {
+			ok, err := c.eval(object, relation, child, depth)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case rewriteIntersection:
+		for _, child := range node.children {
+			ok, err := c.eval(object, relation, child, depth)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case rewriteExclusion:
+		ok, err := c.eval(object, relation, node.children[0], depth)
+		if err != nil || !ok {
+			return false, err
+		}
+		excluded, err := c.eval(object, relation, node.children[1], depth)
+		return !excluded, err
+	}
+	return false, nil
+}
+
+func (c *rebacChecker) read(object ObjectRef, relation string) ([]RelationTuple, error) {
+	key := object.String() + "#" + relation
+	if tuples, ok := c.tuples[key]; ok {
+		return tuples, nil
+	}
+	tuples, err := c.s.store.Read(c.ctx, c.tenantID, TupleFilter{ObjectType: object.Type, ObjectID: object.ID, Relation: relation})
+	if err != nil {
+		return nil, err
+	}
+	c.tuples[key] = tuples
+	return tuples, nil
+}
+
+// version 读取租户的元组版本号，用于 Check 缓存键。
+func (s *ReBACService) version(ctx context.Context, tenantID string) string {
+	v, ok, err := s.m.cache.Get(ctx, "rebac:ver:"+tenantID)
+	if err != nil || !ok {
+		return "0"
+	}
+	return v
+}
+
+// bumpVersion 递增租户元组版本号，使该租户此前缓存的 Check 结果全部失效。
+// 版本键的有效期远长于 CacheTTL，过期重置时旧结果早已过期。
+func (s *ReBACService) bumpVersion(ctx context.Context, tenantID string) {
+	if s.m.cache == nil {
+		return
+	}
+	if _, err := s.m.cache.Increment(ctx, "rebac:ver:"+tenantID, 24*time.Hour); err != nil {
+		gaia.WarnF("[account] bump rebac version for tenant %s failed: %v", tenantID, err)
+	}
+}
+
+// ============================================================================
+// ListObjects
+// ============================================================================
+
+// ListObjects 返回 subject 拥有 relation 的全部 objectType 对象 ID（升序）。
+// 先从主体出发沿反向索引找出所有可能经元组到达主体的对象作为候选，再逐个 Check 精确判定；
+// 访问节点超过 MaxListCandidates 时返回错误，此时应改用业务侧分页 + Check。
+func (s *ReBACService) ListObjects(ctx context.Context, tenantID, objectType, relation string, subject SubjectRef) ([]string, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.rebac.list_objects")
+	defer span.End()
+
+	if !s.Enabled() {
+		return nil, accountError(ErrInvalidArgument, "未启用 ReBAC")
+	}
+	ns, ok := s.namespaces[objectType]
+	if !ok {
+		return nil, accountError(ErrInvalidArgument, "未定义的对象类型: "+objectType)
+	}
+	if _, ok := ns.relations[relation]; !ok {
+		return nil, accountError(ErrInvalidArgument, "未定义的关系: "+objectType+"#"+relation)
+	}
+	tenantID = s.m.tenantID(tenantID)
+
+	start := subject.Object()
+	queue := []ObjectRef{start}
+	if subject.Relation == "" {
+		queue = append(queue, ObjectRef{Type: subject.Type, ID: "*"})
+	}
+	seen := map[ObjectRef]bool{}
+	for _, o := range queue {
+		seen[o] = true
+	}
+	candidates := map[string]bool{}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		tuples, err := s.store.Read(ctx, tenantID, TupleFilter{SubjectType: cur.Type, SubjectID: cur.ID})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tuples {
+			o := t.Object()
+			if seen[o] {
+				continue
+			}
+			if len(seen) >= s.cfg.MaxListCandidates {
+				return nil, fmt.Errorf("rebac list objects exceeded %d candidates", s.cfg.MaxListCandidates)
+			}
+			seen[o] = true
+			queue = append(queue, o)
+			if o.Type == objectType {
+				candidates[o.ID] = true
+			}
+		}
+	}
+	if subject.Relation != "" && start.Type == objectType {
+		candidates[start.ID] = true
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	c := s.newChecker(ctx, tenantID, subject)
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		ok, err := c.check(ObjectRef{Type: objectType, ID: id}, relation, 0)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+// ============================================================================
+// Expand
+// ============================================================================
+
+// ExpandNode Expand 返回的用户集树。
+type ExpandNode struct {
+	// Operation this / union / intersection / exclusion / tuple_to_userset。
+	Operation string `json:"operation"`
+	// Object 节点对应的 type:id#relation；tuple_to_userset 节点为中间关系。
+	Object string `json:"object,omitempty"`
+	// Subjects this 节点上直接写入的主体。
+	Subjects []string      `json:"subjects,omitempty"`
+	Children []*ExpandNode `json:"children,omitempty"`
+}
+
+// Expand 展开 object#relation 的用户集树，用户集主体与父对象逐层展开至 MaxDepth。
+func (s *ReBACService) Expand(ctx context.Context, tenantID string, object ObjectRef, relation string) (*ExpandNode, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.rebac.expand")
+	defer span.End()
+
+	if !s.Enabled() {
+		return nil, accountError(ErrInvalidArgument, "未启用 ReBAC")
+	}
+	ns, ok := s.namespaces[object.Type]
+	if !ok {
+		return nil, accountError(ErrInvalidArgument, "未定义的对象类型: "+object.Type)
+	}
+	if _, ok := ns.relations[relation]; !ok {
+		return nil, accountError(ErrInvalidArgument, "未定义的关系: "+object.Type+"#"+relation)
+	}
+	c := s.newChecker(ctx, s.m.tenantID(tenantID), SubjectRef{})
+	return c.expand(object, relation, 0)
+}
+
+func (c *rebacChecker) expand(object ObjectRef, relation string, depth int) (*ExpandNode, error) {
+	if depth > c.s.cfg.MaxDepth {
+		return nil, fmt.Errorf("rebac expand exceeded max depth %d at %s#%s", c.s.cfg.MaxDepth, object, relation)
+	}
+	ns, ok := c.s.namespaces[object.Type]
+	if !ok {
+		return &ExpandNode{Operation: rewriteThis, Object: object.String() + "#" + relation}, nil
+	}
+	node, ok := ns.relations[relation]
+	if !ok {
+		return &ExpandNode{Operation: rewriteThis, Object: object.String() + "#" + relation}, nil
+	}
+	return c.expandRewrite(object, relation, node, depth)
+}
+
+func (c *rebacChecker) expandRewrite(object ObjectRef, relation string, node *rewriteNode, depth int) (*ExpandNode, error) {
+	switch node.op {
+	case rewriteThis:
+		tuples, err := c.read(object, relation)
+		if err != nil {
+			return nil, err
+		}
+		out := &ExpandNode{Operation: rewriteThis, Object: object.String() + "#" + relation}
+		for _, t := range tuples {
+			out.Subjects = append(out.Subjects, t.Subject().String())
+			if t.SubjectRelation != "" {
+				child, err := c.expand(t.Subject().Object(), t.SubjectRelation, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				out.Children = append(out.Children, child)
+			}
+		}
+		return out, nil
+	case rewriteComputed:
+		return c.expand(object, node.relation, depth+1)
+	case rewriteTupleToUsers:
+		tuples, err := c.read(object, node.tupleset)
+		if err != nil {
+			return nil, err
+		}
+		out := &ExpandNode{Operation: rewriteTupleToUsers, Object: object.String() + "#" + node.tupleset}
+		for _, t := range tuples {
+			child, err := c.expand(t.Subject().Object(), node.relation, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out.Children = append(out.Children, child)
+		}
+		return out, nil
+	default:
+		out := &ExpandNode{Operation: node.op, Object: object.String() + "#" + relation}
+		for _, child := range node.children {
+			n, err := c.expandRewrite(object, relation, child, depth)
+			if err != nil {
+				return nil, err
+			}
+			out.Children = append(out.Children, n)
+		}
+		return out, nil
+	}
+}
+
+// ============================================================================
+// 与 Authorizer 集成
+// ============================================================================
+
+// authzRelation 返回授权请求对应的 object 与关系；请求不带资源或命名空间未映射该权限时 ok 为 false。
+func (s *ReBACService) authzRelation(req AuthzRequest) (ObjectRef, string, bool) {
+	if !s.Enabled() || req.ResourceType == "" || req.ResourceID == "" {
+		return ObjectRef{}, "", false
+	}
+	ns, ok := s.namespaces[req.ResourceType]
+	if !ok {
+		return ObjectRef{}, "", false
+	}
+	relation := ns.permissions[req.Permission]
+	if relation == "" {
+		if _, ok := ns.relations[req.Permission]; !ok {
+			return ObjectRef{}, "", false
+		}
+		relation = req.Permission
+	}
+	return ObjectRef{Type: req.ResourceType, ID: req.ResourceID}, relation, true
+}
+
+// checkAuthz 以主体用户身份（user:<UserID>）检查授权请求对应的关系。
+func (s *ReBACService) checkAuthz(ctx context.Context, req AuthzRequest, object ObjectRef, relation string) (bool, error) {
+	return s.Check(ctx, req.Subject.TenantID, object, relation, SubjectRef{Type: "user", ID: req.Subject.UserID})
+}
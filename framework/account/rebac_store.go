@@ -0,0 +1,380 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	frameworkredis "github.com/xxzhwl/gaia/components/redis"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RelationTuple 关系元组 object#relation@subject，如 document:readme#viewer@user:alice
+// 或 document:readme#viewer@group:eng#member（SubjectRelation 非空时主体为用户集）。
+// SubjectID 为 "*" 表示该类型的全部主体（公开访问）。
+type RelationTuple struct {
+	ID              string    `json:"-" gorm:"size:36;primaryKey"`
+	TenantID        string    `json:"tenant_id" gorm:"size:64;not null;uniqueIndex:uniq_acct_relation_tuples,priority:1;index:idx_acct_relation_tuples_subject,priority:1"`
+	ObjectType      string    `json:"object_type" gorm:"size:64;not null;uniqueIndex:uniq_acct_relation_tuples,priority:2"`
+	ObjectID        string    `json:"object_id" gorm:"size:128;not null;uniqueIndex:uniq_acct_relation_tuples,priority:3"`
+	Relation        string    `json:"relation" gorm:"size:64;not null;uniqueIndex:uniq_acct_relation_tuples,priority:4"`
+	SubjectType     string    `json:"subject_type" gorm:"size:64;not null;uniqueIndex:uniq_acct_relation_tuples,priority:5;index:idx_acct_relation_tuples_subject,priority:2"`
+	SubjectID       string    `json:"subject_id" gorm:"size:128;not null;uniqueIndex:uniq_acct_relation_tuples,priority:6;index:idx_acct_relation_tuples_subject,priority:3"`
+	SubjectRelation string    `json:"subject_relation,omitempty" gorm:"size:64;not null;default:'';uniqueIndex:uniq_acct_relation_tuples,priority:7"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (RelationTuple) TableName() string { return "acct_relation_tuples" }
+
+// Object 返回元组的对象引用。
+func (t RelationTuple) Object() ObjectRef {
+	return ObjectRef{Type: t.ObjectType, ID: t.ObjectID}
+}
+
+// Subject 返回元组的主体引用。
+func (t RelationTuple) Subject() SubjectRef {
+	return SubjectRef{Type: t.SubjectType, ID: t.SubjectID, Relation: t.SubjectRelation}
+}
+
+// String 返回元组的文本形式 type:id#relation@subject。
+func (t RelationTuple) String() string {
+	return t.Object().String() + "#" + t.Relation + "@" + t.Subject().String()
+}
+
+// ObjectRef 对象引用 type:id。
+type ObjectRef struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func (o ObjectRef) String() string { return o.Type + ":" + o.ID }
+
+// SubjectRef 主体引用：用户 user:alice，或用户集 group:eng#member。
+type SubjectRef struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Relation string `json:"relation,omitempty"`
+}
+
+func (s SubjectRef) String() string {
+	if s.Relation == "" {
+		return s.Type + ":" + s.ID
+	}
+	return s.Type + ":" + s.ID + "#" + s.Relation
+}
+
+// Object 返回主体所在的对象；用户集 group:eng#member 对应 group:eng。
+func (s SubjectRef) Object() ObjectRef {
+	return ObjectRef{Type: s.Type, ID: s.ID}
+}
+
+var (
+	rebacNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	rebacIDPattern   = regexp.MustCompile(`^[^#@\s]{1,128}$`)
+)
+
+// ParseRelationTuple 解析 type:id#relation@subject 形式的元组文本。
+func ParseRelationTuple(s string) (RelationTuple, error) {
+	objPart, subjPart, ok := strings.Cut(strings.TrimSpace(s), "@")
+	if !ok {
+		return RelationTuple{}, fmt.Errorf("relation tuple %q: missing @subject", s)
+	}
+	obj, rel, ok := strings.Cut(objPart, "#")
+	if !ok {
+		return RelationTuple{}, fmt.Errorf("relation tuple %q: missing #relation", s)
+	}
+	o, err := ParseObjectRef(obj)
+	if err != nil {
+		return RelationTuple{}, err
+	}
+	subj, err := ParseSubjectRef(subjPart)
+	if err != nil {
+		return RelationTuple{}, err
+	}
+	t := RelationTuple{
+		ObjectType: o.Type, ObjectID: o.ID, Relation: rel,
+		SubjectType: subj.Type, SubjectID: subj.ID, SubjectRelation: subj.Relation,
+	}
+	return t, t.validate()
+}
+
+// ParseObjectRef 解析 type:id 形式的对象引用。
+func ParseObjectRef(s string) (ObjectRef, error) {
+	typ, id, ok := strings.Cut(s, ":")
+	if !ok || typ == "" || id == "" {
+		return ObjectRef{}, fmt.Errorf("object %q must be type:id", s)
+	}
+	return ObjectRef{Type: typ, ID: id}, nil
+}
+
+// ParseSubjectRef 解析 type:id 或 type:id#relation 形式的主体引用。
+func ParseSubjectRef(s string) (SubjectRef, error) {
+	obj, rel, _ := strings.Cut(s, "#")
+	o, err := ParseObjectRef(obj)
+	if err != nil {
+		return SubjectRef{}, fmt.Errorf("subject %q must be type:id or type:id#relation", s)
+	}
+	return SubjectRef{Type: o.Type, ID: o.ID, Relation: rel}, nil
+}
+
+// validate 校验元组各字段的格式（不校验命名空间定义）。
+func (t RelationTuple) validate() error {
+	for _, name := range []string{t.ObjectType, t.Relation, t.SubjectType} {
+		if !rebacNamePattern.MatchString(name) {
+			return fmt.Errorf("relation tuple %s: invalid name %q", t, name)
+		}
+	}
+	if t.SubjectRelation != "" && !rebacNamePattern.MatchString(t.SubjectRelation) {
+		return fmt.Errorf("relation tuple %s: invalid subject relation %q", t, t.SubjectRelation)
+	}
+	if !rebacIDPattern.MatchString(t.ObjectID) || t.ObjectID == "*" {
+		return fmt.Errorf("relation tuple %s: invalid object id", t)
+	}
+	if !rebacIDPattern.MatchString(t.SubjectID) {
+		return fmt.Errorf("relation tuple %s: invalid subject id", t)
+	}
+	if t.SubjectID == "*" && t.SubjectRelation != "" {
+		return fmt.Errorf("relation tuple %s: wildcard subject cannot have a relation", t)
+	}
+	return nil
+}
+
+// TupleFilter 元组查询条件，空字段不参与过滤。
+// 至少需要指定对象（ObjectType+ObjectID）或主体（SubjectType+SubjectID）之一。
+type TupleFilter struct {
+	ObjectType      string `json:"object_type"`
+	ObjectID        string `json:"object_id"`
+	Relation        string `json:"relation"`
+	SubjectType     string `json:"subject_type"`
+	SubjectID       string `json:"subject_id"`
+	SubjectRelation string `json:"subject_relation"`
+	// Limit 最多返回条数，0 表示不限。
+	Limit int `json:"limit"`
+}
+
+func (f TupleFilter) hasObject() bool  { return f.ObjectType != "" && f.ObjectID != "" }
+func (f TupleFilter) hasSubject() bool { return f.SubjectType != "" && f.SubjectID != "" }
+
+func (f TupleFilter) match(t RelationTuple) bool {
+	return (f.ObjectType == "" || f.ObjectType == t.ObjectType) &&
+		(f.ObjectID == "" || f.ObjectID == t.ObjectID) &&
+		(f.Relation == "" || f.Relation == t.Relation) &&
+		(f.SubjectType == "" || f.SubjectType == t.SubjectType) &&
+		(f.SubjectID == "" || f.SubjectID == t.SubjectID) &&
+		(f.SubjectRelation == "" || f.SubjectRelation == t.SubjectRelation)
+}
+
+var errTupleFilterUnbounded = errors.New("tuple filter requires object_type+object_id or subject_type+subject_id")
+
+// TupleStore 关系元组存储。写入与删除须幂等；实现须对不同租户严格隔离。
+type TupleStore interface {
+	Write(ctx context.Context, tenantID string, tuples []RelationTuple) error
+	Delete(ctx context.Context, tenantID string, tuples []RelationTuple) error
+	Read(ctx context.Context, tenantID string, filter TupleFilter) ([]RelationTuple, error)
+}
+
+// ============================================================================
+// MySQL（GORM）实现
+// ============================================================================
+
+// DBTupleStore 基于账户数据库 acct_relation_tuples 表的元组存储。
+type DBTupleStore struct {
+	db *gorm.DB
+}
+
+// NewDBTupleStore 使用给定的 GORM 连接创建元组存储。
+func NewDBTupleStore(db *gorm.DB) *DBTupleStore {
+	return &DBTupleStore{db: db}
+}
+
+// Write 写入元组，已存在的元组被忽略。
+func (s *DBTupleStore) Write(ctx context.Context, tenantID string, tuples []RelationTuple) error {
+	if len(tuples) == 0 {
+		return nil
+	}
+	rows := make([]RelationTuple, len(tuples))
+	for i, t := range tuples {
+		t.ID = newID()
+		t.TenantID = tenantID
+		rows[i] = t
+	}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return fmt.Errorf("write relation tuples: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除元组，不存在的元组被忽略。
+func (s *DBTupleStore) Delete(ctx context.Context, tenantID string, tuples []RelationTuple) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, t := range tuples {
+			if err := tx.Where("tenant_id = ? AND object_type = ? AND object_id = ? AND relation = ? AND subject_type = ? AND subject_id = ? AND subject_relation = ?",
+				tenantID, t.ObjectType, t.ObjectID, t.Relation, t.SubjectType, t.SubjectID, t.SubjectRelation).
+				Delete(&RelationTuple{}).Error; err != nil {
+				return fmt.Errorf("delete relation tuple %s: %w", t, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Read 按条件查询元组。
+func (s *DBTupleStore) Read(ctx context.Context, tenantID string, filter TupleFilter) ([]RelationTuple, error) {
+	if !filter.hasObject() && !filter.hasSubject() {
+		return nil, errTupleFilterUnbounded
+	}
+	q := s.db.WithContext(ctx).Where("tenant_id = ?", tenantID)
+	for col, v := range map[string]string{
+		"object_type": filter.ObjectType, "object_id": filter.ObjectID, "relation": filter.Relation,
+		"subject_type": filter.SubjectType, "subject_id": filter.SubjectID, "subject_relation": filter.SubjectRelation,
+	} {
+		if v != "" {
+			q = q.Where(col+" = ?", v)
+		}
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	var tuples []RelationTuple
+	if err := q.Order("object_type, object_id, relation, subject_type, subject_id, subject_relation").Find(&tuples).Error; err != nil {
+		return nil, fmt.Errorf("read relation tuples: %w", err)
+	}
+	return tuples, nil
+}
+
+// ============================================================================
+// Redis 实现
+// ============================================================================
+
+// RedisTupleStore 基于 Redis 集合的元组存储：
+//
+//	{prefix}{tenant}:o:{type}:{id}#{relation}  对象关系 → 主体集合
+//	{prefix}{tenant}:r:{type}:{id}             对象上出现过的关系名
+//	{prefix}{tenant}:s:{type}:{id}             主体（对象部分）→ 完整元组文本（反向索引）
+//
+// 元组无过期时间，Redis 需开启持久化；关系名索引删除元组后不回收，只影响按对象列出时的多余查询。
+type RedisTupleStore struct {
+	client *goredis.Client
+	prefix string
+}
+
+// NewRedisTupleStore 使用框架 Redis 客户端创建元组存储。prefix 为空时默认 "acct:rebac:"。
+func NewRedisTupleStore(client *frameworkredis.Client, prefix string) *RedisTupleStore {
+	if prefix == "" {
+		prefix = "acct:rebac:"
+	}
+	return &RedisTupleStore{client: client.GetCli(), prefix: prefix}
+}
+
+func (s *RedisTupleStore) objectKey(tenantID string, o ObjectRef, relation string) string {
+	return s.prefix + tenantID + ":o:" + o.String() + "#" + relation
+}
+
+func (s *RedisTupleStore) relationsKey(tenantID string, o ObjectRef) string {
+	return s.prefix + tenantID + ":r:" + o.String()
+}
+
+func (s *RedisTupleStore) subjectKey(tenantID string, o ObjectRef) string {
+	return s.prefix + tenantID + ":s:" + o.String()
+}
+
+// Write 写入元组，已存在的元组被忽略。
+func (s *RedisTupleStore) Write(ctx context.Context, tenantID string, tuples []RelationTuple) error {
+	if len(tuples) == 0 {
+		return nil
+	}
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, t := range tuples {
+			pipe.SAdd(ctx, s.objectKey(tenantID, t.Object(), t.Relation), t.Subject().String())
+			pipe.SAdd(ctx, s.relationsKey(tenantID, t.Object()), t.Relation)
+			pipe.SAdd(ctx, s.subjectKey(tenantID, t.Subject().Object()), t.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("write relation tuples: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除元组，不存在的元组被忽略。
+func (s *RedisTupleStore) Delete(ctx context.Context, tenantID string, tuples []RelationTuple) error {
+	if len(tuples) == 0 {
+		return nil
+	}
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, t := range tuples {
+			pipe.SRem(ctx, s.objectKey(tenantID, t.Object(), t.Relation), t.Subject().String())
+			pipe.SRem(ctx, s.subjectKey(tenantID, t.Subject().Object()), t.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete relation tuples: %w", err)
+	}
+	return nil
+}
+
+// Read 按条件查询元组。指定对象时走正向集合，否则走主体反向索引。
+func (s *RedisTupleStore) Read(ctx context.Context, tenantID string, filter TupleFilter) ([]RelationTuple, error) {
+	var tuples []RelationTuple
+	switch {
+	case filter.hasObject():
+		obj := ObjectRef{Type: filter.ObjectType, ID: filter.ObjectID}
+		relations := []string{filter.Relation}
+		if filter.Relation == "" {
+			var err error
+			if relations, err = s.client.SMembers(ctx, s.relationsKey(tenantID, obj)).Result(); err != nil {
+				return nil, fmt.Errorf("read relation tuples: %w", err)
+			}
+		}
+		for _, rel := range relations {
+			members, err := s.client.SMembers(ctx, s.objectKey(tenantID, obj, rel)).Result()
+			if err != nil {
+				return nil, fmt.Errorf("read relation tuples: %w", err)
+			}
+			for _, m := range members {
+				subj, err := ParseSubjectRef(m)
+				if err != nil {
+					continue
+				}
+				tuples = append(tuples, RelationTuple{
+					TenantID: tenantID, ObjectType: obj.Type, ObjectID: obj.ID, Relation: rel,
+					SubjectType: subj.Type, SubjectID: subj.ID, SubjectRelation: subj.Relation,
+				})
+			}
+		}
+	case filter.hasSubject():
+		members, err := s.client.SMembers(ctx, s.subjectKey(tenantID, ObjectRef{Type: filter.SubjectType, ID: filter.SubjectID})).Result()
+		if err != nil {
+			return nil, fmt.Errorf("read relation tuples: %w", err)
+		}
+		for _, m := range members {
+			t, err := ParseRelationTuple(m)
+			if err != nil {
+				continue
+			}
+			t.TenantID = tenantID
+			tuples = append(tuples, t)
+		}
+	default:
+		return nil, errTupleFilterUnbounded
+	}
+	sort.Slice(tuples, func(i, j int) bool { return tuples[i].String() < tuples[j].String() })
+	out := tuples[:0]
+	for _, t := range tuples {
+		if filter.match(t) {
+			out = append(out, t)
+			if filter.Limit > 0 && len(out) >= filter.Limit {
+				break
+			}
+		}
+	}
+	return out, nil
+}
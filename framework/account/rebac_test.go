@@ -0,0 +1,374 @@
+package account
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// rebacTestCache 进程内 Cache，用于验证 Check 缓存与失效。
+type rebacTestCache struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (c *rebacTestCache) Get(_ context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.data[key]
+	return v, ok, nil
+}
+
+func (c *rebacTestCache) Set(_ context.Context, key, value string, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = value
+	return nil
+}
+
+func (c *rebacTestCache) Del(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+	return nil
+}
+
+func (c *rebacTestCache) Increment(_ context.Context, key string, _ time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, _ := strconv.ParseInt(c.data[key], 10, 64)
+	n++
+	c.data[key] = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func rebacTestNamespaces() map[string]ReBACNamespace {
+	return map[string]ReBACNamespace{
+		"group": {Relations: map[string]string{"member": "this"}},
+		"folder": {Relations: map[string]string{
+			"parent": "",
+			"owner":  "this",
+			"viewer": "this | owner | parent->viewer",
+		}},
+		"document": {
+			Relations: map[string]string{
+				"parent": "this",
+				"owner":  "this",
+				"banned": "this",
+				"editor": "this | owner",
+				"viewer": "(this | editor | parent->viewer) - banned",
+			},
+			Permissions: map[string]string{"doc:read": "viewer", "doc:write": "editor"},
+		},
+	}
+}
+
+func newReBACTestManager(t *testing.T) (*Manager, *rebacTestCache) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "rebac.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &rebacTestCache{data: map[string]string{}}
+	cfg := testAuthConfig()
+	cfg.DB = db
+	cfg.Cache = cache
+	cfg.ReBAC = ReBACConfig{Namespaces: rebacTestNamespaces()}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Bootstrap(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return m, cache
+}
+
+func mustTuples(t *testing.T, raw ...string) []RelationTuple {
+	t.Helper()
+	out := make([]RelationTuple, len(raw))
+	for i, s := range raw {
+		tuple, err := ParseRelationTuple(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = tuple
+	}
+	return out
+}
+
+func TestParseRelationTuple(t *testing.T) {
+	for _, s := range []string{
+		"document:readme#viewer@user:alice",
+		"folder:root#viewer@group:eng#member",
+		"document:public#viewer@user:*",
+		"document:tenant:a/b#owner@user:u-1",
+	} {
+		tuple, err := ParseRelationTuple(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if tuple.String() != s {
+			t.Fatalf("round trip %q -> %q", s, tuple.String())
+		}
+	}
+	for _, s := range []string{
+		"document:readme#viewer",
+		"document:readme@user:alice",
+		"document#viewer@user:alice",
+		"Document:readme#viewer@user:alice",
+		"document:*#viewer@user:alice",
+		"document:readme#viewer@user:*#member",
+		"document:read me#viewer@user:alice",
+	} {
+		if _, err := ParseRelationTuple(s); err == nil {
+			t.Fatalf("%s: expected error", s)
+		}
+	}
+}
+
+func TestParseRewrite(t *testing.T) {
+	node, err := parseRewrite("(this | editor | parent->viewer) - banned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &rewriteNode{op: rewriteExclusion, children: []*rewriteNode{
+		{op: rewriteUnion, children: []*rewriteNode{
+			{op: rewriteThis},
+			{op: rewriteComputed, relation: "editor"},
+			{op: rewriteTupleToUsers, tupleset: "parent", relation: "viewer"},
+		}},
+		{op: rewriteComputed, relation: "banned"},
+	}}
+	if !reflect.DeepEqual(node, want) {
+		t.Fatalf("unexpected tree: %+v", node)
+	}
+	if node, _ := parseRewrite("  "); node.op != rewriteThis {
+		t.Fatalf("empty rewrite should be this, got %+v", node)
+	}
+	for _, expr := range []string{"this |", "(this", "parent->", "this viewer", "this + viewer", "Viewer"} {
+		if _, err := parseRewrite(expr); err == nil {
+			t.Fatalf("%q: expected error", expr)
+		}
+	}
+
+	bad := []map[string]ReBACNamespace{
+		{"doc": {Relations: map[string]string{"viewer": "editor"}}},
+		{"doc": {Relations: map[string]string{"viewer": "parent->viewer"}}},
+		{"doc": {Relations: map[string]string{"viewer": "this"}, Permissions: map[string]string{"doc:read": "reader"}}},
+		{"doc": {Relations: map[string]string{"this": ""}}},
+	}
+	for _, defs := range bad {
+		if _, err := compileReBACNamespaces(defs); err == nil {
+			t.Fatalf("%+v: expected compile error", defs)
+		}
+	}
+	if _, err := compileReBACNamespaces(rebacTestNamespaces()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReBACCheckListExpand(t *testing.T) {
+	m, _ := newReBACTestManager(t)
+	ctx := context.Background()
+	rb := m.ReBAC()
+	if err := rb.WriteTuples(ctx, "", mustTuples(t,
+		"group:eng#member@user:alice",
+		"group:eng#member@user:carol",
+		"folder:root#viewer@group:eng#member",
+		"document:spec#parent@folder:root",
+		"document:spec#owner@user:bob",
+		"document:spec#banned@user:carol",
+		"document:public#viewer@user:*",
+		"document:draft#editor@user:bob",
+	)...); err != nil {
+		t.Fatal(err)
+	}
+	// writes are idempotent
+	if err := rb.WriteTuples(ctx, "", mustTuples(t, "document:spec#owner@user:bob")...); err != nil {
+		t.Fatal(err)
+	}
+	if err := rb.WriteTuples(ctx, "", mustTuples(t, "document:spec#reader@user:bob")...); err == nil {
+		t.Fatal("expected undefined relation to be rejected")
+	}
+
+	spec := ObjectRef{Type: "document", ID: "spec"}
+	cases := []struct {
+		object   ObjectRef
+		relation string
+		subject  string
+		want     bool
+	}{
+		{spec, "viewer", "user:alice", true},  // parent->viewer via group userset
+		{spec, "editor", "user:alice", false}, // viewers are not editors
+		{spec, "editor", "user:bob", true},    // owner implies editor
+		{spec, "viewer", "user:bob", true},    // editor implies viewer
+		{spec, "viewer", "user:carol", false}, // banned is excluded despite group membership
+		{spec, "viewer", "group:eng#member", true},
+		{ObjectRef{Type: "document", ID: "public"}, "viewer", "user:dave", true},
+		{ObjectRef{Type: "document", ID: "public"}, "viewer", "group:eng#member", false},
+		{ObjectRef{Type: "document", ID: "missing"}, "viewer", "user:alice", false},
+	}
+	for _, tc := range cases {
+		subject, err := ParseSubjectRef(tc.subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := rb.Check(ctx, "", tc.object, tc.relation, subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s#%s@%s = %v, want %v", tc.object, tc.relation, tc.subject, got, tc.want)
+		}
+	}
+
+	for subject, want := range map[string][]string{
+		"user:alice": {"public", "spec"},
+		"user:bob":   {"draft", "public", "spec"},
+		"user:carol": {"public"},
+	} {
+		ids, err := rb.ListObjects(ctx, "", "document", "viewer", SubjectRef{Type: "user", ID: subject[len("user:"):]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("ListObjects(%s) = %v, want %v", subject, ids, want)
+		}
+	}
+
+	tree, err := rb.Expand(ctx, "", spec, "viewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Operation != rewriteExclusion || len(tree.Children) != 2 {
+		t.Fatalf("unexpected expand root: %+v", tree)
+	}
+	union := tree.Children[0]
+	if union.Operation != rewriteUnion || len(union.Children) != 3 {
+		t.Fatalf("unexpected union: %+v", union)
+	}
+	ttu := union.Children[2]
+	if ttu.Operation != rewriteTupleToUsers || ttu.Object != "document:spec#parent" || len(ttu.Children) != 1 {
+		t.Fatalf("unexpected tuple_to_userset: %+v", ttu)
+	}
+	if banned := tree.Children[1]; banned.Object != "document:spec#banned" || !reflect.DeepEqual(banned.Subjects, []string{"user:carol"}) {
+		t.Fatalf("unexpected banned node: %+v", banned)
+	}
+
+	// deleting the object removes tuples pointing at it as well
+	if err := rb.DeleteObject(ctx, "", ObjectRef{Type: "folder", ID: "root"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := rb.Check(ctx, "", spec, "viewer", SubjectRef{Type: "user", ID: "alice"}); ok {
+		t.Fatal("expected access to be gone after folder deletion")
+	}
+	left, err := rb.ReadTuples(ctx, "", TupleFilter{ObjectType: "document", ObjectID: "spec", Relation: "parent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Fatalf("expected parent tuple to be deleted, got %v", left)
+	}
+}
+
+func TestReBACCyclesAndTenants(t *testing.T) {
+	m, _ := newReBACTestManager(t)
+	ctx := context.Background()
+	rb := m.ReBAC()
+	if err := rb.WriteTuples(ctx, "", mustTuples(t,
+		"group:a#member@group:b#member",
+		"group:b#member@group:a#member",
+		"group:b#member@user:erin",
+	)...); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		subject string
+		want    bool
+	}{{"erin", true}, {"frank", false}} {
+		got, err := rb.Check(ctx, "", ObjectRef{Type: "group", ID: "a"}, "member", SubjectRef{Type: "user", ID: tc.subject})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Fatalf("cyclic membership for %s = %v, want %v", tc.subject, got, tc.want)
+		}
+	}
+	got, err := rb.Check(ctx, "other", ObjectRef{Type: "group", ID: "a"}, "member", SubjectRef{Type: "user", ID: "erin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got {
+		t.Fatal("tuples must not leak across tenants")
+	}
+}
+
+func TestReBACCheckCacheInvalidation(t *testing.T) {
+	m, cache := newReBACTestManager(t)
+	ctx := context.Background()
+	rb := m.ReBAC()
+	doc := ObjectRef{Type: "document", ID: "plan"}
+	alice := SubjectRef{Type: "user", ID: "alice"}
+
+	if ok, err := rb.Check(ctx, "", doc, "viewer", alice); err != nil || ok {
+		t.Fatalf("expected no access, got %v %v", ok, err)
+	}
+	if len(cache.data) == 0 {
+		t.Fatal("expected check result to be cached")
+	}
+	if err := rb.WriteTuples(ctx, "", mustTuples(t, "document:plan#viewer@user:alice")...); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := rb.Check(ctx, "", doc, "viewer", alice); err != nil || !ok {
+		t.Fatalf("write must invalidate cached denial, got %v %v", ok, err)
+	}
+	if err := rb.DeleteTuples(ctx, "", mustTuples(t, "document:plan#viewer@user:alice")...); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := rb.Check(ctx, "", doc, "viewer", alice); err != nil || ok {
+		t.Fatalf("delete must invalidate cached grant, got %v %v", ok, err)
+	}
+}
+
+func TestAuthorizerReBAC(t *testing.T) {
+	m, _ := newReBACTestManager(t)
+	ctx := context.Background()
+	if err := m.ReBAC().WriteTuples(ctx, "", mustTuples(t,
+		"document:spec#owner@user:u-bob",
+		"document:spec#viewer@user:u-alice",
+	)...); err != nil {
+		t.Fatal(err)
+	}
+	alice := &Principal{UserID: "u-alice", TenantID: "default", Username: "alice"}
+
+	ex, err := m.Authorizer().Explain(ctx, AuthzRequest{Subject: alice, Permission: "doc:read", ResourceType: "document", ResourceID: "spec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ex.Allowed || ex.DecidedBy != AuthzByRelation || ex.Relation != "document:spec#viewer" {
+		t.Fatalf("expected relation grant, got %+v", ex)
+	}
+	decisions, err := m.Authorizer().CheckMany(ctx, []AuthzRequest{
+		{Subject: alice, Permission: "doc:write", ResourceType: "document", ResourceID: "spec"},
+		{Subject: &Principal{UserID: "u-bob", TenantID: "default"}, Permission: "doc:write", ResourceType: "document", ResourceID: "spec"},
+		{Subject: alice, Permission: "viewer", ResourceType: "document", ResourceID: "spec"},
+		{Subject: alice, Permission: "doc:read"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{false, true, true, false} {
+		if decisions[i].Allowed != want {
+			t.Errorf("decision %d = %+v, want allowed=%v", i, decisions[i], want)
+		}
+	}
+}
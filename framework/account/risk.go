@@ -3,7 +3,9 @@ package account
 import (
 	"context"
 	"fmt"
+	"net/netip"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -23,6 +25,9 @@ type RiskAssessment struct {
 	Decision      RiskDecision
 	Reason        string
 	DelayDuration time.Duration
+	// Score 风险信号的加权总分，Signals 为命中的信号。
+	Score   float64
+	Signals []RiskSignalResult
 }
 
 // RiskConfig 风险控制阈值配置。
@@ -32,7 +37,21 @@ type RiskConfig struct {
 	FailureWindow           time.Duration
 	LockoutDuration         time.Duration
 	BlockDuration           time.Duration
-	EnableIPReputation      bool
+	// EnableIPReputation 启用恶意 IP 列表信号（BadIPs / BadIPListFile）。
+	EnableIPReputation bool
+	BadIPs             []string
+	BadIPListFile      string
+	// SignalWeights 各风险信号的权重（信号分值 × 权重 / 100 计入总分），未列出的自定义信号按 100 计。
+	SignalWeights map[string]float64
+	// ChallengeScore / BlockScore 总分达到阈值时分别要求额外验证（MFA）或拒绝登录。
+	ChallengeScore float64
+	BlockScore     float64
+	// MaxTravelSpeedKmh 两次登录之间换算的移动速度上限，超过视为不可能的旅行。
+	MaxTravelSpeedKmh float64
+	// TimeOfDayMinSamples 登录时段信号需要的最少历史登录次数，0 关闭该信号。
+	TimeOfDayMinSamples int
+	// DisableNewDeviceNotice 关闭新设备登录的邮件提醒（审计与事件仍会记录）。
+	DisableNewDeviceNotice bool
 }
 
 func defaultRiskConfig() RiskConfig {
@@ -43,15 +62,60 @@ func defaultRiskConfig() RiskConfig {
 		LockoutDuration:         30 * time.Minute,
 		BlockDuration:           5 * time.Minute,
 		EnableIPReputation:      false,
+		SignalWeights:           defaultRiskSignalWeights(),
+		ChallengeScore:          50,
+		BlockScore:              90,
+		MaxTravelSpeedKmh:       900,
+		TimeOfDayMinSamples:     10,
+	}
+}
+
+func defaultRiskSignalWeights() map[string]float64 {
+	return map[string]float64{
+		RiskSignalNewDevice:   30,
+		RiskSignalGeoVelocity: 60,
+		RiskSignalBadIP:       100,
+		RiskSignalTimeOfDay:   20,
 	}
 }
 
 type RiskService struct {
 	m *Manager
+
+	badIPOnce sync.Once
+	badIPs    []netip.Prefix
 }
 
-// Assess 在验证凭证之前评估登录尝试的风险。
+// Assess 在验证凭证之前评估登录尝试的风险，等同于只带租户、用户与 IP 的 AssessLogin。
 func (s *RiskService) Assess(ctx context.Context, tenantID, userID, ip string) (*RiskAssessment, error) {
+	return s.AssessLogin(ctx, &RiskContext{TenantID: tenantID, UserID: userID, IP: ip})
+}
+
+// AssessLogin 评估登录尝试的风险：先按锁定状态与失败计数做硬性判断，
+// 再运行风险信号流水线（新设备、地理速度、恶意 IP、登录时段及自定义信号），
+// 加权总分达到 BlockScore 拒绝、达到 ChallengeScore 要求额外验证。命中的信号逐条写入审计日志。
+func (s *RiskService) AssessLogin(ctx context.Context, rc *RiskContext) (*RiskAssessment, error) {
+	rc.TenantID = s.m.tenantID(rc.TenantID)
+	result, err := s.assessCounters(ctx, rc.TenantID, rc.UserID, rc.IP)
+	if err != nil || result.Decision != RiskAllow {
+		return result, err
+	}
+	s.prepare(rc)
+	score, hits := s.evaluateSignals(ctx, rc)
+	result.Score, result.Signals = score, hits
+	rcfg := s.m.cfg.Risk
+	switch {
+	case score >= rcfg.BlockScore:
+		result.Decision, result.Reason = RiskBlock, "登录存在风险，已被拒绝"
+	case score >= rcfg.ChallengeScore:
+		result.Decision, result.Reason = RiskChallenge, "登录异常，需要额外验证"
+	}
+	s.auditSignals(ctx, rc, result.Decision, hits)
+	return result, nil
+}
+
+// assessCounters 按账号锁定状态与失败计数器评估。
+func (s *RiskService) assessCounters(ctx context.Context, tenantID, userID, ip string) (*RiskAssessment, error) {
 	// 1. Check if account is already locked in DB
 	if userID != "" {
 		var user User
@@ -167,3 +231,36 @@ func (s *RiskService) RecordSuccess(ctx context.Context, tenantID, userID, ip st
 func (s *RiskService) failureKey(tenantID, dimension, value string) string {
 	return fmt.Sprintf("risk:fail:%s:%s:%s", tenantID, dimension, value)
 }
+
+// CheckVerificationSend 验证码下发的共享限流：同一目标每小时、同一 IP 每 10 分钟的下发次数，
+// 以及登录失败已达封禁阈值的 IP。计数使用原子 Increment，多实例共享同一缓存时全局生效；
+// 未配置缓存时退化为按数据库统计目标的小时下发次数。
+func (s *RiskService) CheckVerificationSend(ctx context.Context, tenantID, targetHash, purpose, ip string) error {
+	vc := s.m.cfg.Verification
+	if s.m.cache == nil {
+		var hourly int64
+		_ = s.m.db.WithContext(ctx).Model(&VerificationChallenge{}).
+			Where("tenant_id = ? AND target_hash = ? AND purpose = ? AND created_at > ?",
+				tenantID, targetHash, purpose, time.Now().Add(-time.Hour)).
+			Count(&hourly).Error
+		if hourly >= int64(vc.MaxPerTargetPerHour) {
+			return accountError(ErrRateLimited, "该账号验证码请求过于频繁，请稍后再试")
+		}
+		return nil
+	}
+	if ip != "" {
+		count, _, _ := s.m.cache.Get(ctx, s.failureKey(tenantID, "ip", ip))
+		if c, _ := strconv.Atoi(count); c >= s.m.cfg.Risk.MaxLoginFailuresPerIP {
+			return accountError(ErrRiskBlocked, "来自该IP的请求存在风险，请稍后再试")
+		}
+		n, err := s.m.cache.Increment(ctx, fmt.Sprintf("risk:vsend:ip:%s:%s", tenantID, ip), 10*time.Minute)
+		if err == nil && n > int64(vc.MaxPerIPPer10Min) {
+			return accountError(ErrRateLimited, "请求过于频繁，请稍后再试")
+		}
+	}
+	n, err := s.m.cache.Increment(ctx, fmt.Sprintf("risk:vsend:target:%s:%s:%s", tenantID, targetHash, purpose), time.Hour)
+	if err == nil && n > int64(vc.MaxPerTargetPerHour) {
+		return accountError(ErrRateLimited, "该账号验证码请求过于频繁，请稍后再试")
+	}
+	return nil
+}
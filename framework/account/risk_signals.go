@@ -0,0 +1,431 @@
+package account
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
+)
+
+// 内置风险信号名称，同时是 RiskConfig.SignalWeights 的键。
+const (
+	RiskSignalNewDevice   = "new_device"
+	RiskSignalGeoVelocity = "geo_velocity"
+	RiskSignalBadIP       = "bad_ip"
+	RiskSignalTimeOfDay   = "time_of_day"
+)
+
+// NotificationPurposeNewDevice 新设备登录提醒使用的模板用途。
+const NotificationPurposeNewDevice = "new_device_login"
+
+// RiskContext 一次登录尝试的上下文，由各风险信号共享。
+type RiskContext struct {
+	TenantID  string
+	UserID    string
+	IP        string
+	UserAgent string
+	// DeviceID 客户端持久化的设备标识（设备 Cookie 或 App 生成的 ID），为空时用 UserAgent 与 ClientHints 生成指纹。
+	DeviceID string
+	// ClientHints 客户端提示头，如 Sec-CH-UA / Sec-CH-UA-Platform / Sec-CH-UA-Mobile / Accept-Language，键不区分大小写。
+	ClientHints map[string]string
+	// Time 登录时间，为空时取当前时间。
+	Time time.Time
+
+	// Fingerprint 与 Location 在评估时计算，信号可直接使用。
+	Fingerprint string
+	Location    *GeoLocation
+}
+
+// RiskSignal 风险信号。Evaluate 返回 0~100 的分值，乘以 RiskConfig.SignalWeights 中的权重 / 100 后累加；
+// 未配置权重的信号按 100 计。返回 nil 表示信号不适用。
+type RiskSignal interface {
+	Name() string
+	Evaluate(ctx context.Context, rc *RiskContext) (*RiskSignalResult, error)
+}
+
+// RiskSignalResult 单个信号的评估结果。
+type RiskSignalResult struct {
+	Name   string  `json:"name"`
+	Score  int     `json:"score"`
+	Weight float64 `json:"weight"`
+	Detail string  `json:"detail"`
+}
+
+// KnownDevice 用户登录成功过的设备，用于新设备识别与地理速度检测。
+type KnownDevice struct {
+	ID          string    `json:"id" gorm:"size:36;primaryKey"`
+	TenantID    string    `json:"tenant_id" gorm:"size:64;not null;uniqueIndex:uniq_acct_known_device,priority:1"`
+	UserID      string    `json:"user_id" gorm:"size:36;not null;uniqueIndex:uniq_acct_known_device,priority:2"`
+	Fingerprint string    `json:"-" gorm:"size:64;not null;uniqueIndex:uniq_acct_known_device,priority:3"`
+	DeviceID    string    `json:"device_id" gorm:"size:128"`
+	UserAgent   string    `json:"user_agent" gorm:"size:512"`
+	LastIP      string    `json:"last_ip" gorm:"size:64"`
+	Country     string    `json:"country" gorm:"size:64"`
+	City        string    `json:"city" gorm:"size:64"`
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	LoginCount  int64     `json:"login_count" gorm:"not null;default:0"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" gorm:"index"`
+}
+
+func (KnownDevice) TableName() string { return "acct_known_devices" }
+
+// DeviceFingerprint 计算设备指纹：有设备标识时只用设备标识，否则用 UserAgent 与排序后的客户端提示。
+func DeviceFingerprint(deviceID, userAgent string, hints map[string]string) string {
+	if deviceID != "" {
+		return tokenHash("device:" + deviceID)
+	}
+	keys := make([]string, 0, len(hints))
+	for k := range hints {
+		keys = append(keys, strings.ToLower(k))
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("ua:" + userAgent)
+	for _, k := range keys {
+		b.WriteString("\n" + k + "=" + clientHint(hints, k))
+	}
+	return tokenHash(b.String())
+}
+
+func clientHint(hints map[string]string, key string) string {
+	for k, v := range hints {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// riskSignals 内置信号加上 Config.RiskSignals 中的自定义信号。
+func (s *RiskService) riskSignals() []RiskSignal {
+	signals := []RiskSignal{
+		newDeviceSignal{s: s},
+		geoVelocitySignal{s: s},
+		badIPSignal{s: s},
+		timeOfDaySignal{s: s},
+	}
+	return append(signals, s.m.cfg.RiskSignals...)
+}
+
+// evaluateSignals 运行信号流水线，返回加权总分与命中的信号（分值大于 0）。
+func (s *RiskService) evaluateSignals(ctx context.Context, rc *RiskContext) (float64, []RiskSignalResult) {
+	var total float64
+	var hits []RiskSignalResult
+	for _, signal := range s.riskSignals() {
+		res, err := signal.Evaluate(ctx, rc)
+		if err != nil {
+			gaia.WarnF("[account] risk signal %s failed: %v", signal.Name(), err)
+			continue
+		}
+		if res == nil || res.Score <= 0 {
+			continue
+		}
+		res.Name = signal.Name()
+		res.Weight = 100
+		if w, ok := s.m.cfg.Risk.SignalWeights[res.Name]; ok {
+			res.Weight = w
+		}
+		total += float64(res.Score) * res.Weight / 100
+		hits = append(hits, *res)
+	}
+	return total, hits
+}
+
+// prepare 补全评估时间、设备指纹与地理位置。
+func (s *RiskService) prepare(rc *RiskContext) {
+	if rc.Time.IsZero() {
+		rc.Time = time.Now()
+	}
+	if rc.Fingerprint == "" && (rc.DeviceID != "" || rc.UserAgent != "") {
+		rc.Fingerprint = DeviceFingerprint(rc.DeviceID, rc.UserAgent, rc.ClientHints)
+	}
+	if rc.Location == nil && rc.IP != "" && s.m.cfg.GeoIP != nil {
+		loc, err := s.m.cfg.GeoIP.Lookup(rc.IP)
+		if err != nil {
+			gaia.WarnF("[account] geoip lookup failed: ip=%s err=%v", rc.IP, err)
+		}
+		rc.Location = loc
+	}
+}
+
+// RecordLogin 在登录完成（已颁发令牌）后登记设备；首次出现的设备会写审计、发布
+// EventNewDeviceLogin 事件，并在未禁用时给用户已验证的邮箱发送提醒。用户的第一台设备不提醒。
+func (s *RiskService) RecordLogin(ctx context.Context, rc RiskContext) {
+	if rc.UserID == "" {
+		return
+	}
+	s.prepare(&rc)
+	if rc.Fingerprint == "" {
+		return
+	}
+	tenantID := s.m.tenantID(rc.TenantID)
+	db := s.m.db.WithContext(ctx)
+	updates := map[string]any{
+		"last_seen_at": rc.Time,
+		"last_ip":      rc.IP,
+		"user_agent":   truncateString(rc.UserAgent, 512),
+		"login_count":  gorm.Expr("login_count + 1"),
+	}
+	if loc := rc.Location; loc != nil {
+		updates["country"], updates["city"] = loc.Country, loc.City
+		updates["latitude"], updates["longitude"] = loc.Latitude, loc.Longitude
+	}
+	res := db.Model(&KnownDevice{}).Where("tenant_id = ? AND user_id = ? AND fingerprint = ?", tenantID, rc.UserID, rc.Fingerprint).Updates(updates)
+	if res.Error != nil {
+		recordDBError(ctx)
+		gaia.WarnF("[account] update known device failed: user_id=%s err=%v", rc.UserID, res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		return
+	}
+
+	var existing int64
+	_ = db.Model(&KnownDevice{}).Where("tenant_id = ? AND user_id = ?", tenantID, rc.UserID).Count(&existing).Error
+	device := &KnownDevice{
+		ID:          newID(),
+		TenantID:    tenantID,
+		UserID:      rc.UserID,
+		Fingerprint: rc.Fingerprint,
+		DeviceID:    rc.DeviceID,
+		UserAgent:   truncateString(rc.UserAgent, 512),
+		LastIP:      rc.IP,
+		LoginCount:  1,
+		FirstSeenAt: rc.Time,
+		LastSeenAt:  rc.Time,
+	}
+	if loc := rc.Location; loc != nil {
+		device.Country, device.City, device.Latitude, device.Longitude = loc.Country, loc.City, loc.Latitude, loc.Longitude
+	}
+	if err := db.Create(device).Error; err != nil {
+		// 并发登录时另一请求已登记同一设备
+		gaia.WarnF("[account] record known device failed: user_id=%s err=%v", rc.UserID, err)
+		return
+	}
+	if existing == 0 {
+		return
+	}
+
+	location := rc.Location.String()
+	s.m.audit(ctx, tenantID, rc.UserID, "new_device_login", "success", truncateString(strings.TrimSpace(location+" "+rc.UserAgent), 255), rc.IP, rc.UserAgent)
+	if err := emitOutbox(db, EventNewDeviceLogin, rc.UserID, map[string]any{
+		"user_id":      rc.UserID,
+		"tenant_id":    tenantID,
+		"device_id":    device.ID,
+		"ip":           rc.IP,
+		"location":     location,
+		"user_agent":   rc.UserAgent,
+		"logged_in_at": rc.Time,
+	}); err != nil {
+		gaia.WarnF("[account] emit new device login event failed: %v", err)
+	}
+	if !s.m.cfg.Risk.DisableNewDeviceNotice {
+		go s.notifyNewDevice(context.WithoutCancel(ctx), tenantID, rc, location)
+	}
+}
+
+// notifyNewDevice 给用户已验证的邮箱发送新设备登录提醒。
+func (s *RiskService) notifyNewDevice(ctx context.Context, tenantID string, rc RiskContext, location string) {
+	var user User
+	if err := s.m.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", rc.UserID, tenantID).First(&user).Error; err != nil {
+		return
+	}
+	if user.Email == nil || user.EmailVerifiedAt == nil {
+		return
+	}
+	if location == "" {
+		location = "-"
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	err := s.m.verification.sendNotice(ctx, tenantID, VerificationChannelEmail, *user.Email, NotificationPurposeNewDevice,
+		parseAcceptLanguage(clientHint(rc.ClientHints, "Accept-Language")), map[string]any{
+			"Username": user.Username,
+			"IP":       rc.IP,
+			"Location": location,
+			"Device":   rc.UserAgent,
+			"Time":     rc.Time.Format("2006-01-02 15:04:05 MST"),
+		})
+	if err != nil {
+		gaia.WarnF("[account] send new device notice failed: user_id=%s err=%v", rc.UserID, err)
+	}
+}
+
+// auditSignals 每个命中的信号写一条 risk_signal 审计，状态为最终决策。
+func (s *RiskService) auditSignals(ctx context.Context, rc *RiskContext, decision RiskDecision, hits []RiskSignalResult) {
+	for _, hit := range hits {
+		reason := fmt.Sprintf("%s score=%d weight=%g: %s", hit.Name, hit.Score, hit.Weight, hit.Detail)
+		s.m.audit(ctx, rc.TenantID, rc.UserID, "risk_signal", string(decision), truncateString(reason, 255), rc.IP, rc.UserAgent)
+	}
+}
+
+// newDeviceSignal 用户已有登记设备、而本次指纹未出现过时命中。
+type newDeviceSignal struct{ s *RiskService }
+
+func (newDeviceSignal) Name() string { return RiskSignalNewDevice }
+
+func (g newDeviceSignal) Evaluate(ctx context.Context, rc *RiskContext) (*RiskSignalResult, error) {
+	if rc.UserID == "" || rc.Fingerprint == "" {
+		return nil, nil
+	}
+	var devices []KnownDevice
+	if err := g.s.m.db.WithContext(ctx).Select("fingerprint").
+		Where("tenant_id = ? AND user_id = ?", rc.TenantID, rc.UserID).Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, nil
+	}
+	for _, d := range devices {
+		if d.Fingerprint == rc.Fingerprint {
+			return nil, nil
+		}
+	}
+	return &RiskSignalResult{Score: 100, Detail: "unrecognized device"}, nil
+}
+
+// geoVelocitySignal 与上一次登录的位置相比，移动速度超过 MaxTravelSpeedKmh 视为不可能的旅行；
+// 首次出现的国家按半分计。需要配置 GeoIP。
+type geoVelocitySignal struct{ s *RiskService }
+
+func (geoVelocitySignal) Name() string { return RiskSignalGeoVelocity }
+
+func (g geoVelocitySignal) Evaluate(ctx context.Context, rc *RiskContext) (*RiskSignalResult, error) {
+	loc := rc.Location
+	if rc.UserID == "" || loc == nil || loc.Country == "" {
+		return nil, nil
+	}
+	var devices []KnownDevice
+	if err := g.s.m.db.WithContext(ctx).Where("tenant_id = ? AND user_id = ? AND country <> ''", rc.TenantID, rc.UserID).
+		Order("last_seen_at DESC").Limit(20).Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, nil
+	}
+	last := devices[0]
+	if last.LastIP != rc.IP && (last.Latitude != 0 || last.Longitude != 0) && (loc.Latitude != 0 || loc.Longitude != 0) {
+		from := &GeoLocation{Latitude: last.Latitude, Longitude: last.Longitude}
+		km := distanceKm(from, loc)
+		hours := rc.Time.Sub(last.LastSeenAt).Hours()
+		if hours < 1.0/60 {
+			hours = 1.0 / 60
+		}
+		if speed := km / hours; km > 100 && speed > g.s.m.cfg.Risk.MaxTravelSpeedKmh {
+			return &RiskSignalResult{Score: 100, Detail: fmt.Sprintf("impossible travel %s -> %s: %.0f km in %.1f h", last.Country+" "+last.City, loc.String(), km, hours)}, nil
+		}
+	}
+	for _, d := range devices {
+		if strings.EqualFold(d.Country, loc.Country) {
+			return nil, nil
+		}
+	}
+	return &RiskSignalResult{Score: 50, Detail: "first login from " + loc.Country}, nil
+}
+
+// badIPSignal IP 命中已知恶意 IP 列表（RiskConfig.BadIPs 与 BadIPListFile）时命中，需开启 EnableIPReputation。
+type badIPSignal struct{ s *RiskService }
+
+func (badIPSignal) Name() string { return RiskSignalBadIP }
+
+func (g badIPSignal) Evaluate(_ context.Context, rc *RiskContext) (*RiskSignalResult, error) {
+	if !g.s.m.cfg.Risk.EnableIPReputation || rc.IP == "" {
+		return nil, nil
+	}
+	addr, err := netip.ParseAddr(rc.IP)
+	if err != nil {
+		return nil, nil
+	}
+	addr = addr.Unmap()
+	for _, prefix := range g.s.badIPPrefixes() {
+		if prefix.Contains(addr) {
+			return &RiskSignalResult{Score: 100, Detail: "ip listed in " + prefix.String()}, nil
+		}
+	}
+	return nil, nil
+}
+
+// badIPPrefixes 首次使用时解析恶意 IP 列表，文件每行一个 IP 或 CIDR，# 之后为注释。
+func (s *RiskService) badIPPrefixes() []netip.Prefix {
+	s.badIPOnce.Do(func() {
+		entries := append([]string{}, s.m.cfg.Risk.BadIPs...)
+		if path := s.m.cfg.Risk.BadIPListFile; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				gaia.ErrorF("[account] load bad ip list %s failed: %v", path, err)
+			}
+			scanner := bufio.NewScanner(bytes.NewReader(data))
+			for scanner.Scan() {
+				line, _, _ := strings.Cut(scanner.Text(), "#")
+				if line = strings.TrimSpace(line); line != "" {
+					entries = append(entries, line)
+				}
+			}
+		}
+		for _, entry := range entries {
+			prefix, err := parseIPOrPrefix(entry)
+			if err != nil {
+				gaia.WarnF("[account] invalid bad ip entry %q: %v", entry, err)
+				continue
+			}
+			s.badIPs = append(s.badIPs, prefix)
+		}
+	})
+	return s.badIPs
+}
+
+func parseIPOrPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// timeOfDaySignal 根据最近的成功登录（审计日志）判断当前小时是否异常：
+// 样本数达到 TimeOfDayMinSamples 且前后一小时内从未登录过时命中。
+type timeOfDaySignal struct{ s *RiskService }
+
+func (timeOfDaySignal) Name() string { return RiskSignalTimeOfDay }
+
+func (g timeOfDaySignal) Evaluate(ctx context.Context, rc *RiskContext) (*RiskSignalResult, error) {
+	minSamples := g.s.m.cfg.Risk.TimeOfDayMinSamples
+	if rc.UserID == "" || minSamples <= 0 {
+		return nil, nil
+	}
+	var times []time.Time
+	if err := g.s.m.db.WithContext(ctx).Model(&AuditLog{}).
+		Where("tenant_id = ? AND user_id = ? AND event = ? AND status = ?", rc.TenantID, rc.UserID, "login", "success").
+		Order("created_at DESC").Limit(100).Pluck("created_at", &times).Error; err != nil {
+		return nil, err
+	}
+	if len(times) < minSamples {
+		return nil, nil
+	}
+	hour := rc.Time.Local().Hour()
+	for _, t := range times {
+		diff := t.Local().Hour() - hour
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= 1 || diff >= 23 {
+			return nil, nil
+		}
+	}
+	return &RiskSignalResult{Score: 100, Detail: fmt.Sprintf("unusual login hour %02d:00", hour)}, nil
+}
@@ -0,0 +1,161 @@
+package account
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testGeoIPCSV = `# start,end,country,region,city,lat,lon
+1.0.0.0,1.0.0.255,CN,Beijing,Beijing,39.9042,116.4074
+8.8.4.0,8.8.8.255,US,New York,New York,40.7128,-74.0060
+2001:db8::,2001:db8::ffff,JP,Tokyo,Tokyo,35.6762,139.6503
+`
+
+type noticeSender struct {
+	ch chan *VerificationMessage
+}
+
+func (n noticeSender) Name() string { return "notice" }
+
+func (n noticeSender) Send(_ context.Context, msg *VerificationMessage) (string, error) {
+	n.ch <- msg
+	return "", nil
+}
+
+type constantSignal struct{ score int }
+
+func (constantSignal) Name() string { return "custom" }
+
+func (c constantSignal) Evaluate(context.Context, *RiskContext) (*RiskSignalResult, error) {
+	return &RiskSignalResult{Score: c.score, Detail: "custom rule"}, nil
+}
+
+func TestCSVGeoIPResolver(t *testing.T) {
+	geo, err := ParseCSVGeoIP(strings.NewReader(testGeoIPCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{"1.0.0.8": "Beijing", "8.8.8.8": "New York", "2001:db8::1": "Tokyo", "::ffff:1.0.0.1": "Beijing"}
+	for ip, city := range cases {
+		loc, _ := geo.Lookup(ip)
+		if loc == nil || loc.City != city {
+			t.Fatalf("lookup %s = %+v, want %s", ip, loc, city)
+		}
+	}
+	for _, ip := range []string{"1.0.1.0", "9.9.9.9", "not-an-ip"} {
+		if loc, _ := geo.Lookup(ip); loc != nil {
+			t.Fatalf("expected %s to be unknown, got %+v", ip, loc)
+		}
+	}
+	if _, err := ParseCSVGeoIP(strings.NewReader("1.0.0.9,1.0.0.1,CN\n")); err == nil {
+		t.Fatal("expected reversed range to be rejected")
+	}
+}
+
+func TestRiskSignalPipeline(t *testing.T) {
+	m, bob := newPolicyTestManager(t)
+	ctx := context.Background()
+	geo, err := ParseCSVGeoIP(strings.NewReader(testGeoIPCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notices := noticeSender{ch: make(chan *VerificationMessage, 4)}
+	m.cfg.GeoIP = geo
+	m.cfg.Risk.EnableIPReputation = true
+	m.cfg.Risk.BadIPs = []string{"203.0.113.0/24"}
+	m.cfg.Verification.Providers = map[string][]VerificationSender{VerificationChannelEmail: {notices}}
+	now := time.Now()
+	if err := m.db.Model(&User{}).Where("id = ?", bob.UserID).Updates(map[string]any{"email": "bob@example.com", "email_verified_at": now}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	assess := func(rc RiskContext) *RiskAssessment {
+		t.Helper()
+		rc.UserID = bob.UserID
+		res, err := m.risk.AssessLogin(ctx, &rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	laptop := RiskContext{IP: "1.0.0.8", UserAgent: "Firefox", DeviceID: "laptop", Time: now.Add(-3 * time.Hour)}
+	if res := assess(laptop); res.Decision != RiskAllow || len(res.Signals) != 0 {
+		t.Fatalf("first login should be allowed without signals: %+v", res)
+	}
+	laptop.UserID = bob.UserID
+	m.risk.RecordLogin(ctx, laptop)
+	select {
+	case <-notices.ch:
+		t.Fatal("first device must not trigger a new device notice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 新设备：命中但未达到挑战阈值，登录后发送提醒
+	phone := RiskContext{IP: "1.0.0.9", UserAgent: "Safari", ClientHints: map[string]string{"Sec-CH-UA-Mobile": "?1", "Accept-Language": "en-US,en;q=0.9"}, Time: now.Add(-2 * time.Hour)}
+	res := assess(phone)
+	if res.Decision != RiskAllow || len(res.Signals) != 1 || res.Signals[0].Name != RiskSignalNewDevice || res.Score != 30 {
+		t.Fatalf("unexpected new device assessment: %+v", res)
+	}
+	phone.UserID = bob.UserID
+	m.risk.RecordLogin(ctx, phone)
+	select {
+	case msg := <-notices.ch:
+		if msg.Target != "bob@example.com" || msg.Purpose != NotificationPurposeNewDevice || !strings.Contains(msg.Body, "1.0.0.9") || !strings.Contains(msg.Subject, "new device") {
+			t.Fatalf("unexpected notice: %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected new device notice")
+	}
+	var events int64
+	m.db.Model(&OutboxEvent{}).Where("topic = ?", EventNewDeviceLogin).Count(&events)
+	if events != 1 {
+		t.Fatalf("expected one new device event, got %d", events)
+	}
+	var signalAudits int64
+	m.db.Model(&AuditLog{}).Where("user_id = ? AND event = ?", bob.UserID, "risk_signal").Count(&signalAudits)
+	if signalAudits != 1 {
+		t.Fatalf("expected the new device signal to be audited, got %d", signalAudits)
+	}
+
+	// 两小时内从北京到纽约：不可能的旅行，要求额外验证
+	travel := laptop
+	travel.IP, travel.Time = "8.8.8.8", now.Add(-time.Hour)
+	if res := assess(travel); res.Decision != RiskChallenge || res.Signals[0].Name != RiskSignalGeoVelocity || !strings.Contains(res.Signals[0].Detail, "impossible travel") {
+		t.Fatalf("expected impossible travel challenge: %+v", res)
+	}
+	// 时间足够长时只算新国家
+	travel.Time = now.Add(48 * time.Hour)
+	if res := assess(travel); res.Decision != RiskAllow || res.Score != 30 {
+		t.Fatalf("expected new country only: %+v", res)
+	}
+
+	if res := assess(RiskContext{IP: "203.0.113.7", UserAgent: "Firefox", DeviceID: "laptop"}); res.Decision != RiskBlock {
+		t.Fatalf("expected listed ip to be blocked: %+v", res)
+	}
+	if res, _ := m.risk.Assess(ctx, "", "", "203.0.113.8"); res.Decision != RiskBlock {
+		t.Fatalf("expected listed ip to be blocked without a user: %+v", res)
+	}
+
+	// 自定义信号与权重
+	m.cfg.RiskSignals = []RiskSignal{constantSignal{score: 100}}
+	m.cfg.Risk.SignalWeights["custom"] = 50
+	if res := assess(RiskContext{IP: "1.0.0.8", UserAgent: "Firefox", DeviceID: "laptop"}); res.Decision != RiskChallenge || res.Score != 50 {
+		t.Fatalf("expected custom signal to challenge: %+v", res)
+	}
+	m.cfg.RiskSignals = nil
+
+	// 历史登录集中在同一时段，偏离 6 小时的登录命中时段信号
+	usual := time.Date(2026, 1, 1, 9, 0, 0, 0, time.Local)
+	for i := 0; i < m.cfg.Risk.TimeOfDayMinSamples; i++ {
+		m.db.Create(&AuditLog{ID: newID(), TenantID: "default", UserID: bob.UserID, Event: "login", Status: "success", CreatedAt: usual.AddDate(0, 0, -i)})
+	}
+	res = assess(RiskContext{IP: "1.0.0.8", UserAgent: "Firefox", DeviceID: "laptop", Time: usual.Add(6 * time.Hour)})
+	if len(res.Signals) != 1 || res.Signals[0].Name != RiskSignalTimeOfDay || res.Decision != RiskAllow {
+		t.Fatalf("expected time of day signal: %+v", res)
+	}
+	if res := assess(RiskContext{IP: "1.0.0.8", UserAgent: "Firefox", DeviceID: "laptop", Time: usual.Add(time.Hour)}); len(res.Signals) != 0 {
+		t.Fatalf("expected usual hour to pass: %+v", res)
+	}
+}
@@ -112,6 +112,22 @@ type AuthzRequest struct {
 	// OrgID 是可选的组织 ID。设置后将仅评估该组织及其祖先组织的作用域内权限，
 	// 而非用户的所有跨组织权限。留空则使用当前行为（全部权限）。
 	OrgID string `json:"org_id,omitempty"`
+	// ResourceAttrs 是可选的资源属性，策略表达式通过 resource.<key> 引用（不覆盖内置属性）。
+	ResourceAttrs map[string]any `json:"resource_attrs,omitempty"`
+	// Context 是可选的请求/环境属性，策略表达式通过 request.* / env.* 引用。
+	Context *AuthzContext `json:"context,omitempty"`
+}
+
+// AuthzContext 授权请求的上下文属性。
+type AuthzContext struct {
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// Time 请求时间，零值表示当前时间。
+	Time time.Time `json:"time,omitempty"`
+	// MFALevel 本次会话完成的认证强度：0 未知 / 1 单因素 / 2 多因素。
+	MFALevel int `json:"mfa_level,omitempty"`
+	// Env 调用方提供的其他环境属性，如 {"region": "cn"}。
+	Env map[string]any `json:"env,omitempty"`
 }
 
 // AuthzDecision 授权检查结果。
@@ -120,8 +136,52 @@ type AuthzDecision struct {
 	Reason  string `json:"reason"`
 }
 
+// 授权决定的来源，见 AuthzExplanation.DecidedBy。
+const (
+	AuthzByMissingPrincipal = "missing_principal"
+	AuthzByAPITokenScope    = "api_token_scope"
+	AuthzByImpersonation    = "impersonation_scope"
+	AuthzByExchangeScope    = "exchange_scope"
+	AuthzByResourceOwner    = "resource_owner"
+	AuthzBySystemRole       = "system_role"
+	AuthzByPolicyDeny       = "policy_deny"
+	AuthzByRole             = "role"
+	AuthzByRelation         = "relation"
+	AuthzByPolicyAllow      = "policy_allow"
+	AuthzByDefaultDeny      = "default_deny"
+)
+
+// AuthzExplanation 授权决定的详细解释，供权限排查界面使用。
+type AuthzExplanation struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// DecidedBy 作出最终决定的环节。
+	DecidedBy string `json:"decided_by"`
+	// DecidingPolicy DecidedBy 为 policy_deny / policy_allow 时的策略编码。
+	DecidingPolicy string   `json:"deciding_policy,omitempty"`
+	Permission     string   `json:"permission"`
+	SubjectRoles   []string `json:"subject_roles"`
+	// GrantingRoles 持有该权限码的角色（含组织作用域），即使最终被策略拒绝也会列出。
+	GrantingRoles []RoleGrant `json:"granting_roles"`
+	// Relation 请求带资源且命名空间映射了该权限时检查的关系元组，如 document:readme#viewer。
+	Relation string `json:"relation,omitempty"`
+	// Policies 每条策略的评估轨迹，按评估顺序排列。
+	Policies []PolicyTrace `json:"policies"`
+	// Attributes 策略求值时使用的属性。
+	Attributes *PolicyEnv `json:"attributes,omitempty"`
+}
+
+// RoleGrant 通过角色授予的权限来源。
+type RoleGrant struct {
+	RoleID    string `json:"role_id"`
+	RoleCode  string `json:"role_code"`
+	RoleName  string `json:"role_name"`
+	ScopeType string `json:"scope_type"`
+	ScopeID   string `json:"scope_id"`
+}
+
 // Check 评估主体是否拥有请求的权限。
-// 评估顺序：系统角色 → 资源所有者 → ABAC 策略拒绝 → RBAC 权限（支持 org 作用域）→ ABAC 策略允许 → 拒绝。
+// 评估顺序：资源所有者 → 系统角色 → ABAC 策略拒绝 → RBAC 权限（支持 org 作用域）→ ReBAC 关系元组 → ABAC 策略允许 → 拒绝。
 func (a *Authorizer) Check(ctx context.Context, req AuthzRequest) (*AuthzDecision, error) {
 	ctx, span := a.m.tracer.Start(ctx, "account.authz.check")
 	defer span.End()
@@ -131,108 +191,196 @@ func (a *Authorizer) Check(ctx context.Context, req AuthzRequest) (*AuthzDecisio
 			recordDuration(ctx, m.AuthzCheckDuration, start)
 		}
 	}()
+	decision, by, err := a.decide(ctx, req, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if by == AuthzByDefaultDeny {
+		if m := a.m.metrics; m != nil {
+			m.PermissionDenied.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("method", "authz_check"),
+			))
+		}
+	}
+	return decision, nil
+}
+
+// CheckMany 在一次调用中评估多个授权请求，使用内存缓存。
+// 评估顺序与 Check 一致。
+func (a *Authorizer) CheckMany(ctx context.Context, reqs []AuthzRequest) ([]AuthzDecision, error) {
+	ctx, span := a.m.tracer.Start(ctx, "account.authz.check_many")
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		if m := a.m.metrics; m != nil {
+			recordDuration(ctx, m.AuthzCheckDuration, start)
+		}
+	}()
+	decisions := make([]AuthzDecision, len(reqs))
+	permissionCache := make(map[string][]string)
+	for i, req := range reqs {
+		decision, _, err := a.decide(ctx, req, permissionCache, nil)
+		if err != nil {
+			return nil, err
+		}
+		decisions[i] = *decision
+	}
+	return decisions, nil
+}
+
+// Explain 与 Check 做出相同的决定，并说明由哪个环节、哪些角色或策略允许或拒绝了请求。
+// 会评估全部策略并查询授予该权限的角色，开销高于 Check，不应用于请求热路径。
+func (a *Authorizer) Explain(ctx context.Context, req AuthzRequest) (*AuthzExplanation, error) {
+	ctx, span := a.m.tracer.Start(ctx, "account.authz.explain")
+	defer span.End()
+
+	ex := &AuthzExplanation{Permission: req.Permission, GrantingRoles: []RoleGrant{}, Policies: []PolicyTrace{}}
+	decision, by, err := a.decide(ctx, req, nil, ex)
+	if err != nil {
+		return nil, err
+	}
+	ex.Allowed, ex.Reason, ex.DecidedBy = decision.Allowed, decision.Reason, by
 	if req.Subject == nil {
-		return &AuthzDecision{Allowed: false, Reason: "missing principal"}, nil
+		return ex, nil
+	}
+	ex.SubjectRoles = req.Subject.Roles
+	if req.Subject.APITokenID == "" {
+		if ex.GrantingRoles, err = a.grantingRoles(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return ex, nil
+}
+
+// ExplainForUser 以指定用户当前的角色构造主体后调用 Explain，供管理端排查他人权限。
+func (a *Authorizer) ExplainForUser(ctx context.Context, userID string, req AuthzRequest) (*AuthzExplanation, error) {
+	var user User
+	if err := a.m.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, accountError(ErrInvalidArgument, "用户不存在")
+		}
+		return nil, err
+	}
+	roles, err := a.m.auth.loadRoleCodes(ctx, a.m.db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	req.Subject = &Principal{
+		UserID:        user.ID,
+		TenantID:      user.TenantID,
+		Username:      user.Username,
+		AuthVersion:   user.AuthVersion,
+		RolesVersion:  user.RolesVersion,
+		PhoneVerified: user.PhoneVerifiedAt != nil,
+		Roles:         roles,
+	}
+	return a.Explain(ctx, req)
+}
+
+// decide 是 Check / CheckMany / Explain 共用的决策流程，返回决定及其来源。
+// permCache 非空时在批量评估间复用权限集；ex 非空时记录策略轨迹。
+func (a *Authorizer) decide(ctx context.Context, req AuthzRequest, permCache map[string][]string, ex *AuthzExplanation) (*AuthzDecision, string, error) {
+	if req.Subject == nil {
+		return &AuthzDecision{Allowed: false, Reason: "missing principal"}, AuthzByMissingPrincipal, nil
 	}
 	if req.Subject.APITokenID != "" && !apiTokenAllowsPermission(req.Subject.Scopes, req.Permission) {
-		return &AuthzDecision{Allowed: false, Reason: "api token scope denied"}, nil
+		return &AuthzDecision{Allowed: false, Reason: "api token scope denied"}, AuthzByAPITokenScope, nil
+	}
+	// 代操作会话只能使用发起时限定的权限范围，且仍需目标用户本身具备该权限
+	if req.Subject.Impersonated && !apiTokenAllowsPermission(req.Subject.Scopes, req.Permission) {
+		return &AuthzDecision{Allowed: false, Reason: "impersonation scope denied"}, AuthzByImpersonation, nil
+	}
+	// 令牌交换取得的令牌只能使用交换时授予的 scope
+	if req.Subject.ClientID != "" && !apiTokenAllowsPermission(req.Subject.GrantedScopes, req.Permission) {
+		return &AuthzDecision{Allowed: false, Reason: "exchange scope denied"}, AuthzByExchangeScope, nil
 	}
 	// Resource owner automatically has access
 	if req.OwnerID != "" && req.OwnerID == req.Subject.UserID {
-		return &AuthzDecision{Allowed: true, Reason: "resource owner"}, nil
+		return &AuthzDecision{Allowed: true, Reason: "resource owner"}, AuthzByResourceOwner, nil
 	}
 	if contains(req.Subject.Roles, "platform_admin") || contains(req.Subject.Roles, "tenant_owner") {
-		return &AuthzDecision{Allowed: true, Reason: "system role"}, nil
+		return &AuthzDecision{Allowed: true, Reason: "system role"}, AuthzBySystemRole, nil
 	}
 
 	// ABAC policy evaluation (deny takes precedence, allow resolves after RBAC)
-	policySvc := &PolicyService{m: a.m}
-	policyDecision, _ := policySvc.EvaluatePolicies(ctx, req)
+	policyDecision, err := a.m.policySvc.evaluate(ctx, req, ex != nil)
+	if err != nil {
+		gaia.WarnF("[account] policy evaluation failed: %v", err)
+		policyDecision = nil
+	}
+	if ex != nil && policyDecision != nil {
+		ex.Policies = append(ex.Policies, policyDecision.Trace...)
+		env := buildPolicyEnv(req)
+		ex.Attributes = &env
+	}
 	if policyDecision != nil && policyDecision.Matched && !policyDecision.Allowed {
-		return &AuthzDecision{Allowed: false, Reason: policyDecision.Reason}, nil
+		if ex != nil {
+			ex.DecidingPolicy = policyDecision.PolicyCode
+		}
+		return &AuthzDecision{Allowed: false, Reason: policyDecision.Reason}, AuthzByPolicyDeny, nil
 	}
 
 	// RBAC permission check (with optional org scoping)
 	var perms []string
-	var err error
-	if req.OrgID != "" {
+	if permCache != nil {
+		perms, err = a.getPermissionsForRequest(ctx, req, permCache)
+	} else if req.OrgID != "" {
 		perms, err = a.GetEffectivePermissionsForPrincipalScoped(ctx, req.Subject, req.OrgID)
 	} else {
 		perms, err = a.GetEffectivePermissionsForPrincipal(ctx, req.Subject)
 	}
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if contains(perms, req.Permission) {
-		return &AuthzDecision{Allowed: true, Reason: "permission matched"}, nil
-	}
-
-	// ABAC policy allow (overrides RBAC denial)
-	if policyDecision != nil && policyDecision.Matched && policyDecision.Allowed {
-		return &AuthzDecision{Allowed: true, Reason: policyDecision.Reason}, nil
-	}
-
-	if m := a.m.metrics; m != nil {
-		m.PermissionDenied.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("method", "authz_check"),
-		))
+		return &AuthzDecision{Allowed: true, Reason: "permission matched"}, AuthzByRole, nil
 	}
-	return &AuthzDecision{Allowed: false, Reason: "permission denied"}, nil
-}
 
-// CheckMany 在一次调用中评估多个授权请求，使用内存缓存。
-// 评估顺序与 Check 一致：系统角色 → 资源所有者 → ABAC 策略拒绝 → RBAC → ABAC 策略允许。
-func (a *Authorizer) CheckMany(ctx context.Context, reqs []AuthzRequest) ([]AuthzDecision, error) {
-	ctx, span := a.m.tracer.Start(ctx, "account.authz.check_many")
-	defer span.End()
-	start := time.Now()
-	defer func() {
-		if m := a.m.metrics; m != nil {
-			recordDuration(ctx, m.AuthzCheckDuration, start)
+	// ReBAC relation tuples (resource-level grants)
+	if object, relation, ok := a.m.rebacSvc.authzRelation(req); ok {
+		if ex != nil {
+			ex.Relation = object.String() + "#" + relation
 		}
-	}()
-	decisions := make([]AuthzDecision, len(reqs))
-	permissionCache := make(map[string][]string)
-	policySvc := &PolicyService{m: a.m}
-	for i, req := range reqs {
-		if req.Subject == nil {
-			decisions[i] = AuthzDecision{Allowed: false, Reason: "missing principal"}
-			continue
-		}
-		if req.Subject.APITokenID != "" && !apiTokenAllowsPermission(req.Subject.Scopes, req.Permission) {
-			decisions[i] = AuthzDecision{Allowed: false, Reason: "api token scope denied"}
-			continue
-		}
-		// Resource owner automatically has access
-		if req.OwnerID != "" && req.OwnerID == req.Subject.UserID {
-			decisions[i] = AuthzDecision{Allowed: true, Reason: "resource owner"}
-			continue
-		}
-		if contains(req.Subject.Roles, "platform_admin") || contains(req.Subject.Roles, "tenant_owner") {
-			decisions[i] = AuthzDecision{Allowed: true, Reason: "system role"}
-			continue
+		allowed, err := a.m.rebacSvc.checkAuthz(ctx, req, object, relation)
+		if err != nil {
+			gaia.WarnF("[account] rebac check failed: %v", err)
+		} else if allowed {
+			return &AuthzDecision{Allowed: true, Reason: "relation " + relation + " matched"}, AuthzByRelation, nil
 		}
+	}
 
-		// ABAC policy deny
-		policyDecision, _ := policySvc.EvaluatePolicies(ctx, req)
-		if policyDecision != nil && policyDecision.Matched && !policyDecision.Allowed {
-			decisions[i] = AuthzDecision{Allowed: false, Reason: policyDecision.Reason}
-			continue
+	// ABAC policy allow (overrides RBAC denial)
+	if policyDecision != nil && policyDecision.Matched && policyDecision.Allowed {
+		if ex != nil {
+			ex.DecidingPolicy = policyDecision.PolicyCode
 		}
+		return &AuthzDecision{Allowed: true, Reason: policyDecision.Reason}, AuthzByPolicyAllow, nil
+	}
+	return &AuthzDecision{Allowed: false, Reason: "permission denied"}, AuthzByDefaultDeny, nil
+}
 
-		perms, err := a.getPermissionsForRequest(ctx, req, permissionCache)
+// grantingRoles 查询主体持有的、包含该权限码的角色；设置 OrgID 时只看租户级与该组织作用域。
+func (a *Authorizer) grantingRoles(ctx context.Context, req AuthzRequest) ([]RoleGrant, error) {
+	p := req.Subject
+	q := a.m.db.WithContext(ctx).Table("acct_user_roles").
+		Select("acct_roles.id AS role_id, acct_roles.code AS role_code, acct_roles.name AS role_name, acct_user_roles.scope_type, acct_user_roles.scope_id").
+		Joins("JOIN acct_roles ON acct_roles.id = acct_user_roles.role_id").
+		Joins("JOIN acct_role_permissions ON acct_role_permissions.role_id = acct_roles.id").
+		Joins("JOIN acct_permissions ON acct_permissions.id = acct_role_permissions.permission_id").
+		Where("acct_user_roles.user_id = ? AND acct_user_roles.tenant_id = ? AND acct_permissions.tenant_id = ? AND acct_permissions.code = ? AND acct_permissions.status = ?",
+			p.UserID, p.TenantID, p.TenantID, req.Permission, "enabled")
+	if req.OrgID != "" {
+		scopeIDs, err := a.m.orgSvc.OrgScopeIDs(ctx, req.OrgID)
 		if err != nil {
-			return nil, err
-		}
-		if contains(perms, req.Permission) {
-			decisions[i] = AuthzDecision{Allowed: true, Reason: "permission matched"}
-		} else if policyDecision != nil && policyDecision.Matched && policyDecision.Allowed {
-			decisions[i] = AuthzDecision{Allowed: true, Reason: policyDecision.Reason}
-		} else {
-			decisions[i] = AuthzDecision{Allowed: false, Reason: "permission denied"}
+			return nil, fmt.Errorf("resolve org scope: %w", err)
 		}
+		q = q.Where("(acct_user_roles.scope_type = ?) OR (acct_user_roles.scope_type = ? AND acct_user_roles.scope_id IN ?)", "tenant", "org", scopeIDs)
 	}
-	return decisions, nil
+	grants := []RoleGrant{}
+	if err := q.Order("acct_roles.code").Scan(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
 }
 
 // GetEffectivePermissions 返回用户的全部有效权限代码。
@@ -252,7 +400,54 @@ func (a *Authorizer) GetEffectivePermissionsForPrincipal(ctx context.Context, pr
 	if principal.APITokenID != "" {
 		return principal.Scopes, nil
 	}
-	return a.GetEffectivePermissionsForUser(ctx, principal.UserID, principal.TenantID, principal.RolesVersion)
+	perms, err := a.GetEffectivePermissionsForUser(ctx, principal.UserID, principal.TenantID, principal.RolesVersion)
+	if err != nil {
+		return nil, err
+	}
+	return filterPrincipalScopes(perms, principal), nil
+}
+
+// filterPrincipalScopes 按代操作范围与令牌交换授予的 scope 收窄权限集。
+func filterPrincipalScopes(perms []string, principal *Principal) []string {
+	if principal.Impersonated {
+		perms = filterImpersonationScopes(perms, principal.Scopes)
+	}
+	if principal.ClientID != "" {
+		perms = filterImpersonationScopes(perms, principal.GrantedScopes)
+	}
+	return perms
+}
+
+// principalRoleAllowed 判断 principal 持有的角色 roleCode 能否用于角色检查。
+// 代操作与令牌交换会话的角色借自目标用户，只有该角色授予的全部权限都落在
+// filterPrincipalScopes 收窄后的范围内时才算持有；不授予任何权限的角色无法按范围判断，一律不算。
+func (a *Authorizer) principalRoleAllowed(ctx context.Context, principal *Principal, roleCode string) (bool, error) {
+	if !contains(principal.Roles, roleCode) {
+		return false, nil
+	}
+	if !principal.Impersonated && principal.ClientID == "" {
+		return true, nil
+	}
+	var perms []string
+	if err := a.m.db.WithContext(ctx).Table("acct_roles").
+		Joins("JOIN acct_role_permissions ON acct_role_permissions.role_id = acct_roles.id").
+		Joins("JOIN acct_permissions ON acct_permissions.id = acct_role_permissions.permission_id").
+		Where("acct_roles.tenant_id = ? AND acct_roles.code = ? AND acct_permissions.status = ?", principal.TenantID, roleCode, "enabled").
+		Distinct().Pluck("acct_permissions.code", &perms).Error; err != nil {
+		return false, err
+	}
+	return len(perms) > 0 && len(filterPrincipalScopes(perms, principal)) == len(perms), nil
+}
+
+// filterImpersonationScopes 取用户权限与代操作权限范围的交集。
+func filterImpersonationScopes(perms, scopes []string) []string {
+	out := make([]string, 0, len(perms))
+	for _, perm := range perms {
+		if apiTokenAllowsPermission(scopes, perm) {
+			out = append(out, perm)
+		}
+	}
+	return out
 }
 
 // GetEffectivePermissionsForPrincipalScoped 返回用户在指定组织作用域内的有效权限代码。
@@ -264,6 +459,15 @@ func (a *Authorizer) GetEffectivePermissionsForPrincipalScoped(ctx context.Conte
 	if principal.APITokenID != "" {
 		return principal.Scopes, nil
 	}
+	if principal.Impersonated || principal.ClientID != "" {
+		own := *principal
+		own.Impersonated, own.ClientID = false, ""
+		perms, err := a.GetEffectivePermissionsForPrincipalScoped(ctx, &own, orgID)
+		if err != nil {
+			return nil, err
+		}
+		return filterPrincipalScopes(perms, principal), nil
+	}
 	if orgID == "" {
 		return a.GetEffectivePermissionsForPrincipal(ctx, principal)
 	}
@@ -0,0 +1,1768 @@
+package account
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/crewjam/saml"
+	xrv "github.com/mattermost/xml-roundtrip-validator"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/xxzhwl/gaia"
+	"github.com/xxzhwl/gaia/errwrap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SAMLProvider SAML 身份在 acct_oauth_accounts 中使用的 provider 标识。
+const SAMLProvider = "saml"
+
+// SAML 连接状态。
+const (
+	SAMLConnectionEnabled  = "enabled"
+	SAMLConnectionDisabled = "disabled"
+)
+
+const (
+	// samlLogoutProvider SP 发起登出时 acct_oauth_states 中的用途标识。
+	samlLogoutProvider  = "saml_logout"
+	samlTicketTTL       = time.Minute
+	samlMaxMessageSize  = 1 << 20
+	samlMetadataTimeout = 10 * time.Second
+	samlSubjectMaxLen   = 128
+	samlTicketParam     = "saml_ticket"
+)
+
+// SAMLService SAML 2.0 服务提供方（SP），每个租户对接一个企业 IdP。
+//
+// 登录：/saml/{tenant}/login 生成 AuthnRequest 跳转到 IdP，IdP 把签名断言 POST 到 /saml/{tenant}/acs；
+// 断言校验通过后生成一次性登录票据并跳回前端（return_to?saml_ticket=...），前端再调用 /auth/login/saml 兑换令牌，
+// 避免令牌出现在浏览器跳转地址中。断言 ID 在有效期内只能使用一次。
+// 身份通过 acct_oauth_accounts（provider=saml，subject=NameID 或映射的属性）关联本地用户，
+// 首次登录自动创建账号，会话与刷新令牌和其他登录方式一致；IdP 组按 GroupRoleMapping 对账租户级角色，
+// 只调整映射中出现的角色，手工授权的其他角色不受影响。
+//
+// 登出：IdP 发起的 LogoutRequest 必须签名，按 NameID / SessionIndex 吊销对应的本地会话；
+// 用户在本系统登出时吊销当前会话，并在 IdP 支持时把浏览器转到 IdP 完成全局登出。
+type SAMLService struct {
+	m *Manager
+
+	keyOnce sync.Once
+	key     crypto.Signer
+	cert    *x509.Certificate
+	keyErr  error
+}
+
+// SAMLConnection 租户对接的 SAML IdP。
+type SAMLConnection struct {
+	ID                string    `json:"id" gorm:"size:36;primaryKey"`
+	TenantID          string    `json:"tenant_id" gorm:"size:64;not null;uniqueIndex:uniq_acct_saml_connections_tenant"`
+	IdPEntityID       string    `json:"idp_entity_id" gorm:"column:idp_entity_id;size:255;not null"`
+	MetadataURL       string    `json:"metadata_url" gorm:"size:512"`
+	Metadata          string    `json:"-" gorm:"type:text;not null"`
+	NameIDFormat      string    `json:"name_id_format" gorm:"size:128"`
+	AttributeMapping  string    `json:"-" gorm:"type:text"` // JSON SAMLAttributeMapping
+	GroupRoleMapping  string    `json:"-" gorm:"type:text"` // JSON map[组名小写]角色编码
+	AllowIDPInitiated bool      `json:"allow_idp_initiated" gorm:"column:allow_idp_initiated;not null;default:false"`
+	Status            string    `json:"status" gorm:"size:20;not null;default:enabled"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (SAMLConnection) TableName() string { return "acct_saml_connections" }
+
+// SAMLSession 本地会话与 IdP 会话（NameID + SessionIndex）的对应关系，用于单点登出。
+type SAMLSession struct {
+	ID           string    `json:"id" gorm:"size:36;primaryKey"`
+	TenantID     string    `json:"tenant_id" gorm:"size:64;not null;index:idx_acct_saml_sessions_name_id,priority:1"`
+	NameID       string    `json:"name_id" gorm:"size:255;not null;index:idx_acct_saml_sessions_name_id,priority:2"`
+	NameIDFormat string    `json:"name_id_format" gorm:"size:128"`
+	SessionIndex string    `json:"session_index" gorm:"size:255"`
+	SessionID    string    `json:"session_id" gorm:"size:36;not null;uniqueIndex:uniq_acct_saml_sessions_session"`
+	UserID       string    `json:"user_id" gorm:"size:36;not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (SAMLSession) TableName() string { return "acct_saml_sessions" }
+
+// SAMLLoginTicket ACS 校验断言后签发的一次性登录票据。
+// 记录保留到断言失效（ReplayUntil），期间同一断言 ID 无法再次使用。
+type SAMLLoginTicket struct {
+	TicketHash  string `gorm:"size:64;primaryKey"`
+	TenantID    string `gorm:"size:64;not null;uniqueIndex:uniq_acct_saml_assertion,priority:1"`
+	AssertionID string `gorm:"size:255;not null;uniqueIndex:uniq_acct_saml_assertion,priority:2"`
+	Identity    string `gorm:"type:text;not null"` // JSON samlIdentity
+	UsedAt      *time.Time
+	ExpiresAt   time.Time `gorm:"not null"`
+	ReplayUntil time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time
+}
+
+func (SAMLLoginTicket) TableName() string { return "acct_saml_tickets" }
+
+// SAMLAttributeMapping 断言属性到用户资料的映射。每项可写多个候选属性名（逗号分隔），
+// 按顺序取第一个存在的值，属性名与 Name 或 FriendlyName 比较，不区分大小写。
+type SAMLAttributeMapping struct {
+	// Subject 作为外部身份标识的属性，为空时使用 NameID。NameID 为 transient 格式时必须配置。
+	Subject string `json:"subject,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Groups  string `json:"groups,omitempty"`
+}
+
+// 常见 IdP（Azure AD / ADFS / Okta / Shibboleth）的默认属性名。
+const (
+	samlDefaultEmailAttrs  = "email,mail,emailaddress,http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress,urn:oid:0.9.2342.19200300.100.1.3"
+	samlDefaultNameAttrs   = "displayName,name,http://schemas.microsoft.com/identity/claims/displayname,http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name,urn:oid:2.16.840.1.113730.3.1.241"
+	samlDefaultGroupsAttrs = "groups,memberOf,http://schemas.microsoft.com/ws/2008/06/identity/claims/groups,urn:oid:1.3.6.1.4.1.5923.1.5.1.1"
+)
+
+func (m SAMLAttributeMapping) withDefaults() SAMLAttributeMapping {
+	if m.Email == "" {
+		m.Email = samlDefaultEmailAttrs
+	}
+	if m.Name == "" {
+		m.Name = samlDefaultNameAttrs
+	}
+	if m.Groups == "" {
+		m.Groups = samlDefaultGroupsAttrs
+	}
+	return m
+}
+
+// SAMLConnectionRequest 创建或更新租户 SAML 连接的参数。
+// Metadata 与 MetadataURL 二选一：Metadata 非空时直接使用，否则从 MetadataURL 拉取。
+type SAMLConnectionRequest struct {
+	TenantID          string               `json:"tenant_id"`
+	Metadata          string               `json:"metadata"`
+	MetadataURL       string               `json:"metadata_url"`
+	NameIDFormat      string               `json:"name_id_format"`
+	AttributeMapping  SAMLAttributeMapping `json:"attribute_mapping"`
+	GroupRoleMapping  map[string]string    `json:"group_role_mapping"`
+	AllowIDPInitiated bool                 `json:"allow_idp_initiated"`
+	Status            string               `json:"status"`
+}
+
+// SAMLConnectionInfo 租户 SAML 连接及需要登记到 IdP 的 SP 参数。
+type SAMLConnectionInfo struct {
+	SAMLConnection
+	AttributeMapping SAMLAttributeMapping `json:"attribute_mapping"`
+	GroupRoleMapping map[string]string    `json:"group_role_mapping"`
+	SSOURL           string               `json:"sso_url"`
+	SLOURL           string               `json:"slo_url,omitempty"`
+	SPEntityID       string               `json:"sp_entity_id"`
+	SPACSURL         string               `json:"sp_acs_url"`
+	SPSLOURL         string               `json:"sp_slo_url"`
+}
+
+// SAMLRedirect 需要浏览器继续完成的跳转：RedirectURL（HTTP-Redirect 绑定）与
+// PostForm（HTTP-POST 绑定的自动提交表单）二选一。
+type SAMLRedirect struct {
+	RedirectURL string `json:"redirect_url,omitempty"`
+	PostForm    string `json:"post_form,omitempty"`
+}
+
+// SAMLLoginStartRequest 发起 SAML 登录的参数。
+type SAMLLoginStartRequest struct {
+	TenantID string
+	ReturnTo string
+}
+
+// SAMLResponseRequest IdP 回传到 ACS 端点的表单。
+type SAMLResponseRequest struct {
+	TenantID     string
+	SAMLResponse string
+	RelayState   string
+	IP           string
+	UserAgent    string
+}
+
+// SAMLLoginRequest 使用 ACS 签发的票据登录。
+type SAMLLoginRequest struct {
+	TenantID  string
+	Ticket    string
+	DeviceID  string
+	IP        string
+	UserAgent string
+}
+
+// SAMLSLORequest IdP 发往 SLO 端点的消息。Redirect 绑定需要原始查询串以校验签名。
+type SAMLSLORequest struct {
+	TenantID     string
+	Binding      string
+	RawQuery     string
+	SAMLRequest  string
+	SAMLResponse string
+	RelayState   string
+	IP           string
+	UserAgent    string
+}
+
+// SAMLLogoutRequest 用户在本系统发起登出的参数。
+type SAMLLogoutRequest struct {
+	TenantID    string
+	UserID      string
+	SessionID   string
+	AccessToken string
+	ReturnTo    string
+	IP          string
+	UserAgent   string
+}
+
+// samlIdentity 断言中提取的身份，随登录票据保存。
+type samlIdentity struct {
+	Subject      string   `json:"subject"`
+	NameID       string   `json:"name_id"`
+	NameIDFormat string   `json:"name_id_format,omitempty"`
+	SessionIndex string   `json:"session_index,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Groups       []string `json:"groups,omitempty"`
+}
+
+// Enabled 是否配置了 SAML 服务提供方。
+func (s *SAMLService) Enabled() bool {
+	return s.m.cfg.SAML.BaseURL != ""
+}
+
+// ============================================================================
+// 连接管理
+// ============================================================================
+
+// SaveConnection 创建或更新租户的 SAML 连接。
+func (s *SAMLService) SaveConnection(ctx context.Context, req SAMLConnectionRequest) (*SAMLConnectionInfo, error) {
+	tenantID := s.m.tenantID(req.TenantID)
+	raw := strings.TrimSpace(req.Metadata)
+	if raw == "" {
+		if req.MetadataURL == "" {
+			return nil, accountError(ErrInvalidArgument, "metadata 与 metadata_url 不能同时为空")
+		}
+		data, err := s.fetchMetadata(ctx, req.MetadataURL)
+		if err != nil {
+			return nil, err
+		}
+		raw = string(data)
+	}
+	md, err := parseSAMLMetadata([]byte(raw))
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "IdP 元数据无效: "+err.Error())
+	}
+	switch req.Status {
+	case "":
+		req.Status = SAMLConnectionEnabled
+	case SAMLConnectionEnabled, SAMLConnectionDisabled:
+	default:
+		return nil, accountError(ErrInvalidArgument, "status 只能为 enabled 或 disabled")
+	}
+
+	groupRoles := make(map[string]string, len(req.GroupRoleMapping))
+	codes := make([]string, 0, len(req.GroupRoleMapping))
+	for group, code := range req.GroupRoleMapping {
+		group, code = strings.ToLower(strings.TrimSpace(group)), strings.TrimSpace(code)
+		if group == "" || code == "" {
+			return nil, accountError(ErrInvalidArgument, "group_role_mapping 不能包含空的组名或角色编码")
+		}
+		if code == "platform_admin" {
+			return nil, accountError(ErrPermissionDenied, "不允许把 IdP 组映射为平台管理员")
+		}
+		groupRoles[group] = code
+		codes = append(codes, code)
+	}
+	if len(codes) > 0 {
+		var found []string
+		if err := s.m.db.WithContext(ctx).Model(&Role{}).Where("tenant_id = ? AND code IN ?", tenantID, codes).
+			Pluck("code", &found).Error; err != nil {
+			recordDBError(ctx)
+			return nil, err
+		}
+		for _, code := range codes {
+			if !contains(found, code) {
+				return nil, accountError(ErrInvalidArgument, fmt.Sprintf("角色不存在: %s", code))
+			}
+		}
+	}
+	attrMapping, _ := json.Marshal(req.AttributeMapping)
+	groupMapping, _ := json.Marshal(groupRoles)
+
+	conn := &SAMLConnection{
+		ID:                newID(),
+		TenantID:          tenantID,
+		IdPEntityID:       truncateString(md.EntityID, 255),
+		MetadataURL:       req.MetadataURL,
+		Metadata:          raw,
+		NameIDFormat:      req.NameIDFormat,
+		AttributeMapping:  string(attrMapping),
+		GroupRoleMapping:  string(groupMapping),
+		AllowIDPInitiated: req.AllowIDPInitiated,
+		Status:            req.Status,
+	}
+	if err := s.m.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"idp_entity_id", "metadata_url", "metadata", "name_id_format",
+			"attribute_mapping", "group_role_mapping", "allow_idp_initiated", "status", "updated_at"}),
+	}).Create(conn).Error; err != nil {
+		recordDBError(ctx)
+		return nil, fmt.Errorf("save saml connection: %w", err)
+	}
+	s.m.audit(ctx, tenantID, "", "saml_connection_save", "success", "idp: "+md.EntityID, "", "")
+	return s.GetConnection(ctx, tenantID)
+}
+
+// GetConnection 返回租户的 SAML 连接。
+func (s *SAMLService) GetConnection(ctx context.Context, tenantID string) (*SAMLConnectionInfo, error) {
+	tenantID = s.m.tenantID(tenantID)
+	var conn SAMLConnection
+	if err := s.m.db.WithContext(ctx).Where("tenant_id = ?", tenantID).First(&conn).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, accountError(ErrInvalidArgument, "租户未配置 SAML 连接")
+		}
+		recordDBError(ctx)
+		return nil, err
+	}
+	info := &SAMLConnectionInfo{SAMLConnection: conn, GroupRoleMapping: conn.groupRoles()}
+	_ = json.Unmarshal([]byte(conn.AttributeMapping), &info.AttributeMapping)
+	if md, err := parseSAMLMetadata([]byte(conn.Metadata)); err == nil {
+		_, info.SSOURL = samlEndpoint(md, false, saml.HTTPRedirectBinding)
+		_, info.SLOURL = samlEndpoint(md, true, saml.HTTPRedirectBinding)
+	}
+	base := s.tenantBaseURL(tenantID)
+	info.SPEntityID, info.SPACSURL, info.SPSLOURL = base+"/metadata", base+"/acs", base+"/slo"
+	return info, nil
+}
+
+// DeleteConnection 删除租户的 SAML 连接。已登录的会话保留，但不再参与单点登出。
+func (s *SAMLService) DeleteConnection(ctx context.Context, tenantID string) error {
+	tenantID = s.m.tenantID(tenantID)
+	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("tenant_id = ?", tenantID).Delete(&SAMLConnection{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return accountError(ErrInvalidArgument, "租户未配置 SAML 连接")
+		}
+		return tx.Where("tenant_id = ?", tenantID).Delete(&SAMLSession{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.m.audit(ctx, tenantID, "", "saml_connection_delete", "success", "", "", "")
+	return nil
+}
+
+func (c *SAMLConnection) groupRoles() map[string]string {
+	mapping := map[string]string{}
+	if c.GroupRoleMapping != "" {
+		_ = json.Unmarshal([]byte(c.GroupRoleMapping), &mapping)
+	}
+	return mapping
+}
+
+func (c *SAMLConnection) attributeMapping() SAMLAttributeMapping {
+	var mapping SAMLAttributeMapping
+	if c.AttributeMapping != "" {
+		_ = json.Unmarshal([]byte(c.AttributeMapping), &mapping)
+	}
+	return mapping.withDefaults()
+}
+
+// fetchMetadata 从 IdP 元数据地址拉取 XML，只允许 https（以及本地调试用的 http://localhost）。
+func (s *SAMLService) fetchMetadata(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, accountError(ErrInvalidArgument, "metadata_url 无效")
+	}
+	client := s.m.cfg.SAML.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: samlMetadataTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "拉取 IdP 元数据失败: "+err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, accountError(ErrInvalidArgument, fmt.Sprintf("拉取 IdP 元数据失败: HTTP %d", resp.StatusCode))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, samlMaxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read saml metadata: %w", err)
+	}
+	if len(data) > samlMaxMessageSize {
+		return nil, accountError(ErrInvalidArgument, "IdP 元数据过大")
+	}
+	return data, nil
+}
+
+// connection 加载启用状态的租户连接及解析后的 IdP 元数据。
+func (s *SAMLService) connection(ctx context.Context, tenantID string) (*SAMLConnection, *saml.EntityDescriptor, error) {
+	if !s.Enabled() {
+		return nil, nil, accountError(ErrInvalidArgument, "未启用 SAML 登录")
+	}
+	var conn SAMLConnection
+	if err := s.m.db.WithContext(ctx).Where("tenant_id = ?", tenantID).First(&conn).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, accountError(ErrInvalidArgument, "租户未配置 SAML 单点登录")
+		}
+		recordDBError(ctx)
+		return nil, nil, err
+	}
+	if conn.Status != SAMLConnectionEnabled {
+		return nil, nil, accountError(ErrPermissionDenied, "租户 SAML 单点登录已停用")
+	}
+	md, err := parseSAMLMetadata([]byte(conn.Metadata))
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse saml metadata of tenant %s: %w", tenantID, err)
+	}
+	return &conn, md, nil
+}
+
+// ============================================================================
+// SP 元数据与登录
+// ============================================================================
+
+// Metadata 返回租户 SP 元数据（XML），供管理员登记到 IdP。未配置 IdP 连接时同样可用。
+func (s *SAMLService) Metadata(ctx context.Context, tenantID string) ([]byte, error) {
+	if !s.Enabled() {
+		return nil, accountError(ErrInvalidArgument, "未启用 SAML 登录")
+	}
+	tenantID = s.m.tenantID(tenantID)
+	var conn *SAMLConnection
+	var c SAMLConnection
+	if err := s.m.db.WithContext(ctx).Where("tenant_id = ?", tenantID).First(&c).Error; err == nil {
+		conn = &c
+	}
+	sp, err := s.serviceProvider(tenantID, conn, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal saml metadata: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// StartLogin 生成发往 IdP 的 AuthnRequest。RelayState 为一次性随机值，
+// 与请求 ID、登录后的跳转地址一起保存在 acct_oauth_states 中。
+func (s *SAMLService) StartLogin(ctx context.Context, req SAMLLoginStartRequest) (*SAMLRedirect, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.saml.start_login")
+	defer span.End()
+	tenantID := s.m.tenantID(req.TenantID)
+	if err := s.m.tenantSvc.checkLogin(ctx, tenantID, LoginMethodSAML); err != nil {
+		return nil, err
+	}
+	conn, md, err := s.connection(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	returnTo, err := s.returnTo(req.ReturnTo)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := s.serviceProvider(tenantID, conn, md)
+	if err != nil {
+		return nil, err
+	}
+	binding, location := samlEndpoint(md, false, saml.HTTPRedirectBinding)
+	if location == "" {
+		return nil, accountError(ErrInvalidArgument, "IdP 元数据缺少 SingleSignOnService")
+	}
+	authnReq, err := sp.MakeAuthenticationRequest(location, binding, saml.HTTPPostBinding)
+	if err != nil {
+		return nil, fmt.Errorf("make saml authn request: %w", err)
+	}
+	relayState, err := s.saveState(ctx, tenantID, SAMLProvider, authnReq.ID, returnTo)
+	if err != nil {
+		return nil, err
+	}
+	return samlEncode(sp, binding, location, "SAMLRequest", authnReq, relayState)
+}
+
+// ConsumeResponse 校验 IdP 回传的断言，签发一次性登录票据并返回带票据的前端跳转地址。
+func (s *SAMLService) ConsumeResponse(ctx context.Context, req SAMLResponseRequest) (string, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.saml.acs")
+	defer span.End()
+	tenantID := s.m.tenantID(req.TenantID)
+	fail := func(err error, detail string) (string, error) {
+		s.m.audit(ctx, tenantID, "", "saml_login", "failed", truncateString(detail, 500), req.IP, req.UserAgent)
+		return "", err
+	}
+
+	conn, md, err := s.connection(ctx, tenantID)
+	if err != nil {
+		return fail(err, err.Error())
+	}
+	sp, err := s.serviceProvider(tenantID, conn, md)
+	if err != nil {
+		return "", err
+	}
+
+	var requestIDs []string
+	returnTo := s.m.cfg.SAML.DefaultRedirectURL
+	if req.RelayState != "" {
+		state, err := s.m.oauth.takeState(ctx, tenantID, SAMLProvider, req.RelayState)
+		switch {
+		case err == nil:
+			requestIDs = []string{state.Nonce}
+			returnTo = state.RedirectURI
+		case conn.AllowIDPInitiated:
+			// IdP 发起的登录可能把目标地址放在 RelayState 中，仍需通过跳转白名单
+			if u, err := s.returnTo(req.RelayState); err == nil {
+				returnTo = u
+			}
+		default:
+			return fail(err, "relay state: "+err.Error())
+		}
+	} else if !conn.AllowIDPInitiated {
+		return fail(accountError(ErrInvalidArgument, "缺少 RelayState"), "missing relay state")
+	}
+
+	raw, err := decodeSAMLMessage(req.SAMLResponse, false)
+	if err != nil {
+		return fail(err, err.Error())
+	}
+	assertion, err := sp.ParseXMLResponse(raw, requestIDs, sp.AcsURL)
+	if err != nil {
+		detail := err.Error()
+		var ire *saml.InvalidResponseError
+		if errors.As(err, &ire) && ire.PrivateErr != nil {
+			detail = ire.PrivateErr.Error()
+		}
+		return fail(accountError(ErrInvalidCredential, "SAML 断言无效"), "assertion: "+detail)
+	}
+	identity, err := samlIdentityFrom(assertion, conn.attributeMapping())
+	if err != nil {
+		return fail(err, err.Error())
+	}
+
+	ticket, err := generateAuthCode()
+	if err != nil {
+		return "", err
+	}
+	data, _ := json.Marshal(identity)
+	now := time.Now()
+	replayUntil := now.Add(saml.MaxIssueDelay + saml.MaxClockSkew)
+	if assertion.Conditions != nil && !assertion.Conditions.NotOnOrAfter.IsZero() {
+		if t := assertion.Conditions.NotOnOrAfter.Add(saml.MaxClockSkew); t.After(replayUntil) {
+			replayUntil = t
+		}
+	}
+	if err := s.m.db.WithContext(ctx).Create(&SAMLLoginTicket{
+		TicketHash:  tokenHash(ticket),
+		TenantID:    tenantID,
+		AssertionID: truncateString(assertion.ID, 255),
+		Identity:    string(data),
+		ExpiresAt:   now.Add(samlTicketTTL),
+		ReplayUntil: replayUntil,
+	}).Error; err != nil {
+		if isDuplicateKeyErr(err) {
+			return fail(accountError(ErrInvalidCredential, "SAML 断言已被使用"), "assertion replay: "+assertion.ID)
+		}
+		recordDBError(ctx)
+		return "", fmt.Errorf("create saml ticket: %w", err)
+	}
+
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(samlTicketParam, ticket)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Login 兑换 ACS 签发的票据并登录。首次登录自动创建本地账号，之后每次登录同步断言中的资料与组映射角色。
+func (s *SAMLService) Login(ctx context.Context, req SAMLLoginRequest) (*AuthResult, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.saml.login")
+	defer span.End()
+	tenantID := s.m.tenantID(req.TenantID)
+	if err := s.m.tenantSvc.checkLogin(ctx, tenantID, LoginMethodSAML); err != nil {
+		return nil, err
+	}
+
+	identity, err := s.redeemTicket(ctx, tenantID, req.Ticket)
+	if err != nil {
+		s.m.audit(ctx, tenantID, "", "saml_login", "failed", "ticket: "+err.Error(), req.IP, req.UserAgent)
+		return nil, err
+	}
+	conn, _, err := s.connection(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		user         *User
+		result       *AuthResult
+		rolesChanged bool
+	)
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := s.linkedUser(tx, tenantID, identity.Subject)
+		if err != nil {
+			return err
+		}
+		if user, err = s.applyIdentity(ctx, tx, tenantID, identity, current); err != nil {
+			return err
+		}
+		if user.Status == UserStatusDisabled || user.Status == UserStatusDeleted {
+			return accountError(ErrPermissionDenied, "账号不可用")
+		}
+		if user.Status == UserStatusLocked {
+			if user.LockedUntil == nil || time.Now().Before(*user.LockedUntil) {
+				return accountError(ErrAccountLocked, "账号已锁定")
+			}
+			if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+				"status":       UserStatusNormal,
+				"locked_until": nil,
+			}).Error; err != nil {
+				return err
+			}
+			user.Status = UserStatusNormal
+			user.LockedUntil = nil
+		}
+		if rolesChanged, err = s.syncGroupRoles(tx, tenantID, user, identity.Groups, conn.groupRoles()); err != nil {
+			return err
+		}
+		if s.m.phoneBindingRequired(user) {
+			result = &AuthResult{
+				User:                 user.toInfo(nil, nil),
+				PhoneBindingRequired: true,
+				TokenType:            "Bearer",
+			}
+			return nil
+		}
+		roles, err := s.m.auth.loadRoleCodes(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		// Enforce admin MFA / tenant MFA policy: require TOTP, or fall back to email/SMS verification
+		mfaRequired, mfaEnforced := s.m.mfa.enrollmentState(ctx, tenantID, user.ID, roles)
+		if mfaEnforced {
+			if r := s.m.auth.adminMFAFallback(ctx, tx, user, req.IP); r != nil {
+				result = r
+				return nil
+			}
+			return accountError(ErrPermissionDenied, "需要先配置 MFA 后才能登录")
+		}
+		sessionID := newID()
+		if result, err = s.m.auth.issueTokens(ctx, tx, user, roles, []string{AMRFederated}, req.DeviceID, req.IP, req.UserAgent, sessionID, "", ""); err != nil {
+			return err
+		}
+		result.MFAEnrollmentRequired = mfaRequired
+		if err := tx.Create(&SAMLSession{
+			ID:           newID(),
+			TenantID:     tenantID,
+			NameID:       truncateString(identity.NameID, 255),
+			NameIDFormat: truncateString(identity.NameIDFormat, 128),
+			SessionIndex: truncateString(identity.SessionIndex, 255),
+			SessionID:    sessionID,
+			UserID:       user.ID,
+			ExpiresAt:    time.Now().Add(s.m.cfg.RefreshTokenTTL),
+		}).Error; err != nil {
+			return fmt.Errorf("create saml session: %w", err)
+		}
+		now := time.Now()
+		if err := emitOutbox(tx, EventUserLoggedIn, user.ID, map[string]any{
+			"user_id":      user.ID,
+			"tenant_id":    tenantID,
+			"method":       "saml",
+			"logged_in_at": now,
+		}); err != nil {
+			gaia.WarnF("[account] emit user logged in event failed: %v", err)
+		}
+		return tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"last_login_at": now,
+			"last_login_ip": req.IP,
+		}).Error
+	})
+	if err != nil {
+		userID := ""
+		if user != nil {
+			userID = user.ID
+		}
+		s.m.audit(ctx, tenantID, userID, "saml_login", "failed", err.Error(), req.IP, req.UserAgent)
+		return nil, err
+	}
+	if rolesChanged {
+		_ = s.m.authorizer.invalidatePermissions(ctx, user.ID)
+		s.m.auth.invalidateUserPrincipalCaches(ctx, user.ID)
+	}
+	if !result.MFARequired && !result.PhoneBindingRequired {
+		s.m.audit(ctx, tenantID, user.ID, "saml_login", "success", "name_id="+identity.NameID, req.IP, req.UserAgent)
+		_, _ = s.m.authorizer.GetEffectivePermissions(ctx, user.ID)
+	}
+	return result, nil
+}
+
+// redeemTicket 原子地标记票据已使用并返回其中的身份。
+func (s *SAMLService) redeemTicket(ctx context.Context, tenantID, ticket string) (*samlIdentity, error) {
+	if ticket == "" {
+		return nil, accountError(ErrInvalidArgument, "缺少 SAML 登录票据")
+	}
+	hash := tokenHash(ticket)
+	now := time.Now()
+	res := s.m.db.WithContext(ctx).Model(&SAMLLoginTicket{}).
+		Where("ticket_hash = ? AND tenant_id = ? AND used_at IS NULL AND expires_at > ?", hash, tenantID, now).
+		Update("used_at", now)
+	if res.Error != nil {
+		recordDBError(ctx)
+		return nil, res.Error
+	}
+	if res.RowsAffected != 1 {
+		return nil, accountError(ErrInvalidCredential, "SAML 登录票据无效或已过期")
+	}
+	var row SAMLLoginTicket
+	if err := s.m.db.WithContext(ctx).Where("ticket_hash = ?", hash).First(&row).Error; err != nil {
+		return nil, err
+	}
+	var identity samlIdentity
+	if err := json.Unmarshal([]byte(row.Identity), &identity); err != nil {
+		return nil, fmt.Errorf("decode saml identity: %w", err)
+	}
+	return &identity, nil
+}
+
+// linkedUser 返回 SAML 身份关联的本地用户，未关联时返回 nil。
+func (s *SAMLService) linkedUser(tx *gorm.DB, tenantID, subject string) (*User, error) {
+	var link OAuthAccount
+	err := tx.Where("tenant_id = ? AND provider = ? AND subject = ?", tenantID, SAMLProvider, subject).First(&link).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	if err := tx.Where("id = ? AND tenant_id = ?", link.UserID, tenantID).First(&user).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		return nil, tx.Delete(&link).Error
+	}
+	return &user, nil
+}
+
+// applyIdentity 把断言身份落到本地：current 为 nil 时创建账号并关联，否则同步昵称与邮箱。
+// 邮箱已被其他本地账号占用时不覆盖，避免按邮箱静默合并账号。
+func (s *SAMLService) applyIdentity(ctx context.Context, tx *gorm.DB, tenantID string, id *samlIdentity, current *User) (*User, error) {
+	now := time.Now()
+	email := normalizeEmail(id.Email)
+	if email != "" && !isEmailIdentifier(email) {
+		email = ""
+	}
+	if current == nil {
+		username := truncateString(id.NameID, 80)
+		if email != "" {
+			username = truncateString(strings.SplitN(email, "@", 2)[0], 80)
+		}
+		if username == "" || identifierTaken(tx, tenantID, "username", username, "") {
+			username = fmt.Sprintf("%s_%s", truncateString(username, 71), newID()[:8])
+		}
+		user := &User{
+			ID:             newID(),
+			TenantID:       tenantID,
+			Username:       username,
+			Nickname:       truncateString(id.Name, 80),
+			Status:         UserStatusNormal,
+			AuthVersion:    1,
+			RolesVersion:   1,
+			ProfileVersion: 1,
+		}
+		if email != "" && !identifierTaken(tx, tenantID, "email", email, "") {
+			user.Email = nullableString(email)
+			user.EmailVerifiedAt = &now
+		}
+		if s.m.cfg.AccountPolicy.RequireVerifiedPhone {
+			user.Status = UserStatusPending
+		}
+		if err := s.m.tenantSvc.admitUser(tx, tenantID); err != nil {
+			return nil, err
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return nil, fmt.Errorf("create user from saml: %w", err)
+		}
+		if err := tx.Create(&OAuthAccount{
+			ID:       newID(),
+			TenantID: tenantID,
+			UserID:   user.ID,
+			Provider: SAMLProvider,
+			Subject:  id.Subject,
+			Email:    email,
+			Name:     truncateString(id.Name, 160),
+		}).Error; err != nil {
+			return nil, fmt.Errorf("create saml account link: %w", err)
+		}
+		if err := s.m.auth.assignDefaultRole(ctx, tx, user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	updates := map[string]any{}
+	if name := truncateString(id.Name, 80); name != "" && name != current.Nickname {
+		updates["nickname"] = name
+		current.Nickname = name
+	}
+	if email != "" && email != stringValue(current.Email) && !identifierTaken(tx, tenantID, "email", email, current.ID) {
+		updates["email"] = email
+		updates["email_verified_at"] = now
+		current.Email = nullableString(email)
+		current.EmailVerifiedAt = &now
+	}
+	if len(updates) == 0 {
+		return current, nil
+	}
+	updates["profile_version"] = gorm.Expr("profile_version + 1")
+	if err := tx.Model(&User{}).Where("id = ?", current.ID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&OAuthAccount{}).Where("tenant_id = ? AND provider = ? AND subject = ?", tenantID, SAMLProvider, id.Subject).
+		Updates(map[string]any{"email": email, "name": truncateString(id.Name, 160)}).Error; err != nil {
+		return nil, err
+	}
+	s.m.auth.invalidateUserPrincipalCaches(ctx, current.ID)
+	return current, nil
+}
+
+// syncGroupRoles 按组映射对账用户的租户级角色：只授予/撤销映射中出现的角色。
+func (s *SAMLService) syncGroupRoles(tx *gorm.DB, tenantID string, user *User, groups []string, mapping map[string]string) (bool, error) {
+	if len(mapping) == 0 {
+		return false, nil
+	}
+	want := map[string]bool{}
+	for _, g := range groups {
+		if code := mapping[strings.ToLower(strings.TrimSpace(g))]; code != "" {
+			want[code] = true
+		}
+	}
+	codes := make([]string, 0, len(mapping))
+	for _, code := range mapping {
+		codes = append(codes, code)
+	}
+	var roles []Role
+	if err := tx.Where("tenant_id = ? AND code IN ?", tenantID, codes).Find(&roles).Error; err != nil {
+		return false, err
+	}
+	if len(roles) == 0 {
+		return false, nil
+	}
+	roleIDs := make([]string, 0, len(roles))
+	for _, r := range roles {
+		roleIDs = append(roleIDs, r.ID)
+	}
+	var current []UserRole
+	if err := tx.Where("tenant_id = ? AND user_id = ? AND scope_type = ? AND role_id IN ?", tenantID, user.ID, "tenant", roleIDs).
+		Find(&current).Error; err != nil {
+		return false, err
+	}
+	have := make(map[string]string, len(current))
+	for _, ur := range current {
+		have[ur.RoleID] = ur.ID
+	}
+	changed := false
+	for _, r := range roles {
+		grantID, granted := have[r.ID]
+		switch {
+		case want[r.Code] && !granted:
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UserRole{
+				ID:        newID(),
+				TenantID:  tenantID,
+				UserID:    user.ID,
+				RoleID:    r.ID,
+				ScopeType: "tenant",
+				ScopeID:   tenantID,
+			}).Error; err != nil {
+				return false, err
+			}
+			changed = true
+		case !want[r.Code] && granted:
+			if err := tx.Where("id = ?", grantID).Delete(&UserRole{}).Error; err != nil {
+				return false, err
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	if err := bumpRolesVersion(tx, []string{user.ID}); err != nil {
+		return false, err
+	}
+	user.RolesVersion++
+	return true, nil
+}
+
+// ============================================================================
+// 单点登出
+// ============================================================================
+
+// HandleSLO 处理 IdP 发往 SLO 端点的消息：LogoutRequest（IdP 发起的全局登出）或
+// LogoutResponse（本系统发起登出后 IdP 的回应）。
+func (s *SAMLService) HandleSLO(ctx context.Context, req SAMLSLORequest) (*SAMLRedirect, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.saml.slo")
+	defer span.End()
+	tenantID := s.m.tenantID(req.TenantID)
+	switch {
+	case req.SAMLRequest != "":
+		return s.handleLogoutRequest(ctx, tenantID, req)
+	case req.SAMLResponse != "":
+		return s.handleLogoutResponse(ctx, tenantID, req)
+	default:
+		return nil, accountError(ErrInvalidArgument, "缺少 SAMLRequest 或 SAMLResponse")
+	}
+}
+
+func (s *SAMLService) handleLogoutRequest(ctx context.Context, tenantID string, req SAMLSLORequest) (*SAMLRedirect, error) {
+	conn, md, err := s.connection(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := s.serviceProvider(tenantID, conn, md)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := samlIDPSigningCerts(md)
+	if err != nil {
+		return nil, err
+	}
+	redirect := req.Binding == saml.HTTPRedirectBinding
+	raw, err := decodeSAMLMessage(req.SAMLRequest, redirect)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(detail string) (*SAMLRedirect, error) {
+		s.m.audit(ctx, tenantID, "", "saml_slo", "failed", truncateString(detail, 500), req.IP, req.UserAgent)
+		return nil, accountError(ErrInvalidCredential, "SAML 登出请求无效")
+	}
+
+	// IdP 发起的登出必须签名：Redirect 绑定校验查询串签名，POST 绑定校验 XML 内嵌签名
+	var logoutReq saml.LogoutRequest
+	if redirect {
+		if err := verifySAMLRedirectSignature(req.RawQuery, "SAMLRequest", certs); err != nil {
+			return fail("signature: " + err.Error())
+		}
+		if err := xrv.Validate(bytes.NewReader(raw)); err != nil {
+			return fail("invalid xml: " + err.Error())
+		}
+		if err := xml.Unmarshal(raw, &logoutReq); err != nil {
+			return fail("decode: " + err.Error())
+		}
+	} else {
+		el, err := verifySAMLXMLSignature(raw, certs)
+		if err != nil {
+			return fail("signature: " + err.Error())
+		}
+		doc := etree.NewDocument()
+		doc.SetRoot(el)
+		data, err := doc.WriteToBytes()
+		if err != nil {
+			return nil, err
+		}
+		if err := xml.Unmarshal(data, &logoutReq); err != nil {
+			return fail("decode: " + err.Error())
+		}
+	}
+
+	now := saml.TimeNow()
+	switch {
+	case logoutReq.Issuer == nil || logoutReq.Issuer.Value != md.EntityID:
+		return fail("issuer mismatch")
+	case logoutReq.Destination != "" && logoutReq.Destination != sp.SloURL.String():
+		return fail("destination mismatch: " + logoutReq.Destination)
+	case logoutReq.IssueInstant.Add(saml.MaxIssueDelay + saml.MaxClockSkew).Before(now):
+		return fail("expired")
+	case logoutReq.NotOnOrAfter != nil && logoutReq.NotOnOrAfter.Add(saml.MaxClockSkew).Before(now):
+		return fail("expired")
+	case logoutReq.NameID == nil || logoutReq.NameID.Value == "":
+		return fail("missing NameID")
+	}
+	sessionIndex := ""
+	if logoutReq.SessionIndex != nil {
+		sessionIndex = logoutReq.SessionIndex.Value
+	}
+	n, err := s.revokeSessions(ctx, tenantID, logoutReq.NameID.Value, sessionIndex)
+	if err != nil {
+		return nil, err
+	}
+	s.m.audit(ctx, tenantID, "", "saml_slo", "success",
+		fmt.Sprintf("name_id=%s revoked=%d", logoutReq.NameID.Value, n), req.IP, req.UserAgent)
+
+	binding, location := samlEndpoint(md, true, req.Binding)
+	if location == "" {
+		// IdP 未发布 SLO 端点时无法回应，直接回到默认页面
+		return &SAMLRedirect{RedirectURL: s.m.cfg.SAML.DefaultRedirectURL}, nil
+	}
+	resp := &saml.LogoutResponse{
+		ID:           samlMessageID(),
+		InResponseTo: logoutReq.ID,
+		Version:      "2.0",
+		IssueInstant: now,
+		Destination:  location,
+		Issuer:       &saml.Issuer{Format: "urn:oasis:names:tc:SAML:2.0:nameid-format:entity", Value: sp.EntityID},
+		Status:       saml.Status{StatusCode: saml.StatusCode{Value: saml.StatusSuccess}},
+	}
+	return samlEncode(sp, binding, location, "SAMLResponse", resp, req.RelayState)
+}
+
+// handleLogoutResponse 结束本系统发起的登出。本地会话在发起时已吊销，LogoutResponse 只决定跳转地址，
+// InResponseTo 与一次性 RelayState 绑定，无需再校验签名。
+func (s *SAMLService) handleLogoutResponse(ctx context.Context, tenantID string, req SAMLSLORequest) (*SAMLRedirect, error) {
+	state, err := s.m.oauth.takeState(ctx, tenantID, samlLogoutProvider, req.RelayState)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeSAMLMessage(req.SAMLResponse, req.Binding == saml.HTTPRedirectBinding)
+	if err != nil {
+		return nil, err
+	}
+	if err := xrv.Validate(bytes.NewReader(raw)); err != nil {
+		return nil, accountError(ErrInvalidArgument, "SAML 登出响应无效")
+	}
+	var resp saml.LogoutResponse
+	if err := xml.Unmarshal(raw, &resp); err != nil {
+		return nil, accountError(ErrInvalidArgument, "SAML 登出响应无效")
+	}
+	if resp.InResponseTo != state.Nonce {
+		return nil, accountError(ErrInvalidCredential, "SAML 登出响应与请求不匹配")
+	}
+	if resp.Status.StatusCode.Value != saml.StatusSuccess {
+		gaia.WarnF("[account] saml logout for tenant %s returned status %s", tenantID, resp.Status.StatusCode.Value)
+	}
+	return &SAMLRedirect{RedirectURL: state.RedirectURI}, nil
+}
+
+// Logout 吊销当前会话；会话来自 SAML 登录且 IdP 支持单点登出时，返回发往 IdP 的 LogoutRequest。
+func (s *SAMLService) Logout(ctx context.Context, req SAMLLogoutRequest) (*SAMLRedirect, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.saml.logout")
+	defer span.End()
+	tenantID := s.m.tenantID(req.TenantID)
+	returnTo, err := s.returnTo(req.ReturnTo)
+	if err != nil {
+		return nil, err
+	}
+	var ss SAMLSession
+	found := s.m.db.WithContext(ctx).Where("tenant_id = ? AND session_id = ? AND user_id = ?",
+		tenantID, req.SessionID, req.UserID).First(&ss).Error == nil
+	if err := s.m.auth.Logout(ctx, LogoutRequest{AccessToken: req.AccessToken, SessionID: req.SessionID}); err != nil {
+		return nil, err
+	}
+	local := &SAMLRedirect{RedirectURL: returnTo}
+	if !found {
+		return local, nil
+	}
+	if err := s.m.db.WithContext(ctx).Where("id = ?", ss.ID).Delete(&SAMLSession{}).Error; err != nil {
+		recordDBError(ctx)
+		return nil, err
+	}
+	conn, md, err := s.connection(ctx, tenantID)
+	if err != nil {
+		// 连接已删除或停用时只完成本地登出
+		return local, nil
+	}
+	binding, location := samlEndpoint(md, true, saml.HTTPRedirectBinding)
+	if location == "" {
+		return local, nil
+	}
+	sp, err := s.serviceProvider(tenantID, conn, md)
+	if err != nil {
+		return nil, err
+	}
+	logoutReq := &saml.LogoutRequest{
+		ID:           samlMessageID(),
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Destination:  location,
+		Issuer:       &saml.Issuer{Format: "urn:oasis:names:tc:SAML:2.0:nameid-format:entity", Value: sp.EntityID},
+		NameID:       &saml.NameID{Format: ss.NameIDFormat, Value: ss.NameID},
+	}
+	if ss.SessionIndex != "" {
+		logoutReq.SessionIndex = &saml.SessionIndex{Value: ss.SessionIndex}
+	}
+	relayState, err := s.saveState(ctx, tenantID, samlLogoutProvider, logoutReq.ID, returnTo)
+	if err != nil {
+		return nil, err
+	}
+	s.m.audit(ctx, tenantID, req.UserID, "saml_logout", "success", "name_id="+ss.NameID, req.IP, req.UserAgent)
+	return samlEncode(sp, binding, location, "SAMLRequest", logoutReq, relayState)
+}
+
+// revokeSessions 吊销 NameID（及 SessionIndex）对应的全部本地会话。
+func (s *SAMLService) revokeSessions(ctx context.Context, tenantID, nameID, sessionIndex string) (int, error) {
+	q := s.m.db.WithContext(ctx).Where("tenant_id = ? AND name_id = ?", tenantID, nameID)
+	if sessionIndex != "" {
+		q = q.Where("session_index = ?", sessionIndex)
+	}
+	var rows []SAMLSession
+	if err := q.Find(&rows).Error; err != nil {
+		recordDBError(ctx)
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, 0, len(rows))
+	sessionIDs := make([]string, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.ID)
+		sessionIDs = append(sessionIDs, r.SessionID)
+	}
+	now := time.Now()
+	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Session{}).Where("id IN ? AND status = ?", sessionIDs, SessionActive).Updates(map[string]any{
+			"status":     SessionRevoked,
+			"revoked_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&RefreshToken{}).Where("session_id IN ?", sessionIDs).Update("status", RefreshRevoked).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&SAMLSession{}).Error
+	})
+	if err != nil {
+		recordDBError(ctx)
+		return 0, err
+	}
+	for _, r := range rows {
+		s.m.auth.invalidatePrincipalCache(ctx, r.SessionID)
+		_ = emitOutbox(s.m.db.WithContext(ctx), EventUserLoggedOut, r.UserID, map[string]any{
+			"user_id":       r.UserID,
+			"session_id":    r.SessionID,
+			"reason":        "saml_slo",
+			"logged_out_at": now,
+		})
+	}
+	return len(rows), nil
+}
+
+// ============================================================================
+// 辅助函数
+// ============================================================================
+
+func (s *SAMLService) tenantBaseURL(tenantID string) string {
+	return strings.TrimSuffix(s.m.cfg.SAML.BaseURL, "/") + "/saml/" + url.PathEscape(tenantID)
+}
+
+// serviceProvider 构造租户的 SP 实例。SP EntityID 即其元数据地址，每个租户独立。
+func (s *SAMLService) serviceProvider(tenantID string, conn *SAMLConnection, md *saml.EntityDescriptor) (*saml.ServiceProvider, error) {
+	base := s.tenantBaseURL(tenantID)
+	metadataURL, err := url.Parse(base + "/metadata")
+	if err != nil {
+		return nil, fmt.Errorf("saml base url: %w", err)
+	}
+	acsURL, _ := url.Parse(base + "/acs")
+	sloURL, _ := url.Parse(base + "/slo")
+	sp := &saml.ServiceProvider{
+		EntityID:          metadataURL.String(),
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		SloURL:            *sloURL,
+		IDPMetadata:       md,
+		LogoutBindings:    []string{saml.HTTPRedirectBinding, saml.HTTPPostBinding},
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+	}
+	key, cert, err := s.keyPair()
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		sp.Key, sp.Certificate = key, cert
+		if s.m.cfg.SAML.SignRequests {
+			sp.SignatureMethod = dsig.RSASHA256SignatureMethod
+			if _, ok := key.(*ecdsa.PrivateKey); ok {
+				sp.SignatureMethod = dsig.ECDSASHA256SignatureMethod
+			}
+		}
+	}
+	if conn != nil {
+		sp.AllowIDPInitiated = conn.AllowIDPInitiated
+		if conn.NameIDFormat != "" {
+			sp.AuthnNameIDFormat = saml.NameIDFormat(conn.NameIDFormat)
+		}
+	}
+	return sp, nil
+}
+
+func (s *SAMLService) keyPair() (crypto.Signer, *x509.Certificate, error) {
+	s.keyOnce.Do(func() {
+		s.key, s.cert, s.keyErr = parseSAMLKeyPair(s.m.cfg.SAML.Certificate, s.m.cfg.SAML.PrivateKey)
+	})
+	return s.key, s.cert, s.keyErr
+}
+
+// returnTo 校验登录/登出后的跳转地址：为空时使用 DefaultRedirectURL，
+// 否则必须与 DefaultRedirectURL 或 AllowedRedirectURLs 之一同源且路径前缀匹配。
+func (s *SAMLService) returnTo(raw string) (string, error) {
+	cfg := s.m.cfg.SAML
+	if raw == "" {
+		return cfg.DefaultRedirectURL, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") || u.User != nil {
+		return "", accountError(ErrInvalidArgument, "return_to 无效")
+	}
+	for _, allowed := range append([]string{cfg.DefaultRedirectURL}, cfg.AllowedRedirectURLs...) {
+		a, err := url.Parse(allowed)
+		if err != nil || a.Host == "" {
+			continue
+		}
+		if strings.EqualFold(a.Scheme, u.Scheme) && strings.EqualFold(a.Host, u.Host) && strings.HasPrefix(u.Path, a.Path) {
+			return u.String(), nil
+		}
+	}
+	return "", accountError(ErrInvalidArgument, "return_to 不在允许的跳转地址中")
+}
+
+// saveState 保存一次性 RelayState，nonce 记录对应的 SAML 请求 ID。
+func (s *SAMLService) saveState(ctx context.Context, tenantID, provider, requestID, returnTo string) (string, error) {
+	relayState, err := generateAuthCode()
+	if err != nil {
+		return "", err
+	}
+	if err := s.m.db.WithContext(ctx).Create(&OAuthState{
+		State:       relayState,
+		TenantID:    tenantID,
+		Provider:    provider,
+		Nonce:       requestID,
+		RedirectURI: returnTo,
+		ExpiresAt:   time.Now().Add(oauthStateTTL),
+	}).Error; err != nil {
+		recordDBError(ctx)
+		return "", fmt.Errorf("create saml relay state: %w", err)
+	}
+	return relayState, nil
+}
+
+// samlIdentityFrom 从已校验的断言中提取身份与映射的属性。
+func samlIdentityFrom(a *saml.Assertion, mapping SAMLAttributeMapping) (*samlIdentity, error) {
+	if a.Subject == nil || a.Subject.NameID == nil || a.Subject.NameID.Value == "" {
+		return nil, accountError(ErrInvalidCredential, "SAML 断言缺少 NameID")
+	}
+	attrs := map[string][]string{}
+	for _, st := range a.AttributeStatements {
+		for _, attr := range st.Attributes {
+			var values []string
+			for _, v := range attr.Values {
+				if v.Value = strings.TrimSpace(v.Value); v.Value != "" {
+					values = append(values, v.Value)
+				}
+			}
+			for _, name := range []string{attr.Name, attr.FriendlyName} {
+				if key := strings.ToLower(name); key != "" {
+					attrs[key] = append(attrs[key], values...)
+				}
+			}
+		}
+	}
+	lookup := func(candidates string) []string {
+		for _, name := range strings.Split(candidates, ",") {
+			if values := attrs[strings.ToLower(strings.TrimSpace(name))]; len(values) > 0 {
+				return values
+			}
+		}
+		return nil
+	}
+	first := func(candidates string) string {
+		if values := lookup(candidates); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	id := &samlIdentity{
+		NameID:       a.Subject.NameID.Value,
+		NameIDFormat: a.Subject.NameID.Format,
+		Email:        first(mapping.Email),
+		Name:         first(mapping.Name),
+		Groups:       lookup(mapping.Groups),
+	}
+	if len(a.AuthnStatements) > 0 {
+		id.SessionIndex = a.AuthnStatements[0].SessionIndex
+	}
+	if mapping.Subject != "" {
+		id.Subject = first(mapping.Subject)
+		if id.Subject == "" {
+			return nil, accountError(ErrInvalidCredential, "SAML 断言缺少身份属性")
+		}
+	} else {
+		if id.NameIDFormat == string(saml.TransientNameIDFormat) {
+			return nil, accountError(ErrInvalidCredential, "transient NameID 需要配置 attribute_mapping.subject")
+		}
+		id.Subject = id.NameID
+	}
+	if len(id.Subject) > samlSubjectMaxLen {
+		sum := sha256.Sum256([]byte(id.Subject))
+		id.Subject = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	if id.Email == "" && id.NameIDFormat == string(saml.EmailAddressNameIDFormat) {
+		id.Email = id.NameID
+	}
+	sort.Strings(id.Groups)
+	return id, nil
+}
+
+// parseSAMLMetadata 解析 IdP 元数据，兼容单个 EntityDescriptor 与 EntitiesDescriptor 聚合文件。
+func parseSAMLMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	if err := xrv.Validate(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	var candidates []saml.EntityDescriptor
+	var ed saml.EntityDescriptor
+	if err := xml.Unmarshal(data, &ed); err == nil {
+		candidates = append(candidates, ed)
+	} else {
+		var eds saml.EntitiesDescriptor
+		if err := xml.Unmarshal(data, &eds); err != nil {
+			return nil, err
+		}
+		candidates = eds.EntityDescriptors
+	}
+	for i := range candidates {
+		md := &candidates[i]
+		if md.EntityID == "" || len(md.IDPSSODescriptors) == 0 {
+			continue
+		}
+		if _, location := samlEndpoint(md, false, saml.HTTPRedirectBinding); location == "" {
+			return nil, errors.New("missing SingleSignOnService")
+		}
+		if _, err := samlIDPSigningCerts(md); err != nil {
+			return nil, err
+		}
+		return md, nil
+	}
+	return nil, errors.New("no IDPSSODescriptor found")
+}
+
+// samlEndpoint 选择 IdP 的 SSO/SLO 端点，优先使用 preferred 绑定，否则回退到另一种绑定。
+// 登出端点返回 ResponseLocation（如有）以便回应 LogoutRequest。
+func samlEndpoint(md *saml.EntityDescriptor, logout bool, preferred string) (binding, location string) {
+	bindings := []string{preferred, saml.HTTPRedirectBinding, saml.HTTPPostBinding}
+	for _, b := range bindings {
+		for _, d := range md.IDPSSODescriptors {
+			endpoints := d.SingleSignOnServices
+			if logout {
+				endpoints = d.SingleLogoutServices
+			}
+			for _, e := range endpoints {
+				if e.Binding != b || e.Location == "" {
+					continue
+				}
+				if logout && e.ResponseLocation != "" {
+					return b, e.ResponseLocation
+				}
+				return b, e.Location
+			}
+		}
+	}
+	return "", ""
+}
+
+var samlWhitespace = regexp.MustCompile(`\s+`)
+
+// samlIDPSigningCerts 返回 IdP 元数据中 use=signing（或未标注用途）的证书。
+func samlIDPSigningCerts(md *saml.EntityDescriptor) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, d := range md.IDPSSODescriptors {
+		for _, kd := range d.KeyDescriptors {
+			if kd.Use != "" && kd.Use != "signing" {
+				continue
+			}
+			for _, c := range kd.KeyInfo.X509Data.X509Certificates {
+				der, err := base64.StdEncoding.DecodeString(samlWhitespace.ReplaceAllString(c.Data, ""))
+				if err != nil {
+					return nil, fmt.Errorf("decode idp certificate: %w", err)
+				}
+				cert, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, fmt.Errorf("parse idp certificate: %w", err)
+				}
+				certs = append(certs, cert)
+			}
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no IdP signing certificate found")
+	}
+	return certs, nil
+}
+
+// parseSAMLKeyPair 解析 SP 证书与私钥（PEM），私钥支持 PKCS#8、PKCS#1 与 SEC1 EC 格式。
+func parseSAMLKeyPair(certPEM, keyPEM string) (crypto.Signer, *x509.Certificate, error) {
+	if certPEM == "" && keyPEM == "" {
+		return nil, nil, nil
+	}
+	if certPEM == "" || keyPEM == "" {
+		return nil, nil, errors.New("account saml Certificate and PrivateKey must be set together")
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, nil, errors.New("account saml Certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("account saml Certificate: %w", err)
+	}
+	block, _ = pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, nil, errors.New("account saml PrivateKey is not PEM encoded")
+	}
+	var key any
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+				return nil, nil, errors.New("account saml PrivateKey must be a PKCS#8, PKCS#1 or EC private key")
+			}
+		}
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, cert, nil
+	case *ecdsa.PrivateKey:
+		return k, cert, nil
+	default:
+		return nil, nil, fmt.Errorf("account saml PrivateKey type %T is not supported", key)
+	}
+}
+
+// decodeSAMLMessage 解码 SAML 协议消息：POST 绑定为 base64，Redirect 绑定为 DEFLATE 后再 base64。
+func decodeSAMLMessage(data string, deflated bool) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(samlWhitespace.ReplaceAllString(data, ""))
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, "SAML 消息编码无效")
+	}
+	if deflated {
+		raw, err = io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(raw)), samlMaxMessageSize+1))
+		if err != nil {
+			return nil, accountError(ErrInvalidArgument, "SAML 消息压缩格式无效")
+		}
+	}
+	if len(raw) > samlMaxMessageSize {
+		return nil, accountError(ErrInvalidArgument, "SAML 消息过大")
+	}
+	return raw, nil
+}
+
+// verifySAMLXMLSignature 校验消息根元素的内嵌签名，返回签名覆盖的元素。
+// 后续只能使用返回的元素，避免签名包装攻击。
+func verifySAMLXMLSignature(raw []byte, certs []*x509.Certificate) (*etree.Element, error) {
+	if err := xrv.Validate(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return nil, err
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, errors.New("empty document")
+	}
+	if root.FindElement("./Signature") == nil {
+		return nil, errors.New("message is not signed")
+	}
+	vc := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: certs})
+	vc.IdAttribute = "ID"
+	if saml.Clock != nil {
+		vc.Clock = saml.Clock
+	}
+	return vc.Validate(root)
+}
+
+// verifySAMLRedirectSignature 校验 HTTP-Redirect 绑定的查询串签名。
+// 签名串按收到的原始编码拼接 "param=...&RelayState=...&SigAlg=..."，不能重新编码。
+func verifySAMLRedirectSignature(rawQuery, param string, certs []*x509.Certificate) error {
+	values := map[string]string{}
+	for _, part := range strings.Split(rawQuery, "&") {
+		k, v, _ := strings.Cut(part, "=")
+		if _, dup := values[k]; dup {
+			return fmt.Errorf("duplicate query parameter %s", k)
+		}
+		values[k] = v
+	}
+	if values[param] == "" || values["SigAlg"] == "" || values["Signature"] == "" {
+		return errors.New("message is not signed")
+	}
+	signed := param + "=" + values[param]
+	if rs, ok := values["RelayState"]; ok {
+		signed += "&RelayState=" + rs
+	}
+	signed += "&SigAlg=" + values["SigAlg"]
+
+	sigAlg, err := url.QueryUnescape(values["SigAlg"])
+	if err != nil {
+		return err
+	}
+	sigB64, err := url.QueryUnescape(values["Signature"])
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return err
+	}
+	var hash crypto.Hash
+	switch sigAlg {
+	case dsig.RSASHA1SignatureMethod, dsig.ECDSASHA1SignatureMethod:
+		hash = crypto.SHA1
+	case dsig.RSASHA256SignatureMethod, dsig.ECDSASHA256SignatureMethod:
+		hash = crypto.SHA256
+	case dsig.RSASHA384SignatureMethod, dsig.ECDSASHA384SignatureMethod:
+		hash = crypto.SHA384
+	case dsig.RSASHA512SignatureMethod, dsig.ECDSASHA512SignatureMethod:
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported SigAlg %s", sigAlg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	isECDSA := strings.Contains(sigAlg, "ecdsa")
+	for _, cert := range certs {
+		switch pub := cert.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if !isECDSA && rsa.VerifyPKCS1v15(pub, hash, digest, sig) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if !isECDSA {
+				continue
+			}
+			// XML DSig 的 ECDSA 签名为 r||s 定长拼接，部分实现使用 ASN.1 编码
+			if ecdsa.VerifyASN1(pub, digest, sig) {
+				return nil
+			}
+			if len(sig)%2 == 0 {
+				r := new(big.Int).SetBytes(sig[:len(sig)/2])
+				s := new(big.Int).SetBytes(sig[len(sig)/2:])
+				if ecdsa.Verify(pub, digest, r, s) {
+					return nil
+				}
+			}
+		}
+	}
+	return errors.New("signature verification failed")
+}
+
+var samlPostFormTemplate = template.Must(template.New("saml-post").Parse(`<!DOCTYPE html>` +
+	`<html><body onload="document.forms[0].submit()">` +
+	`<form method="post" action="{{.URL}}">` +
+	`<input type="hidden" name="{{.Param}}" value="{{.Value}}" />` +
+	`{{if .RelayState}}<input type="hidden" name="RelayState" value="{{.RelayState}}" />{{end}}` +
+	`<noscript><input type="submit" value="Continue" /></noscript>` +
+	`</form></body></html>`))
+
+// samlEncode 按绑定编码发往 IdP 的消息。POST 绑定在 XML 内嵌签名；
+// Redirect 绑定对 DEFLATE 编码后的查询串签名，消息本身不内嵌签名。
+func samlEncode(sp *saml.ServiceProvider, binding, destination, param string, msg interface{ Element() *etree.Element }, relayState string) (*SAMLRedirect, error) {
+	signing := sp.SignatureMethod != ""
+	if binding == saml.HTTPPostBinding {
+		if signing {
+			var err error
+			switch m := msg.(type) {
+			case *saml.AuthnRequest:
+				if m.Signature == nil {
+					err = sp.SignAuthnRequest(m)
+				}
+			case *saml.LogoutRequest:
+				err = sp.SignLogoutRequest(m)
+			case *saml.LogoutResponse:
+				err = sp.SignLogoutResponse(m)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("sign saml message: %w", err)
+			}
+		}
+		doc := etree.NewDocument()
+		doc.SetRoot(msg.Element())
+		data, err := doc.WriteToBytes()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := samlPostFormTemplate.Execute(&buf, map[string]string{
+			"URL":        destination,
+			"Param":      param,
+			"Value":      base64.StdEncoding.EncodeToString(data),
+			"RelayState": relayState,
+		}); err != nil {
+			return nil, err
+		}
+		return &SAMLRedirect{PostForm: buf.String()}, nil
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(msg.Element())
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	if _, err := doc.WriteTo(fw); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+	query := param + "=" + url.QueryEscape(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	if signing {
+		query += "&SigAlg=" + url.QueryEscape(sp.SignatureMethod)
+		sc, err := saml.GetSigningContext(sp)
+		if err != nil {
+			return nil, fmt.Errorf("saml signing context: %w", err)
+		}
+		sig, err := sc.SignString(query)
+		if err != nil {
+			return nil, fmt.Errorf("sign saml message: %w", err)
+		}
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(sig))
+	}
+	u, err := url.Parse(destination)
+	if err != nil {
+		return nil, fmt.Errorf("saml destination: %w", err)
+	}
+	if u.RawQuery != "" {
+		query = u.RawQuery + "&" + query
+	}
+	u.RawQuery = query
+	return &SAMLRedirect{RedirectURL: u.String()}, nil
+}
+
+// samlMessageID 生成 SAML 消息 ID（xs:ID 不能以数字开头）。
+func samlMessageID() string {
+	return "id-" + strings.ReplaceAll(newID(), "-", "")
+}
+
+// ============================================================================
+// HTTP 端点
+// ============================================================================
+
+// RegisterRoutes 在 r 上注册浏览器直接访问的 SAML 端点，r 通常为 "/saml" 分组：
+//
+//	GET  /:tenant/metadata  SP 元数据
+//	GET  /:tenant/login     发起登录（?return_to=）
+//	POST /:tenant/acs       断言消费端点
+//	GET|POST /:tenant/slo   单点登出
+func (s *SAMLService) RegisterRoutes(r *route.RouterGroup) {
+	r.GET("/:tenant/metadata", s.handleMetadata)
+	r.GET("/:tenant/login", s.handleStartLogin)
+	r.POST("/:tenant/acs", s.handleACS)
+	r.GET("/:tenant/slo", s.handleSLO)
+	r.POST("/:tenant/slo", s.handleSLO)
+}
+
+func (s *SAMLService) handleMetadata(ctx context.Context, c *app.RequestContext) {
+	data, err := s.Metadata(ctx, c.Param("tenant"))
+	if err != nil {
+		writeSAMLError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", data)
+}
+
+func (s *SAMLService) handleStartLogin(ctx context.Context, c *app.RequestContext) {
+	res, err := s.StartLogin(ctx, SAMLLoginStartRequest{
+		TenantID: c.Param("tenant"),
+		ReturnTo: c.Query("return_to"),
+	})
+	if err != nil {
+		writeSAMLError(c, err)
+		return
+	}
+	writeSAMLRedirect(c, res)
+}
+
+func (s *SAMLService) handleACS(ctx context.Context, c *app.RequestContext) {
+	target, err := s.ConsumeResponse(ctx, SAMLResponseRequest{
+		TenantID:     c.Param("tenant"),
+		SAMLResponse: string(c.FormValue("SAMLResponse")),
+		RelayState:   string(c.FormValue("RelayState")),
+		IP:           c.ClientIP(),
+		UserAgent:    string(c.UserAgent()),
+	})
+	if err != nil {
+		writeSAMLError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, []byte(target))
+}
+
+func (s *SAMLService) handleSLO(ctx context.Context, c *app.RequestContext) {
+	req := SAMLSLORequest{
+		TenantID:  c.Param("tenant"),
+		Binding:   saml.HTTPRedirectBinding,
+		RawQuery:  string(c.URI().QueryString()),
+		IP:        c.ClientIP(),
+		UserAgent: string(c.UserAgent()),
+	}
+	if string(c.Method()) == http.MethodPost {
+		req.Binding = saml.HTTPPostBinding
+		req.SAMLRequest = string(c.PostForm("SAMLRequest"))
+		req.SAMLResponse = string(c.PostForm("SAMLResponse"))
+		req.RelayState = string(c.PostForm("RelayState"))
+	} else {
+		req.SAMLRequest = c.Query("SAMLRequest")
+		req.SAMLResponse = c.Query("SAMLResponse")
+		req.RelayState = c.Query("RelayState")
+	}
+	res, err := s.HandleSLO(ctx, req)
+	if err != nil {
+		writeSAMLError(c, err)
+		return
+	}
+	writeSAMLRedirect(c, res)
+}
+
+func writeSAMLRedirect(c *app.RequestContext, res *SAMLRedirect) {
+	if res.PostForm != "" {
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(res.PostForm))
+		return
+	}
+	c.Redirect(http.StatusFound, []byte(res.RedirectURL))
+}
+
+// writeSAMLError 浏览器端点以纯文本返回错误，内部错误不暴露细节。
+func writeSAMLError(c *app.RequestContext, err error) {
+	status, msg := http.StatusInternalServerError, "internal error"
+	var le errwrap.LogicError
+	if errors.As(err, &le) {
+		if code := int(le.GetCode()); code >= 400 && code < 600 {
+			status, msg = code, le.GetMessage()
+		}
+	}
+	if status == http.StatusInternalServerError {
+		gaia.ErrorF("[account] saml endpoint %s failed: %v", c.FullPath(), err)
+	}
+	c.AbortWithStatus(status)
+	c.Data(status, "text/plain; charset=utf-8", []byte(msg))
+}
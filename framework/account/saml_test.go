@@ -0,0 +1,416 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/xxzhwl/gaia/errwrap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const samlTestBaseURL = "https://account.example.com"
+
+func samlTestKeyPair(t *testing.T, cn string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+// samlTestSPProvider 让测试 IdP 通过 Manager 获取 SP 元数据。
+type samlTestSPProvider struct{ m *Manager }
+
+func (p samlTestSPProvider) GetServiceProvider(_ *http.Request, id string) (*saml.EntityDescriptor, error) {
+	data, err := p.m.SAML().Metadata(context.Background(), "default")
+	if err != nil {
+		return nil, err
+	}
+	var md saml.EntityDescriptor
+	if err := xml.Unmarshal(data, &md); err != nil {
+		return nil, err
+	}
+	if md.EntityID != id {
+		return nil, os.ErrNotExist
+	}
+	return &md, nil
+}
+
+func newSAMLTestManager(t *testing.T) (*Manager, *saml.IdentityProvider) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "saml.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spKey, spCert := samlTestKeyPair(t, "sp.test")
+	cfg := testAuthConfig()
+	cfg.DB = db
+	cfg.SAML = SAMLConfig{
+		BaseURL:             samlTestBaseURL,
+		Certificate:         string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: spCert.Raw})),
+		PrivateKey:          string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(spKey)})),
+		SignRequests:        true,
+		DefaultRedirectURL:  "https://app.example.com/sso/callback",
+		AllowedRedirectURLs: []string{"https://app.example.com/"},
+	}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Bootstrap(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	idpKey, idpCert := samlTestKeyPair(t, "idp.test")
+	mustURL := func(s string) url.URL {
+		u, _ := url.Parse(s)
+		return *u
+	}
+	idp := &saml.IdentityProvider{
+		Key:                     idpKey,
+		Certificate:             idpCert,
+		MetadataURL:             mustURL("https://idp.example.com/metadata"),
+		SSOURL:                  mustURL("https://idp.example.com/sso"),
+		LogoutURL:               mustURL("https://idp.example.com/slo"),
+		ServiceProviderProvider: samlTestSPProvider{m: m},
+		SignatureMethod:         dsig.RSASHA256SignatureMethod,
+	}
+	if err := db.Create(&Role{ID: newID(), TenantID: "default", Code: "engineer", Name: "Engineer"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := xml.Marshal(idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SAML().SaveConnection(context.Background(), SAMLConnectionRequest{
+		Metadata:         string(metadata),
+		AttributeMapping: SAMLAttributeMapping{Name: "cn", Groups: "eduPersonAffiliation"},
+		GroupRoleMapping: map[string]string{"Engineering": "engineer"},
+	}); err != nil {
+		t.Fatalf("保存 SAML 连接失败: %v", err)
+	}
+	return m, idp
+}
+
+// samlTestSSO 模拟浏览器跳转到 IdP 并返回 IdP POST 到 ACS 的表单。
+func samlTestSSO(t *testing.T, idp *saml.IdentityProvider, redirectURL string, session *saml.Session) (samlResponse, relayState string) {
+	t.Helper()
+	r, err := http.NewRequest(http.MethodGet, redirectURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := saml.NewIdpAuthnRequest(idp, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("IdP 校验 AuthnRequest 失败: %v", err)
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		t.Fatal(err)
+	}
+	form, err := req.PostBinding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.URL != samlTestBaseURL+"/saml/default/acs" {
+		t.Fatalf("ACS 地址不正确: %s", form.URL)
+	}
+	return form.SAMLResponse, form.RelayState
+}
+
+func samlTestSession(nameID string, groups ...string) *saml.Session {
+	return &saml.Session{
+		ID:             newID(),
+		NameID:         nameID,
+		NameIDFormat:   string(saml.PersistentNameIDFormat),
+		Index:          "idx-" + nameID,
+		UserEmail:      nameID + "@corp.test",
+		UserCommonName: "User " + nameID,
+		Groups:         groups,
+		CreateTime:     time.Now(),
+		ExpireTime:     time.Now().Add(time.Hour),
+	}
+}
+
+func samlTestLogin(t *testing.T, m *Manager, idp *saml.IdentityProvider, session *saml.Session) *AuthResult {
+	t.Helper()
+	ctx := context.Background()
+	start, err := m.SAML().StartLogin(ctx, SAMLLoginStartRequest{ReturnTo: "https://app.example.com/done"})
+	if err != nil {
+		t.Fatalf("发起 SAML 登录失败: %v", err)
+	}
+	if !strings.HasPrefix(start.RedirectURL, "https://idp.example.com/sso?") || !strings.Contains(start.RedirectURL, "&Signature=") {
+		t.Fatalf("应使用签名的 Redirect 绑定跳转到 IdP: %s", start.RedirectURL)
+	}
+	resp, relay := samlTestSSO(t, idp, start.RedirectURL, session)
+	target, err := m.SAML().ConsumeResponse(ctx, SAMLResponseRequest{SAMLResponse: resp, RelayState: relay})
+	if err != nil {
+		t.Fatalf("ACS 处理断言失败: %v", err)
+	}
+	u, _ := url.Parse(target)
+	if u.Host != "app.example.com" || u.Path != "/done" || u.Query().Get(samlTicketParam) == "" {
+		t.Fatalf("ACS 应跳回 return_to 并携带票据: %s", target)
+	}
+	if _, err := m.SAML().ConsumeResponse(ctx, SAMLResponseRequest{SAMLResponse: resp, RelayState: relay}); err == nil {
+		t.Fatal("同一 RelayState 不能重复使用")
+	}
+	ticket := u.Query().Get(samlTicketParam)
+	result, err := m.SAML().Login(ctx, SAMLLoginRequest{Ticket: ticket})
+	if err != nil {
+		t.Fatalf("票据登录失败: %v", err)
+	}
+	if _, err := m.SAML().Login(ctx, SAMLLoginRequest{Ticket: ticket}); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("票据只能使用一次，got %v", err)
+	}
+	return result
+}
+
+func TestSAMLLoginProvisionsAndSyncsRoles(t *testing.T) {
+	m, idp := newSAMLTestManager(t)
+	ctx := context.Background()
+
+	result := samlTestLogin(t, m, idp, samlTestSession("alice", "Engineering", "Staff"))
+	if result.AccessToken == "" || result.User.Nickname != "User alice" || result.User.Email != "alice@corp.test" {
+		t.Fatalf("首次登录应创建账号并同步资料: %+v", result.User)
+	}
+	p, err := m.Auth().Validate(ctx, result.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(p.Roles, "engineer") {
+		t.Fatalf("IdP 组应映射为角色: %v", p.Roles)
+	}
+
+	// 再次登录不再属于映射组：撤销映射角色，仍关联到同一账号
+	again := samlTestLogin(t, m, idp, samlTestSession("alice", "Staff"))
+	if again.User.ID != result.User.ID {
+		t.Fatal("同一 NameID 应登录到同一账号")
+	}
+	p, err = m.Auth().Validate(ctx, again.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contains(p.Roles, "engineer") {
+		t.Fatalf("离开映射组后应撤销角色: %v", p.Roles)
+	}
+}
+
+func TestSAMLRejectsTamperedResponse(t *testing.T) {
+	m, idp := newSAMLTestManager(t)
+	ctx := context.Background()
+	start, err := m.SAML().StartLogin(ctx, SAMLLoginStartRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, relay := samlTestSSO(t, idp, start.RedirectURL, samlTestSession("bob"))
+
+	// 换成其他 IdP 的密钥签名：证书不在元数据中，必须拒绝
+	otherKey, otherCert := samlTestKeyPair(t, "evil.test")
+	evil := *idp
+	evil.Key, evil.Certificate = otherKey, otherCert
+	start2, err := m.SAML().StartLogin(ctx, SAMLLoginStartRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evilResp, evilRelay := samlTestSSO(t, &evil, start2.RedirectURL, samlTestSession("bob"))
+	if _, err := m.SAML().ConsumeResponse(ctx, SAMLResponseRequest{SAMLResponse: evilResp, RelayState: evilRelay}); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("非 IdP 签名的断言应被拒绝，got %v", err)
+	}
+	// 未经 SP 发起且未允许 IdP 发起登录时必须携带 RelayState
+	if _, err := m.SAML().ConsumeResponse(ctx, SAMLResponseRequest{SAMLResponse: resp}); err == nil {
+		t.Fatal("缺少 RelayState 应被拒绝")
+	}
+	if _, err := m.SAML().StartLogin(ctx, SAMLLoginStartRequest{ReturnTo: "https://evil.example.com/"}); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("return_to 不在白名单时应拒绝，got %v", err)
+	}
+	if _, err := m.SAML().ConsumeResponse(ctx, SAMLResponseRequest{SAMLResponse: resp, RelayState: relay}); err != nil {
+		t.Fatalf("原始断言应可用: %v", err)
+	}
+}
+
+func TestSAMLIdPInitiatedLogout(t *testing.T) {
+	m, idp := newSAMLTestManager(t)
+	ctx := context.Background()
+	result := samlTestLogin(t, m, idp, samlTestSession("carol"))
+
+	logoutReq := &saml.LogoutRequest{
+		ID:           "id-idp-logout-1",
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Destination:  samlTestBaseURL + "/saml/default/slo",
+		Issuer:       &saml.Issuer{Value: idp.MetadataURL.String()},
+		NameID:       &saml.NameID{Format: string(saml.PersistentNameIDFormat), Value: "carol"},
+		SessionIndex: &saml.SessionIndex{Value: "idx-carol"},
+	}
+	// 复用 SP 编码逻辑，以 IdP 的密钥对查询串签名
+	idpSigner := &saml.ServiceProvider{Key: idp.Key.(*rsa.PrivateKey), Certificate: idp.Certificate, SignatureMethod: dsig.RSASHA256SignatureMethod}
+	unsigned, err := samlEncode(&saml.ServiceProvider{}, saml.HTTPRedirectBinding, logoutReq.Destination, "SAMLRequest", logoutReq, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SAML().HandleSLO(ctx, samlTestSLORequest(t, unsigned.RedirectURL)); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("未签名的 LogoutRequest 应被拒绝，got %v", err)
+	}
+	if _, err := m.Auth().Validate(ctx, result.AccessToken); err != nil {
+		t.Fatalf("拒绝的登出请求不应吊销会话: %v", err)
+	}
+
+	signed, err := samlEncode(idpSigner, saml.HTTPRedirectBinding, logoutReq.Destination, "SAMLRequest", logoutReq, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := m.SAML().HandleSLO(ctx, samlTestSLORequest(t, signed.RedirectURL))
+	if err != nil {
+		t.Fatalf("处理 IdP 登出请求失败: %v", err)
+	}
+	if _, err := m.Auth().Validate(ctx, result.AccessToken); err == nil {
+		t.Fatal("IdP 登出后本地会话应失效")
+	}
+	u, _ := url.Parse(res.RedirectURL)
+	if u.Host != "idp.example.com" || u.Query().Get("RelayState") != "r1" || u.Query().Get("Signature") == "" {
+		t.Fatalf("应以签名的 LogoutResponse 回应 IdP: %s", res.RedirectURL)
+	}
+	raw, err := decodeSAMLMessage(u.Query().Get("SAMLResponse"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp saml.LogoutResponse
+	if err := xml.Unmarshal(raw, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.InResponseTo != logoutReq.ID || resp.Status.StatusCode.Value != saml.StatusSuccess {
+		t.Fatalf("LogoutResponse 内容不正确: %+v", resp)
+	}
+}
+
+func TestSAMLSPInitiatedLogout(t *testing.T) {
+	m, idp := newSAMLTestManager(t)
+	ctx := context.Background()
+	result := samlTestLogin(t, m, idp, samlTestSession("dave"))
+	p, err := m.Auth().Validate(ctx, result.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := m.SAML().Logout(ctx, SAMLLogoutRequest{TenantID: p.TenantID, UserID: p.UserID, SessionID: p.SessionID})
+	if err != nil {
+		t.Fatalf("SP 发起登出失败: %v", err)
+	}
+	if _, err := m.Auth().Validate(ctx, result.AccessToken); err == nil {
+		t.Fatal("登出后本地会话应立即失效")
+	}
+	u, _ := url.Parse(res.RedirectURL)
+	if u.Host != "idp.example.com" || u.Path != "/slo" {
+		t.Fatalf("应跳转到 IdP 单点登出地址: %s", res.RedirectURL)
+	}
+	raw, err := decodeSAMLMessage(u.Query().Get("SAMLRequest"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logoutReq saml.LogoutRequest
+	if err := xml.Unmarshal(raw, &logoutReq); err != nil {
+		t.Fatal(err)
+	}
+	if logoutReq.NameID == nil || logoutReq.NameID.Value != "dave" || logoutReq.SessionIndex == nil || logoutReq.SessionIndex.Value != "idx-dave" {
+		t.Fatalf("LogoutRequest 应携带 NameID 与 SessionIndex: %+v", logoutReq)
+	}
+
+	// IdP 回应 LogoutResponse 后跳回默认页面
+	logoutResp := &saml.LogoutResponse{
+		ID:           "id-idp-resp-1",
+		InResponseTo: logoutReq.ID,
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Issuer:       &saml.Issuer{Value: idp.MetadataURL.String()},
+		Status:       saml.Status{StatusCode: saml.StatusCode{Value: saml.StatusSuccess}},
+	}
+	back, err := samlEncode(&saml.ServiceProvider{}, saml.HTTPRedirectBinding, samlTestBaseURL+"/saml/default/slo",
+		"SAMLResponse", logoutResp, u.Query().Get("RelayState"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.SAML().HandleSLO(ctx, samlTestSLORequest(t, back.RedirectURL))
+	if err != nil {
+		t.Fatalf("处理 LogoutResponse 失败: %v", err)
+	}
+	if done.RedirectURL != m.cfg.SAML.DefaultRedirectURL {
+		t.Fatalf("登出完成后应跳回默认地址: %s", done.RedirectURL)
+	}
+	if _, err := m.SAML().HandleSLO(ctx, samlTestSLORequest(t, back.RedirectURL)); err == nil {
+		t.Fatal("LogoutResponse 的 RelayState 只能使用一次")
+	}
+}
+
+func samlTestSLORequest(t *testing.T, redirectURL string) SAMLSLORequest {
+	t.Helper()
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	return SAMLSLORequest{
+		Binding:      saml.HTTPRedirectBinding,
+		RawQuery:     u.RawQuery,
+		SAMLRequest:  q.Get("SAMLRequest"),
+		SAMLResponse: q.Get("SAMLResponse"),
+		RelayState:   q.Get("RelayState"),
+	}
+}
+
+func TestSAMLIdentityFrom(t *testing.T) {
+	assertion := &saml.Assertion{
+		Subject: &saml.Subject{NameID: &saml.NameID{Format: string(saml.TransientNameIDFormat), Value: "_t1"}},
+		AttributeStatements: []saml.AttributeStatement{{Attributes: []saml.Attribute{
+			{Name: "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress", Values: []saml.AttributeValue{{Value: "Eve@Corp.Test"}}},
+			{Name: "urn:oid:0.9.2342.19200300.100.1.1", FriendlyName: "uid", Values: []saml.AttributeValue{{Value: strings.Repeat("e", 200)}}},
+			{Name: "groups", Values: []saml.AttributeValue{{Value: "b"}, {Value: " a "}}},
+		}}},
+	}
+	if _, err := samlIdentityFrom(assertion, SAMLAttributeMapping{}.withDefaults()); errwrap.GetCode(err) != ErrInvalidCredential {
+		t.Fatalf("transient NameID 未配置 subject 属性时应拒绝，got %v", err)
+	}
+	id, err := samlIdentityFrom(assertion, SAMLAttributeMapping{Subject: "UID"}.withDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id.Subject, "sha256:") || len(id.Subject) > samlSubjectMaxLen {
+		t.Fatalf("过长的 subject 应哈希: %s", id.Subject)
+	}
+	if id.Email != "Eve@Corp.Test" || strings.Join(id.Groups, ",") != "a,b" {
+		t.Fatalf("属性映射不正确: %+v", id)
+	}
+}
@@ -0,0 +1,1897 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/xxzhwl/gaia"
+	"github.com/xxzhwl/gaia/errwrap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================================================
+// SCIM 2.0 预配置（RFC 7643 / RFC 7644）
+// ============================================================================
+//
+// 映射关系：
+//   - User  → acct_users：userName=username，displayName/name=nickname，主邮箱/主手机号=email/phone，
+//     active=false 时禁用账号并撤销全部会话，DELETE 为软删除
+//   - Group → 非系统角色（GroupBackend=role，成员即租户级角色分配），
+//     或组织（GroupBackend=org，成员即在该组织内被授予 OrgMemberRole）
+//   - externalId 单独存放在 acct_scim_external_ids
+//
+// 系统角色（platform_admin、user 等）不通过 SCIM 暴露，避免 IdP 侧越权提权。
+// 认证使用个人访问令牌（PAT），令牌 scope 与持有人自身都需具备 scim:provision；
+// 每次变更都会写入审计日志与 outbox 事件（account.scim.*）。
+
+const (
+	SCIMSchemaUser  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaGroup = "urn:ietf:params:scim:schemas:core:2.0:Group"
+
+	scimSchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimSchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimSchemaBulkResponse = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+	scimSchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimSchemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimSchemaResourceType = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	scimSchemaSchema       = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+
+	scimContentType = "application/scim+json"
+
+	// PermissionSCIMProvision 调用 SCIM 接口所需的权限点。
+	PermissionSCIMProvision = "scim:provision"
+
+	SCIMGroupBackendRole = "role"
+	SCIMGroupBackendOrg  = "org"
+
+	scimResourceUser  = "User"
+	scimResourceGroup = "Group"
+
+	scimScanBatch = 200
+)
+
+// SCIM 错误类型（RFC 7644 §3.12 scimType）。
+const (
+	scimTypeInvalidFilter = "invalidFilter"
+	scimTypeInvalidSyntax = "invalidSyntax"
+	scimTypeInvalidPath   = "invalidPath"
+	scimTypeNoTarget      = "noTarget"
+	scimTypeInvalidValue  = "invalidValue"
+	scimTypeUniqueness    = "uniqueness"
+	scimTypeTooMany       = "tooMany"
+)
+
+// SCIMExternalID 记录 IdP 侧资源标识（SCIM externalId）与本地资源的映射。
+type SCIMExternalID struct {
+	ID           string    `json:"id" gorm:"size:36;primaryKey"`
+	TenantID     string    `json:"tenant_id" gorm:"size:64;not null;uniqueIndex:uniq_acct_scim_resource,priority:1;index:idx_acct_scim_external,priority:1"`
+	ResourceType string    `json:"resource_type" gorm:"size:16;not null;uniqueIndex:uniq_acct_scim_resource,priority:2;index:idx_acct_scim_external,priority:2"`
+	ResourceID   string    `json:"resource_id" gorm:"size:36;not null;uniqueIndex:uniq_acct_scim_resource,priority:3"`
+	ExternalID   string    `json:"external_id" gorm:"size:255;not null;index:idx_acct_scim_external,priority:3"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (SCIMExternalID) TableName() string { return "acct_scim_external_ids" }
+
+// SCIMError SCIM 错误响应，同时实现 error。
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e *SCIMError) Error() string { return e.Detail }
+
+// HTTPStatus 返回对应的 HTTP 状态码。
+func (e *SCIMError) HTTPStatus() int {
+	code, err := strconv.Atoi(e.Status)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return code
+}
+
+func scimErr(status int, scimType, detail string) *SCIMError {
+	return &SCIMError{Schemas: []string{scimSchemaError}, Status: strconv.Itoa(status), ScimType: scimType, Detail: detail}
+}
+
+// toSCIMError 将账户模块错误转换为 SCIM 错误。
+func toSCIMError(err error) *SCIMError {
+	var se *SCIMError
+	if errors.As(err, &se) {
+		return se
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return scimErr(http.StatusNotFound, "", "resource not found")
+	}
+	msg := err.Error()
+	if logicErr, ok := err.(errwrap.LogicError); ok {
+		msg = logicErr.GetMessage()
+	}
+	switch errwrap.GetCode(err) {
+	case ErrInvalidArgument:
+		return scimErr(http.StatusBadRequest, scimTypeInvalidValue, msg)
+	case ErrInvalidToken:
+		return scimErr(http.StatusUnauthorized, "", msg)
+	case ErrPermissionDenied:
+		return scimErr(http.StatusForbidden, "", msg)
+	case ErrIdentifierExists:
+		return scimErr(http.StatusConflict, scimTypeUniqueness, msg)
+	}
+	gaia.ErrorF("[account] scim request failed: %v", err)
+	return scimErr(http.StatusInternalServerError, "", "internal error")
+}
+
+// SCIMName 用户姓名。
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// SCIMMultiValue 多值属性元素（emails、phoneNumbers）。
+type SCIMMultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMMember 组成员或用户所属组的引用。
+type SCIMMember struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// SCIMMeta 资源元数据。
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// SCIMUser SCIM User 资源。
+type SCIMUser struct {
+	Schemas      []string         `json:"schemas"`
+	ID           string           `json:"id,omitempty"`
+	ExternalID   string           `json:"externalId,omitempty"`
+	UserName     string           `json:"userName"`
+	Name         *SCIMName        `json:"name,omitempty"`
+	DisplayName  string           `json:"displayName,omitempty"`
+	Active       *bool            `json:"active,omitempty"`
+	Emails       []SCIMMultiValue `json:"emails,omitempty"`
+	PhoneNumbers []SCIMMultiValue `json:"phoneNumbers,omitempty"`
+	Groups       []SCIMMember     `json:"groups,omitempty"`
+	Meta         *SCIMMeta        `json:"meta,omitempty"`
+}
+
+// SCIMGroup SCIM Group 资源。
+type SCIMGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members,omitempty"`
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+// SCIMListResponse 列表/搜索响应。
+type SCIMListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// SCIMQuery 列表查询参数。StartIndex 从 1 开始；Count 为 0 时取 SCIMConfig.MaxResults。
+type SCIMQuery struct {
+	Filter             string   `json:"filter,omitempty"`
+	StartIndex         int      `json:"startIndex,omitempty"`
+	Count              int      `json:"count,omitempty"`
+	Attributes         []string `json:"attributes,omitempty"`
+	ExcludedAttributes []string `json:"excludedAttributes,omitempty"`
+}
+
+// SCIMBulkOperation /Bulk 中的单个操作。data 中可用 "bulkId:<id>" 引用同批次先前创建的资源。
+type SCIMBulkOperation struct {
+	Method  string          `json:"method"`
+	BulkID  string          `json:"bulkId,omitempty"`
+	Version string          `json:"version,omitempty"`
+	Path    string          `json:"path"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// SCIMBulkRequest /Bulk 请求体。FailOnErrors 为累计失败多少次后停止，0 表示全部执行。
+type SCIMBulkRequest struct {
+	Schemas      []string            `json:"schemas"`
+	FailOnErrors int                 `json:"failOnErrors,omitempty"`
+	Operations   []SCIMBulkOperation `json:"Operations"`
+}
+
+// SCIMBulkResult 单个操作的执行结果。
+type SCIMBulkResult struct {
+	Method   string `json:"method"`
+	BulkID   string `json:"bulkId,omitempty"`
+	Location string `json:"location,omitempty"`
+	Status   string `json:"status"`
+	Response any    `json:"response,omitempty"`
+}
+
+// SCIMBulkResponse /Bulk 响应体。
+type SCIMBulkResponse struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []SCIMBulkResult `json:"Operations"`
+}
+
+// SCIMService 提供 SCIM 2.0 用户与组的预配置能力。
+type SCIMService struct {
+	m *Manager
+}
+
+type scimBaseURLKey struct{}
+
+// scimLocation 返回资源的绝对地址，base URL 由 HTTP 层写入 context。
+func scimLocation(ctx context.Context, endpoint, id string) string {
+	base, _ := ctx.Value(scimBaseURLKey{}).(string)
+	if base == "" {
+		return ""
+	}
+	if id == "" {
+		return base + "/" + endpoint
+	}
+	return base + "/" + endpoint + "/" + id
+}
+
+func (s *SCIMService) orgBackend() bool {
+	return s.m.cfg.SCIM.GroupBackend == SCIMGroupBackendOrg
+}
+
+func (s *SCIMService) page(q SCIMQuery) (start, count int) {
+	start, count = q.StartIndex, q.Count
+	if start < 1 {
+		start = 1
+	}
+	if count <= 0 || count > s.m.cfg.SCIM.MaxResults {
+		count = s.m.cfg.SCIM.MaxResults
+	}
+	return start, count
+}
+
+// ============================================================================
+// Users
+// ============================================================================
+
+// ListUsers 按过滤条件分页列出用户。
+func (s *SCIMService) ListUsers(ctx context.Context, tenantID string, q SCIMQuery) (*SCIMListResponse, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.list_users")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	filter, err := parseSCIMFilter(q.Filter)
+	if err != nil {
+		return nil, scimErr(http.StatusBadRequest, scimTypeInvalidFilter, err.Error())
+	}
+	db := s.m.db.WithContext(ctx).Model(&User{}).Where("tenant_id = ?", tenantID)
+	if attr, value, ok := scimEqualityTerm(filter); ok {
+		switch strings.ToLower(attr) {
+		case "id":
+			db = db.Where("id = ?", value)
+		case "username":
+			db = db.Where("username = ?", value)
+		case "externalid":
+			db = db.Where("id IN (?)", s.externalIDQuery(ctx, tenantID, scimResourceUser, value))
+		}
+	}
+	start, count := s.page(q)
+	return s.paginate(db, filter, start, count, q, func(db *gorm.DB) ([]map[string]any, error) {
+		var users []User
+		if err := db.Find(&users).Error; err != nil {
+			return nil, err
+		}
+		resources, err := s.userResources(ctx, tenantID, users)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]map[string]any, len(resources))
+		for i, r := range resources {
+			out[i] = scimToMap(r)
+		}
+		return out, nil
+	})
+}
+
+// GetUser 返回单个用户。
+func (s *SCIMService) GetUser(ctx context.Context, tenantID, id string) (*SCIMUser, error) {
+	tenantID = s.m.tenantID(tenantID)
+	var user User
+	if err := s.m.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	resources, err := s.userResources(ctx, tenantID, []User{user})
+	if err != nil {
+		return nil, err
+	}
+	return resources[0], nil
+}
+
+// CreateUser 创建用户并分配默认角色。SCIM 用户没有本地密码，需通过 SSO 或找回密码登录。
+func (s *SCIMService) CreateUser(ctx context.Context, tenantID string, in *SCIMUser) (*SCIMUser, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.create_user")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	fields, err := scimUserFieldsFrom(in)
+	if err != nil {
+		return nil, err
+	}
+	user := User{
+		ID:             newID(),
+		TenantID:       tenantID,
+		Username:       fields.username,
+		Email:          nullableString(fields.email),
+		Phone:          nullableString(fields.phone),
+		Nickname:       fields.nickname,
+		Status:         UserStatusNormal,
+		AuthVersion:    1,
+		RolesVersion:   1,
+		ProfileVersion: 1,
+	}
+	now := time.Now()
+	// IdP 推送的邮箱/手机号由企业目录背书，视为已验证
+	if user.Email != nil {
+		user.EmailVerifiedAt = &now
+	}
+	if user.Phone != nil {
+		user.PhoneVerifiedAt = &now
+	}
+	if fields.active != nil && !*fields.active {
+		user.Status = UserStatusDisabled
+	}
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.m.tenantSvc.admitUser(tx, tenantID); err != nil {
+			return err
+		}
+		if err := s.checkUserUnique(tx, tenantID, "", fields); err != nil {
+			return err
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return fmt.Errorf("create user: %w", err)
+		}
+		if err := s.m.auth.assignDefaultRole(ctx, tx, &user); err != nil {
+			return err
+		}
+		if err := s.setExternalID(tx, tenantID, scimResourceUser, user.ID, fields.externalID); err != nil {
+			return err
+		}
+		return s.emit(ctx, tx, EventSCIMUserCreated, tenantID, user.ID, map[string]any{
+			"username":    user.Username,
+			"external_id": fields.externalID,
+			"active":      user.Status == UserStatusNormal,
+		})
+	})
+	s.audit(ctx, tenantID, user.ID, "scim_user_create", err, "userName="+fields.username)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, tenantID, user.ID)
+}
+
+// ReplaceUser 以请求体整体替换用户（PUT）。未出现的可选属性会被清空。
+func (s *SCIMService) ReplaceUser(ctx context.Context, tenantID, id string, in *SCIMUser) (*SCIMUser, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.replace_user")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	fields, err := scimUserFieldsFrom(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveUser(ctx, tenantID, id, fields); err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, tenantID, id)
+}
+
+// PatchUser 对用户执行 PATCH 操作。
+func (s *SCIMService) PatchUser(ctx context.Context, tenantID, id string, req SCIMPatchRequest) (*SCIMUser, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.patch_user")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	current, err := s.GetUser(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := scimToMap(current)
+	if err := applySCIMPatch(res, req.Operations); err != nil {
+		return nil, err
+	}
+	// displayName 与 name.formatted 都映射到 nickname；仅修改 name.givenName 等子属性时，
+	// 去掉未变化的旧值，让新姓名生效
+	if key, v, ok := scimLookup(res, "displayName"); ok && v == current.DisplayName {
+		delete(res, key)
+	}
+	if _, name, ok := scimLookup(res, "name"); ok {
+		if nm, isMap := name.(map[string]any); isMap {
+			_, given, _ := scimLookup(nm, "givenName")
+			_, family, _ := scimLookup(nm, "familyName")
+			if key, v, ok := scimLookup(nm, "formatted"); ok && v == current.DisplayName && (given != nil || family != nil) {
+				delete(nm, key)
+			}
+		}
+	}
+	// 兼容 Azure AD 以字符串下发布尔值（"False"）
+	if key, v, ok := scimLookup(res, "active"); ok {
+		if str, isStr := v.(string); isStr {
+			b, err := strconv.ParseBool(str)
+			if err != nil {
+				return nil, scimErr(http.StatusBadRequest, scimTypeInvalidValue, "active must be a boolean")
+			}
+			res[key] = b
+		}
+	}
+	var patched SCIMUser
+	if err := scimFromMap(res, &patched); err != nil {
+		return nil, err
+	}
+	fields, err := scimUserFieldsFrom(&patched)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveUser(ctx, tenantID, id, fields); err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, tenantID, id)
+}
+
+// DeleteUser 软删除用户：停用凭证、撤销会话与刷新令牌、移除全部角色分配。
+func (s *SCIMService) DeleteUser(ctx context.Context, tenantID, id string) error {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.delete_user")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	var sessionIDs []string
+	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("id = ? AND tenant_id = ?", id, tenantID).First(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Session{}).Where("user_id = ?", id).Pluck("id", &sessionIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Credential{}).Where("user_id = ?", id).Update("enabled", false).Error; err != nil {
+			return err
+		}
+		if err := revokeUserSessionsTx(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("id = ?", id).Updates(map[string]any{
+			"status":       UserStatusDeleted,
+			"auth_version": gorm.Expr("auth_version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", id).Delete(&User{}).Error; err != nil {
+			return err
+		}
+		if err := s.setExternalID(tx, tenantID, scimResourceUser, id, ""); err != nil {
+			return err
+		}
+		// 同时发布通用的用户删除事件，既有订阅方无需感知 SCIM
+		if err := emitOutbox(tx, EventUserDeleted, id, map[string]any{
+			"user_id":    id,
+			"deleted_at": time.Now(),
+		}); err != nil {
+			return err
+		}
+		return s.emit(ctx, tx, EventSCIMUserDeleted, tenantID, id, map[string]any{"username": user.Username})
+	})
+	s.audit(ctx, tenantID, id, "scim_user_delete", err, "")
+	if err != nil {
+		return err
+	}
+	s.m.auth.invalidatePrincipalCaches(ctx, sessionIDs)
+	_ = s.m.authorizer.invalidatePermissions(ctx, id)
+	return nil
+}
+
+// scimUserFields 从 SCIM User 中提取并校验的可写字段。
+type scimUserFields struct {
+	username   string
+	email      string
+	phone      string
+	nickname   string
+	externalID string
+	active     *bool
+}
+
+func scimUserFieldsFrom(in *SCIMUser) (*scimUserFields, error) {
+	if in == nil {
+		return nil, scimErr(http.StatusBadRequest, scimTypeInvalidSyntax, "request body is required")
+	}
+	f := &scimUserFields{
+		username:   strings.TrimSpace(in.UserName),
+		email:      normalizeEmail(primaryValue(in.Emails)),
+		phone:      normalizePhone(primaryValue(in.PhoneNumbers)),
+		externalID: strings.TrimSpace(in.ExternalID),
+		active:     in.Active,
+	}
+	if f.username == "" {
+		return nil, scimErr(http.StatusBadRequest, scimTypeInvalidValue, "userName is required")
+	}
+	if f.email != "" && !isEmailIdentifier(f.email) {
+		return nil, scimErr(http.StatusBadRequest, scimTypeInvalidValue, "invalid email address")
+	}
+	switch {
+	case strings.TrimSpace(in.DisplayName) != "":
+		f.nickname = strings.TrimSpace(in.DisplayName)
+	case in.Name != nil && strings.TrimSpace(in.Name.Formatted) != "":
+		f.nickname = strings.TrimSpace(in.Name.Formatted)
+	case in.Name != nil:
+		f.nickname = strings.TrimSpace(in.Name.GivenName + " " + in.Name.FamilyName)
+	}
+	return f, nil
+}
+
+// primaryValue 返回 primary 元素的值，没有 primary 时取第一个。
+func primaryValue(values []SCIMMultiValue) string {
+	for _, v := range values {
+		if v.Primary {
+			return v.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+// checkUserUnique 校验 userName/邮箱/手机号在租户内唯一（含已软删除的行，与唯一索引一致）。
+func (s *SCIMService) checkUserUnique(tx *gorm.DB, tenantID, excludeID string, f *scimUserFields) error {
+	checks := []struct{ column, value, attr string }{
+		{"username", f.username, "userName"},
+		{"email", f.email, "emails"},
+		{"phone", f.phone, "phoneNumbers"},
+	}
+	for _, c := range checks {
+		if c.value == "" {
+			continue
+		}
+		var count int64
+		if err := tx.Unscoped().Model(&User{}).
+			Where("tenant_id = ? AND "+c.column+" = ? AND id <> ?", tenantID, c.value, excludeID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return scimErr(http.StatusConflict, scimTypeUniqueness, c.attr+" already exists")
+		}
+	}
+	return nil
+}
+
+// saveUser 将字段写回用户；active 变化时同步禁用/启用账号。
+func (s *SCIMService) saveUser(ctx context.Context, tenantID, id string, f *scimUserFields) error {
+	var (
+		sessionIDs  []string
+		deactivated bool
+	)
+	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("id = ? AND tenant_id = ?", id, tenantID).First(&user).Error; err != nil {
+			return err
+		}
+		if err := s.checkUserUnique(tx, tenantID, id, f); err != nil {
+			return err
+		}
+		now := time.Now()
+		updates := map[string]any{
+			"username":        f.username,
+			"email":           nullableString(f.email),
+			"phone":           nullableString(f.phone),
+			"nickname":        f.nickname,
+			"profile_version": gorm.Expr("profile_version + 1"),
+		}
+		if f.email != stringValue(user.Email) {
+			updates["email_verified_at"] = nil
+			if f.email != "" {
+				updates["email_verified_at"] = now
+			}
+		}
+		if f.phone != stringValue(user.Phone) {
+			updates["phone_verified_at"] = nil
+			if f.phone != "" {
+				updates["phone_verified_at"] = now
+			}
+		}
+		if f.active != nil {
+			switch {
+			case !*f.active && user.Status != UserStatusDisabled:
+				updates["status"] = UserStatusDisabled
+				updates["auth_version"] = gorm.Expr("auth_version + 1")
+				if err := tx.Model(&Session{}).Where("user_id = ?", id).Pluck("id", &sessionIDs).Error; err != nil {
+					return err
+				}
+				if err := revokeUserSessionsTx(tx, id); err != nil {
+					return err
+				}
+				deactivated = true
+			case *f.active && user.Status != UserStatusNormal:
+				updates["status"] = UserStatusNormal
+				updates["locked_until"] = nil
+			}
+		}
+		if err := tx.Model(&User{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := s.setExternalID(tx, tenantID, scimResourceUser, id, f.externalID); err != nil {
+			return err
+		}
+		active := user.Status == UserStatusNormal
+		if f.active != nil {
+			active = *f.active
+		}
+		return s.emit(ctx, tx, EventSCIMUserUpdated, tenantID, id, map[string]any{
+			"username":    f.username,
+			"external_id": f.externalID,
+			"active":      active,
+			"deactivated": deactivated,
+		})
+	})
+	reason := "userName=" + f.username
+	if deactivated {
+		reason += " deactivated"
+	}
+	s.audit(ctx, tenantID, id, "scim_user_update", err, reason)
+	if err != nil {
+		return err
+	}
+	if deactivated {
+		s.m.auth.invalidatePrincipalCaches(ctx, sessionIDs)
+	}
+	return nil
+}
+
+// userResources 批量转换用户，附带 externalId 与所属组。
+func (s *SCIMService) userResources(ctx context.Context, tenantID string, users []User) ([]*SCIMUser, error) {
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	externalIDs, err := s.loadExternalIDs(ctx, tenantID, scimResourceUser, ids)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.loadUserGroups(ctx, tenantID, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*SCIMUser, len(users))
+	for i, u := range users {
+		active := u.Status == UserStatusNormal
+		r := &SCIMUser{
+			Schemas:     []string{SCIMSchemaUser},
+			ID:          u.ID,
+			ExternalID:  externalIDs[u.ID],
+			UserName:    u.Username,
+			DisplayName: u.Nickname,
+			Active:      &active,
+			Groups:      groups[u.ID],
+			Meta: &SCIMMeta{
+				ResourceType: scimResourceUser,
+				Created:      u.CreatedAt,
+				LastModified: u.UpdatedAt,
+				Location:     scimLocation(ctx, "Users", u.ID),
+			},
+		}
+		if u.Nickname != "" {
+			r.Name = &SCIMName{Formatted: u.Nickname}
+		}
+		if u.Email != nil {
+			r.Emails = []SCIMMultiValue{{Value: *u.Email, Type: "work", Primary: true}}
+		}
+		if u.Phone != nil {
+			r.PhoneNumbers = []SCIMMultiValue{{Value: *u.Phone, Type: "mobile", Primary: true}}
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+// ============================================================================
+// Groups
+// ============================================================================
+
+// scimGroupRecord 是角色或组织在 SCIM Group 视角下的公共字段。
+type scimGroupRecord struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// scimMembership 成员关系查询结果。
+type scimMembership struct {
+	UserID  string
+	GroupID string
+	Display string
+}
+
+// groupQuery 返回当前 GroupBackend 下租户内可被 SCIM 管理的组。
+func (s *SCIMService) groupQuery(db *gorm.DB, tenantID string) *gorm.DB {
+	if s.orgBackend() {
+		return db.Model(&Organization{}).Where("tenant_id = ?", tenantID)
+	}
+	return db.Model(&Role{}).Where("tenant_id = ? AND is_system = ?", tenantID, false)
+}
+
+func (s *SCIMService) loadGroupRecords(db *gorm.DB) ([]scimGroupRecord, error) {
+	var out []scimGroupRecord
+	if s.orgBackend() {
+		var orgs []Organization
+		if err := db.Find(&orgs).Error; err != nil {
+			return nil, err
+		}
+		for _, o := range orgs {
+			out = append(out, scimGroupRecord{ID: o.ID, Name: o.Name, CreatedAt: o.CreatedAt, UpdatedAt: o.UpdatedAt})
+		}
+		return out, nil
+	}
+	var roles []Role
+	if err := db.Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		out = append(out, scimGroupRecord{ID: r.ID, Name: r.Name, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt})
+	}
+	return out, nil
+}
+
+// memberRoleID 返回 org 模式下组织成员被授予的角色。
+func (s *SCIMService) memberRoleID(tx *gorm.DB, tenantID string) (string, error) {
+	var role Role
+	if err := tx.Where("tenant_id = ? AND code = ? AND status = ?", tenantID, s.m.cfg.SCIM.OrgMemberRole, "enabled").
+		First(&role).Error; err != nil {
+		return "", fmt.Errorf("load scim org member role %s: %w", s.m.cfg.SCIM.OrgMemberRole, err)
+	}
+	return role.ID, nil
+}
+
+// membershipScope 返回组成员对应的 UserRole 条件：role 模式为租户级角色分配，org 模式为组织内成员角色。
+func (s *SCIMService) membershipScope(tx *gorm.DB, tenantID, groupID string) (roleID, scopeType, scopeID string, err error) {
+	if !s.orgBackend() {
+		return groupID, "tenant", tenantID, nil
+	}
+	roleID, err = s.memberRoleID(tx, tenantID)
+	return roleID, "org", groupID, err
+}
+
+// ListGroups 按过滤条件分页列出组。excludedAttributes=members 时不加载成员。
+func (s *SCIMService) ListGroups(ctx context.Context, tenantID string, q SCIMQuery) (*SCIMListResponse, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.list_groups")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	filter, err := parseSCIMFilter(q.Filter)
+	if err != nil {
+		return nil, scimErr(http.StatusBadRequest, scimTypeInvalidFilter, err.Error())
+	}
+	db := s.groupQuery(s.m.db.WithContext(ctx), tenantID)
+	if attr, value, ok := scimEqualityTerm(filter); ok {
+		switch strings.ToLower(attr) {
+		case "id":
+			db = db.Where("id = ?", value)
+		case "displayname":
+			db = db.Where("name = ?", value)
+		case "externalid":
+			db = db.Where("id IN (?)", s.externalIDQuery(ctx, tenantID, scimResourceGroup, value))
+		}
+	}
+	withMembers := filter != nil || !containsFold(q.ExcludedAttributes, "members")
+	start, count := s.page(q)
+	return s.paginate(db, filter, start, count, q, func(db *gorm.DB) ([]map[string]any, error) {
+		records, err := s.loadGroupRecords(db)
+		if err != nil {
+			return nil, err
+		}
+		resources, err := s.groupResources(ctx, tenantID, records, withMembers)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]map[string]any, len(resources))
+		for i, r := range resources {
+			out[i] = scimToMap(r)
+		}
+		return out, nil
+	})
+}
+
+// GetGroup 返回单个组及其成员。
+func (s *SCIMService) GetGroup(ctx context.Context, tenantID, id string) (*SCIMGroup, error) {
+	tenantID = s.m.tenantID(tenantID)
+	records, err := s.loadGroupRecords(s.groupQuery(s.m.db.WithContext(ctx), tenantID).Where("id = ?", id).Limit(1))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	resources, err := s.groupResources(ctx, tenantID, records, true)
+	if err != nil {
+		return nil, err
+	}
+	return resources[0], nil
+}
+
+// CreateGroup 创建组（角色或组织）并写入成员。
+func (s *SCIMService) CreateGroup(ctx context.Context, tenantID string, in *SCIMGroup) (*SCIMGroup, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.create_group")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	name, members, err := scimGroupFieldsFrom(in)
+	if err != nil {
+		return nil, err
+	}
+	id := newID()
+	var added []string
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.checkGroupUnique(tx, tenantID, "", name); err != nil {
+			return err
+		}
+		code := "scim_" + strings.ReplaceAll(id, "-", "")
+		var err error
+		if s.orgBackend() {
+			err = tx.Create(&Organization{ID: id, TenantID: tenantID, Code: code, Name: name, Status: "enabled", Version: 1}).Error
+		} else {
+			err = tx.Create(&Role{ID: id, TenantID: tenantID, Code: code, Name: name, Description: "SCIM group", Status: "enabled", Version: 1}).Error
+		}
+		if err != nil {
+			return fmt.Errorf("create scim group: %w", err)
+		}
+		if added, _, err = s.syncMembers(tx, tenantID, id, members); err != nil {
+			return err
+		}
+		if err := s.setExternalID(tx, tenantID, scimResourceGroup, id, strings.TrimSpace(in.ExternalID)); err != nil {
+			return err
+		}
+		return s.emit(ctx, tx, EventSCIMGroupCreated, tenantID, id, map[string]any{
+			"display_name":  name,
+			"backend":       s.m.cfg.SCIM.GroupBackend,
+			"members_added": added,
+		})
+	})
+	s.audit(ctx, tenantID, "", "scim_group_create", err, "group="+id+" displayName="+name)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateUsers(ctx, added)
+	return s.GetGroup(ctx, tenantID, id)
+}
+
+// ReplaceGroup 整体替换组名称与成员（PUT）。
+func (s *SCIMService) ReplaceGroup(ctx context.Context, tenantID, id string, in *SCIMGroup) (*SCIMGroup, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.replace_group")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	if err := s.saveGroup(ctx, tenantID, id, in); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, tenantID, id)
+}
+
+// PatchGroup 对组执行 PATCH 操作，常用于增删成员。
+func (s *SCIMService) PatchGroup(ctx context.Context, tenantID, id string, req SCIMPatchRequest) (*SCIMGroup, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.patch_group")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	current, err := s.GetGroup(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := scimToMap(current)
+	if err := applySCIMPatch(res, req.Operations); err != nil {
+		return nil, err
+	}
+	var patched SCIMGroup
+	if err := scimFromMap(res, &patched); err != nil {
+		return nil, err
+	}
+	if err := s.saveGroup(ctx, tenantID, id, &patched); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, tenantID, id)
+}
+
+// DeleteGroup 删除组并移除全部成员关系。org 模式下与 OrgService.DeleteOrg 一致，一并删除子组织。
+func (s *SCIMService) DeleteGroup(ctx context.Context, tenantID, id string) error {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.delete_group")
+	defer span.End()
+	tenantID = s.m.tenantID(tenantID)
+	var affected []string
+	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		records, err := s.loadGroupRecords(s.groupQuery(tx, tenantID).Where("id = ?", id).Limit(1))
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if s.orgBackend() {
+			ids := append(s.m.orgSvc.collectOrgIDs(tx, id), id)
+			members := tx.Model(&UserRole{}).Where("scope_type = ? AND scope_id IN ?", "org", ids)
+			if err := members.Distinct().Pluck("user_id", &affected).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("scope_type = ? AND scope_id IN ?", "org", ids).Delete(&UserRole{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&Organization{}).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Model(&UserRole{}).Where("role_id = ?", id).Distinct().Pluck("user_id", &affected).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("role_id = ?", id).Delete(&RolePermission{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("role_id = ?", id).Delete(&UserRole{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id = ?", id).Delete(&Role{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := bumpRolesVersion(tx, affected); err != nil {
+			return err
+		}
+		if err := s.setExternalID(tx, tenantID, scimResourceGroup, id, ""); err != nil {
+			return err
+		}
+		return s.emit(ctx, tx, EventSCIMGroupDeleted, tenantID, id, map[string]any{
+			"display_name":    records[0].Name,
+			"members_removed": affected,
+		})
+	})
+	s.audit(ctx, tenantID, "", "scim_group_delete", err, "group="+id)
+	if err != nil {
+		return err
+	}
+	s.invalidateUsers(ctx, affected)
+	return nil
+}
+
+func scimGroupFieldsFrom(in *SCIMGroup) (name string, members []string, err error) {
+	if in == nil {
+		return "", nil, scimErr(http.StatusBadRequest, scimTypeInvalidSyntax, "request body is required")
+	}
+	name = strings.TrimSpace(in.DisplayName)
+	if name == "" {
+		return "", nil, scimErr(http.StatusBadRequest, scimTypeInvalidValue, "displayName is required")
+	}
+	seen := make(map[string]bool, len(in.Members))
+	for _, m := range in.Members {
+		if m.Type != "" && !strings.EqualFold(m.Type, scimResourceUser) {
+			return "", nil, scimErr(http.StatusBadRequest, scimTypeInvalidValue, "nested groups are not supported")
+		}
+		id := strings.TrimSpace(m.Value)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		members = append(members, id)
+	}
+	return name, members, nil
+}
+
+func (s *SCIMService) checkGroupUnique(tx *gorm.DB, tenantID, excludeID, name string) error {
+	var count int64
+	if err := s.groupQuery(tx, tenantID).Where("name = ? AND id <> ?", name, excludeID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return scimErr(http.StatusConflict, scimTypeUniqueness, "displayName already exists")
+	}
+	return nil
+}
+
+func (s *SCIMService) saveGroup(ctx context.Context, tenantID, id string, in *SCIMGroup) error {
+	name, members, err := scimGroupFieldsFrom(in)
+	if err != nil {
+		return err
+	}
+	var added, removed []string
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		records, err := s.loadGroupRecords(s.groupQuery(tx, tenantID).Where("id = ?", id).Limit(1))
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if name != records[0].Name {
+			if err := s.checkGroupUnique(tx, tenantID, id, name); err != nil {
+				return err
+			}
+			if err := s.groupQuery(tx, tenantID).Where("id = ?", id).Updates(map[string]any{
+				"name":    name,
+				"version": gorm.Expr("version + 1"),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if added, removed, err = s.syncMembers(tx, tenantID, id, members); err != nil {
+			return err
+		}
+		if err := s.setExternalID(tx, tenantID, scimResourceGroup, id, strings.TrimSpace(in.ExternalID)); err != nil {
+			return err
+		}
+		return s.emit(ctx, tx, EventSCIMGroupUpdated, tenantID, id, map[string]any{
+			"display_name":    name,
+			"backend":         s.m.cfg.SCIM.GroupBackend,
+			"members_added":   added,
+			"members_removed": removed,
+		})
+	})
+	s.audit(ctx, tenantID, "", "scim_group_update", err,
+		fmt.Sprintf("group=%s added=%d removed=%d", id, len(added), len(removed)))
+	if err != nil {
+		return err
+	}
+	s.invalidateUsers(ctx, append(added, removed...))
+	return nil
+}
+
+// syncMembers 将组成员调整为 want，返回新增与移除的用户 ID。
+func (s *SCIMService) syncMembers(tx *gorm.DB, tenantID, groupID string, want []string) (added, removed []string, err error) {
+	roleID, scopeType, scopeID, err := s.membershipScope(tx, tenantID, groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(want) > 0 {
+		var count int64
+		if err := tx.Model(&User{}).Where("tenant_id = ? AND id IN ?", tenantID, want).Count(&count).Error; err != nil {
+			return nil, nil, err
+		}
+		if int(count) != len(want) {
+			return nil, nil, scimErr(http.StatusBadRequest, scimTypeInvalidValue, "members contain unknown users")
+		}
+	}
+	var current []string
+	if err := tx.Model(&UserRole{}).Where("role_id = ? AND scope_type = ? AND scope_id = ?", roleID, scopeType, scopeID).
+		Pluck("user_id", &current).Error; err != nil {
+		return nil, nil, err
+	}
+	wantSet := make(map[string]bool, len(want))
+	for _, id := range want {
+		wantSet[id] = true
+	}
+	currentSet := make(map[string]bool, len(current))
+	for _, id := range current {
+		currentSet[id] = true
+		if !wantSet[id] {
+			removed = append(removed, id)
+		}
+	}
+	for _, id := range want {
+		if currentSet[id] {
+			continue
+		}
+		added = append(added, id)
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UserRole{
+			ID:        newID(),
+			TenantID:  tenantID,
+			UserID:    id,
+			RoleID:    roleID,
+			ScopeType: scopeType,
+			ScopeID:   scopeID,
+		}).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(removed) > 0 {
+		if err := tx.Where("role_id = ? AND scope_type = ? AND scope_id = ? AND user_id IN ?", roleID, scopeType, scopeID, removed).
+			Delete(&UserRole{}).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := bumpRolesVersion(tx, append(append([]string(nil), added...), removed...)); err != nil {
+		return nil, nil, err
+	}
+	return added, removed, nil
+}
+
+func bumpRolesVersion(tx *gorm.DB, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&User{}).Where("id IN ?", userIDs).Update("roles_version", gorm.Expr("roles_version + 1")).Error
+}
+
+// groupResources 批量转换组；withMembers 为 false 时跳过成员查询。
+func (s *SCIMService) groupResources(ctx context.Context, tenantID string, records []scimGroupRecord, withMembers bool) ([]*SCIMGroup, error) {
+	ids := make([]string, len(records))
+	for i, r := range records {
+		ids[i] = r.ID
+	}
+	externalIDs, err := s.loadExternalIDs(ctx, tenantID, scimResourceGroup, ids)
+	if err != nil {
+		return nil, err
+	}
+	members := map[string][]SCIMMember{}
+	if withMembers {
+		if members, err = s.loadGroupMembers(ctx, tenantID, ids); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]*SCIMGroup, len(records))
+	for i, r := range records {
+		out[i] = &SCIMGroup{
+			Schemas:     []string{SCIMSchemaGroup},
+			ID:          r.ID,
+			ExternalID:  externalIDs[r.ID],
+			DisplayName: r.Name,
+			Members:     members[r.ID],
+			Meta: &SCIMMeta{
+				ResourceType: scimResourceGroup,
+				Created:      r.CreatedAt,
+				LastModified: r.UpdatedAt,
+				Location:     scimLocation(ctx, "Groups", r.ID),
+			},
+		}
+	}
+	return out, nil
+}
+
+func (s *SCIMService) loadGroupMembers(ctx context.Context, tenantID string, groupIDs []string) (map[string][]SCIMMember, error) {
+	out := make(map[string][]SCIMMember, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return out, nil
+	}
+	db := s.m.db.WithContext(ctx)
+	q := db.Table("acct_user_roles").
+		Joins("JOIN acct_users ON acct_users.id = acct_user_roles.user_id AND acct_users.deleted_at IS NULL").
+		Where("acct_user_roles.tenant_id = ?", tenantID)
+	if s.orgBackend() {
+		roleID, err := s.memberRoleID(db, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Select("acct_user_roles.user_id AS user_id, acct_user_roles.scope_id AS group_id, acct_users.username AS display").
+			Where("acct_user_roles.scope_type = ? AND acct_user_roles.scope_id IN ? AND acct_user_roles.role_id = ?", "org", groupIDs, roleID)
+	} else {
+		q = q.Select("acct_user_roles.user_id AS user_id, acct_user_roles.role_id AS group_id, acct_users.username AS display").
+			Where("acct_user_roles.scope_type = ? AND acct_user_roles.role_id IN ?", "tenant", groupIDs)
+	}
+	var rows []scimMembership
+	if err := q.Order("acct_user_roles.user_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.GroupID] = append(out[r.GroupID], SCIMMember{
+			Value:   r.UserID,
+			Ref:     scimLocation(ctx, "Users", r.UserID),
+			Display: r.Display,
+			Type:    scimResourceUser,
+		})
+	}
+	return out, nil
+}
+
+func (s *SCIMService) loadUserGroups(ctx context.Context, tenantID string, userIDs []string) (map[string][]SCIMMember, error) {
+	out := make(map[string][]SCIMMember, len(userIDs))
+	if len(userIDs) == 0 {
+		return out, nil
+	}
+	db := s.m.db.WithContext(ctx)
+	var q *gorm.DB
+	if s.orgBackend() {
+		roleID, err := s.memberRoleID(db, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		q = db.Table("acct_user_roles").
+			Select("acct_user_roles.user_id AS user_id, acct_organizations.id AS group_id, acct_organizations.name AS display").
+			Joins("JOIN acct_organizations ON acct_organizations.id = acct_user_roles.scope_id AND acct_organizations.deleted_at IS NULL").
+			Where("acct_user_roles.scope_type = ? AND acct_user_roles.role_id = ? AND acct_user_roles.user_id IN ?", "org", roleID, userIDs)
+	} else {
+		q = db.Table("acct_user_roles").
+			Select("acct_user_roles.user_id AS user_id, acct_roles.id AS group_id, acct_roles.name AS display").
+			Joins("JOIN acct_roles ON acct_roles.id = acct_user_roles.role_id").
+			Where("acct_user_roles.scope_type = ? AND acct_roles.is_system = ? AND acct_user_roles.user_id IN ?", "tenant", false, userIDs)
+	}
+	var rows []scimMembership
+	if err := q.Where("acct_user_roles.tenant_id = ?", tenantID).Order("group_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.UserID] = append(out[r.UserID], SCIMMember{
+			Value:   r.GroupID,
+			Ref:     scimLocation(ctx, "Groups", r.GroupID),
+			Display: r.Display,
+			Type:    "direct",
+		})
+	}
+	return out, nil
+}
+
+// ============================================================================
+// externalId / 列表 / 审计 / outbox
+// ============================================================================
+
+func (s *SCIMService) externalIDQuery(ctx context.Context, tenantID, resourceType, externalID string) *gorm.DB {
+	return s.m.db.WithContext(ctx).Model(&SCIMExternalID{}).Select("resource_id").
+		Where("tenant_id = ? AND resource_type = ? AND external_id = ?", tenantID, resourceType, externalID)
+}
+
+func (s *SCIMService) loadExternalIDs(ctx context.Context, tenantID, resourceType string, ids []string) (map[string]string, error) {
+	out := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var rows []SCIMExternalID
+	if err := s.m.db.WithContext(ctx).Where("tenant_id = ? AND resource_type = ? AND resource_id IN ?", tenantID, resourceType, ids).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.ResourceID] = r.ExternalID
+	}
+	return out, nil
+}
+
+// setExternalID 写入或清除资源的 externalId。
+func (s *SCIMService) setExternalID(tx *gorm.DB, tenantID, resourceType, resourceID, externalID string) error {
+	if externalID == "" {
+		return tx.Where("tenant_id = ? AND resource_type = ? AND resource_id = ?", tenantID, resourceType, resourceID).
+			Delete(&SCIMExternalID{}).Error
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "resource_type"}, {Name: "resource_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"external_id", "updated_at"}),
+	}).Create(&SCIMExternalID{
+		ID:           newID(),
+		TenantID:     tenantID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		ExternalID:   externalID,
+	}).Error
+}
+
+// paginate 分页执行查询。无过滤时直接 COUNT + OFFSET；有过滤时按批扫描并在内存中求值。
+func (s *SCIMService) paginate(db *gorm.DB, filter scimFilter, start, count int, q SCIMQuery,
+	load func(db *gorm.DB) ([]map[string]any, error)) (*SCIMListResponse, error) {
+	resp := &SCIMListResponse{Schemas: []string{scimSchemaListResponse}, StartIndex: start, Resources: []any{}}
+	if filter == nil {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		resp.TotalResults = int(total)
+		items, err := load(db.Session(&gorm.Session{}).Order("id ASC").Offset(start - 1).Limit(count))
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			resp.Resources = append(resp.Resources, scimProject(item, q.Attributes, q.ExcludedAttributes))
+		}
+	} else {
+		for offset := 0; ; offset += scimScanBatch {
+			items, err := load(db.Session(&gorm.Session{}).Order("id ASC").Offset(offset).Limit(scimScanBatch))
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				if !filter.match(item) {
+					continue
+				}
+				resp.TotalResults++
+				if resp.TotalResults >= start && len(resp.Resources) < count {
+					resp.Resources = append(resp.Resources, scimProject(item, q.Attributes, q.ExcludedAttributes))
+				}
+			}
+			if len(items) < scimScanBatch {
+				break
+			}
+		}
+	}
+	resp.ItemsPerPage = len(resp.Resources)
+	return resp, nil
+}
+
+// scimProject 按 attributes / excludedAttributes 裁剪顶层属性；schemas、id、meta 始终返回。
+func scimProject(res map[string]any, attributes, excluded []string) map[string]any {
+	if len(attributes) == 0 && len(excluded) == 0 {
+		return res
+	}
+	out := make(map[string]any, len(res))
+	for k, v := range res {
+		switch k {
+		case "schemas", "id", "meta":
+			out[k] = v
+			continue
+		}
+		if len(attributes) > 0 && !containsFold(scimTopLevel(attributes), k) {
+			continue
+		}
+		if containsFold(scimTopLevel(excluded), k) {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func scimTopLevel(paths []string) []string {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		attr, _ := splitSCIMAttrPath(strings.TrimSpace(p))
+		out = append(out, attr)
+	}
+	return out
+}
+
+func containsFold(list []string, target string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func scimToMap(v any) map[string]any {
+	data, _ := json.Marshal(v)
+	var out map[string]any
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+func scimFromMap(res map[string]any, dst any) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return scimErr(http.StatusBadRequest, scimTypeInvalidValue, err.Error())
+	}
+	return nil
+}
+
+// emit 写入 SCIM outbox 事件，payload 自动附带租户、资源 ID 与操作者。
+func (s *SCIMService) emit(ctx context.Context, tx *gorm.DB, topic, tenantID, id string, payload map[string]any) error {
+	payload["tenant_id"] = tenantID
+	payload["id"] = id
+	payload["occurred_at"] = time.Now()
+	if p, ok := PrincipalFromContext(ctx); ok && p != nil {
+		payload["actor_id"] = p.UserID
+		payload["api_token_id"] = p.APITokenID
+	}
+	return emitOutbox(tx, topic, id, payload)
+}
+
+// audit 记录 SCIM 审计日志，reason 中附带调用方令牌。
+func (s *SCIMService) audit(ctx context.Context, tenantID, userID, event string, err error, detail string) {
+	status, reason := "success", detail
+	if p, ok := PrincipalFromContext(ctx); ok && p != nil {
+		reason = strings.TrimSpace(fmt.Sprintf("actor=%s token=%s %s", p.UserID, p.APITokenID, detail))
+	}
+	if err != nil {
+		status = "failed"
+		reason = strings.TrimSpace(reason + " err=" + err.Error())
+	}
+	s.m.audit(ctx, tenantID, userID, event, status, reason, "", "")
+}
+
+// invalidateUsers 在成员关系变更后清理权限与主体缓存。
+func (s *SCIMService) invalidateUsers(ctx context.Context, userIDs []string) {
+	for _, id := range userIDs {
+		_ = s.m.authorizer.invalidatePermissions(ctx, id)
+		s.m.auth.invalidateUserPrincipalCaches(ctx, id)
+	}
+}
+
+// ============================================================================
+// Bulk
+// ============================================================================
+
+// Bulk 按顺序执行批量操作。后续操作可通过 "bulkId:<id>" 引用先前 POST 创建的资源。
+func (s *SCIMService) Bulk(ctx context.Context, tenantID string, req SCIMBulkRequest) (*SCIMBulkResponse, error) {
+	ctx, span := s.m.tracer.Start(ctx, "account.scim.bulk")
+	defer span.End()
+	if len(req.Operations) > s.m.cfg.SCIM.MaxBulkOperations {
+		return nil, scimErr(http.StatusRequestEntityTooLarge, scimTypeTooMany,
+			fmt.Sprintf("too many operations, max %d", s.m.cfg.SCIM.MaxBulkOperations))
+	}
+	resp := &SCIMBulkResponse{Schemas: []string{scimSchemaBulkResponse}, Operations: []SCIMBulkResult{}}
+	resolved := map[string]string{}
+	failures := 0
+	for _, op := range req.Operations {
+		result := SCIMBulkResult{Method: strings.ToUpper(op.Method), BulkID: op.BulkID}
+		status, location, err := s.bulkOp(ctx, tenantID, op, resolved)
+		if err != nil {
+			se := toSCIMError(err)
+			result.Status, result.Response = se.Status, se
+			failures++
+		} else {
+			result.Status, result.Location = strconv.Itoa(status), location
+		}
+		resp.Operations = append(resp.Operations, result)
+		if req.FailOnErrors > 0 && failures >= req.FailOnErrors {
+			break
+		}
+	}
+	return resp, nil
+}
+
+func (s *SCIMService) bulkOp(ctx context.Context, tenantID string, op SCIMBulkOperation, resolved map[string]string) (int, string, error) {
+	path, data := op.Path, string(op.Data)
+	for bulkID, id := range resolved {
+		path = strings.ReplaceAll(path, "bulkId:"+bulkID, id)
+		data = strings.ReplaceAll(data, "bulkId:"+bulkID, id)
+	}
+	if strings.Contains(path, "bulkId:") || strings.Contains(data, "bulkId:") {
+		return 0, "", scimErr(http.StatusConflict, scimTypeInvalidValue, "unresolved bulkId reference")
+	}
+	endpoint, id, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	if endpoint != "Users" && endpoint != "Groups" {
+		return 0, "", scimErr(http.StatusBadRequest, scimTypeInvalidPath, "unsupported path "+op.Path)
+	}
+	method := strings.ToUpper(op.Method)
+	if (method == http.MethodPost) != (id == "") {
+		return 0, "", scimErr(http.StatusBadRequest, scimTypeInvalidPath, "invalid path for "+method)
+	}
+	decode := func(dst any) error {
+		if err := json.Unmarshal([]byte(data), dst); err != nil {
+			return scimErr(http.StatusBadRequest, scimTypeInvalidSyntax, err.Error())
+		}
+		return nil
+	}
+
+	var (
+		resourceID string
+		err        error
+	)
+	switch method {
+	case http.MethodPost:
+		if op.BulkID == "" {
+			return 0, "", scimErr(http.StatusBadRequest, scimTypeInvalidSyntax, "bulkId is required for POST")
+		}
+		if endpoint == "Users" {
+			var in SCIMUser
+			if err = decode(&in); err == nil {
+				var out *SCIMUser
+				if out, err = s.CreateUser(ctx, tenantID, &in); err == nil {
+					resourceID = out.ID
+				}
+			}
+		} else {
+			var in SCIMGroup
+			if err = decode(&in); err == nil {
+				var out *SCIMGroup
+				if out, err = s.CreateGroup(ctx, tenantID, &in); err == nil {
+					resourceID = out.ID
+				}
+			}
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		resolved[op.BulkID] = resourceID
+		return http.StatusCreated, scimLocation(ctx, endpoint, resourceID), nil
+	case http.MethodPut:
+		if endpoint == "Users" {
+			var in SCIMUser
+			if err = decode(&in); err == nil {
+				_, err = s.ReplaceUser(ctx, tenantID, id, &in)
+			}
+		} else {
+			var in SCIMGroup
+			if err = decode(&in); err == nil {
+				_, err = s.ReplaceGroup(ctx, tenantID, id, &in)
+			}
+		}
+	case http.MethodPatch:
+		var in SCIMPatchRequest
+		if err = decode(&in); err == nil {
+			if endpoint == "Users" {
+				_, err = s.PatchUser(ctx, tenantID, id, in)
+			} else {
+				_, err = s.PatchGroup(ctx, tenantID, id, in)
+			}
+		}
+	case http.MethodDelete:
+		if endpoint == "Users" {
+			err = s.DeleteUser(ctx, tenantID, id)
+		} else {
+			err = s.DeleteGroup(ctx, tenantID, id)
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		return http.StatusNoContent, "", nil
+	default:
+		return 0, "", scimErr(http.StatusBadRequest, scimTypeInvalidSyntax, "unsupported method "+op.Method)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return http.StatusOK, scimLocation(ctx, endpoint, id), nil
+}
+
+// ============================================================================
+// HTTP
+// ============================================================================
+
+// RegisterRoutes 在 r 上注册 SCIM 2.0 端点，r 通常为 "/scim/v2" 分组。
+// 所有端点都要求 Bearer PAT，且令牌 scope 与持有人都具备 scim:provision 权限。
+func (s *SCIMService) RegisterRoutes(r *route.RouterGroup) {
+	base := strings.TrimSuffix(r.BasePath(), "/")
+	r.Use(s.authenticate())
+	r.GET("/ServiceProviderConfig", s.handle(base, s.handleServiceProviderConfig))
+	r.GET("/ResourceTypes", s.handle(base, s.handleResourceTypes))
+	r.GET("/Schemas", s.handle(base, s.handleSchemas))
+
+	r.GET("/Users", s.handle(base, s.handleListUsers))
+	r.POST("/Users/.search", s.handle(base, s.handleSearchUsers))
+	r.POST("/Users", s.handle(base, s.handleCreateUser))
+	r.GET("/Users/:id", s.handle(base, s.handleGetUser))
+	r.PUT("/Users/:id", s.handle(base, s.handleReplaceUser))
+	r.PATCH("/Users/:id", s.handle(base, s.handlePatchUser))
+	r.DELETE("/Users/:id", s.handle(base, s.handleDeleteUser))
+
+	r.GET("/Groups", s.handle(base, s.handleListGroups))
+	r.POST("/Groups/.search", s.handle(base, s.handleSearchGroups))
+	r.POST("/Groups", s.handle(base, s.handleCreateGroup))
+	r.GET("/Groups/:id", s.handle(base, s.handleGetGroup))
+	r.PUT("/Groups/:id", s.handle(base, s.handleReplaceGroup))
+	r.PATCH("/Groups/:id", s.handle(base, s.handlePatchGroup))
+	r.DELETE("/Groups/:id", s.handle(base, s.handleDeleteGroup))
+
+	r.POST("/Bulk", s.handle(base, s.handleBulk))
+}
+
+// authenticate 校验 Bearer PAT 与 scim:provision 权限，失败时返回 SCIM 错误体。
+func (s *SCIMService) authenticate() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		token := bearerToken(string(c.GetHeader("Authorization")))
+		if token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			writeSCIMError(c, scimErr(http.StatusUnauthorized, "", "missing bearer token"))
+			return
+		}
+		principal, err := s.m.APITokens().Validate(ctx, token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+			writeSCIMError(c, scimErr(http.StatusUnauthorized, "", "invalid api token"))
+			return
+		}
+		if !apiTokenAllowsPermission(principal.Scopes, PermissionSCIMProvision) {
+			writeSCIMError(c, scimErr(http.StatusForbidden, "", "api token scope denied"))
+			return
+		}
+		// PAT 的有效权限即其 scope，而 scope 由持有人自行声明；
+		// 这里再按持有人自身的 RBAC 校验一次，避免普通用户签发 scim:provision 令牌越权
+		owner := *principal
+		owner.APITokenID, owner.Scopes = "", nil
+		decision, err := s.m.Authorizer().Check(ctx, AuthzRequest{Subject: &owner, Permission: PermissionSCIMProvision})
+		if err != nil {
+			writeSCIMError(c, err)
+			return
+		}
+		if !decision.Allowed {
+			writeSCIMError(c, scimErr(http.StatusForbidden, "", decision.Reason))
+			return
+		}
+		c.Set(principalContextKey, principal)
+		c.Next(ctx)
+	}
+}
+
+type scimHandlerFunc func(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error)
+
+func (s *SCIMService) handle(base string, fn scimHandlerFunc) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if len(c.Request.Body()) > s.m.cfg.SCIM.MaxPayloadSize {
+			writeSCIMError(c, scimErr(http.StatusRequestEntityTooLarge, "", "payload too large"))
+			return
+		}
+		v, _ := c.Get(principalContextKey)
+		principal, _ := v.(*Principal)
+		if principal == nil {
+			writeSCIMError(c, scimErr(http.StatusUnauthorized, "", "missing principal"))
+			return
+		}
+		scheme := string(c.GetHeader("X-Forwarded-Proto"))
+		if scheme == "" {
+			scheme = string(c.URI().Scheme())
+		}
+		host := string(c.Request.Header.Host())
+		if host == "" {
+			host = string(c.Request.Host())
+		}
+		ctx = context.WithValue(ctx, scimBaseURLKey{}, scheme+"://"+host+base)
+		ctx = ContextWithPrincipal(ctx, principal)
+
+		status, body, err := fn(ctx, c, principal.TenantID)
+		if err != nil {
+			writeSCIMError(c, err)
+			return
+		}
+		if body == nil {
+			c.Status(status)
+			return
+		}
+		writeSCIM(c, status, body)
+	}
+}
+
+func writeSCIM(c *app.RequestContext, status int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	c.Data(status, scimContentType, data)
+}
+
+func writeSCIMError(c *app.RequestContext, err error) {
+	se := toSCIMError(err)
+	data, _ := json.Marshal(se)
+	c.AbortWithStatus(se.HTTPStatus())
+	c.Data(se.HTTPStatus(), scimContentType, data)
+}
+
+func bindSCIM(c *app.RequestContext, dst any) error {
+	if err := json.Unmarshal(c.Request.Body(), dst); err != nil {
+		return scimErr(http.StatusBadRequest, scimTypeInvalidSyntax, err.Error())
+	}
+	return nil
+}
+
+// queryFromRequest 解析 filter / startIndex / count / attributes / excludedAttributes 查询参数。
+func queryFromRequest(c *app.RequestContext) SCIMQuery {
+	q := SCIMQuery{Filter: c.Query("filter")}
+	q.StartIndex, _ = strconv.Atoi(c.Query("startIndex"))
+	q.Count, _ = strconv.Atoi(c.Query("count"))
+	q.Attributes = splitCSV(c.Query("attributes"))
+	q.ExcludedAttributes = splitCSV(c.Query("excludedAttributes"))
+	return q
+}
+
+func splitCSV(v string) []string {
+	var out []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (s *SCIMService) handleListUsers(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	resp, err := s.ListUsers(ctx, tenantID, queryFromRequest(c))
+	return http.StatusOK, resp, err
+}
+
+func (s *SCIMService) handleSearchUsers(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var q SCIMQuery
+	if err := bindSCIM(c, &q); err != nil {
+		return 0, nil, err
+	}
+	resp, err := s.ListUsers(ctx, tenantID, q)
+	return http.StatusOK, resp, err
+}
+
+func (s *SCIMService) handleCreateUser(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var in SCIMUser
+	if err := bindSCIM(c, &in); err != nil {
+		return 0, nil, err
+	}
+	out, err := s.CreateUser(ctx, tenantID, &in)
+	if err != nil {
+		return 0, nil, err
+	}
+	c.Header("Location", out.Meta.Location)
+	return http.StatusCreated, out, nil
+}
+
+func (s *SCIMService) handleGetUser(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	out, err := s.GetUser(ctx, tenantID, c.Param("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+	q := queryFromRequest(c)
+	return http.StatusOK, scimProject(scimToMap(out), q.Attributes, q.ExcludedAttributes), nil
+}
+
+func (s *SCIMService) handleReplaceUser(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var in SCIMUser
+	if err := bindSCIM(c, &in); err != nil {
+		return 0, nil, err
+	}
+	out, err := s.ReplaceUser(ctx, tenantID, c.Param("id"), &in)
+	return http.StatusOK, out, err
+}
+
+func (s *SCIMService) handlePatchUser(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var in SCIMPatchRequest
+	if err := bindSCIM(c, &in); err != nil {
+		return 0, nil, err
+	}
+	out, err := s.PatchUser(ctx, tenantID, c.Param("id"), in)
+	return http.StatusOK, out, err
+}
+
+func (s *SCIMService) handleDeleteUser(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	return http.StatusNoContent, nil, s.DeleteUser(ctx, tenantID, c.Param("id"))
+}
+
+func (s *SCIMService) handleListGroups(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	resp, err := s.ListGroups(ctx, tenantID, queryFromRequest(c))
+	return http.StatusOK, resp, err
+}
+
+func (s *SCIMService) handleSearchGroups(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var q SCIMQuery
+	if err := bindSCIM(c, &q); err != nil {
+		return 0, nil, err
+	}
+	resp, err := s.ListGroups(ctx, tenantID, q)
+	return http.StatusOK, resp, err
+}
+
+func (s *SCIMService) handleCreateGroup(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var in SCIMGroup
+	if err := bindSCIM(c, &in); err != nil {
+		return 0, nil, err
+	}
+	out, err := s.CreateGroup(ctx, tenantID, &in)
+	if err != nil {
+		return 0, nil, err
+	}
+	c.Header("Location", out.Meta.Location)
+	return http.StatusCreated, out, nil
+}
+
+func (s *SCIMService) handleGetGroup(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	out, err := s.GetGroup(ctx, tenantID, c.Param("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+	q := queryFromRequest(c)
+	return http.StatusOK, scimProject(scimToMap(out), q.Attributes, q.ExcludedAttributes), nil
+}
+
+func (s *SCIMService) handleReplaceGroup(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var in SCIMGroup
+	if err := bindSCIM(c, &in); err != nil {
+		return 0, nil, err
+	}
+	out, err := s.ReplaceGroup(ctx, tenantID, c.Param("id"), &in)
+	return http.StatusOK, out, err
+}
+
+func (s *SCIMService) handlePatchGroup(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var in SCIMPatchRequest
+	if err := bindSCIM(c, &in); err != nil {
+		return 0, nil, err
+	}
+	out, err := s.PatchGroup(ctx, tenantID, c.Param("id"), in)
+	return http.StatusOK, out, err
+}
+
+func (s *SCIMService) handleDeleteGroup(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	return http.StatusNoContent, nil, s.DeleteGroup(ctx, tenantID, c.Param("id"))
+}
+
+func (s *SCIMService) handleBulk(ctx context.Context, c *app.RequestContext, tenantID string) (int, any, error) {
+	var in SCIMBulkRequest
+	if err := bindSCIM(c, &in); err != nil {
+		return 0, nil, err
+	}
+	resp, err := s.Bulk(ctx, tenantID, in)
+	return http.StatusOK, resp, err
+}
+
+func (s *SCIMService) handleServiceProviderConfig(ctx context.Context, _ *app.RequestContext, _ string) (int, any, error) {
+	cfg := s.m.cfg.SCIM
+	return http.StatusOK, map[string]any{
+		"schemas":        []string{scimSchemaSPConfig},
+		"patch":          map[string]any{"supported": true},
+		"bulk":           map[string]any{"supported": true, "maxOperations": cfg.MaxBulkOperations, "maxPayloadSize": cfg.MaxPayloadSize},
+		"filter":         map[string]any{"supported": true, "maxResults": cfg.MaxResults},
+		"changePassword": map[string]any{"supported": false},
+		"sort":           map[string]any{"supported": false},
+		"etag":           map[string]any{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Personal Access Token",
+			"description": "Bearer PAT with scim:provision scope",
+			"primary":     true,
+		}},
+		"meta": map[string]any{"resourceType": "ServiceProviderConfig", "location": scimLocation(ctx, "ServiceProviderConfig", "")},
+	}, nil
+}
+
+func (s *SCIMService) handleResourceTypes(ctx context.Context, _ *app.RequestContext, _ string) (int, any, error) {
+	types := []any{
+		map[string]any{
+			"schemas": []string{scimSchemaResourceType}, "id": scimResourceUser, "name": scimResourceUser,
+			"endpoint": "/Users", "schema": SCIMSchemaUser,
+			"meta": map[string]any{"resourceType": "ResourceType", "location": scimLocation(ctx, "ResourceTypes", scimResourceUser)},
+		},
+		map[string]any{
+			"schemas": []string{scimSchemaResourceType}, "id": scimResourceGroup, "name": scimResourceGroup,
+			"endpoint": "/Groups", "schema": SCIMSchemaGroup,
+			"meta": map[string]any{"resourceType": "ResourceType", "location": scimLocation(ctx, "ResourceTypes", scimResourceGroup)},
+		},
+	}
+	return http.StatusOK, &SCIMListResponse{
+		Schemas: []string{scimSchemaListResponse}, TotalResults: len(types), StartIndex: 1, ItemsPerPage: len(types), Resources: types,
+	}, nil
+}
+
+func (s *SCIMService) handleSchemas(ctx context.Context, _ *app.RequestContext, _ string) (int, any, error) {
+	attr := func(name, typ string, multi, required bool, mutability string, subs ...map[string]any) map[string]any {
+		a := map[string]any{
+			"name": name, "type": typ, "multiValued": multi, "required": required,
+			"mutability": mutability, "returned": "default", "caseExact": false, "uniqueness": "none",
+		}
+		if len(subs) > 0 {
+			a["subAttributes"] = subs
+		}
+		return a
+	}
+	multi := func(name, mutability string) map[string]any {
+		return attr(name, "complex", true, false, mutability,
+			attr("value", "string", false, false, mutability),
+			attr("type", "string", false, false, mutability),
+			attr("primary", "boolean", false, false, mutability),
+			attr("display", "string", false, false, mutability),
+		)
+	}
+	userName := attr("userName", "string", false, true, "readWrite")
+	userName["uniqueness"] = "server"
+	schemas := []any{
+		map[string]any{
+			"schemas": []string{scimSchemaSchema}, "id": SCIMSchemaUser, "name": scimResourceUser,
+			"attributes": []any{
+				userName,
+				attr("name", "complex", false, false, "readWrite",
+					attr("formatted", "string", false, false, "readWrite"),
+					attr("givenName", "string", false, false, "readWrite"),
+					attr("familyName", "string", false, false, "readWrite"),
+				),
+				attr("displayName", "string", false, false, "readWrite"),
+				attr("active", "boolean", false, false, "readWrite"),
+				multi("emails", "readWrite"),
+				multi("phoneNumbers", "readWrite"),
+				multi("groups", "readOnly"),
+			},
+			"meta": map[string]any{"resourceType": "Schema", "location": scimLocation(ctx, "Schemas", SCIMSchemaUser)},
+		},
+		map[string]any{
+			"schemas": []string{scimSchemaSchema}, "id": SCIMSchemaGroup, "name": scimResourceGroup,
+			"attributes": []any{
+				attr("displayName", "string", false, true, "readWrite"),
+				multi("members", "readWrite"),
+			},
+			"meta": map[string]any{"resourceType": "Schema", "location": scimLocation(ctx, "Schemas", SCIMSchemaGroup)},
+		},
+	}
+	return http.StatusOK, &SCIMListResponse{
+		Schemas: []string{scimSchemaListResponse}, TotalResults: len(schemas), StartIndex: 1, ItemsPerPage: len(schemas), Resources: schemas,
+	}, nil
+}
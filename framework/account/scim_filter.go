@@ -0,0 +1,728 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================================================================
+// SCIM 过滤表达式（RFC 7644 §3.4.2.2）
+// ============================================================================
+//
+// 支持的语法：
+//   - 比较：eq ne co sw ew gt ge lt le，存在性：pr
+//   - 逻辑：and or not(...)，括号分组；优先级 not > and > or
+//   - 值路径：emails[type eq "work" and value co "@example.com"]
+//   - 属性路径可带 schema URN 前缀与子属性，如 urn:...:User:name.givenName
+//
+// 过滤在资源的 JSON 表示上求值，属性名大小写不敏感，字符串比较亦大小写不敏感。
+
+// scimFilter 是解析后的过滤表达式节点。
+type scimFilter interface {
+	match(res map[string]any) bool
+}
+
+type scimAndFilter struct{ left, right scimFilter }
+
+func (f scimAndFilter) match(res map[string]any) bool { return f.left.match(res) && f.right.match(res) }
+
+type scimOrFilter struct{ left, right scimFilter }
+
+func (f scimOrFilter) match(res map[string]any) bool { return f.left.match(res) || f.right.match(res) }
+
+type scimNotFilter struct{ inner scimFilter }
+
+func (f scimNotFilter) match(res map[string]any) bool { return !f.inner.match(res) }
+
+// scimCompareFilter 属性比较，attr/sub 为去掉 URN 前缀后的属性名与子属性名。
+type scimCompareFilter struct {
+	attr  string
+	sub   string
+	op    string
+	value any
+}
+
+func (f scimCompareFilter) match(res map[string]any) bool {
+	values := scimAttrValues(res, f.attr, f.sub)
+	if f.op == "pr" {
+		for _, v := range values {
+			if scimPresent(v) {
+				return true
+			}
+		}
+		return false
+	}
+	if f.op == "ne" {
+		for _, v := range values {
+			if scimCompare(v, "eq", f.value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if scimCompare(v, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// scimValuePathFilter 值路径过滤：多值属性中任一元素满足内部过滤即匹配。
+type scimValuePathFilter struct {
+	attr  string
+	inner scimFilter
+}
+
+func (f scimValuePathFilter) match(res map[string]any) bool {
+	for _, elem := range scimElements(res, f.attr) {
+		if m, ok := elem.(map[string]any); ok && f.inner.match(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSCIMFilter 解析过滤表达式，空串返回 nil。
+func parseSCIMFilter(expr string) (scimFilter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	tokens, err := scimTokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &scimFilterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+// scimEqualityTerm 若过滤式为单个无子属性的 eq 字符串比较，返回属性名与值，用于下推到 SQL。
+func scimEqualityTerm(f scimFilter) (attr, value string, ok bool) {
+	cmp, isCmp := f.(scimCompareFilter)
+	if !isCmp || cmp.op != "eq" || cmp.sub != "" {
+		return "", "", false
+	}
+	s, isStr := cmp.value.(string)
+	return cmp.attr, s, isStr
+}
+
+const (
+	scimTokWord = iota
+	scimTokString
+	scimTokLParen
+	scimTokRParen
+	scimTokLBracket
+	scimTokRBracket
+)
+
+type scimToken struct {
+	kind int
+	text string
+}
+
+func scimTokenize(expr string) ([]scimToken, error) {
+	var tokens []scimToken
+	for i := 0; i < len(expr); {
+		ch := expr[i]
+		switch {
+		case unicode.IsSpace(rune(ch)):
+			i++
+		case ch == '(':
+			tokens = append(tokens, scimToken{kind: scimTokLParen, text: "("})
+			i++
+		case ch == ')':
+			tokens = append(tokens, scimToken{kind: scimTokRParen, text: ")"})
+			i++
+		case ch == '[':
+			tokens = append(tokens, scimToken{kind: scimTokLBracket, text: "["})
+			i++
+		case ch == ']':
+			tokens = append(tokens, scimToken{kind: scimTokRBracket, text: "]"})
+			i++
+		case ch == '"':
+			j := i + 1
+			for j < len(expr) && expr[j] != '"' {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			var s string
+			if err := json.Unmarshal([]byte(expr[i:j+1]), &s); err != nil {
+				return nil, fmt.Errorf("invalid string literal at %d", i)
+			}
+			tokens = append(tokens, scimToken{kind: scimTokString, text: s})
+			i = j + 1
+		default:
+			j := i
+			for j < len(expr) && !unicode.IsSpace(rune(expr[j])) && !strings.ContainsRune("()[]\"", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, scimToken{kind: scimTokWord, text: expr[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type scimFilterParser struct {
+	tokens []scimToken
+	pos    int
+}
+
+func (p *scimFilterParser) peekWord(word string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == scimTokWord && strings.EqualFold(p.tokens[p.pos].text, word)
+}
+
+func (p *scimFilterParser) expect(kind int, text string) error {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != kind {
+		return fmt.Errorf("expected %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *scimFilterParser) parseOr() (scimFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = scimOrFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseAnd() (scimFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = scimAndFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseUnary() (scimFilter, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	if p.peekWord("not") {
+		p.pos++
+		if err := p.expect(scimTokLParen, "("); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(scimTokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return scimNotFilter{inner: inner}, nil
+	}
+	tok := p.tokens[p.pos]
+	if tok.kind == scimTokLParen {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(scimTokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	if tok.kind != scimTokWord {
+		return nil, fmt.Errorf("expected attribute path, got %q", tok.text)
+	}
+	p.pos++
+	attr, sub := splitSCIMAttrPath(tok.text)
+	if attr == "" {
+		return nil, fmt.Errorf("invalid attribute path %q", tok.text)
+	}
+
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == scimTokLBracket {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(scimTokRBracket, "]"); err != nil {
+			return nil, err
+		}
+		return scimValuePathFilter{attr: attr, inner: inner}, nil
+	}
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != scimTokWord {
+		return nil, fmt.Errorf("expected operator after %q", tok.text)
+	}
+	op := strings.ToLower(p.tokens[p.pos].text)
+	p.pos++
+	switch op {
+	case "pr":
+		return scimCompareFilter{attr: attr, sub: sub, op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("expected value after %q", op)
+	}
+	valTok := p.tokens[p.pos]
+	p.pos++
+	var value any
+	switch {
+	case valTok.kind == scimTokString:
+		value = valTok.text
+	case valTok.kind != scimTokWord:
+		return nil, fmt.Errorf("invalid comparison value %q", valTok.text)
+	case strings.EqualFold(valTok.text, "true"):
+		value = true
+	case strings.EqualFold(valTok.text, "false"):
+		value = false
+	case strings.EqualFold(valTok.text, "null"):
+		value = nil
+	default:
+		n, err := strconv.ParseFloat(valTok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid comparison value %q", valTok.text)
+		}
+		value = n
+	}
+	return scimCompareFilter{attr: attr, sub: sub, op: op, value: value}, nil
+}
+
+// splitSCIMAttrPath 去掉 schema URN 前缀，拆出属性名与子属性名。
+func splitSCIMAttrPath(path string) (attr, sub string) {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		if i := strings.LastIndex(path, ":"); i >= 0 {
+			path = path[i+1:]
+		}
+	}
+	attr, sub, _ = strings.Cut(path, ".")
+	return attr, sub
+}
+
+// scimLookup 大小写不敏感地读取 map 中的属性，返回实际键名。
+func scimLookup(m map[string]any, name string) (string, any, bool) {
+	if v, ok := m[name]; ok {
+		return name, v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return k, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// scimElements 返回属性值；多值属性展开为元素列表。
+func scimElements(res map[string]any, attr string) []any {
+	_, v, ok := scimLookup(res, attr)
+	if !ok || v == nil {
+		return nil
+	}
+	if arr, isArr := v.([]any); isArr {
+		return arr
+	}
+	return []any{v}
+}
+
+// scimAttrValues 返回参与比较的标量值。多值复杂属性未指定子属性时取各元素的 value。
+func scimAttrValues(res map[string]any, attr, sub string) []any {
+	var out []any
+	for _, elem := range scimElements(res, attr) {
+		m, isMap := elem.(map[string]any)
+		switch {
+		case sub != "" && isMap:
+			if _, v, ok := scimLookup(m, sub); ok {
+				out = append(out, v)
+			}
+		case sub == "" && isMap:
+			if _, v, ok := scimLookup(m, "value"); ok {
+				out = append(out, v)
+			}
+		case sub == "":
+			out = append(out, elem)
+		}
+	}
+	return out
+}
+
+func scimPresent(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case string:
+		return x != ""
+	case []any:
+		return len(x) > 0
+	case map[string]any:
+		return len(x) > 0
+	}
+	return true
+}
+
+// scimCompare 比较资源属性值 actual 与过滤值 expected。
+func scimCompare(actual any, op string, expected any) bool {
+	switch want := expected.(type) {
+	case nil:
+		return op == "eq" && !scimPresent(actual)
+	case bool:
+		got, ok := actual.(bool)
+		return ok && op == "eq" && got == want
+	case float64:
+		got, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		return scimOrdered(op, compareFloat(got, want))
+	case string:
+		got, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		a, b := strings.ToLower(got), strings.ToLower(want)
+		switch op {
+		case "eq":
+			return a == b
+		case "co":
+			return strings.Contains(a, b)
+		case "sw":
+			return strings.HasPrefix(a, b)
+		case "ew":
+			return strings.HasSuffix(a, b)
+		}
+		// 时间戳按时间比较，其余按字典序
+		ta, errA := time.Parse(time.RFC3339, got)
+		tb, errB := time.Parse(time.RFC3339, want)
+		if errA == nil && errB == nil {
+			return scimOrdered(op, ta.Compare(tb))
+		}
+		return scimOrdered(op, strings.Compare(a, b))
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func scimOrdered(op string, cmp int) bool {
+	switch op {
+	case "eq":
+		return cmp == 0
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "le":
+		return cmp <= 0
+	}
+	return false
+}
+
+// ============================================================================
+// SCIM PATCH（RFC 7644 §3.5.2）
+// ============================================================================
+
+// SCIMPatchOperation 单个 PATCH 操作。op 大小写不敏感（兼容 Azure AD 的 "Replace"）。
+type SCIMPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// SCIMPatchRequest PATCH 请求体。
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// scimPatchPath 解析后的 PATCH 路径：attr[filter].sub。
+type scimPatchPath struct {
+	attr   string
+	sub    string
+	filter scimFilter
+}
+
+func parseSCIMPatchPath(path string) (*scimPatchPath, error) {
+	path = strings.TrimSpace(path)
+	head, rest, hasFilter := strings.Cut(path, "[")
+	attr, sub := splitSCIMAttrPath(head)
+	if attr == "" {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+	p := &scimPatchPath{attr: attr, sub: sub}
+	if !hasFilter {
+		return p, nil
+	}
+	if sub != "" {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+	end := strings.LastIndex(rest, "]")
+	if end < 0 {
+		return nil, fmt.Errorf("invalid path %q: missing ]", path)
+	}
+	f, err := parseSCIMFilter(rest[:end])
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, fmt.Errorf("invalid path %q: empty filter", path)
+	}
+	p.filter = f
+	if tail := rest[end+1:]; tail != "" {
+		if !strings.HasPrefix(tail, ".") || len(tail) < 2 {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+		p.sub = tail[1:]
+	}
+	return p, nil
+}
+
+// applySCIMPatch 将 PATCH 操作依次作用于资源的 JSON 表示。
+func applySCIMPatch(res map[string]any, ops []SCIMPatchOperation) error {
+	for i, op := range ops {
+		kind := strings.ToLower(op.Op)
+		if kind != "add" && kind != "replace" && kind != "remove" {
+			return scimErr(400, scimTypeInvalidSyntax, fmt.Sprintf("Operations[%d]: unsupported op %q", i, op.Op))
+		}
+		var err error
+		if strings.TrimSpace(op.Path) == "" {
+			err = patchWithoutPath(res, kind, op.Value)
+		} else {
+			var path *scimPatchPath
+			if path, err = parseSCIMPatchPath(op.Path); err != nil {
+				return scimErr(400, scimTypeInvalidPath, fmt.Sprintf("Operations[%d]: %v", i, err))
+			}
+			err = patchPath(res, kind, path, op.Value)
+		}
+		if err != nil {
+			if se, ok := err.(*SCIMError); ok {
+				return se
+			}
+			return scimErr(400, scimTypeInvalidValue, fmt.Sprintf("Operations[%d]: %v", i, err))
+		}
+	}
+	return nil
+}
+
+func patchWithoutPath(res map[string]any, kind string, value any) error {
+	if kind == "remove" {
+		return scimErr(400, scimTypeNoTarget, "remove requires path")
+	}
+	attrs, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("value must be an object when path is omitted")
+	}
+	for k, v := range attrs {
+		// 值对象中的键也可能是带 URN 的完整路径，或 "name.givenName" 形式
+		path, err := parseSCIMPatchPath(k)
+		if err != nil {
+			return err
+		}
+		if err := patchPath(res, kind, path, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func patchPath(res map[string]any, kind string, path *scimPatchPath, value any) error {
+	key, current, exists := scimLookup(res, path.attr)
+	if !exists {
+		key = path.attr
+	}
+
+	if path.filter != nil {
+		return patchFiltered(res, key, current, kind, path, value)
+	}
+
+	if path.sub != "" {
+		if arr, isArr := current.([]any); isArr {
+			for _, elem := range arr {
+				if m, ok := elem.(map[string]any); ok {
+					setOrRemoveSub(m, path.sub, kind, value)
+				}
+			}
+			return nil
+		}
+		m, _ := current.(map[string]any)
+		if m == nil {
+			if kind == "remove" {
+				return nil
+			}
+			m = map[string]any{}
+			res[key] = m
+		}
+		setOrRemoveSub(m, path.sub, kind, value)
+		return nil
+	}
+
+	switch kind {
+	case "remove":
+		// remove 带 value 时仅移除多值属性中 value 相同的元素（Azure AD 移除成员的写法）
+		if arr, isArr := current.([]any); isArr && value != nil {
+			res[key] = removeByValue(arr, value)
+			return nil
+		}
+		delete(res, key)
+	case "add":
+		if arr, isArr := current.([]any); isArr {
+			res[key] = appendUnique(arr, value)
+			return nil
+		}
+		if existing, ok := current.(map[string]any); ok {
+			if incoming, ok := value.(map[string]any); ok {
+				for k, v := range incoming {
+					setOrRemoveSub(existing, k, "replace", v)
+				}
+				return nil
+			}
+		}
+		res[key] = value
+	case "replace":
+		res[key] = value
+	}
+	return nil
+}
+
+func patchFiltered(res map[string]any, key string, current any, kind string, path *scimPatchPath, value any) error {
+	arr, _ := current.([]any)
+	matched := false
+	kept := arr[:0:0]
+	for _, elem := range arr {
+		m, ok := elem.(map[string]any)
+		if !ok || !path.filter.match(m) {
+			kept = append(kept, elem)
+			continue
+		}
+		matched = true
+		switch {
+		case kind == "remove" && path.sub == "":
+			continue
+		case path.sub != "":
+			setOrRemoveSub(m, path.sub, kind, value)
+		default:
+			if incoming, ok := value.(map[string]any); ok {
+				for k, v := range incoming {
+					setOrRemoveSub(m, k, "replace", v)
+				}
+			}
+		}
+		kept = append(kept, m)
+	}
+	if !matched && kind != "remove" {
+		// 目标不存在时按 add 处理：以过滤条件中的 eq 项构造新元素，如 emails[type eq "work"].value
+		attr, v, ok := scimEqualityTerm(path.filter)
+		if !ok {
+			return scimErr(400, scimTypeNoTarget, "no element matches path filter")
+		}
+		elem := map[string]any{attr: v}
+		if path.sub != "" {
+			elem[path.sub] = value
+		} else if incoming, ok := value.(map[string]any); ok {
+			for k, val := range incoming {
+				elem[k] = val
+			}
+		}
+		kept = append(kept, elem)
+	}
+	res[key] = kept
+	return nil
+}
+
+func setOrRemoveSub(m map[string]any, sub, kind string, value any) {
+	key, _, exists := scimLookup(m, sub)
+	if !exists {
+		key = sub
+	}
+	if kind == "remove" {
+		delete(m, key)
+		return
+	}
+	m[key] = value
+}
+
+// scimElementValue 返回多值元素的 value 子属性，用于去重与按值移除。
+func scimElementValue(elem any) any {
+	if m, ok := elem.(map[string]any); ok {
+		_, v, _ := scimLookup(m, "value")
+		return v
+	}
+	return elem
+}
+
+func appendUnique(arr []any, value any) []any {
+	incoming, isArr := value.([]any)
+	if !isArr {
+		incoming = []any{value}
+	}
+	out := append([]any(nil), arr...)
+	for _, v := range incoming {
+		dup := false
+		for _, e := range out {
+			if fmt.Sprint(scimElementValue(e)) == fmt.Sprint(scimElementValue(v)) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func removeByValue(arr []any, value any) []any {
+	incoming, isArr := value.([]any)
+	if !isArr {
+		incoming = []any{value}
+	}
+	drop := make(map[string]bool, len(incoming))
+	for _, v := range incoming {
+		drop[fmt.Sprint(scimElementValue(v))] = true
+	}
+	out := arr[:0:0]
+	for _, e := range arr {
+		if !drop[fmt.Sprint(scimElementValue(e))] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
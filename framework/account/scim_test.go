@@ -0,0 +1,397 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSCIMFilterMatch(t *testing.T) {
+	res := map[string]any{
+		"userName": "Alice@Example.com",
+		"active":   true,
+		"name":     map[string]any{"givenName": "Alice", "familyName": "Liddell"},
+		"emails": []any{
+			map[string]any{"value": "alice@work.com", "type": "work"},
+			map[string]any{"value": "alice@home.com", "type": "home"},
+		},
+		"meta": map[string]any{"lastModified": "2026-10-18T08:00:00Z"},
+	}
+	cases := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "alice@example.com"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "ALICE"`, true},
+		{`name.familyName co "dell"`, true},
+		{`emails co "home.com"`, true},
+		{`emails[type eq "work" and value ew "@work.com"]`, true},
+		{`emails[type eq "other"]`, false},
+		{`active eq false`, false},
+		{`not (active eq false) and userName pr`, true},
+		{`phoneNumbers pr or (name.givenName eq "Bob")`, false},
+		{`userName ne "bob"`, true},
+		{`meta.lastModified gt "2026-10-01T00:00:00Z"`, true},
+	}
+	for _, tc := range cases {
+		f, err := parseSCIMFilter(tc.filter)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.filter, err)
+		}
+		if got := f.match(res); got != tc.want {
+			t.Errorf("%q = %v, want %v", tc.filter, got, tc.want)
+		}
+	}
+	for _, bad := range []string{`userName xx "a"`, `userName eq`, `(userName pr`, `emails[type eq "work"`} {
+		if _, err := parseSCIMFilter(bad); err == nil {
+			t.Errorf("%q 应解析失败", bad)
+		}
+	}
+}
+
+func TestSCIMApplyPatch(t *testing.T) {
+	res := map[string]any{
+		"userName": "alice",
+		"active":   true,
+		"emails":   []any{map[string]any{"value": "a@work.com", "type": "work", "primary": true}},
+		"members":  []any{map[string]any{"value": "u1"}, map[string]any{"value": "u2"}},
+	}
+	err := applySCIMPatch(res, []SCIMPatchOperation{
+		{Op: "Replace", Path: "active", Value: false},
+		{Op: "replace", Path: `emails[type eq "work"].value`, Value: "b@work.com"},
+		{Op: "add", Path: `emails[type eq "home"].value`, Value: "b@home.com"},
+		{Op: "add", Path: "name.givenName", Value: "Bob"},
+		{Op: "add", Path: "members", Value: []any{map[string]any{"value": "u2"}, map[string]any{"value": "u3"}}},
+		{Op: "remove", Path: `members[value eq "u1"]`},
+		{Op: "replace", Value: map[string]any{"displayName": "Bob B"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["active"] != false || res["displayName"] != "Bob B" {
+		t.Fatalf("replace 未生效: %+v", res)
+	}
+	emails := res["emails"].([]any)
+	if len(emails) != 2 || emails[0].(map[string]any)["value"] != "b@work.com" || emails[1].(map[string]any)["value"] != "b@home.com" {
+		t.Fatalf("emails 更新错误: %+v", emails)
+	}
+	if res["name"].(map[string]any)["givenName"] != "Bob" {
+		t.Fatalf("子属性 add 未生效: %+v", res["name"])
+	}
+	var members []string
+	for _, m := range res["members"].([]any) {
+		members = append(members, m.(map[string]any)["value"].(string))
+	}
+	if fmt.Sprint(members) != "[u2 u3]" {
+		t.Fatalf("members = %v", members)
+	}
+	if err := applySCIMPatch(res, []SCIMPatchOperation{{Op: "move", Path: "active"}}); err == nil {
+		t.Fatal("不支持的 op 应报错")
+	}
+}
+
+// scimTestEnv 基于 sqlite 的 SCIM 端到端测试环境。
+type scimTestEnv struct {
+	m     *Manager
+	h     *server.Hertz
+	token string
+}
+
+func newSCIMTestEnv(t *testing.T, backend string) *scimTestEnv {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "scim.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testAuthConfig()
+	cfg.DB = db
+	cfg.SCIM.GroupBackend = backend
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := m.Bootstrap(ctx); err != nil {
+		t.Fatal(err)
+	}
+	owner := &User{Username: "idp-provisioner"}
+	if err := m.Admin().CreateUser(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	var adminRole Role
+	if err := db.Where("code = ?", "tenant_admin").First(&adminRole).Error; err != nil {
+		t.Fatal(err)
+	}
+	var perm Permission
+	if err := db.Where("code = ?", PermissionSCIMProvision).First(&perm).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Admin().AssignPermissionToRole(ctx, "default", adminRole.ID, perm.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Admin().AssignUserRole(ctx, owner.ID, adminRole.ID); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := m.APITokens().Create(ctx, CreateAPITokenRequest{UserID: owner.ID, Name: "scim", Scopes: []string{"scim:*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := server.New()
+	m.SCIM().RegisterRoutes(h.Group("/scim/v2"))
+	return &scimTestEnv{m: m, h: h, token: tok.Token}
+}
+
+func (e *scimTestEnv) do(t *testing.T, method, path string, body any, out any) int {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	w := ut.PerformRequest(e.h.Engine, method, path, &ut.Body{Body: bytes.NewReader(data), Len: len(data)},
+		ut.Header{Key: "Host", Value: "example.com"},
+		ut.Header{Key: "Authorization", Value: "Bearer " + e.token},
+		ut.Header{Key: "Content-Type", Value: scimContentType})
+	resp := w.Result()
+	if out != nil && len(resp.Body()) > 0 {
+		if err := json.Unmarshal(resp.Body(), out); err != nil {
+			t.Fatalf("%s %s: decode %s: %v", method, path, resp.Body(), err)
+		}
+	}
+	return resp.StatusCode()
+}
+
+func (e *scimTestEnv) outboxCount(t *testing.T, topic string) int64 {
+	var n int64
+	e.m.db.Model(&OutboxEvent{}).Where("topic = ?", topic).Count(&n)
+	return n
+}
+
+func TestSCIMUsersEndToEnd(t *testing.T) {
+	env := newSCIMTestEnv(t, SCIMGroupBackendRole)
+
+	var created SCIMUser
+	code := env.do(t, http.MethodPost, "/scim/v2/Users", map[string]any{
+		"schemas":    []string{SCIMSchemaUser},
+		"userName":   "alice@example.com",
+		"externalId": "okta-001",
+		"name":       map[string]any{"givenName": "Alice", "familyName": "Liddell"},
+		"emails":     []any{map[string]any{"value": "Alice@Example.com", "primary": true}},
+		"active":     true,
+	}, &created)
+	if code != http.StatusCreated || created.ID == "" || created.ExternalID != "okta-001" {
+		t.Fatalf("创建用户失败: %d %+v", code, created)
+	}
+	if created.DisplayName != "Alice Liddell" || len(created.Emails) != 1 || created.Emails[0].Value != "alice@example.com" {
+		t.Fatalf("字段映射错误: %+v", created)
+	}
+	if created.Meta == nil || created.Meta.Location != "http://example.com/scim/v2/Users/"+created.ID {
+		t.Fatalf("meta.location 错误: %+v", created.Meta)
+	}
+
+	var scimErrResp SCIMError
+	if code := env.do(t, http.MethodPost, "/scim/v2/Users", map[string]any{"userName": "alice@example.com"}, &scimErrResp); code != http.StatusConflict || scimErrResp.ScimType != scimTypeUniqueness {
+		t.Fatalf("重复 userName 应返回 409 uniqueness，实际 %d %+v", code, scimErrResp)
+	}
+
+	var list SCIMListResponse
+	env.do(t, http.MethodGet, `/scim/v2/Users?filter=externalId%20eq%20%22okta-001%22`, nil, &list)
+	if list.TotalResults != 1 {
+		t.Fatalf("按 externalId 过滤应返回 1 条，实际 %+v", list)
+	}
+	env.do(t, http.MethodGet, `/scim/v2/Users?filter=emails%20co%20%22example%22&attributes=userName`, nil, &list)
+	if list.TotalResults != 1 || list.Resources[0].(map[string]any)["emails"] != nil {
+		t.Fatalf("内存过滤或属性裁剪错误: %+v", list)
+	}
+	env.do(t, http.MethodGet, "/scim/v2/Users?startIndex=1&count=1", nil, &list)
+	if list.TotalResults != 2 || list.ItemsPerPage != 1 {
+		t.Fatalf("分页错误: %+v", list)
+	}
+
+	var patched SCIMUser
+	code = env.do(t, http.MethodPatch, "/scim/v2/Users/"+created.ID, map[string]any{
+		"schemas": []string{scimSchemaPatchOp},
+		"Operations": []any{
+			map[string]any{"op": "Replace", "path": "active", "value": "False"},
+			map[string]any{"op": "replace", "path": "name.givenName", "value": "Alicia"},
+		},
+	}, &patched)
+	if code != http.StatusOK || patched.Active == nil || *patched.Active || patched.DisplayName != "Alicia" {
+		t.Fatalf("PATCH 失败: %d %+v", code, patched)
+	}
+	var user User
+	env.m.db.Where("id = ?", created.ID).First(&user)
+	if user.Status != UserStatusDisabled || user.AuthVersion != 2 {
+		t.Fatalf("停用后状态应为 disabled 且 auth_version 递增: %+v", user)
+	}
+
+	if code := env.do(t, http.MethodDelete, "/scim/v2/Users/"+created.ID, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("删除用户应返回 204，实际 %d", code)
+	}
+	if code := env.do(t, http.MethodGet, "/scim/v2/Users/"+created.ID, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("删除后应返回 404，实际 %d", code)
+	}
+
+	for topic, want := range map[string]int64{EventSCIMUserCreated: 1, EventSCIMUserUpdated: 1, EventSCIMUserDeleted: 1, EventUserDeleted: 1} {
+		if got := env.outboxCount(t, topic); got != want {
+			t.Errorf("outbox %s = %d, want %d", topic, got, want)
+		}
+	}
+	var audits int64
+	env.m.db.Model(&AuditLog{}).Where("event LIKE ? AND status = ?", "scim_user_%", "success").Count(&audits)
+	if audits != 3 {
+		t.Errorf("应记录 3 条 SCIM 审计，实际 %d", audits)
+	}
+}
+
+func TestSCIMGroupsAndBulk(t *testing.T) {
+	for _, backend := range []string{SCIMGroupBackendRole, SCIMGroupBackendOrg} {
+		t.Run(backend, func(t *testing.T) {
+			env := newSCIMTestEnv(t, backend)
+
+			var bulk SCIMBulkResponse
+			code := env.do(t, http.MethodPost, "/scim/v2/Bulk", map[string]any{
+				"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:BulkRequest"},
+				"Operations": []any{
+					map[string]any{"method": "POST", "path": "/Users", "bulkId": "u1", "data": map[string]any{"userName": "bob"}},
+					map[string]any{"method": "POST", "path": "/Users", "bulkId": "u2", "data": map[string]any{"userName": "carol"}},
+					map[string]any{"method": "POST", "path": "/Groups", "bulkId": "g1", "data": map[string]any{
+						"displayName": "Engineering",
+						"members":     []any{map[string]any{"value": "bulkId:u1"}, map[string]any{"value": "bulkId:u2"}},
+					}},
+					map[string]any{"method": "POST", "path": "/Groups", "bulkId": "g2", "data": map[string]any{
+						"displayName": "Broken",
+						"members":     []any{map[string]any{"value": "bulkId:missing"}},
+					}},
+				},
+			}, &bulk)
+			if code != http.StatusOK || len(bulk.Operations) != 4 {
+				t.Fatalf("bulk 失败: %d %+v", code, bulk)
+			}
+			for i, want := range []string{"201", "201", "201", "409"} {
+				if bulk.Operations[i].Status != want {
+					t.Fatalf("Operations[%d].status = %s, want %s (%+v)", i, bulk.Operations[i].Status, want, bulk.Operations[i])
+				}
+			}
+
+			var list SCIMListResponse
+			env.do(t, http.MethodGet, `/scim/v2/Groups?filter=displayName%20eq%20%22Engineering%22`, nil, &list)
+			if list.TotalResults != 1 {
+				t.Fatalf("按 displayName 过滤应返回 1 条，实际 %+v", list)
+			}
+			group := list.Resources[0].(map[string]any)
+			groupID := group["id"].(string)
+			members := group["members"].([]any)
+			if len(members) != 2 {
+				t.Fatalf("成员数应为 2: %+v", group)
+			}
+			bobID := members[0].(map[string]any)["value"].(string)
+			if members[0].(map[string]any)["display"] != "bob" {
+				bobID = members[1].(map[string]any)["value"].(string)
+			}
+
+			var bob SCIMUser
+			env.do(t, http.MethodGet, "/scim/v2/Users/"+bobID, nil, &bob)
+			if len(bob.Groups) != 1 || bob.Groups[0].Value != groupID {
+				t.Fatalf("用户 groups 应包含 Engineering: %+v", bob.Groups)
+			}
+
+			var patched SCIMGroup
+			code = env.do(t, http.MethodPatch, "/scim/v2/Groups/"+groupID, map[string]any{
+				"schemas": []string{scimSchemaPatchOp},
+				"Operations": []any{
+					map[string]any{"op": "remove", "path": fmt.Sprintf(`members[value eq "%s"]`, bobID)},
+					map[string]any{"op": "replace", "path": "displayName", "value": "Platform"},
+				},
+			}, &patched)
+			if code != http.StatusOK || patched.DisplayName != "Platform" || len(patched.Members) != 1 {
+				t.Fatalf("PATCH 组失败: %d %+v", code, patched)
+			}
+			var user User
+			env.m.db.Where("id = ?", bobID).First(&user)
+			if user.RolesVersion < 3 {
+				t.Fatalf("成员变更应递增 roles_version，实际 %d", user.RolesVersion)
+			}
+
+			if code := env.do(t, http.MethodDelete, "/scim/v2/Groups/"+groupID, nil, nil); code != http.StatusNoContent {
+				t.Fatalf("删除组应返回 204，实际 %d", code)
+			}
+			env.do(t, http.MethodGet, "/scim/v2/Groups", nil, &list)
+			if list.TotalResults != 0 {
+				t.Fatalf("删除后不应再有组: %+v", list)
+			}
+			for topic, want := range map[string]int64{EventSCIMGroupCreated: 1, EventSCIMGroupUpdated: 1, EventSCIMGroupDeleted: 1} {
+				if got := env.outboxCount(t, topic); got != want {
+					t.Errorf("outbox %s = %d, want %d", topic, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSCIMSystemRolesHidden(t *testing.T) {
+	env := newSCIMTestEnv(t, SCIMGroupBackendRole)
+	var list SCIMListResponse
+	env.do(t, http.MethodGet, "/scim/v2/Groups", nil, &list)
+	if list.TotalResults != 0 {
+		t.Fatalf("系统角色不应作为 SCIM 组暴露: %+v", list)
+	}
+	var admin Role
+	env.m.db.Where("code = ?", "platform_admin").First(&admin)
+	if code := env.do(t, http.MethodPatch, "/scim/v2/Groups/"+admin.ID, map[string]any{
+		"Operations": []any{map[string]any{"op": "add", "path": "members", "value": []any{map[string]any{"value": "x"}}}},
+	}, nil); code != http.StatusNotFound {
+		t.Fatalf("修改系统角色应返回 404，实际 %d", code)
+	}
+}
+
+func TestSCIMAuthentication(t *testing.T) {
+	env := newSCIMTestEnv(t, SCIMGroupBackendRole)
+	var spc map[string]any
+	if code := env.do(t, http.MethodGet, "/scim/v2/ServiceProviderConfig", nil, &spc); code != http.StatusOK {
+		t.Fatalf("ServiceProviderConfig 应返回 200，实际 %d", code)
+	}
+
+	valid := env.token
+	env.token = "gaia_pat_invalid"
+	if code := env.do(t, http.MethodGet, "/scim/v2/Users", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("无效令牌应返回 401，实际 %d", code)
+	}
+
+	ctx := context.Background()
+	var owner PersonalAccessToken
+	env.m.db.First(&owner)
+	tok, err := env.m.APITokens().Create(ctx, CreateAPITokenRequest{UserID: owner.UserID, Name: "read", Scopes: []string{"user:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.token = tok.Token
+	var se SCIMError
+	if code := env.do(t, http.MethodGet, "/scim/v2/Users", nil, &se); code != http.StatusForbidden || se.Status != "403" {
+		t.Fatalf("scope 不含 scim:provision 应返回 403，实际 %d %+v", code, se)
+	}
+
+	// 持有人本身没有 scim:provision 时，自行声明的 scope 不能越权
+	plain := &User{Username: "plain-user"}
+	if err := env.m.Admin().CreateUser(ctx, plain); err != nil {
+		t.Fatal(err)
+	}
+	tok, err = env.m.APITokens().Create(ctx, CreateAPITokenRequest{UserID: plain.ID, Name: "forged", Scopes: []string{PermissionSCIMProvision}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.token = tok.Token
+	if code := env.do(t, http.MethodGet, "/scim/v2/Users", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("持有人无 scim:provision 权限应返回 403，实际 %d", code)
+	}
+	env.token = valid
+}
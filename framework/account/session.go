@@ -22,6 +22,8 @@ type SessionInfo struct {
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
 	IsCurrent bool      `json:"is_current"`
+	// Impersonated 为 true 表示该会话由管理员代操作发起，用户可在会话列表中看到并撤销。
+	Impersonated bool `json:"impersonated,omitempty"`
 }
 
 // List 返回用户的活跃会话列表。currentSessionID 会被标记为当前会话。
@@ -37,13 +39,14 @@ func (s *SessionService) List(ctx context.Context, tenantID, userID, currentSess
 	infos := make([]SessionInfo, 0, len(sessions))
 	for _, s := range sessions {
 		infos = append(infos, SessionInfo{
-			ID:        s.ID,
-			DeviceID:  s.DeviceID,
-			IP:        s.IP,
-			Status:    s.Status,
-			CreatedAt: s.CreatedAt,
-			ExpiresAt: s.ExpiresAt,
-			IsCurrent: s.ID == currentSessionID,
+			ID:           s.ID,
+			DeviceID:     s.DeviceID,
+			IP:           s.IP,
+			Status:       s.Status,
+			CreatedAt:    s.CreatedAt,
+			ExpiresAt:    s.ExpiresAt,
+			IsCurrent:    s.ID == currentSessionID,
+			Impersonated: s.ActorID != "",
 		})
 	}
 	return infos, nil
@@ -144,3 +147,15 @@ func (s *SessionService) RevokeByID(ctx context.Context, tenantID, sessionID str
 		return nil
 	})
 }
+
+// revokeUserSessionsTx 在事务内撤销用户的全部活跃会话及其刷新令牌。
+func revokeUserSessionsTx(tx *gorm.DB, userID string) error {
+	if err := tx.Model(&Session{}).Where("user_id = ? AND status = ?", userID, SessionActive).Updates(map[string]any{
+		"status":     SessionRevoked,
+		"revoked_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	sessions := tx.Session(&gorm.Session{NewDB: true}).Model(&Session{}).Select("id").Where("user_id = ?", userID)
+	return tx.Model(&RefreshToken{}).Where("session_id IN (?)", sessions).Update("status", RefreshRevoked).Error
+}
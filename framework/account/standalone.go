@@ -3,9 +3,11 @@ package account
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol"
 	"github.com/cloudwego/hertz/pkg/route"
 	"github.com/xxzhwl/gaia"
 	"github.com/xxzhwl/gaia/framework/server"
@@ -37,6 +39,8 @@ const (
 	ModulePasskey      RouteModule = "passkey"      // /passkey/*
 	ModuleAudit        RouteModule = "audit"        // /audit/* 自助查询
 	ModuleAdmin        RouteModule = "admin"        // /admin/* 后台管理端
+	ModuleSCIM         RouteModule = "scim"         // /scim/v2/* SCIM 2.0 预配置
+	ModuleSAML         RouteModule = "saml"         // /saml/* SAML 2.0 SP 端点
 )
 
 // RouteProfile 部署形态预设。允许业务方一行配置切出"对外/管理端"两套服务。
@@ -48,7 +52,7 @@ const (
 	// ProfilePublic 面向 C 端/前端：开放认证、用户自助、会话、MFA、组织只读、
 	// OIDC、Passkey 与自助审计；关闭 admin、idp 客户端管理。
 	ProfilePublic RouteProfile = "public"
-	// ProfileAdmin 仅管理端：开放健康检查、登录登出、会话与用户自助接口及 /admin/*。
+	// ProfileAdmin 仅管理端：开放健康检查、登录登出、会话与用户自助接口、/admin/* 及 /scim/v2/*。
 	// 推荐部署在内网/管理 VPC，与 ProfilePublic 进程分离。
 	ProfileAdmin RouteProfile = "admin"
 )
@@ -66,6 +70,10 @@ type StandaloneConfig struct {
 	// DisabledModules 在 Profile 之上额外禁用的模块（取差集）。
 	// 与 EnabledModules 同时出现时，禁用优先级更高。
 	DisabledModules []RouteModule
+	// DeviceVerificationPage 设备授权流程的用户码验证页钩子，挂载到 GET /oauth/device（查询参数 user_code）。
+	// 页面负责登录后调用 GET/POST /oauth/device/verify 确认授权；为空且未配置
+	// Account.IdP.DeviceVerificationURI 时设备授权端点不可用。
+	DeviceVerificationPage app.HandlerFunc
 }
 
 // profileBaseModules 返回某个 Profile 内置启用的模块集合。
@@ -79,6 +87,7 @@ func profileBaseModules(p RouteProfile) map[RouteModule]bool {
 			ModuleUser:    true,
 			ModuleSession: true,
 			ModuleAdmin:   true,
+			ModuleSCIM:    true,
 		}
 	case ProfilePublic:
 		return map[RouteModule]bool{
@@ -92,7 +101,8 @@ func profileBaseModules(p RouteProfile) map[RouteModule]bool {
 			ModuleOIDC:         true,
 			ModulePasskey:      true,
 			ModuleAudit:        true,
-			// 默认不暴露 ModuleAdmin / ModuleIdP
+			ModuleSAML:         true,
+			// 默认不暴露 ModuleAdmin / ModuleIdP / ModuleSCIM
 		}
 	case ProfileFull, "":
 		fallthrough
@@ -110,6 +120,8 @@ func profileBaseModules(p RouteProfile) map[RouteModule]bool {
 			ModulePasskey:      true,
 			ModuleAudit:        true,
 			ModuleAdmin:        true,
+			ModuleSCIM:         true,
+			ModuleSAML:         true,
 		}
 	}
 }
@@ -185,6 +197,9 @@ func (s *StandaloneService) Run(ctx context.Context) error {
 	if s.m.cfg.Audit.AsyncWrite {
 		s.m.StartAuditWriter(ctx)
 	}
+	if s.m.LDAP().Enabled() && s.m.cfg.LDAP.SyncInterval > 0 {
+		s.m.StartLDAPSyncTask(cleanupCtx, s.m.cfg.LDAP.SyncInterval)
+	}
 
 	s.registerRoutes()
 
@@ -241,6 +256,12 @@ func (s *StandaloneService) registerRoutes() {
 	if enabled[ModuleAdmin] {
 		s.registerAdminRoutes(r)
 	}
+	if enabled[ModuleSCIM] {
+		s.m.SCIM().RegisterRoutes(r.Group("/scim/v2"))
+	}
+	if enabled[ModuleSAML] && s.m.SAML().Enabled() {
+		s.m.SAML().RegisterRoutes(r.Group("/saml"))
+	}
 
 	gaia.InfoF("[account] standalone routes registered: profile=%s modules=%v",
 		nonEmptyProfile(s.cfg.Profile), modulesToSlice(enabled))
@@ -268,6 +289,14 @@ func (s *StandaloneService) registerAuthRoutes(r *route.RouterGroup) {
 	auth.POST("/login", s.handler(s.handleLogin))
 	auth.POST("/login/code", s.handler(s.handleLoginWithCode))
 	auth.POST("/login/oauth/:provider", s.handler(s.handleOAuthLogin))
+	auth.POST("/login/oauth/:provider/start", s.handler(s.handleOAuthStart))
+	if s.m.LDAP().Enabled() {
+		auth.POST("/login/ldap", s.handler(s.handleLDAPLogin))
+	}
+	if s.m.SAML().Enabled() {
+		auth.POST("/login/saml", s.handler(s.handleSAMLLogin))
+		auth.POST("/logout/saml", s.m.Middleware().Authenticate(), s.handler(s.handleSAMLLogout))
+	}
 	auth.POST("/bind-phone-and-login", s.handler(s.handleBindPhoneAndLogin))
 	auth.POST("/mfa/complete", s.handler(s.handleCompleteMFA))
 	auth.POST("/mfa/code", s.m.Middleware().Authenticate(), s.handler(s.handleRequestMFACode))
@@ -291,7 +320,7 @@ func (s *StandaloneService) registerUserRoutes(r *route.RouterGroup) {
 	user.GET("/me", s.handler(s.handleGetCurrentUser))
 	user.PUT("/me", s.handler(s.handleUpdateProfile))
 	user.PUT("/password", s.handler(s.handleChangePassword))
-	user.DELETE("/me", s.handler(s.handleDeleteAccount))
+	user.DELETE("/me", s.m.Middleware().RequireStepUp(0), s.handler(s.handleDeleteAccount))
 	user.GET("/me/permissions", s.handler(s.handleGetMyPermissions))
 	user.POST("/me/bind-email", s.handler(s.handleBindEmail))
 	user.POST("/me/bind-phone", s.handler(s.handleBindPhone))
@@ -300,10 +329,19 @@ func (s *StandaloneService) registerUserRoutes(r *route.RouterGroup) {
 	user.DELETE("/me/oauth-accounts/:provider", s.handler(s.handleUnbindOAuthAccount))
 	user.GET("/me/orgs", s.handler(s.handleListMyOrgs))
 	user.GET("/me/api-tokens", s.handler(s.handleListAPITokens))
-	user.POST("/me/api-tokens", s.handler(s.handleCreateAPIToken))
+	user.POST("/me/api-tokens", s.m.Middleware().RequireStepUp(0), s.handler(s.handleCreateAPIToken))
 	user.DELETE("/me/api-tokens/:id", s.handler(s.handleRevokeAPIToken))
 	user.GET("/me/consents", s.handler(s.handleListConsents))
 	user.POST("/me/consents", s.handler(s.handleRecordConsent))
+	if s.m.DataExports().Enabled() {
+		user.GET("/me/exports", s.handler(s.handleListDataExports))
+		user.POST("/me/exports", s.m.Middleware().RequireStepUp(0), s.handler(s.handleRequestDataExport))
+		user.GET("/me/exports/:id", s.handler(s.handleGetDataExport))
+	}
+
+	invitations := r.Group("/invitations")
+	invitations.GET("/preview", s.handler(s.handlePreviewInvitation))
+	invitations.POST("/accept", s.m.Middleware().Authenticate(), s.handler(s.handleAcceptInvitation))
 }
 
 // registerMFARoutes 注册 /mfa/* 端点。
@@ -324,6 +362,7 @@ func (s *StandaloneService) registerSessionRoutes(r *route.RouterGroup) {
 	session.GET("", s.handler(s.handleListSessions))
 	session.DELETE("/:id", s.handler(s.handleRevokeSession))
 	session.POST("/revoke-others", s.handler(s.handleRevokeOtherSessions))
+	session.POST("/impersonation/end", s.handler(s.handleEndImpersonation))
 }
 
 // registerOrgRoutes 注册 /orgs/* 只读端点。
@@ -357,6 +396,13 @@ func (s *StandaloneService) registerOIDCEndpointRoutes(r *route.RouterGroup) {
 	r.DELETE("/oauth/authorized-apps/:client_id", s.m.Middleware().Authenticate(), s.handler(s.handleRevokeAuthorizedApp))
 	r.POST("/oauth/revoke", s.handler(s.handleOAuthRevoke))
 	r.POST("/oauth/introspect", s.handler(s.handleOAuthIntrospect))
+	r.POST("/oauth/token", s.handler(s.handleTokenEndpoint))
+	r.POST("/oauth/device/code", s.handler(s.handleDeviceAuthorization))
+	r.GET("/oauth/device/verify", s.m.Middleware().Authenticate(), s.handler(s.handleLookupDeviceCode))
+	r.POST("/oauth/device/verify", s.m.Middleware().Authenticate(), s.handler(s.handleConfirmDeviceCode))
+	if s.cfg.DeviceVerificationPage != nil {
+		r.GET("/oauth/device", s.cfg.DeviceVerificationPage)
+	}
 }
 
 // registerPasskeyRoutes 注册 /passkey/* 端点（WebAuthn）。
@@ -366,6 +412,8 @@ func (s *StandaloneService) registerPasskeyRoutes(r *route.RouterGroup) {
 	passkey.POST("/register/complete", s.m.Middleware().Authenticate(), s.handler(s.handlePasskeyRegisterComplete))
 	passkey.POST("/auth/start", s.handler(s.handlePasskeyAuthStart))
 	passkey.POST("/auth/complete", s.handler(s.handlePasskeyAuthComplete))
+	passkey.POST("/login/start", s.handler(s.handlePasskeyLoginStart))
+	passkey.POST("/login/complete", s.handler(s.handlePasskeyLoginComplete))
 	passkey.GET("/credentials", s.m.Middleware().Authenticate(), s.handler(s.handleListPasskeyCredentials))
 	passkey.DELETE("/credentials/:id", s.m.Middleware().Authenticate(), s.handler(s.handleDeletePasskeyCredential))
 }
@@ -407,7 +455,7 @@ func (s *StandaloneService) handleRegister(req server.Request) (any, error) {
 		return nil, err
 	}
 	return s.m.Auth().Register(req.TraceContext, RegisterRequest{
-		TenantID:                     body.TenantID,
+		TenantID:                     s.tenantID(req, body.TenantID),
 		Username:                     body.Username,
 		Password:                     body.Password,
 		Email:                        body.Email,
@@ -434,16 +482,58 @@ func (s *StandaloneService) handleLogin(req server.Request) (any, error) {
 		return nil, err
 	}
 	return s.m.Auth().Login(req.TraceContext, LoginRequest{
-		TenantID:       body.TenantID,
+		TenantID:       s.tenantID(req, body.TenantID),
 		Identifier:     body.Identifier,
 		IdentifierType: body.IdentifierType,
 		Password:       body.Password,
-		DeviceID:       string(req.C().GetHeader("X-Device-ID")),
+		DeviceID:       s.deviceID(req),
 		IP:             req.C().ClientIP(),
 		UserAgent:      string(req.C().UserAgent()),
+		ClientHints:    clientHints(req),
 	})
 }
 
+// deviceCookieName 浏览器没有 X-Device-ID 时用于识别设备的 Cookie。
+const deviceCookieName = "acct_device_id"
+
+// deviceID 取 X-Device-ID 头，没有时使用设备 Cookie，首次访问时生成并下发（两年有效）。
+func (s *StandaloneService) deviceID(req server.Request) string {
+	if id := string(req.C().GetHeader("X-Device-ID")); id != "" {
+		return id
+	}
+	if id := string(req.C().Cookie(deviceCookieName)); id != "" {
+		return id
+	}
+	id := newID()
+	secure := string(req.C().URI().Scheme()) == "https" || string(req.C().GetHeader("X-Forwarded-Proto")) == "https"
+	req.C().SetCookie(deviceCookieName, id, 2*365*24*3600, "/", "", protocol.CookieSameSiteLaxMode, secure, true)
+	return id
+}
+
+// tenantID 确定公开接口的租户：请求 Host 绑定了租户自定义域名时以域名为准（忽略请求体中的 tenant_id），
+// 否则使用请求体中的值。
+func (s *StandaloneService) tenantID(req server.Request, tenantID string) string {
+	resolved, err := s.m.Tenants().ResolveHost(req.TraceContext, string(req.C().Host()))
+	if err != nil {
+		gaia.WarnF("[account] resolve tenant from host failed: %v", err)
+	}
+	if resolved != "" {
+		return resolved
+	}
+	return tenantID
+}
+
+// clientHints 收集用于设备指纹的客户端提示头。
+func clientHints(req server.Request) map[string]string {
+	hints := map[string]string{}
+	for _, name := range []string{"Sec-CH-UA", "Sec-CH-UA-Platform", "Sec-CH-UA-Mobile", "Sec-CH-UA-Model", "Accept-Language"} {
+		if v := string(req.C().GetHeader(name)); v != "" {
+			hints[name] = v
+		}
+	}
+	return hints
+}
+
 func (s *StandaloneService) handleRefresh(req server.Request) (any, error) {
 	var body struct {
 		RefreshToken string `json:"refresh_token"`
@@ -548,17 +638,21 @@ func (s *StandaloneService) handleRevokeSession(req server.Request) (any, error)
 
 func (s *StandaloneService) handleRegisterClient(req server.Request) (any, error) {
 	var body struct {
-		Name         string   `json:"name"`
-		RedirectURIs []string `json:"redirect_uris"`
-		Scopes       []string `json:"scopes"`
+		Name              string   `json:"name"`
+		RedirectURIs      []string `json:"redirect_uris"`
+		AllowedGrantTypes []string `json:"allowed_grant_types"`
+		Scopes            []string `json:"scopes"`
+		Public            bool     `json:"public"`
 	}
 	if err := req.BindJson(&body); err != nil {
 		return nil, err
 	}
 	return s.m.IdP().RegisterClient(req.TraceContext, IdpClientRequest{
-		Name:         body.Name,
-		RedirectURIs: body.RedirectURIs,
-		Scopes:       body.Scopes,
+		Name:              body.Name,
+		RedirectURIs:      body.RedirectURIs,
+		AllowedGrantTypes: body.AllowedGrantTypes,
+		Scopes:            body.Scopes,
+		Public:            body.Public,
 	})
 }
 
@@ -584,25 +678,85 @@ func (s *StandaloneService) handleTokenEndpoint(req server.Request) (any, error)
 		CodeVerifier string `json:"code_verifier"`
 		RefreshToken string `json:"refresh_token"`
 		Scope        string `json:"scope"`
+		DeviceCode   string `json:"device_code"`
+		// 令牌交换参数；audience 多个值以空格分隔。
+		SubjectToken       string `json:"subject_token"`
+		SubjectTokenType   string `json:"subject_token_type"`
+		ActorToken         string `json:"actor_token"`
+		ActorTokenType     string `json:"actor_token_type"`
+		RequestedTokenType string `json:"requested_token_type"`
+		Audience           string `json:"audience"`
 	}
 	if err := req.BindJson(&body); err != nil {
 		return nil, err
 	}
 	return s.m.IdP().Token(req.TraceContext, TokenRequest{
-		GrantType:    body.GrantType,
-		Code:         body.Code,
-		RedirectURI:  body.RedirectURI,
-		ClientID:     body.ClientID,
-		ClientSecret: body.ClientSecret,
-		CodeVerifier: body.CodeVerifier,
-		RefreshToken: body.RefreshToken,
-		Scope:        body.Scope,
+		GrantType:          body.GrantType,
+		Code:               body.Code,
+		RedirectURI:        body.RedirectURI,
+		ClientID:           body.ClientID,
+		ClientSecret:       body.ClientSecret,
+		CodeVerifier:       body.CodeVerifier,
+		RefreshToken:       body.RefreshToken,
+		Scope:              body.Scope,
+		DeviceCode:         body.DeviceCode,
+		SubjectToken:       body.SubjectToken,
+		SubjectTokenType:   body.SubjectTokenType,
+		ActorToken:         body.ActorToken,
+		ActorTokenType:     body.ActorTokenType,
+		RequestedTokenType: body.RequestedTokenType,
+		Audience:           strings.Fields(body.Audience),
+		IP:                 req.C().ClientIP(),
+		UserAgent:          string(req.C().UserAgent()),
 	})
 }
 
+// issuer 返回本服务对外的 OIDC issuer 地址（含 /api/v1/account 前缀）。
+func (s *StandaloneService) issuer(req server.Request) string {
+	return fmt.Sprintf("%s://%s/api/v1/account", req.C().URI().Scheme(), string(req.C().Host()))
+}
+
 func (s *StandaloneService) handleOpenIDConfig(req server.Request) (any, error) {
-	issuer := fmt.Sprintf("%s://%s", req.C().URI().Scheme(), string(req.C().Host()))
-	return s.m.IdP().OpenIDConfig(req.TraceContext, issuer+"/api/v1/account"), nil
+	return s.m.IdP().OpenIDConfig(req.TraceContext, s.issuer(req)), nil
+}
+
+func (s *StandaloneService) handleDeviceAuthorization(req server.Request) (any, error) {
+	var body struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		Scope        string `json:"scope"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	var verificationURI string
+	if s.cfg.DeviceVerificationPage != nil {
+		verificationURI = s.issuer(req) + "/oauth/device"
+	}
+	return s.m.IdP().StartDeviceAuthorization(req.TraceContext, DeviceAuthorizationRequest{
+		ClientID:        body.ClientID,
+		ClientSecret:    body.ClientSecret,
+		Scope:           body.Scope,
+		VerificationURI: verificationURI,
+	})
+}
+
+func (s *StandaloneService) handleLookupDeviceCode(req server.Request) (any, error) {
+	return s.m.IdP().LookupDeviceCode(req.TraceContext, contextPrincipal(req), req.GetUrlQuery("user_code"))
+}
+
+func (s *StandaloneService) handleConfirmDeviceCode(req server.Request) (any, error) {
+	var body struct {
+		UserCode string `json:"user_code"`
+		Approve  bool   `json:"approve"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	if err := s.m.IdP().ConfirmDeviceCode(req.TraceContext, contextPrincipal(req), body.UserCode, body.Approve); err != nil {
+		return nil, err
+	}
+	return map[string]bool{"approved": body.Approve}, nil
 }
 
 func (s *StandaloneService) handleJWKS(req server.Request) (any, error) {
@@ -644,7 +798,37 @@ func (s *StandaloneService) handlePasskeyAuthComplete(req server.Request) (any,
 	if err := req.BindJson(&body); err != nil {
 		return nil, err
 	}
-	return s.m.Passkey().CompletePasskeyAuthentication(req.TraceContext, body.UserHandle, body)
+	// userHandle 即注册时下发的 user_handle（base64url 编码的用户 ID）
+	userID, err := decodeWebAuthnBase64(body.UserHandle)
+	if err != nil || len(userID) == 0 {
+		return nil, accountError(ErrInvalidArgument, "user_handle 无效")
+	}
+	return s.m.Passkey().CompletePasskeyAuthentication(req.TraceContext, string(userID), body)
+}
+
+func (s *StandaloneService) handlePasskeyLoginStart(req server.Request) (any, error) {
+	var body struct {
+		TenantID string `json:"tenant_id"`
+	}
+	req.BindJson(&body)
+	return s.m.Passkey().StartPasskeyLogin(req.TraceContext, s.tenantID(req, body.TenantID))
+}
+
+func (s *StandaloneService) handlePasskeyLoginComplete(req server.Request) (any, error) {
+	var body struct {
+		TenantID string `json:"tenant_id"`
+		PasskeyAuthenticationResponse
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.Passkey().LoginWithPasskey(req.TraceContext, PasskeyLoginRequest{
+		TenantID:   s.tenantID(req, body.TenantID),
+		Credential: body.PasskeyAuthenticationResponse,
+		DeviceID:   string(req.C().GetHeader("X-Device-ID")),
+		IP:         req.C().ClientIP(),
+		UserAgent:  string(req.C().UserAgent()),
+	})
 }
 
 func (s *StandaloneService) handleListPasskeyCredentials(req server.Request) (any, error) {
@@ -675,7 +859,7 @@ func (s *StandaloneService) handleQueryAudit(req server.Request) (any, error) {
 	}
 	req.BindJson(&body)
 	return s.m.Audit().Query(req.TraceContext, AuditQueryRequest{
-		TenantID: body.TenantID,
+		TenantID: s.tenantID(req, body.TenantID),
 		UserID:   body.UserID,
 		Event:    body.Event,
 		Status:   body.Status,
@@ -697,7 +881,7 @@ func (s *StandaloneService) handleQueryArchivedAudit(req server.Request) (any, e
 	}
 	req.BindJson(&body)
 	return s.m.Audit().QueryArchived(req.TraceContext, AuditQueryRequest{
-		TenantID: body.TenantID,
+		TenantID: s.tenantID(req, body.TenantID),
 		UserID:   body.UserID,
 		Event:    body.Event,
 		Status:   body.Status,
@@ -731,16 +915,21 @@ func (s *StandaloneService) handleSendVerificationCode(req server.Request) (any,
 		Channel  string `json:"channel"`
 		Target   string `json:"target"`
 		Purpose  string `json:"purpose"`
+		Locale   string `json:"locale"`
 	}
 	if err := req.BindJson(&body); err != nil {
 		return nil, err
 	}
+	if body.Locale == "" {
+		body.Locale = parseAcceptLanguage(string(req.C().GetHeader("Accept-Language")))
+	}
 	return s.m.Verification().Send(req.TraceContext, SendVerificationRequest{
-		TenantID: body.TenantID,
+		TenantID: s.tenantID(req, body.TenantID),
 		Channel:  body.Channel,
 		Target:   body.Target,
 		Purpose:  body.Purpose,
 		IP:       req.C().ClientIP(),
+		Locale:   body.Locale,
 	})
 }
 
@@ -757,7 +946,7 @@ func (s *StandaloneService) handleVerifyCode(req server.Request) (any, error) {
 		return nil, err
 	}
 	return nil, s.m.Verification().Verify(req.TraceContext, VerifyCodeRequest{
-		TenantID:    body.TenantID,
+		TenantID:    s.tenantID(req, body.TenantID),
 		ChallengeID: body.ChallengeID,
 		Code:        body.Code,
 		Purpose:     body.Purpose,
@@ -780,7 +969,7 @@ func (s *StandaloneService) handleLoginWithCode(req server.Request) (any, error)
 		return nil, err
 	}
 	return s.m.Auth().LoginWithVerificationCode(req.TraceContext, LoginWithVerificationCodeRequest{
-		TenantID:       body.TenantID,
+		TenantID:       s.tenantID(req, body.TenantID),
 		Identifier:     body.Identifier,
 		IdentifierType: body.IdentifierType,
 		ChallengeID:    body.ChallengeID,
@@ -804,7 +993,7 @@ func (s *StandaloneService) handleOAuthLogin(req server.Request) (any, error) {
 		return nil, err
 	}
 	return s.m.OAuth().Login(req.TraceContext, OAuthLoginRequest{
-		TenantID:     body.TenantID,
+		TenantID:     s.tenantID(req, body.TenantID),
 		Provider:     req.GetUrlParam("provider"),
 		Code:         body.Code,
 		RedirectURI:  body.RedirectURI,
@@ -817,6 +1006,79 @@ func (s *StandaloneService) handleOAuthLogin(req server.Request) (any, error) {
 	})
 }
 
+func (s *StandaloneService) handleOAuthStart(req server.Request) (any, error) {
+	var body struct {
+		TenantID    string `json:"tenant_id"`
+		RedirectURI string `json:"redirect_uri"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.OAuth().Authorize(req.TraceContext, OAuthAuthorizeRequest{
+		TenantID:    s.tenantID(req, body.TenantID),
+		Provider:    req.GetUrlParam("provider"),
+		RedirectURI: body.RedirectURI,
+	})
+}
+
+func (s *StandaloneService) handleLDAPLogin(req server.Request) (any, error) {
+	var body struct {
+		TenantID string `json:"tenant_id"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.LDAP().Login(req.TraceContext, LDAPLoginRequest{
+		TenantID:  s.tenantID(req, body.TenantID),
+		Username:  body.Username,
+		Password:  body.Password,
+		DeviceID:  string(req.C().GetHeader("X-Device-ID")),
+		IP:        req.C().ClientIP(),
+		UserAgent: string(req.C().UserAgent()),
+	})
+}
+
+func (s *StandaloneService) handleSAMLLogin(req server.Request) (any, error) {
+	var body struct {
+		TenantID string `json:"tenant_id"`
+		Ticket   string `json:"ticket"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.SAML().Login(req.TraceContext, SAMLLoginRequest{
+		TenantID:  s.tenantID(req, body.TenantID),
+		Ticket:    body.Ticket,
+		DeviceID:  string(req.C().GetHeader("X-Device-ID")),
+		IP:        req.C().ClientIP(),
+		UserAgent: string(req.C().UserAgent()),
+	})
+}
+
+func (s *StandaloneService) handleSAMLLogout(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body struct {
+		ReturnTo string `json:"return_to"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.SAML().Logout(req.TraceContext, SAMLLogoutRequest{
+		TenantID:    p.TenantID,
+		UserID:      p.UserID,
+		SessionID:   p.SessionID,
+		AccessToken: bearerToken(string(req.C().GetHeader("Authorization"))),
+		ReturnTo:    body.ReturnTo,
+		IP:          req.C().ClientIP(),
+		UserAgent:   string(req.C().UserAgent()),
+	})
+}
+
 func (s *StandaloneService) handleBindPhoneAndLogin(req server.Request) (any, error) {
 	var body struct {
 		TenantID       string `json:"tenant_id"`
@@ -831,7 +1093,7 @@ func (s *StandaloneService) handleBindPhoneAndLogin(req server.Request) (any, er
 		return nil, err
 	}
 	return s.m.Auth().BindPhoneAndLogin(req.TraceContext, BindPhoneRequest{
-		TenantID:       body.TenantID,
+		TenantID:       s.tenantID(req, body.TenantID),
 		Identifier:     body.Identifier,
 		IdentifierType: body.IdentifierType,
 		Password:       body.Password,
@@ -853,9 +1115,10 @@ func (s *StandaloneService) handleCompleteMFA(req server.Request) (any, error) {
 		return nil, err
 	}
 	return s.m.Auth().CompleteMFA(req.TraceContext, body.ChallengeID, body.Code, CompleteMFARequest{
-		DeviceID:  string(req.C().GetHeader("X-Device-ID")),
-		IP:        req.C().ClientIP(),
-		UserAgent: string(req.C().UserAgent()),
+		DeviceID:    s.deviceID(req),
+		IP:          req.C().ClientIP(),
+		UserAgent:   string(req.C().UserAgent()),
+		ClientHints: clientHints(req),
 	})
 }
 
@@ -886,7 +1149,7 @@ func (s *StandaloneService) handleForgotPasswordStart(req server.Request) (any,
 		return nil, err
 	}
 	return s.m.Users().StartResetPassword(req.TraceContext, StartResetPasswordRequest{
-		TenantID:   body.TenantID,
+		TenantID:   s.tenantID(req, body.TenantID),
 		Username:   body.Username,
 		Identifier: body.Identifier,
 		Channel:    body.Channel,
@@ -904,7 +1167,7 @@ func (s *StandaloneService) handleForgotPasswordComplete(req server.Request) (an
 		return nil, err
 	}
 	return nil, s.m.Users().CompleteResetPassword(req.TraceContext, CompleteResetPasswordRequest{
-		TenantID:    body.TenantID,
+		TenantID:    s.tenantID(req, body.TenantID),
 		ChallengeID: body.ChallengeID,
 		Code:        body.Code,
 		NewPassword: body.NewPassword,
@@ -1062,11 +1325,14 @@ func (s *StandaloneService) handleStepUpStart(req server.Request) (any, error) {
 	if p == nil {
 		return nil, accountError(ErrInvalidToken, "未认证")
 	}
-	challengeID, err := s.m.Auth().RequestStepUp(req.TraceContext, p)
-	if err != nil {
-		return nil, err
+	var body struct {
+		Method string `json:"method"` // otp（默认）、sms、email、hwk
+	}
+	req.BindJson(&body)
+	if body.Method == "" {
+		body.Method = AMROTP
 	}
-	return map[string]string{"challenge_id": challengeID}, nil
+	return s.m.Auth().StartStepUp(req.TraceContext, p, body.Method, req.C().ClientIP())
 }
 
 func (s *StandaloneService) handleStepUpComplete(req server.Request) (any, error) {
@@ -1075,12 +1341,16 @@ func (s *StandaloneService) handleStepUpComplete(req server.Request) (any, error
 		return nil, accountError(ErrInvalidToken, "未认证")
 	}
 	var body struct {
-		ChallengeID string `json:"challenge_id"`
-		Code        string `json:"code"`
+		ChallengeID string                         `json:"challenge_id"`
+		Code        string                         `json:"code"`
+		Passkey     *PasskeyAuthenticationResponse `json:"passkey"` // method=hwk 时提交的通行密钥断言
 	}
 	if err := req.BindJson(&body); err != nil {
 		return nil, err
 	}
+	if body.Passkey != nil {
+		return nil, s.m.Auth().CompletePasskeyStepUp(req.TraceContext, p, *body.Passkey)
+	}
 	return nil, s.m.Auth().CompleteStepUp(req.TraceContext, p, body.ChallengeID, body.Code)
 }
 
@@ -1091,6 +1361,9 @@ func (s *StandaloneService) handleRevokeOtherSessions(req server.Request) (any,
 	if p == nil {
 		return nil, accountError(ErrInvalidToken, "未认证")
 	}
+	if p.Impersonated {
+		return nil, accountError(ErrPermissionDenied, "代操作会话不能执行敏感操作")
+	}
 	n, err := s.m.Sessions().RevokeOther(req.TraceContext, p.TenantID, p.UserID, p.SessionID)
 	if err != nil {
 		return nil, err
@@ -1098,6 +1371,18 @@ func (s *StandaloneService) handleRevokeOtherSessions(req server.Request) (any,
 	return map[string]int64{"revoked": n}, nil
 }
 
+// handleEndImpersonation 由代操作会话自身结束代操作（前端横幅上的“退出代操作”）。
+func (s *StandaloneService) handleEndImpersonation(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	if !p.Impersonated {
+		return nil, accountError(ErrInvalidArgument, "当前会话不是代操作会话")
+	}
+	return nil, s.m.Admin().EndImpersonation(req.TraceContext, p, p.SessionID)
+}
+
 // ===== 新增：组织（只读） =====
 
 func (s *StandaloneService) handleListOrgs(req server.Request) (any, error) {
@@ -1178,6 +1463,48 @@ func (s *StandaloneService) handleRevokeAPIToken(req server.Request) (any, error
 
 // ===== SDK 扩展：隐私协议签署 =====
 
+func (s *StandaloneService) handleRequestDataExport(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return s.m.DataExports().Request(req.TraceContext, p)
+}
+
+func (s *StandaloneService) handleListDataExports(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return s.m.DataExports().List(req.TraceContext, p.TenantID, p.UserID)
+}
+
+func (s *StandaloneService) handleGetDataExport(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return s.m.DataExports().Get(req.TraceContext, p.TenantID, p.UserID, req.GetUrlParam("id"))
+}
+
+func (s *StandaloneService) handlePreviewInvitation(req server.Request) (any, error) {
+	return s.m.Users().PreviewInvitation(req.TraceContext, req.GetUrlQuery("token"))
+}
+
+func (s *StandaloneService) handleAcceptInvitation(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.Users().AcceptInvitation(req.TraceContext, p, body.Token)
+}
+
 func (s *StandaloneService) handleListConsents(req server.Request) (any, error) {
 	p := contextPrincipal(req)
 	if p == nil {
@@ -1309,14 +1636,19 @@ func (s *StandaloneService) handleOAuthIntrospect(req server.Request) (any, erro
 	if err != nil || p == nil {
 		return map[string]any{"active": false}, nil
 	}
-	return map[string]any{
+	resp := map[string]any{
 		"active":     true,
 		"sub":        p.UserID,
 		"username":   p.Username,
 		"tenant_id":  p.TenantID,
 		"session_id": p.SessionID,
 		"roles":      p.Roles,
-	}, nil
+	}
+	if p.ClientID != "" {
+		resp["client_id"] = p.ClientID
+		resp["delegation"] = p.Delegation
+	}
+	return resp, nil
 }
 
 // contextPrincipal 从请求上下文中提取经过认证的身份主体。
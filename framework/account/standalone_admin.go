@@ -1,6 +1,7 @@
 package account
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/route"
@@ -11,13 +12,19 @@ import (
 // 所有路由要求登录，并按操作粒度做权限校验。
 //
 // 权限码约定（默认未在 seedDefaults 中创建，需要租户管理员手动配置或通过迁移脚本灌入）：
-//   admin.user.{list,read,create,update,delete}
+//   admin.user.{list,read,create,update,delete,impersonate,invite}
 //   admin.role.{list,read,create,update,delete,assign}
 //   admin.permission.{list,create,update,delete,assign}
 //   admin.session.{read,revoke}
+//   admin.mfa.{read,write}
 //   admin.audit.{read,restore,archive}
 //   admin.policy.{list,create,update,delete}
+//   admin.authz.explain
 //   admin.org.{create,update,delete,assign}
+//   admin.ldap.sync
+//   admin.saml.{read,write}
+//   admin.rebac.{read,write}
+//   admin.tenant.{read,write}（仅默认租户的管理员可用）
 func (s *StandaloneService) registerAdminRoutes(r *route.RouterGroup) {
 	admin := r.Group("/admin")
 	admin.Use(s.m.Middleware().Authenticate())
@@ -31,6 +38,13 @@ func (s *StandaloneService) registerAdminRoutes(r *route.RouterGroup) {
 	admin.POST("/users/:id/reset-mfa", mw.RequirePermission("admin.user.update"), s.handler(s.handleAdminResetUserMFA))
 	admin.GET("/users/:id/sessions", mw.RequirePermission("admin.user.read"), s.handler(s.handleAdminListUserSessions))
 	admin.GET("/users/:id/permissions", mw.RequirePermission("admin.user.read"), s.handler(s.handleAdminGetUserPermissions))
+	admin.POST("/users/:id/impersonate", mw.RequirePermission(PermissionImpersonate), s.handler(s.handleAdminImpersonate))
+	admin.GET("/impersonations", mw.RequirePermission(PermissionImpersonate), s.handler(s.handleAdminListImpersonations))
+	admin.DELETE("/impersonations/:id", mw.RequirePermission(PermissionImpersonate), s.handler(s.handleAdminEndImpersonation))
+	admin.POST("/users/import", mw.RequirePermission("admin.user.create"), s.handler(s.handleAdminImportUsers))
+	admin.GET("/invitations", mw.RequirePermission("admin.user.invite"), s.handler(s.handleAdminListInvitations))
+	admin.POST("/invitations", mw.RequirePermission("admin.user.invite"), s.handler(s.handleAdminCreateInvitation))
+	admin.DELETE("/invitations/:id", mw.RequirePermission("admin.user.invite"), s.handler(s.handleAdminRevokeInvitation))
 	admin.POST("/users/:id/roles/:role_id", mw.RequirePermission("admin.role.assign"), s.handler(s.handleAdminAssignUserRole))
 	admin.DELETE("/users/:id/roles/:role_id", mw.RequirePermission("admin.role.assign"), s.handler(s.handleAdminRemoveUserRole))
 
@@ -52,6 +66,25 @@ func (s *StandaloneService) registerAdminRoutes(r *route.RouterGroup) {
 	// ===== 会话管理 =====
 	admin.DELETE("/sessions/:id", mw.RequirePermission("admin.session.revoke"), s.handler(s.handleAdminRevokeSession))
 
+	// ===== 验证码投递 =====
+	admin.GET("/verifications/:id/deliveries", mw.RequirePermission("admin.user.read"), s.handler(s.handleAdminGetVerificationDelivery))
+
+	// ===== MFA 强制策略 =====
+	admin.GET("/mfa/policy", mw.RequirePermission("admin.mfa.read"), s.handler(s.handleAdminGetMFAPolicy))
+	admin.PUT("/mfa/policy", mw.RequirePermission("admin.mfa.write"), mw.RequireStepUp(0), s.handler(s.handleAdminSetMFAPolicy))
+	admin.DELETE("/mfa/policy", mw.RequirePermission("admin.mfa.write"), mw.RequireStepUp(0), s.handler(s.handleAdminDeleteMFAPolicy))
+
+	// ===== 租户管理（平台级） =====
+	admin.GET("/tenants", mw.RequirePermission("admin.tenant.read"), s.handler(s.handleAdminListTenants))
+	admin.POST("/tenants", mw.RequirePermission("admin.tenant.write"), s.handler(s.handleAdminCreateTenant))
+	admin.GET("/tenants/:id", mw.RequirePermission("admin.tenant.read"), s.handler(s.handleAdminGetTenant))
+	admin.PUT("/tenants/:id", mw.RequirePermission("admin.tenant.write"), s.handler(s.handleAdminUpdateTenant))
+	admin.POST("/tenants/:id/suspend", mw.RequirePermission("admin.tenant.write"), s.handler(s.handleAdminSuspendTenant))
+	admin.POST("/tenants/:id/resume", mw.RequirePermission("admin.tenant.write"), s.handler(s.handleAdminResumeTenant))
+	admin.DELETE("/tenants/:id", mw.RequirePermission("admin.tenant.write"), mw.RequireStepUp(0), s.handler(s.handleAdminDeleteTenant))
+	admin.POST("/tenants/:id/domains", mw.RequirePermission("admin.tenant.write"), s.handler(s.handleAdminAddTenantDomain))
+	admin.DELETE("/tenants/:id/domains/:domain", mw.RequirePermission("admin.tenant.write"), s.handler(s.handleAdminRemoveTenantDomain))
+
 	// ===== 审计 =====
 	admin.GET("/audit/events", mw.RequirePermission("admin.audit.read"), s.handler(s.handleAdminListAuditEvents))
 	admin.GET("/audit/:id", mw.RequirePermission("admin.audit.read"), s.handler(s.handleAdminGetAuditLog))
@@ -62,6 +95,8 @@ func (s *StandaloneService) registerAdminRoutes(r *route.RouterGroup) {
 	admin.POST("/policies", mw.RequirePermission("admin.policy.create"), s.handler(s.handleAdminCreatePolicy))
 	admin.PUT("/policies/:id", mw.RequirePermission("admin.policy.update"), s.handler(s.handleAdminUpdatePolicy))
 	admin.DELETE("/policies/:id", mw.RequirePermission("admin.policy.delete"), s.handler(s.handleAdminDeletePolicy))
+	admin.POST("/policies/validate", mw.RequirePermission("admin.policy.create"), s.handler(s.handleAdminValidatePolicy))
+	admin.POST("/authz/explain", mw.RequirePermission("admin.authz.explain"), s.handler(s.handleAdminExplainAuthz))
 
 	// ===== 组织 =====
 	admin.POST("/orgs", mw.RequirePermission("admin.org.create"), s.handler(s.handleAdminCreateOrg))
@@ -69,6 +104,28 @@ func (s *StandaloneService) registerAdminRoutes(r *route.RouterGroup) {
 	admin.DELETE("/orgs/:id", mw.RequirePermission("admin.org.delete"), s.handler(s.handleAdminDeleteOrg))
 	admin.POST("/orgs/:id/members/:user_id/roles/:role_id", mw.RequirePermission("admin.org.assign"), s.handler(s.handleAdminAssignOrgRole))
 	admin.DELETE("/orgs/:id/members/:user_id/roles/:role_id", mw.RequirePermission("admin.org.assign"), s.handler(s.handleAdminRemoveOrgRole))
+
+	// ===== 目录同步 =====
+	if s.m.LDAP().Enabled() {
+		admin.POST("/ldap/sync", mw.RequirePermission("admin.ldap.sync"), s.handler(s.handleAdminLDAPSync))
+	}
+
+	// ===== SAML 单点登录 =====
+	if s.m.SAML().Enabled() {
+		admin.GET("/saml/connection", mw.RequirePermission("admin.saml.read"), s.handler(s.handleAdminGetSAMLConnection))
+		admin.PUT("/saml/connection", mw.RequirePermission("admin.saml.write"), s.handler(s.handleAdminSaveSAMLConnection))
+		admin.DELETE("/saml/connection", mw.RequirePermission("admin.saml.write"), s.handler(s.handleAdminDeleteSAMLConnection))
+	}
+
+	// ===== 关系元组（ReBAC）=====
+	if s.m.ReBAC().Enabled() {
+		admin.GET("/rebac/tuples", mw.RequirePermission("admin.rebac.read"), s.handler(s.handleAdminReadTuples))
+		admin.POST("/rebac/tuples", mw.RequirePermission("admin.rebac.write"), s.handler(s.handleAdminWriteTuples))
+		admin.POST("/rebac/tuples/delete", mw.RequirePermission("admin.rebac.write"), s.handler(s.handleAdminDeleteTuples))
+		admin.POST("/rebac/check", mw.RequirePermission("admin.rebac.read"), s.handler(s.handleAdminReBACCheck))
+		admin.POST("/rebac/expand", mw.RequirePermission("admin.rebac.read"), s.handler(s.handleAdminReBACExpand))
+		admin.POST("/rebac/list-objects", mw.RequirePermission("admin.rebac.read"), s.handler(s.handleAdminReBACListObjects))
+	}
 }
 
 // ============================================================
@@ -182,6 +239,14 @@ func (s *StandaloneService) handleAdminListUserSessions(req server.Request) (any
 	return s.m.Admin().ListUserSessions(req.TraceContext, tenantID, req.GetUrlParam("id"))
 }
 
+func (s *StandaloneService) handleAdminGetVerificationDelivery(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return s.m.Verification().DeliveryStatus(req.TraceContext, p.TenantID, req.GetUrlParam("id"))
+}
+
 func (s *StandaloneService) handleAdminGetUserPermissions(req server.Request) (any, error) {
 	perms, err := s.m.Authorizer().GetEffectivePermissions(req.TraceContext, req.GetUrlParam("id"))
 	if err != nil {
@@ -386,6 +451,248 @@ func (s *StandaloneService) handleAdminRevokeSession(req server.Request) (any, e
 	return nil, s.m.Admin().RevokeSession(req.TraceContext, tenantID, req.GetUrlParam("id"))
 }
 
+// ============================================================
+// MFA 强制策略
+// ============================================================
+
+func (s *StandaloneService) handleAdminGetMFAPolicy(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return s.m.MFA().GetPolicy(req.TraceContext, p.TenantID)
+}
+
+func (s *StandaloneService) handleAdminSetMFAPolicy(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body MFAPolicyRequest
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.MFA().SetPolicy(req.TraceContext, p.TenantID, body)
+}
+
+func (s *StandaloneService) handleAdminDeleteMFAPolicy(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return nil, s.m.MFA().DeletePolicy(req.TraceContext, p.TenantID)
+}
+
+// ============================================================
+// 租户管理
+// ============================================================
+
+// platformPrincipal 租户管理跨租户生效，只允许默认（平台）租户的管理员调用。
+func (s *StandaloneService) platformPrincipal(req server.Request) (*Principal, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	if p.TenantID != s.m.cfg.DefaultTenantID {
+		return nil, accountError(ErrPermissionDenied, "仅平台租户的管理员可以管理租户")
+	}
+	return p, nil
+}
+
+func (s *StandaloneService) handleAdminListTenants(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	var body ListTenantsRequest
+	_ = req.BindJson(&body)
+	return s.m.Tenants().List(req.TraceContext, body)
+}
+
+func (s *StandaloneService) handleAdminCreateTenant(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	var body CreateTenantRequest
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.Tenants().Create(req.TraceContext, body)
+}
+
+func (s *StandaloneService) handleAdminGetTenant(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	return s.m.Tenants().Get(req.TraceContext, req.GetUrlParam("id"))
+}
+
+func (s *StandaloneService) handleAdminUpdateTenant(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	var body UpdateTenantRequest
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.Tenants().Update(req.TraceContext, req.GetUrlParam("id"), body)
+}
+
+func (s *StandaloneService) handleAdminSuspendTenant(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	_ = req.BindJson(&body)
+	return nil, s.m.Tenants().Suspend(req.TraceContext, req.GetUrlParam("id"), body.Reason)
+}
+
+func (s *StandaloneService) handleAdminResumeTenant(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	return nil, s.m.Tenants().Resume(req.TraceContext, req.GetUrlParam("id"))
+}
+
+func (s *StandaloneService) handleAdminDeleteTenant(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	return nil, s.m.Tenants().Delete(req.TraceContext, req.GetUrlParam("id"))
+}
+
+func (s *StandaloneService) handleAdminAddTenantDomain(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	var body struct {
+		Domain string `json:"domain"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return nil, s.m.Tenants().AddDomain(req.TraceContext, req.GetUrlParam("id"), body.Domain)
+}
+
+func (s *StandaloneService) handleAdminRemoveTenantDomain(req server.Request) (any, error) {
+	if _, err := s.platformPrincipal(req); err != nil {
+		return nil, err
+	}
+	return nil, s.m.Tenants().RemoveDomain(req.TraceContext, req.GetUrlParam("id"), req.GetUrlParam("domain"))
+}
+
+// ============================================================
+// 代操作
+// ============================================================
+
+func (s *StandaloneService) handleAdminImpersonate(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body struct {
+		Reason     string   `json:"reason"`
+		Scopes     []string `json:"scopes"`
+		TTLSeconds int      `json:"ttl_seconds"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.Admin().Impersonate(req.TraceContext, p, ImpersonateRequest{
+		TargetUserID: req.GetUrlParam("id"),
+		Reason:       body.Reason,
+		Scopes:       body.Scopes,
+		TTL:          time.Duration(body.TTLSeconds) * time.Second,
+		IP:           req.C().ClientIP(),
+		UserAgent:    string(req.C().UserAgent()),
+	})
+}
+
+func (s *StandaloneService) handleAdminListImpersonations(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	tenantID := req.GetUrlQuery("tenant_id")
+	if tenantID == "" {
+		tenantID = p.TenantID
+	}
+	return s.m.Admin().ListImpersonations(req.TraceContext, tenantID)
+}
+
+func (s *StandaloneService) handleAdminEndImpersonation(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return nil, s.m.Admin().EndImpersonation(req.TraceContext, p, req.GetUrlParam("id"))
+}
+
+// ============================================================
+// 邀请与批量导入
+// ============================================================
+
+func (s *StandaloneService) handleAdminCreateInvitation(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body struct {
+		Email      string          `json:"email"`
+		Roles      []string        `json:"roles"`
+		Orgs       []InvitationOrg `json:"orgs"`
+		TTLSeconds int             `json:"ttl_seconds"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.Admin().CreateInvitation(req.TraceContext, p, CreateInvitationRequest{
+		Email: body.Email,
+		Roles: body.Roles,
+		Orgs:  body.Orgs,
+		TTL:   time.Duration(body.TTLSeconds) * time.Second,
+	})
+}
+
+func (s *StandaloneService) handleAdminListInvitations(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return s.m.Admin().ListInvitations(req.TraceContext, p.TenantID, req.GetUrlQuery("status"))
+}
+
+func (s *StandaloneService) handleAdminRevokeInvitation(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	return nil, s.m.Admin().RevokeInvitation(req.TraceContext, p.TenantID, req.GetUrlParam("id"))
+}
+
+// handleAdminImportUsers 请求体 {"format":"csv","content":"username,email\n...","dry_run":true}；
+// format=json 时 content 为用户数组的 JSON 文本。
+func (s *StandaloneService) handleAdminImportUsers(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body struct {
+		Format  string `json:"format"`
+		Content string `json:"content"`
+		DryRun  bool   `json:"dry_run"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	return s.m.Admin().ImportUsers(req.TraceContext, p, ImportUsersRequest{
+		Format: body.Format,
+		Data:   []byte(body.Content),
+		DryRun: body.DryRun,
+	})
+}
+
 // ============================================================
 // 审计
 // ============================================================
@@ -461,6 +768,59 @@ func (s *StandaloneService) handleAdminDeletePolicy(req server.Request) (any, er
 	return nil, s.m.Policies().DeletePolicy(req.TraceContext, req.GetUrlParam("id"))
 }
 
+func (s *StandaloneService) handleAdminValidatePolicy(req server.Request) (any, error) {
+	var body struct {
+		Expression string `json:"expression"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	if err := s.m.Policies().ValidateExpression(body.Expression); err != nil {
+		return map[string]any{"valid": false, "error": err.Error()}, nil
+	}
+	return map[string]any{"valid": true}, nil
+}
+
+// handleAdminExplainAuthz 解释指定用户对某权限的授权决定，供权限排查界面使用。
+func (s *StandaloneService) handleAdminExplainAuthz(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body struct {
+		UserID        string         `json:"user_id"`
+		Permission    string         `json:"permission"`
+		ResourceType  string         `json:"resource_type"`
+		ResourceID    string         `json:"resource_id"`
+		OwnerID       string         `json:"owner_id"`
+		OrgID         string         `json:"org_id"`
+		ResourceAttrs map[string]any `json:"resource_attrs"`
+		Context       *AuthzContext  `json:"context"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	if body.UserID == "" || body.Permission == "" {
+		return nil, accountError(ErrInvalidArgument, "user_id 与 permission 不能为空")
+	}
+	var target User
+	if err := s.m.db.WithContext(req.TraceContext).Select("id", "tenant_id").Where("id = ?", body.UserID).First(&target).Error; err != nil {
+		return nil, accountError(ErrInvalidArgument, "用户不存在")
+	}
+	if target.TenantID != p.TenantID && !contains(p.Roles, "platform_admin") {
+		return nil, accountError(ErrPermissionDenied, "不能查看其他租户的授权")
+	}
+	return s.m.Authorizer().ExplainForUser(req.TraceContext, body.UserID, AuthzRequest{
+		Permission:    body.Permission,
+		ResourceType:  body.ResourceType,
+		ResourceID:    body.ResourceID,
+		OwnerID:       body.OwnerID,
+		OrgID:         body.OrgID,
+		ResourceAttrs: body.ResourceAttrs,
+		Context:       body.Context,
+	})
+}
+
 type createPolicyBody struct {
 	TenantID     string `json:"tenant_id"`
 	Code         string `json:"code"`
@@ -546,3 +906,197 @@ func (s *StandaloneService) handleAdminRemoveOrgRole(req server.Request) (any, e
 		req.GetUrlParam("role_id"),
 	)
 }
+
+// ============================================================
+// 目录同步
+// ============================================================
+
+func (s *StandaloneService) handleAdminLDAPSync(req server.Request) (any, error) {
+	return s.m.LDAP().Sync(req.TraceContext)
+}
+
+// ============================================================
+// SAML 单点登录
+// ============================================================
+
+func (s *StandaloneService) handleAdminGetSAMLConnection(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	tenantID := req.GetUrlQuery("tenant_id")
+	if tenantID == "" {
+		tenantID = p.TenantID
+	}
+	return s.m.SAML().GetConnection(req.TraceContext, tenantID)
+}
+
+func (s *StandaloneService) handleAdminSaveSAMLConnection(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body SAMLConnectionRequest
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	if body.TenantID == "" {
+		body.TenantID = p.TenantID
+	}
+	return s.m.SAML().SaveConnection(req.TraceContext, body)
+}
+
+func (s *StandaloneService) handleAdminDeleteSAMLConnection(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	tenantID := req.GetUrlQuery("tenant_id")
+	if tenantID == "" {
+		tenantID = p.TenantID
+	}
+	return nil, s.m.SAML().DeleteConnection(req.TraceContext, tenantID)
+}
+
+// ============================================================
+// 关系元组（ReBAC），均作用于当前登录者所在租户
+// ============================================================
+
+func (s *StandaloneService) handleAdminReadTuples(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	filter := TupleFilter{
+		ObjectType:      req.GetUrlQuery("object_type"),
+		ObjectID:        req.GetUrlQuery("object_id"),
+		Relation:        req.GetUrlQuery("relation"),
+		SubjectType:     req.GetUrlQuery("subject_type"),
+		SubjectID:       req.GetUrlQuery("subject_id"),
+		SubjectRelation: req.GetUrlQuery("subject_relation"),
+		Limit:           500,
+	}
+	return s.m.ReBAC().ReadTuples(req.TraceContext, p.TenantID, filter)
+}
+
+// bindTuples 解析 {"tuples": ["document:readme#viewer@user:alice", ...]}。
+func bindTuples(req server.Request) ([]RelationTuple, error) {
+	var body struct {
+		Tuples []string `json:"tuples"`
+	}
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	if len(body.Tuples) == 0 || len(body.Tuples) > 100 {
+		return nil, accountError(ErrInvalidArgument, "tuples 数量须为 1-100")
+	}
+	tuples := make([]RelationTuple, 0, len(body.Tuples))
+	for _, raw := range body.Tuples {
+		t, err := ParseRelationTuple(raw)
+		if err != nil {
+			return nil, accountError(ErrInvalidArgument, err.Error())
+		}
+		tuples = append(tuples, t)
+	}
+	return tuples, nil
+}
+
+func (s *StandaloneService) handleAdminWriteTuples(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	tuples, err := bindTuples(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.m.ReBAC().WriteTuples(req.TraceContext, p.TenantID, tuples...); err != nil {
+		return nil, err
+	}
+	s.m.audit(req.TraceContext, p.TenantID, p.UserID, "rebac_tuples_write", "success", fmt.Sprintf("count=%d", len(tuples)), req.C().ClientIP(), string(req.C().UserAgent()))
+	return nil, nil
+}
+
+func (s *StandaloneService) handleAdminDeleteTuples(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	tuples, err := bindTuples(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.m.ReBAC().DeleteTuples(req.TraceContext, p.TenantID, tuples...); err != nil {
+		return nil, err
+	}
+	s.m.audit(req.TraceContext, p.TenantID, p.UserID, "rebac_tuples_delete", "success", fmt.Sprintf("count=%d", len(tuples)), req.C().ClientIP(), string(req.C().UserAgent()))
+	return nil, nil
+}
+
+// rebacQueryBody check / expand / list-objects 的请求体：object 为 type:id，subject 为 type:id[#relation]。
+type rebacQueryBody struct {
+	Object     string `json:"object"`
+	ObjectType string `json:"object_type"`
+	Relation   string `json:"relation"`
+	Subject    string `json:"subject"`
+}
+
+func (s *StandaloneService) handleAdminReBACCheck(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body rebacQueryBody
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	object, err := ParseObjectRef(body.Object)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	subject, err := ParseSubjectRef(body.Subject)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	allowed, err := s.m.ReBAC().Check(req.TraceContext, p.TenantID, object, body.Relation, subject)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"allowed": allowed}, nil
+}
+
+func (s *StandaloneService) handleAdminReBACExpand(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body rebacQueryBody
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	object, err := ParseObjectRef(body.Object)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	return s.m.ReBAC().Expand(req.TraceContext, p.TenantID, object, body.Relation)
+}
+
+func (s *StandaloneService) handleAdminReBACListObjects(req server.Request) (any, error) {
+	p := contextPrincipal(req)
+	if p == nil {
+		return nil, accountError(ErrInvalidToken, "未认证")
+	}
+	var body rebacQueryBody
+	if err := req.BindJson(&body); err != nil {
+		return nil, err
+	}
+	subject, err := ParseSubjectRef(body.Subject)
+	if err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	ids, err := s.m.ReBAC().ListObjects(req.TraceContext, p.TenantID, body.ObjectType, body.Relation, subject)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"object_ids": ids}, nil
+}
@@ -0,0 +1,253 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 认证方式（amr，RFC 8176），记录在会话与 access token 的 amr 声明中。
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp" // TOTP 或恢复码
+	AMRSMS         = "sms"
+	AMREmail       = "email"
+	AMRPasskey     = "hwk" // WebAuthn / Passkey
+	AMRFederated   = "fed" // 外部身份提供方（OAuth / OIDC / SAML / 企业目录以外的 IdP）
+	AMRMultiFactor = "mfa" // 使用了多个认证因素
+)
+
+// 认证保证级别（acr），参照 NIST SP 800-63B 的 AAL 划分。
+const (
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+)
+
+// secondFactorMethods 可以满足 step-up 的认证方式：otp / sms / email 通过 CompleteStepUp 完成，
+// hwk 通过 CompletePasskeyStepUp 完成。
+var secondFactorMethods = []string{AMROTP, AMRSMS, AMREmail, AMRPasskey}
+
+// validateStepUpMethods 校验 step-up 要求的认证方式都是可以完成的第二因素。
+func validateStepUpMethods(methods []string) error {
+	for _, method := range methods {
+		if !contains(secondFactorMethods, method) {
+			return fmt.Errorf("unsupported step-up method %q", method)
+		}
+	}
+	return nil
+}
+
+// amrForMethod 把验证码通道 / MFA 挑战方式映射为 amr 值。
+func amrForMethod(method string) string {
+	switch method {
+	case VerificationChannelEmail:
+		return AMREmail
+	case VerificationChannelSMS:
+		return AMRSMS
+	default:
+		return AMROTP
+	}
+}
+
+// authAssurance 会话的认证上下文，签发 access token 时写入 acr / amr / auth_time 声明。
+type authAssurance struct {
+	ACR      string
+	AMR      []string
+	AuthTime time.Time
+}
+
+// newAuthAssurance 根据本次登录使用的认证方式计算 acr：
+// Passkey 或两种及以上因素为 aal2，其余为 aal1；amr 为空时不写认证上下文。
+func newAuthAssurance(amr []string, at time.Time) authAssurance {
+	methods := make([]string, 0, len(amr)+1)
+	factors := 0
+	for _, method := range amr {
+		if method == "" || contains(methods, method) {
+			continue
+		}
+		methods = append(methods, method)
+		if method != AMRMultiFactor {
+			factors++
+		}
+	}
+	if len(methods) == 0 {
+		return authAssurance{}
+	}
+	a := authAssurance{ACR: ACRSingleFactor, AMR: methods, AuthTime: at}
+	if factors >= 2 && !contains(methods, AMRMultiFactor) {
+		a.AMR = append(a.AMR, AMRMultiFactor)
+	}
+	if contains(a.AMR, AMRMultiFactor) || contains(a.AMR, AMRPasskey) {
+		a.ACR = ACRMultiFactor
+	}
+	return a
+}
+
+// mfaMethod 返回满足 aal2 的第二因素，单因素时为空。
+func (a authAssurance) mfaMethod() string {
+	if a.ACR != ACRMultiFactor {
+		return ""
+	}
+	for i := len(a.AMR) - 1; i >= 0; i-- {
+		if contains(secondFactorMethods, a.AMR[i]) {
+			return a.AMR[i]
+		}
+	}
+	return ""
+}
+
+// assurance 从会话记录还原认证上下文；auth_time 取登录与最近一次 step-up 中较晚者。
+func (s Session) assurance() authAssurance {
+	a := authAssurance{ACR: s.ACR, AuthTime: s.CreatedAt}
+	if s.AMR != "" {
+		a.AMR = strings.Split(s.AMR, ",")
+	}
+	if s.MFASatisfiedAt != nil && s.MFASatisfiedAt.After(a.AuthTime) {
+		a.AuthTime = *s.MFASatisfiedAt
+	}
+	return a
+}
+
+// upgradeAMR 在会话已有的 amr 上追加 step-up 使用的方式。
+func upgradeAMR(existing, method string) string {
+	var amr []string
+	if existing != "" {
+		amr = strings.Split(existing, ",")
+	}
+	for _, m := range []string{method, AMRMultiFactor} {
+		if !contains(amr, m) {
+			amr = append(amr, m)
+		}
+	}
+	return strings.Join(amr, ",")
+}
+
+// StepUpRequirement 敏感操作对当前会话的认证要求。
+type StepUpRequirement struct {
+	// MaxAge 最近一次第二因素验证距今的最长时间，0 使用 Config.StepUpWindow。
+	MaxAge time.Duration
+	// Methods 可接受的第二因素（amr 值：otp / sms / email / hwk），为空表示任意。
+	Methods []string
+}
+
+// StepUpChallenge 表示当前会话不满足 StepUpRequirement，客户端需要完成 step-up 后重试。
+type StepUpChallenge struct {
+	Reason  string        `json:"reason"`
+	ACR     string        `json:"acr_values"`
+	MaxAge  time.Duration `json:"-"`
+	Methods []string      `json:"amr_values,omitempty"`
+}
+
+// WWWAuthenticate 返回 RFC 9470 风格的 WWW-Authenticate 响应头值。
+func (c *StepUpChallenge) WWWAuthenticate() string {
+	header := fmt.Sprintf(`Bearer error="insufficient_user_authentication", error_description="step-up authentication required", acr_values="%s", max_age=%d`,
+		c.ACR, int64(c.MaxAge/time.Second))
+	if len(c.Methods) > 0 {
+		header += fmt.Sprintf(`, amr_values="%s"`, strings.Join(c.Methods, " "))
+	}
+	return header
+}
+
+// EvaluateStepUp 按 req 检查当前会话最近是否完成过第二因素验证。
+// 满足时返回 (nil, nil)；需要 step-up 时返回挑战；会话失效、代操作会话、
+// 或租户策略要求启用 MFA 而用户尚未启用等无法通过 step-up 解决的情况返回 error。
+// 用户没有可用于 req.Methods 的第二因素（未限定方式时为未启用 TOTP）且没有策略强制时直接放行，与 RequireStepUp 一致。
+func (s *AuthService) EvaluateStepUp(ctx context.Context, principal *Principal, req StepUpRequirement) (*StepUpChallenge, error) {
+	if principal == nil {
+		return nil, accountError(ErrInvalidArgument, "principal 不能为空")
+	}
+	// 代操作会话无法代替用户完成二次验证，敏感操作一律拒绝。
+	if principal.Impersonated {
+		s.recordStepUpDenied(ctx, "impersonated")
+		return nil, accountError(ErrPermissionDenied, "代操作会话不能执行敏感操作")
+	}
+	// 令牌交换取得的令牌由下游服务持有，同样不能代替用户完成二次验证。
+	if principal.ClientID != "" {
+		s.recordStepUpDenied(ctx, "delegated")
+		return nil, accountError(ErrPermissionDenied, "委托令牌不能执行敏感操作")
+	}
+	if err := validateStepUpMethods(req.Methods); err != nil {
+		return nil, accountError(ErrInvalidArgument, err.Error())
+	}
+	maxAge := req.MaxAge
+	if maxAge <= 0 {
+		maxAge = s.m.cfg.StepUpWindow
+	}
+	if maxAge <= 0 {
+		maxAge = 5 * time.Minute
+	}
+	challenge := func(reason string) *StepUpChallenge {
+		s.recordStepUpDenied(ctx, reason)
+		return &StepUpChallenge{Reason: reason, ACR: ACRMultiFactor, MaxAge: maxAge, Methods: req.Methods}
+	}
+
+	if !s.hasStepUpFactor(ctx, principal, req.Methods) {
+		// 用户没有可用的第二因素，跳过 step-up；租户策略要求该用户启用 MFA 时拒绝，引导先完成绑定。
+		if required, _ := s.m.mfa.enrollmentState(ctx, principal.TenantID, principal.UserID, principal.Roles); required {
+			s.recordStepUpDenied(ctx, "enrollment_required")
+			return nil, accountError(ErrPermissionDenied, "需要先启用 MFA 才能执行此操作")
+		}
+		return nil, nil
+	}
+	if principal.SessionID == "" {
+		return challenge("no_sid"), nil
+	}
+	var sess Session
+	if err := s.m.db.WithContext(ctx).
+		Select("id", "status", "mfa_satisfied_at", "mfa_method").
+		Where("id = ? AND tenant_id = ? AND user_id = ?",
+			principal.SessionID, principal.TenantID, principal.UserID).
+		First(&sess).Error; err != nil {
+		return challenge("session_not_found"), nil
+	}
+	if sess.Status != SessionActive {
+		s.recordStepUpDenied(ctx, "session_inactive")
+		return nil, accountError(ErrInvalidToken, "会话已失效")
+	}
+	if sess.MFASatisfiedAt == nil || time.Since(*sess.MFASatisfiedAt) > maxAge {
+		return challenge("expired_or_missing"), nil
+	}
+	if len(req.Methods) > 0 && !contains(req.Methods, sess.MFAMethod) {
+		return challenge("method_mismatch"), nil
+	}
+	return nil, nil
+}
+
+// hasStepUpFactor 判断用户是否具备 methods 中任一第二因素：otp 为已启用 TOTP，hwk 为已注册通行密钥，
+// sms / email 为已验证的手机号 / 邮箱。methods 为空时以是否启用 TOTP 判断。
+func (s *AuthService) hasStepUpFactor(ctx context.Context, principal *Principal, methods []string) bool {
+	if len(methods) == 0 {
+		return s.m.mfa.HasTOTP(ctx, principal.TenantID, principal.UserID)
+	}
+	var user User
+	if err := s.m.db.WithContext(ctx).Select("id", "email_verified_at", "phone_verified_at").
+		Where("id = ? AND tenant_id = ?", principal.UserID, principal.TenantID).First(&user).Error; err != nil {
+		return false
+	}
+	for _, method := range methods {
+		switch method {
+		case AMROTP:
+			if s.m.mfa.HasTOTP(ctx, principal.TenantID, principal.UserID) {
+				return true
+			}
+		case AMRPasskey:
+			var count int64
+			s.m.db.WithContext(ctx).Model(&PasskeyCredential{}).
+				Where("tenant_id = ? AND user_id = ? AND enabled = ?", principal.TenantID, principal.UserID, true).
+				Count(&count)
+			if count > 0 {
+				return true
+			}
+		case AMRSMS:
+			if user.PhoneVerifiedAt != nil {
+				return true
+			}
+		case AMREmail:
+			if user.EmailVerifiedAt != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
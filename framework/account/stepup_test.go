@@ -0,0 +1,230 @@
+package account
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"gorm.io/gorm"
+)
+
+func TestNewAuthAssurance(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		amr     []string
+		acr     string
+		wantAMR string
+		mfa     string
+	}{
+		{nil, "", "", ""},
+		{[]string{AMRPassword}, ACRSingleFactor, "pwd", ""},
+		{[]string{AMRPassword, AMRSMS}, ACRMultiFactor, "pwd,sms,mfa", AMRSMS},
+		{[]string{AMROTP, AMRMultiFactor}, ACRMultiFactor, "otp,mfa", AMROTP},
+		{[]string{AMRPasskey}, ACRMultiFactor, "hwk", AMRPasskey},
+		{[]string{AMRFederated, AMRFederated}, ACRSingleFactor, "fed", ""},
+	}
+	for _, tc := range cases {
+		a := newAuthAssurance(tc.amr, now)
+		if a.ACR != tc.acr || strings.Join(a.AMR, ",") != tc.wantAMR || a.mfaMethod() != tc.mfa {
+			t.Errorf("newAuthAssurance(%v) = %+v, mfa %q", tc.amr, a, a.mfaMethod())
+		}
+	}
+	if got := upgradeAMR("pwd", AMROTP); got != "pwd,otp,mfa" {
+		t.Errorf("upgradeAMR = %q", got)
+	}
+	c := &StepUpChallenge{ACR: ACRMultiFactor, MaxAge: 5 * time.Minute, Methods: []string{AMROTP, AMRPasskey}}
+	if h := c.WWWAuthenticate(); !strings.Contains(h, `error="insufficient_user_authentication"`) ||
+		!strings.Contains(h, "max_age=300") || !strings.Contains(h, `amr_values="otp hwk"`) {
+		t.Errorf("WWWAuthenticate = %s", h)
+	}
+}
+
+func TestSessionAssuranceClaims(t *testing.T) {
+	m, p := newPolicyTestManager(t)
+	ctx := context.Background()
+	var user User
+	if err := m.db.Where("id = ?", p.UserID).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	var result *AuthResult
+	if err := m.db.Transaction(func(tx *gorm.DB) (err error) {
+		result, err = m.auth.issueTokens(ctx, tx, &user, p.Roles, []string{AMRPassword}, "", "", "", "", "", "")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	principal, err := m.Auth().Validate(ctx, result.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.ACR != ACRSingleFactor || strings.Join(principal.AMR, ",") != "pwd" || principal.AuthTime == 0 {
+		t.Fatalf("unexpected assurance: acr=%s amr=%v auth_time=%d", principal.ACR, principal.AMR, principal.AuthTime)
+	}
+
+	// step-up 后会话升级，续签的令牌带上新的声明
+	now := time.Now()
+	if err := m.db.Model(&Session{}).Where("id = ?", principal.SessionID).Updates(map[string]any{
+		"mfa_satisfied_at": &now, "mfa_method": AMROTP, "acr": ACRMultiFactor, "amr": upgradeAMR("pwd", AMROTP),
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := m.Auth().Refresh(ctx, RefreshRequest{RefreshToken: result.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.auth.parseAccessToken(refreshed.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ACR != ACRMultiFactor || strings.Join(claims.AMR, ",") != "pwd,otp,mfa" {
+		t.Fatalf("refreshed claims: acr=%s amr=%v", claims.ACR, claims.AMR)
+	}
+}
+
+func TestEvaluateStepUp(t *testing.T) {
+	m, p := newPolicyTestManager(t)
+	ctx := context.Background()
+	session := Session{ID: newID(), TenantID: "default", UserID: p.UserID, FamilyID: newID(), Status: SessionActive, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := m.db.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	p.SessionID = session.ID
+
+	// 未启用 TOTP 且无策略：放行
+	if c, err := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{}); err != nil || c != nil {
+		t.Fatalf("expected pass without MFA, got %+v %v", c, err)
+	}
+
+	// 租户策略要求 accountant 启用 MFA：宽限期内可登录，但敏感操作拒绝
+	future := time.Now().Add(24 * time.Hour)
+	if _, err := m.MFA().SetPolicy(ctx, "default", MFAPolicyRequest{RequiredRoles: []string{"accountant"}, EnforceAfter: &future}); err != nil {
+		t.Fatal(err)
+	}
+	if required, enforced := m.mfa.enrollmentState(ctx, "default", p.UserID, p.Roles); !required || enforced {
+		t.Fatalf("enrollmentState = %v, %v", required, enforced)
+	}
+	if _, err := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{}); err == nil {
+		t.Fatal("expected enrollment to be required")
+	}
+	if _, err := m.MFA().SetPolicy(ctx, "default", MFAPolicyRequest{RequiredRoles: []string{"accountant"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, enforced := m.mfa.enrollmentState(ctx, "default", p.UserID, p.Roles); !enforced {
+		t.Fatal("expected policy without grace period to be enforced")
+	}
+
+	if err := m.db.Create(&Credential{ID: newID(), TenantID: "default", UserID: p.UserID, Type: CredentialTOTP, Identifier: p.UserID, SecretHash: "x", Enabled: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if required, _ := m.mfa.enrollmentState(ctx, "default", p.UserID, p.Roles); required {
+		t.Fatal("enrolled user should not be required to enroll")
+	}
+	c, err := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{})
+	if err != nil || c == nil || c.Reason != "expired_or_missing" {
+		t.Fatalf("expected challenge, got %+v %v", c, err)
+	}
+	if err := m.Auth().RequireStepUp(ctx, p); err == nil {
+		t.Fatal("expected RequireStepUp to deny")
+	}
+
+	satisfied := time.Now().Add(-2 * time.Minute)
+	if err := m.db.Model(&Session{}).Where("id = ?", session.ID).Updates(map[string]any{"mfa_satisfied_at": &satisfied, "mfa_method": AMROTP}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Auth().RequireStepUp(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{MaxAge: time.Minute}); c == nil || c.Reason != "expired_or_missing" {
+		t.Fatalf("expected max age challenge, got %+v", c)
+	}
+	if c, err := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{Methods: []string{AMROTP}}); err != nil || c != nil {
+		t.Fatalf("expected pass, got %+v %v", c, err)
+	}
+	// 登录时用短信完成的第二因素不满足只接受 otp 的要求
+	if err := m.db.Model(&Session{}).Where("id = ?", session.ID).Update("mfa_method", AMRSMS).Error; err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{Methods: []string{AMROTP}}); c == nil || c.Reason != "method_mismatch" {
+		t.Fatalf("expected method challenge, got %+v", c)
+	}
+	// 用户没有注册通行密钥时只接受 hwk 的要求直接放行，注册后需要 step-up
+	if c, err := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{Methods: []string{AMRPasskey}}); err != nil || c != nil {
+		t.Fatalf("expected pass without passkey, got %+v %v", c, err)
+	}
+	if err := m.db.Create(&PasskeyCredential{ID: newID(), TenantID: "default", UserID: p.UserID, CredentialID: newID(), Enabled: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{Methods: []string{AMRPasskey}}); c == nil || c.Reason != "method_mismatch" {
+		t.Fatalf("expected passkey challenge, got %+v", c)
+	}
+	if _, err := m.Auth().EvaluateStepUp(ctx, p, StepUpRequirement{Methods: []string{"pin"}}); err == nil {
+		t.Fatal("expected unknown step-up method to be rejected")
+	}
+}
+
+func TestCompleteStepUpWithEmailCode(t *testing.T) {
+	mail := &fakeSender{name: "mail"}
+	m, _ := newNotifyTestManager(t, map[string][]VerificationSender{VerificationChannelEmail: {mail}})
+	ctx := context.Background()
+	email := "bob@example.com"
+	now := time.Now()
+	user := User{ID: newID(), TenantID: "default", Username: "bob", Email: &email, EmailVerifiedAt: &now, Status: UserStatusNormal}
+	session := Session{ID: newID(), TenantID: "default", UserID: user.ID, FamilyID: newID(), Status: SessionActive, AMR: "pwd", ExpiresAt: time.Now().Add(time.Hour)}
+	for _, v := range []any{&user, &session} {
+		if err := m.db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := &Principal{UserID: user.ID, TenantID: "default", SessionID: session.ID}
+	req := StepUpRequirement{Methods: []string{AMREmail}}
+	if c, err := m.Auth().EvaluateStepUp(ctx, p, req); err != nil || c == nil {
+		t.Fatalf("expected email challenge, got %+v %v", c, err)
+	}
+	if _, err := m.Auth().StartStepUp(ctx, p, AMROTP, "10.0.0.1"); err == nil {
+		t.Fatal("expected otp step-up to fail without TOTP")
+	}
+
+	start, err := m.Auth().StartStepUp(ctx, p, AMREmail, "10.0.0.1")
+	if err != nil || start.ChallengeID == "" || len(mail.sent) != 1 {
+		t.Fatalf("StartStepUp = %+v %v, sent %d", start, err, len(mail.sent))
+	}
+	if err := m.Auth().CompleteStepUp(ctx, p, start.ChallengeID, "000000"+mail.sent[0].Code); err == nil {
+		t.Fatal("expected wrong code to be rejected")
+	}
+	if err := m.Auth().CompleteStepUp(ctx, p, start.ChallengeID, mail.sent[0].Code); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := m.Auth().EvaluateStepUp(ctx, p, req); err != nil || c != nil {
+		t.Fatalf("expected pass after email step-up, got %+v %v", c, err)
+	}
+	var got Session
+	if err := m.db.Where("id = ?", session.ID).First(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.MFAMethod != AMREmail || got.ACR != ACRMultiFactor || got.AMR != "pwd,email,mfa" {
+		t.Fatalf("session assurance = %q %q %q", got.MFAMethod, got.ACR, got.AMR)
+	}
+	if err := m.Auth().CompleteStepUp(ctx, p, start.ChallengeID, mail.sent[0].Code); err == nil {
+		t.Fatal("expected consumed challenge to be rejected")
+	}
+}
+
+func TestRequireStepUpMiddlewareConfigError(t *testing.T) {
+	m, p := newPolicyTestManager(t)
+	h := server.New()
+	h.GET("/x", func(ctx context.Context, c *app.RequestContext) {
+		c.Set(principalContextKey, p)
+		c.Next(ctx)
+	}, m.Middleware().RequireStepUp(0, "pin"), func(ctx context.Context, c *app.RequestContext) {
+		c.String(http.StatusOK, "ok")
+	})
+	// 无法完成的方式是配置错误：不 panic，也不放行
+	if w := ut.PerformRequest(h.Engine, http.MethodGet, "/x", nil); w.Code == http.StatusOK {
+		t.Fatalf("expected misconfigured RequireStepUp to fail, got %d %s", w.Code, w.Body.String())
+	}
+}
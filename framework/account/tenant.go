@@ -0,0 +1,840 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 租户状态。deleting 表示级联清理进行中，重复调用 Delete 会从中断处继续。
+const (
+	TenantStatusActive    = "active"
+	TenantStatusSuspended = "suspended"
+	TenantStatusDeleting  = "deleting"
+)
+
+// 租户可限制的登录方式，对应 TenantSettings.AllowedLoginMethods。
+const (
+	LoginMethodPassword = "password"
+	LoginMethodCode     = "code"
+	LoginMethodPasskey  = "passkey"
+	LoginMethodOAuth    = "oauth"
+	LoginMethodLDAP     = "ldap"
+	LoginMethodSAML     = "saml"
+)
+
+var loginMethods = []string{LoginMethodPassword, LoginMethodCode, LoginMethodPasskey, LoginMethodOAuth, LoginMethodLDAP, LoginMethodSAML}
+
+// tenantCacheTTL 进程内租户设置缓存时长。停用租户时会同时吊销全部会话，
+// 其他副本在缓存过期前依靠会话状态拒绝请求。
+const tenantCacheTTL = 30 * time.Second
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Tenant 租户。未在本表登记的租户 ID 仍可使用（与历史行为一致），只是没有覆盖配置与配额。
+type Tenant struct {
+	ID            string     `json:"id" gorm:"size:64;primaryKey"`
+	Name          string     `json:"name" gorm:"size:128;not null"`
+	Status        string     `json:"status" gorm:"size:16;not null;default:active;index"`
+	MaxUsers      int        `json:"max_users" gorm:"not null;default:0"` // 0 表示不限
+	Settings      string     `json:"-" gorm:"type:text"`                  // JSON TenantSettings
+	SuspendReason string     `json:"suspend_reason,omitempty" gorm:"size:255"`
+	SuspendedAt   *time.Time `json:"suspended_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (Tenant) TableName() string { return "acct_tenants" }
+
+// TenantDomain 租户自定义域名，请求 Host 命中时据此确定租户。
+type TenantDomain struct {
+	Domain    string    `json:"domain" gorm:"size:255;primaryKey"`
+	TenantID  string    `json:"tenant_id" gorm:"size:64;not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (TenantDomain) TableName() string { return "acct_tenant_domains" }
+
+// TenantSettings 租户级配置覆盖，零值字段沿用全局 Config。
+type TenantSettings struct {
+	// PasswordMinLength 覆盖 Config.Password.MinLength，取值 8 ~ Config.Password.MaxLength。
+	PasswordMinLength int `json:"password_min_length,omitempty"`
+	// AllowedLoginMethods 允许的登录方式（LoginMethod*），为空不限制。
+	AllowedLoginMethods []string `json:"allowed_login_methods,omitempty"`
+	// RequireMFA 要求租户内全部用户启用 TOTP：未启用的用户登录结果带 mfa_enrollment_required，
+	// 绑定前敏感操作一律拒绝。需要拒绝登录时配合 MFAPolicy 的 EnforceAfter 使用。
+	RequireMFA bool `json:"require_mfa,omitempty"`
+	// AccessTokenTTL 访问令牌有效期（秒），不能超过全局 AccessTokenTTL（黑名单条目按全局时长保留）。
+	AccessTokenTTL int `json:"access_token_ttl,omitempty"`
+	// RefreshTokenTTL 会话/刷新令牌有效期（秒）。
+	RefreshTokenTTL int `json:"refresh_token_ttl,omitempty"`
+	// AllowedOAuthProviders 允许的第三方登录提供商（Config.OAuthProviders 的键），为空不限制。
+	AllowedOAuthProviders []string `json:"allowed_oauth_providers,omitempty"`
+	// AllowedIdPConnections 租户用户可通过内置 IdP 登录的客户端（client_id），为空不限制。
+	AllowedIdPConnections []string `json:"allowed_idp_connections,omitempty"`
+}
+
+// TenantInfo 租户的对外视图。
+type TenantInfo struct {
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Status        string         `json:"status"`
+	MaxUsers      int            `json:"max_users"`
+	UserCount     int64          `json:"user_count"`
+	Settings      TenantSettings `json:"settings"`
+	Domains       []string       `json:"domains"`
+	SuspendReason string         `json:"suspend_reason,omitempty"`
+	SuspendedAt   *time.Time     `json:"suspended_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// CreateTenantRequest 创建租户的参数。
+type CreateTenantRequest struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	MaxUsers int            `json:"max_users"`
+	Settings TenantSettings `json:"settings"`
+	Domains  []string       `json:"domains"`
+}
+
+// UpdateTenantRequest 更新租户的参数，nil 字段保持不变；Settings 整体覆盖。
+type UpdateTenantRequest struct {
+	Name     *string         `json:"name"`
+	MaxUsers *int            `json:"max_users"`
+	Settings *TenantSettings `json:"settings"`
+}
+
+// ListTenantsRequest 租户列表查询参数。
+type ListTenantsRequest struct {
+	Status   string `json:"status"`
+	Keyword  string `json:"keyword"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+}
+
+// ListTenantsResult 租户列表。
+type ListTenantsResult struct {
+	Items []TenantInfo `json:"items"`
+	Total int64        `json:"total"`
+	Page  int          `json:"page"`
+}
+
+type tenantCacheEntry struct {
+	tenant   *Tenant // nil 表示未登记
+	settings TenantSettings
+	expires  time.Time
+}
+
+// TenantService 租户生命周期、租户级配置覆盖、用户配额与自定义域名。
+type TenantService struct {
+	m       *Manager
+	mu      sync.RWMutex
+	entries map[string]tenantCacheEntry
+	domains map[string]tenantDomainEntry
+}
+
+type tenantDomainEntry struct {
+	tenantID string
+	expires  time.Time
+}
+
+// Create 登记租户，并为其复制默认租户的内置角色。
+func (s *TenantService) Create(ctx context.Context, req CreateTenantRequest) (*TenantInfo, error) {
+	req.ID = strings.TrimSpace(req.ID)
+	req.Name = strings.TrimSpace(req.Name)
+	if !tenantIDPattern.MatchString(req.ID) {
+		return nil, accountError(ErrInvalidArgument, "租户 ID 只能包含小写字母、数字、下划线和短横线，最长 64 位")
+	}
+	if req.Name == "" {
+		req.Name = req.ID
+	}
+	if req.MaxUsers < 0 {
+		return nil, accountError(ErrInvalidArgument, "max_users 不能为负数")
+	}
+	settings, err := s.encodeSettings(req.Settings)
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]string, 0, len(req.Domains))
+	for _, d := range req.Domains {
+		domain, err := normalizeTenantDomain(d)
+		if err != nil {
+			return nil, err
+		}
+		if !contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	tenant := Tenant{ID: req.ID, Name: truncateString(req.Name, 128), Status: TenantStatusActive, MaxUsers: req.MaxUsers, Settings: settings}
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Tenant{}).Where("id = ?", tenant.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return accountError(ErrIdentifierExists, "租户已存在")
+		}
+		if err := tx.Create(&tenant).Error; err != nil {
+			return fmt.Errorf("create tenant: %w", err)
+		}
+		for _, domain := range domains {
+			if err := s.addDomainTx(tx, tenant.ID, domain); err != nil {
+				return err
+			}
+		}
+		if err := s.seedRoles(tx, tenant.ID); err != nil {
+			return err
+		}
+		return emitOutbox(tx, EventTenantCreated, tenant.ID, map[string]any{
+			"tenant_id":  tenant.ID,
+			"name":       tenant.Name,
+			"created_at": time.Now(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(tenant.ID, domains...)
+	s.m.audit(ctx, tenant.ID, "", "tenant", "created", "", "", "")
+	return s.Get(ctx, tenant.ID)
+}
+
+// Get 返回租户详情。
+func (s *TenantService) Get(ctx context.Context, tenantID string) (*TenantInfo, error) {
+	tenant, err := s.load(s.m.db.WithContext(ctx), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := s.toInfos(ctx, []Tenant{*tenant})
+	if err != nil {
+		return nil, err
+	}
+	return &infos[0], nil
+}
+
+// List 分页查询租户。
+func (s *TenantService) List(ctx context.Context, req ListTenantsRequest) (*ListTenantsResult, error) {
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PageSize < 1 || req.PageSize > 200 {
+		req.PageSize = 50
+	}
+	q := s.m.db.WithContext(ctx).Model(&Tenant{})
+	if req.Status != "" {
+		q = q.Where("status = ?", req.Status)
+	}
+	if req.Keyword != "" {
+		kw := "%" + req.Keyword + "%"
+		q = q.Where("id LIKE ? OR name LIKE ?", kw, kw)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	var tenants []Tenant
+	if err := q.Order("created_at DESC").Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize).Find(&tenants).Error; err != nil {
+		return nil, err
+	}
+	items, err := s.toInfos(ctx, tenants)
+	if err != nil {
+		return nil, err
+	}
+	return &ListTenantsResult{Items: items, Total: total, Page: req.Page}, nil
+}
+
+// Update 修改租户名称、用户配额或覆盖配置。降低配额不影响已有用户，只阻止新增。
+func (s *TenantService) Update(ctx context.Context, tenantID string, req UpdateTenantRequest) (*TenantInfo, error) {
+	tenant, err := s.load(s.m.db.WithContext(ctx), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]any{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, accountError(ErrInvalidArgument, "租户名称不能为空")
+		}
+		updates["name"] = truncateString(name, 128)
+	}
+	if req.MaxUsers != nil {
+		if *req.MaxUsers < 0 {
+			return nil, accountError(ErrInvalidArgument, "max_users 不能为负数")
+		}
+		updates["max_users"] = *req.MaxUsers
+	}
+	if req.Settings != nil {
+		settings, err := s.encodeSettings(*req.Settings)
+		if err != nil {
+			return nil, err
+		}
+		updates["settings"] = settings
+	}
+	if len(updates) == 0 {
+		return s.Get(ctx, tenant.ID)
+	}
+	if err := s.m.db.WithContext(ctx).Model(&Tenant{}).Where("id = ?", tenant.ID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("update tenant: %w", err)
+	}
+	s.invalidate(tenant.ID)
+	s.m.audit(ctx, tenant.ID, "", "tenant", "updated", "", "", "")
+	return s.Get(ctx, tenant.ID)
+}
+
+// Suspend 停用租户：拒绝登录、注册与令牌校验，并吊销租户内全部会话。
+func (s *TenantService) Suspend(ctx context.Context, tenantID, reason string) error {
+	tenant, err := s.load(s.m.db.WithContext(ctx), tenantID)
+	if err != nil {
+		return err
+	}
+	if tenant.ID == s.m.cfg.DefaultTenantID {
+		return accountError(ErrInvalidArgument, "不能停用默认租户")
+	}
+	if tenant.Status != TenantStatusActive {
+		return accountError(ErrInvalidArgument, "租户当前状态不能停用: "+tenant.Status)
+	}
+	now := time.Now()
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Tenant{}).Where("id = ?", tenant.ID).Updates(map[string]any{
+			"status":         TenantStatusSuspended,
+			"suspend_reason": truncateString(reason, 255),
+			"suspended_at":   now,
+		}).Error; err != nil {
+			return err
+		}
+		return emitOutbox(tx, EventTenantSuspended, tenant.ID, map[string]any{
+			"tenant_id":    tenant.ID,
+			"reason":       reason,
+			"suspended_at": now,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("suspend tenant: %w", err)
+	}
+	s.invalidate(tenant.ID)
+	if err := s.revokeSessions(ctx, tenant.ID); err != nil {
+		return err
+	}
+	s.m.audit(ctx, tenant.ID, "", "tenant", "suspended", truncateString(reason, 255), "", "")
+	return nil
+}
+
+// Resume 恢复已停用的租户。被吊销的会话不会恢复，用户需重新登录。
+func (s *TenantService) Resume(ctx context.Context, tenantID string) error {
+	tenant, err := s.load(s.m.db.WithContext(ctx), tenantID)
+	if err != nil {
+		return err
+	}
+	if tenant.Status != TenantStatusSuspended {
+		return accountError(ErrInvalidArgument, "租户未处于停用状态")
+	}
+	err = s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Tenant{}).Where("id = ?", tenant.ID).Updates(map[string]any{
+			"status":         TenantStatusActive,
+			"suspend_reason": "",
+			"suspended_at":   nil,
+		}).Error; err != nil {
+			return err
+		}
+		return emitOutbox(tx, EventTenantResumed, tenant.ID, map[string]any{
+			"tenant_id":  tenant.ID,
+			"resumed_at": time.Now(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("resume tenant: %w", err)
+	}
+	s.invalidate(tenant.ID)
+	s.m.audit(ctx, tenant.ID, "", "tenant", "resumed", "", "", "")
+	return nil
+}
+
+// Delete 删除租户及其全部账户数据（用户、凭证、会话、角色、组织、策略、客户端等）。
+// 审计日志保留，由 Audit.RetentionDays 到期清理。租户先被置为 deleting 并吊销会话，
+// 再逐表删除；中途失败可重复调用，从中断处继续。
+func (s *TenantService) Delete(ctx context.Context, tenantID string) error {
+	tenant, err := s.load(s.m.db.WithContext(ctx), tenantID)
+	if err != nil {
+		return err
+	}
+	if tenant.ID == s.m.cfg.DefaultTenantID {
+		return accountError(ErrInvalidArgument, "不能删除默认租户")
+	}
+	db := s.m.db.WithContext(ctx)
+	if err := db.Model(&Tenant{}).Where("id = ?", tenant.ID).Update("status", TenantStatusDeleting).Error; err != nil {
+		return fmt.Errorf("mark tenant deleting: %w", err)
+	}
+	s.invalidate(tenant.ID)
+	if err := s.revokeSessions(ctx, tenant.ID); err != nil {
+		return err
+	}
+
+	// 无 tenant_id 列的关联表先按父表清理
+	roles := db.Model(&Role{}).Select("id").Where("tenant_id = ?", tenant.ID)
+	if err := db.Where("role_id IN (?)", roles).Delete(&RolePermission{}).Error; err != nil {
+		return fmt.Errorf("delete tenant role permissions: %w", err)
+	}
+	users := db.Model(&User{}).Select("id").Where("tenant_id = ?", tenant.ID)
+	if err := db.Where("user_id IN (?)", users).Delete(&AccessTokenDenylist{}).Error; err != nil {
+		return fmt.Errorf("delete tenant denylist: %w", err)
+	}
+	for _, model := range accountModels() {
+		switch model.(type) {
+		case *AuditLog, *AuditLogArchive, *Tenant, *TenantDomain:
+			continue
+		}
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("parse %T: %w", model, err)
+		}
+		if stmt.Schema.LookUpField("tenant_id") == nil {
+			continue
+		}
+		if err := db.Where("tenant_id = ?", tenant.ID).Delete(model).Error; err != nil {
+			return fmt.Errorf("delete tenant rows from %s: %w", stmt.Schema.Table, err)
+		}
+	}
+
+	var domains []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&TenantDomain{}).Where("tenant_id = ?", tenant.ID).Pluck("domain", &domains).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tenant_id = ?", tenant.ID).Delete(&TenantDomain{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", tenant.ID).Delete(&Tenant{}).Error; err != nil {
+			return err
+		}
+		return emitOutbox(tx, EventTenantDeleted, tenant.ID, map[string]any{
+			"tenant_id":  tenant.ID,
+			"deleted_at": time.Now(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("delete tenant: %w", err)
+	}
+	s.invalidate(tenant.ID, domains...)
+	s.m.audit(ctx, tenant.ID, "", "tenant", "deleted", "", "", "")
+	gaia.InfoF("[account] tenant %s deleted", tenant.ID)
+	return nil
+}
+
+// AddDomain 为租户绑定自定义域名。域名全局唯一，需由调用方自行完成所有权校验（如 DNS TXT）。
+func (s *TenantService) AddDomain(ctx context.Context, tenantID, domain string) error {
+	domain, err := normalizeTenantDomain(domain)
+	if err != nil {
+		return err
+	}
+	db := s.m.db.WithContext(ctx)
+	tenant, err := s.load(db, tenantID)
+	if err != nil {
+		return err
+	}
+	if err := s.addDomainTx(db, tenant.ID, domain); err != nil {
+		return err
+	}
+	s.invalidate(tenant.ID, domain)
+	s.m.audit(ctx, tenant.ID, "", "tenant_domain", "added", domain, "", "")
+	return nil
+}
+
+// RemoveDomain 解绑租户的自定义域名。
+func (s *TenantService) RemoveDomain(ctx context.Context, tenantID, domain string) error {
+	domain, err := normalizeTenantDomain(domain)
+	if err != nil {
+		return err
+	}
+	res := s.m.db.WithContext(ctx).Where("domain = ? AND tenant_id = ?", domain, tenantID).Delete(&TenantDomain{})
+	if res.Error != nil {
+		return fmt.Errorf("remove tenant domain: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return accountError(ErrInvalidArgument, "域名未绑定到该租户")
+	}
+	s.invalidate(tenantID, domain)
+	s.m.audit(ctx, tenantID, "", "tenant_domain", "removed", domain, "", "")
+	return nil
+}
+
+// ResolveHost 根据请求 Host（可带端口）返回绑定的租户 ID，未绑定时返回空字符串。
+func (s *TenantService) ResolveHost(ctx context.Context, host string) (string, error) {
+	domain, err := normalizeTenantDomain(host)
+	if err != nil {
+		return "", nil
+	}
+	now := time.Now()
+	s.mu.RLock()
+	entry, ok := s.domains[domain]
+	s.mu.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.tenantID, nil
+	}
+	var tenantIDs []string
+	if err := s.m.db.WithContext(ctx).Model(&TenantDomain{}).Where("domain = ?", domain).Limit(1).Pluck("tenant_id", &tenantIDs).Error; err != nil {
+		recordDBError(ctx)
+		return "", fmt.Errorf("resolve tenant domain: %w", err)
+	}
+	entry = tenantDomainEntry{expires: now.Add(tenantCacheTTL)}
+	if len(tenantIDs) > 0 {
+		entry.tenantID = tenantIDs[0]
+	}
+	s.mu.Lock()
+	if s.domains == nil {
+		s.domains = map[string]tenantDomainEntry{}
+	}
+	s.domains[domain] = entry
+	s.mu.Unlock()
+	return entry.tenantID, nil
+}
+
+// ===== 内部：供各登录/注册/签发路径调用的检查 =====
+
+// checkActive 拒绝已停用或删除中的租户。
+func (s *TenantService) checkActive(ctx context.Context, tenantID string) error {
+	entry, err := s.lookup(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if entry.tenant == nil {
+		return nil
+	}
+	switch entry.tenant.Status {
+	case TenantStatusSuspended:
+		return accountError(ErrPermissionDenied, "租户已停用")
+	case TenantStatusDeleting:
+		return accountError(ErrPermissionDenied, "租户已删除")
+	}
+	return nil
+}
+
+// checkLogin 校验租户状态以及是否允许该登录方式。
+func (s *TenantService) checkLogin(ctx context.Context, tenantID, method string) error {
+	if err := s.checkActive(ctx, tenantID); err != nil {
+		return err
+	}
+	entry, err := s.lookup(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if allowed := entry.settings.AllowedLoginMethods; len(allowed) > 0 && !contains(allowed, method) {
+		return accountError(ErrPermissionDenied, "租户未开放该登录方式: "+method)
+	}
+	return nil
+}
+
+// checkOAuthProvider 校验租户是否允许该第三方登录提供商。
+func (s *TenantService) checkOAuthProvider(ctx context.Context, tenantID, provider string) error {
+	if err := s.checkLogin(ctx, tenantID, LoginMethodOAuth); err != nil {
+		return err
+	}
+	entry, err := s.lookup(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if allowed := entry.settings.AllowedOAuthProviders; len(allowed) > 0 && !contains(allowed, provider) {
+		return accountError(ErrPermissionDenied, "租户未开放该第三方登录: "+provider)
+	}
+	return nil
+}
+
+// checkIdPConnection 校验租户用户是否可以通过内置 IdP 登录该客户端。
+func (s *TenantService) checkIdPConnection(ctx context.Context, tenantID, clientID string) error {
+	if err := s.checkActive(ctx, tenantID); err != nil {
+		return err
+	}
+	entry, err := s.lookup(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if allowed := entry.settings.AllowedIdPConnections; len(allowed) > 0 && !contains(allowed, clientID) {
+		return accountError(ErrPermissionDenied, "租户未开放该应用: "+clientID)
+	}
+	return nil
+}
+
+// requireMFA 租户是否要求全部用户启用 MFA。
+func (s *TenantService) requireMFA(ctx context.Context, tenantID string) bool {
+	return s.settings(ctx, tenantID).RequireMFA
+}
+
+// passwordConfig 返回应用租户覆盖后的密码策略。
+func (s *TenantService) passwordConfig(ctx context.Context, tenantID string) PasswordConfig {
+	cfg := s.m.cfg.Password
+	if n := s.settings(ctx, tenantID).PasswordMinLength; n > 0 {
+		cfg.MinLength = n
+	}
+	return cfg
+}
+
+// tokenTTLs 返回应用租户覆盖后的访问令牌与会话有效期。
+func (s *TenantService) tokenTTLs(ctx context.Context, tenantID string) (access, refresh time.Duration) {
+	access, refresh = s.m.cfg.AccessTokenTTL, s.m.cfg.RefreshTokenTTL
+	settings := s.settings(ctx, tenantID)
+	if settings.AccessTokenTTL > 0 {
+		access = time.Duration(settings.AccessTokenTTL) * time.Second
+	}
+	if settings.RefreshTokenTTL > 0 {
+		refresh = time.Duration(settings.RefreshTokenTTL) * time.Second
+	}
+	return access, refresh
+}
+
+// admitUser 在创建用户的事务内校验租户状态与用户配额。
+// 配额非 0 时锁定租户行，使同一租户的并发注册串行化，避免超额。
+func (s *TenantService) admitUser(tx *gorm.DB, tenantID string) error {
+	var tenants []Tenant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", tenantID).Limit(1).Find(&tenants).Error; err != nil {
+		return fmt.Errorf("load tenant: %w", err)
+	}
+	if len(tenants) == 0 {
+		return nil
+	}
+	tenant := tenants[0]
+	if tenant.Status != TenantStatusActive {
+		return accountError(ErrPermissionDenied, "租户不可用")
+	}
+	if tenant.MaxUsers <= 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&User{}).Where("tenant_id = ? AND status <> ?", tenantID, UserStatusDeleted).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= int64(tenant.MaxUsers) {
+		return accountError(ErrPermissionDenied, fmt.Sprintf("租户用户数已达上限（%d）", tenant.MaxUsers))
+	}
+	return nil
+}
+
+// settings 返回租户覆盖配置；查询失败时记录日志并沿用全局配置。
+func (s *TenantService) settings(ctx context.Context, tenantID string) TenantSettings {
+	entry, err := s.lookup(ctx, tenantID)
+	if err != nil {
+		gaia.WarnF("[account] load tenant %s settings failed: %v", tenantID, err)
+		return TenantSettings{}
+	}
+	return entry.settings
+}
+
+func (s *TenantService) lookup(ctx context.Context, tenantID string) (tenantCacheEntry, error) {
+	tenantID = s.m.tenantID(tenantID)
+	now := time.Now()
+	s.mu.RLock()
+	entry, ok := s.entries[tenantID]
+	s.mu.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry, nil
+	}
+	var tenants []Tenant
+	if err := s.m.db.WithContext(ctx).Where("id = ?", tenantID).Limit(1).Find(&tenants).Error; err != nil {
+		recordDBError(ctx)
+		return tenantCacheEntry{}, fmt.Errorf("load tenant: %w", err)
+	}
+	entry = tenantCacheEntry{expires: now.Add(tenantCacheTTL)}
+	if len(tenants) > 0 {
+		entry.tenant = &tenants[0]
+		entry.settings = decodeTenantSettings(tenants[0].Settings)
+	}
+	s.mu.Lock()
+	if s.entries == nil {
+		s.entries = map[string]tenantCacheEntry{}
+	}
+	s.entries[tenantID] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
+
+// invalidate 清除本进程内租户及其域名的缓存。
+func (s *TenantService) invalidate(tenantID string, domains ...string) {
+	s.mu.Lock()
+	delete(s.entries, tenantID)
+	for _, d := range domains {
+		delete(s.domains, d)
+	}
+	s.mu.Unlock()
+}
+
+func (s *TenantService) load(db *gorm.DB, tenantID string) (*Tenant, error) {
+	var tenant Tenant
+	if err := db.Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, accountError(ErrInvalidArgument, "租户不存在")
+		}
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// seedRoles 把默认租户的内置角色（含注册时分配的 "user"）复制到新租户，不复制权限分配。
+func (s *TenantService) seedRoles(tx *gorm.DB, tenantID string) error {
+	var roles []Role
+	if err := tx.Where("tenant_id = ? AND is_system = ?", s.m.cfg.DefaultTenantID, true).Find(&roles).Error; err != nil {
+		return err
+	}
+	for _, role := range roles {
+		role.ID = newID()
+		role.TenantID = tenantID
+		role.Version = 1
+		role.CreatedAt, role.UpdatedAt = time.Time{}, time.Time{}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&role).Error; err != nil {
+			return fmt.Errorf("seed tenant role %s: %w", role.Code, err)
+		}
+	}
+	return nil
+}
+
+func (s *TenantService) addDomainTx(tx *gorm.DB, tenantID, domain string) error {
+	var owner []string
+	if err := tx.Model(&TenantDomain{}).Where("domain = ?", domain).Limit(1).Pluck("tenant_id", &owner).Error; err != nil {
+		return err
+	}
+	if len(owner) > 0 {
+		if owner[0] == tenantID {
+			return nil
+		}
+		return accountError(ErrIdentifierExists, "域名已被其他租户绑定: "+domain)
+	}
+	if err := tx.Create(&TenantDomain{Domain: domain, TenantID: tenantID}).Error; err != nil {
+		return fmt.Errorf("add tenant domain: %w", err)
+	}
+	return nil
+}
+
+// revokeSessions 吊销租户内全部活跃会话与刷新令牌。
+func (s *TenantService) revokeSessions(ctx context.Context, tenantID string) error {
+	var sessionIDs []string
+	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Session{}).Where("tenant_id = ? AND status = ?", tenantID, SessionActive).Pluck("id", &sessionIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Session{}).Where("tenant_id = ? AND status = ?", tenantID, SessionActive).Updates(map[string]any{
+			"status":     SessionRevoked,
+			"revoked_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		sessions := tx.Model(&Session{}).Select("id").Where("tenant_id = ?", tenantID)
+		return tx.Model(&RefreshToken{}).Where("session_id IN (?) AND status = ?", sessions, RefreshActive).Update("status", RefreshRevoked).Error
+	})
+	if err != nil {
+		return fmt.Errorf("revoke tenant sessions: %w", err)
+	}
+	s.m.auth.invalidatePrincipalCaches(ctx, sessionIDs)
+	return nil
+}
+
+func (s *TenantService) encodeSettings(settings TenantSettings) (string, error) {
+	if n := settings.PasswordMinLength; n != 0 && (n < 8 || n > s.m.cfg.Password.MaxLength) {
+		return "", accountError(ErrInvalidArgument, fmt.Sprintf("password_min_length 取值范围为 8 ~ %d", s.m.cfg.Password.MaxLength))
+	}
+	for _, method := range settings.AllowedLoginMethods {
+		if !contains(loginMethods, method) {
+			return "", accountError(ErrInvalidArgument, "未知的登录方式: "+method)
+		}
+	}
+	if settings.AccessTokenTTL < 0 || settings.RefreshTokenTTL < 0 {
+		return "", accountError(ErrInvalidArgument, "令牌有效期不能为负数")
+	}
+	if time.Duration(settings.AccessTokenTTL)*time.Second > s.m.cfg.AccessTokenTTL {
+		return "", accountError(ErrInvalidArgument, fmt.Sprintf("access_token_ttl 不能超过全局配置 %d 秒", int(s.m.cfg.AccessTokenTTL.Seconds())))
+	}
+	for _, provider := range settings.AllowedOAuthProviders {
+		if _, ok := s.m.cfg.OAuthProviders[provider]; !ok {
+			return "", accountError(ErrInvalidArgument, "未配置的 OAuth 提供商: "+provider)
+		}
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("marshal tenant settings: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodeTenantSettings(raw string) TenantSettings {
+	var settings TenantSettings
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &settings); err != nil {
+			gaia.WarnF("[account] decode tenant settings failed: %v", err)
+		}
+	}
+	return settings
+}
+
+func (s *TenantService) toInfos(ctx context.Context, tenants []Tenant) ([]TenantInfo, error) {
+	items := make([]TenantInfo, 0, len(tenants))
+	if len(tenants) == 0 {
+		return items, nil
+	}
+	ids := make([]string, len(tenants))
+	for i, t := range tenants {
+		ids[i] = t.ID
+	}
+	db := s.m.db.WithContext(ctx)
+	var counts []struct {
+		TenantID string
+		Total    int64
+	}
+	if err := db.Model(&User{}).Select("tenant_id, COUNT(*) AS total").
+		Where("tenant_id IN ? AND status <> ?", ids, UserStatusDeleted).Group("tenant_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	var domains []TenantDomain
+	if err := db.Where("tenant_id IN ?", ids).Order("domain").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range tenants {
+		info := TenantInfo{
+			ID:            t.ID,
+			Name:          t.Name,
+			Status:        t.Status,
+			MaxUsers:      t.MaxUsers,
+			Settings:      decodeTenantSettings(t.Settings),
+			Domains:       []string{},
+			SuspendReason: t.SuspendReason,
+			SuspendedAt:   t.SuspendedAt,
+			CreatedAt:     t.CreatedAt,
+			UpdatedAt:     t.UpdatedAt,
+		}
+		for _, c := range counts {
+			if c.TenantID == t.ID {
+				info.UserCount = c.Total
+			}
+		}
+		for _, d := range domains {
+			if d.TenantID == t.ID {
+				info.Domains = append(info.Domains, d.Domain)
+			}
+		}
+		items = append(items, info)
+	}
+	return items, nil
+}
+
+// normalizeTenantDomain 去掉端口与末尾的点并转为小写。
+func normalizeTenantDomain(host string) (string, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 255 || !strings.Contains(host, ".") || net.ParseIP(host) != nil {
+		return "", accountError(ErrInvalidArgument, "无效的域名: "+host)
+	}
+	for _, r := range host {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return "", accountError(ErrInvalidArgument, "无效的域名: "+host)
+		}
+	}
+	return host, nil
+}
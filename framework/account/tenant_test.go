@@ -0,0 +1,162 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xxzhwl/gaia/errwrap"
+)
+
+func TestTenantLifecycle(t *testing.T) {
+	m, bob := newPolicyTestManager(t)
+	ctx := context.Background()
+
+	if _, err := m.Tenants().Create(ctx, CreateTenantRequest{ID: "Acme Corp"}); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("expected invalid tenant id to be rejected, got %v", err)
+	}
+	if _, err := m.Tenants().Create(ctx, CreateTenantRequest{ID: "acme", Settings: TenantSettings{AccessTokenTTL: 3600}}); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("expected access token ttl above the global value to be rejected, got %v", err)
+	}
+	info, err := m.Tenants().Create(ctx, CreateTenantRequest{
+		ID:       "acme",
+		Name:     "Acme",
+		MaxUsers: 2,
+		Settings: TenantSettings{
+			PasswordMinLength:   16,
+			AllowedLoginMethods: []string{LoginMethodPassword},
+			AccessTokenTTL:      300,
+			RefreshTokenTTL:     3600,
+		},
+		Domains: []string{"Login.Acme.com."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != TenantStatusActive || len(info.Domains) != 1 || info.Domains[0] != "login.acme.com" {
+		t.Fatalf("unexpected tenant: %+v", info)
+	}
+	if _, err := m.Tenants().Create(ctx, CreateTenantRequest{ID: "other", Domains: []string{"login.acme.com"}}); errwrap.GetCode(err) != ErrIdentifierExists {
+		t.Fatalf("expected domain to be unique across tenants, got %v", err)
+	}
+
+	// 自定义域名解析
+	if id, _ := m.Tenants().ResolveHost(ctx, "LOGIN.acme.com:8443"); id != "acme" {
+		t.Fatalf("expected host to resolve to acme, got %q", id)
+	}
+	if id, _ := m.Tenants().ResolveHost(ctx, "www.example.com"); id != "" {
+		t.Fatalf("expected unknown host to resolve to nothing, got %q", id)
+	}
+
+	// 租户密码策略与用户配额
+	register := func(username, password string) (*AuthResult, error) {
+		return m.Auth().Register(ctx, RegisterRequest{TenantID: "acme", Username: username, Password: password})
+	}
+	if _, err := register("alice", "short-pass-123"); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("expected tenant password length to apply, got %v", err)
+	}
+	alice, err := register("alice", "a-long-enough-passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := register("carol", "another-long-passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := register("dave", "yet-another-long-passphrase"); errwrap.GetCode(err) != ErrPermissionDenied {
+		t.Fatalf("expected seat quota to block the third user, got %v", err)
+	}
+	// 默认租户不受影响
+	if _, err := m.Auth().Register(ctx, RegisterRequest{Username: "erin", Password: "erin-pass-2026"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 租户令牌有效期
+	if ttl := time.Until(alice.ExpiresAt); ttl > 5*time.Minute || ttl < 4*time.Minute {
+		t.Fatalf("expected tenant access token ttl, got %v", ttl)
+	}
+	var session Session
+	if err := m.db.Where("user_id = ?", alice.User.ID).First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ttl := time.Until(session.ExpiresAt); ttl > time.Hour || ttl < 59*time.Minute {
+		t.Fatalf("expected tenant session ttl, got %v", ttl)
+	}
+
+	// 登录方式限制
+	if err := m.Tenants().checkLogin(ctx, "acme", LoginMethodCode); errwrap.GetCode(err) != ErrPermissionDenied {
+		t.Fatalf("expected code login to be disabled, got %v", err)
+	}
+	if _, err := m.Auth().LoginWithVerificationCode(ctx, LoginWithVerificationCodeRequest{TenantID: "acme", Identifier: "alice@acme.com", ChallengeID: "x", Code: "123456"}); errwrap.GetCode(err) != ErrPermissionDenied {
+		t.Fatalf("expected code login to be rejected, got %v", err)
+	}
+
+	// 租户级 MFA 要求：允许登录但提示绑定
+	settings := info.Settings
+	settings.RequireMFA = true
+	if _, err := m.Tenants().Update(ctx, "acme", UpdateTenantRequest{Settings: &settings}); err != nil {
+		t.Fatal(err)
+	}
+	login, err := m.Auth().Login(ctx, LoginRequest{TenantID: "acme", Identifier: "alice", Password: "a-long-enough-passphrase"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !login.MFAEnrollmentRequired {
+		t.Fatal("expected tenant mfa requirement to be reported")
+	}
+	if _, err := m.Auth().Validate(ctx, login.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	// 停用：拒绝令牌校验、登录与注册
+	if err := m.Tenants().Suspend(ctx, "acme", "billing overdue"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Auth().Validate(ctx, login.AccessToken); errwrap.GetCode(err) != ErrInvalidToken {
+		t.Fatalf("expected token of suspended tenant to be rejected, got %v", err)
+	}
+	if _, err := m.Auth().Login(ctx, LoginRequest{TenantID: "acme", Identifier: "alice", Password: "a-long-enough-passphrase"}); errwrap.GetCode(err) != ErrPermissionDenied {
+		t.Fatalf("expected login to suspended tenant to be rejected, got %v", err)
+	}
+	var active int64
+	m.db.Model(&Session{}).Where("tenant_id = ? AND status = ?", "acme", SessionActive).Count(&active)
+	if active != 0 {
+		t.Fatalf("expected sessions to be revoked, %d still active", active)
+	}
+	if err := m.Tenants().Resume(ctx, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Auth().Login(ctx, LoginRequest{TenantID: "acme", Identifier: "alice", Password: "a-long-enough-passphrase"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除：级联清理租户数据，保留审计，默认租户不可删除
+	if err := m.Tenants().Delete(ctx, "default"); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("expected default tenant deletion to be rejected, got %v", err)
+	}
+	if err := m.Tenants().Delete(ctx, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	for _, model := range []any{&User{}, &Credential{}, &Session{}, &UserRole{}, &TenantDomain{}} {
+		var n int64
+		m.db.Model(model).Where("tenant_id = ?", "acme").Count(&n)
+		if n != 0 {
+			t.Fatalf("expected %T rows of acme to be deleted, got %d", model, n)
+		}
+	}
+	if _, err := m.Tenants().Get(ctx, "acme"); errwrap.GetCode(err) != ErrInvalidArgument {
+		t.Fatalf("expected tenant to be gone, got %v", err)
+	}
+	if id, _ := m.Tenants().ResolveHost(ctx, "login.acme.com"); id != "" {
+		t.Fatalf("expected domain to be released, got %q", id)
+	}
+	var audits int64
+	m.db.Model(&AuditLog{}).Where("tenant_id = ?", "acme").Count(&audits)
+	if audits == 0 {
+		t.Fatal("expected audit logs to be retained")
+	}
+	var bobCount int64
+	m.db.Model(&User{}).Where("id = ?", bob.UserID).Count(&bobCount)
+	if bobCount != 1 {
+		t.Fatal("expected users of other tenants to be untouched")
+	}
+}
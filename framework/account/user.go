@@ -119,9 +119,6 @@ func (s *UserService) ChangePassword(ctx context.Context, req ChangePasswordRequ
 			return err
 		}
 	}
-	if err := validatePassword(req.NewPassword, s.m.cfg.Password); err != nil {
-		return err
-	}
 	var sessionIDs []string
 	_ = s.m.db.WithContext(ctx).Model(&Session{}).Where("user_id = ?", req.UserID).Pluck("id", &sessionIDs).Error
 	err := s.m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
@@ -132,6 +129,9 @@ func (s *UserService) ChangePassword(ctx context.Context, req ChangePasswordRequ
 		if !verifyPassword(req.OldPassword, cred.SecretHash) {
 			return accountError(ErrInvalidCredential, "旧密码错误")
 		}
+		if err := validatePassword(req.NewPassword, s.m.tenantSvc.passwordConfig(ctx, cred.TenantID)); err != nil {
+			return err
+		}
 		newHash, err := hashPassword(req.NewPassword, s.m.cfg.Password)
 		if err != nil {
 			return fmt.Errorf("hash new password: %w", err)
@@ -364,7 +364,7 @@ func (s *UserService) CompleteResetPassword(ctx context.Context, req CompleteRes
 		}
 		userID = user.ID
 
-		if err := validatePassword(req.NewPassword, s.m.cfg.Password); err != nil {
+		if err := validatePassword(req.NewPassword, s.m.tenantSvc.passwordConfig(ctx, tenantID)); err != nil {
 			return err
 		}
 		newHash, err := hashPassword(req.NewPassword, s.m.cfg.Password)
@@ -52,12 +52,40 @@ type VerificationChallenge struct {
 	MaxAttempts int        `gorm:"not null;default:5"`
 	ExpiresAt   time.Time  `gorm:"not null;index:idx_acct_vc_expires,priority:2"`
 	ConsumedAt  *time.Time `gorm:"default:null"`
-	CreatedAt   time.Time  `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+	Locale      string     `gorm:"size:16"`
+	// DeliveryStatus 最近一次投递的状态，Provider 为最终成功（或最后尝试）的提供商。
+	DeliveryStatus string    `gorm:"size:16"`
+	Provider       string    `gorm:"size:32"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
 }
 
 func (VerificationChallenge) TableName() string { return "acct_verification_challenges" }
 
+// 验证码投递状态。sent / failed 由发送结果决定，delivered / undelivered 来自提供商回执。
+const (
+	DeliveryStatusSent        = "sent"
+	DeliveryStatusFailed      = "failed"
+	DeliveryStatusDelivered   = "delivered"
+	DeliveryStatusUndelivered = "undelivered"
+)
+
+// VerificationDelivery 验证码的一次投递尝试，回退到下一个提供商时每次尝试各记一条。
+type VerificationDelivery struct {
+	ID                string    `gorm:"size:36;primaryKey"`
+	TenantID          string    `gorm:"size:64;not null"`
+	ChallengeID       string    `gorm:"size:36;not null;index"`
+	Channel           string    `gorm:"size:16;not null"`
+	Provider          string    `gorm:"size:32;not null;index:idx_acct_vd_message,priority:1"`
+	ProviderMessageID string    `gorm:"size:128;index:idx_acct_vd_message,priority:2"`
+	Status            string    `gorm:"size:16;not null"`
+	Error             string    `gorm:"size:512"`
+	CreatedAt         time.Time `gorm:"autoCreateTime;index"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+}
+
+func (VerificationDelivery) TableName() string { return "acct_verification_deliveries" }
+
 type VerificationService struct {
 	m *Manager
 }
@@ -70,6 +98,12 @@ type VerificationConfig struct {
 	SendInterval        time.Duration
 	MaxPerTargetPerHour int
 	MaxPerIPPer10Min    int
+	// Providers 各渠道（email / sms）的发送方，按顺序尝试；Config.NotifyProvider 作为最后的回退。
+	Providers map[string][]VerificationSender
+	// Templates 自定义消息模板，优先于内置模板。
+	Templates []VerificationTemplate
+	// DefaultLocale 请求未指定语言或没有对应语言的模板时使用，默认 zh-CN。
+	DefaultLocale string
 }
 
 func defaultVerificationConfig() VerificationConfig {
@@ -80,6 +114,7 @@ func defaultVerificationConfig() VerificationConfig {
 		SendInterval:        60 * time.Second,
 		MaxPerTargetPerHour: 5,
 		MaxPerIPPer10Min:    20,
+		DefaultLocale:       "zh-CN",
 	}
 }
 
@@ -90,6 +125,7 @@ type SendVerificationRequest struct {
 	Target   string // email address or phone number
 	Purpose  string // register / login / reset_password / bind / mfa
 	IP       string
+	Locale   string // 消息语言，如 zh-CN / en；为空时使用 DefaultLocale
 }
 
 // SendVerificationResult 发送验证码的结果，返回挑战 ID（不含验证码）。
@@ -117,11 +153,9 @@ func (s *VerificationService) Send(ctx context.Context, req SendVerificationRequ
 	if err := s.checkSendRateLimit(ctx, tenantID, req.Channel, req.Target, req.Purpose, vc); err != nil {
 		return nil, err
 	}
-	// Rate limit: per IP
-	if req.IP != "" {
-		if err := s.checkIPRateLimit(ctx, tenantID, req.IP, vc); err != nil {
-			return nil, err
-		}
+	// Rate limit: per target / per IP, shared with RiskService
+	if err := s.m.risk.CheckVerificationSend(ctx, tenantID, targetHash, req.Purpose, req.IP); err != nil {
+		return nil, err
 	}
 
 	code, err := generateCode(vc.CodeLength)
@@ -131,6 +165,10 @@ func (s *VerificationService) Send(ctx context.Context, req SendVerificationRequ
 
 	codeHash := codeHash(code)
 	now := time.Now()
+	locale := req.Locale
+	if locale == "" {
+		locale = vc.DefaultLocale
+	}
 	challenge := &VerificationChallenge{
 		ID:          newID(),
 		TenantID:    tenantID,
@@ -141,20 +179,21 @@ func (s *VerificationService) Send(ctx context.Context, req SendVerificationRequ
 		Attempts:    0,
 		MaxAttempts: vc.MaxAttempts,
 		ExpiresAt:   now.Add(vc.CodeTTL),
+		Locale:      locale,
 	}
 	if err := s.m.db.WithContext(ctx).Create(challenge).Error; err != nil {
 		return nil, fmt.Errorf("create verification challenge: %w", err)
 	}
 
-	if s.m.cfg.NotifyProvider == nil {
+	senders := s.verificationSenders(req.Channel)
+	if len(senders) == 0 {
 		if strings.EqualFold(s.m.cfg.Mode, "production") {
 			return nil, accountError(ErrInternal, "未配置验证码通知提供商")
 		}
-		gaia.WarnF("[account] no NotifyProvider configured, verification challenge created: channel=%s challenge=%s",
+		gaia.WarnF("[account] no verification sender configured, verification challenge created: channel=%s challenge=%s",
 			req.Channel, challenge.ID)
-	} else if err := s.m.cfg.NotifyProvider.Send(ctx, req.Channel, req.Target, code); err != nil {
-		gaia.WarnF("[account] NotifyProvider.Send failed: channel=%s target=%s err=%v", req.Channel, req.Target, err)
-		return nil, accountError(ErrInternal, "验证码发送失败")
+	} else if err := s.deliver(ctx, challenge, senders, req.Target, code); err != nil {
+		return nil, err
 	}
 
 	if m := s.m.metrics; m != nil {
@@ -237,54 +276,17 @@ func (s *VerificationService) checkSendRateLimit(ctx context.Context, tenantID,
 	if err := s.m.cache.Set(ctx, key, time.Now().Add(cfg.SendInterval).Format("15:04:05"), cfg.SendInterval); err != nil {
 		gaia.WarnF("[account] rate limit cache write error: %v", err)
 	}
-
-	// Per-target per-hour limit
-	hourKey := fmt.Sprintf("rate:vh:%s:%s:%s", tenantID, targetHash(target), purpose)
-	count, _, err := s.m.cache.Get(ctx, hourKey)
-	if err == nil && count != "" {
-		// Simple counter approach - we'll check via DB
-	}
-	// Use DB for hourly count as it's more reliable
-	var hourlyCount int64
-	_ = s.m.db.WithContext(ctx).Model(&VerificationChallenge{}).
-		Where("tenant_id = ? AND target_hash = ? AND purpose = ? AND created_at > ?",
-			tenantID, targetHash(target), purpose, time.Now().Add(-1*time.Hour)).
-		Count(&hourlyCount).Error
-	if hourlyCount >= int64(cfg.MaxPerTargetPerHour) {
-		return accountError(ErrRateLimited, "该账号验证码请求过于频繁，请稍后再试")
-	}
 	return nil
 }
 
-func (s *VerificationService) checkIPRateLimit(ctx context.Context, tenantID, ip string, cfg VerificationConfig) error {
-	if s.m.cache == nil || ip == "" {
-		return nil
-	}
-	key := fmt.Sprintf("rate:vip:%s:%s", tenantID, ip)
-	countStr, ok, err := s.m.cache.Get(ctx, key)
-	if err != nil {
-		return nil
-	}
-	var count int
-	if ok {
-		fmt.Sscanf(countStr, "%d", &count)
-	}
-	count++
-	if count > cfg.MaxPerIPPer10Min {
-		return accountError(ErrRateLimited, "请求过于频繁，请稍后再试")
-	}
-	// Use 10-minute TTL; if key doesn't exist yet, set it fresh
-	if !ok {
-		_ = s.m.cache.Set(ctx, key, "1", 10*time.Minute)
-	} else {
-		_ = s.m.cache.Set(ctx, key, fmt.Sprintf("%d", count), 10*time.Minute)
-	}
-	return nil
-}
-
-// CleanupExpired 删除过期的验证挑战。
+// CleanupExpired 删除过期的验证挑战及其投递记录。
 func (s *VerificationService) CleanupExpired(ctx context.Context) error {
-	return s.m.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&VerificationChallenge{}).Error
+	db := s.m.db.WithContext(ctx)
+	if err := db.Where("challenge_id IN (?)", s.m.db.Model(&VerificationChallenge{}).Select("id").Where("expires_at < ?", time.Now())).
+		Delete(&VerificationDelivery{}).Error; err != nil {
+		return err
+	}
+	return db.Where("expires_at < ?", time.Now()).Delete(&VerificationChallenge{}).Error
 }
 
 // generateCode returns a numeric code of the given length.
@@ -0,0 +1,620 @@
+package account
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// WebAuthn 协议解析与校验：最小 CBOR 解码、authenticatorData / COSE 公钥解析、
+// 断言签名校验，以及 none / packed / fido-u2f 三种 attestation 语句的验证。
+
+// authenticatorData 标志位。
+const (
+	authFlagUserPresent    byte = 0x01
+	authFlagUserVerified   byte = 0x04
+	authFlagBackupEligible byte = 0x08
+	authFlagBackupState    byte = 0x10
+	authFlagAttestedData   byte = 0x40
+	authFlagExtensionData  byte = 0x80
+)
+
+// COSE 算法标识。
+const (
+	coseAlgES256 int64 = -7
+	coseAlgEdDSA int64 = -8
+	coseAlgES384 int64 = -35
+	coseAlgES512 int64 = -36
+	coseAlgPS256 int64 = -37
+	coseAlgRS256 int64 = -257
+	coseAlgRS384 int64 = -258
+	coseAlgRS512 int64 = -259
+	coseAlgRS1   int64 = -65535
+)
+
+// Attestation 类型，记录在 PasskeyCredential.AttestationType。
+const (
+	PasskeyAttestationNone  = "none"
+	PasskeyAttestationSelf  = "self"
+	PasskeyAttestationBasic = "basic"
+)
+
+// oidFIDOGenCEAAGUID 证书扩展 id-fido-gen-ce-aaguid。
+var oidFIDOGenCEAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+const cborMaxDepth = 16
+
+// cborDecoder 只支持 WebAuthn 用到的 CBOR 子集（CTAP2 规范编码）：整数、字节串、文本、数组、映射、
+// 简单值与浮点数；不支持不定长编码。整数统一解码为 int64，映射解码为 map[any]any。
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// cborDecode 解码 data 开头的一个 CBOR 数据项，返回值与消耗的字节数。
+func cborDecode(data []byte) (any, int, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.pos, nil
+}
+
+func (d *cborDecoder) readN(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errors.New("cbor: unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *cborDecoder) head() (major byte, arg uint64, info byte, err error) {
+	b, err := d.readN(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), info, nil
+	case info <= 27:
+		raw, err := d.readN(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, c := range raw {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, arg, info, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+}
+
+func (d *cborDecoder) decode(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+	major, arg, info, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		b, err := d.readN(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 3:
+		b, err := d.readN(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4:
+		if arg > uint64(len(d.data)) {
+			return nil, errors.New("cbor: array too long")
+		}
+		out := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case 5:
+		if arg > uint64(len(d.data)) {
+			return nil, errors.New("cbor: map too long")
+		}
+		out := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errors.New("cbor: unsupported map key type")
+			}
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := out[k]; dup {
+				return nil, errors.New("cbor: duplicate map key")
+			}
+			out[k] = v
+		}
+		return out, nil
+	case 6:
+		// 标签只保留内容
+		return d.decode(depth + 1)
+	default:
+		switch {
+		case info == 20:
+			return false, nil
+		case info == 21:
+			return true, nil
+		case info == 22 || info == 23:
+			return nil, nil
+		case info == 25:
+			return float64(halfToFloat(uint16(arg))), nil
+		case info == 26:
+			return float64(math.Float32frombits(uint32(arg))), nil
+		case info == 27:
+			return math.Float64frombits(arg), nil
+		default:
+			return nil, fmt.Errorf("cbor: unsupported simple value %d", arg)
+		}
+	}
+}
+
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		f := float32(frac) / 1024 * float32(math.Pow(2, -14))
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | frac<<13)
+	default:
+		return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+	}
+}
+
+// webauthnClientData clientDataJSON 中校验用到的字段。
+type webauthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// parseWebAuthnClientData 解析并校验 clientDataJSON 的类型与来源，返回其中的挑战。
+func parseWebAuthnClientData(raw []byte, wantType string, origins []string) (*webauthnClientData, error) {
+	var cd webauthnClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, errors.New("clientDataJSON 格式无效")
+	}
+	if cd.Type != wantType {
+		return nil, fmt.Errorf("clientDataJSON.type 应为 %s", wantType)
+	}
+	if cd.CrossOrigin {
+		return nil, errors.New("不允许跨源调用 WebAuthn")
+	}
+	if !contains(origins, strings.TrimSuffix(cd.Origin, "/")) {
+		return nil, fmt.Errorf("来源 %s 不被允许", cd.Origin)
+	}
+	if cd.Challenge == "" {
+		return nil, errors.New("clientDataJSON 缺少 challenge")
+	}
+	return &cd, nil
+}
+
+// authenticatorData 解析后的认证器数据。
+type authenticatorData struct {
+	Raw          []byte
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE_Key 原始 CBOR
+}
+
+func (a *authenticatorData) has(flag byte) bool { return a.Flags&flag != 0 }
+
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("authenticatorData 长度不足")
+	}
+	ad := &authenticatorData{
+		Raw:       raw,
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+	if ad.has(authFlagAttestedData) {
+		if len(rest) < 18 {
+			return nil, errors.New("attestedCredentialData 长度不足")
+		}
+		ad.AAGUID = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if n == 0 || n > 1023 || len(rest) < n {
+			return nil, errors.New("credentialId 长度无效")
+		}
+		ad.CredentialID, rest = rest[:n], rest[n:]
+		_, used, err := cborDecode(rest)
+		if err != nil {
+			return nil, fmt.Errorf("credentialPublicKey: %w", err)
+		}
+		ad.PublicKey, rest = rest[:used], rest[used:]
+	}
+	if ad.has(authFlagExtensionData) {
+		_, used, err := cborDecode(rest)
+		if err != nil {
+			return nil, fmt.Errorf("extensions: %w", err)
+		}
+		rest = rest[used:]
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("authenticatorData 含有多余数据")
+	}
+	return ad, nil
+}
+
+// verifyRP 校验 rpIdHash 与用户在场/验证标志。
+func (a *authenticatorData) verifyRP(rpID string, requireUV bool) error {
+	want := sha256.Sum256([]byte(rpID))
+	if subtle.ConstantTimeCompare(a.RPIDHash, want[:]) != 1 {
+		return errors.New("rpIdHash 不匹配")
+	}
+	if !a.has(authFlagUserPresent) {
+		return errors.New("认证器未确认用户在场")
+	}
+	if requireUV && !a.has(authFlagUserVerified) {
+		return errors.New("认证器未完成用户验证")
+	}
+	return nil
+}
+
+// formatAAGUID 把 16 字节 AAGUID 格式化为 UUID 字符串，全零时返回空串。
+func formatAAGUID(b []byte) string {
+	if len(b) != 16 || bytes.Equal(b, make([]byte, 16)) {
+		return ""
+	}
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// parseCOSEKey 解析 COSE_Key，返回公钥与算法。
+func parseCOSEKey(raw []byte) (crypto.PublicKey, int64, error) {
+	v, used, err := cborDecode(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	if used != len(raw) {
+		return nil, 0, errors.New("COSE key 含有多余数据")
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, 0, errors.New("COSE key 不是映射")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	switch kty {
+	case 2: // EC2
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		var curve elliptic.Curve
+		switch {
+		case crv == 1 && (alg == coseAlgES256 || alg == 0):
+			curve = elliptic.P256()
+		case crv == 2 && alg == coseAlgES384:
+			curve = elliptic.P384()
+		case crv == 3 && alg == coseAlgES512:
+			curve = elliptic.P521()
+		default:
+			return nil, 0, fmt.Errorf("不支持的 EC 曲线 %d / 算法 %d", crv, alg)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, 0, errors.New("EC 公钥坐标长度无效")
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, errors.New("EC 公钥不在曲线上")
+		}
+		return pub, alg, nil
+	case 3: // RSA
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		switch alg {
+		case coseAlgRS256, coseAlgRS384, coseAlgRS512, coseAlgPS256, coseAlgRS1:
+		default:
+			return nil, 0, fmt.Errorf("不支持的 RSA 算法 %d", alg)
+		}
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("RSA 公钥参数无效")
+		}
+		exp := 0
+		for _, c := range e {
+			exp = exp<<8 | int(c)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, alg, nil
+	case 1: // OKP
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || alg != coseAlgEdDSA || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("只支持 Ed25519 OKP 公钥")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	default:
+		return nil, 0, fmt.Errorf("不支持的 COSE 密钥类型 %d", kty)
+	}
+}
+
+// verifyWebAuthnSignature 按 COSE 算法校验签名。
+func verifyWebAuthnSignature(pub crypto.PublicKey, alg int64, data, sig []byte) error {
+	hashFor := func(alg int64) crypto.Hash {
+		switch alg {
+		case coseAlgES384, coseAlgRS384:
+			return crypto.SHA384
+		case coseAlgES512, coseAlgRS512:
+			return crypto.SHA512
+		case coseAlgRS1:
+			return crypto.SHA1
+		default:
+			return crypto.SHA256
+		}
+	}
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if alg != coseAlgES256 && alg != coseAlgES384 && alg != coseAlgES512 {
+			return fmt.Errorf("算法 %d 与 EC 公钥不匹配", alg)
+		}
+		h := hashFor(alg).New()
+		h.Write(data)
+		if !ecdsa.VerifyASN1(k, h.Sum(nil), sig) {
+			return errors.New("签名校验失败")
+		}
+		return nil
+	case *rsa.PublicKey:
+		hash := hashFor(alg)
+		h := hash.New()
+		h.Write(data)
+		var err error
+		switch alg {
+		case coseAlgPS256:
+			err = rsa.VerifyPSS(k, hash, h.Sum(nil), sig, nil)
+		case coseAlgRS256, coseAlgRS384, coseAlgRS512, coseAlgRS1:
+			err = rsa.VerifyPKCS1v15(k, hash, h.Sum(nil), sig)
+		default:
+			return fmt.Errorf("算法 %d 与 RSA 公钥不匹配", alg)
+		}
+		if err != nil {
+			return errors.New("签名校验失败")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if alg != coseAlgEdDSA || !ed25519.Verify(k, data, sig) {
+			return errors.New("签名校验失败")
+		}
+		return nil
+	default:
+		return errors.New("不支持的公钥类型")
+	}
+}
+
+// attestationResult 注册时 attestation 校验的结果。
+type attestationResult struct {
+	AuthData  *authenticatorData
+	PublicKey crypto.PublicKey
+	Alg       int64
+	Format    string
+	Type      string
+}
+
+// verifyAttestationObject 解析 attestationObject，校验 attestation 语句，返回凭证公钥。
+// 只校验语句签名与证书约束，不校验证书链的可信根（需要 FIDO MDS），因此 basic 只表示“由 x5c 证书签名”。
+func verifyAttestationObject(raw, clientDataHash []byte) (*attestationResult, error) {
+	v, used, err := cborDecode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("attestationObject: %w", err)
+	}
+	if used != len(raw) {
+		return nil, errors.New("attestationObject 含有多余数据")
+	}
+	obj, ok := v.(map[any]any)
+	if !ok {
+		return nil, errors.New("attestationObject 不是映射")
+	}
+	format, _ := obj["fmt"].(string)
+	authDataRaw, _ := obj["authData"].([]byte)
+	stmt, ok := obj["attStmt"].(map[any]any)
+	if !ok {
+		return nil, errors.New("attestationObject 缺少 attStmt")
+	}
+	ad, err := parseAuthenticatorData(authDataRaw)
+	if err != nil {
+		return nil, err
+	}
+	if !ad.has(authFlagAttestedData) || ad.PublicKey == nil {
+		return nil, errors.New("authenticatorData 缺少凭证数据")
+	}
+	pub, alg, err := parseCOSEKey(ad.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	res := &attestationResult{AuthData: ad, PublicKey: pub, Alg: alg, Format: format}
+	signed := append(append([]byte(nil), authDataRaw...), clientDataHash...)
+
+	switch format {
+	case "none":
+		if len(stmt) != 0 {
+			return nil, errors.New("none attestation 的 attStmt 必须为空")
+		}
+		res.Type = PasskeyAttestationNone
+	case "packed":
+		stmtAlg, _ := stmt["alg"].(int64)
+		sig, _ := stmt["sig"].([]byte)
+		if len(sig) == 0 {
+			return nil, errors.New("packed attestation 缺少 sig")
+		}
+		if _, ok := stmt["ecdaaKeyId"]; ok {
+			return nil, errors.New("不支持 ECDAA attestation")
+		}
+		x5c, hasX5C := stmt["x5c"].([]any)
+		if !hasX5C {
+			// 自证明：用凭证私钥签名
+			if stmtAlg != alg {
+				return nil, errors.New("packed 自证明的 alg 与凭证公钥不一致")
+			}
+			if err := verifyWebAuthnSignature(pub, alg, signed, sig); err != nil {
+				return nil, fmt.Errorf("packed attestation: %w", err)
+			}
+			res.Type = PasskeyAttestationSelf
+			break
+		}
+		cert, err := attestationCert(x5c)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyWebAuthnSignature(cert.PublicKey, stmtAlg, signed, sig); err != nil {
+			return nil, fmt.Errorf("packed attestation: %w", err)
+		}
+		if err := checkPackedAttestationCert(cert, ad.AAGUID); err != nil {
+			return nil, err
+		}
+		res.Type = PasskeyAttestationBasic
+	case "fido-u2f":
+		x5c, _ := stmt["x5c"].([]any)
+		sig, _ := stmt["sig"].([]byte)
+		if len(x5c) != 1 || len(sig) == 0 {
+			return nil, errors.New("fido-u2f attestation 必须包含一张证书与签名")
+		}
+		cert, err := attestationCert(x5c)
+		if err != nil {
+			return nil, err
+		}
+		certKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+		if !ok || certKey.Curve != elliptic.P256() {
+			return nil, errors.New("fido-u2f 证书必须为 P-256 公钥")
+		}
+		credKey, ok := pub.(*ecdsa.PublicKey)
+		if !ok || credKey.Curve != elliptic.P256() {
+			return nil, errors.New("fido-u2f 凭证必须为 P-256 公钥")
+		}
+		if formatAAGUID(ad.AAGUID) != "" {
+			return nil, errors.New("fido-u2f 的 AAGUID 必须为全零")
+		}
+		pubU2F := make([]byte, 65)
+		pubU2F[0] = 0x04
+		credKey.X.FillBytes(pubU2F[1:33])
+		credKey.Y.FillBytes(pubU2F[33:65])
+		var data []byte
+		data = append(data, 0x00)
+		data = append(data, ad.RPIDHash...)
+		data = append(data, clientDataHash...)
+		data = append(data, ad.CredentialID...)
+		data = append(data, pubU2F...)
+		if err := verifyWebAuthnSignature(certKey, coseAlgES256, data, sig); err != nil {
+			return nil, fmt.Errorf("fido-u2f attestation: %w", err)
+		}
+		res.Type = PasskeyAttestationBasic
+	default:
+		return nil, fmt.Errorf("不支持的 attestation 格式 %q", format)
+	}
+	return res, nil
+}
+
+func attestationCert(x5c []any) (*x509.Certificate, error) {
+	if len(x5c) == 0 {
+		return nil, errors.New("attestation 缺少证书")
+	}
+	der, ok := x5c[0].([]byte)
+	if !ok {
+		return nil, errors.New("attestation 证书格式无效")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("attestation 证书: %w", err)
+	}
+	return cert, nil
+}
+
+// checkPackedAttestationCert 按 WebAuthn §8.2.1 校验 packed attestation 证书。
+func checkPackedAttestationCert(cert *x509.Certificate, aaguid []byte) error {
+	if cert.Version != 3 {
+		return errors.New("attestation 证书必须为 X.509 v3")
+	}
+	if !contains(cert.Subject.OrganizationalUnit, "Authenticator Attestation") ||
+		len(cert.Subject.Country) == 0 || len(cert.Subject.Organization) == 0 || cert.Subject.CommonName == "" {
+		return errors.New("attestation 证书主体不符合要求")
+	}
+	if cert.BasicConstraintsValid && cert.IsCA {
+		return errors.New("attestation 证书不能是 CA 证书")
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidFIDOGenCEAAGUID) {
+			continue
+		}
+		if ext.Critical {
+			return errors.New("AAGUID 扩展不能标记为 critical")
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(ext.Value, &value); err != nil || !bytes.Equal(value, aaguid) {
+			return errors.New("attestation 证书中的 AAGUID 与认证器不一致")
+		}
+	}
+	return nil
+}
+
+// decodeWebAuthnBase64 兼容 base64url（WebAuthn 规范）与标准 base64。
+func decodeWebAuthnBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
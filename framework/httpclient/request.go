@@ -176,6 +176,9 @@ type HttpRequest struct {
 	Logger *logImpl.DefaultLogger
 	Ctx    context.Context
 
+	// signer 非空时每次发送（含重试）前对请求重新签名
+	signer *RequestSigner
+
 	// 仅在 do() 内部使用，供 defer writeOutLog 读取响应头
 	lastRespHeader http.Header
 }
@@ -314,6 +317,12 @@ func (h *HttpRequest) WithHeader(headers http.Header) *HttpRequest {
 	return h
 }
 
+// WithSigner 使用服务间签名规范对请求签名，见 RequestSigner。
+func (h *HttpRequest) WithSigner(signer *RequestSigner) *HttpRequest {
+	h.signer = signer
+	return h
+}
+
 func (h *HttpRequest) AddHeader(key, value string) *HttpRequest {
 	h.Header.Add(key, value)
 	return h
@@ -411,6 +420,12 @@ func (h *HttpRequest) do() (respBody []byte, statusCode int, err error) {
 	} else {
 		h.Header = request.Header
 	}
+	if h.signer != nil {
+		if signErr := h.signer.Sign(request, h.Body); signErr != nil {
+			err = fmt.Errorf("请求签名失败: %w", signErr)
+			return nil, 0, err
+		}
+	}
 	reqHeader = request.Header
 	otelglobal.GetTextMapPropagator().Inject(requestCtx, propagation.HeaderCarrier(request.Header))
 
@@ -0,0 +1,306 @@
+// Package httpclient 服务间请求签名规范与客户端签名器。
+//
+// 签名规范（服务端校验见 framework/server.SignatureVerifier）：
+//
+//	Authorization: GAIA-HMAC-SHA256 Credential=<sysId>/<keyId>, SignedHeaders=<h1;h2;...>, Signature=<hex>
+//	X-Gaia-Date: <unix 秒>
+//	X-Gaia-Nonce: <随机串，时间窗口内不可重复>
+//	X-Gaia-Content-Sha256: <hex(sha256(body))>
+//
+// 规范请求（CanonicalRequest）按行拼接：
+//
+//	METHOD
+//	规范化路径（逐段 URL 编码，空路径为 /）
+//	规范化查询串（按 key、value 排序后 URL 编码）
+//	规范化头（小写名:去首尾空白的值，按名称排序，每行一个）
+//	已签名头列表（小写、排序、分号分隔）
+//	body 的 sha256 十六进制
+//
+// 待签名串 = 算法 \n 时间戳 \n nonce \n hex(sha256(规范请求))，
+// 签名 = hex(HMAC(secret, 待签名串))。x-gaia-date / x-gaia-nonce / x-gaia-content-sha256
+// 三个头必须参与签名，保证时间戳、nonce 与 body 都无法被单独替换。
+//
+// @author wanlizhan
+// @created 2026-10-18
+package httpclient
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xxzhwl/gaia"
+)
+
+const (
+	SignAlgorithmHmacSha256 = "GAIA-HMAC-SHA256"
+	SignAlgorithmHmacSha512 = "GAIA-HMAC-SHA512"
+
+	SignDateHeader        = "X-Gaia-Date"
+	SignNonceHeader       = "X-Gaia-Nonce"
+	SignContentHashHeader = "X-Gaia-Content-Sha256"
+)
+
+// RequiredSignedHeaders 每个签名请求都必须签入的头（小写）。
+var RequiredSignedHeaders = []string{"x-gaia-content-sha256", "x-gaia-date", "x-gaia-nonce"}
+
+// SignAuthorization Authorization 头的结构化表示。
+type SignAuthorization struct {
+	Algorithm     string
+	SysId         string
+	KeyId         string
+	SignedHeaders []string
+	Signature     string
+}
+
+// String 序列化为 Authorization 头的值。
+func (a SignAuthorization) String() string {
+	return fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.Algorithm, a.SysId, a.KeyId, strings.Join(a.SignedHeaders, ";"), a.Signature)
+}
+
+// IsSignAuthorization 判断 Authorization 头是否为本规范的签名（而非旧版 HMAC 或 Bearer）。
+func IsSignAuthorization(header string) bool {
+	return strings.HasPrefix(header, "GAIA-HMAC-")
+}
+
+// ParseSignAuthorization 解析 Authorization 头。
+func ParseSignAuthorization(header string) (*SignAuthorization, error) {
+	algorithm, rest, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || (algorithm != SignAlgorithmHmacSha256 && algorithm != SignAlgorithmHmacSha512) {
+		return nil, errors.New("不支持的签名算法")
+	}
+	auth := &SignAuthorization{Algorithm: algorithm}
+	for _, part := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, errors.New("签名头格式错误")
+		}
+		switch k {
+		case "Credential":
+			sysId, keyId, ok := strings.Cut(v, "/")
+			if !ok || sysId == "" {
+				return nil, errors.New("签名头 Credential 格式错误")
+			}
+			auth.SysId, auth.KeyId = sysId, keyId
+		case "SignedHeaders":
+			auth.SignedHeaders = strings.Split(strings.ToLower(v), ";")
+		case "Signature":
+			auth.Signature = v
+		}
+	}
+	if auth.SysId == "" || auth.Signature == "" || len(auth.SignedHeaders) == 0 {
+		return nil, errors.New("签名头缺少必要字段")
+	}
+	return auth, nil
+}
+
+// CanonicalRequest 构造规范请求。path 为解码后的路径，rawQuery 为原始查询串，
+// header 按名称（大小写不敏感）取值，signedHeaders 为小写头名。
+func CanonicalRequest(method, path, rawQuery string, header func(name string) string,
+	signedHeaders []string, bodyHash string) string {
+	names := normalizeSignedHeaders(signedHeaders)
+	var sb strings.Builder
+	sb.WriteString(strings.ToUpper(method))
+	sb.WriteByte('\n')
+	sb.WriteString(canonicalPath(path))
+	sb.WriteByte('\n')
+	sb.WriteString(canonicalQuery(rawQuery))
+	sb.WriteByte('\n')
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte(':')
+		sb.WriteString(strings.Join(strings.Fields(header(name)), " "))
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(strings.Join(names, ";"))
+	sb.WriteByte('\n')
+	sb.WriteString(bodyHash)
+	return sb.String()
+}
+
+// StringToSign 构造待签名串。
+func StringToSign(algorithm, timestamp, nonce, canonicalRequest string) string {
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	return algorithm + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(sum[:])
+}
+
+// ComputeSignature 按算法计算签名，返回十六进制串。
+func ComputeSignature(algorithm string, secret []byte, stringToSign string) (string, error) {
+	var h func() hash.Hash
+	switch algorithm {
+	case SignAlgorithmHmacSha256:
+		h = sha256.New
+	case SignAlgorithmHmacSha512:
+		h = sha512.New
+	default:
+		return "", errors.New("不支持的签名算法")
+	}
+	mac := hmac.New(h, secret)
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// BodySha256 返回 body 的 sha256 十六进制串。
+func BodySha256(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeSignedHeaders(headers []string) []string {
+	seen := make(map[string]struct{}, len(headers))
+	out := make([]string, 0, len(headers))
+	for _, h := range headers {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func canonicalPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, _ := url.ParseQuery(rawQuery)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(values))
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// RequestSigner 客户端签名器：持有本系统的凭证，为出站请求生成签名头。
+type RequestSigner struct {
+	SysId     string
+	KeyId     string
+	Secret    string
+	Algorithm string
+	// ExtraHeaders 额外参与签名的头（host / content-type 默认签入，必签头无需列出）。
+	ExtraHeaders []string
+
+	now func() time.Time
+}
+
+// NewRequestSigner 创建签名器，默认算法 GAIA-HMAC-SHA256。
+// keyId 用于服务端在密钥轮换期间定位具体密钥，可为空（对应 sys_auth 中 key_id 为空的行）。
+func NewRequestSigner(sysId, keyId, secret string) *RequestSigner {
+	return &RequestSigner{SysId: sysId, KeyId: keyId, Secret: secret, Algorithm: SignAlgorithmHmacSha256, now: time.Now}
+}
+
+// NewRequestSignerFromConf 从配置创建签名器：
+//   - <schema>.SysId / <schema>.KeyId / <schema>.Secret
+//   - <schema>.Algorithm: 默认 GAIA-HMAC-SHA256
+func NewRequestSignerFromConf(schema string) (*RequestSigner, error) {
+	sysId := gaia.GetSafeConfString(schema + ".SysId")
+	secret := gaia.GetSafeConfString(schema + ".Secret")
+	if sysId == "" || secret == "" {
+		return nil, fmt.Errorf("签名配置 %s.SysId/%s.Secret 未配置", schema, schema)
+	}
+	s := NewRequestSigner(sysId, gaia.GetSafeConfString(schema+".KeyId"), secret)
+	s.Algorithm = gaia.GetSafeConfStringWithDefault(schema+".Algorithm", SignAlgorithmHmacSha256)
+	return s, nil
+}
+
+// Sign 为 req 写入签名相关头，body 须与实际发送的 body 一致。
+// 每次调用都会生成新的时间戳与 nonce，重试时需重新签名。
+func (s *RequestSigner) Sign(req *http.Request, body []byte) error {
+	if s == nil || s.SysId == "" || s.Secret == "" {
+		return errors.New("签名器未配置 SysId/Secret")
+	}
+	algorithm := s.Algorithm
+	if algorithm == "" {
+		algorithm = SignAlgorithmHmacSha256
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	nonce, err := newSignNonce()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	bodyHash := BodySha256(body)
+
+	req.Header.Set(SignDateHeader, timestamp)
+	req.Header.Set(SignNonceHeader, nonce)
+	req.Header.Set(SignContentHashHeader, bodyHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headerOf := func(name string) string {
+		if name == "host" {
+			return host
+		}
+		return req.Header.Get(name)
+	}
+
+	signed := append([]string{"host"}, RequiredSignedHeaders...)
+	if req.Header.Get("Content-Type") != "" {
+		signed = append(signed, "content-type")
+	}
+	signed = normalizeSignedHeaders(append(signed, s.ExtraHeaders...))
+
+	canonical := CanonicalRequest(req.Method, req.URL.Path, req.URL.RawQuery, headerOf, signed, bodyHash)
+	sig, err := ComputeSignature(algorithm, []byte(s.Secret), StringToSign(algorithm, timestamp, nonce, canonical))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", SignAuthorization{
+		Algorithm:     algorithm,
+		SysId:         s.SysId,
+		KeyId:         s.KeyId,
+		SignedHeaders: signed,
+		Signature:     sig,
+	}.String())
+	return nil
+}
+
+func newSignNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
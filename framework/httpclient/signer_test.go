@@ -0,0 +1,117 @@
+// signer_test.go 验证：
+//  1. 规范请求的查询串按 key、value 排序并统一编码，与原始顺序无关
+//  2. 空 body 的摘要为空串的 sha256，空路径规范化为 /
+//  3. 已签名头去重、转小写、排序，头值折叠空白，未签入的头不影响签名
+//  4. Sign 签入 host、必签头与 content-type，并可被 ParseSignAuthorization 还原
+//
+// @author wanlizhan
+// @created 2026-10-19
+package httpclient
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const emptyBodySha256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestCanonicalRequest_QueryOrdering(t *testing.T) {
+	header := func(string) string { return "" }
+	a := CanonicalRequest("get", "/v1/items", "b=2&a=3&a=1&c=x%20y", header, nil, emptyBodySha256)
+	b := CanonicalRequest("GET", "/v1/items", "c=x+y&a=1&b=2&a=3", header, nil, emptyBodySha256)
+	if a != b {
+		t.Fatalf("查询串顺序不应影响规范请求:\n%s\n---\n%s", a, b)
+	}
+	lines := strings.Split(a, "\n")
+	if lines[0] != "GET" || lines[2] != "a=1&a=3&b=2&c=x+y" {
+		t.Fatalf("规范请求错误: %q", lines)
+	}
+	if got := canonicalQuery(""); got != "" {
+		t.Fatalf("空查询串应为空, 实际 %q", got)
+	}
+}
+
+func TestCanonicalRequest_EmptyBodyAndPath(t *testing.T) {
+	if got := BodySha256(nil); got != emptyBodySha256 {
+		t.Fatalf("空 body 摘要错误: %s", got)
+	}
+	if BodySha256([]byte{}) != BodySha256(nil) {
+		t.Fatalf("nil 与空切片的摘要应一致")
+	}
+	canonical := CanonicalRequest("POST", "", "", func(string) string { return "" }, nil, BodySha256(nil))
+	want := "POST\n/\n\n\n" + emptyBodySha256
+	if canonical != want {
+		t.Fatalf("空路径与空 body 的规范请求错误:\n%q\n期望\n%q", canonical, want)
+	}
+	if got := canonicalPath("/a b/c"); got != "/a%20b/c" {
+		t.Fatalf("路径应逐段编码, 实际 %s", got)
+	}
+}
+
+func TestCanonicalRequest_SignedHeaders(t *testing.T) {
+	values := map[string]string{"host": "svc.local", "x-gaia-date": "1700000000", "x-trace": "  a   b "}
+	header := func(name string) string { return values[name] }
+	canonical := CanonicalRequest("GET", "/", "", header, []string{"X-Trace", "host", "x-gaia-date", "HOST", " "}, emptyBodySha256)
+	want := "GET\n/\n\n" +
+		"host:svc.local\n" +
+		"x-gaia-date:1700000000\n" +
+		"x-trace:a b\n" +
+		"host;x-gaia-date;x-trace\n" +
+		emptyBodySha256
+	if canonical != want {
+		t.Fatalf("已签名头规范化错误:\n%q\n期望\n%q", canonical, want)
+	}
+
+	// 未签入的头变化不影响规范请求，签入的头变化必须影响
+	values["x-other"] = "changed"
+	if got := CanonicalRequest("GET", "/", "", header, []string{"host", "x-gaia-date", "x-trace"}, emptyBodySha256); got != want {
+		t.Fatalf("未签入的头不应影响规范请求")
+	}
+	values["x-trace"] = "c"
+	if got := CanonicalRequest("GET", "/", "", header, []string{"host", "x-gaia-date", "x-trace"}, emptyBodySha256); got == want {
+		t.Fatalf("签入的头变化应改变规范请求")
+	}
+}
+
+func TestRequestSigner_Sign(t *testing.T) {
+	s := NewRequestSigner("ord", "k1", "secret")
+	s.now = func() time.Time { return time.Unix(1700000000, 0) }
+	body := []byte(`{"id":1}`)
+	req, _ := http.NewRequest(http.MethodPost, "http://svc.local/v1/orders?b=2&a=1", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if err := s.Sign(req, body); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get(SignDateHeader) != "1700000000" || req.Header.Get(SignContentHashHeader) != BodySha256(body) ||
+		req.Header.Get(SignNonceHeader) == "" {
+		t.Fatalf("签名头缺失: %v", req.Header)
+	}
+
+	auth, err := ParseSignAuthorization(req.Header.Get("Authorization"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.SysId != "ord" || auth.KeyId != "k1" || auth.Algorithm != SignAlgorithmHmacSha256 {
+		t.Fatalf("Authorization 解析错误: %+v", auth)
+	}
+	if got := strings.Join(auth.SignedHeaders, ";"); got != "content-type;host;x-gaia-content-sha256;x-gaia-date;x-gaia-nonce" {
+		t.Fatalf("签入的头错误: %s", got)
+	}
+
+	// 按规范独立重算签名，应与签名器一致
+	headerOf := func(name string) string {
+		if name == "host" {
+			return req.URL.Host
+		}
+		return req.Header.Get(name)
+	}
+	canonical := CanonicalRequest(req.Method, req.URL.Path, req.URL.RawQuery, headerOf, auth.SignedHeaders, BodySha256(body))
+	sig, err := ComputeSignature(auth.Algorithm, []byte("secret"),
+		StringToSign(auth.Algorithm, req.Header.Get(SignDateHeader), req.Header.Get(SignNonceHeader), canonical))
+	if err != nil || sig != auth.Signature {
+		t.Fatalf("签名不一致: %s != %s (%v)", sig, auth.Signature, err)
+	}
+}
@@ -22,6 +22,8 @@ type AsyncTaskLogModel struct {
 //   - "fail"     执行失败（已计入 RetryCount）
 //   - "retry"    准备重试
 //   - "drop"     超过最大重试次数被丢弃 / 进入死信
+//   - "cancel"   执行中被取消
+//   - "timeout"  执行超时
 //
 // Payload 仅在 enqueue / fail 时建议带；高频 run/success 不建议写入以免 ES 文档膨胀。
 type AsyncTaskLogBaseModel struct {
@@ -4,13 +4,13 @@
 package server
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"hash"
 	"strconv"
@@ -23,86 +23,81 @@ import (
 )
 
 type SysAuthModel struct {
-	Id         int64
-	SysId      string
-	SysKey     string
-	Enable     bool
-	Duty       string
+	Id     int64
+	SysId  string
+	KeyId  string
+	SysKey string
+	Enable bool
+	Duty   string
+	// ExpireTime 为空表示长期有效；密钥轮换时给旧密钥设置过期时间作为过渡期
+	ExpireTime *time.Time
 	CreateTime time.Time
 	UpdateTime time.Time
 }
 
-func SysAuth(c *app.RequestContext) {
-	path := c.Request.RequestURI()
-	auth := c.GetHeader("Authorization")
-	method := c.Request.Method()
-	if err := resolveAuth(string(auth), string(path), string(method)); err != nil {
+// SysAuth 按默认配置校验服务间调用签名，失败时中断请求并输出错误，签名规范见 framework/httpclient/signer.go。
+// 通过后可用 c.GetString(SysIdKey) 取调用方系统 ID；ctx 为请求上下文，nonce 校验随请求取消。
+// 作为路由中间件挂载请使用 DefaultSignatureVerifier().Middleware()。
+func SysAuth(ctx context.Context, c *app.RequestContext) {
+	sysId, err := DefaultSignatureVerifier().Verify(ctx, c)
+	if err != nil {
 		c.Abort()
 		NewRequest(c).resp(nil, err)
 		return
 	}
+	c.Set(SysIdKey, sysId)
 }
 
-// 系统ID(三位)算法256|512(三位)之后为秒级时间戳后面加_签名
-func resolveAuth(auth, path, method string) error {
+// verifyLegacy 校验旧版签名：系统ID(三位)算法256|512(三位)之后为秒级时间戳后面加_签名。
+// 旧版只签 method+path+时间戳，不含 body 与 nonce，仅为迁移期保留。
+func (v *SignatureVerifier) verifyLegacy(auth, path, method string) (string, error) {
 	split := strings.Split(auth, "_")
 	if len(split) != 2 {
-		return errors.New("签名校验失败")
+		return "", ErrSignatureInvalid
 	}
 
 	pre, sig := split[0], split[1]
 	if len(pre) < 6 {
-		return errors.New("签名校验失败")
+		return "", ErrSignatureInvalid
 	}
-	currentTimeStamp := time.Now().Unix()
 	systemId, cryMethod, reqTimeStampStr := pre[0:3], pre[3:6], pre[6:]
 
-	//根据系统id获取对应的系统密钥
-	key, err := getSystemKeyById(systemId)
+	reqTimeStamp, err := strconv.ParseInt(reqTimeStampStr, 10, 64)
 	if err != nil {
-		return err
+		return "", ErrSignatureInvalid
 	}
-	if len(key) == 0 {
-		return errors.New("签名校验失败")
-	}
-
-	// 使用配置而不是硬编码时间
-	var allowedTimeWindow = gaia.GetSafeConfInt64("Auth.AllowedTimeWindow")
-	if allowedTimeWindow == 0 {
-		allowedTimeWindow = 60 // 默认1分钟
+	now := v.now()
+	if absInt64(now.Unix()-reqTimeStamp) > int64(v.Window/time.Second) {
+		// 区分过期与时间戳异常，便于排查（仍统一返回"签名已过期"，避免泄漏服务器时间方向）
+		return "", ErrSignatureExpired
 	}
 
-	reqTimeStamp, err := strconv.ParseInt(reqTimeStampStr, 10, 64)
+	//根据系统id获取对应的系统密钥，轮换期间任一有效密钥签名均可通过
+	keys, err := v.Keys.Keys(systemId)
 	if err != nil {
-		return errors.New("签名校验失败")
+		gaia.WarnF("查询系统 %s 签名密钥失败: %v", systemId, err)
+		return "", ErrSignatureInvalid
 	}
-	timeDiff := currentTimeStamp - reqTimeStamp
-	if absInt64(timeDiff) > allowedTimeWindow {
-		// 区分过期与时间戳异常，便于排查（仍统一返回"签名已过期"，避免泄漏服务器时间方向）
-		return errors.New("签名已过期")
-	}
-	res := false
-	// 对密钥和msg进行hmac加密，校验和传入的sig是否一致
 	// 消息中包含时间戳，避免签名被重放后仅替换时间戳即可绕过校验
 	msg := fmt.Sprintf("%s%s%s", method, path, reqTimeStampStr)
-	switch cryMethod {
-	case "256":
-		temp := HmacSha256([]byte(key), msg, false)
-		if subtle.ConstantTimeCompare([]byte(temp), []byte(sig)) == 1 {
-			res = true
-			break
+	for _, key := range keys {
+		if key.Secret == "" || !key.active(now) {
+			continue
+		}
+		var temp string
+		switch cryMethod {
+		case "256":
+			temp = HmacSha256([]byte(key.Secret), msg, false)
+		case "512":
+			temp = HmacSha512([]byte(key.Secret), msg, false)
+		default:
+			return "", ErrSignatureInvalid
 		}
-	case "512":
-		temp := HmacSha512([]byte(key), msg, false)
 		if subtle.ConstantTimeCompare([]byte(temp), []byte(sig)) == 1 {
-			res = true
-			break
+			return systemId, nil
 		}
 	}
-	if !res {
-		return errors.New("签名校验失败")
-	}
-	return nil
+	return "", ErrSignatureInvalid
 }
 
 func absInt64(v int64) int64 {
@@ -112,23 +107,33 @@ func absInt64(v int64) int64 {
 	return v
 }
 
-// getSystemKeyById 从 sys_auth 表中查询 systemId 对应的密钥。
+// DbSysKeyProvider 从 sys_auth 表中查询 systemId 下全部启用的密钥。
 // 高 QPS 接口每次签名校验都会调到这里，因此使用 gaia.CacheLoad 进行 5 分钟缓存。
-// 缓存 TTL 取决于密钥变更频率：项目侧若需要立即生效，可在管理后台改密钥后清缓存。
-func getSystemKeyById(systemId string) (string, error) {
-	return gaia.CacheLoad("sys_auth_key_"+systemId, time.Minute*5, func() (string, error) {
-		db, err := gaia.NewFrameworkMysql()
-		if err != nil {
-			return "", err
-		}
-		sysAuth := &SysAuthModel{}
-		tx := db.GetGormDb().Table("sys_auth").
-			Where("sys_id=? and enable=?", systemId, 1).
-			First(&sysAuth)
-		if tx.Error != nil {
-			return "", tx.Error
-		}
-		return sysAuth.SysKey, nil
+// 缓存 TTL 取决于密钥变更频率：轮换时先新增密钥、等缓存过期后客户端再切换 keyId 即可平滑过渡。
+func DbSysKeyProvider() SysKeyProvider {
+	return SysKeyProviderFunc(func(systemId string) ([]SysKey, error) {
+		return gaia.CacheLoad("sys_auth_keys_"+systemId, time.Minute*5, func() ([]SysKey, error) {
+			db, err := gaia.NewFrameworkMysql()
+			if err != nil {
+				return nil, err
+			}
+			var rows []SysAuthModel
+			tx := db.GetGormDb().Table("sys_auth").
+				Where("sys_id=? and enable=?", systemId, 1).
+				Find(&rows)
+			if tx.Error != nil {
+				return nil, tx.Error
+			}
+			keys := make([]SysKey, 0, len(rows))
+			for _, row := range rows {
+				key := SysKey{KeyId: row.KeyId, Secret: row.SysKey}
+				if row.ExpireTime != nil {
+					key.ExpireTime = *row.ExpireTime
+				}
+				keys = append(keys, key)
+			}
+			return keys, nil
+		})
 	})
 }
 
@@ -0,0 +1,278 @@
+// Package server 跨实例消息扇出（fan-out）后端。
+//
+// WSHub / SSEBroker 这类"向所有连接推送"的能力在多副本部署下都面临同一个问题：
+// 连接分散在不同 Pod 上，本实例只能推给自己持有的连接。FanoutBackend 把
+// 广播消息投递到所有实例，由各实例再推给本地连接。
+//
+// 内置三种实现：
+//
+//	MemoryFanout  进程内（单实例 / 单测），零依赖
+//	RedisFanout   Redis Pub/Sub，低延迟，消息不落盘（实例离线期间的消息会丢）
+//	KafkaFanout   Kafka，不使用 consumer group，每个实例按分区从最新 offset 开始消费
+//
+// 约定：Publish 的消息会被投递给包括自身在内的所有订阅者，是否跳过自身消息由
+// 上层根据消息内的来源实例 ID 自行判断。
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/xxzhwl/gaia"
+	"github.com/xxzhwl/gaia/components/kafka"
+	gaiaredis "github.com/xxzhwl/gaia/components/redis"
+)
+
+// FanoutBackend 跨实例消息总线。
+type FanoutBackend interface {
+	// Publish 向 channel 发布一条消息。
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe 订阅 channel，handler 在后端的接收协程中串行调用；
+	// 返回的 unsubscribe 幂等，调用后不再回调 handler。
+	Subscribe(ctx context.Context, channel string, handler func(payload []byte)) (unsubscribe func(), err error)
+}
+
+// ============================================================================
+// MemoryFanout
+// ============================================================================
+
+// MemoryFanout 进程内扇出，多个 WSHub/SSEBroker 共享同一实例即可模拟多副本。
+type MemoryFanout struct {
+	mu   sync.RWMutex
+	seq  uint64
+	subs map[string]map[uint64]func([]byte)
+}
+
+// NewMemoryFanout 创建进程内扇出后端。
+func NewMemoryFanout() *MemoryFanout {
+	return &MemoryFanout{subs: make(map[string]map[uint64]func([]byte))}
+}
+
+// Publish implements FanoutBackend，同步回调全部订阅者。
+func (m *MemoryFanout) Publish(_ context.Context, channel string, payload []byte) error {
+	m.mu.RLock()
+	handlers := make([]func([]byte), 0, len(m.subs[channel]))
+	for _, h := range m.subs[channel] {
+		handlers = append(handlers, h)
+	}
+	m.mu.RUnlock()
+	for _, h := range handlers {
+		h(payload)
+	}
+	return nil
+}
+
+// Subscribe implements FanoutBackend。
+func (m *MemoryFanout) Subscribe(_ context.Context, channel string, handler func(payload []byte)) (func(), error) {
+	m.mu.Lock()
+	m.seq++
+	id := m.seq
+	if m.subs[channel] == nil {
+		m.subs[channel] = make(map[uint64]func([]byte))
+	}
+	m.subs[channel][id] = handler
+	m.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subs[channel], id)
+			m.mu.Unlock()
+		})
+	}, nil
+}
+
+// ============================================================================
+// RedisFanout
+// ============================================================================
+
+// RedisFanout 基于 Redis Pub/Sub 的扇出后端。
+type RedisFanout struct {
+	cli *redis.Client
+}
+
+// NewRedisFanout 基于 gaia redis 客户端创建扇出后端，共享其连接池。
+func NewRedisFanout(cli *gaiaredis.Client) *RedisFanout {
+	return &RedisFanout{cli: cli.GetCli()}
+}
+
+// Publish implements FanoutBackend。
+func (r *RedisFanout) Publish(ctx context.Context, channel string, payload []byte) error {
+	return r.cli.Publish(ctx, channel, payload).Err()
+}
+
+// Subscribe implements FanoutBackend。
+// 先等待订阅确认再返回，避免订阅建立前发布的消息丢失。
+func (r *RedisFanout) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) (func(), error) {
+	ps := r.cli.Subscribe(ctx, channel)
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range ps.Channel() {
+			func() {
+				defer func() {
+					if rec := recover(); rec != nil {
+						gaia.PanicLog(rec)
+					}
+				}()
+				handler([]byte(msg.Payload))
+			}()
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			_ = ps.Close()
+			<-done
+		})
+	}, nil
+}
+
+// ============================================================================
+// KafkaFanout
+// ============================================================================
+
+// KafkaFanout 基于 Kafka 的扇出后端：所有 channel 复用同一个 topic，以消息 key 区分 channel。
+//
+// Subscribe 不加入 consumer group，而是为 topic 的每个分区创建一个从最新 offset 开始的 reader，
+// 保证每个实例都能收到全量广播，也不会在 broker 上遗留随实例重启不断增加的 group。
+type KafkaFanout struct {
+	brokers  []string
+	topic    string
+	producer *kafka.Producer
+}
+
+// NewKafkaFanout 创建 Kafka 扇出后端。
+func NewKafkaFanout(brokers []string, topic string) *KafkaFanout {
+	return &KafkaFanout{
+		brokers:  brokers,
+		topic:    topic,
+		producer: kafka.NewProducer(brokers, topic),
+	}
+}
+
+// Publish implements FanoutBackend。
+func (k *KafkaFanout) Publish(ctx context.Context, channel string, payload []byte) error {
+	return k.producer.WriteKvMsgWithCtx(ctx, []kafka.KvMsg{{Key: channel, Value: string(payload)}})
+}
+
+// Subscribe implements FanoutBackend。
+func (k *KafkaFanout) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) (func(), error) {
+	if len(k.brokers) == 0 || k.topic == "" {
+		return nil, errors.New("kafka fanout: brokers/topic 未配置")
+	}
+	partitions, err := k.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	readers := make([]*kafkago.Reader, 0, len(partitions))
+	closeReaders := func() {
+		for _, reader := range readers {
+			_ = reader.Close()
+		}
+	}
+	for _, partition := range partitions {
+		reader := kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers:   k.brokers,
+			Topic:     k.topic,
+			Partition: partition,
+			MinBytes:  1,
+			MaxBytes:  5e6,
+			MaxWait:   500 * time.Millisecond,
+		})
+		readers = append(readers, reader)
+		// 未加入 group 时 StartOffset 不生效，需显式定位到最新 offset
+		if err := reader.SetOffset(kafkago.LastOffset); err != nil {
+			closeReaders()
+			return nil, err
+		}
+	}
+
+	subCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	var wg sync.WaitGroup
+	for _, reader := range readers {
+		wg.Add(1)
+		go func(reader *kafkago.Reader) {
+			defer wg.Done()
+			k.consume(subCtx, reader, channel, handler)
+		}(reader)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			wg.Wait()
+			closeReaders()
+		})
+	}, nil
+}
+
+// partitions 查询 topic 的分区列表，依次尝试各个 broker。
+func (k *KafkaFanout) partitions(ctx context.Context) ([]int, error) {
+	var lastErr error
+	for _, broker := range k.brokers {
+		conn, err := kafkago.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		parts, err := conn.ReadPartitions(k.topic)
+		_ = conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ids := make([]int, 0, len(parts))
+		for _, p := range parts {
+			ids = append(ids, p.ID)
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("kafka fanout: topic %s 没有分区", k.topic)
+		}
+		return ids, nil
+	}
+	return nil, fmt.Errorf("kafka fanout: 查询 topic %s 分区失败: %w", k.topic, lastErr)
+}
+
+// consume 读取单个分区，把 key 等于 channel 的消息交给 handler，直到 ctx 取消。
+func (k *KafkaFanout) consume(ctx context.Context, reader *kafkago.Reader, channel string, handler func(payload []byte)) {
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			gaia.WarnF("kafka fanout 读取失败 topic=%s: %v", k.topic, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if string(msg.Key) != channel {
+			continue
+		}
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					gaia.PanicLog(rec)
+				}
+			}()
+			handler(msg.Value)
+		}()
+	}
+}
@@ -68,17 +68,28 @@ type httpServerMetrics struct {
 	InFlight metric.Int64UpDownCounter
 	// ResponseSize 响应字节数直方图，用于流量预估与异常大包检测。
 	ResponseSize metric.Int64Histogram
+	// VersionRequests 版本化路由的请求数，按 version/deprecated/route 维度，
+	// 用于观察旧版本剩余调用量（见 versioning.go）。
+	VersionRequests metric.Int64Counter
+	// ResponseCacheResults 响应缓存结果计数，按 route/result（hit/miss/bypass）维度。
+	ResponseCacheResults metric.Int64Counter
 }
 
 // httpMetricAttr HTTP 指标的标签 key，集中常量化避免拼写漂移。
 var httpMetricAttr = struct {
-	Method attribute.Key
-	Route  attribute.Key
-	Status attribute.Key
+	Method      attribute.Key
+	Route       attribute.Key
+	Status      attribute.Key
+	Version     attribute.Key
+	Deprecated  attribute.Key
+	CacheResult attribute.Key
 }{
-	Method: attribute.Key("http.method"),
-	Route:  attribute.Key("http.route"),
-	Status: attribute.Key("http.status_code"),
+	Method:      attribute.Key("http.method"),
+	Route:       attribute.Key("http.route"),
+	Status:      attribute.Key("http.status_code"),
+	Version:     attribute.Key("api.version"),
+	Deprecated:  attribute.Key("api.deprecated"),
+	CacheResult: attribute.Key("cache.result"),
 }
 
 // initHTTPMetrics 创建 HTTP 指标实例。metrics 系统若未启用，
@@ -121,6 +132,20 @@ func initHTTPMetrics() *httpServerMetrics {
 		otel.Handle(err)
 	}
 
+	m.VersionRequests, err = meter.Int64Counter("http.server.api_version.requests.total",
+		metric.WithDescription("Total versioned API requests by version, deprecation and route"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	m.ResponseCacheResults, err = meter.Int64Counter("http.server.response_cache.total",
+		metric.WithDescription("Response cache lookups by route and result (hit/miss/bypass)"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
 	return m
 }
 
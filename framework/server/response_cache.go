@@ -0,0 +1,519 @@
+// Package server 响应缓存 & ETag 条件请求中间件。
+//
+// # 适用场景
+//
+// 变化缓慢的 GET 接口（通用查询 schema 列表、配置导出、字典等）每次都重新查库/
+// 读文件/序列化，纯属浪费。ResponseCache 按路由缓存完整响应，并补齐 HTTP 协商缓存：
+//
+//  1. 缓存 key：method + path + 排序后的 query + 选定请求头 + 可选的调用方身份
+//     （Authorization 摘要，避免不同用户互相看到对方数据）；
+//  2. 存储：gaia 本地缓存（ristretto，单实例）或 Redis（多副本共享）；
+//  3. 自动为响应生成弱 ETag（W/"sha256 前 16 字节"），命中 If-None-Match 时返回
+//     304 空 body；同时下发 Cache-Control，由调用方决定浏览器/CDN 的缓存时长；
+//  4. 按标签失效：每个标签维护一个版本号，缓存条目记录写入时的标签版本快照，
+//     InvalidateTags 只需把版本号 +1，无需追踪/扫描 key，本地与 Redis 行为一致。
+//
+// # 与其它中间件的关系
+//
+// 本中间件按路由挂载（group.GET(path, rc.Cache(opt), handler)），位于全局 gzip
+// 之内：缓存的是压缩前的 body，ETag 用弱校验（W/），不同 Content-Encoding 下
+// 也能正确比对。只缓存 200 响应；业务写了 Cache-Control: no-store 的响应不缓存。
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/xxzhwl/gaia"
+	gaiaredis "github.com/xxzhwl/gaia/components/redis"
+)
+
+// CachedResponse 一条缓存的响应。
+type CachedResponse struct {
+	Status       int              `json:"status"`
+	ContentType  string           `json:"content_type"`
+	Body         []byte           `json:"body"`
+	ETag         string           `json:"etag"`
+	StoredAt     time.Time        `json:"stored_at"`
+	TagVersions  map[string]int64 `json:"tag_versions,omitempty"`
+	CacheControl string           `json:"cache_control,omitempty"`
+}
+
+// ResponseCacheStore 响应缓存存储。
+type ResponseCacheStore interface {
+	Get(ctx context.Context, key string) (*CachedResponse, bool, error)
+	Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error
+	// TagVersions 批量读取标签当前版本，未失效过的标签版本为 0。
+	TagVersions(ctx context.Context, tags []string) (map[string]int64, error)
+	// BumpTags 把标签版本 +1，使带这些标签的缓存条目全部失效。
+	BumpTags(ctx context.Context, tags ...string) error
+}
+
+// ============================================================================
+// LocalResponseCacheStore
+// ============================================================================
+
+// LocalResponseCacheStore 基于 gaia 本地缓存（ristretto）的存储。
+// 标签版本号放在独立的 map 里而不是 ristretto 中：版本号一旦被淘汰就会归零，
+// 已失效的旧条目会"复活"。
+type LocalResponseCacheStore struct {
+	cache *gaia.Cache
+
+	mu   sync.RWMutex
+	tags map[string]int64
+}
+
+// NewLocalResponseCacheStore 创建本地存储。
+func NewLocalResponseCacheStore() *LocalResponseCacheStore {
+	return &LocalResponseCacheStore{cache: gaia.NewCache(), tags: make(map[string]int64)}
+}
+
+func (l *LocalResponseCacheStore) Get(_ context.Context, key string) (*CachedResponse, bool, error) {
+	if v, ok := l.cache.Get(key).(*CachedResponse); ok {
+		return v, true, nil
+	}
+	return nil, false, nil
+}
+
+func (l *LocalResponseCacheStore) Set(_ context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	l.cache.Set(key, resp, ttl)
+	return nil
+}
+
+func (l *LocalResponseCacheStore) TagVersions(_ context.Context, tags []string) (map[string]int64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make(map[string]int64, len(tags))
+	for _, t := range tags {
+		out[t] = l.tags[t]
+	}
+	return out, nil
+}
+
+func (l *LocalResponseCacheStore) BumpTags(_ context.Context, tags ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, t := range tags {
+		l.tags[t]++
+	}
+	return nil
+}
+
+// ============================================================================
+// RedisResponseCacheStore
+// ============================================================================
+
+// RedisResponseCacheStore 基于 Redis 的存储，多副本共享缓存与标签版本。
+type RedisResponseCacheStore struct {
+	cli       *redis.Client
+	keyPrefix string
+}
+
+// NewRedisResponseCacheStore 创建 Redis 存储，keyPrefix 为空时使用 "gaia:respcache:"。
+func NewRedisResponseCacheStore(cli *gaiaredis.Client, keyPrefix string) *RedisResponseCacheStore {
+	if keyPrefix == "" {
+		keyPrefix = "gaia:respcache:"
+	}
+	return &RedisResponseCacheStore{cli: cli.GetCli(), keyPrefix: keyPrefix}
+}
+
+func (r *RedisResponseCacheStore) Get(ctx context.Context, key string) (*CachedResponse, bool, error) {
+	bs, err := r.cli.Get(ctx, r.keyPrefix+"entry:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	resp := &CachedResponse{}
+	if err := json.Unmarshal(bs, resp); err != nil {
+		return nil, false, err
+	}
+	return resp, true, nil
+}
+
+func (r *RedisResponseCacheStore) Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	bs, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return r.cli.Set(ctx, r.keyPrefix+"entry:"+key, bs, ttl).Err()
+}
+
+func (r *RedisResponseCacheStore) TagVersions(ctx context.Context, tags []string) (map[string]int64, error) {
+	out := make(map[string]int64, len(tags))
+	if len(tags) == 0 {
+		return out, nil
+	}
+	keys := make([]string, len(tags))
+	for i, t := range tags {
+		keys[i] = r.keyPrefix + "tag:" + t
+	}
+	vals, err := r.cli.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			out[tags[i]], _ = strconv.ParseInt(s, 10, 64)
+		} else {
+			out[tags[i]] = 0
+		}
+	}
+	return out, nil
+}
+
+func (r *RedisResponseCacheStore) BumpTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	pipe := r.cli.Pipeline()
+	for _, t := range tags {
+		pipe.Incr(ctx, r.keyPrefix+"tag:"+t)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ============================================================================
+// ResponseCache
+// ============================================================================
+
+// ResponseCacheOption 单条路由的缓存选项。
+type ResponseCacheOption struct {
+	// TTL 服务端缓存时长，默认使用 ResponseCache 的默认 TTL。
+	TTL time.Duration
+	// VaryHeaders 参与缓存 key 的请求头（如 Accept-Language）。
+	VaryHeaders []string
+	// VaryPrincipal 为 true 时按调用方身份区分缓存：默认取鉴权中间件写入 PrincipalKey 的主体
+	// （account 中间件写入租户 + 用户 ID），取不到身份的请求不走缓存。
+	VaryPrincipal bool
+	// PrincipalFunc 自定义调用方身份，VaryPrincipal 为 true 时生效；返回空串时不走缓存。
+	PrincipalFunc func(c *app.RequestContext) string
+	// KeyFunc 完全自定义缓存 key（仍会叠加 method 前缀）。
+	KeyFunc func(c *app.RequestContext) string
+	// Tags 静态失效标签。
+	Tags []string
+	// TagFunc 按请求计算的失效标签（如 "query_schema:" + c.Query("name")）。
+	TagFunc func(c *app.RequestContext) []string
+	// CacheControl 下发给客户端的 Cache-Control；为空时按 TTL 生成，
+	// VaryPrincipal 时为 private，否则为 public。
+	CacheControl string
+}
+
+// PrincipalKey 鉴权中间件写入 RequestContext 的调用方主体（account.Middleware 写入 *account.Principal）。
+const PrincipalKey = "account_principal"
+
+// CacheIdentifier 由 PrincipalKey 下的主体实现，返回区分响应缓存的调用方身份，未登录时为空。
+type CacheIdentifier interface {
+	CacheIdentity() string
+}
+
+// ResponseCache 响应缓存。
+type ResponseCache struct {
+	store      ResponseCacheStore
+	defaultTTL time.Duration
+	metrics    *httpServerMetrics
+}
+
+// NewResponseCache 基于指定存储创建响应缓存，defaultTTL<=0 时默认 60s。
+func NewResponseCache(store ResponseCacheStore, defaultTTL time.Duration) *ResponseCache {
+	if defaultTTL <= 0 {
+		defaultTTL = time.Minute
+	}
+	return &ResponseCache{store: store, defaultTTL: defaultTTL}
+}
+
+// ResponseCache 返回按 <schema>.ResponseCache.* 配置创建的响应缓存（进程内单例）：
+//   - <schema>.ResponseCache.Store:       "local"（默认）| "redis"
+//   - <schema>.ResponseCache.RedisSchema: Redis 配置前缀，默认 "Framework.Redis"
+//   - <schema>.ResponseCache.KeyPrefix:   Redis key 前缀，默认 "gaia:respcache:<schema>:"
+//   - <schema>.ResponseCache.TTLSeconds:  默认缓存时长，默认 60
+func (s *Server) ResponseCache() *ResponseCache {
+	s.respCacheOnce.Do(func() {
+		prefix := s.schema + ".ResponseCache."
+		ttl := time.Duration(gaia.GetSafeConfInt64WithDefault(prefix+"TTLSeconds", 60)) * time.Second
+
+		var store ResponseCacheStore
+		switch gaia.GetSafeConfStringWithDefault(prefix+"Store", "local") {
+		case "redis":
+			cli := gaiaredis.NewClientWithSchema(gaia.GetSafeConfStringWithDefault(prefix+"RedisSchema", "Framework.Redis"))
+			store = NewRedisResponseCacheStore(cli,
+				gaia.GetSafeConfStringWithDefault(prefix+"KeyPrefix", "gaia:respcache:"+s.schema+":"))
+		default:
+			store = NewLocalResponseCacheStore()
+		}
+		s.respCache = NewResponseCache(store, ttl)
+		s.respCache.metrics = s.httpMetrics
+	})
+	return s.respCache
+}
+
+// InvalidateTags 使带指定标签的缓存全部失效。
+func (rc *ResponseCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	return rc.store.BumpTags(ctx, tags...)
+}
+
+// RegisterInvalidateRoute 在 group 下挂载 POST /cache/invalidate，body: {"tags": ["..."]}。
+// 务必传入鉴权中间件，避免被外部随意清缓存。
+func (rc *ResponseCache) RegisterInvalidateRoute(group *route.RouterGroup, middlewares ...app.HandlerFunc) {
+	handlers := append(append([]app.HandlerFunc{}, middlewares...), MakeHandler(func(req Request) (map[string]any, error) {
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		if err := req.BindJson(&body); err != nil {
+			return nil, err
+		}
+		if len(body.Tags) == 0 {
+			return nil, errors.New("tags 不能为空")
+		}
+		if err := rc.InvalidateTags(req.TraceContext, body.Tags...); err != nil {
+			return nil, err
+		}
+		return map[string]any{"invalidated": body.Tags}, nil
+	}))
+	group.POST("/cache/invalidate", handlers...)
+}
+
+// Cache 返回按路由挂载的缓存中间件。
+func (rc *ResponseCache) Cache(opt ResponseCacheOption) app.HandlerFunc {
+	ttl := opt.TTL
+	if ttl <= 0 {
+		ttl = rc.defaultTTL
+	}
+	cacheControl := opt.CacheControl
+	if cacheControl == "" {
+		scope := "public"
+		if opt.VaryPrincipal {
+			scope = "private"
+		}
+		cacheControl = scope + ", max-age=" + strconv.Itoa(int(ttl.Seconds()))
+	}
+
+	return func(c context.Context, ctx *app.RequestContext) {
+		method := string(ctx.Request.Method())
+		if method != http.MethodGet && method != http.MethodHead {
+			ctx.Next(c)
+			return
+		}
+
+		key, ok := rc.buildKey(ctx, opt)
+		if !ok {
+			// 按调用方区分缓存却取不到身份，不能与其他匿名请求共享
+			rc.record(c, ctx, "bypass")
+			ctx.Next(c)
+			return
+		}
+		tags := append(append([]string{}, opt.Tags...), callTagFunc(opt.TagFunc, ctx)...)
+		versions, err := rc.store.TagVersions(c, tags)
+		if err != nil {
+			gaia.WarnF("响应缓存读取标签版本失败，跳过缓存 path=%s: %v", string(ctx.Request.URI().Path()), err)
+			ctx.Next(c)
+			return
+		}
+
+		// 请求显式要求 no-cache 时跳过读取，但仍回填最新结果
+		if !requestNoCache(ctx) {
+			cached, ok, gerr := rc.store.Get(c, key)
+			if gerr != nil {
+				gaia.WarnF("响应缓存读取失败 key=%s: %v", key, gerr)
+			}
+			if ok && sameTagVersions(cached.TagVersions, versions) {
+				rc.record(c, ctx, "hit")
+				writeCachedResponse(ctx, cached)
+				ctx.Abort()
+				return
+			}
+		}
+
+		ctx.Next(c)
+
+		status := ctx.Response.StatusCode()
+		body := ctx.Response.Body()
+		if status != http.StatusOK || len(body) == 0 || isErrorEnvelope(ctx, body) ||
+			strings.Contains(string(ctx.Response.Header.Peek("Cache-Control")), "no-store") {
+			rc.record(c, ctx, "bypass")
+			return
+		}
+
+		resp := &CachedResponse{
+			Status:       status,
+			ContentType:  string(ctx.Response.Header.ContentType()),
+			Body:         append([]byte(nil), body...),
+			ETag:         weakETag(body),
+			StoredAt:     time.Now(),
+			TagVersions:  versions,
+			CacheControl: cacheControl,
+		}
+		if err := rc.store.Set(c, key, resp, ttl); err != nil {
+			gaia.WarnF("响应缓存写入失败 key=%s: %v", key, err)
+		}
+		rc.record(c, ctx, "miss")
+
+		ctx.Response.Header.Set("ETag", resp.ETag)
+		ctx.Response.Header.Set("Cache-Control", cacheControl)
+		ctx.Response.Header.Set("X-Cache", "MISS")
+		if etagMatch(string(ctx.Request.Header.Peek("If-None-Match")), resp.ETag) {
+			ctx.Response.ResetBody()
+			ctx.SetStatusCode(http.StatusNotModified)
+		}
+	}
+}
+
+// isErrorEnvelope 判断 JSON 响应体是否为 code 非 0 的 Response：
+// 业务错误码（>=1000）与 200 一起下发，只看 HTTP 状态码会把错误结果缓存下来。
+func isErrorEnvelope(ctx *app.RequestContext, body []byte) bool {
+	if !strings.Contains(string(ctx.Response.Header.ContentType()), "json") {
+		return false
+	}
+	var envelope struct {
+		Code *int64 `json:"code"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return false
+	}
+	return envelope.Code != nil && *envelope.Code != 0
+}
+
+// buildKey 计算缓存 key：method + path + 排序 query + vary 头 + 调用方身份。
+// VaryPrincipal 时取不到调用方身份返回 false，该请求不走缓存。
+func (rc *ResponseCache) buildKey(ctx *app.RequestContext, opt ResponseCacheOption) (string, bool) {
+	var sb strings.Builder
+	sb.WriteString(string(ctx.Request.Method()))
+	sb.WriteByte(' ')
+	if opt.KeyFunc != nil {
+		sb.WriteString(opt.KeyFunc(ctx))
+	} else {
+		sb.WriteString(string(ctx.Request.URI().Path()))
+		var pairs []string
+		ctx.QueryArgs().VisitAll(func(k, v []byte) {
+			pairs = append(pairs, string(k)+"="+string(v))
+		})
+		sort.Strings(pairs)
+		sb.WriteByte('?')
+		sb.WriteString(strings.Join(pairs, "&"))
+	}
+	for _, h := range opt.VaryHeaders {
+		sb.WriteString("|" + strings.ToLower(h) + "=" + string(ctx.GetHeader(h)))
+	}
+	if opt.VaryPrincipal {
+		principal := requestPrincipal(ctx, opt)
+		if principal == "" {
+			return "", false
+		}
+		sum := sha256.Sum256([]byte(principal))
+		sb.WriteString("|p=" + hex.EncodeToString(sum[:8]))
+	}
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// requestPrincipal 返回区分缓存的调用方身份：优先 PrincipalFunc，否则取 PrincipalKey 下的主体。
+func requestPrincipal(ctx *app.RequestContext, opt ResponseCacheOption) string {
+	if opt.PrincipalFunc != nil {
+		return opt.PrincipalFunc(ctx)
+	}
+	if v, ok := ctx.Get(PrincipalKey); ok {
+		if p, ok := v.(CacheIdentifier); ok {
+			return p.CacheIdentity()
+		}
+	}
+	return ""
+}
+
+func (rc *ResponseCache) record(c context.Context, ctx *app.RequestContext, result string) {
+	if rc.metrics == nil || rc.metrics.ResponseCacheResults == nil {
+		return
+	}
+	route := ctx.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	rc.metrics.ResponseCacheResults.Add(c, 1, metric.WithAttributes(
+		httpMetricAttr.Route.String(route),
+		httpMetricAttr.CacheResult.String(result),
+	))
+}
+
+// writeCachedResponse 回写缓存命中的响应；If-None-Match 匹配时返回 304。
+func writeCachedResponse(ctx *app.RequestContext, cached *CachedResponse) {
+	ctx.Response.Header.Set("ETag", cached.ETag)
+	if cached.CacheControl != "" {
+		ctx.Response.Header.Set("Cache-Control", cached.CacheControl)
+	}
+	ctx.Response.Header.Set("Age", strconv.Itoa(int(time.Since(cached.StoredAt).Seconds())))
+	ctx.Response.Header.Set("X-Cache", "HIT")
+	if etagMatch(string(ctx.Request.Header.Peek("If-None-Match")), cached.ETag) {
+		ctx.SetStatusCode(http.StatusNotModified)
+		return
+	}
+	if cached.ContentType != "" {
+		ctx.Response.Header.SetContentType(cached.ContentType)
+	}
+	ctx.SetStatusCode(cached.Status)
+	ctx.Response.SetBody(cached.Body)
+}
+
+func callTagFunc(fn func(c *app.RequestContext) []string, ctx *app.RequestContext) []string {
+	if fn == nil {
+		return nil
+	}
+	return fn(ctx)
+}
+
+func requestNoCache(ctx *app.RequestContext) bool {
+	cc := strings.ToLower(string(ctx.Request.Header.Peek("Cache-Control")))
+	return strings.Contains(cc, "no-cache") || strings.Contains(cc, "no-store")
+}
+
+func sameTagVersions(cached, current map[string]int64) bool {
+	if len(cached) != len(current) {
+		return false
+	}
+	for tag, v := range current {
+		if cached[tag] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// weakETag 基于 body 内容生成弱 ETag。
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch 按 RFC 9110 弱比较判断 If-None-Match 是否命中。
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+// response_cache_test.go 验证：
+//  1. 首次请求 MISS 回填，二次请求 HIT 且不再进入业务 handler
+//  2. If-None-Match 命中返回 304
+//  3. 按标签失效后重新进入业务 handler
+//  4. VaryPrincipal 时按鉴权主体区分缓存，没有主体的请求不走缓存
+//  5. 非 200 响应不缓存
+//  6. 以 200 下发的业务错误（code >= 1000）不缓存
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/xxzhwl/gaia/errwrap"
+)
+
+// cacheTestPrincipal 模拟鉴权中间件写入 PrincipalKey 的主体
+type cacheTestPrincipal string
+
+func (p cacheTestPrincipal) CacheIdentity() string { return string(p) }
+
+func newCacheTestServer(opt ResponseCacheOption, status int) (*Server, *ResponseCache, *atomic.Int64) {
+	s := newVersioningTestServer()
+	rc := NewResponseCache(NewLocalResponseCacheStore(), 0)
+	calls := &atomic.Int64{}
+	authenticate := func(c context.Context, ctx *app.RequestContext) {
+		if user := string(ctx.GetHeader("X-User")); user != "" {
+			ctx.Set(PrincipalKey, cacheTestPrincipal("default/"+user))
+		}
+	}
+	s.GET("/schemas", authenticate, rc.Cache(opt), func(_ context.Context, ctx *app.RequestContext) {
+		n := calls.Add(1)
+		ctx.JSON(status, map[string]any{"n": n})
+	})
+	return s, rc, calls
+}
+
+func TestResponseCache_HitMissAndETag(t *testing.T) {
+	s, _, calls := newCacheTestServer(ResponseCacheOption{Tags: []string{"schema"}}, http.StatusOK)
+
+	first := ut.PerformRequest(s.Engine, http.MethodGet, "/schemas?b=2&a=1", nil).Result()
+	if got := string(first.Header.Peek("X-Cache")); got != "MISS" {
+		t.Fatalf("首次请求应 MISS，实际 %q", got)
+	}
+	etag := string(first.Header.Peek("ETag"))
+	if etag == "" || string(first.Header.Peek("Cache-Control")) != "public, max-age=60" {
+		t.Fatalf("缺少 ETag/Cache-Control: etag=%q cc=%q", etag, first.Header.Peek("Cache-Control"))
+	}
+
+	// query 顺序不同也命中同一缓存
+	second := ut.PerformRequest(s.Engine, http.MethodGet, "/schemas?a=1&b=2", nil).Result()
+	if got := string(second.Header.Peek("X-Cache")); got != "HIT" {
+		t.Fatalf("二次请求应 HIT，实际 %q", got)
+	}
+	if string(second.Body()) != string(first.Body()) || calls.Load() != 1 {
+		t.Fatalf("命中缓存不应再进入 handler，calls=%d", calls.Load())
+	}
+
+	notModified := ut.PerformRequest(s.Engine, http.MethodGet, "/schemas?a=1&b=2", nil,
+		ut.Header{Key: "If-None-Match", Value: etag}).Result()
+	if notModified.StatusCode() != http.StatusNotModified || len(notModified.Body()) != 0 {
+		t.Fatalf("If-None-Match 命中应返回 304 空 body，实际 %d %q", notModified.StatusCode(), notModified.Body())
+	}
+}
+
+func TestResponseCache_InvalidateTags(t *testing.T) {
+	s, rc, calls := newCacheTestServer(ResponseCacheOption{Tags: []string{"schema"}}, http.StatusOK)
+
+	ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil)
+	ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil)
+	if calls.Load() != 1 {
+		t.Fatalf("失效前应只调用一次 handler，实际 %d", calls.Load())
+	}
+	if err := rc.InvalidateTags(context.Background(), "schema"); err != nil {
+		t.Fatal(err)
+	}
+	resp := ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil).Result()
+	if calls.Load() != 2 || string(resp.Header.Peek("X-Cache")) != "MISS" {
+		t.Fatalf("标签失效后应重新进入 handler，calls=%d", calls.Load())
+	}
+}
+
+func TestResponseCache_VaryPrincipal(t *testing.T) {
+	s, _, calls := newCacheTestServer(ResponseCacheOption{VaryPrincipal: true}, http.StatusOK)
+
+	alice := ut.Header{Key: "X-User", Value: "alice"}
+	bob := ut.Header{Key: "X-User", Value: "bob"}
+	ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil, alice)
+	resp := ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil, bob).Result()
+	if calls.Load() != 2 {
+		t.Fatalf("不同调用方不应共享缓存，calls=%d", calls.Load())
+	}
+	if got := string(resp.Header.Peek("Cache-Control")); got != "private, max-age=60" {
+		t.Fatalf("VaryPrincipal 应下发 private，实际 %q", got)
+	}
+	// 同一主体换了令牌仍命中：身份取自鉴权后的主体而不是 Authorization 头
+	ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil, alice, ut.Header{Key: "Authorization", Value: "Bearer refreshed"})
+	if calls.Load() != 2 {
+		t.Fatalf("同一调用方应命中缓存，calls=%d", calls.Load())
+	}
+
+	// 没有主体的请求不走缓存，也不会互相命中
+	for i := 0; i < 2; i++ {
+		anon := ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil).Result()
+		if got := string(anon.Header.Peek("X-Cache")); got != "" {
+			t.Fatalf("匿名请求不应走缓存，X-Cache=%q", got)
+		}
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("匿名请求应每次进入 handler，calls=%d", calls.Load())
+	}
+}
+
+func TestResponseCache_SkipNon200(t *testing.T) {
+	s, _, calls := newCacheTestServer(ResponseCacheOption{}, http.StatusInternalServerError)
+	ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil)
+	ut.PerformRequest(s.Engine, http.MethodGet, "/schemas", nil)
+	if calls.Load() != 2 {
+		t.Fatalf("非 200 响应不应缓存，calls=%d", calls.Load())
+	}
+}
+
+func TestResponseCache_SkipBusinessError(t *testing.T) {
+	s := newVersioningTestServer()
+	rc := NewResponseCache(NewLocalResponseCacheStore(), 0)
+	calls := &atomic.Int64{}
+	s.GET("/orders", rc.Cache(ResponseCacheOption{}), MakeHandler(func(req Request) (any, error) {
+		calls.Add(1)
+		return nil, errwrap.Errorf(1001, "订单不存在")
+	}))
+
+	first := ut.PerformRequest(s.Engine, http.MethodGet, "/orders", nil).Result()
+	if first.StatusCode() != http.StatusOK || string(first.Header.Peek("X-Cache")) != "" {
+		t.Fatalf("业务错误应以 200 下发且不标记缓存，实际 %d %q", first.StatusCode(), first.Header.Peek("X-Cache"))
+	}
+	ut.PerformRequest(s.Engine, http.MethodGet, "/orders", nil)
+	if calls.Load() != 2 {
+		t.Fatalf("业务错误响应不应缓存，calls=%d", calls.Load())
+	}
+}
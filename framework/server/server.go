@@ -48,6 +48,15 @@ type Server struct {
 	cleanupMu   sync.Mutex
 	cleanupFns  []func()
 	cleanupOnce sync.Once // 保证 runCleanups 只执行一次（多次 Shutdown 信号不会重复触发）
+
+	// httpMetrics HTTP 服务侧指标，registerPlugin 时创建；版本化路由等插件复用同一组 instrument。
+	httpMetrics *httpServerMetrics
+	// versionInfo 版本化路由的版本/弃用信息，仅用于启动时打印路由表。
+	versionInfo routeVersionInfo
+
+	// respCache 按 <schema>.ResponseCache.* 懒加载的响应缓存，见 response_cache.go。
+	respCacheOnce sync.Once
+	respCache     *ResponseCache
 }
 
 // ClientAuthType 客户端验证类型映射
@@ -402,17 +411,17 @@ func (s *Server) sortRoutes(routes route.RoutesInfo) {
 // formatRoutesInfo 生成格式化的路由信息表格
 func (s *Server) formatRoutesInfo(routes route.RoutesInfo) string {
 	// 生成分隔线
-	dashLine := s.generateDashLine(80)
+	dashLine := s.generateDashLine(120)
 
 	// 初始化结果字符串
 	result := "\n" + "=" + dashLine + "=\n"
 
 	// 添加居中标题
-	result += "|" + s.centerString("REGISTERED ROUTES ("+fmt.Sprintf("%d", len(routes))+")", 82) + "|\n"
+	result += "|" + s.centerString("REGISTERED ROUTES ("+fmt.Sprintf("%d", len(routes))+")", 120) + "|\n"
 	result += "=" + dashLine + "=\n"
 
 	// 添加表头
-	result += "| METHOD   | PATH                                               | HANDLER |\n"
+	result += "| METHOD   | PATH                                               | VERSION                                  | HANDLER |\n"
 	result += "=" + dashLine + "=\n"
 
 	// 添加每条路由信息
@@ -451,13 +460,16 @@ func (s *Server) formatRoute(route route.RouteInfo) string {
 	resetColor := "\033[0m"
 	formattedMethod := color + method + resetColor
 
+	// 版本化路由展示版本与弃用信息（不截断，弃用信息需要完整可见），普通路由留空
+	version := s.versionInfo.get(method, path)
+
 	// 限制路径长度显示
 	if len(path) > 50 {
 		path = path[:47] + "..."
 	}
 
 	// 格式化输出
-	return fmt.Sprintf("| %-8s | %-50s | %s |\n", formattedMethod, path, handler)
+	return fmt.Sprintf("| %-8s | %-50s | %-40s | %s |\n", formattedMethod, path, version, handler)
 }
 
 // getMethodColor 获取HTTP方法对应的终端颜色代码
@@ -503,7 +515,8 @@ func (s *Server) registerPlugin() {
 	// 启用 HTTP 指标采集（QPS / 时延 / 在途 / 响应大小）。
 	// metrics 系统未启用时 otel.Meter 返回 noop，几乎零开销。
 	gaia.Info("启用 HTTP 指标采集")
-	s.Use(s.metricsPlugin(initHTTPMetrics()))
+	s.httpMetrics = initHTTPMetrics()
+	s.Use(s.metricsPlugin(s.httpMetrics))
 
 	// 安全头中间件（HSTS / X-Frame-Options / CSP 等浏览器侧防护）
 	if s.securityHeadersEnabled() {
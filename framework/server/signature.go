@@ -0,0 +1,268 @@
+// Package server 服务间请求签名校验。
+//
+// 签名规范与客户端签名器见 framework/httpclient/signer.go，本文件负责服务端校验：
+//  1. 解析 Authorization，定位 sysId/keyId 对应的密钥（同一系统可同时启用多把密钥，便于轮换）
+//  2. 校验时间戳窗口与 body 摘要，按规范重建待签名串并做常量时间比较
+//  3. 签名通过后以 sysId+nonce 占位 nonce，窗口期内的重复请求视为重放
+//
+// DefaultSignatureVerifier 默认把 nonce 存放在 Redis 以便多副本共享，单实例可配置 Auth.NonceStore=local。
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/xxzhwl/gaia"
+	gaiaredis "github.com/xxzhwl/gaia/components/redis"
+	"github.com/xxzhwl/gaia/framework/httpclient"
+)
+
+// SysIdKey 签名校验通过后写入 RequestContext 的调用方系统 ID。
+const SysIdKey = "CtxSysId"
+
+var (
+	ErrSignatureInvalid = errors.New("签名校验失败")
+	ErrSignatureExpired = errors.New("签名已过期")
+	ErrSignatureReplay  = errors.New("签名重复使用")
+)
+
+// SysKey 系统的一把签名密钥。
+type SysKey struct {
+	KeyId  string
+	Secret string
+	// ExpireTime 非零时表示密钥在该时间后失效，用于轮换时给旧密钥留出过渡期。
+	ExpireTime time.Time
+}
+
+func (k SysKey) active(now time.Time) bool {
+	return k.ExpireTime.IsZero() || now.Before(k.ExpireTime)
+}
+
+// SysKeyProvider 按系统 ID 返回其全部启用的密钥。
+type SysKeyProvider interface {
+	Keys(sysId string) ([]SysKey, error)
+}
+
+// SysKeyProviderFunc 函数式 SysKeyProvider。
+type SysKeyProviderFunc func(sysId string) ([]SysKey, error)
+
+// Keys implements SysKeyProvider。
+func (f SysKeyProviderFunc) Keys(sysId string) ([]SysKey, error) { return f(sysId) }
+
+// NonceStore 记录已使用的 nonce。
+type NonceStore interface {
+	// Claim 占用 key，ttl 内再次 Claim 同一 key 返回 false。
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// LocalNonceStore 进程内 nonce 存储，仅适合单实例。
+type LocalNonceStore struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastPurge time.Time
+}
+
+// NewLocalNonceStore 创建进程内 nonce 存储。
+func NewLocalNonceStore() *LocalNonceStore {
+	return &LocalNonceStore{entries: make(map[string]time.Time)}
+}
+
+// Claim implements NonceStore。
+func (l *LocalNonceStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastPurge) > ttl {
+		for k, exp := range l.entries {
+			if now.After(exp) {
+				delete(l.entries, k)
+			}
+		}
+		l.lastPurge = now
+	}
+	if exp, ok := l.entries[key]; ok && now.Before(exp) {
+		return false, nil
+	}
+	l.entries[key] = now.Add(ttl)
+	return true, nil
+}
+
+// RedisNonceStore 基于 SETNX 的 nonce 存储，多实例共享。
+type RedisNonceStore struct {
+	cli       *gaiaredis.Client
+	keyPrefix string
+}
+
+// NewRedisNonceStore 创建 Redis nonce 存储，keyPrefix 为空时默认 "gaia:sign:nonce:"。
+func NewRedisNonceStore(cli *gaiaredis.Client, keyPrefix string) *RedisNonceStore {
+	if keyPrefix == "" {
+		keyPrefix = "gaia:sign:nonce:"
+	}
+	return &RedisNonceStore{cli: cli, keyPrefix: keyPrefix}
+}
+
+// Claim implements NonceStore。
+func (r *RedisNonceStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.cli.GetCli().SetNX(ctx, r.keyPrefix+key, 1, ttl).Result()
+}
+
+// SignatureVerifier 服务端签名校验器。
+type SignatureVerifier struct {
+	Keys   SysKeyProvider
+	Nonces NonceStore
+	// Window 允许的客户端与服务端时间差，默认 60s；nonce 保留 2*Window。
+	Window time.Duration
+	// AllowLegacy 是否继续接受旧版 "<sysId><256|512><ts>_<sig>" 格式，迁移完成后应关闭。
+	AllowLegacy bool
+
+	now func() time.Time
+}
+
+// NewSignatureVerifier 创建签名校验器，nonces 为空时使用进程内存储。
+func NewSignatureVerifier(keys SysKeyProvider, nonces NonceStore, window time.Duration) *SignatureVerifier {
+	if nonces == nil {
+		nonces = NewLocalNonceStore()
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &SignatureVerifier{Keys: keys, Nonces: nonces, Window: window, now: time.Now}
+}
+
+var (
+	defaultVerifierOnce sync.Once
+	defaultVerifier     *SignatureVerifier
+)
+
+// DefaultSignatureVerifier 返回按 Auth.* 配置创建的校验器（进程内单例）：
+//   - Auth.AllowedTimeWindow:    时间窗口（秒），默认 60
+//   - Auth.NonceStore:           "redis"（默认）| "local"，local 仅适合单实例部署
+//   - Auth.RedisSchema:          Redis 配置前缀，默认 "Framework.Redis"
+//   - Auth.AllowLegacySignature: 是否兼容旧版签名，默认 false，仅在迁移期显式开启
+//
+// 密钥来自 sys_auth 表，见 DbSysKeyProvider。
+func DefaultSignatureVerifier() *SignatureVerifier {
+	defaultVerifierOnce.Do(func() {
+		var nonces NonceStore
+		if gaia.GetSafeConfStringWithDefault("Auth.NonceStore", "redis") != "local" {
+			cli := gaiaredis.NewClientWithSchema(gaia.GetSafeConfStringWithDefault("Auth.RedisSchema", "Framework.Redis"))
+			nonces = NewRedisNonceStore(cli, "")
+		}
+		window := time.Duration(gaia.GetSafeConfInt64WithDefault("Auth.AllowedTimeWindow", 60)) * time.Second
+		defaultVerifier = NewSignatureVerifier(DbSysKeyProvider(), nonces, window)
+		defaultVerifier.AllowLegacy = gaia.GetSafeConfBoolWithDefault("Auth.AllowLegacySignature", false)
+	})
+	return defaultVerifier
+}
+
+// Middleware 返回校验签名的中间件，通过后把 sysId 写入 SysIdKey。
+func (v *SignatureVerifier) Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		sysId, err := v.Verify(ctx, c)
+		if err != nil {
+			c.Abort()
+			NewRequest(c).resp(nil, err)
+			return
+		}
+		c.Set(SysIdKey, sysId)
+		c.Next(ctx)
+	}
+}
+
+// Verify 校验请求签名，返回调用方系统 ID。
+func (v *SignatureVerifier) Verify(ctx context.Context, c *app.RequestContext) (string, error) {
+	authHeader := string(c.GetHeader("Authorization"))
+	if !httpclient.IsSignAuthorization(authHeader) {
+		if !v.AllowLegacy {
+			return "", ErrSignatureInvalid
+		}
+		return v.verifyLegacy(authHeader, string(c.Request.RequestURI()), string(c.Request.Method()))
+	}
+
+	auth, err := httpclient.ParseSignAuthorization(authHeader)
+	if err != nil {
+		return "", ErrSignatureInvalid
+	}
+	signed := make(map[string]struct{}, len(auth.SignedHeaders))
+	for _, h := range auth.SignedHeaders {
+		signed[h] = struct{}{}
+	}
+	for _, h := range httpclient.RequiredSignedHeaders {
+		if _, ok := signed[h]; !ok {
+			return "", ErrSignatureInvalid
+		}
+	}
+
+	timestamp := string(c.GetHeader(httpclient.SignDateHeader))
+	nonce := string(c.GetHeader(httpclient.SignNonceHeader))
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || nonce == "" {
+		return "", ErrSignatureInvalid
+	}
+	now := v.now()
+	if absInt64(now.Unix()-ts) > int64(v.Window/time.Second) {
+		return "", ErrSignatureExpired
+	}
+
+	bodyHash := httpclient.BodySha256(c.Request.Body())
+	if subtle.ConstantTimeCompare([]byte(bodyHash), c.GetHeader(httpclient.SignContentHashHeader)) != 1 {
+		return "", ErrSignatureInvalid
+	}
+
+	key, err := v.findKey(auth.SysId, auth.KeyId, now)
+	if err != nil {
+		return "", err
+	}
+
+	headerOf := func(name string) string {
+		if name == "host" {
+			if host := c.Request.Header.Host(); len(host) > 0 {
+				return string(host)
+			}
+			return string(c.Request.Host())
+		}
+		return string(c.GetHeader(name))
+	}
+	uri := c.Request.URI()
+	canonical := httpclient.CanonicalRequest(string(c.Request.Method()), string(uri.Path()),
+		string(uri.QueryString()), headerOf, auth.SignedHeaders, bodyHash)
+	expected, err := httpclient.ComputeSignature(auth.Algorithm, []byte(key.Secret),
+		httpclient.StringToSign(auth.Algorithm, timestamp, nonce, canonical))
+	if err != nil || subtle.ConstantTimeCompare([]byte(expected), []byte(auth.Signature)) != 1 {
+		return "", ErrSignatureInvalid
+	}
+
+	// 签名通过后才占用 nonce，避免伪造请求耗尽合法调用方的 nonce
+	fresh, err := v.Nonces.Claim(ctx, auth.SysId+":"+nonce, 2*v.Window)
+	if err != nil {
+		gaia.ErrorF("签名 nonce 存储不可用 sysId=%s: %v", auth.SysId, err)
+		return "", ErrSignatureInvalid
+	}
+	if !fresh {
+		return "", ErrSignatureReplay
+	}
+	return auth.SysId, nil
+}
+
+func (v *SignatureVerifier) findKey(sysId, keyId string, now time.Time) (SysKey, error) {
+	keys, err := v.Keys.Keys(sysId)
+	if err != nil {
+		gaia.WarnF("查询系统 %s 签名密钥失败: %v", sysId, err)
+		return SysKey{}, ErrSignatureInvalid
+	}
+	for _, k := range keys {
+		if k.KeyId == keyId && k.Secret != "" && k.active(now) {
+			return k, nil
+		}
+	}
+	return SysKey{}, ErrSignatureInvalid
+}
@@ -0,0 +1,570 @@
+// Package server
+//
+// SSE Broker：主题发布 + 事件持久 + Last-Event-ID 断点续传 + 跨实例投递
+// =========================================================================
+// MakeSSEHandler 只负责"一条流怎么写"，SSEWriter.LastEventID() 虽然能拿到客户端
+// 上次收到的事件 ID，但没有地方存历史事件，断线重连的客户端无从续传。SSEBroker
+// 补上这一层：
+//
+//  1. 发布：Publish(topic, event) 由 SSEEventStore 分配单调递增的事件 ID 并写入
+//     有界环形缓冲（内存 / Redis Streams），随后推给本实例订阅者，再经
+//     FanoutBackend 投递给其它副本；
+//  2. 订阅：Handler() 生成的路由先订阅实时事件，再按 Last-Event-ID 从 Store 回放
+//     缺失事件，最后无缝切换到实时流（按回放过的 ID 去重，回放与实时之间不丢不重）；
+//  3. 缓冲区已淘汰客户端所需的事件时，先下发一条 event="reset" 事件，提示客户端
+//     全量刷新状态，再从最早保留的事件开始回放；
+//  4. 慢订阅者：订阅队列写满时直接断开流，客户端 EventSource 自动重连后凭
+//     Last-Event-ID 回放补齐——背压不会丢事件，也不会拖慢发布方。
+//
+// 多副本部署时 Store 必须使用 RedisSSEStore（事件 ID 由 Redis 统一分配），
+// Fanout 使用 RedisFanout。典型场景：任务进度、workflow 待办通知。
+//
+// 用法：
+//
+//	broker := server.NewSSEBroker(server.SSEBrokerConfig{
+//	    Title:  "JobProgress",
+//	    Store:  server.NewRedisSSEStore(redis.NewFrameworkClient(), 500),
+//	    Fanout: server.NewRedisFanout(redis.NewFrameworkClient()),
+//	})
+//	s.GET("/jobs/:id/events", broker.Handler(func(_ context.Context, c *app.RequestContext) (string, error) {
+//	    return "job:" + c.Param("id"), nil
+//	}))
+//	_, _ = broker.PublishJSON(ctx, "job:42", "progress", map[string]any{"percent": 60})
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/xxzhwl/gaia"
+	gaiaredis "github.com/xxzhwl/gaia/components/redis"
+)
+
+// SSEResetEvent 回放缺口提示事件名：客户端收到后应全量刷新状态。
+const SSEResetEvent = "reset"
+
+// SSEEventStore 事件存储：分配事件 ID 并保留最近 N 条事件用于回放。
+type SSEEventStore interface {
+	// Append 写入一条事件并返回带 ID 的事件。
+	Append(ctx context.Context, topic string, event SSEEvent) (SSEEvent, error)
+	// Since 返回 lastID 之后的事件（最多 limit 条）；lastID 为空时返回空。
+	// complete=false 表示回放存在缺口：lastID 之后的部分事件已被淘汰、回放被 limit 截断，
+	// 或存储中已没有 lastID 对应的历史（例如重启后主题为空、lastID 超出当前序号）。
+	Since(ctx context.Context, topic, lastID string, limit int) (events []SSEEvent, complete bool, err error)
+}
+
+// ============================================================================
+// MemorySSEStore
+// ============================================================================
+
+// MemorySSEStore 进程内环形缓冲存储，事件 ID 为按主题递增的十进制整数。
+// 仅适用于单实例部署：多副本下各实例 ID 相互独立，无法跨实例续传。
+type MemorySSEStore struct {
+	capacity int
+
+	mu     sync.Mutex
+	topics map[string]*sseRing
+}
+
+type sseRing struct {
+	seq    int64
+	events []SSEEvent // 按 ID 升序，长度不超过 capacity
+}
+
+// NewMemorySSEStore 创建内存存储，capacity 为每个主题保留的事件数，默认 1000。
+func NewMemorySSEStore(capacity int) *MemorySSEStore {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &MemorySSEStore{capacity: capacity, topics: make(map[string]*sseRing)}
+}
+
+// Append implements SSEEventStore。
+func (m *MemorySSEStore) Append(_ context.Context, topic string, event SSEEvent) (SSEEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ring := m.topics[topic]
+	if ring == nil {
+		ring = &sseRing{}
+		m.topics[topic] = ring
+	}
+	ring.seq++
+	event.ID = strconv.FormatInt(ring.seq, 10)
+	ring.events = append(ring.events, event)
+	if over := len(ring.events) - m.capacity; over > 0 {
+		ring.events = append(ring.events[:0:0], ring.events[over:]...)
+	}
+	return event, nil
+}
+
+// Since implements SSEEventStore。
+func (m *MemorySSEStore) Since(_ context.Context, topic, lastID string, limit int) ([]SSEEvent, bool, error) {
+	if lastID == "" {
+		return nil, true, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ring := m.topics[topic]
+	if ring == nil || len(ring.events) == 0 {
+		// 没有任何历史（多为重启后），无法确认客户端是否漏了事件
+		return nil, false, nil
+	}
+	if n, err := strconv.ParseInt(lastID, 10, 64); err != nil || n > ring.seq {
+		// lastID 不是本存储分配的 ID（重启前的序号或其他存储），按缺口处理
+		return nil, false, nil
+	}
+	// 客户端需要的下一条事件已被淘汰 → 存在缺口
+	complete := compareSSEEventID(ring.events[0].ID, nextMemoryID(lastID)) <= 0
+	var out []SSEEvent
+	for _, ev := range ring.events {
+		if compareSSEEventID(ev.ID, lastID) <= 0 {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			// 回放被截断，剩余事件不会再发送
+			complete = false
+			break
+		}
+		out = append(out, ev)
+	}
+	return out, complete, nil
+}
+
+func nextMemoryID(id string) string {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return id
+	}
+	return strconv.FormatInt(n+1, 10)
+}
+
+// ============================================================================
+// RedisSSEStore
+// ============================================================================
+
+// RedisSSEStore 基于 Redis Streams 的存储：事件 ID 由 XADD 分配（<ms>-<seq>），
+// 天然跨实例单调；每个主题一个 stream，按 MAXLEN 近似裁剪。
+type RedisSSEStore struct {
+	cli       *redis.Client
+	maxLen    int64
+	keyPrefix string
+	ttl       time.Duration
+}
+
+// NewRedisSSEStore 创建 Redis Streams 存储，maxLen 为每个主题保留的事件数，默认 1000。
+func NewRedisSSEStore(cli *gaiaredis.Client, maxLen int64) *RedisSSEStore {
+	if maxLen <= 0 {
+		maxLen = 1000
+	}
+	return &RedisSSEStore{cli: cli.GetCli(), maxLen: maxLen, keyPrefix: "gaia:sse:", ttl: 24 * time.Hour}
+}
+
+// SetKeyPrefix 自定义 stream key 前缀，默认 "gaia:sse:"。
+func (r *RedisSSEStore) SetKeyPrefix(prefix string) *RedisSSEStore {
+	r.keyPrefix = prefix
+	return r
+}
+
+// SetTTL 主题 stream 的空闲过期时间，每次 Append 续期；<=0 不过期。默认 24h。
+func (r *RedisSSEStore) SetTTL(ttl time.Duration) *RedisSSEStore {
+	r.ttl = ttl
+	return r
+}
+
+// Append implements SSEEventStore。
+func (r *RedisSSEStore) Append(ctx context.Context, topic string, event SSEEvent) (SSEEvent, error) {
+	key := r.keyPrefix + topic
+	id, err := r.cli.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: r.maxLen,
+		Approx: true,
+		Values: map[string]any{
+			"event": event.Event,
+			"retry": event.Retry,
+			"data":  event.Data,
+		},
+	}).Result()
+	if err != nil {
+		return event, err
+	}
+	if r.ttl > 0 {
+		_ = r.cli.Expire(ctx, key, r.ttl).Err()
+	}
+	event.ID = id
+	return event, nil
+}
+
+// Since implements SSEEventStore。
+func (r *RedisSSEStore) Since(ctx context.Context, topic, lastID string, limit int) ([]SSEEvent, bool, error) {
+	if lastID == "" {
+		return nil, true, nil
+	}
+	key := r.keyPrefix + topic
+	count := r.maxLen
+	if limit > 0 {
+		// 多取一条用来判断回放是否被截断
+		count = int64(limit) + 1
+	}
+	msgs, err := r.cli.XRangeN(ctx, key, "("+lastID, "+", count).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	complete := true
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+		complete = false
+	}
+	if first, ferr := r.cli.XRangeN(ctx, key, "-", "+", 1).Result(); ferr == nil {
+		// stream 为空说明历史已丢失；最早保留的事件比客户端已收到的更新 → 二者之间的事件可能已被裁剪。
+		// Redis ID 不连续，无法精确判断，宁可多发一次 reset 也不漏报缺口
+		if len(first) == 0 || compareSSEEventID(first[0].ID, lastID) > 0 {
+			complete = false
+		}
+	}
+	out := make([]SSEEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		out = append(out, sseEventFromStream(msg))
+	}
+	return out, complete, nil
+}
+
+func sseEventFromStream(msg redis.XMessage) SSEEvent {
+	ev := SSEEvent{ID: msg.ID}
+	if v, ok := msg.Values["event"].(string); ok {
+		ev.Event = v
+	}
+	if v, ok := msg.Values["data"].(string); ok {
+		ev.Data = []byte(v)
+	}
+	if v, ok := msg.Values["retry"].(string); ok {
+		ev.Retry, _ = strconv.ParseUint(v, 10, 64)
+	}
+	return ev
+}
+
+// compareSSEEventID 比较两个事件 ID：兼容纯整数（内存存储）与 <ms>-<seq>（Redis Streams）。
+func compareSSEEventID(a, b string) int {
+	aHi, aLo := splitSSEEventID(a)
+	bHi, bLo := splitSSEEventID(b)
+	switch {
+	case aHi != bHi:
+		if aHi < bHi {
+			return -1
+		}
+		return 1
+	case aLo != bLo:
+		if aLo < bLo {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func splitSSEEventID(id string) (uint64, uint64) {
+	hi, lo, _ := strings.Cut(id, "-")
+	h, _ := strconv.ParseUint(hi, 10, 64)
+	l, _ := strconv.ParseUint(lo, 10, 64)
+	return h, l
+}
+
+// ============================================================================
+// SSEBroker
+// ============================================================================
+
+// SSEBrokerConfig SSEBroker 配置。
+type SSEBrokerConfig struct {
+	// Title 用于日志与扇出 channel 的业务标识，默认 "SSEBroker"。
+	Title string
+	// Store 事件存储，默认 NewMemorySSEStore(1000)。
+	Store SSEEventStore
+	// Fanout 跨实例扇出后端；为 nil 时只投递本实例订阅者。
+	Fanout FanoutBackend
+	// Channel 扇出 channel 名，默认 "gaia:sse:<Title>"。
+	Channel string
+	// SubscriberBuffer 每个订阅者的事件队列长度，默认 64。
+	SubscriberBuffer int
+	// ReplayLimit 单次重连最多回放的事件数，默认 1000；超出时先发送 reset 事件。
+	ReplayLimit int
+	// SSE 底层流配置（心跳、Nginx 缓冲等）。
+	SSE SSEOptions
+}
+
+// SSESubscription 一个主题订阅。
+type SSESubscription struct {
+	broker *SSEBroker
+	topic  string
+	events chan SSEEvent
+	lagged chan struct{}
+	once   sync.Once
+}
+
+// Events 实时事件通道。
+func (s *SSESubscription) Events() <-chan SSEEvent { return s.events }
+
+// Lagged 订阅队列写满时被 close，订阅者应断开并由客户端重连续传。
+func (s *SSESubscription) Lagged() <-chan struct{} { return s.lagged }
+
+// Close 取消订阅。幂等。
+func (s *SSESubscription) Close() {
+	s.once.Do(func() {
+		s.broker.removeSubscriber(s)
+	})
+}
+
+func (s *SSESubscription) deliver(ev SSEEvent) {
+	select {
+	case s.events <- ev:
+	default:
+		select {
+		case <-s.lagged:
+		default:
+			close(s.lagged)
+		}
+	}
+}
+
+// sseEnvelope 跨实例扇出的消息信封。
+type sseEnvelope struct {
+	Origin string `json:"origin"`
+	Topic  string `json:"topic"`
+	Event  string `json:"event"`
+	ID     string `json:"id"`
+	Retry  uint64 `json:"retry,omitempty"`
+	Data   []byte `json:"data"`
+}
+
+// SSEBroker 主题化 SSE 事件分发器。
+type SSEBroker struct {
+	cfg        SSEBrokerConfig
+	instanceID string
+
+	mu   sync.RWMutex
+	subs map[string]map[*SSESubscription]struct{}
+
+	unsubscribe func()
+	closeOnce   sync.Once
+}
+
+// NewSSEBroker 创建 Broker；配置了 Fanout 时立即订阅扇出 channel，订阅失败只告警，
+// Broker 退化为单实例投递。
+func NewSSEBroker(cfg SSEBrokerConfig) *SSEBroker {
+	if cfg.Title == "" {
+		cfg.Title = "SSEBroker"
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemorySSEStore(1000)
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = "gaia:sse:" + cfg.Title
+	}
+	if cfg.SubscriberBuffer <= 0 {
+		cfg.SubscriberBuffer = 64
+	}
+	if cfg.ReplayLimit <= 0 {
+		cfg.ReplayLimit = 1000
+	}
+	if cfg.SSE.Title == "" {
+		cfg.SSE.Title = cfg.Title
+	}
+
+	b := &SSEBroker{
+		cfg:        cfg,
+		instanceID: gaia.GetUUID(),
+		subs:       make(map[string]map[*SSESubscription]struct{}),
+	}
+	if cfg.Fanout != nil {
+		unsub, err := cfg.Fanout.Subscribe(context.Background(), cfg.Channel, b.onFanout)
+		if err != nil {
+			gaia.ErrorF("[%s] sse broker 订阅扇出失败，仅投递本实例订阅者: %v", cfg.Title, err)
+		} else {
+			b.unsubscribe = unsub
+		}
+	}
+	return b
+}
+
+// Close 取消扇出订阅。幂等。
+func (b *SSEBroker) Close() {
+	b.closeOnce.Do(func() {
+		if b.unsubscribe != nil {
+			b.unsubscribe()
+		}
+	})
+}
+
+// Publish 发布事件到主题，返回带 ID 的事件。
+// 扇出失败时本实例订阅者已收到事件，其它副本的客户端可在重连时通过回放补齐。
+func (b *SSEBroker) Publish(ctx context.Context, topic string, event SSEEvent) (SSEEvent, error) {
+	if event.Event == "" {
+		event.Event = "message"
+	}
+	stored, err := b.cfg.Store.Append(ctx, topic, event)
+	if err != nil {
+		return event, fmt.Errorf("sse store append: %w", err)
+	}
+	b.deliverLocal(topic, stored)
+	if b.cfg.Fanout == nil {
+		return stored, nil
+	}
+	bs, err := json.Marshal(sseEnvelope{
+		Origin: b.instanceID,
+		Topic:  topic,
+		Event:  stored.Event,
+		ID:     stored.ID,
+		Retry:  stored.Retry,
+		Data:   stored.Data,
+	})
+	if err != nil {
+		return stored, err
+	}
+	return stored, b.cfg.Fanout.Publish(ctx, b.cfg.Channel, bs)
+}
+
+// PublishJSON 以事件名 + JSON 数据发布。
+func (b *SSEBroker) PublishJSON(ctx context.Context, topic, name string, v any) (SSEEvent, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return SSEEvent{}, fmt.Errorf("sse marshal json: %w", err)
+	}
+	return b.Publish(ctx, topic, SSEEvent{Event: name, Data: bs})
+}
+
+// Subscribe 订阅主题的实时事件（不含回放）。
+func (b *SSEBroker) Subscribe(topic string) *SSESubscription {
+	sub := &SSESubscription{
+		broker: b,
+		topic:  topic,
+		events: make(chan SSEEvent, b.cfg.SubscriberBuffer),
+		lagged: make(chan struct{}),
+	}
+	b.mu.Lock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[*SSESubscription]struct{})
+	}
+	b.subs[topic][sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Subscribers 主题在本实例上的订阅者数量。
+func (b *SSEBroker) Subscribers(topic string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs[topic])
+}
+
+func (b *SSEBroker) removeSubscriber(sub *SSESubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs[sub.topic], sub)
+	if len(b.subs[sub.topic]) == 0 {
+		delete(b.subs, sub.topic)
+	}
+}
+
+func (b *SSEBroker) deliverLocal(topic string, ev SSEEvent) {
+	b.mu.RLock()
+	targets := make([]*SSESubscription, 0, len(b.subs[topic]))
+	for sub := range b.subs[topic] {
+		targets = append(targets, sub)
+	}
+	b.mu.RUnlock()
+	for _, sub := range targets {
+		sub.deliver(ev)
+	}
+}
+
+// onFanout 处理其它副本扇出过来的事件，跳过自身发出的事件避免重复投递。
+func (b *SSEBroker) onFanout(payload []byte) {
+	var env sseEnvelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		gaia.WarnF("[%s] sse broker 扇出消息解析失败: %v", b.cfg.Title, err)
+		return
+	}
+	if env.Origin == b.instanceID {
+		return
+	}
+	b.deliverLocal(env.Topic, SSEEvent{Event: env.Event, ID: env.ID, Retry: env.Retry, Data: env.Data})
+}
+
+// Replay 返回 lastID 之后仍保留的事件；complete=false 表示存在缺口。
+func (b *SSEBroker) Replay(ctx context.Context, topic, lastID string) ([]SSEEvent, bool, error) {
+	return b.cfg.Store.Since(ctx, topic, lastID, b.cfg.ReplayLimit)
+}
+
+// Handler 生成订阅路由：topicFn 从请求中解析主题（可在其中做鉴权，返回 err 拒绝订阅）。
+func (b *SSEBroker) Handler(topicFn func(ctx context.Context, c *app.RequestContext) (string, error)) app.HandlerFunc {
+	return MakeSSEHandler(func(ctx context.Context, c *app.RequestContext, w SSEWriter) error {
+		topic, err := topicFn(ctx, c)
+		if err != nil {
+			return err
+		}
+		return b.Stream(ctx, topic, w)
+	}, b.cfg.SSE)
+}
+
+// Stream 把主题事件写入 w：先订阅实时事件，再回放 Last-Event-ID 之后的缺失事件，
+// 最后切到实时流。直到客户端断开、订阅滞后（返回 nil）或写失败才返回。
+//
+// 实时事件只与回放阶段已发送的 ID 去重，不按 ID 大小过滤：多副本发布时扇出顺序
+// 不等于 XADD 分配的 ID 顺序，ID 较小的事件可能晚到，按大小过滤会把它永久丢掉。
+func (b *SSEBroker) Stream(ctx context.Context, topic string, w SSEWriter) error {
+	// 先订阅再回放：回放期间发布的事件会在订阅队列里等着，跳过回放已发送的 ID 即可无缝衔接
+	sub := b.Subscribe(topic)
+	defer sub.Close()
+
+	lastID := w.LastEventID()
+	var replayed map[string]struct{}
+	if lastID != "" {
+		events, complete, err := b.Replay(ctx, topic, lastID)
+		if err != nil {
+			gaia.WarnF("[%s] sse 回放失败 topic=%s last=%s: %v", b.cfg.Title, topic, lastID, err)
+		}
+		if !complete {
+			if err := w.Send(SSEEvent{Event: SSEResetEvent, Data: []byte("{}")}); err != nil {
+				return err
+			}
+		}
+		replayed = make(map[string]struct{}, len(events))
+		for _, ev := range events {
+			if err := w.Send(ev); err != nil {
+				return err
+			}
+			replayed[ev.ID] = struct{}{}
+			lastID = ev.ID
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.Lagged():
+			// 断开后客户端会带 Last-Event-ID 重连回放，属预期行为，不按错误处理
+			gaia.WarnF("[%s] sse 订阅者滞后，断开等待重连 topic=%s last=%s", b.cfg.Title, topic, lastID)
+			return nil
+		case ev := <-sub.Events():
+			if _, ok := replayed[ev.ID]; ok {
+				// 每个 ID 在订阅队列里至多出现一次，命中后即可释放
+				delete(replayed, ev.ID)
+				continue
+			}
+			if err := w.Send(ev); err != nil {
+				return err
+			}
+			lastID = ev.ID
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// sse_broker_test.go 验证：
+//  1. MemorySSEStore 的 ID 单调递增、环形淘汰与缺口判断（含截断、主题为空与 lastID 超出序号）
+//  2. compareSSEEventID 兼容整数与 Redis Streams ID
+//  3. Stream 先回放 Last-Event-ID 之后的事件再切实时流，且不重复
+//  4. 共享 MemoryFanout 的两个 Broker 之间跨实例投递
+//  5. 重启后带旧 Last-Event-ID 重连先收到 reset，随后的实时事件不被旧 ID 过滤
+//  6. 实时事件乱序到达时不因 ID 较小被丢弃，只跳过回放阶段已发送的事件
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSSEWriter 记录写入事件的 SSEWriter。
+type fakeSSEWriter struct {
+	ctx    context.Context
+	lastID string
+
+	mu     sync.Mutex
+	events []SSEEvent
+}
+
+func (f *fakeSSEWriter) Send(event SSEEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+	return nil
+}
+func (f *fakeSSEWriter) SendData(data []byte) error        { return f.Send(SSEEvent{Data: data}) }
+func (f *fakeSSEWriter) SendJSON(name string, _ any) error { return f.Send(SSEEvent{Event: name}) }
+func (f *fakeSSEWriter) Ping() error                       { return nil }
+func (f *fakeSSEWriter) Context() context.Context          { return f.ctx }
+func (f *fakeSSEWriter) LastEventID() string               { return f.lastID }
+
+func (f *fakeSSEWriter) ids() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]string, 0, len(f.events))
+	for _, ev := range f.events {
+		if ev.Event == SSEResetEvent {
+			out = append(out, SSEResetEvent)
+			continue
+		}
+		out = append(out, ev.ID)
+	}
+	return out
+}
+
+func waitSSE(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("等待条件超时")
+}
+
+func TestMemorySSEStore_RingAndGap(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySSEStore(3)
+	for i := 0; i < 5; i++ {
+		_, _ = store.Append(ctx, "t", SSEEvent{Data: []byte("x")})
+	}
+
+	events, complete, _ := store.Since(ctx, "t", "3", 0)
+	if !complete || len(events) != 2 || events[0].ID != "4" {
+		t.Fatalf("Since(3) 错误: complete=%v events=%+v", complete, events)
+	}
+	events, complete, _ = store.Since(ctx, "t", "1", 0)
+	if complete || len(events) != 3 || events[0].ID != "3" {
+		t.Fatalf("Since(1) 应报告缺口并从 3 开始: complete=%v events=%+v", complete, events)
+	}
+	if events, _, _ = store.Since(ctx, "t", "", 0); len(events) != 0 {
+		t.Fatalf("空 lastID 不应回放")
+	}
+	events, complete, _ = store.Since(ctx, "t", "3", 1)
+	if complete || len(events) != 1 || events[0].ID != "4" {
+		t.Fatalf("被 limit 截断应报告缺口: complete=%v events=%+v", complete, events)
+	}
+	if events, complete, _ = store.Since(ctx, "t", "9", 0); complete || len(events) != 0 {
+		t.Fatalf("lastID 超出当前序号应报告缺口: complete=%v events=%+v", complete, events)
+	}
+	if _, complete, _ = store.Since(ctx, "missing", "3", 0); complete {
+		t.Fatalf("主题没有历史时应报告缺口")
+	}
+}
+
+func TestCompareSSEEventID(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"2", "10", -1},
+		{"10", "10", 0},
+		{"1700000000000-1", "1700000000000-0", 1},
+		{"1700000000000-5", "1700000000001-0", -1},
+	}
+	for _, tc := range cases {
+		if got := compareSSEEventID(tc.a, tc.b); got != tc.want {
+			t.Fatalf("compare(%s,%s) 期望 %d，实际 %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
+
+func TestSSEBroker_ReplayThenLive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := NewSSEBroker(SSEBrokerConfig{})
+	for i := 0; i < 3; i++ {
+		_, _ = b.Publish(ctx, "job:1", SSEEvent{Data: []byte("p")})
+	}
+
+	w := &fakeSSEWriter{ctx: ctx, lastID: "1"}
+	done := make(chan error, 1)
+	go func() { done <- b.Stream(ctx, "job:1", w) }()
+
+	waitSSE(t, func() bool { return b.Subscribers("job:1") == 1 && len(w.ids()) == 2 })
+	_, _ = b.Publish(ctx, "job:1", SSEEvent{Data: []byte("live")})
+	waitSSE(t, func() bool { return len(w.ids()) == 3 })
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Stream 返回错误: %v", err)
+	}
+	got := w.ids()
+	if got[0] != "2" || got[1] != "3" || got[2] != "4" {
+		t.Fatalf("事件顺序错误: %v", got)
+	}
+	if b.Subscribers("job:1") != 0 {
+		t.Fatalf("Stream 结束后应取消订阅")
+	}
+}
+
+func TestSSEBroker_CrossInstance(t *testing.T) {
+	bus := NewMemoryFanout()
+	store := NewMemorySSEStore(10) // 模拟共享存储
+	b1 := NewSSEBroker(SSEBrokerConfig{Title: "T", Store: store, Fanout: bus})
+	b2 := NewSSEBroker(SSEBrokerConfig{Title: "T", Store: store, Fanout: bus})
+	defer b1.Close()
+	defer b2.Close()
+
+	sub1 := b1.Subscribe("wf")
+	sub2 := b2.Subscribe("wf")
+	defer sub1.Close()
+	defer sub2.Close()
+
+	if _, err := b1.Publish(context.Background(), "wf", SSEEvent{Event: "task", Data: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	for i, sub := range []*SSESubscription{sub1, sub2} {
+		select {
+		case ev := <-sub.Events():
+			if ev.ID != "1" || ev.Event != "task" {
+				t.Fatalf("订阅者 %d 收到错误事件: %+v", i, ev)
+			}
+		default:
+			t.Fatalf("订阅者 %d 未收到事件", i)
+		}
+		select {
+		case ev := <-sub.Events():
+			t.Fatalf("订阅者 %d 收到重复事件: %+v", i, ev)
+		default:
+		}
+	}
+}
+
+func TestSSEBroker_ResetAfterRestart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := NewSSEBroker(SSEBrokerConfig{})
+
+	// 客户端带着重启前的 ID 重连，新实例的序号从 1 重新开始
+	w := &fakeSSEWriter{ctx: ctx, lastID: "42"}
+	done := make(chan error, 1)
+	go func() { done <- b.Stream(ctx, "job:1", w) }()
+
+	waitSSE(t, func() bool { return b.Subscribers("job:1") == 1 && len(w.ids()) == 1 })
+	_, _ = b.Publish(ctx, "job:1", SSEEvent{Data: []byte("live")})
+	waitSSE(t, func() bool { return len(w.ids()) == 2 })
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Stream 返回错误: %v", err)
+	}
+	if got := w.ids(); got[0] != SSEResetEvent || got[1] != "1" {
+		t.Fatalf("应先 reset 再收到新序号的实时事件: %v", got)
+	}
+}
+
+func TestSSEBroker_LiveOutOfOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := NewSSEBroker(SSEBrokerConfig{})
+	for i := 0; i < 3; i++ {
+		_, _ = b.Publish(ctx, "job:1", SSEEvent{Data: []byte("p")})
+	}
+
+	w := &fakeSSEWriter{ctx: ctx, lastID: "1"}
+	done := make(chan error, 1)
+	go func() { done <- b.Stream(ctx, "job:1", w) }()
+	waitSSE(t, func() bool { return b.Subscribers("job:1") == 1 && len(w.ids()) == 2 })
+
+	// 模拟回放期间发布、又经订阅队列到达的事件，以及多副本扇出导致的乱序
+	b.deliverLocal("job:1", SSEEvent{ID: "3"})
+	b.deliverLocal("job:1", SSEEvent{ID: "6"})
+	b.deliverLocal("job:1", SSEEvent{ID: "5"})
+	waitSSE(t, func() bool { return len(w.ids()) == 4 })
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Stream 返回错误: %v", err)
+	}
+	got := w.ids()
+	if len(got) != 4 || got[0] != "2" || got[1] != "3" || got[2] != "6" || got[3] != "5" {
+		t.Fatalf("回放事件应去重，乱序实时事件不应丢弃: %v", got)
+	}
+}
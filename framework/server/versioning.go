@@ -0,0 +1,513 @@
+// Package server API 版本化 & 弃用声明。
+//
+// 同一组 API 的 v1 / v2 需要并存时，通过 Server.APIVersions 注册版本化路由组，
+// 支持三种版本识别方式：
+//
+//	VersionByPath       URL 前缀：/api/v1/users、/api/v2/users（每个版本一条 Hertz 路由）
+//	VersionByHeader     请求头：Accept-Version: v2（同一 path 一条 Hertz 路由，内部按版本分发）
+//	VersionByMediaType  媒体类型协商：Accept: application/vnd.<vendor>.v2+json
+//	                    或 Accept: application/json; version=2
+//
+// 版本或单条路由可以标记为弃用，命中时按 RFC 9745 / RFC 8594 回写：
+//
+//	Deprecation: @1767196800           （弃用生效时间；未指定时回写 "true"）
+//	Sunset: Thu, 31 Dec 2026 00:00:00 GMT
+//	Link: <https://doc/migrate>; rel="deprecation"
+//
+// 每次命中都会按 version/deprecated/route 三维度计入 httpServerMetrics.VersionRequests，
+// 便于观察旧版本的剩余调用量、决定何时真正下线。启动时打印的路由表会额外展示
+// 版本与弃用信息。
+//
+// 用法：
+//
+//	api := s.APIVersions("/api", server.VersioningOption{Strategy: server.VersionByHeader})
+//	api.Version("v1").Deprecate(server.Deprecation{Sunset: sunset}).GET("/users", listUsersV1)
+//	api.Version("v2").GET("/users", listUsersV2)
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/route"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// VersionStrategy 版本识别方式。
+type VersionStrategy int
+
+const (
+	// VersionByPath 版本号作为 URL 前缀（默认）。
+	VersionByPath VersionStrategy = iota
+	// VersionByHeader 版本号放在请求头（默认 Accept-Version）。
+	VersionByHeader
+	// VersionByMediaType 版本号通过 Accept 媒体类型协商。
+	VersionByMediaType
+)
+
+// String 用于路由表与日志展示。
+func (s VersionStrategy) String() string {
+	switch s {
+	case VersionByHeader:
+		return "header"
+	case VersionByMediaType:
+		return "media-type"
+	default:
+		return "path"
+	}
+}
+
+// DefaultVersionHeader VersionByHeader 默认读取的请求头。
+const DefaultVersionHeader = "Accept-Version"
+
+// VersionResponseHeader 响应中回写实际命中版本的头，方便客户端确认协商结果。
+const VersionResponseHeader = "X-Api-Version"
+
+// VersioningOption 版本化路由组配置。
+type VersioningOption struct {
+	// Strategy 版本识别方式，默认 VersionByPath。
+	Strategy VersionStrategy
+	// Header VersionByHeader 读取的请求头，默认 Accept-Version。
+	Header string
+	// Vendor VersionByMediaType 的厂商段：application/vnd.<Vendor>.v2+json。
+	// 为空时只识别 version 参数形式（application/json; version=2）。
+	Vendor string
+	// DefaultVersion 请求未携带版本时使用的版本；为空时取最老的未弃用版本（全部弃用时取最老的版本），
+	// 新注册的版本不会改变未带版本的老客户端拿到的响应。
+	DefaultVersion string
+}
+
+// Deprecation 弃用声明。
+type Deprecation struct {
+	// Since 弃用生效时间；零值时 Deprecation 头回写 "true"。
+	Since time.Time
+	// Sunset 计划下线时间；零值时不回写 Sunset 头。
+	Sunset time.Time
+	// Link 迁移文档地址；非空时回写 Link: <...>; rel="deprecation"。
+	Link string
+}
+
+// describe 路由表中的简短描述。
+func (d *Deprecation) describe() string {
+	if d == nil {
+		return ""
+	}
+	if d.Sunset.IsZero() {
+		return "deprecated"
+	}
+	return "deprecated, sunset " + d.Sunset.UTC().Format("2006-01-02")
+}
+
+// writeHeaders 按 RFC 9745 / RFC 8594 回写弃用相关响应头。
+func (d *Deprecation) writeHeaders(ctx *app.RequestContext) {
+	if d == nil {
+		return
+	}
+	if d.Since.IsZero() {
+		ctx.Response.Header.Set("Deprecation", "true")
+	} else {
+		ctx.Response.Header.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		ctx.Response.Header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		ctx.Response.Header.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", d.Link))
+	}
+}
+
+// RouteOption 单条版本化路由的附加选项。
+type RouteOption func(*versionedHandler)
+
+// Deprecated 将单条路由标记为弃用；优先级高于版本级弃用声明。
+func Deprecated(d Deprecation) RouteOption {
+	return func(h *versionedHandler) {
+		h.deprecation = &d
+	}
+}
+
+// versionedHandler 某个版本下某条路由的处理器。
+type versionedHandler struct {
+	av          *APIVersion
+	version     string
+	handler     app.HandlerFunc
+	deprecation *Deprecation // 路由级弃用声明，为空时沿用版本级声明
+}
+
+// effectiveDeprecation 请求时生效的弃用声明：路由级优先，其次版本级。
+// 版本级声明在请求时读取，注册路由之后再调用 Deprecate 同样生效。
+func (h *versionedHandler) effectiveDeprecation() *Deprecation {
+	if h.deprecation != nil {
+		return h.deprecation
+	}
+	return h.av.currentDeprecation()
+}
+
+// versionedRoute VersionByHeader / VersionByMediaType 下同一 method+path 的全部版本实现。
+type versionedRoute struct {
+	mu       sync.RWMutex
+	handlers map[string]*versionedHandler
+}
+
+// APIVersioning 版本化路由组。
+type APIVersioning struct {
+	s      *Server
+	group  *route.RouterGroup
+	option VersioningOption
+
+	mu       sync.Mutex
+	versions map[string]*APIVersion
+	order    []string
+	routes   map[string]*versionedRoute
+}
+
+// APIVersion 版本化路由组中的单个版本。
+type APIVersion struct {
+	parent *APIVersioning
+	name   string
+	group  *route.RouterGroup
+
+	mu          sync.RWMutex
+	deprecation *Deprecation
+}
+
+// routeVersionInfo 路由表展示用的版本元信息。
+// 描述在打印路由表时才计算，注册之后追加的版本级弃用声明也能体现。
+type routeVersionInfo struct {
+	mu     sync.Mutex
+	values map[string][]func() string // "METHOD path" -> ["v1 (deprecated, sunset 2026-12-31)", "v2"]
+}
+
+func (r *routeVersionInfo) add(method, path string, desc func() string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.values == nil {
+		r.values = make(map[string][]func() string)
+	}
+	key := method + " " + path
+	r.values[key] = append(r.values[key], desc)
+}
+
+func (r *routeVersionInfo) get(method, path string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	descs := r.values[method+" "+path]
+	if len(descs) == 0 {
+		return ""
+	}
+	sorted := make([]string, 0, len(descs))
+	for _, desc := range descs {
+		sorted = append(sorted, desc())
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
+}
+
+// APIVersions 在 prefix 下创建一个版本化路由组。
+func (s *Server) APIVersions(prefix string, option VersioningOption, middlewares ...app.HandlerFunc) *APIVersioning {
+	if option.Header == "" {
+		option.Header = DefaultVersionHeader
+	}
+	return &APIVersioning{
+		s:        s,
+		group:    s.Group(prefix, middlewares...),
+		option:   option,
+		versions: make(map[string]*APIVersion),
+		routes:   make(map[string]*versionedRoute),
+	}
+}
+
+// Version 获取（不存在则创建）名为 name 的版本，name 统一规范为 "v<N>" 形式。
+func (v *APIVersioning) Version(name string) *APIVersion {
+	name = normalizeVersion(name)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if av, ok := v.versions[name]; ok {
+		return av
+	}
+	av := &APIVersion{parent: v, name: name}
+	if v.option.Strategy == VersionByPath {
+		av.group = v.group.Group("/" + name)
+	}
+	v.versions[name] = av
+	v.order = append(v.order, name)
+	return av
+}
+
+// Versions 按注册顺序返回全部版本名。
+func (v *APIVersioning) Versions() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]string(nil), v.order...)
+}
+
+// defaultVersion 请求未带版本时使用的版本：显式配置的 DefaultVersion，否则为最老的未弃用版本。
+func (v *APIVersioning) defaultVersion() string {
+	if v.option.DefaultVersion != "" {
+		return normalizeVersion(v.option.DefaultVersion)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	oldest, oldestStable := "", ""
+	for _, name := range v.order {
+		if oldest == "" || compareVersion(name, oldest) < 0 {
+			oldest = name
+		}
+		if v.versions[name].currentDeprecation() == nil && (oldestStable == "" || compareVersion(name, oldestStable) < 0) {
+			oldestStable = name
+		}
+	}
+	if oldestStable != "" {
+		return oldestStable
+	}
+	return oldest
+}
+
+// compareVersion 按点分数字比较 "v1" / "v1.2" 形式的版本名，无法解析的段按字符串比较。
+func compareVersion(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		if aerr != nil || berr != nil {
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// Name 版本名（如 "v2"）。
+func (av *APIVersion) Name() string {
+	return av.name
+}
+
+// Deprecate 将整个版本标记为弃用，影响此后与此前注册的全部路由（带 Deprecated 选项的路由除外）。
+func (av *APIVersion) Deprecate(d Deprecation) *APIVersion {
+	av.mu.Lock()
+	av.deprecation = &d
+	av.mu.Unlock()
+	return av
+}
+
+// currentDeprecation 版本级弃用声明，未弃用时为 nil。
+func (av *APIVersion) currentDeprecation() *Deprecation {
+	av.mu.RLock()
+	defer av.mu.RUnlock()
+	return av.deprecation
+}
+
+// Use 为该版本追加中间件，仅 VersionByPath 下生效；
+// 其他策略下同一 path 由多个版本共享一条 Hertz 路由，中间件请挂在 APIVersions 上。
+func (av *APIVersion) Use(middlewares ...app.HandlerFunc) *APIVersion {
+	if av.group != nil {
+		av.group.Use(middlewares...)
+	}
+	return av
+}
+
+func (av *APIVersion) GET(path string, handler app.HandlerFunc, opts ...RouteOption) *APIVersion {
+	return av.Handle(http.MethodGet, path, handler, opts...)
+}
+
+func (av *APIVersion) POST(path string, handler app.HandlerFunc, opts ...RouteOption) *APIVersion {
+	return av.Handle(http.MethodPost, path, handler, opts...)
+}
+
+func (av *APIVersion) PUT(path string, handler app.HandlerFunc, opts ...RouteOption) *APIVersion {
+	return av.Handle(http.MethodPut, path, handler, opts...)
+}
+
+func (av *APIVersion) PATCH(path string, handler app.HandlerFunc, opts ...RouteOption) *APIVersion {
+	return av.Handle(http.MethodPatch, path, handler, opts...)
+}
+
+func (av *APIVersion) DELETE(path string, handler app.HandlerFunc, opts ...RouteOption) *APIVersion {
+	return av.Handle(http.MethodDelete, path, handler, opts...)
+}
+
+// Handle 在该版本下注册一条路由。
+func (av *APIVersion) Handle(method, path string, handler app.HandlerFunc, opts ...RouteOption) *APIVersion {
+	h := &versionedHandler{av: av, version: av.name, handler: handler}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	v := av.parent
+	if v.option.Strategy == VersionByPath {
+		av.group.Handle(method, path, v.wrap(h))
+		v.s.versionInfo.add(method, joinRoutePath(av.group.BasePath(), path), func() string { return v.describe(h) })
+		return av
+	}
+
+	fullPath := joinRoutePath(v.group.BasePath(), path)
+	key := method + " " + fullPath
+	v.mu.Lock()
+	vr, exists := v.routes[key]
+	if !exists {
+		vr = &versionedRoute{handlers: make(map[string]*versionedHandler)}
+		v.routes[key] = vr
+	}
+	v.mu.Unlock()
+
+	vr.mu.Lock()
+	vr.handlers[av.name] = h
+	vr.mu.Unlock()
+
+	if !exists {
+		v.group.Handle(method, path, v.dispatch(vr))
+	}
+	v.s.versionInfo.add(method, fullPath, func() string { return v.describe(h) })
+	return av
+}
+
+// describe 路由表中单个版本的描述，如 "v1 (deprecated, sunset 2026-12-31)"。
+func (v *APIVersioning) describe(h *versionedHandler) string {
+	if d := h.effectiveDeprecation(); d != nil {
+		return h.version + " (" + d.describe() + ")"
+	}
+	if v.option.Strategy != VersionByPath {
+		return h.version + " [" + v.option.Strategy.String() + "]"
+	}
+	return h.version
+}
+
+// wrap 给单个版本处理器加上弃用头回写与版本计数。
+func (v *APIVersioning) wrap(h *versionedHandler) app.HandlerFunc {
+	return func(c context.Context, ctx *app.RequestContext) {
+		ctx.Response.Header.Set(VersionResponseHeader, h.version)
+		d := h.effectiveDeprecation()
+		d.writeHeaders(ctx)
+		v.s.recordVersionRequest(c, ctx, h, d)
+		h.handler(c, ctx)
+	}
+}
+
+// dispatch VersionByHeader / VersionByMediaType 下按请求版本分发。
+// 请求的版本不存在时返回 406，避免静默落到别的版本上产生语义错误。
+func (v *APIVersioning) dispatch(vr *versionedRoute) app.HandlerFunc {
+	return func(c context.Context, ctx *app.RequestContext) {
+		version := v.requestedVersion(ctx)
+		if version == "" {
+			version = v.defaultVersion()
+		}
+
+		vr.mu.RLock()
+		h, ok := vr.handlers[version]
+		vr.mu.RUnlock()
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusNotAcceptable, Response{
+				Code: http.StatusNotAcceptable,
+				Msg:  fmt.Sprintf("不支持的 API 版本: %s", version),
+			})
+			return
+		}
+		v.wrap(h)(c, ctx)
+	}
+}
+
+// requestedVersion 按策略从请求中解析版本号；未携带时返回空串。
+func (v *APIVersioning) requestedVersion(ctx *app.RequestContext) string {
+	switch v.option.Strategy {
+	case VersionByHeader:
+		return normalizeVersion(string(ctx.GetHeader(v.option.Header)))
+	case VersionByMediaType:
+		return versionFromAccept(string(ctx.GetHeader("Accept")), v.option.Vendor)
+	}
+	return ""
+}
+
+// versionFromAccept 从 Accept 头解析版本，支持两种写法：
+//
+//	application/vnd.<vendor>.v2+json
+//	application/json; version=2
+//
+// 多个媒体类型时取第一个能解析出版本的。
+func versionFromAccept(accept, vendor string) string {
+	if accept == "" {
+		return ""
+	}
+	vendorPrefix := ""
+	if vendor != "" {
+		vendorPrefix = "application/vnd." + strings.ToLower(vendor) + "."
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if ver := params["version"]; ver != "" {
+			return normalizeVersion(ver)
+		}
+		if vendorPrefix != "" && strings.HasPrefix(mediaType, vendorPrefix) {
+			ver := strings.TrimPrefix(mediaType, vendorPrefix)
+			if i := strings.IndexByte(ver, '+'); i >= 0 {
+				ver = ver[:i]
+			}
+			if ver != "" {
+				return normalizeVersion(ver)
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeVersion 把 "2" / "V2" / " v2 " 统一为 "v2"。
+func normalizeVersion(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if version == "" {
+		return ""
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version
+}
+
+// joinRoutePath 拼接路由组前缀与相对路径，与 Hertz 的 calculateAbsolutePath 行为一致。
+func joinRoutePath(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	joined := strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(relative, "/")
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// recordVersionRequest 计入按版本维度的请求计数。
+func (s *Server) recordVersionRequest(c context.Context, ctx *app.RequestContext, h *versionedHandler, d *Deprecation) {
+	if s.httpMetrics == nil || s.httpMetrics.VersionRequests == nil {
+		return
+	}
+	route := ctx.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	s.httpMetrics.VersionRequests.Add(c, 1, metric.WithAttributes(
+		httpMetricAttr.Version.String(h.version),
+		httpMetricAttr.Deprecated.Bool(d != nil),
+		httpMetricAttr.Route.String(route),
+	))
+}
@@ -0,0 +1,188 @@
+// versioning_test.go 验证：
+//  1. VersionByPath 每个版本独立前缀，弃用版本回写 Deprecation/Sunset/Link
+//  2. VersionByHeader 按 Accept-Version 分发，缺省走默认版本，未知版本 406
+//  3. VersionByMediaType 解析 vnd 厂商媒体类型与 version 参数
+//  4. 路由表展示版本与弃用信息
+//  5. 注册路由之后再弃用版本，请求与路由表同样生效，路由级声明优先
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func newVersioningTestServer() *Server {
+	return &Server{Hertz: server.New()}
+}
+
+func versionEcho(body string) app.HandlerFunc {
+	return func(_ context.Context, ctx *app.RequestContext) {
+		ctx.String(http.StatusOK, body)
+	}
+}
+
+func TestVersioning_ByPath(t *testing.T) {
+	s := newVersioningTestServer()
+	sunset := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	api := s.APIVersions("/api", VersioningOption{})
+	api.Version("v1").Deprecate(Deprecation{Sunset: sunset, Link: "https://doc/migrate"}).
+		GET("/users", versionEcho("v1"))
+	api.Version("2").GET("/users", versionEcho("v2"))
+
+	w := ut.PerformRequest(s.Engine, http.MethodGet, "/api/v1/users", nil)
+	resp := w.Result()
+	if string(resp.Body()) != "v1" {
+		t.Fatalf("应命中 v1，实际 %q", resp.Body())
+	}
+	if got := string(resp.Header.Peek("Deprecation")); got != "true" {
+		t.Fatalf("Deprecation 头错误: %q", got)
+	}
+	if got := string(resp.Header.Peek("Sunset")); got != sunset.Format(http.TimeFormat) {
+		t.Fatalf("Sunset 头错误: %q", got)
+	}
+	if got := string(resp.Header.Peek("Link")); !strings.Contains(got, `rel="deprecation"`) {
+		t.Fatalf("Link 头错误: %q", got)
+	}
+
+	w = ut.PerformRequest(s.Engine, http.MethodGet, "/api/v2/users", nil)
+	resp = w.Result()
+	if string(resp.Body()) != "v2" {
+		t.Fatalf("应命中 v2，实际 %q", resp.Body())
+	}
+	if len(resp.Header.Peek("Deprecation")) != 0 {
+		t.Fatalf("v2 不应回写 Deprecation 头")
+	}
+	if got := string(resp.Header.Peek(VersionResponseHeader)); got != "v2" {
+		t.Fatalf("%s 应为 v2，实际 %q", VersionResponseHeader, got)
+	}
+}
+
+func TestVersioning_ByHeader(t *testing.T) {
+	s := newVersioningTestServer()
+	since := time.Unix(1767196800, 0)
+	api := s.APIVersions("/api", VersioningOption{Strategy: VersionByHeader})
+	api.Version("v1").GET("/users", versionEcho("v1"), Deprecated(Deprecation{Since: since}))
+	api.Version("v2").GET("/users", versionEcho("v2"))
+
+	cases := []struct {
+		header string
+		status int
+		body   string
+	}{
+		{header: "v1", status: http.StatusOK, body: "v1"},
+		{header: "2", status: http.StatusOK, body: "v2"},
+		{header: "", status: http.StatusOK, body: "v1"}, // 缺省取最老的未弃用版本（v1 只弃用了单条路由）
+		{header: "v9", status: http.StatusNotAcceptable},
+	}
+	for _, tc := range cases {
+		var headers []ut.Header
+		if tc.header != "" {
+			headers = append(headers, ut.Header{Key: DefaultVersionHeader, Value: tc.header})
+		}
+		resp := ut.PerformRequest(s.Engine, http.MethodGet, "/api/users", nil, headers...).Result()
+		if resp.StatusCode() != tc.status {
+			t.Fatalf("header=%q 期望 %d，实际 %d", tc.header, tc.status, resp.StatusCode())
+		}
+		if tc.body != "" && string(resp.Body()) != tc.body {
+			t.Fatalf("header=%q 期望 %q，实际 %q", tc.header, tc.body, resp.Body())
+		}
+		if tc.body == "v1" && string(resp.Header.Peek("Deprecation")) != "@1767196800" {
+			t.Fatalf("v1 Deprecation 头错误: %q", resp.Header.Peek("Deprecation"))
+		}
+	}
+}
+
+func TestVersioning_DefaultVersion(t *testing.T) {
+	s := newVersioningTestServer()
+	api := s.APIVersions("/api", VersioningOption{Strategy: VersionByHeader})
+	// 注册顺序与版本号无关；整个版本弃用后缺省版本顺延到下一个
+	api.Version("v10").GET("/users", versionEcho("v10"))
+	api.Version("v2").GET("/users", versionEcho("v2"))
+	api.Version("v1").GET("/users", versionEcho("v1"))
+	api.Version("v1").Deprecate(Deprecation{})
+
+	if resp := ut.PerformRequest(s.Engine, http.MethodGet, "/api/users", nil).Result(); string(resp.Body()) != "v2" {
+		t.Fatalf("缺省应取最老的未弃用版本 v2，实际 %q", resp.Body())
+	}
+	api.Version("v2").Deprecate(Deprecation{})
+	api.Version("v10").Deprecate(Deprecation{})
+	if got := api.defaultVersion(); got != "v1" {
+		t.Fatalf("全部弃用时应取最老的版本 v1，实际 %q", got)
+	}
+
+	pinned := s.APIVersions("/pinned", VersioningOption{Strategy: VersionByHeader, DefaultVersion: "3"})
+	if got := pinned.defaultVersion(); got != "v3" {
+		t.Fatalf("显式 DefaultVersion 应优先，实际 %q", got)
+	}
+}
+
+func TestVersioning_VersionFromAccept(t *testing.T) {
+	cases := map[string]string{
+		"application/vnd.gaia.v2+json":                  "v2",
+		"text/html, application/vnd.gaia.v3+json;q=0.9": "v3",
+		"application/json; version=1":                   "v1",
+		"application/json":                              "",
+		"application/vnd.other.v2+json":                 "",
+		"":                                              "",
+	}
+	for accept, want := range cases {
+		if got := versionFromAccept(accept, "gaia"); got != want {
+			t.Fatalf("accept=%q 期望 %q，实际 %q", accept, want, got)
+		}
+	}
+}
+
+func TestVersioning_RoutesTable(t *testing.T) {
+	s := newVersioningTestServer()
+	api := s.APIVersions("/api", VersioningOption{Strategy: VersionByMediaType, Vendor: "gaia"})
+	api.Version("v1").Deprecate(Deprecation{Sunset: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)}).
+		GET("/orders", versionEcho("v1"))
+	api.Version("v2").GET("/orders", versionEcho("v2"))
+
+	table := s.formatRoutesInfo(s.Routes())
+	if !strings.Contains(table, "v1 (deprecated, sunset 2026-12-31)") {
+		t.Fatalf("路由表缺少弃用信息:\n%s", table)
+	}
+	if !strings.Contains(table, "v2 [media-type]") {
+		t.Fatalf("路由表缺少版本信息:\n%s", table)
+	}
+}
+
+func TestVersioning_DeprecateAfterRegistration(t *testing.T) {
+	s := newVersioningTestServer()
+	since := time.Unix(1767196800, 0)
+	api := s.APIVersions("/api", VersioningOption{Strategy: VersionByHeader})
+	v1 := api.Version("v1")
+	v1.GET("/users", versionEcho("v1"))
+	v1.POST("/users", versionEcho("v1"), Deprecated(Deprecation{Since: since}))
+	api.Version("v2").GET("/users", versionEcho("v2"))
+
+	v1.Deprecate(Deprecation{Sunset: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)})
+
+	header := ut.Header{Key: DefaultVersionHeader, Value: "v1"}
+	resp := ut.PerformRequest(s.Engine, http.MethodGet, "/api/users", nil, header).Result()
+	if string(resp.Header.Peek("Deprecation")) != "true" || len(resp.Header.Peek("Sunset")) == 0 {
+		t.Fatalf("后续 Deprecate 应作用于已注册的 GET: Deprecation=%q Sunset=%q",
+			resp.Header.Peek("Deprecation"), resp.Header.Peek("Sunset"))
+	}
+	resp = ut.PerformRequest(s.Engine, http.MethodPost, "/api/users", nil, header).Result()
+	if string(resp.Header.Peek("Deprecation")) != "@1767196800" || len(resp.Header.Peek("Sunset")) != 0 {
+		t.Fatalf("路由级声明应优先于版本级: Deprecation=%q Sunset=%q",
+			resp.Header.Peek("Deprecation"), resp.Header.Peek("Sunset"))
+	}
+
+	table := s.formatRoutesInfo(s.Routes())
+	if !strings.Contains(table, "v1 (deprecated, sunset 2026-12-31)") {
+		t.Fatalf("路由表应体现注册后的弃用声明:\n%s", table)
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -40,6 +41,7 @@ import (
 	"github.com/hertz-contrib/websocket"
 
 	"github.com/xxzhwl/gaia"
+	"github.com/xxzhwl/gaia/errwrap"
 )
 
 // WSMessageType 常量别名（避免上游直接依赖 hertz-contrib/websocket）。
@@ -190,6 +192,7 @@ type WSConfig struct {
 
 	// NewHandler 每次 Upgrade 成功后调用一次，返回本连接独占的处理器。
 	// 可在此闭包中读取 *app.RequestContext（鉴权信息、URL 参数等）并绑定到 handler 上。
+	// 返回 err 时拒绝升级：错误码为 4xx/5xx 时作为 HTTP 状态码（如 401 / 403），否则返回 400。
 	// 必填。
 	NewHandler func(ctx context.Context, c *app.RequestContext) (WSConnHandler, error)
 
@@ -215,6 +218,15 @@ type WSConfig struct {
 	PingInterval time.Duration
 }
 
+// wsHandshakeStatus 握手阶段拒绝连接时的 HTTP 状态码：错误码本身是合法的 4xx/5xx 时直接复用，
+// 否则返回 fallback。
+func wsHandshakeStatus(err error, fallback int) int {
+	if code := errwrap.GetCode(err); code >= 400 && code < 600 {
+		return int(code)
+	}
+	return fallback
+}
+
 // MakeWSHandler 基于 WSConfig 创建 hertz 路由处理器。
 //
 // 示例：
@@ -247,7 +259,7 @@ func MakeWSHandler(cfg WSConfig) app.HandlerFunc {
 		if err != nil {
 			cancel()
 			gaia.ErrorF("[%s] ws NewHandler err: %v", cfg.Title, err)
-			c.AbortWithMsg(err.Error(), 400)
+			c.AbortWithMsg(err.Error(), wsHandshakeStatus(err, http.StatusBadRequest))
 			return
 		}
 		remote := c.ClientIP()
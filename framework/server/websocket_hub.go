@@ -0,0 +1,549 @@
+// Package server
+//
+// WebSocket Hub：连接注册表 + 房间 + 广播 + 跨实例扇出
+// =========================================================================
+// MakeWSHandler 只管理单个 WSConn，业务需要"按房间/按用户/全员推送"时都得
+// 自己维护连接表。WSHub 把这部分收口：
+//
+//  1. 连接注册：Hub.Handler() 生成的路由在握手时鉴权（WSHubConfig.Authenticate），
+//     把 WSIdentity（用户 ID + Principal）绑定到 WSClient，断开时自动注销并退出所有房间；
+//  2. 房间：Join / Leave / LeaveAll，房间随最后一个成员离开自动回收；
+//  3. 广播：BroadcastRoom / BroadcastUser / BroadcastAll，先推本地连接，再经
+//     FanoutBackend 投递给其它副本，其它副本收到后推给各自的本地连接；
+//  4. 在线查询：Online / RoomMembers / Rooms / Count（仅反映当前副本）；
+//  5. 背压：每连接一个有界发送队列 + 独立写协程，慢消费者按 SlowConsumerPolicy
+//     丢弃消息或直接断开，绝不阻塞广播方。
+//
+// 用法：
+//
+//	hub := server.NewWSHub(server.WSHubConfig{
+//	    Title:        "Notify",
+//	    Authenticate: accountManager.Middleware().WSAuthenticator(),
+//	    Fanout:       server.NewRedisFanout(redis.NewFrameworkClient()),
+//	    OnOpen: func(ctx context.Context, c *server.WSClient) error {
+//	        return c.Join("user:" + c.Identity().UserID)
+//	    },
+//	})
+//	defer hub.Close()
+//	s.GET("/ws", hub.Handler())
+//	_ = hub.BroadcastRoom(ctx, "order:42", server.WSTextMessage, payload)
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/xxzhwl/gaia"
+	"github.com/xxzhwl/gaia/errwrap"
+)
+
+// SlowConsumerPolicy 发送队列写满时对慢消费者的处理策略。
+type SlowConsumerPolicy int
+
+const (
+	// WSDropMessage 丢弃当前消息，连接保留（默认）。
+	WSDropMessage SlowConsumerPolicy = iota
+	// WSDisconnect 断开慢消费者，由客户端重连后重新拉取状态。
+	WSDisconnect
+)
+
+// ErrWSClientNotFound 连接不在本实例上。
+var ErrWSClientNotFound = errors.New("ws client not found")
+
+// WSIdentity 握手阶段鉴权得到的连接身份。
+type WSIdentity struct {
+	// UserID 用户 ID，BroadcastUser 按它路由；匿名连接为空。
+	UserID string
+	// Principal 鉴权主体（如 *account.Principal），Hub 只透传不解析。
+	Principal any
+	// Meta 业务附加信息（设备、租户等）。
+	Meta map[string]string
+}
+
+// WSHubConfig WSHub 配置。
+type WSHubConfig struct {
+	// Title 用于日志与扇出 channel 的业务标识，默认 "WSHub"。
+	Title string
+
+	// WS 底层连接配置（超时、Upgrader 等）；NewHandler 字段由 Hub 接管，无需填写。
+	WS WSConfig
+
+	// Authenticate 握手鉴权，返回 err 时拒绝升级：默认 401，错误码为 4xx/5xx 时沿用（如 403 无权限）；
+	// 为 nil 时所有连接均为匿名。
+	Authenticate func(ctx context.Context, c *app.RequestContext) (*WSIdentity, error)
+
+	// OnOpen 连接注册到 Hub 后调用，常用于加入默认房间；返回 err 会关闭连接。
+	OnOpen func(ctx context.Context, client *WSClient) error
+	// OnMessage 收到客户端消息时调用。
+	OnMessage func(ctx context.Context, client *WSClient, messageType int, data []byte) error
+	// OnClose 连接注销（已退出全部房间）后调用。
+	OnClose func(ctx context.Context, client *WSClient, err error)
+
+	// SendBuffer 每连接发送队列长度，默认 256。
+	SendBuffer int
+	// SlowConsumer 发送队列写满时的处理策略，默认 WSDropMessage。
+	SlowConsumer SlowConsumerPolicy
+
+	// Fanout 跨实例扇出后端；为 nil 时只推送本实例连接。
+	Fanout FanoutBackend
+	// Channel 扇出 channel 名，默认 "gaia:wshub:<Title>"。
+	Channel string
+}
+
+// wsOutbound 发送队列中的一条消息。
+type wsOutbound struct {
+	messageType int
+	data        []byte
+}
+
+// WSClient Hub 内的一条连接。
+type WSClient struct {
+	hub      *WSHub
+	conn     *WSConn
+	identity WSIdentity
+	send     chan wsOutbound
+	dropped  atomic.Int64
+
+	mu    sync.Mutex
+	rooms map[string]struct{}
+}
+
+// ID 连接 ID。
+func (c *WSClient) ID() string { return c.conn.ID() }
+
+// Conn 底层连接；直接 Send 会绕过发送队列，仅用于需要同步写结果的场景。
+func (c *WSClient) Conn() *WSConn { return c.conn }
+
+// Identity 握手时绑定的身份。
+func (c *WSClient) Identity() WSIdentity { return c.identity }
+
+// Dropped 因背压被丢弃的消息数。
+func (c *WSClient) Dropped() int64 { return c.dropped.Load() }
+
+// Rooms 当前加入的房间。
+func (c *WSClient) Rooms() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rooms := make([]string, 0, len(c.rooms))
+	for r := range c.rooms {
+		rooms = append(rooms, r)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Join 加入房间。
+func (c *WSClient) Join(room string) error { return c.hub.Join(c.ID(), room) }
+
+// Leave 离开房间。
+func (c *WSClient) Leave(room string) error { return c.hub.Leave(c.ID(), room) }
+
+// Enqueue 把消息放入发送队列，队列满时按 SlowConsumerPolicy 处理。
+func (c *WSClient) Enqueue(messageType int, data []byte) {
+	if c.conn.IsClosed() {
+		return
+	}
+	select {
+	case c.send <- wsOutbound{messageType: messageType, data: data}:
+		return
+	default:
+	}
+	if c.hub.cfg.SlowConsumer == WSDisconnect {
+		gaia.WarnF("[%s] ws 慢消费者已断开 id=%s user=%s", c.hub.cfg.Title, c.ID(), c.identity.UserID)
+		_ = c.conn.Close()
+		return
+	}
+	if c.dropped.Add(1)%100 == 1 {
+		gaia.WarnF("[%s] ws 发送队列已满，丢弃消息 id=%s user=%s dropped=%d",
+			c.hub.cfg.Title, c.ID(), c.identity.UserID, c.dropped.Load())
+	}
+}
+
+// writeLoop 独立写协程：串行消费发送队列，任一写失败即关闭连接。
+func (c *WSClient) writeLoop() {
+	for {
+		select {
+		case <-c.conn.Done():
+			return
+		case msg := <-c.send:
+			if err := c.conn.writeMessage(msg.messageType, msg.data); err != nil {
+				gaia.DebugF("[%s] ws 写入失败 id=%s: %v", c.hub.cfg.Title, c.ID(), err)
+				_ = c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// WSPresence 在线查询结果。
+type WSPresence struct {
+	ClientID string `json:"client_id"`
+	UserID   string `json:"user_id"`
+	Remote   string `json:"remote"`
+}
+
+// wsEnvelope 跨实例扇出的消息信封。
+type wsEnvelope struct {
+	Origin      string `json:"origin"`
+	Scope       string `json:"scope"` // room / user / all
+	Target      string `json:"target,omitempty"`
+	MessageType int    `json:"message_type"`
+	Data        []byte `json:"data"`
+}
+
+const (
+	wsScopeRoom = "room"
+	wsScopeUser = "user"
+	wsScopeAll  = "all"
+)
+
+// WSHub WebSocket 连接注册表。
+type WSHub struct {
+	cfg        WSHubConfig
+	instanceID string
+
+	mu      sync.RWMutex
+	clients map[string]*WSClient
+	rooms   map[string]map[string]*WSClient
+	users   map[string]map[string]*WSClient
+
+	unsubscribe func()
+	closeOnce   sync.Once
+}
+
+// NewWSHub 创建 Hub；配置了 Fanout 时立即订阅扇出 channel，订阅失败只告警，
+// Hub 退化为单实例推送。
+func NewWSHub(cfg WSHubConfig) *WSHub {
+	if cfg.Title == "" {
+		cfg.Title = "WSHub"
+	}
+	if cfg.SendBuffer <= 0 {
+		cfg.SendBuffer = 256
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = "gaia:wshub:" + cfg.Title
+	}
+	if cfg.WS.Title == "" {
+		cfg.WS.Title = cfg.Title
+	}
+
+	h := &WSHub{
+		cfg:        cfg,
+		instanceID: gaia.GetUUID(),
+		clients:    make(map[string]*WSClient),
+		rooms:      make(map[string]map[string]*WSClient),
+		users:      make(map[string]map[string]*WSClient),
+	}
+	if cfg.Fanout != nil {
+		unsub, err := cfg.Fanout.Subscribe(context.Background(), cfg.Channel, h.onFanout)
+		if err != nil {
+			gaia.ErrorF("[%s] ws hub 订阅扇出失败，仅推送本实例连接: %v", cfg.Title, err)
+		} else {
+			h.unsubscribe = unsub
+		}
+	}
+	return h
+}
+
+// Close 取消扇出订阅并关闭全部本地连接。幂等。
+func (h *WSHub) Close() {
+	h.closeOnce.Do(func() {
+		if h.unsubscribe != nil {
+			h.unsubscribe()
+		}
+		h.mu.RLock()
+		clients := make([]*WSClient, 0, len(h.clients))
+		for _, c := range h.clients {
+			clients = append(clients, c)
+		}
+		h.mu.RUnlock()
+		for _, c := range clients {
+			_ = c.conn.Close()
+		}
+	})
+}
+
+// Handler 生成挂载到路由上的 WebSocket 处理器。
+func (h *WSHub) Handler() app.HandlerFunc {
+	wsCfg := h.cfg.WS
+	wsCfg.NewHandler = func(ctx context.Context, c *app.RequestContext) (WSConnHandler, error) {
+		identity := &WSIdentity{}
+		if h.cfg.Authenticate != nil {
+			id, err := h.cfg.Authenticate(ctx, c)
+			if err != nil {
+				return nil, wsAuthError(err)
+			}
+			if id != nil {
+				identity = id
+			}
+		}
+		return &wsHubConnHandler{hub: h, identity: *identity}, nil
+	}
+	return MakeWSHandler(wsCfg)
+}
+
+// wsAuthError 握手鉴权失败默认按未认证（401）拒绝；错误码已是 4xx/5xx（如 403 无权限）时保持不变。
+func wsAuthError(err error) error {
+	if wsHandshakeStatus(err, 0) != 0 {
+		return err
+	}
+	msg := err.Error()
+	if logicErr, ok := err.(errwrap.LogicError); ok {
+		msg = logicErr.GetMessage()
+	}
+	return errwrap.New("", http.StatusUnauthorized, msg)
+}
+
+// wsHubConnHandler 把单连接生命周期桥接到 Hub。
+type wsHubConnHandler struct {
+	hub      *WSHub
+	identity WSIdentity
+	client   *WSClient
+}
+
+func (w *wsHubConnHandler) OnOpen(ctx context.Context, conn *WSConn) error {
+	w.client = w.hub.register(conn, w.identity)
+	go w.client.writeLoop()
+	if w.hub.cfg.OnOpen != nil {
+		return w.hub.cfg.OnOpen(ctx, w.client)
+	}
+	return nil
+}
+
+func (w *wsHubConnHandler) OnMessage(ctx context.Context, _ *WSConn, mt int, data []byte) error {
+	if w.hub.cfg.OnMessage != nil {
+		return w.hub.cfg.OnMessage(ctx, w.client, mt, data)
+	}
+	return nil
+}
+
+func (w *wsHubConnHandler) OnClose(ctx context.Context, _ *WSConn, err error) {
+	if w.client == nil {
+		return
+	}
+	w.hub.unregister(w.client)
+	if w.hub.cfg.OnClose != nil {
+		w.hub.cfg.OnClose(ctx, w.client, err)
+	}
+}
+
+// register 把连接加入注册表。
+func (h *WSHub) register(conn *WSConn, identity WSIdentity) *WSClient {
+	c := &WSClient{
+		hub:      h,
+		conn:     conn,
+		identity: identity,
+		send:     make(chan wsOutbound, h.cfg.SendBuffer),
+		rooms:    make(map[string]struct{}),
+	}
+	h.mu.Lock()
+	h.clients[c.ID()] = c
+	if identity.UserID != "" {
+		if h.users[identity.UserID] == nil {
+			h.users[identity.UserID] = make(map[string]*WSClient)
+		}
+		h.users[identity.UserID][c.ID()] = c
+	}
+	h.mu.Unlock()
+	return c
+}
+
+// unregister 注销连接并退出全部房间。
+func (h *WSHub) unregister(c *WSClient) {
+	h.mu.Lock()
+	delete(h.clients, c.ID())
+	if uid := c.identity.UserID; uid != "" {
+		delete(h.users[uid], c.ID())
+		if len(h.users[uid]) == 0 {
+			delete(h.users, uid)
+		}
+	}
+	c.mu.Lock()
+	for room := range c.rooms {
+		h.removeFromRoomLocked(room, c.ID())
+	}
+	c.rooms = make(map[string]struct{})
+	c.mu.Unlock()
+	h.mu.Unlock()
+}
+
+func (h *WSHub) removeFromRoomLocked(room, clientID string) {
+	members := h.rooms[room]
+	if members == nil {
+		return
+	}
+	delete(members, clientID)
+	if len(members) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// Join 让本实例上的连接加入房间。
+func (h *WSHub) Join(clientID, room string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.clients[clientID]
+	if !ok {
+		return ErrWSClientNotFound
+	}
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[string]*WSClient)
+	}
+	h.rooms[room][clientID] = c
+	c.mu.Lock()
+	c.rooms[room] = struct{}{}
+	c.mu.Unlock()
+	return nil
+}
+
+// Leave 让本实例上的连接离开房间。
+func (h *WSHub) Leave(clientID, room string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.clients[clientID]
+	if !ok {
+		return ErrWSClientNotFound
+	}
+	h.removeFromRoomLocked(room, clientID)
+	c.mu.Lock()
+	delete(c.rooms, room)
+	c.mu.Unlock()
+	return nil
+}
+
+// Client 按 ID 获取本实例上的连接。
+func (h *WSHub) Client(clientID string) (*WSClient, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	c, ok := h.clients[clientID]
+	return c, ok
+}
+
+// Count 本实例连接数。
+func (h *WSHub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Online 用户在本实例上是否有连接。
+func (h *WSHub) Online(userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.users[userID]) > 0
+}
+
+// Rooms 本实例上非空的房间列表。
+func (h *WSHub) Rooms() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make([]string, 0, len(h.rooms))
+	for r := range h.rooms {
+		rooms = append(rooms, r)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// RoomMembers 房间在本实例上的成员。
+func (h *WSHub) RoomMembers(room string) []WSPresence {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	members := make([]WSPresence, 0, len(h.rooms[room]))
+	for _, c := range h.rooms[room] {
+		members = append(members, WSPresence{
+			ClientID: c.ID(),
+			UserID:   c.identity.UserID,
+			Remote:   c.conn.RemoteAddr(),
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ClientID < members[j].ClientID })
+	return members
+}
+
+// BroadcastRoom 推送给房间内全部连接（含其它副本）。
+func (h *WSHub) BroadcastRoom(ctx context.Context, room string, messageType int, data []byte) error {
+	return h.broadcast(ctx, wsEnvelope{Scope: wsScopeRoom, Target: room, MessageType: messageType, Data: data})
+}
+
+// BroadcastUser 推送给用户的全部连接（含其它副本）。
+func (h *WSHub) BroadcastUser(ctx context.Context, userID string, messageType int, data []byte) error {
+	return h.broadcast(ctx, wsEnvelope{Scope: wsScopeUser, Target: userID, MessageType: messageType, Data: data})
+}
+
+// BroadcastAll 推送给全部连接（含其它副本）。
+func (h *WSHub) BroadcastAll(ctx context.Context, messageType int, data []byte) error {
+	return h.broadcast(ctx, wsEnvelope{Scope: wsScopeAll, MessageType: messageType, Data: data})
+}
+
+// BroadcastRoomJSON 以 JSON 文本消息推送给房间。
+func (h *WSHub) BroadcastRoomJSON(ctx context.Context, room string, v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return h.BroadcastRoom(ctx, room, WSTextMessage, bs)
+}
+
+// broadcast 先推本地，再经扇出后端投递给其它副本。
+// 扇出失败只影响其它副本，本地推送已完成，因此返回 err 供调用方决定是否重试。
+func (h *WSHub) broadcast(ctx context.Context, env wsEnvelope) error {
+	h.deliverLocal(env)
+	if h.cfg.Fanout == nil {
+		return nil
+	}
+	env.Origin = h.instanceID
+	bs, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return h.cfg.Fanout.Publish(ctx, h.cfg.Channel, bs)
+}
+
+// onFanout 处理其它副本扇出过来的消息，跳过自身发出的消息避免重复推送。
+func (h *WSHub) onFanout(payload []byte) {
+	var env wsEnvelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		gaia.WarnF("[%s] ws hub 扇出消息解析失败: %v", h.cfg.Title, err)
+		return
+	}
+	if env.Origin == h.instanceID {
+		return
+	}
+	h.deliverLocal(env)
+}
+
+// deliverLocal 推送给本实例上命中的连接。
+func (h *WSHub) deliverLocal(env wsEnvelope) {
+	h.mu.RLock()
+	var targets []*WSClient
+	switch env.Scope {
+	case wsScopeRoom:
+		for _, c := range h.rooms[env.Target] {
+			targets = append(targets, c)
+		}
+	case wsScopeUser:
+		for _, c := range h.users[env.Target] {
+			targets = append(targets, c)
+		}
+	case wsScopeAll:
+		for _, c := range h.clients {
+			targets = append(targets, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range targets {
+		c.Enqueue(env.MessageType, env.Data)
+	}
+}
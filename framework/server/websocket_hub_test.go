@@ -0,0 +1,160 @@
+// websocket_hub_test.go 验证：
+//  1. 房间加入/离开、注销后自动退出房间
+//  2. 按房间/用户/全员广播只命中目标连接
+//  3. 共享 MemoryFanout 的两个 Hub 之间跨实例投递，且不重复投递给本实例
+//  4. 发送队列写满时 WSDropMessage 丢弃并计数
+//  5. 握手鉴权失败按错误码返回 401 / 403
+//
+// 测试不建立真实 WebSocket：直接 register 一个无底层连接的 WSConn，
+// 且不启动 writeLoop，通过检查发送队列断言投递结果。
+//
+// @author wanlizhan
+// @created 2026-10-18
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/xxzhwl/gaia/errwrap"
+)
+
+func newTestWSClient(h *WSHub, id, userID string) *WSClient {
+	conn := &WSConn{closed: make(chan struct{}), id: id, remote: "127.0.0.1"}
+	return h.register(conn, WSIdentity{UserID: userID})
+}
+
+func drainWS(c *WSClient) []string {
+	var out []string
+	for {
+		select {
+		case msg := <-c.send:
+			out = append(out, string(msg.data))
+		default:
+			return out
+		}
+	}
+}
+
+func TestWSHub_RoomsAndPresence(t *testing.T) {
+	h := NewWSHub(WSHubConfig{})
+	a := newTestWSClient(h, "a", "u1")
+	b := newTestWSClient(h, "b", "u2")
+
+	if err := a.Join("order:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Join("order:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Join("missing", "order:1"); err != ErrWSClientNotFound {
+		t.Fatalf("未注册连接应返回 ErrWSClientNotFound，实际 %v", err)
+	}
+	if got := len(h.RoomMembers("order:1")); got != 2 {
+		t.Fatalf("房间应有 2 个成员，实际 %d", got)
+	}
+	if !h.Online("u1") || h.Online("u3") {
+		t.Fatalf("在线状态错误")
+	}
+
+	h.unregister(a)
+	if got := h.RoomMembers("order:1"); len(got) != 1 || got[0].ClientID != "b" {
+		t.Fatalf("注销后房间成员错误: %+v", got)
+	}
+	if h.Online("u1") {
+		t.Fatalf("注销后 u1 应离线")
+	}
+
+	_ = b.Leave("order:1")
+	if rooms := h.Rooms(); len(rooms) != 0 {
+		t.Fatalf("空房间应被回收，实际 %v", rooms)
+	}
+}
+
+func TestWSHub_BroadcastTargets(t *testing.T) {
+	h := NewWSHub(WSHubConfig{})
+	a := newTestWSClient(h, "a", "u1")
+	b := newTestWSClient(h, "b", "u1")
+	c := newTestWSClient(h, "c", "u2")
+	_ = a.Join("r1")
+
+	ctx := context.Background()
+	_ = h.BroadcastRoom(ctx, "r1", WSTextMessage, []byte("room"))
+	_ = h.BroadcastUser(ctx, "u1", WSTextMessage, []byte("user"))
+	_ = h.BroadcastAll(ctx, WSTextMessage, []byte("all"))
+
+	if got := drainWS(a); len(got) != 3 {
+		t.Fatalf("a 应收到 3 条，实际 %v", got)
+	}
+	if got := drainWS(b); len(got) != 2 || got[0] != "user" {
+		t.Fatalf("b 应收到 user+all，实际 %v", got)
+	}
+	if got := drainWS(c); len(got) != 1 || got[0] != "all" {
+		t.Fatalf("c 应只收到 all，实际 %v", got)
+	}
+}
+
+func TestWSHub_CrossInstanceFanout(t *testing.T) {
+	bus := NewMemoryFanout()
+	h1 := NewWSHub(WSHubConfig{Title: "T", Fanout: bus})
+	h2 := NewWSHub(WSHubConfig{Title: "T", Fanout: bus})
+	defer h1.unsubscribe()
+	defer h2.unsubscribe()
+
+	a := newTestWSClient(h1, "a", "u1")
+	b := newTestWSClient(h2, "b", "u1")
+
+	if err := h1.BroadcastUser(context.Background(), "u1", WSTextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if got := drainWS(a); len(got) != 1 {
+		t.Fatalf("本实例连接应只收到 1 条（不重复），实际 %v", got)
+	}
+	if got := drainWS(b); len(got) != 1 || got[0] != "hi" {
+		t.Fatalf("其它实例连接应收到扇出消息，实际 %v", got)
+	}
+}
+
+func TestWSHub_SlowConsumerDrop(t *testing.T) {
+	h := NewWSHub(WSHubConfig{SendBuffer: 2})
+	a := newTestWSClient(h, "a", "")
+	for i := 0; i < 5; i++ {
+		_ = h.BroadcastAll(context.Background(), WSTextMessage, []byte("x"))
+	}
+	if got := len(drainWS(a)); got != 2 {
+		t.Fatalf("队列应保留 2 条，实际 %d", got)
+	}
+	if a.Dropped() != 3 {
+		t.Fatalf("应丢弃 3 条，实际 %d", a.Dropped())
+	}
+}
+
+func TestWSHub_AuthenticateStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain error", errors.New("missing token"), http.StatusUnauthorized},
+		{"invalid token", errwrap.Error(http.StatusUnauthorized, errors.New("token expired")), http.StatusUnauthorized},
+		{"permission denied", errwrap.Error(http.StatusForbidden, errors.New("no access")), http.StatusForbidden},
+		{"business code", errwrap.Errorf(1001, "tenant disabled"), http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newVersioningTestServer()
+			h := NewWSHub(WSHubConfig{Authenticate: func(context.Context, *app.RequestContext) (*WSIdentity, error) {
+				return nil, tc.err
+			}})
+			defer h.Close()
+			s.GET("/ws", h.Handler())
+			if got := ut.PerformRequest(s.Engine, http.MethodGet, "/ws", nil).Result().StatusCode(); got != tc.want {
+				t.Fatalf("握手鉴权失败应返回 %d，实际 %d", tc.want, got)
+			}
+		})
+	}
+}
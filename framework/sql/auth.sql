@@ -1,11 +1,19 @@
 CREATE TABLE `sys_auth` (
                             `id` int(11) NOT NULL AUTO_INCREMENT,
                             `sys_id` varchar(32) COLLATE utf8_bin NOT NULL DEFAULT '',
+                            `key_id` varchar(32) COLLATE utf8_bin NOT NULL DEFAULT '',
                             `sys_key` varchar(512) COLLATE utf8_bin NOT NULL DEFAULT '',
                             `enable` tinyint(4) NOT NULL DEFAULT '0',
                             `duty` varchar(32) COLLATE utf8_bin NOT NULL DEFAULT '',
+                            `expire_time` datetime DEFAULT NULL,
                             `create_time` datetime DEFAULT NULL,
                             `update_time` datetime DEFAULT NULL,
                             PRIMARY KEY (`id`),
-                            KEY `sys_auth_sys_id_index` (`sys_id`)
-) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
\ No newline at end of file
+                            KEY `sys_auth_sys_id_index` (`sys_id`),
+                            UNIQUE KEY `sys_auth_sys_id_key_id_uindex` (`sys_id`, `key_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- 已有库升级：同一 sys_id 可配置多把密钥（key_id 区分），轮换时给旧密钥设置 expire_time
+-- ALTER TABLE `sys_auth` ADD COLUMN `key_id` varchar(32) COLLATE utf8_bin NOT NULL DEFAULT '' AFTER `sys_id`,
+--     ADD COLUMN `expire_time` datetime DEFAULT NULL AFTER `duty`,
+--     ADD UNIQUE KEY `sys_auth_sys_id_key_id_uindex` (`sys_id`, `key_id`);